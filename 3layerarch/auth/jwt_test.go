@@ -0,0 +1,174 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"3layerarch/auth"
+	"3layerarch/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/task", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func issue(t *testing.T, i *auth.Issuer) string {
+	t.Helper()
+	tok, err := i.Issue(models.Principal{UserID: 7, Name: "alice"})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	return tok.AccessToken
+}
+
+func TestJWT_HS256(t *testing.T) {
+	keys := &auth.KeySet{}
+	keys.AddHS256("", secret)
+	j := &auth.JWT{Keys: keys, Issuer: "test"}
+
+	tok, err := auth.NewHS256Issuer("", secret, "test", time.Hour).Issue(models.Principal{UserID: 7, Name: "alice"})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if tok.TokenType != "Bearer" || tok.ExpiresIn != 3600 {
+		t.Errorf("unexpected token: %+v", tok)
+	}
+
+	p, err := j.Authenticate(bearer(tok.AccessToken))
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if p != (models.Principal{UserID: 7, Name: "alice", Role: models.RoleUser, Method: "jwt"}) {
+		t.Errorf("Authenticate() = %+v", p)
+	}
+
+	// The role a token was issued with comes back with it
+	tok, err = auth.NewHS256Issuer("", secret, "test", time.Hour).Issue(models.Principal{UserID: 1, Name: "root", Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if p, err := j.Authenticate(bearer(tok.AccessToken)); err != nil || p.Role != models.RoleAdmin {
+		t.Errorf("Authenticate() = %+v, %v, want an admin", p, err)
+	}
+}
+
+func TestJWT_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &auth.KeySet{}
+	keys.AddRS256("k1", &key.PublicKey)
+	j := &auth.JWT{Keys: keys}
+
+	p, err := j.Authenticate(bearer(issue(t, auth.NewRS256Issuer("k1", key, "test", time.Hour))))
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if p.UserID != 7 {
+		t.Errorf("Authenticate() = %+v", p)
+	}
+
+	// A key ID the set does not know is rejected
+	if _, err := j.Authenticate(bearer(issue(t, auth.NewRS256Issuer("k2", key, "test", time.Hour)))); err == nil {
+		t.Error("expected an unknown key ID to be rejected")
+	}
+}
+
+func TestJWT_Invalid(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &auth.KeySet{}
+	keys.AddHS256("", secret)
+	keys.AddRS256("", &key.PublicKey)
+	j := &auth.JWT{Keys: keys, Issuer: "test"}
+
+	// Signing with the RSA public key as an HMAC secret must not pass
+	pub := x509.MarshalPKCS1PublicKey(&key.PublicKey)
+	confused := issue(t, auth.NewHS256Issuer("", pub, "test", time.Hour))
+
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"sub": "7", "iss": "test", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{name: "expired", token: issue(t, auth.NewHS256Issuer("", secret, "test", -time.Minute)), want: "token has expired"},
+		{name: "wrong issuer", token: issue(t, auth.NewHS256Issuer("", secret, "other", time.Hour)), want: "invalid token"},
+		{name: "wrong secret", token: issue(t, auth.NewHS256Issuer("", []byte(strings.Repeat("x", 32)), "test", time.Hour)), want: "invalid token"},
+		{name: "algorithm confusion", token: confused, want: "invalid token"},
+		{name: "alg none", token: none, want: "invalid token"},
+		{name: "garbage", token: "not.a.token", want: "invalid token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := j.Authenticate(bearer(tt.token))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Authenticate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/task", nil)
+	r.Header.Set("Authorization", "Basic YWxpY2U6cHc=")
+	if _, err := j.Authenticate(r); err != auth.ErrNoCredentials {
+		t.Errorf("Authenticate() with basic auth error = %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestReadRSAKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	priv := filepath.Join(dir, "2024-01.pem")
+	pub := filepath.Join(dir, "2024-01.pub.pem")
+	writePEM(t, priv, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	writePEM(t, pub, "PUBLIC KEY", der)
+
+	if _, err := auth.ReadRSAPrivateKey(priv); err != nil {
+		t.Errorf("ReadRSAPrivateKey() error = %v", err)
+	}
+	got, err := auth.ReadRSAPublicKey(pub)
+	if err != nil {
+		t.Fatalf("ReadRSAPublicKey() error = %v", err)
+	}
+	if !got.Equal(&key.PublicKey) {
+		t.Error("ReadRSAPublicKey() returned a different key")
+	}
+	if _, err := auth.ReadRSAPublicKey(priv); err == nil {
+		t.Error("expected a private key to be rejected as a public key")
+	}
+	if id := auth.KeyID(priv); id != "2024-01" {
+		t.Errorf("KeyID() = %q, want 2024-01", id)
+	}
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
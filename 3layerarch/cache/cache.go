@@ -0,0 +1,21 @@
+// Package cache holds values for a limited time, in process or in Redis, for
+// the caching stores to keep what they read.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Backend stores values by key until their time to live runs out. A value
+// may also be dropped earlier, so a miss never means more than that the
+// value must be loaded again.
+type Backend interface {
+	// Get returns the value of key and whether there is one.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete drops the values of keys; keys without one are ignored.
+	Delete(ctx context.Context, keys ...string) error
+	// DeletePrefix drops the values of every key starting with prefix.
+	DeletePrefix(ctx context.Context, prefix string) error
+}
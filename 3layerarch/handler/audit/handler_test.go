@@ -0,0 +1,68 @@
+package audithandler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"3layerarch/handler/audit"
+	"3layerarch/models"
+)
+
+// MockAuditService implements AuditService interface with function fields
+type MockAuditService struct {
+	ViewAuditFn func(ctx context.Context, f models.AuditFilter) (models.AuditPage, error)
+}
+
+func (m *MockAuditService) ViewAudit(ctx context.Context, f models.AuditFilter) (models.AuditPage, error) {
+	return m.ViewAuditFn(ctx, f)
+}
+
+func TestViewAuditHandler(t *testing.T) {
+	var got models.AuditFilter
+	mockSvc := &MockAuditService{
+		ViewAuditFn: func(ctx context.Context, f models.AuditFilter) (models.AuditPage, error) {
+			got = f
+			if f.Entity == "project" {
+				return models.AuditPage{}, models.Validation(`unknown entity "project"`)
+			}
+			entries := []models.AuditEntry{{ID: 4, Actor: "alice", Action: models.AuditComplete, Entity: f.Entity, EntityID: f.EntityID}}
+			return models.AuditPage{Entries: entries, Total: 3, Limit: 1, Offset: f.Offset}, nil
+		},
+	}
+	handler := audithandler.New(mockSvc)
+
+	req := httptest.NewRequest(http.MethodGet, "/audit?entity=task&id=7&limit=1&offset=1", nil)
+	w := httptest.NewRecorder()
+	handler.ViewAudit(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 OK, got %d", w.Code)
+	}
+	if got != (models.AuditFilter{Entity: "task", EntityID: 7, Limit: 1, Offset: 1}) {
+		t.Errorf("unexpected filter: %+v", got)
+	}
+	var page models.AuditPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(page.Entries) != 1 || page.Next != "/audit?entity=task&id=7&limit=1&offset=2" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/audit?entity=task&id=seven", nil)
+	w = httptest.NewRecorder()
+	handler.ViewAudit(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for id=seven, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/audit?entity=project", nil)
+	w = httptest.NewRecorder()
+	handler.ViewAudit(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for entity=project, got %d", w.Code)
+	}
+}
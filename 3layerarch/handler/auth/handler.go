@@ -0,0 +1,63 @@
+package authhandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"3layerarch/handler"
+	"3layerarch/models"
+)
+
+type UserService interface {
+	Authenticate(ctx context.Context, name, password string) (models.Principal, error)
+}
+
+type TokenIssuer interface {
+	Issue(p models.Principal) (models.Token, error)
+}
+
+type Handler struct {
+	Users  UserService
+	Tokens TokenIssuer
+}
+
+func New(users UserService, tokens TokenIssuer) *Handler {
+	return &Handler{Users: users, Tokens: tokens}
+}
+
+// Token exchanges a user's name and password for a bearer token.
+func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
+		return
+	}
+	var c models.Credentials
+	if err := json.Unmarshal(body, &c); err != nil {
+		handler.WriteBadRequest(w, "Invalid JSON input")
+		return
+	}
+	if c.Name == "" || c.Password == "" {
+		handler.WriteBadRequest(w, "name and password are required")
+		return
+	}
+	p, err := h.Users.Authenticate(r.Context(), c.Name, c.Password)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	t, err := h.Tokens.Issue(p)
+	if err != nil {
+		handler.WriteError(w, models.Internal(err))
+		return
+	}
+	// Tokens must not be kept by caches along the way
+	w.Header().Set("Cache-Control", "no-store")
+	b, _ := json.Marshal(t)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
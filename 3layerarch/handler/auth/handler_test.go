@@ -0,0 +1,100 @@
+package authhandler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"3layerarch/handler/auth"
+	"3layerarch/models"
+)
+
+type MockUserService struct {
+	AuthenticateFn func(ctx context.Context, name, password string) (models.Principal, error)
+}
+
+func (m *MockUserService) Authenticate(ctx context.Context, name, password string) (models.Principal, error) {
+	return m.AuthenticateFn(ctx, name, password)
+}
+
+type MockIssuer struct {
+	IssueFn func(p models.Principal) (models.Token, error)
+}
+
+func (m *MockIssuer) Issue(p models.Principal) (models.Token, error) {
+	return m.IssueFn(p)
+}
+
+func TestTokenHandler_Success(t *testing.T) {
+	h := authhandler.New(
+		&MockUserService{AuthenticateFn: func(ctx context.Context, name, password string) (models.Principal, error) {
+			if name != "alice" || password != "correct horse" {
+				t.Errorf("Authenticate(%q, %q)", name, password)
+			}
+			return models.Principal{UserID: 7, Name: name, Role: models.RoleUser}, nil
+		}},
+		&MockIssuer{IssueFn: func(p models.Principal) (models.Token, error) {
+			if p.UserID != 7 || p.Role != models.RoleUser {
+				t.Errorf("Issue got %+v, want user 7", p)
+			}
+			return models.Token{AccessToken: "tok", TokenType: "Bearer", ExpiresIn: 3600}, nil
+		}},
+	)
+
+	body, _ := json.Marshal(models.Credentials{Name: "alice", Password: "correct horse"})
+	req := httptest.NewRequest(http.MethodPost, "/auth/token", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	h.Token(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("expected Cache-Control no-store, got %q", got)
+	}
+	var tok models.Token
+	if err := json.Unmarshal(w.Body.Bytes(), &tok); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if tok.AccessToken != "tok" || tok.TokenType != "Bearer" || tok.ExpiresIn != 3600 {
+		t.Errorf("unexpected token: %+v", tok)
+	}
+}
+
+func TestTokenHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		authErr  error
+		issueErr error
+		want     int
+	}{
+		{name: "empty body", body: "", want: http.StatusBadRequest},
+		{name: "invalid JSON", body: "{", want: http.StatusBadRequest},
+		{name: "missing password", body: `{"name":"alice"}`, want: http.StatusBadRequest},
+		{name: "wrong password", body: `{"name":"alice","password":"nope"}`, authErr: models.Unauthorized("invalid name or password"), want: http.StatusUnauthorized},
+		{name: "issue fails", body: `{"name":"alice","password":"pw"}`, issueErr: errors.New("sign failed"), want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := authhandler.New(
+				&MockUserService{AuthenticateFn: func(ctx context.Context, name, password string) (models.Principal, error) {
+					return models.Principal{UserID: 1, Name: name}, tt.authErr
+				}},
+				&MockIssuer{IssueFn: func(p models.Principal) (models.Token, error) {
+					return models.Token{}, tt.issueErr
+				}},
+			)
+			req := httptest.NewRequest(http.MethodPost, "/auth/token", bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+			h.Token(w, req)
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
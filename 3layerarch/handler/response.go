@@ -0,0 +1,81 @@
+// Package handler holds the HTTP helpers shared by the task and user handlers.
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"3layerarch/models"
+)
+
+// ErrorResponse is the JSON body of every error response.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail carries a machine-readable code and a message for people.
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// WriteError maps a service error to a status code and writes it as an
+// ErrorResponse. Errors that are not a known domain kind are logged and
+// reported as internal errors so that database details never reach clients.
+// A request that ran out of time or was abandoned by the client is reported
+// as such rather than as an internal error.
+func WriteError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusGatewayTimeout, "timeout", "request timed out")
+	case errors.Is(err, context.Canceled):
+		writeError(w, http.StatusServiceUnavailable, "cancelled", "request cancelled")
+	case errors.Is(err, models.ErrNotFound):
+		writeError(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, models.ErrValidation):
+		writeError(w, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	case errors.Is(err, models.ErrConflict):
+		writeError(w, http.StatusConflict, "conflict", err.Error())
+	case errors.Is(err, models.ErrPreconditionFailed):
+		writeError(w, http.StatusPreconditionFailed, "precondition_failed", err.Error())
+	case errors.Is(err, models.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "unauthorized", err.Error())
+	case errors.Is(err, models.ErrForbidden):
+		writeError(w, http.StatusForbidden, "forbidden", err.Error())
+	case errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, "too_large", fmt.Sprintf("request body cannot be more than %d bytes", tooLarge.Limit))
+	default:
+		log.Printf("internal error: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal server error")
+	}
+}
+
+// WriteBadRequest reports a request that could not be parsed.
+func WriteBadRequest(w http.ResponseWriter, msg string) {
+	writeError(w, http.StatusBadRequest, "bad_request", msg)
+}
+
+// WriteBodyError reports a request body that could not be read, or was empty
+// if err is nil. A body over the size limit is reported as too large.
+func WriteBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		WriteError(w, err)
+		return
+	}
+	WriteBadRequest(w, "Empty or unreadable body")
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	b, _ := json.Marshal(ErrorResponse{Error: ErrorDetail{Code: code, Message: msg}})
+	if _, err := w.Write(b); err != nil {
+		log.Printf("failed to write error response: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package taskhandler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"3layerarch/handler"
+	"3layerarch/models"
+)
+
+// CompleteTask completes the task in the path. A task with open blockers
+// is only completed with force=true.
+func (h *Handler) CompleteTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	force := false
+	if v := r.URL.Query().Get("force"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			handler.WriteBadRequest(w, "invalid force value")
+			return
+		}
+		force = b
+	}
+	version, err := ifMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	t, err := h.Service.CompleteTask(r.Context(), id, force, version)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(t))
+	writeJSON(w, t)
+}
+
+// TaskTree returns the task in the path with its blockers and, nested, its
+// subtasks.
+func (h *Handler) TaskTree(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	tree, err := h.Service.TaskTree(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, tree)
+}
+
+// ViewBlockers lists the tasks blocking the task in the path.
+func (h *Handler) ViewBlockers(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	blockers, err := h.Service.ViewBlockers(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, blockers)
+}
+
+// AddDependency makes the blocker in the path block the task in the path
+// and lists the task's blockers.
+func (h *Handler) AddDependency(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	blockerID, ok := pathID(w, r, "blocker_id")
+	if !ok {
+		return
+	}
+	blockers, err := h.Service.AddDependency(r.Context(), id, blockerID)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, blockers)
+}
+
+// RemoveDependency stops the blocker in the path blocking the task in the
+// path and lists the task's blockers.
+func (h *Handler) RemoveDependency(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	blockerID, ok := pathID(w, r, "blocker_id")
+	if !ok {
+		return
+	}
+	blockers, err := h.Service.RemoveDependency(r.Context(), id, blockerID)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, blockers)
+}
+
+// TaskGraph exports the dependency graph, of one user's tasks with
+// user_id, as JSON or, with format=dot, as a Graphviz digraph.
+func (h *Handler) TaskGraph(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "dot" {
+		handler.WriteBadRequest(w, "format must be json or dot")
+		return
+	}
+	var userID *int
+	if v := q.Get("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			handler.WriteBadRequest(w, "invalid user_id value")
+			return
+		}
+		userID = &id
+	}
+	g, err := h.Service.TaskGraph(r.Context(), userID)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	if format != "dot" {
+		writeJSON(w, g)
+		return
+	}
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+	if _, err := w.Write([]byte(dot(g))); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// dot renders g in the Graphviz DOT language, with an edge from each
+// blocker to the task it blocks and completed tasks greyed out.
+func dot(g models.TaskGraph) string {
+	var b strings.Builder
+	b.WriteString("digraph tasks {\n")
+	for _, t := range g.Tasks {
+		fmt.Fprintf(&b, "\ttask%d [label=%s", t.ID, strconv.Quote(fmt.Sprintf("#%d %s", t.ID, t.Task)))
+		if t.Completed {
+			b.WriteString(", color=gray, fontcolor=gray")
+		}
+		b.WriteString("];\n")
+	}
+	for _, d := range g.Dependencies {
+		fmt.Fprintf(&b, "\ttask%d -> task%d;\n", d.BlockerID, d.TaskID)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
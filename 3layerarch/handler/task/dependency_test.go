@@ -0,0 +1,160 @@
+package taskhandler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"3layerarch/handler/task"
+	"3layerarch/models"
+)
+
+func (m *MockService) CompleteTask(ctx context.Context, id int, force bool, version int) (models.Task, error) {
+	if err := checkVersion(version); err != nil {
+		return models.Task{}, err
+	}
+	if id == 2 && !force {
+		return models.Task{}, models.Conflict("task is blocked by open tasks; complete them first or force it")
+	}
+	return models.Task{ID: id, Task: "Ship", Completed: true, UserID: 1, Version: 5}, nil
+}
+
+func (m *MockService) TaskTree(ctx context.Context, id int) (models.TaskTree, error) {
+	if id != 1 {
+		return models.TaskTree{}, models.NotFound("task not found")
+	}
+	return models.TaskTree{
+		Task:      models.Task{ID: 1, Task: "Release", UserID: 1, Version: 1},
+		BlockedBy: []int{},
+		Subtasks: []models.TaskTree{
+			{Task: models.Task{ID: 2, Task: "Ship", UserID: 1, ParentID: 1, Version: 1}, BlockedBy: []int{3}, Subtasks: []models.TaskTree{}},
+		},
+	}, nil
+}
+
+func (m *MockService) ViewBlockers(ctx context.Context, taskID int) ([]models.Task, error) {
+	if taskID != 2 {
+		return nil, models.NotFound("task not found")
+	}
+	return []models.Task{{ID: 3, Task: "Test", UserID: 1, Version: 1}}, nil
+}
+
+func (m *MockService) AddDependency(ctx context.Context, taskID, blockerID int) ([]models.Task, error) {
+	if taskID == blockerID {
+		return nil, models.Conflict("a task cannot block itself")
+	}
+	return m.ViewBlockers(ctx, taskID)
+}
+
+func (m *MockService) RemoveDependency(ctx context.Context, taskID, blockerID int) ([]models.Task, error) {
+	if _, err := m.ViewBlockers(ctx, taskID); err != nil {
+		return nil, err
+	}
+	return []models.Task{}, nil
+}
+
+func (m *MockService) TaskGraph(ctx context.Context, userID *int) (models.TaskGraph, error) {
+	if userID != nil && *userID <= 0 {
+		return models.TaskGraph{}, models.Validation("invalid user ID")
+	}
+	return models.TaskGraph{
+		Tasks: []models.Task{
+			{ID: 2, Task: "Ship", UserID: 1, Version: 1},
+			{ID: 3, Task: `Say "done"`, Completed: true, UserID: 1, Version: 2},
+		},
+		Dependencies: []models.Dependency{{TaskID: 2, BlockerID: 3}},
+	}, nil
+}
+
+func TestDependencyHandlers(t *testing.T) {
+	handler := taskhandler.New(&MockService{})
+
+	tests := []struct {
+		desc     string
+		serve    http.HandlerFunc
+		method   string
+		target   string
+		path     map[string]string
+		wantCode int
+		wantBody string
+	}{
+		{"Complete", handler.CompleteTask, http.MethodPost, "/task/1/complete", map[string]string{"id": "1"}, http.StatusOK,
+			`{"id":1,"task":"Ship","completed":true,"user_id":1,"version":5,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`},
+		{"Complete blocked", handler.CompleteTask, http.MethodPost, "/task/2/complete", map[string]string{"id": "2"}, http.StatusConflict, ""},
+		{"Complete blocked by force", handler.CompleteTask, http.MethodPost, "/task/2/complete?force=true", map[string]string{"id": "2"}, http.StatusOK, ""},
+		{"Complete invalid force", handler.CompleteTask, http.MethodPost, "/task/2/complete?force=maybe", map[string]string{"id": "2"}, http.StatusBadRequest, ""},
+		{"Tree", handler.TaskTree, http.MethodGet, "/task/1/tree", map[string]string{"id": "1"}, http.StatusOK, ""},
+		{"Unknown tree", handler.TaskTree, http.MethodGet, "/task/9/tree", map[string]string{"id": "9"}, http.StatusNotFound, ""},
+		{"Blockers", handler.ViewBlockers, http.MethodGet, "/task/2/dependencies", map[string]string{"id": "2"}, http.StatusOK, ""},
+		{"Add", handler.AddDependency, http.MethodPut, "/task/2/dependencies/3", map[string]string{"id": "2", "blocker_id": "3"}, http.StatusOK, ""},
+		{"Add itself", handler.AddDependency, http.MethodPut, "/task/2/dependencies/2", map[string]string{"id": "2", "blocker_id": "2"}, http.StatusConflict, ""},
+		{"Add invalid blocker ID", handler.AddDependency, http.MethodPut, "/task/2/dependencies/x", map[string]string{"id": "2", "blocker_id": "x"}, http.StatusBadRequest, ""},
+		{"Remove", handler.RemoveDependency, http.MethodDelete, "/task/2/dependencies/3", map[string]string{"id": "2", "blocker_id": "3"}, http.StatusOK, `[]`},
+		{"Remove from unknown task", handler.RemoveDependency, http.MethodDelete, "/task/9/dependencies/3", map[string]string{"id": "9", "blocker_id": "3"}, http.StatusNotFound, ""},
+		{"Graph", handler.TaskGraph, http.MethodGet, "/task/graph?user_id=1", nil, http.StatusOK, ""},
+		{"Graph invalid user ID", handler.TaskGraph, http.MethodGet, "/task/graph?user_id=x", nil, http.StatusBadRequest, ""},
+		{"Graph unknown format", handler.TaskGraph, http.MethodGet, "/task/graph?format=svg", nil, http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.target, nil)
+		for name, v := range tc.path {
+			req.SetPathValue(name, v)
+		}
+		w := httptest.NewRecorder()
+		tc.serve(w, req)
+		if w.Code != tc.wantCode {
+			t.Errorf("%s: expected %d, got %d", tc.desc, tc.wantCode, w.Code)
+		}
+		if tc.wantBody != "" && w.Body.String() != tc.wantBody {
+			t.Errorf("%s: expected %s, got %s", tc.desc, tc.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestCompleteTaskHandler_ETag(t *testing.T) {
+	handler := taskhandler.New(&MockService{})
+
+	req := httptest.NewRequest(http.MethodPost, "/task/1/complete", nil)
+	req.SetPathValue("id", "1")
+	req.Header.Set("If-Match", `"3"`)
+	w := httptest.NewRecorder()
+	handler.CompleteTask(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"5"` {
+		t.Errorf("expected 200 with ETag \"5\", got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestTaskTreeHandler(t *testing.T) {
+	handler := taskhandler.New(&MockService{})
+
+	req := httptest.NewRequest(http.MethodGet, "/task/1/tree", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.TaskTree(w, req)
+	want := `"blocked_by":[],"subtasks":[{"id":2,"task":"Ship","completed":false,"user_id":1,"parent_id":1`
+	if body := w.Body.String(); !strings.Contains(body, want) || !strings.Contains(body, `"blocked_by":[3],"subtasks":[]`) {
+		t.Errorf("expected the subtask nested under its parent, got %s", body)
+	}
+}
+
+func TestTaskGraphHandler_Dot(t *testing.T) {
+	handler := taskhandler.New(&MockService{})
+
+	req := httptest.NewRequest(http.MethodGet, "/task/graph?format=dot", nil)
+	w := httptest.NewRecorder()
+	handler.TaskGraph(w, req)
+	if ct := w.Header().Get("Content-Type"); ct != "text/vnd.graphviz" {
+		t.Errorf("expected a Graphviz content type, got %q", ct)
+	}
+	want := "digraph tasks {\n" +
+		"\ttask2 [label=\"#2 Ship\"];\n" +
+		"\ttask3 [label=\"#3 Say \\\"done\\\"\", color=gray, fontcolor=gray];\n" +
+		"\ttask3 -> task2;\n" +
+		"}\n"
+	if w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+}
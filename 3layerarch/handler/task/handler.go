@@ -1,21 +1,46 @@
 package taskhandler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"3layerarch/handler"
 	"3layerarch/models"
 )
 
 type TaskService interface {
-	CreateTask(t models.Task) error
-	GetTask(id int) (models.Task, error)
-	ViewTasks() ([]models.Task, error)
-	UpdateTask(id int) error
-	DeleteTask(id int) error
+	CreateTask(ctx context.Context, t models.Task) (models.Task, error)
+	GetTask(ctx context.Context, id int) (models.Task, error)
+	ViewTasks(ctx context.Context, f models.TaskFilter) (models.TaskPage, error)
+	ViewUserTasks(ctx context.Context, userID int, f models.TaskFilter) (models.TaskPage, error)
+	UpdateTask(ctx context.Context, id int, t models.Task, version int) (models.Task, error)
+	PatchTask(ctx context.Context, id int, p models.TaskPatch, version int) (models.Task, error)
+	DeleteTask(ctx context.Context, id int, version int) error
+	RestoreTask(ctx context.Context, id int) (models.Task, error)
+	CompleteTask(ctx context.Context, id int, force bool, version int) (models.Task, error)
+	TaskTree(ctx context.Context, id int) (models.TaskTree, error)
+	ViewBlockers(ctx context.Context, taskID int) ([]models.Task, error)
+	AddDependency(ctx context.Context, taskID, blockerID int) ([]models.Task, error)
+	RemoveDependency(ctx context.Context, taskID, blockerID int) ([]models.Task, error)
+	TaskGraph(ctx context.Context, userID *int) (models.TaskGraph, error)
+
+	CreateLabel(ctx context.Context, l models.Label) (models.Label, error)
+	GetLabel(ctx context.Context, id int) (models.Label, error)
+	ViewLabels(ctx context.Context) ([]models.Label, error)
+	UpdateLabel(ctx context.Context, id int, l models.Label) (models.Label, error)
+	DeleteLabel(ctx context.Context, id int) error
+	ViewTaskLabels(ctx context.Context, taskID int) ([]models.Label, error)
+	AddTaskLabel(ctx context.Context, taskID, labelID int) ([]models.Label, error)
+	RemoveTaskLabel(ctx context.Context, taskID, labelID int) ([]models.Label, error)
 }
 
 type Handler struct {
@@ -29,35 +54,47 @@ func New(service TaskService) *Handler {
 func (h *Handler) CreateTask(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil || len(body) == 0 {
-		http.Error(w, "Empty or unreadable body", http.StatusBadRequest)
+		handler.WriteBodyError(w, err)
 		return
 	}
 	var t models.Task
 	if err := json.Unmarshal(body, &t); err != nil {
-		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Invalid JSON input")
 		return
 	}
-	if err := h.Service.CreateTask(t); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	created, err := h.Service.CreateTask(r.Context(), t)
+	if err != nil {
+		handler.WriteError(w, err)
 		return
 	}
+	w.Header().Set("Location", "/task/"+strconv.Itoa(created.ID))
+	w.Header().Set("ETag", etag(created))
 	w.WriteHeader(http.StatusCreated)
+	b, _ := json.Marshal(created)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
 }
 
 func (h *Handler) GetTask(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	if idStr == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Missing ID")
 		return
 	}
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Invalid ID format")
 		return
 	}
-	t, err := h.Service.GetTask(id)
+	t, err := h.Service.GetTask(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		handler.WriteError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(t))
+	if noneMatch(r.Header.Get("If-None-Match"), etag(t)) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 	b, _ := json.Marshal(t)
@@ -66,50 +103,369 @@ func (h *Handler) GetTask(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *Handler) ViewTasks(w http.ResponseWriter, _ *http.Request) {
-	tasks, err := h.Service.ViewTasks()
+// ViewTasks lists tasks. It accepts completed, user_id, label_id, priority,
+// overdue, q (text search), sort, limit and offset query parameters, and
+// the created and completed time bounds.
+func (h *Handler) ViewTasks(w http.ResponseWriter, r *http.Request) {
+	f, err := parseTaskFilter(r.URL.Query())
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	page, err := h.Service.ViewTasks(r.Context(), f)
 	if err != nil {
-		http.Error(w, "Failed to fetch tasks", http.StatusInternalServerError)
+		handler.WriteError(w, err)
 		return
 	}
-	b, _ := json.Marshal(tasks)
+	if page.Offset+len(page.Tasks) < page.Total {
+		f.Limit, f.Offset = page.Limit, page.Offset+page.Limit
+		page.Next = r.URL.Path + "?" + taskFilterQuery(f).Encode()
+	}
+	b, _ := json.Marshal(page)
 	if _, err := w.Write(b); err != nil {
 		fmt.Printf("failed to write response: %v\n", err)
 	}
 }
 
+// ViewTrash lists the deleted tasks that can still be restored. It accepts
+// the same query parameters as ViewTasks.
+func (h *Handler) ViewTrash(w http.ResponseWriter, r *http.Request) {
+	f, err := parseTaskFilter(r.URL.Query())
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	f.Deleted = true
+	page, err := h.Service.ViewTasks(r.Context(), f)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	if page.Offset+len(page.Tasks) < page.Total {
+		f.Limit, f.Offset = page.Limit, page.Offset+page.Limit
+		page.Next = r.URL.Path + "?" + taskFilterQuery(f).Encode()
+	}
+	b, _ := json.Marshal(page)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// ViewUserTasks lists the tasks of the user in the path. It accepts the same
+// query parameters as ViewTasks, except user_id.
+func (h *Handler) ViewUserTasks(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		handler.WriteBadRequest(w, "Missing ID")
+		return
+	}
+	userID, err := strconv.Atoi(idStr)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	f, err := parseTaskFilter(r.URL.Query())
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	if f.UserID != nil {
+		handler.WriteBadRequest(w, "user_id cannot be used here")
+		return
+	}
+	page, err := h.Service.ViewUserTasks(r.Context(), userID, f)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	if page.Offset+len(page.Tasks) < page.Total {
+		f.Limit, f.Offset = page.Limit, page.Offset+page.Limit
+		page.Next = r.URL.Path + "?" + taskFilterQuery(f).Encode()
+	}
+	b, _ := json.Marshal(page)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// parseTaskFilter reads a task filter from the query string of a listing.
+func parseTaskFilter(q url.Values) (models.TaskFilter, error) {
+	f := models.TaskFilter{Search: q.Get("q"), Sort: q.Get("sort"), Priority: models.Priority(q.Get("priority"))}
+	for name, dst := range map[string]**bool{"completed": &f.Completed, "overdue": &f.Overdue} {
+		if v := q.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return models.TaskFilter{}, fmt.Errorf("invalid %s value", name)
+			}
+			*dst = &b
+		}
+	}
+	for name, dst := range map[string]**int{"user_id": &f.UserID, "label_id": &f.LabelID} {
+		if v := q.Get(name); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return models.TaskFilter{}, fmt.Errorf("invalid %s value", name)
+			}
+			*dst = &id
+		}
+	}
+	for name, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return models.TaskFilter{}, fmt.Errorf("invalid %s value", name)
+			}
+			*dst = n
+		}
+	}
+	for name, dst := range timeParams(&f) {
+		if v := q.Get(name); v != "" {
+			at, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return models.TaskFilter{}, fmt.Errorf("invalid %s value", name)
+			}
+			*dst = at
+		}
+	}
+	return f, nil
+}
+
+// timeParams maps the query parameters of f's time bounds to the fields.
+func timeParams(f *models.TaskFilter) map[string]*time.Time {
+	return map[string]*time.Time{
+		"created_after":    &f.CreatedAfter,
+		"created_before":   &f.CreatedBefore,
+		"completed_after":  &f.CompletedAfter,
+		"completed_before": &f.CompletedBefore,
+	}
+}
+
+// taskFilterQuery is the inverse of parseTaskFilter.
+func taskFilterQuery(f models.TaskFilter) url.Values {
+	q := url.Values{}
+	if f.Completed != nil {
+		q.Set("completed", strconv.FormatBool(*f.Completed))
+	}
+	if f.UserID != nil {
+		q.Set("user_id", strconv.Itoa(*f.UserID))
+	}
+	if f.LabelID != nil {
+		q.Set("label_id", strconv.Itoa(*f.LabelID))
+	}
+	if f.Priority != "" {
+		q.Set("priority", string(f.Priority))
+	}
+	if f.Overdue != nil {
+		q.Set("overdue", strconv.FormatBool(*f.Overdue))
+	}
+	if f.Search != "" {
+		q.Set("q", f.Search)
+	}
+	if f.Sort != "" {
+		q.Set("sort", f.Sort)
+	}
+	for name, at := range timeParams(&f) {
+		if !at.IsZero() {
+			q.Set(name, at.Format(time.RFC3339Nano))
+		}
+	}
+	q.Set("limit", strconv.Itoa(f.Limit))
+	q.Set("offset", strconv.Itoa(f.Offset))
+	return q
+}
+
 func (h *Handler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	if idStr == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Missing ID")
 		return
 	}
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
 		return
 	}
-	if err := h.Service.UpdateTask(id); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var t models.Task
+	if err := json.Unmarshal(body, &t); err != nil {
+		handler.WriteBadRequest(w, "Invalid JSON input")
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	version, err := ifMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	updated, err := h.Service.UpdateTask(r.Context(), id, t, version)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(updated))
+	b, _ := json.Marshal(updated)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+func (h *Handler) PatchTask(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		handler.WriteBadRequest(w, "Missing ID")
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
+		return
+	}
+	p, err := decodePatch(body)
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	version, err := ifMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	updated, err := h.Service.PatchTask(r.Context(), id, p, version)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(updated))
+	b, _ := json.Marshal(updated)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// decodePatch parses a JSON merge-patch (RFC 7396) body. A null removes
+// the parent, priority or due date; the other task fields are required, so a null
+// for one of them is rejected, as are fields the task does not have.
+func decodePatch(body []byte) (models.TaskPatch, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return models.TaskPatch{}, errors.New("invalid JSON input")
+	}
+	for field, v := range raw {
+		if string(v) == "null" && field != "parent_id" && field != "priority" && field != "due_at" {
+			return models.TaskPatch{}, fmt.Errorf("%s cannot be null", field)
+		}
+	}
+	var p models.TaskPatch
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return models.TaskPatch{}, fmt.Errorf("invalid patch: %v", err)
+	}
+	if string(raw["parent_id"]) == "null" {
+		p.ParentID = new(int)
+	}
+	if string(raw["priority"]) == "null" {
+		p.Priority = new(models.Priority)
+	}
+	if string(raw["due_at"]) == "null" {
+		p.DueAt = new(time.Time)
+	}
+	return p, nil
 }
 
 func (h *Handler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	if idStr == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Missing ID")
 		return
 	}
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Invalid ID format")
 		return
 	}
-	if err := h.Service.DeleteTask(id); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	version, err := ifMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	if err := h.Service.DeleteTask(r.Context(), id, version); err != nil {
+		handler.WriteError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
+
+// RestoreTask takes a deleted task out of the trash and returns it.
+func (h *Handler) RestoreTask(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		handler.WriteBadRequest(w, "Missing ID")
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	t, err := h.Service.RestoreTask(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(t))
+	b, _ := json.Marshal(t)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// etag is the entity tag of t: its version, which changes with every write.
+func etag(t models.Task) string {
+	return `"` + strconv.Itoa(t.Version) + `"`
+}
+
+// noVersion is what ifMatch returns for a tag that names no version, such
+// as a weak one, so that it matches none.
+const noVersion = -1
+
+// ifMatch returns the version an If-Match header requires, or 0 for any
+// version when the header is absent or *.
+func ifMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if strings.Contains(header, ",") {
+		return 0, errors.New("If-Match must be * or a single ETag")
+	}
+	// If-Match compares strongly, so a weak tag never matches
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return noVersion, nil
+	}
+	version, err := strconv.Atoi(header[1 : len(header)-1])
+	if err != nil || version <= 0 {
+		return noVersion, nil
+	}
+	return version, nil
+}
+
+// noneMatch reports whether an If-None-Match header matches tag, so that
+// the client's copy is current. It compares weakly.
+func noneMatch(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -2,52 +2,152 @@ package taskhandler_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"io"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"3layerarch/handler"
 	"3layerarch/handler/task"
 	"3layerarch/models"
 )
 
 type MockService struct{}
 
-func (m *MockService) CreateTask(t models.Task) error {
+func (m *MockService) CreateTask(ctx context.Context, t models.Task) (models.Task, error) {
 	if t.Task == "" {
-		return io.EOF
+		return models.Task{}, models.Validation("task cannot be empty")
 	}
-	return nil
+	t.ID, t.Version = 7, 1
+	return t, nil
 }
 
-func (m *MockService) GetTask(id int) (models.Task, error) {
+func (m *MockService) GetTask(ctx context.Context, id int) (models.Task, error) {
 	if id == 1 {
-		return models.Task{ID: 1, Task: "Hello", Completed: false, UserID: 1}, nil
+		return models.Task{ID: 1, Task: "Hello", Completed: false, UserID: 1, Version: 3}, nil
+	}
+	if id == 500 {
+		return models.Task{}, errors.New("dial tcp 10.0.0.5:3306: connection refused")
+	}
+	if id == 504 {
+		// A slow query that gives up when the request does
+		<-ctx.Done()
+		return models.Task{}, ctx.Err()
 	}
-	return models.Task{}, io.EOF
+	return models.Task{}, models.NotFound("task not found")
 }
 
-func (m *MockService) ViewTasks() ([]models.Task, error) {
-	return []models.Task{
-		{ID: 1, Task: "Test 1", Completed: false, UserID: 1},
-		{ID: 2, Task: "Test 2", Completed: true, UserID: 2},
+func (m *MockService) ViewTasks(ctx context.Context, f models.TaskFilter) (models.TaskPage, error) {
+	if f.Limit > 100 {
+		return models.TaskPage{}, models.Validation("limit must be between 1 and 100")
+	}
+	if f.Limit == 0 {
+		f.Limit = 2
+	}
+	if f.Deleted {
+		return models.TaskPage{
+			Tasks: []models.Task{{ID: 9, Task: "Binned", UserID: 1, Version: 2, DeletedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}},
+			Total: 1,
+			Limit: f.Limit,
+		}, nil
+	}
+	return models.TaskPage{
+		Tasks: []models.Task{
+			{ID: 1, Task: "Test 1", Completed: false, UserID: 1},
+			{ID: 2, Task: "Test 2", Completed: true, UserID: 2},
+		},
+		Total:  3,
+		Limit:  f.Limit,
+		Offset: f.Offset,
 	}, nil
 }
 
-func (m *MockService) UpdateTask(id int) error {
-	if id == 0 {
-		return io.EOF
+func (m *MockService) ViewUserTasks(ctx context.Context, userID int, f models.TaskFilter) (models.TaskPage, error) {
+	if userID != 1 {
+		return models.TaskPage{}, models.NotFound("user not found")
+	}
+	f.UserID = &userID
+	return m.ViewTasks(ctx, f)
+}
+
+// checkVersion fails changes made against any version of a task but 3.
+func checkVersion(version int) error {
+	if version != 0 && version != 3 {
+		return models.PreconditionFailed("task is at version 3")
 	}
 	return nil
 }
 
-func (m *MockService) DeleteTask(id int) error {
+func (m *MockService) UpdateTask(ctx context.Context, id int, t models.Task, version int) (models.Task, error) {
 	if id == 0 {
-		return io.EOF
+		return models.Task{}, models.Validation("invalid task ID")
 	}
-	return nil
+	if err := checkVersion(version); err != nil {
+		return models.Task{}, err
+	}
+	t.ID, t.Version = id, 4
+	return stamped(t), nil
+}
+
+// stamped is t with the times a store would have given it: created on 2
+// January 2026 and updated, or completed, a day later.
+func stamped(t models.Task) models.Task {
+	t.CreatedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	t.UpdatedAt = t.CreatedAt.AddDate(0, 0, 1)
+	if t.Completed {
+		t.CompletedAt = t.UpdatedAt
+	}
+	return t
+}
+
+func (m *MockService) PatchTask(ctx context.Context, id int, p models.TaskPatch, version int) (models.Task, error) {
+	if id == 0 {
+		return models.Task{}, models.Validation("invalid task ID")
+	}
+	if err := checkVersion(version); err != nil {
+		return models.Task{}, err
+	}
+	t := models.Task{ID: id, Task: "Hello", Completed: false, UserID: 1, Version: 4}
+	if id == 2 {
+		t.ParentID, t.Priority, t.DueAt = 5, models.PriorityHigh, time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+	}
+	if p.Task != nil {
+		t.Task = *p.Task
+	}
+	if p.Completed != nil {
+		t.Completed = *p.Completed
+	}
+	if p.UserID != nil {
+		t.UserID = *p.UserID
+	}
+	if p.ParentID != nil {
+		t.ParentID = *p.ParentID
+	}
+	if p.Priority != nil {
+		t.Priority = *p.Priority
+	}
+	if p.DueAt != nil {
+		t.DueAt = *p.DueAt
+	}
+	return stamped(t), nil
+}
+
+func (m *MockService) DeleteTask(ctx context.Context, id int, version int) error {
+	if id == 0 {
+		return models.Validation("invalid task ID")
+	}
+	return checkVersion(version)
+}
+
+func (m *MockService) RestoreTask(ctx context.Context, id int) (models.Task, error) {
+	if id != 9 {
+		return models.Task{}, models.NotFound("task not found in the trash")
+	}
+	return models.Task{ID: 9, Task: "Binned", UserID: 1, Version: 3}, nil
 }
 
 func TestCreateTaskHandler(t *testing.T) {
@@ -62,6 +162,16 @@ func TestCreateTaskHandler(t *testing.T) {
 	if w.Code != http.StatusCreated {
 		t.Errorf("expected status 201, got %d", w.Code)
 	}
+	if loc := w.Header().Get("Location"); loc != "/task/7" {
+		t.Errorf("expected Location /task/7, got %q", loc)
+	}
+	if tag := w.Header().Get("ETag"); tag != `"1"` {
+		t.Errorf("expected ETag \"1\", got %q", tag)
+	}
+	var created models.Task
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil || created.ID != 7 || created.Task != "Test task" {
+		t.Errorf("expected the created task, got %+v (err %v)", created, err)
+	}
 
 	// Invalid Task (empty body)
 	req = httptest.NewRequest(http.MethodPost, "/task", bytes.NewReader([]byte{}))
@@ -118,8 +228,46 @@ func TestGetTaskHandler(t *testing.T) {
 	req.SetPathValue("id", "999")
 	w = httptest.NewRecorder()
 	handler.GetTask(w, req)
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("expected 400 for not found, got %d", w.Code)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for not found, got %d", w.Code)
+	}
+	want := `{"error":{"code":"not_found","message":"task not found"}}`
+	if w.Body.String() != want {
+		t.Errorf("expected error body %s, got %s", want, w.Body.String())
+	}
+
+	// Database failure
+	req = httptest.NewRequest(http.MethodGet, "/task/500", nil)
+	req.SetPathValue("id", "500")
+	w = httptest.NewRecorder()
+	handler.GetTask(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 for database failure, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "3306") {
+		t.Errorf("database error leaked to client: %s", w.Body.String())
+	}
+}
+
+func TestGetTaskHandler_Timeout(t *testing.T) {
+	h := taskhandler.New(&MockService{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /task/{id}", h.GetTask)
+	srv := handler.Timeout(10*time.Millisecond, mux)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/task/504", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status %d, got %d: %s", http.StatusGatewayTimeout, w.Code, w.Body)
+	}
+
+	// A client that hangs up cancels the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/task/504", nil).WithContext(ctx))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d: %s", http.StatusServiceUnavailable, w.Code, w.Body)
 	}
 }
 
@@ -135,22 +283,134 @@ func TestViewTasksHandler(t *testing.T) {
 	if !strings.Contains(w.Body.String(), "Test 1") || !strings.Contains(w.Body.String(), "Test 2") {
 		t.Errorf("unexpected tasks list response: %s", w.Body.String())
 	}
+
+	var page models.TaskPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("invalid page JSON: %v", err)
+	}
+	if page.Total != 3 || page.Next != "/task?limit=2&offset=2" {
+		t.Errorf("expected total 3 and a next link, got %+v", page)
+	}
+}
+
+func TestViewTasksHandler_Filters(t *testing.T) {
+	handler := taskhandler.New(&MockService{})
+
+	// Filters are carried over to the next link
+	req := httptest.NewRequest(http.MethodGet, "/task?completed=false&user_id=1&label_id=4&priority=high&overdue=true&q=milk&sort=-task&limit=2", nil)
+	w := httptest.NewRecorder()
+	handler.ViewTasks(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 OK, got %d", w.Code)
+	}
+	var page models.TaskPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("invalid page JSON: %v", err)
+	}
+	want := "/task?completed=false&label_id=4&limit=2&offset=2&overdue=true&priority=high&q=milk&sort=-task&user_id=1"
+	if page.Next != want {
+		t.Errorf("expected next link %s, got %s", want, page.Next)
+	}
+
+	// So are time bounds, in the zone they were given in
+	req = httptest.NewRequest(http.MethodGet, "/task?created_after=2026-01-02T03:04:05%2B02:00&completed_before=2026-02-01T00:00:00.5Z", nil)
+	w = httptest.NewRecorder()
+	handler.ViewTasks(w, req)
+	page = models.TaskPage{}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("invalid page JSON: %v", err)
+	}
+	want = "/task?completed_before=2026-02-01T00%3A00%3A00.5Z&created_after=2026-01-02T03%3A04%3A05%2B02%3A00&limit=2&offset=2"
+	if page.Next != want {
+		t.Errorf("expected next link %s, got %s", want, page.Next)
+	}
+
+	// Last page has no next link
+	req = httptest.NewRequest(http.MethodGet, "/task?offset=1", nil)
+	w = httptest.NewRecorder()
+	handler.ViewTasks(w, req)
+	if strings.Contains(w.Body.String(), "next") {
+		t.Errorf("expected no next link on the last page, got %s", w.Body.String())
+	}
+
+	// Invalid query values
+	for _, query := range []string{"completed=maybe", "user_id=abc", "label_id=work", "overdue=soon", "limit=ten", "offset=x", "created_after=yesterday", "completed_before=2026-01-02"} {
+		req = httptest.NewRequest(http.MethodGet, "/task?"+query, nil)
+		w = httptest.NewRecorder()
+		handler.ViewTasks(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", query, w.Code)
+		}
+	}
+
+	// Out of range values are rejected by the service
+	req = httptest.NewRequest(http.MethodGet, "/task?limit=1000", nil)
+	w = httptest.NewRecorder()
+	handler.ViewTasks(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for limit=1000, got %d", w.Code)
+	}
+}
+
+func TestViewUserTasksHandler(t *testing.T) {
+	handler := taskhandler.New(&MockService{})
+
+	req := httptest.NewRequest(http.MethodGet, "/user/1/tasks?completed=true", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.ViewUserTasks(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 OK, got %d", w.Code)
+	}
+	var page models.TaskPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("invalid page JSON: %v", err)
+	}
+	// The user comes from the path, so it is not repeated in the next link
+	if want := "/user/1/tasks?completed=true&limit=2&offset=2"; page.Next != want {
+		t.Errorf("expected next link %s, got %s", want, page.Next)
+	}
+
+	tests := []struct {
+		id, query  string
+		wantStatus int
+	}{
+		{"9", "", http.StatusNotFound},
+		{"abc", "", http.StatusBadRequest},
+		{"1", "?user_id=2", http.StatusBadRequest},
+		{"1", "?limit=ten", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/user/"+tc.id+"/tasks"+tc.query, nil)
+		req.SetPathValue("id", tc.id)
+		w := httptest.NewRecorder()
+		handler.ViewUserTasks(w, req)
+		if w.Code != tc.wantStatus {
+			t.Errorf("%s%s: expected %d, got %d", tc.id, tc.query, tc.wantStatus, w.Code)
+		}
+	}
 }
 
 func TestUpdateTaskHandler(t *testing.T) {
 	handler := taskhandler.New(&MockService{})
+	body := `{"task":"Renamed","completed":true,"user_id":2}`
 
 	// Valid ID
-	req := httptest.NewRequest(http.MethodPut, "/task/1", nil)
+	req := httptest.NewRequest(http.MethodPut, "/task/1", strings.NewReader(body))
 	req.SetPathValue("id", "1")
 	w := httptest.NewRecorder()
 	handler.UpdateTask(w, req)
 	if w.Code != http.StatusOK {
 		t.Errorf("expected 200 OK, got %d", w.Code)
 	}
+	want := `{"id":1,"task":"Renamed","completed":true,"user_id":2,"version":4,` +
+		`"created_at":"2026-01-02T03:04:05Z","updated_at":"2026-01-03T03:04:05Z","completed_at":"2026-01-03T03:04:05Z"}`
+	if w.Body.String() != want {
+		t.Errorf("expected updated task %s, got %s", want, w.Body.String())
+	}
 
 	// Missing ID
-	req = httptest.NewRequest(http.MethodPut, "/task/", nil)
+	req = httptest.NewRequest(http.MethodPut, "/task/", strings.NewReader(body))
 	req.SetPathValue("id", "")
 	w = httptest.NewRecorder()
 	handler.UpdateTask(w, req)
@@ -159,7 +419,7 @@ func TestUpdateTaskHandler(t *testing.T) {
 	}
 
 	// Invalid ID
-	req = httptest.NewRequest(http.MethodPut, "/task/abc", nil)
+	req = httptest.NewRequest(http.MethodPut, "/task/abc", strings.NewReader(body))
 	req.SetPathValue("id", "abc")
 	w = httptest.NewRecorder()
 	handler.UpdateTask(w, req)
@@ -167,13 +427,94 @@ func TestUpdateTaskHandler(t *testing.T) {
 		t.Errorf("expected 400 for invalid ID, got %d", w.Code)
 	}
 
+	// Empty body
+	req = httptest.NewRequest(http.MethodPut, "/task/1", nil)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handler.UpdateTask(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for empty body, got %d", w.Code)
+	}
+
 	// Error in service for update
-	req = httptest.NewRequest(http.MethodPut, "/task/0", nil)
+	req = httptest.NewRequest(http.MethodPut, "/task/0", strings.NewReader(body))
 	req.SetPathValue("id", "0")
 	w = httptest.NewRecorder()
 	handler.UpdateTask(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for update error, got %d", w.Code)
+	}
+}
+
+func TestPatchTaskHandler(t *testing.T) {
+	handler := taskhandler.New(&MockService{})
+
+	// Only the fields in the patch change
+	req := httptest.NewRequest(http.MethodPatch, "/task/1", strings.NewReader(`{"completed":true}`))
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.PatchTask(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 OK, got %d", w.Code)
+	}
+	want := `{"id":1,"task":"Hello","completed":true,"user_id":1,"version":4,` +
+		`"created_at":"2026-01-02T03:04:05Z","updated_at":"2026-01-03T03:04:05Z","completed_at":"2026-01-03T03:04:05Z"}`
+	if w.Body.String() != want {
+		t.Errorf("expected patched task %s, got %s", want, w.Body.String())
+	}
+
+	// A priority and due date can be set, and removed with null
+	req = httptest.NewRequest(http.MethodPatch, "/task/1", strings.NewReader(`{"priority":"urgent","due_at":"2026-02-01T09:00:00Z"}`))
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handler.PatchTask(w, req)
+	if body := w.Body.String(); !strings.Contains(body, `"priority":"urgent","due_at":"2026-02-01T09:00:00Z"`) {
+		t.Errorf("expected the priority and due date set, got %s", body)
+	}
+	req = httptest.NewRequest(http.MethodPatch, "/task/2", strings.NewReader(`{"priority":null,"due_at":null}`))
+	req.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	handler.PatchTask(w, req)
+	if body := w.Body.String(); w.Code != http.StatusOK || strings.Contains(body, "priority") || strings.Contains(body, "due_at") {
+		t.Errorf("expected the priority and due date removed, got %d %s", w.Code, body)
+	}
+
+	// A null parent makes the task a top-level one
+	req = httptest.NewRequest(http.MethodPatch, "/task/2", strings.NewReader(`{"parent_id":null}`))
+	req.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	handler.PatchTask(w, req)
+	if body := w.Body.String(); w.Code != http.StatusOK || strings.Contains(body, "parent_id") {
+		t.Errorf("expected the parent removed, got %d %s", w.Code, body)
+	}
+
+	// Invalid patches
+	for _, body := range []string{"", "{invalid json", `{"task":null}`, `{"title":"x"}`, `["task"]`, `{"due_at":"tomorrow"}`} {
+		req = httptest.NewRequest(http.MethodPatch, "/task/1", strings.NewReader(body))
+		req.SetPathValue("id", "1")
+		w = httptest.NewRecorder()
+		handler.PatchTask(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for patch %q, got %d", body, w.Code)
+		}
+	}
+
+	// Invalid ID
+	req = httptest.NewRequest(http.MethodPatch, "/task/abc", strings.NewReader(`{"completed":true}`))
+	req.SetPathValue("id", "abc")
+	w = httptest.NewRecorder()
+	handler.PatchTask(w, req)
 	if w.Code != http.StatusBadRequest {
-		t.Errorf("expected 400 for update error, got %d", w.Code)
+		t.Errorf("expected 400 for invalid ID, got %d", w.Code)
+	}
+
+	// Error in service for patch
+	req = httptest.NewRequest(http.MethodPatch, "/task/0", strings.NewReader(`{"completed":true}`))
+	req.SetPathValue("id", "0")
+	w = httptest.NewRecorder()
+	handler.PatchTask(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for patch error, got %d", w.Code)
 	}
 }
 
@@ -212,7 +553,145 @@ func TestDeleteTaskHandler(t *testing.T) {
 	req.SetPathValue("id", "0")
 	w = httptest.NewRecorder()
 	handler.DeleteTask(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for delete error, got %d", w.Code)
+	}
+}
+
+func TestViewTrashHandler(t *testing.T) {
+	handler := taskhandler.New(&MockService{})
+
+	req := httptest.NewRequest(http.MethodGet, "/trash", nil)
+	w := httptest.NewRecorder()
+	handler.ViewTrash(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 OK, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"deleted_at":"2026-01-02T03:04:05Z"`) {
+		t.Errorf("expected the deleted task with its deletion time, got %s", w.Body.String())
+	}
+
+	// Tasks out of the trash leave deleted_at out
+	w = httptest.NewRecorder()
+	handler.ViewTasks(w, httptest.NewRequest(http.MethodGet, "/task", nil))
+	if strings.Contains(w.Body.String(), "deleted_at") {
+		t.Errorf("expected no deleted_at, got %s", w.Body.String())
+	}
+}
+
+func TestRestoreTaskHandler(t *testing.T) {
+	handler := taskhandler.New(&MockService{})
+
+	req := httptest.NewRequest(http.MethodPost, "/task/9/restore", nil)
+	req.SetPathValue("id", "9")
+	w := httptest.NewRecorder()
+	handler.RestoreTask(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"3"` {
+		t.Errorf("expected 200 OK with ETag \"3\", got %d and %q", w.Code, w.Header().Get("ETag"))
+	}
+
+	// Not in the trash
+	req = httptest.NewRequest(http.MethodPost, "/task/1/restore", nil)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handler.RestoreTask(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+
+	// Invalid ID
+	req = httptest.NewRequest(http.MethodPost, "/task/abc/restore", nil)
+	req.SetPathValue("id", "abc")
+	w = httptest.NewRecorder()
+	handler.RestoreTask(w, req)
 	if w.Code != http.StatusBadRequest {
-		t.Errorf("expected 400 for delete error, got %d", w.Code)
+		t.Errorf("expected 400 for invalid ID, got %d", w.Code)
+	}
+}
+
+func TestGetTaskHandler_ETag(t *testing.T) {
+	h := taskhandler.New(&MockService{})
+
+	tests := []struct {
+		ifNoneMatch string
+		wantCode    int
+	}{
+		{"", http.StatusOK},
+		{`"2"`, http.StatusOK},
+		{`"3"`, http.StatusNotModified},
+		{`W/"3"`, http.StatusNotModified},
+		{`"1", "3"`, http.StatusNotModified},
+		{"*", http.StatusNotModified},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/task/1", nil)
+		req.SetPathValue("id", "1")
+		if tc.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", tc.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		h.GetTask(w, req)
+		if w.Code != tc.wantCode {
+			t.Errorf("If-None-Match %s: expected %d, got %d", tc.ifNoneMatch, tc.wantCode, w.Code)
+		}
+		if tag := w.Header().Get("ETag"); tag != `"3"` {
+			t.Errorf("If-None-Match %s: expected ETag \"3\", got %q", tc.ifNoneMatch, tag)
+		}
+		if tc.wantCode == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: expected no body, got %s", tc.ifNoneMatch, w.Body.String())
+		}
 	}
 }
+
+func TestIfMatch(t *testing.T) {
+	h := taskhandler.New(&MockService{})
+
+	tests := []struct {
+		ifMatch  string
+		wantCode int
+	}{
+		{"", http.StatusOK},
+		{"*", http.StatusOK},
+		{`"3"`, http.StatusOK},
+		{`"2"`, http.StatusPreconditionFailed},
+		{`W/"3"`, http.StatusPreconditionFailed},
+		{`"abc"`, http.StatusPreconditionFailed},
+		{`"2", "3"`, http.StatusBadRequest},
+	}
+	requests := map[string]func(ifMatch string) *httptest.ResponseRecorder{
+		"PUT": func(ifMatch string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPut, "/task/1", strings.NewReader(`{"task":"Renamed","user_id":1}`))
+			return serveIfMatch(h.UpdateTask, req, ifMatch)
+		},
+		"PATCH": func(ifMatch string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPatch, "/task/1", strings.NewReader(`{"completed":true}`))
+			return serveIfMatch(h.PatchTask, req, ifMatch)
+		},
+		"DELETE": func(ifMatch string) *httptest.ResponseRecorder {
+			return serveIfMatch(h.DeleteTask, httptest.NewRequest(http.MethodDelete, "/task/1", nil), ifMatch)
+		},
+	}
+	for method, do := range requests {
+		for _, tc := range tests {
+			w := do(tc.ifMatch)
+			if w.Code != tc.wantCode {
+				t.Errorf("%s with If-Match %s: expected %d, got %d", method, tc.ifMatch, tc.wantCode, w.Code)
+			}
+			if method != "DELETE" && w.Code == http.StatusOK && w.Header().Get("ETag") != `"4"` {
+				t.Errorf("%s: expected the ETag of the new version, got %q", method, w.Header().Get("ETag"))
+			}
+		}
+	}
+}
+
+// serveIfMatch serves req, for task 1, with the If-Match header set if
+// ifMatch is not empty.
+func serveIfMatch(serve http.HandlerFunc, req *http.Request, ifMatch string) *httptest.ResponseRecorder {
+	req.SetPathValue("id", "1")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	serve(w, req)
+	return w
+}
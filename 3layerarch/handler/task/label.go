@@ -0,0 +1,169 @@
+package taskhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"3layerarch/handler"
+	"3layerarch/models"
+)
+
+// CreateLabel creates a label that tasks can then be given.
+func (h *Handler) CreateLabel(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
+		return
+	}
+	var l models.Label
+	if err := json.Unmarshal(body, &l); err != nil {
+		handler.WriteBadRequest(w, "Invalid JSON input")
+		return
+	}
+	created, err := h.Service.CreateLabel(r.Context(), l)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/label/"+strconv.Itoa(created.ID))
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, created)
+}
+
+func (h *Handler) GetLabel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	l, err := h.Service.GetLabel(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, l)
+}
+
+// ViewLabels lists every label by name.
+func (h *Handler) ViewLabels(w http.ResponseWriter, r *http.Request) {
+	labels, err := h.Service.ViewLabels(r.Context())
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, labels)
+}
+
+// UpdateLabel renames a label.
+func (h *Handler) UpdateLabel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
+		return
+	}
+	var l models.Label
+	if err := json.Unmarshal(body, &l); err != nil {
+		handler.WriteBadRequest(w, "Invalid JSON input")
+		return
+	}
+	updated, err := h.Service.UpdateLabel(r.Context(), id, l)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, updated)
+}
+
+// DeleteLabel deletes a label, taking it off every task.
+func (h *Handler) DeleteLabel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := h.Service.DeleteLabel(r.Context(), id); err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ViewTaskLabels lists the labels of the task in the path.
+func (h *Handler) ViewTaskLabels(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	labels, err := h.Service.ViewTaskLabels(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, labels)
+}
+
+// AddTaskLabel puts the label in the path on the task in the path and lists
+// the task's labels.
+func (h *Handler) AddTaskLabel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	labelID, ok := pathID(w, r, "label_id")
+	if !ok {
+		return
+	}
+	labels, err := h.Service.AddTaskLabel(r.Context(), id, labelID)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, labels)
+}
+
+// RemoveTaskLabel takes the label in the path off the task in the path and
+// lists the task's labels.
+func (h *Handler) RemoveTaskLabel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	labelID, ok := pathID(w, r, "label_id")
+	if !ok {
+		return
+	}
+	labels, err := h.Service.RemoveTaskLabel(r.Context(), id, labelID)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, labels)
+}
+
+// pathID reads the ID named name from the path, or writes the error and
+// reports false.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	v := r.PathValue(name)
+	if v == "" {
+		handler.WriteBadRequest(w, "Missing ID")
+		return 0, false
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	b, _ := json.Marshal(v)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
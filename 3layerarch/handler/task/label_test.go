@@ -0,0 +1,113 @@
+package taskhandler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"3layerarch/handler/task"
+	"3layerarch/models"
+)
+
+func (m *MockService) CreateLabel(ctx context.Context, l models.Label) (models.Label, error) {
+	if l.Name == "" {
+		return models.Label{}, models.Validation("label name cannot be empty")
+	}
+	l.ID = 3
+	return l, nil
+}
+
+func (m *MockService) GetLabel(ctx context.Context, id int) (models.Label, error) {
+	if id != 1 {
+		return models.Label{}, models.NotFound("label not found")
+	}
+	return models.Label{ID: 1, Name: "work"}, nil
+}
+
+func (m *MockService) ViewLabels(ctx context.Context) ([]models.Label, error) {
+	return []models.Label{{ID: 2, Name: "home"}, {ID: 1, Name: "work"}}, nil
+}
+
+func (m *MockService) UpdateLabel(ctx context.Context, id int, l models.Label) (models.Label, error) {
+	if _, err := m.GetLabel(ctx, id); err != nil {
+		return models.Label{}, err
+	}
+	l.ID = id
+	return l, nil
+}
+
+func (m *MockService) DeleteLabel(ctx context.Context, id int) error {
+	_, err := m.GetLabel(ctx, id)
+	return err
+}
+
+func (m *MockService) ViewTaskLabels(ctx context.Context, taskID int) ([]models.Label, error) {
+	if taskID != 1 {
+		return nil, models.NotFound("task not found")
+	}
+	return []models.Label{{ID: 1, Name: "work"}}, nil
+}
+
+func (m *MockService) AddTaskLabel(ctx context.Context, taskID, labelID int) ([]models.Label, error) {
+	if _, err := m.GetLabel(ctx, labelID); err != nil {
+		return nil, err
+	}
+	return m.ViewTaskLabels(ctx, taskID)
+}
+
+func (m *MockService) RemoveTaskLabel(ctx context.Context, taskID, labelID int) ([]models.Label, error) {
+	if _, err := m.ViewTaskLabels(ctx, taskID); err != nil {
+		return nil, err
+	}
+	return []models.Label{}, nil
+}
+
+func TestLabelHandlers(t *testing.T) {
+	handler := taskhandler.New(&MockService{})
+
+	tests := []struct {
+		desc     string
+		serve    http.HandlerFunc
+		method   string
+		path     map[string]string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"Create", handler.CreateLabel, http.MethodPost, nil, `{"name":"errands"}`, http.StatusCreated, `{"id":3,"name":"errands"}`},
+		{"Create without body", handler.CreateLabel, http.MethodPost, nil, "", http.StatusBadRequest, ""},
+		{"Create invalid", handler.CreateLabel, http.MethodPost, nil, `{"name":""}`, http.StatusUnprocessableEntity, ""},
+		{"Get", handler.GetLabel, http.MethodGet, map[string]string{"id": "1"}, "", http.StatusOK, `{"id":1,"name":"work"}`},
+		{"Get unknown", handler.GetLabel, http.MethodGet, map[string]string{"id": "9"}, "", http.StatusNotFound, ""},
+		{"Get invalid ID", handler.GetLabel, http.MethodGet, map[string]string{"id": "abc"}, "", http.StatusBadRequest, ""},
+		{"List", handler.ViewLabels, http.MethodGet, nil, "", http.StatusOK, `[{"id":2,"name":"home"},{"id":1,"name":"work"}]`},
+		{"Rename", handler.UpdateLabel, http.MethodPut, map[string]string{"id": "1"}, `{"name":"office"}`, http.StatusOK, `{"id":1,"name":"office"}`},
+		{"Rename invalid JSON", handler.UpdateLabel, http.MethodPut, map[string]string{"id": "1"}, `{name}`, http.StatusBadRequest, ""},
+		{"Delete", handler.DeleteLabel, http.MethodDelete, map[string]string{"id": "1"}, "", http.StatusOK, ""},
+		{"Delete unknown", handler.DeleteLabel, http.MethodDelete, map[string]string{"id": "9"}, "", http.StatusNotFound, ""},
+		{"Task labels", handler.ViewTaskLabels, http.MethodGet, map[string]string{"id": "1"}, "", http.StatusOK, `[{"id":1,"name":"work"}]`},
+		{"Unknown task's labels", handler.ViewTaskLabels, http.MethodGet, map[string]string{"id": "9"}, "", http.StatusNotFound, ""},
+		{"Add", handler.AddTaskLabel, http.MethodPut, map[string]string{"id": "1", "label_id": "1"}, "", http.StatusOK, `[{"id":1,"name":"work"}]`},
+		{"Add unknown label", handler.AddTaskLabel, http.MethodPut, map[string]string{"id": "1", "label_id": "9"}, "", http.StatusNotFound, ""},
+		{"Add invalid label ID", handler.AddTaskLabel, http.MethodPut, map[string]string{"id": "1", "label_id": "x"}, "", http.StatusBadRequest, ""},
+		{"Remove", handler.RemoveTaskLabel, http.MethodDelete, map[string]string{"id": "1", "label_id": "1"}, "", http.StatusOK, `[]`},
+		{"Remove missing label ID", handler.RemoveTaskLabel, http.MethodDelete, map[string]string{"id": "1"}, "", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, "/label", strings.NewReader(tc.body))
+		for name, v := range tc.path {
+			req.SetPathValue(name, v)
+		}
+		w := httptest.NewRecorder()
+		tc.serve(w, req)
+		if w.Code != tc.wantCode {
+			t.Errorf("%s: expected %d, got %d", tc.desc, tc.wantCode, w.Code)
+		}
+		if tc.wantBody != "" && w.Body.String() != tc.wantBody {
+			t.Errorf("%s: expected %s, got %s", tc.desc, tc.wantBody, w.Body.String())
+		}
+	}
+}
@@ -1,18 +1,30 @@
 package userhandler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"3layerarch/handler"
 	"3layerarch/models"
 )
 
 type UserService interface {
-	CreateUser(u models.User) error
-	GetUser(id int) (models.User, error)
+	CreateUser(ctx context.Context, u models.User) (models.User, error)
+	GetUser(ctx context.Context, id int) (models.User, error)
+	GetUserDetail(ctx context.Context, id int, inc models.UserInclude) (models.UserDetail, error)
+	ViewUsers(ctx context.Context, f models.UserFilter) (models.UserPage, error)
+	UpdateUser(ctx context.Context, id int, u models.User) (models.User, error)
+	PatchUser(ctx context.Context, id int, p models.UserPatch) (models.User, error)
+	DeleteUser(ctx context.Context, id int, d models.UserDelete) error
+	RestoreUser(ctx context.Context, id int) (models.User, error)
 }
 
 type Handler struct {
@@ -26,35 +38,260 @@ func New(service UserService) *Handler {
 func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil || len(body) == 0 {
-		http.Error(w, "Empty or unreadable body", http.StatusBadRequest)
+		handler.WriteBodyError(w, err)
 		return
 	}
 	var u models.User
 	if err := json.Unmarshal(body, &u); err != nil {
-		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Invalid JSON input")
 		return
 	}
-	if err := h.Service.CreateUser(u); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	created, err := h.Service.CreateUser(r.Context(), u)
+	if err != nil {
+		handler.WriteError(w, err)
 		return
 	}
+	w.Header().Set("Location", "/user/"+strconv.Itoa(created.ID))
 	w.WriteHeader(http.StatusCreated)
+	b, _ := json.Marshal(created)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
 }
 
+// GetUser returns a user. The include query parameter, a comma-separated
+// list of tasks and stats, adds the user's tasks and their task counts.
 func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	if idStr == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Missing ID")
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	inc, err := parseInclude(r.URL.Query().Get("include"))
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	var u any
+	if inc == (models.UserInclude{}) {
+		u, err = h.Service.GetUser(r.Context(), id)
+	} else {
+		u, err = h.Service.GetUserDetail(r.Context(), id, inc)
+	}
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	b, _ := json.Marshal(u)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// parseInclude reads the include query parameter of a user fetch.
+func parseInclude(v string) (models.UserInclude, error) {
+	var inc models.UserInclude
+	if v == "" {
+		return inc, nil
+	}
+	for _, name := range strings.Split(v, ",") {
+		switch strings.TrimSpace(name) {
+		case "tasks":
+			inc.Tasks = true
+		case "stats":
+			inc.Stats = true
+		default:
+			return models.UserInclude{}, fmt.Errorf("cannot include %q", name)
+		}
+	}
+	return inc, nil
+}
+
+// ViewUsers lists users ordered by ID. It accepts limit and offset query
+// parameters.
+func (h *Handler) ViewUsers(w http.ResponseWriter, r *http.Request) {
+	h.viewUsers(w, r, false)
+}
+
+// ViewTrash lists the deleted users that can still be restored, like
+// ViewUsers.
+func (h *Handler) ViewTrash(w http.ResponseWriter, r *http.Request) {
+	h.viewUsers(w, r, true)
+}
+
+func (h *Handler) viewUsers(w http.ResponseWriter, r *http.Request, deleted bool) {
+	f, err := parseUserFilter(r.URL.Query())
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	f.Deleted = deleted
+	page, err := h.Service.ViewUsers(r.Context(), f)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	if page.Offset+len(page.Users) < page.Total {
+		q := url.Values{}
+		q.Set("limit", strconv.Itoa(page.Limit))
+		q.Set("offset", strconv.Itoa(page.Offset+page.Limit))
+		page.Next = r.URL.Path + "?" + q.Encode()
+	}
+	b, _ := json.Marshal(page)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// parseUserFilter reads the paging parameters of a user listing.
+func parseUserFilter(q url.Values) (models.UserFilter, error) {
+	var f models.UserFilter
+	for name, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return models.UserFilter{}, fmt.Errorf("invalid %s value", name)
+			}
+			*dst = n
+		}
+	}
+	return f, nil
+}
+
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		handler.WriteBadRequest(w, "Missing ID")
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
+		return
+	}
+	var u models.User
+	if err := json.Unmarshal(body, &u); err != nil {
+		handler.WriteBadRequest(w, "Invalid JSON input")
+		return
+	}
+	updated, err := h.Service.UpdateUser(r.Context(), id, u)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	b, _ := json.Marshal(updated)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+func (h *Handler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		handler.WriteBadRequest(w, "Missing ID")
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
+		return
+	}
+	p, err := decodePatch(body)
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	updated, err := h.Service.PatchUser(r.Context(), id, p)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	b, _ := json.Marshal(updated)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// decodePatch parses a JSON merge-patch (RFC 7396) body. A null name, which
+// would remove it, is rejected, as are fields the user does not have.
+func decodePatch(body []byte) (models.UserPatch, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return models.UserPatch{}, errors.New("invalid JSON input")
+	}
+	for field, v := range raw {
+		if string(v) == "null" {
+			return models.UserPatch{}, fmt.Errorf("%s cannot be null", field)
+		}
+	}
+	var p models.UserPatch
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return models.UserPatch{}, fmt.Errorf("invalid patch: %v", err)
+	}
+	return p, nil
+}
+
+// DeleteUser moves a user to the trash. The tasks query parameter picks
+// what happens to their tasks (reject, cascade or reassign); reassign also
+// needs reassign_to, the ID of the user who takes them over.
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		handler.WriteBadRequest(w, "Missing ID")
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	d := models.UserDelete{Policy: models.DeletePolicy(r.URL.Query().Get("tasks"))}
+	if v := r.URL.Query().Get("reassign_to"); v != "" {
+		if d.ReassignTo, err = strconv.Atoi(v); err != nil {
+			handler.WriteBadRequest(w, "invalid reassign_to value")
+			return
+		}
+	}
+	if err := h.Service.DeleteUser(r.Context(), id, d); err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RestoreUser takes a deleted user, and the tasks deleted with them, out of
+// the trash and returns the user.
+func (h *Handler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		handler.WriteBadRequest(w, "Missing ID")
 		return
 	}
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Invalid ID format")
 		return
 	}
-	u, err := h.Service.GetUser(id)
+	u, err := h.Service.RestoreUser(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		handler.WriteError(w, err)
 		return
 	}
 	b, _ := json.Marshal(u)
@@ -2,11 +2,14 @@ package userhandler_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"3layerarch/handler/user"
 	"3layerarch/models"
@@ -14,21 +17,54 @@ import (
 
 // MockUserService implements UserService interface with function fields
 type MockUserService struct {
-	CreateUserFn func(u models.User) error
-	GetUserFn    func(id int) (models.User, error)
+	CreateUserFn    func(ctx context.Context, u models.User) (models.User, error)
+	GetUserFn       func(ctx context.Context, id int) (models.User, error)
+	GetUserDetailFn func(ctx context.Context, id int, inc models.UserInclude) (models.UserDetail, error)
+	ViewUsersFn     func(ctx context.Context, f models.UserFilter) (models.UserPage, error)
+	UpdateUserFn    func(ctx context.Context, id int, u models.User) (models.User, error)
+	PatchUserFn     func(ctx context.Context, id int, p models.UserPatch) (models.User, error)
+	DeleteUserFn    func(ctx context.Context, id int, d models.UserDelete) error
+	RestoreUserFn   func(ctx context.Context, id int) (models.User, error)
 }
 
-func (m *MockUserService) CreateUser(u models.User) error {
-	return m.CreateUserFn(u)
+func (m *MockUserService) CreateUser(ctx context.Context, u models.User) (models.User, error) {
+	return m.CreateUserFn(ctx, u)
 }
 
-func (m *MockUserService) GetUser(id int) (models.User, error) {
-	return m.GetUserFn(id)
+func (m *MockUserService) GetUser(ctx context.Context, id int) (models.User, error) {
+	return m.GetUserFn(ctx, id)
+}
+
+func (m *MockUserService) GetUserDetail(ctx context.Context, id int, inc models.UserInclude) (models.UserDetail, error) {
+	return m.GetUserDetailFn(ctx, id, inc)
+}
+
+func (m *MockUserService) ViewUsers(ctx context.Context, f models.UserFilter) (models.UserPage, error) {
+	return m.ViewUsersFn(ctx, f)
+}
+
+func (m *MockUserService) UpdateUser(ctx context.Context, id int, u models.User) (models.User, error) {
+	return m.UpdateUserFn(ctx, id, u)
+}
+
+func (m *MockUserService) PatchUser(ctx context.Context, id int, p models.UserPatch) (models.User, error) {
+	return m.PatchUserFn(ctx, id, p)
+}
+
+func (m *MockUserService) DeleteUser(ctx context.Context, id int, d models.UserDelete) error {
+	return m.DeleteUserFn(ctx, id, d)
+}
+
+func (m *MockUserService) RestoreUser(ctx context.Context, id int) (models.User, error) {
+	return m.RestoreUserFn(ctx, id)
 }
 
 func TestCreateUserHandler_Success(t *testing.T) {
 	mockSvc := &MockUserService{
-		CreateUserFn: func(u models.User) error { return nil },
+		CreateUserFn: func(ctx context.Context, u models.User) (models.User, error) {
+			u.ID = 3
+			return u, nil
+		},
 	}
 	handler := userhandler.New(mockSvc)
 
@@ -43,6 +79,17 @@ func TestCreateUserHandler_Success(t *testing.T) {
 	if w.Result().StatusCode != http.StatusCreated {
 		t.Errorf("expected status 201 Created, got %d", w.Result().StatusCode)
 	}
+	if loc := w.Header().Get("Location"); loc != "/user/3" {
+		t.Errorf("expected Location /user/3, got %q", loc)
+	}
+
+	var created models.User
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if created.ID != 3 || created.Name != "Alice" {
+		t.Errorf("unexpected user: %+v", created)
+	}
 }
 
 func TestCreateUserHandler_EmptyBody(t *testing.T) {
@@ -75,7 +122,9 @@ func TestCreateUserHandler_InvalidJSON(t *testing.T) {
 
 func TestCreateUserHandler_ServiceError(t *testing.T) {
 	mockSvc := &MockUserService{
-		CreateUserFn: func(u models.User) error { return errors.New("service error") },
+		CreateUserFn: func(ctx context.Context, u models.User) (models.User, error) {
+			return models.User{}, models.Validation("user name cannot be empty")
+		},
 	}
 	handler := userhandler.New(mockSvc)
 
@@ -87,14 +136,37 @@ func TestCreateUserHandler_ServiceError(t *testing.T) {
 
 	handler.CreateUser(w, req)
 
-	if w.Result().StatusCode != http.StatusBadRequest {
-		t.Errorf("expected status 400 BadRequest, got %d", w.Result().StatusCode)
+	if w.Result().StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 UnprocessableEntity, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestCreateUserHandler_InternalError(t *testing.T) {
+	mockSvc := &MockUserService{
+		CreateUserFn: func(ctx context.Context, u models.User) (models.User, error) {
+			return models.User{}, errors.New("Error 1146: Table 'USERS' doesn't exist")
+		},
+	}
+	handler := userhandler.New(mockSvc)
+
+	body, _ := json.Marshal(models.User{Name: "Alice"})
+
+	req := httptest.NewRequest(http.MethodPost, "/user", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.CreateUser(w, req)
+
+	if w.Result().StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected status 500 InternalServerError, got %d", w.Result().StatusCode)
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("USERS")) {
+		t.Errorf("internal error leaked to client: %s", w.Body.String())
 	}
 }
 
 func TestGetUserHandler_Success(t *testing.T) {
 	mockSvc := &MockUserService{
-		GetUserFn: func(id int) (models.User, error) {
+		GetUserFn: func(ctx context.Context, id int) (models.User, error) {
 			return models.User{ID: id, Name: "Alice"}, nil
 		},
 	}
@@ -120,6 +192,55 @@ func TestGetUserHandler_Success(t *testing.T) {
 	}
 }
 
+func TestGetUserHandler_Include(t *testing.T) {
+	day := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var got models.UserInclude
+	mockSvc := &MockUserService{
+		GetUserDetailFn: func(ctx context.Context, id int, inc models.UserInclude) (models.UserDetail, error) {
+			got = inc
+			return models.UserDetail{
+				User:  models.User{ID: id, Name: "Alice"},
+				Tasks: []models.Task{{ID: 3, Task: "Buy milk", UserID: id, Version: 1, CreatedAt: day, UpdatedAt: day}},
+				Stats: &models.UserStats{Open: 1},
+			}, nil
+		},
+	}
+	handler := userhandler.New(mockSvc)
+
+	req := httptest.NewRequest(http.MethodGet, "/user/1?include=tasks,stats", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetUser(w, req)
+
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 OK, got %d", w.Result().StatusCode)
+	}
+	if got != (models.UserInclude{Tasks: true, Stats: true}) {
+		t.Errorf("unexpected include: %+v", got)
+	}
+	want := `{"id":1,"name":"Alice","tasks":[{"id":3,"task":"Buy milk","completed":false,"user_id":1,"version":1,` +
+		`"created_at":"2026-01-02T03:04:05Z","updated_at":"2026-01-02T03:04:05Z"}],"stats":{"open":1,"completed":0}}`
+	if w.Body.String() != want {
+		t.Errorf("expected body %s, got %s", want, w.Body.String())
+	}
+}
+
+func TestGetUserHandler_UnknownInclude(t *testing.T) {
+	mockSvc := &MockUserService{}
+	handler := userhandler.New(mockSvc)
+
+	req := httptest.NewRequest(http.MethodGet, "/user/1?include=tasks,friends", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	handler.GetUser(w, req)
+
+	if w.Result().StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 BadRequest, got %d", w.Result().StatusCode)
+	}
+}
+
 func TestGetUserHandler_MissingID(t *testing.T) {
 	mockSvc := &MockUserService{}
 	handler := userhandler.New(mockSvc)
@@ -150,18 +271,220 @@ func TestGetUserHandler_InvalidID(t *testing.T) {
 
 func TestGetUserHandler_ServiceError(t *testing.T) {
 	mockSvc := &MockUserService{
-		GetUserFn: func(id int) (models.User, error) {
-			return models.User{}, errors.New("service error")
+		GetUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{}, models.NotFound("user not found")
 		},
 	}
 	handler := userhandler.New(mockSvc)
 
-	req := httptest.NewRequest(http.MethodGet, "/user?id=1", nil)
+	req := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+	req.SetPathValue("id", "1")
 	w := httptest.NewRecorder()
 
 	handler.GetUser(w, req)
 
-	if w.Result().StatusCode != http.StatusBadRequest {
-		t.Errorf("expected status 400 BadRequest, got %d", w.Result().StatusCode)
+	if w.Result().StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 NotFound, got %d", w.Result().StatusCode)
+	}
+}
+
+func TestViewUsersHandler(t *testing.T) {
+	mockSvc := &MockUserService{
+		ViewUsersFn: func(ctx context.Context, f models.UserFilter) (models.UserPage, error) {
+			if f.Limit > 100 {
+				return models.UserPage{}, models.Validation("limit must be between 1 and 100")
+			}
+			return models.UserPage{Users: []models.User{{ID: 3, Name: "Carol"}}, Total: 5, Limit: 1, Offset: f.Offset}, nil
+		},
+	}
+	handler := userhandler.New(mockSvc)
+
+	req := httptest.NewRequest(http.MethodGet, "/user?limit=1&offset=2", nil)
+	w := httptest.NewRecorder()
+	handler.ViewUsers(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 OK, got %d", w.Code)
+	}
+	var page models.UserPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(page.Users) != 1 || page.Next != "/user?limit=1&offset=3" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/user?limit=ten", nil)
+	w = httptest.NewRecorder()
+	handler.ViewUsers(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for limit=ten, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/user?limit=1000", nil)
+	w = httptest.NewRecorder()
+	handler.ViewUsers(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for limit=1000, got %d", w.Code)
+	}
+}
+
+func TestUpdateUserHandler(t *testing.T) {
+	mockSvc := &MockUserService{
+		UpdateUserFn: func(ctx context.Context, id int, u models.User) (models.User, error) {
+			if u.Name == "Bob" {
+				return models.User{}, models.Conflict("user name already taken")
+			}
+			return models.User{ID: id, Name: u.Name}, nil
+		},
+	}
+	handler := userhandler.New(mockSvc)
+
+	req := httptest.NewRequest(http.MethodPut, "/user/1", bytes.NewReader([]byte(`{"name":"Alicia"}`)))
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.UpdateUser(w, req)
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(`"name":"Alicia"`)) {
+		t.Errorf("expected the updated user, got %d %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/user/1", bytes.NewReader([]byte(`{"name":"Bob"}`)))
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handler.UpdateUser(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 Conflict, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/user/abc", bytes.NewReader([]byte(`{"name":"Bob"}`)))
+	req.SetPathValue("id", "abc")
+	w = httptest.NewRecorder()
+	handler.UpdateUser(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 BadRequest, got %d", w.Code)
+	}
+}
+
+func TestPatchUserHandler(t *testing.T) {
+	mockSvc := &MockUserService{
+		PatchUserFn: func(ctx context.Context, id int, p models.UserPatch) (models.User, error) {
+			return models.User{ID: id, Name: *p.Name}, nil
+		},
+	}
+	handler := userhandler.New(mockSvc)
+
+	req := httptest.NewRequest(http.MethodPatch, "/user/1", bytes.NewReader([]byte(`{"name":"Alicia"}`)))
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.PatchUser(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 OK, got %d", w.Code)
+	}
+
+	for _, body := range []string{`{"name":null}`, `{"email":"a@b.c"}`, `{`} {
+		req = httptest.NewRequest(http.MethodPatch, "/user/1", bytes.NewReader([]byte(body)))
+		req.SetPathValue("id", "1")
+		w = httptest.NewRecorder()
+		handler.PatchUser(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400 BadRequest, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestDeleteUserHandler(t *testing.T) {
+	var got models.UserDelete
+	mockSvc := &MockUserService{
+		DeleteUserFn: func(ctx context.Context, id int, d models.UserDelete) error {
+			got = d
+			if id == 2 {
+				return models.Conflict("user still owns 3 tasks")
+			}
+			return nil
+		},
+	}
+	handler := userhandler.New(mockSvc)
+
+	req := httptest.NewRequest(http.MethodDelete, "/user/1?tasks=reassign&reassign_to=4", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.DeleteUser(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 OK, got %d", w.Code)
+	}
+	if got != (models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 4}) {
+		t.Errorf("unexpected delete options: %+v", got)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/user/2", nil)
+	req.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	handler.DeleteUser(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 Conflict, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/user/1?tasks=reassign&reassign_to=bob", nil)
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handler.DeleteUser(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 BadRequest, got %d", w.Code)
+	}
+}
+
+func TestViewTrashHandler(t *testing.T) {
+	var got models.UserFilter
+	mockSvc := &MockUserService{
+		ViewUsersFn: func(ctx context.Context, f models.UserFilter) (models.UserPage, error) {
+			got = f
+			return models.UserPage{Users: []models.User{{ID: 3, Name: "Carol"}}, Total: 2, Limit: 1}, nil
+		},
+	}
+	handler := userhandler.New(mockSvc)
+
+	req := httptest.NewRequest(http.MethodGet, "/trash/users?limit=1", nil)
+	w := httptest.NewRecorder()
+	handler.ViewTrash(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 OK, got %d", w.Code)
+	}
+	if !got.Deleted {
+		t.Errorf("expected the users in the trash to be asked for, got %+v", got)
+	}
+	var page models.UserPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if page.Next != "/trash/users?limit=1&offset=1" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+}
+
+func TestRestoreUserHandler(t *testing.T) {
+	mockSvc := &MockUserService{
+		RestoreUserFn: func(ctx context.Context, id int) (models.User, error) {
+			if id != 1 {
+				return models.User{}, models.NotFound("user not found in the trash")
+			}
+			return models.User{ID: 1, Name: "Alice"}, nil
+		},
+	}
+	handler := userhandler.New(mockSvc)
+
+	req := httptest.NewRequest(http.MethodPost, "/user/1/restore", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler.RestoreUser(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Alice") {
+		t.Errorf("expected status 200 OK with the user, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/user/2/restore", nil)
+	req.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	handler.RestoreUser(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
 	}
 }
@@ -0,0 +1,80 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"3layerarch/health"
+)
+
+func probe(t *testing.T, h http.HandlerFunc) (int, health.Report) {
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var report health.Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	return w.Code, report
+}
+
+func TestReady(t *testing.T) {
+	var dbErr error
+	c := health.New(50 * time.Millisecond)
+	c.Add("mysql", func(ctx context.Context) error { return dbErr })
+	c.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	tests := []struct {
+		desc   string
+		dbErr  error
+		status int
+		want   health.Report
+	}{
+		{
+			"slow check times out", nil, http.StatusServiceUnavailable,
+			health.Report{Status: health.StatusDegraded, Checks: map[string]string{"mysql": "ok", "slow": "down"}},
+		},
+		{
+			"every check fails", errors.New("dial tcp: connection refused"), http.StatusServiceUnavailable,
+			health.Report{Status: health.StatusDegraded, Checks: map[string]string{"mysql": "down", "slow": "down"}},
+		},
+	}
+	for _, tc := range tests {
+		dbErr = tc.dbErr
+		status, report := probe(t, c.Ready)
+		if status != tc.status || !reflect.DeepEqual(report, tc.want) {
+			t.Errorf("%s: expected %d %+v, got %d %+v", tc.desc, tc.status, tc.want, status, report)
+		}
+	}
+}
+
+func TestReady_Healthy(t *testing.T) {
+	c := health.New(time.Second)
+	c.Add("mysql", func(ctx context.Context) error { return nil })
+
+	status, report := probe(t, c.Ready)
+	if status != http.StatusOK || report.Status != health.StatusOK || report.Checks["mysql"] != health.StatusOK {
+		t.Errorf("expected ready, got %d %+v", status, report)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	c := health.New(time.Second)
+	c.Shutdown()
+
+	if status, report := probe(t, c.Ready); status != http.StatusServiceUnavailable || report.Status != health.StatusShuttingDown {
+		t.Errorf("expected not ready while shutting down, got %d %+v", status, report)
+	}
+	// A draining server is still alive
+	if status, report := probe(t, c.Live); status != http.StatusOK || report.Status != health.StatusOK {
+		t.Errorf("expected alive while shutting down, got %d %+v", status, report)
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"3layerarch/handler"
+)
+
+// MaxBodySize refuses request bodies of more than n bytes with a 413. A body
+// whose length is declared is refused before it is read; any other fails to
+// read past the limit. A zero n sets no limit.
+func MaxBodySize(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		if n <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				handler.WriteError(w, &http.MaxBytesError{Limit: n})
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Package middleware holds the handlers every request passes through before
+// it reaches the routes: request IDs, access logging, panic recovery, CORS
+// and body size limits.
+package middleware
+
+import (
+	"net/http"
+)
+
+// Middleware wraps a handler with behaviour of its own.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in m, the first of which sees each request first.
+func Chain(h http.Handler, m ...Middleware) http.Handler {
+	for i := len(m) - 1; i >= 0; i-- {
+		h = m[i](h)
+	}
+	return h
+}
+
+// Recorder remembers the status and size of the response written through
+// it, for the middleware here and the metrics to report on.
+type Recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// NewRecorder returns a Recorder that writes through to w.
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w}
+}
+
+// Status is the status of the response: 200 if the handler wrote nothing,
+// as the server sends then.
+func (r *Recorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// Bytes is the size of the response body written so far.
+func (r *Recorder) Bytes() int { return r.bytes }
+
+func (r *Recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the writer underneath.
+func (r *Recorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"3layerarch/handler"
+)
+
+// Recover turns a panic in a handler into a 500 error response, logged with
+// its stack, instead of a dropped connection. A handler that had already
+// started its response has it cut off, so the client cannot take it for a
+// whole one.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := NewRecorder(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// The server's own way of aborting a response
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			err := fmt.Errorf("panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, RequestIDFrom(r.Context()), v, debug.Stack())
+			if rec.status != 0 {
+				log.Print(err)
+				panic(http.ErrAbortHandler)
+			}
+			handler.WriteError(rec, err)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"3layerarch/models"
+)
+
+// RequestIDHeader carries the ID of a request, both from a client or proxy
+// that already gave it one and back in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds the IDs taken from clients, which end up in logs.
+const maxRequestIDLen = 128
+
+// RequestID makes sure every request has an ID. A valid ID sent by the client
+// is kept so that a request can be followed across services; otherwise a new
+// one is made. The ID is set on the response and carried in the context.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+			r.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(models.WithRequestID(r.Context(), id)))
+	})
+}
+
+// RequestIDFrom returns the ID RequestID gave the request of ctx, or "".
+func RequestIDFrom(ctx context.Context) string {
+	return models.RequestIDFrom(ctx)
+}
+
+// validRequestID allows the characters of UUIDs and the usual trace IDs, so
+// that a client cannot forge log lines with one.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b) // never fails, see crypto/rand
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,216 @@
+// Package migrate applies versioned schema changes to the database and
+// records the ones that have run in the SCHEMA_MIGRATIONS table.
+//
+// The versions are those of 3layerarch and day-15_mockgen, which share a
+// schema. Other apps keep theirs in a database of their own, test_db being
+// this one's.
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"3layerarch/store"
+)
+
+// Migration is one schema change. Down undoes exactly what Up does.
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// Status is a migration and whether it has been applied.
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// ErrOutdated is returned by Check when migrations are pending.
+var ErrOutdated = errors.New("database schema is out of date")
+
+const createVersionTable = `CREATE TABLE IF NOT EXISTS SCHEMA_MIGRATIONS (
+	version INT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    store.Dialect
+	migrations []Migration
+}
+
+// New returns a Migrator for migrations, which must be ordered by version
+// and written for dialect.
+func New(db *sql.DB, dialect store.Dialect, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}
+}
+
+// applied returns the versions recorded in SCHEMA_MIGRATIONS, creating the
+// table on first use.
+func (m *Migrator) applied() (map[int]bool, error) {
+	if _, err := m.db.Exec(createVersionTable); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.Query("SELECT version FROM SCHEMA_MIGRATIONS")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}()
+
+	versions := map[int]bool{}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions[v] = true
+	}
+	return versions, rows.Err()
+}
+
+// Status lists every migration in order and whether it has been applied.
+func (m *Migrator) Status() ([]Status, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	status := make([]Status, len(m.migrations))
+	for i, mg := range m.migrations {
+		status[i] = Status{Migration: mg, Applied: versions[mg.Version]}
+	}
+	return status, nil
+}
+
+// Up applies every pending migration in order and returns the ones it
+// applied. It stops at the first failure.
+func (m *Migrator) Up() ([]Migration, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mg := range m.migrations {
+		if versions[mg.Version] {
+			continue
+		}
+		if err := m.run(mg, mg.Up, "INSERT INTO SCHEMA_MIGRATIONS (version, name) VALUES (?, ?)", mg.Version, mg.Name); err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", mg.Version, mg.Name, err)
+		}
+		done = append(done, mg)
+	}
+	return done, nil
+}
+
+// Down reverts the latest applied migration and returns it.
+func (m *Migrator) Down() (Migration, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return Migration{}, err
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if !versions[mg.Version] {
+			continue
+		}
+		if err := m.run(mg, mg.Down, "DELETE FROM SCHEMA_MIGRATIONS WHERE version = ?", mg.Version); err != nil {
+			return Migration{}, fmt.Errorf("reverting migration %d %s: %w", mg.Version, mg.Name, err)
+		}
+		return mg, nil
+	}
+	return Migration{}, errors.New("no migrations have been applied")
+}
+
+// run executes stmts and then record in one transaction. MySQL commits
+// schema changes implicitly, so a migration that fails part way there may
+// need fixing by hand before it is run again.
+func (m *Migrator) run(mg Migration, stmts []string, record string, args ...any) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Println("Error rolling back migration:", err)
+		}
+	}()
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(m.dialect.Rebind(record), args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Check returns an error wrapping ErrOutdated if any migration is pending,
+// so a server never runs against an older schema than it was built for.
+func (m *Migrator) Check() error {
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, s := range status {
+		if !s.Applied {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migrations, run migrate up", ErrOutdated, pending)
+	}
+	return nil
+}
+
+// Run carries out a migrate command and reports on w: up applies every
+// pending migration, down reverts the latest one and status lists them.
+func Run(m *Migrator, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate up|down|status")
+	}
+	switch args[0] {
+	case "up":
+		done, err := m.Up()
+		for _, mg := range done {
+			fmt.Fprintf(w, "applied %d %s\n", mg.Version, mg.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(w, "schema is up to date")
+		}
+		return err
+	case "down":
+		mg, err := m.Down()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "reverted %d %s\n", mg.Version, mg.Name)
+		return nil
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Fprintf(w, "%d %s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, want up, down or status", args[0])
+	}
+}
@@ -0,0 +1,590 @@
+package migrate
+
+import "3layerarch/store"
+
+// For returns the migrations written for dialect.
+func For(dialect store.Dialect) []Migration {
+	switch dialect.Name {
+	case store.Postgres.Name:
+		return PostgresMigrations
+	case store.SQLite.Name:
+		return SQLiteMigrations
+	default:
+		return Migrations
+	}
+}
+
+// Migrations is the schema of the task and user tables on MySQL, oldest
+// first. Add new changes to the end; never edit one that has been released.
+var Migrations = []Migration{
+	{
+		// The tables as the servers used to create them, so an existing
+		// database adopts the migrations without losing data.
+		Version: 1,
+		Name:    "create_tables",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS USERS (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				name VARCHAR(100)
+			)`,
+			`CREATE TABLE IF NOT EXISTS TASKS (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				task TEXT,
+				completed BOOL DEFAULT FALSE,
+				user_id INT
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE TASKS",
+			"DROP TABLE USERS",
+		},
+	},
+	{
+		// Tasks without an owner fail this migration and must be assigned
+		// to a user first.
+		Version: 2,
+		Name:    "not_null",
+		Up: []string{
+			"UPDATE TASKS SET task = '' WHERE task IS NULL",
+			"UPDATE TASKS SET completed = FALSE WHERE completed IS NULL",
+			"UPDATE USERS SET name = CONCAT('user ', id) WHERE name IS NULL",
+			"ALTER TABLE USERS MODIFY name VARCHAR(100) NOT NULL",
+			"ALTER TABLE TASKS MODIFY task TEXT NOT NULL, MODIFY completed BOOL NOT NULL DEFAULT FALSE, MODIFY user_id INT NOT NULL",
+		},
+		Down: []string{
+			"ALTER TABLE TASKS MODIFY task TEXT, MODIFY completed BOOL DEFAULT FALSE, MODIFY user_id INT",
+			"ALTER TABLE USERS MODIFY name VARCHAR(100)",
+		},
+	},
+	{
+		Version: 3,
+		Name:    "indexes",
+		Up: []string{
+			"CREATE INDEX idx_tasks_user_id ON TASKS (user_id)",
+			"CREATE INDEX idx_tasks_completed ON TASKS (completed)",
+			"CREATE INDEX idx_users_name ON USERS (name)",
+		},
+		Down: []string{
+			"DROP INDEX idx_users_name ON USERS",
+			"DROP INDEX idx_tasks_completed ON TASKS",
+			"DROP INDEX idx_tasks_user_id ON TASKS",
+		},
+	},
+	{
+		// Tasks whose user no longer exists fail this migration.
+		Version: 4,
+		Name:    "tasks_user_fk",
+		Up: []string{
+			"ALTER TABLE TASKS ADD CONSTRAINT fk_tasks_user FOREIGN KEY (user_id) REFERENCES USERS (id)",
+		},
+		Down: []string{
+			"ALTER TABLE TASKS DROP FOREIGN KEY fk_tasks_user",
+		},
+	},
+	{
+		// NULL until the user sets a password; such users cannot log in.
+		Version: 5,
+		Name:    "user_password",
+		Up: []string{
+			"ALTER TABLE USERS ADD COLUMN password_hash VARCHAR(255) NULL",
+		},
+		Down: []string{
+			"ALTER TABLE USERS DROP COLUMN password_hash",
+		},
+	},
+	{
+		// Every existing user is a plain user; admins are made by hand.
+		Version: 6,
+		Name:    "user_role",
+		Up: []string{
+			"ALTER TABLE USERS ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user'",
+		},
+		Down: []string{
+			"ALTER TABLE USERS DROP COLUMN role",
+		},
+	},
+	{
+		// Tasks count their writes from 1, so updates can be made against
+		// the version a client last saw.
+		Version: 7,
+		Name:    "task_version",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN version INT NOT NULL DEFAULT 1",
+		},
+		Down: []string{
+			"ALTER TABLE TASKS DROP COLUMN version",
+		},
+	},
+	{
+		// Deleted tasks and users stay in the trash, where only restores and
+		// the purge see them, until deleted_at is past the retention. Going
+		// down empties the trash for good, or it would come back to life.
+		Version: 8,
+		Name:    "soft_delete",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN deleted_at DATETIME(6) NULL",
+			"ALTER TABLE USERS ADD COLUMN deleted_at DATETIME(6) NULL",
+		},
+		Down: []string{
+			"DELETE FROM TASKS WHERE deleted_at IS NOT NULL OR user_id IN (SELECT id FROM USERS WHERE deleted_at IS NOT NULL)",
+			"DELETE FROM USERS WHERE deleted_at IS NOT NULL",
+			"ALTER TABLE USERS DROP COLUMN deleted_at",
+			"ALTER TABLE TASKS DROP COLUMN deleted_at",
+		},
+	},
+	{
+		// Every change the services make to a task or user is recorded in
+		// the same transaction. Nothing updates or deletes these rows.
+		Version: 9,
+		Name:    "audit",
+		Up: []string{
+			`CREATE TABLE AUDIT (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				created_at DATETIME(6) NOT NULL,
+				actor VARCHAR(100) NOT NULL,
+				action VARCHAR(20) NOT NULL,
+				entity VARCHAR(20) NOT NULL,
+				entity_id INT NOT NULL,
+				changes TEXT NOT NULL,
+				request_id VARCHAR(128) NOT NULL
+			)`,
+			"CREATE INDEX idx_audit_entity ON AUDIT (entity, entity_id)",
+		},
+		Down: []string{
+			"DROP TABLE AUDIT",
+		},
+	},
+	{
+		// Tasks that predate the timestamps are taken to have been created,
+		// and completed if they are, when the migration ran.
+		Version: 10,
+		Name:    "task_timestamps",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN created_at DATETIME(6) NULL, ADD COLUMN updated_at DATETIME(6) NULL, ADD COLUMN completed_at DATETIME(6) NULL",
+			"UPDATE TASKS SET created_at = UTC_TIMESTAMP(6), updated_at = UTC_TIMESTAMP(6), completed_at = CASE WHEN completed THEN UTC_TIMESTAMP(6) END",
+			"ALTER TABLE TASKS MODIFY created_at DATETIME(6) NOT NULL, MODIFY updated_at DATETIME(6) NOT NULL",
+			"CREATE INDEX idx_tasks_created_at ON TASKS (created_at)",
+			"CREATE INDEX idx_tasks_completed_at ON TASKS (completed_at)",
+		},
+		Down: []string{
+			"DROP INDEX idx_tasks_completed_at ON TASKS",
+			"DROP INDEX idx_tasks_created_at ON TASKS",
+			"ALTER TABLE TASKS DROP COLUMN completed_at, DROP COLUMN updated_at, DROP COLUMN created_at",
+		},
+	},
+	{
+		Version: 11,
+		Name:    "task_planning",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN priority VARCHAR(10) NOT NULL DEFAULT '', ADD COLUMN due_at DATETIME(6) NULL",
+			"CREATE INDEX idx_tasks_due_at ON TASKS (due_at)",
+			`CREATE TABLE LABELS (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				name VARCHAR(50) NOT NULL,
+				CONSTRAINT uq_labels_name UNIQUE (name)
+			)`,
+			`CREATE TABLE TASK_LABELS (
+				task_id INT NOT NULL,
+				label_id INT NOT NULL,
+				PRIMARY KEY (task_id, label_id),
+				CONSTRAINT fk_task_labels_task FOREIGN KEY (task_id) REFERENCES TASKS (id) ON DELETE CASCADE,
+				CONSTRAINT fk_task_labels_label FOREIGN KEY (label_id) REFERENCES LABELS (id) ON DELETE CASCADE
+			)`,
+			"CREATE INDEX idx_task_labels_label_id ON TASK_LABELS (label_id)",
+		},
+		Down: []string{
+			"DROP TABLE TASK_LABELS",
+			"DROP TABLE LABELS",
+			"DROP INDEX idx_tasks_due_at ON TASKS",
+			"ALTER TABLE TASKS DROP COLUMN due_at, DROP COLUMN priority",
+		},
+	},
+	{
+		Version: 12,
+		Name:    "task_dependencies",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN parent_id INT NULL",
+			"CREATE INDEX idx_tasks_parent_id ON TASKS (parent_id)",
+			"ALTER TABLE TASKS ADD CONSTRAINT fk_tasks_parent FOREIGN KEY (parent_id) REFERENCES TASKS (id) ON DELETE SET NULL",
+			`CREATE TABLE TASK_DEPENDENCIES (
+				task_id INT NOT NULL,
+				blocker_id INT NOT NULL,
+				PRIMARY KEY (task_id, blocker_id),
+				CONSTRAINT fk_task_dependencies_task FOREIGN KEY (task_id) REFERENCES TASKS (id) ON DELETE CASCADE,
+				CONSTRAINT fk_task_dependencies_blocker FOREIGN KEY (blocker_id) REFERENCES TASKS (id) ON DELETE CASCADE
+			)`,
+			"CREATE INDEX idx_task_dependencies_blocker_id ON TASK_DEPENDENCIES (blocker_id)",
+		},
+		Down: []string{
+			"DROP TABLE TASK_DEPENDENCIES",
+			"ALTER TABLE TASKS DROP FOREIGN KEY fk_tasks_parent",
+			"DROP INDEX idx_tasks_parent_id ON TASKS",
+			"ALTER TABLE TASKS DROP COLUMN parent_id",
+		},
+	},
+	{
+		// Entries from before the column have 0: only the name of whoever
+		// made them is known.
+		Version: 13,
+		Name:    "audit_actor_id",
+		Up: []string{
+			"ALTER TABLE AUDIT ADD COLUMN actor_id INT NOT NULL DEFAULT 0",
+		},
+		Down: []string{
+			"ALTER TABLE AUDIT DROP COLUMN actor_id",
+		},
+	},
+	{
+		// User names are unique, users in the trash included. Users that
+		// share a name fail this migration and must be renamed first.
+		Version: 14,
+		Name:    "users_name_unique",
+		Up: []string{
+			"DROP INDEX idx_users_name ON USERS",
+			"CREATE UNIQUE INDEX idx_users_name ON USERS (name)",
+		},
+		Down: []string{
+			"DROP INDEX idx_users_name ON USERS",
+			"CREATE INDEX idx_users_name ON USERS (name)",
+		},
+	},
+}
+
+// SQLiteMigrations is the schema on SQLite, which cannot change columns in
+// place. It starts at the schema of version 6 in one step; later changes
+// take the version they have in Migrations.
+//
+// Every change to the schema is added to each dialect's migrations.
+var SQLiteMigrations = []Migration{
+	{
+		Version: 6,
+		Name:    "create_tables",
+		Up: []string{
+			`CREATE TABLE USERS (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name VARCHAR(100) NOT NULL,
+				password_hash VARCHAR(255) NULL,
+				role VARCHAR(20) NOT NULL DEFAULT 'user'
+			)`,
+			`CREATE TABLE TASKS (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				task TEXT NOT NULL,
+				completed BOOLEAN NOT NULL DEFAULT FALSE,
+				user_id INTEGER NOT NULL,
+				CONSTRAINT fk_tasks_user FOREIGN KEY (user_id) REFERENCES USERS (id)
+			)`,
+			"CREATE INDEX idx_tasks_user_id ON TASKS (user_id)",
+			"CREATE INDEX idx_tasks_completed ON TASKS (completed)",
+			"CREATE INDEX idx_users_name ON USERS (name)",
+		},
+		Down: []string{
+			"DROP TABLE TASKS",
+			"DROP TABLE USERS",
+		},
+	},
+	{
+		Version: 7,
+		Name:    "task_version",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN version INTEGER NOT NULL DEFAULT 1",
+		},
+		Down: []string{
+			"ALTER TABLE TASKS DROP COLUMN version",
+		},
+	},
+	{
+		Version: 8,
+		Name:    "soft_delete",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN deleted_at DATETIME NULL",
+			"ALTER TABLE USERS ADD COLUMN deleted_at DATETIME NULL",
+		},
+		Down: []string{
+			"DELETE FROM TASKS WHERE deleted_at IS NOT NULL OR user_id IN (SELECT id FROM USERS WHERE deleted_at IS NOT NULL)",
+			"DELETE FROM USERS WHERE deleted_at IS NOT NULL",
+			"ALTER TABLE USERS DROP COLUMN deleted_at",
+			"ALTER TABLE TASKS DROP COLUMN deleted_at",
+		},
+	},
+	{
+		Version: 9,
+		Name:    "audit",
+		Up: []string{
+			`CREATE TABLE AUDIT (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME NOT NULL,
+				actor VARCHAR(100) NOT NULL,
+				action VARCHAR(20) NOT NULL,
+				entity VARCHAR(20) NOT NULL,
+				entity_id INTEGER NOT NULL,
+				changes TEXT NOT NULL,
+				request_id VARCHAR(128) NOT NULL
+			)`,
+			"CREATE INDEX idx_audit_entity ON AUDIT (entity, entity_id)",
+		},
+		Down: []string{
+			"DROP TABLE AUDIT",
+		},
+	},
+	{
+		// SQLite cannot make the columns NOT NULL afterwards; the stores
+		// always set them. Times are written as the driver writes them.
+		Version: 10,
+		Name:    "task_timestamps",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN created_at DATETIME NULL",
+			"ALTER TABLE TASKS ADD COLUMN updated_at DATETIME NULL",
+			"ALTER TABLE TASKS ADD COLUMN completed_at DATETIME NULL",
+			`UPDATE TASKS SET created_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'), updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+				completed_at = CASE WHEN completed THEN strftime('%Y-%m-%d %H:%M:%f+00:00', 'now') END`,
+			"CREATE INDEX idx_tasks_created_at ON TASKS (created_at)",
+			"CREATE INDEX idx_tasks_completed_at ON TASKS (completed_at)",
+		},
+		Down: []string{
+			"DROP INDEX idx_tasks_completed_at",
+			"DROP INDEX idx_tasks_created_at",
+			"ALTER TABLE TASKS DROP COLUMN completed_at",
+			"ALTER TABLE TASKS DROP COLUMN updated_at",
+			"ALTER TABLE TASKS DROP COLUMN created_at",
+		},
+	},
+	{
+		Version: 11,
+		Name:    "task_planning",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN priority VARCHAR(10) NOT NULL DEFAULT ''",
+			"ALTER TABLE TASKS ADD COLUMN due_at DATETIME NULL",
+			"CREATE INDEX idx_tasks_due_at ON TASKS (due_at)",
+			`CREATE TABLE LABELS (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name VARCHAR(50) NOT NULL,
+				CONSTRAINT uq_labels_name UNIQUE (name)
+			)`,
+			`CREATE TABLE TASK_LABELS (
+				task_id INTEGER NOT NULL,
+				label_id INTEGER NOT NULL,
+				PRIMARY KEY (task_id, label_id),
+				CONSTRAINT fk_task_labels_task FOREIGN KEY (task_id) REFERENCES TASKS (id) ON DELETE CASCADE,
+				CONSTRAINT fk_task_labels_label FOREIGN KEY (label_id) REFERENCES LABELS (id) ON DELETE CASCADE
+			)`,
+			"CREATE INDEX idx_task_labels_label_id ON TASK_LABELS (label_id)",
+		},
+		Down: []string{
+			"DROP TABLE TASK_LABELS",
+			"DROP TABLE LABELS",
+			"DROP INDEX idx_tasks_due_at",
+			"ALTER TABLE TASKS DROP COLUMN due_at",
+			"ALTER TABLE TASKS DROP COLUMN priority",
+		},
+	},
+	{
+		// SQLite cannot drop a column that has a foreign key, so parent_id
+		// has none; the stores clear the parent of purged tasks themselves.
+		Version: 12,
+		Name:    "task_dependencies",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN parent_id INTEGER NULL",
+			"CREATE INDEX idx_tasks_parent_id ON TASKS (parent_id)",
+			`CREATE TABLE TASK_DEPENDENCIES (
+				task_id INTEGER NOT NULL,
+				blocker_id INTEGER NOT NULL,
+				PRIMARY KEY (task_id, blocker_id),
+				CONSTRAINT fk_task_dependencies_task FOREIGN KEY (task_id) REFERENCES TASKS (id) ON DELETE CASCADE,
+				CONSTRAINT fk_task_dependencies_blocker FOREIGN KEY (blocker_id) REFERENCES TASKS (id) ON DELETE CASCADE
+			)`,
+			"CREATE INDEX idx_task_dependencies_blocker_id ON TASK_DEPENDENCIES (blocker_id)",
+		},
+		Down: []string{
+			"DROP TABLE TASK_DEPENDENCIES",
+			"DROP INDEX idx_tasks_parent_id",
+			"ALTER TABLE TASKS DROP COLUMN parent_id",
+		},
+	},
+	{
+		// Entries from before the column have 0: only the name of whoever
+		// made them is known.
+		Version: 13,
+		Name:    "audit_actor_id",
+		Up: []string{
+			"ALTER TABLE AUDIT ADD COLUMN actor_id INTEGER NOT NULL DEFAULT 0",
+		},
+		Down: []string{
+			"ALTER TABLE AUDIT DROP COLUMN actor_id",
+		},
+	},
+	{
+		Version: 14,
+		Name:    "users_name_unique",
+		Up: []string{
+			"DROP INDEX idx_users_name",
+			"CREATE UNIQUE INDEX idx_users_name ON USERS (name)",
+		},
+		Down: []string{
+			"DROP INDEX idx_users_name",
+			"CREATE INDEX idx_users_name ON USERS (name)",
+		},
+	},
+}
+
+// PostgresMigrations is the schema on PostgreSQL. Like SQLiteMigrations it
+// starts at version 6, as no database predates it.
+var PostgresMigrations = []Migration{
+	{
+		Version: 6,
+		Name:    "create_tables",
+		Up: []string{
+			`CREATE TABLE USERS (
+				id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+				name VARCHAR(100) NOT NULL,
+				password_hash VARCHAR(255) NULL,
+				role VARCHAR(20) NOT NULL DEFAULT 'user'
+			)`,
+			`CREATE TABLE TASKS (
+				id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+				task TEXT NOT NULL,
+				completed BOOLEAN NOT NULL DEFAULT FALSE,
+				user_id INTEGER NOT NULL,
+				CONSTRAINT fk_tasks_user FOREIGN KEY (user_id) REFERENCES USERS (id)
+			)`,
+			"CREATE INDEX idx_tasks_user_id ON TASKS (user_id)",
+			"CREATE INDEX idx_tasks_completed ON TASKS (completed)",
+			"CREATE INDEX idx_users_name ON USERS (name)",
+		},
+		Down: []string{
+			"DROP TABLE TASKS",
+			"DROP TABLE USERS",
+		},
+	},
+	{
+		Version: 7,
+		Name:    "task_version",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN version INTEGER NOT NULL DEFAULT 1",
+		},
+		Down: []string{
+			"ALTER TABLE TASKS DROP COLUMN version",
+		},
+	},
+	{
+		Version: 8,
+		Name:    "soft_delete",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN deleted_at TIMESTAMPTZ NULL",
+			"ALTER TABLE USERS ADD COLUMN deleted_at TIMESTAMPTZ NULL",
+		},
+		Down: []string{
+			"DELETE FROM TASKS WHERE deleted_at IS NOT NULL OR user_id IN (SELECT id FROM USERS WHERE deleted_at IS NOT NULL)",
+			"DELETE FROM USERS WHERE deleted_at IS NOT NULL",
+			"ALTER TABLE USERS DROP COLUMN deleted_at",
+			"ALTER TABLE TASKS DROP COLUMN deleted_at",
+		},
+	},
+	{
+		Version: 9,
+		Name:    "audit",
+		Up: []string{
+			`CREATE TABLE AUDIT (
+				id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+				created_at TIMESTAMPTZ NOT NULL,
+				actor VARCHAR(100) NOT NULL,
+				action VARCHAR(20) NOT NULL,
+				entity VARCHAR(20) NOT NULL,
+				entity_id INTEGER NOT NULL,
+				changes TEXT NOT NULL,
+				request_id VARCHAR(128) NOT NULL
+			)`,
+			"CREATE INDEX idx_audit_entity ON AUDIT (entity, entity_id)",
+		},
+		Down: []string{
+			"DROP TABLE AUDIT",
+		},
+	},
+	{
+		Version: 10,
+		Name:    "task_timestamps",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN created_at TIMESTAMPTZ NULL, ADD COLUMN updated_at TIMESTAMPTZ NULL, ADD COLUMN completed_at TIMESTAMPTZ NULL",
+			"UPDATE TASKS SET created_at = now(), updated_at = now(), completed_at = CASE WHEN completed THEN now() END",
+			"ALTER TABLE TASKS ALTER COLUMN created_at SET NOT NULL, ALTER COLUMN updated_at SET NOT NULL",
+			"CREATE INDEX idx_tasks_created_at ON TASKS (created_at)",
+			"CREATE INDEX idx_tasks_completed_at ON TASKS (completed_at)",
+		},
+		Down: []string{
+			"DROP INDEX idx_tasks_completed_at",
+			"DROP INDEX idx_tasks_created_at",
+			"ALTER TABLE TASKS DROP COLUMN completed_at, DROP COLUMN updated_at, DROP COLUMN created_at",
+		},
+	},
+	{
+		Version: 11,
+		Name:    "task_planning",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN priority VARCHAR(10) NOT NULL DEFAULT '', ADD COLUMN due_at TIMESTAMPTZ NULL",
+			"CREATE INDEX idx_tasks_due_at ON TASKS (due_at)",
+			`CREATE TABLE LABELS (
+				id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+				name VARCHAR(50) NOT NULL,
+				CONSTRAINT uq_labels_name UNIQUE (name)
+			)`,
+			`CREATE TABLE TASK_LABELS (
+				task_id INTEGER NOT NULL,
+				label_id INTEGER NOT NULL,
+				PRIMARY KEY (task_id, label_id),
+				CONSTRAINT fk_task_labels_task FOREIGN KEY (task_id) REFERENCES TASKS (id) ON DELETE CASCADE,
+				CONSTRAINT fk_task_labels_label FOREIGN KEY (label_id) REFERENCES LABELS (id) ON DELETE CASCADE
+			)`,
+			"CREATE INDEX idx_task_labels_label_id ON TASK_LABELS (label_id)",
+		},
+		Down: []string{
+			"DROP TABLE TASK_LABELS",
+			"DROP TABLE LABELS",
+			"DROP INDEX idx_tasks_due_at",
+			"ALTER TABLE TASKS DROP COLUMN due_at, DROP COLUMN priority",
+		},
+	},
+	{
+		Version: 12,
+		Name:    "task_dependencies",
+		Up: []string{
+			"ALTER TABLE TASKS ADD COLUMN parent_id INTEGER NULL CONSTRAINT fk_tasks_parent REFERENCES TASKS (id) ON DELETE SET NULL",
+			"CREATE INDEX idx_tasks_parent_id ON TASKS (parent_id)",
+			`CREATE TABLE TASK_DEPENDENCIES (
+				task_id INTEGER NOT NULL,
+				blocker_id INTEGER NOT NULL,
+				PRIMARY KEY (task_id, blocker_id),
+				CONSTRAINT fk_task_dependencies_task FOREIGN KEY (task_id) REFERENCES TASKS (id) ON DELETE CASCADE,
+				CONSTRAINT fk_task_dependencies_blocker FOREIGN KEY (blocker_id) REFERENCES TASKS (id) ON DELETE CASCADE
+			)`,
+			"CREATE INDEX idx_task_dependencies_blocker_id ON TASK_DEPENDENCIES (blocker_id)",
+		},
+		Down: []string{
+			"DROP TABLE TASK_DEPENDENCIES",
+			"DROP INDEX idx_tasks_parent_id",
+			"ALTER TABLE TASKS DROP COLUMN parent_id",
+		},
+	},
+	{
+		// Entries from before the column have 0: only the name of whoever
+		// made them is known.
+		Version: 13,
+		Name:    "audit_actor_id",
+		Up: []string{
+			"ALTER TABLE AUDIT ADD COLUMN actor_id INTEGER NOT NULL DEFAULT 0",
+		},
+		Down: []string{
+			"ALTER TABLE AUDIT DROP COLUMN actor_id",
+		},
+	},
+	{
+		Version: 14,
+		Name:    "users_name_unique",
+		Up: []string{
+			"DROP INDEX idx_users_name",
+			"CREATE UNIQUE INDEX idx_users_name ON USERS (name)",
+		},
+		Down: []string{
+			"DROP INDEX idx_users_name",
+			"CREATE INDEX idx_users_name ON USERS (name)",
+		},
+	},
+}
@@ -0,0 +1,8 @@
+package models
+
+// Label tags tasks. A task can have any number of labels and a label any
+// number of tasks; label names are unique.
+type Label struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
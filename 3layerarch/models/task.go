@@ -1,8 +1,89 @@
 package models
 
+import "time"
+
 type Task struct {
 	ID        int    `json:"id"`
 	Task      string `json:"task"`
 	Completed bool   `json:"completed"`
 	UserID    int    `json:"user_id"`
+	// ParentID is the task this one is a subtask of; zero, and left out of
+	// the JSON, for a top-level task.
+	ParentID int `json:"parent_id,omitempty"`
+	// Priority is empty, and left out of the JSON, for a task without one.
+	Priority Priority `json:"priority,omitempty"`
+	// DueAt is when the task should be completed by; zero, and left out of
+	// the JSON, for a task without a due date.
+	DueAt time.Time `json:"due_at,omitzero"`
+	// Version counts the writes to the task, starting at 1. It is set by
+	// the store; a version sent by a client is ignored.
+	Version int `json:"version"`
+	// CreatedAt and UpdatedAt are set by the store: when the task was
+	// created, and when it last changed, which is whenever Version moves.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// CompletedAt is when the task was last marked completed; zero, and
+	// left out of the JSON, while it is open. It is set by the store too.
+	CompletedAt time.Time `json:"completed_at,omitzero"`
+	// DeletedAt is when the task was moved to the trash; zero, and left
+	// out of the JSON, for a task that is not in it.
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+}
+
+// Priority is how urgent a task is.
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+	PriorityUrgent Priority = "urgent"
+)
+
+// Priorities are the priorities a task can have, least urgent first.
+var Priorities = []Priority{PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent}
+
+// TaskPatch is a JSON merge-patch for a task. Nil fields were absent from
+// the patch and are left unchanged; a zero ParentID, Priority or DueAt
+// removes it.
+type TaskPatch struct {
+	Task      *string    `json:"task,omitempty"`
+	Completed *bool      `json:"completed,omitempty"`
+	UserID    *int       `json:"user_id,omitempty"`
+	ParentID  *int       `json:"parent_id,omitempty"`
+	Priority  *Priority  `json:"priority,omitempty"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+}
+
+// TaskFilter narrows, orders and pages a task listing. Nil fields, and zero
+// values, do not filter. The After and Before times are exclusive; a
+// completion time only matches completed tasks. Overdue tasks are open
+// tasks past their due date. Sort names a task field, prefixed with "-"
+// for descending order; tasks without a due date come last by due_at
+// either way. Deleted lists the tasks in the trash instead of the others.
+type TaskFilter struct {
+	Deleted         bool
+	Completed       *bool
+	UserID          *int
+	LabelID         *int
+	Priority        Priority
+	Overdue         *bool
+	Search          string
+	CreatedAfter    time.Time
+	CreatedBefore   time.Time
+	CompletedAfter  time.Time
+	CompletedBefore time.Time
+	Sort            string
+	Limit           int
+	Offset          int
+}
+
+// TaskPage is one page of a task listing along with the total number of
+// tasks that matched the filter.
+type TaskPage struct {
+	Tasks  []Task `json:"tasks"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Next   string `json:"next,omitempty"`
 }
@@ -0,0 +1,80 @@
+package auditservice_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"3layerarch/models"
+	"3layerarch/service/audit"
+)
+
+// MockAuditStore implements AuditStore interface with function fields
+type MockAuditStore struct {
+	AppendAuditFn func(ctx context.Context, e models.AuditEntry) error
+	ViewAuditFn   func(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, int, error)
+}
+
+func (m *MockAuditStore) AppendAudit(ctx context.Context, e models.AuditEntry) error {
+	return m.AppendAuditFn(ctx, e)
+}
+
+func (m *MockAuditStore) ViewAudit(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, int, error) {
+	return m.ViewAuditFn(ctx, f)
+}
+
+var adminCtx = models.WithPrincipal(context.Background(), models.Principal{Name: "ops", Role: models.RoleAdmin})
+
+func TestViewAudit(t *testing.T) {
+	var got models.AuditFilter
+	mockStore := &MockAuditStore{
+		ViewAuditFn: func(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, int, error) {
+			got = f
+			return []models.AuditEntry{{ID: 1, Action: models.AuditCreate, Entity: models.AuditTask, EntityID: 7}}, 3, nil
+		},
+	}
+	svc := auditservice.New(mockStore)
+
+	page, err := svc.ViewAudit(adminCtx, models.AuditFilter{Entity: models.AuditTask, EntityID: 7})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Limit != 20 || got.Entity != models.AuditTask || got.EntityID != 7 {
+		t.Errorf("expected the default page size and the filter to reach the store, got %+v", got)
+	}
+	if len(page.Entries) != 1 || page.Total != 3 || page.Limit != 20 || page.Offset != 0 {
+		t.Errorf("unexpected page: %+v", page)
+	}
+}
+
+func TestViewAudit_Errors(t *testing.T) {
+	errDB := errors.New("db down")
+	mockStore := &MockAuditStore{
+		ViewAuditFn: func(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, int, error) {
+			return nil, 0, errDB
+		},
+	}
+	svc := auditservice.New(mockStore)
+	userCtx := models.WithPrincipal(context.Background(), models.Principal{UserID: 1, Role: models.RoleUser})
+
+	tests := []struct {
+		desc string
+		ctx  context.Context
+		f    models.AuditFilter
+		want error
+	}{
+		{"no principal", context.Background(), models.AuditFilter{}, models.ErrUnauthorized},
+		{"not an admin", userCtx, models.AuditFilter{}, models.ErrForbidden},
+		{"unknown entity", adminCtx, models.AuditFilter{Entity: "project"}, models.ErrValidation},
+		{"negative ID", adminCtx, models.AuditFilter{Entity: models.AuditTask, EntityID: -1}, models.ErrValidation},
+		{"ID without entity", adminCtx, models.AuditFilter{EntityID: 7}, models.ErrValidation},
+		{"limit too large", adminCtx, models.AuditFilter{Limit: 101}, models.ErrValidation},
+		{"negative offset", adminCtx, models.AuditFilter{Offset: -1}, models.ErrValidation},
+		{"store fails", adminCtx, models.AuditFilter{}, errDB},
+	}
+	for _, tc := range tests {
+		if _, err := svc.ViewAudit(tc.ctx, tc.f); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.desc, tc.want, err)
+		}
+	}
+}
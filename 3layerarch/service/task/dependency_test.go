@@ -0,0 +1,307 @@
+package taskservice_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"3layerarch/models"
+	"3layerarch/service/task"
+)
+
+// treeStore returns a store holding tasks, keyed by ID, and the blocking
+// dependencies between them as [task, blocker] pairs. It keeps both up to
+// date as the service changes them.
+func treeStore(tasks map[int]models.Task, deps map[[2]int]bool) *MockTaskStore {
+	get := func(ctx context.Context, id int) (models.Task, error) {
+		t, ok := tasks[id]
+		if !ok {
+			return models.Task{}, sql.ErrNoRows
+		}
+		return t, nil
+	}
+	collect := func(match func(t models.Task) bool) []models.Task {
+		found := []models.Task{}
+		for _, t := range tasks {
+			if match(t) {
+				found = append(found, t)
+			}
+		}
+		slices.SortFunc(found, func(a, b models.Task) int { return a.ID - b.ID })
+		return found
+	}
+	return &MockTaskStore{
+		GetTaskFn:          get,
+		GetTaskForUpdateFn: get,
+		UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			t.Version++
+			tasks[t.ID] = t
+			return t, nil
+		},
+		GetParentIDFn: func(ctx context.Context, id int) (int, error) {
+			t, err := get(ctx, id)
+			return t.ParentID, err
+		},
+		SubtasksFn: func(ctx context.Context, parentID int) ([]models.Task, error) {
+			return collect(func(t models.Task) bool { return t.ParentID == parentID }), nil
+		},
+		BlockersFn: func(ctx context.Context, taskID int) ([]models.Task, error) {
+			return collect(func(t models.Task) bool { return deps[[2]int{taskID, t.ID}] }), nil
+		},
+		BlockerIDsFn: func(ctx context.Context, taskID int) ([]int, error) {
+			ids := []int{}
+			for d := range deps {
+				if d[0] == taskID {
+					ids = append(ids, d[1])
+				}
+			}
+			return ids, nil
+		},
+		AddDependencyFn: func(ctx context.Context, taskID, blockerID int) error {
+			deps[[2]int{taskID, blockerID}] = true
+			return nil
+		},
+		RemoveDependencyFn: func(ctx context.Context, taskID, blockerID int) error {
+			delete(deps, [2]int{taskID, blockerID})
+			return nil
+		},
+	}
+}
+
+func TestAddDependency(t *testing.T) {
+	tasks := map[int]models.Task{
+		1: {ID: 1, Task: "design", UserID: 1},
+		2: {ID: 2, Task: "build", UserID: 1},
+		3: {ID: 3, Task: "ship", UserID: 1},
+		4: {ID: 4, Task: "theirs", UserID: 2},
+	}
+	deps := map[[2]int]bool{}
+	tx := &MockTx{}
+	svc := taskservice.New(treeStore(tasks, deps), nil)
+	svc.Tx = tx
+
+	// 3 waits for 2, which waits for 1
+	if _, err := svc.AddDependency(userCtx(1), 2, 1); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	got, err := svc.AddDependency(userCtx(1), 3, 2)
+	if err != nil || len(got) != 1 || got[0].ID != 2 {
+		t.Fatalf("expected task 2 blocking task 3, got %v, %v", got, err)
+	}
+	if tx.Runs != 2 {
+		t.Errorf("expected each change in a unit of work, got %d runs", tx.Runs)
+	}
+
+	tests := []struct {
+		desc    string
+		ctx     context.Context
+		task    int
+		blocker int
+		wantErr error
+	}{
+		{"Direct cycle", userCtx(1), 1, 2, models.ErrConflict},
+		{"Indirect cycle", userCtx(1), 1, 3, models.ErrConflict},
+		{"Itself", userCtx(1), 2, 2, models.ErrConflict},
+		{"Other user's blocker", userCtx(1), 3, 4, models.ErrNotFound},
+		{"Unknown task", adminCtx, 9, 1, models.ErrNotFound},
+		{"Invalid task", adminCtx, 0, 1, models.ErrValidation},
+	}
+	for _, tc := range tests {
+		if _, err := svc.AddDependency(tc.ctx, tc.task, tc.blocker); !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: expected %v, got %v", tc.desc, tc.wantErr, err)
+		}
+	}
+	if len(deps) != 2 {
+		t.Errorf("expected the refused dependencies left out, got %v", deps)
+	}
+
+	// Once removed, the edge no longer closes a cycle
+	if got, err := svc.RemoveDependency(userCtx(1), 3, 2); err != nil || len(got) != 0 {
+		t.Errorf("expected no blockers left, got %v, %v", got, err)
+	}
+	if _, err := svc.AddDependency(userCtx(1), 1, 3); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+}
+
+func TestCompleteTask_Blocked(t *testing.T) {
+	tasks := map[int]models.Task{
+		1: {ID: 1, Task: "design", UserID: 1},
+		2: {ID: 2, Task: "build", UserID: 1},
+		3: {ID: 3, Task: "test", UserID: 1},
+	}
+	deps := map[[2]int]bool{{2, 1}: true, {3, 1}: true}
+	svc := taskservice.New(treeStore(tasks, deps), nil)
+	done := true
+
+	if _, err := svc.PatchTask(userCtx(1), 2, models.TaskPatch{Completed: &done}, 0); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("Patch: expected conflict, got %v", err)
+	}
+	if _, err := svc.UpdateTask(userCtx(1), 2, models.Task{Task: "build", UserID: 1, Completed: true}, 0); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("Update: expected conflict, got %v", err)
+	}
+	if _, err := svc.CompleteTask(userCtx(1), 2, false, 0); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("Complete: expected conflict, got %v", err)
+	}
+	if tasks[2].Completed {
+		t.Fatalf("expected the blocked task left open")
+	}
+
+	// Forced, or once the blocker is done
+	if got, err := svc.CompleteTask(userCtx(1), 2, true, 0); err != nil || !got.Completed {
+		t.Errorf("Forced: expected the task completed, got %v, %v", got, err)
+	}
+	if _, err := svc.CompleteTask(userCtx(1), 1, false, 0); err != nil {
+		t.Errorf("Blocker: expected nil error, got %v", err)
+	}
+	if got, err := svc.PatchTask(userCtx(1), 3, models.TaskPatch{Completed: &done}, 0); err != nil || !got.Completed {
+		t.Errorf("Unblocked: expected the task completed, got %v, %v", got, err)
+	}
+}
+
+func TestCompleteTask_Parents(t *testing.T) {
+	tasks := map[int]models.Task{
+		1: {ID: 1, Task: "release", UserID: 1},
+		2: {ID: 2, Task: "build", UserID: 1, ParentID: 1},
+		3: {ID: 3, Task: "compile", UserID: 1, ParentID: 2},
+		4: {ID: 4, Task: "link", UserID: 1, ParentID: 2},
+		5: {ID: 5, Task: "announce", UserID: 1},
+	}
+	deps := map[[2]int]bool{}
+	metrics := &MockMetrics{}
+	svc := taskservice.New(treeStore(tasks, deps), nil)
+	svc.Metrics = metrics
+
+	// Another subtask of 2 is still open
+	if _, err := svc.CompleteTask(userCtx(1), 3, false, 0); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if tasks[2].Completed {
+		t.Errorf("expected the parent open while a subtask is")
+	}
+
+	// The last subtask completes 2, and so 1
+	if _, err := svc.CompleteTask(userCtx(1), 4, false, 0); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !tasks[2].Completed || !tasks[1].Completed {
+		t.Errorf("expected both parents completed, got %+v and %+v", tasks[2], tasks[1])
+	}
+	if metrics.Completed != 4 {
+		t.Errorf("expected 4 completions counted, got %d", metrics.Completed)
+	}
+
+	// A parent with an open blocker stays open
+	tasks[6] = models.Task{ID: 6, Task: "party", UserID: 1, ParentID: 5}
+	deps[[2]int{5, 2}] = true
+	tasks[2] = models.Task{ID: 2, Task: "build", UserID: 1, ParentID: 1}
+	if _, err := svc.CompleteTask(userCtx(1), 6, false, 0); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if tasks[5].Completed {
+		t.Errorf("expected the blocked parent left open")
+	}
+}
+
+func TestPatchTask_Parent(t *testing.T) {
+	tasks := map[int]models.Task{
+		1: {ID: 1, Task: "release", UserID: 1},
+		2: {ID: 2, Task: "build", UserID: 1, ParentID: 1},
+		3: {ID: 3, Task: "compile", UserID: 1, ParentID: 2},
+		4: {ID: 4, Task: "theirs", UserID: 2},
+	}
+	svc := taskservice.New(treeStore(tasks, map[[2]int]bool{}), nil)
+
+	tests := []struct {
+		desc    string
+		task    int
+		parent  int
+		wantErr error
+	}{
+		{"Itself", 1, 1, models.ErrConflict},
+		{"Own subtask", 1, 3, models.ErrConflict},
+		{"Other user's task", 1, 4, models.ErrValidation},
+		{"Unknown parent", 1, 9, models.ErrValidation},
+		{"Invalid parent", 1, -1, models.ErrValidation},
+	}
+	for _, tc := range tests {
+		if _, err := svc.PatchTask(userCtx(1), tc.task, models.TaskPatch{ParentID: &tc.parent}, 0); !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: expected %v, got %v", tc.desc, tc.wantErr, err)
+		}
+	}
+
+	// Moving a subtask elsewhere in the tree, and out of it
+	moved, top := 1, 0
+	if got, err := svc.PatchTask(userCtx(1), 3, models.TaskPatch{ParentID: &moved}, 0); err != nil || got.ParentID != 1 {
+		t.Errorf("expected the task moved under 1, got %v, %v", got, err)
+	}
+	if got, err := svc.PatchTask(userCtx(1), 3, models.TaskPatch{ParentID: &top}, 0); err != nil || got.ParentID != 0 {
+		t.Errorf("expected a top-level task, got %v, %v", got, err)
+	}
+}
+
+func TestTaskTree(t *testing.T) {
+	tasks := map[int]models.Task{
+		1: {ID: 1, Task: "release", UserID: 1},
+		2: {ID: 2, Task: "build", UserID: 1, ParentID: 1},
+		3: {ID: 3, Task: "compile", UserID: 1, ParentID: 2},
+		4: {ID: 4, Task: "docs", UserID: 1, ParentID: 1},
+		5: {ID: 5, Task: "theirs", UserID: 2, ParentID: 1},
+	}
+	deps := map[[2]int]bool{{4, 2}: true, {4, 5}: true}
+	svc := taskservice.New(treeStore(tasks, deps), nil)
+
+	got, err := svc.TaskTree(userCtx(1), 1)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	want := models.TaskTree{Task: tasks[1], BlockedBy: []int{}, Subtasks: []models.TaskTree{
+		{Task: tasks[2], BlockedBy: []int{}, Subtasks: []models.TaskTree{
+			{Task: tasks[3], BlockedBy: []int{}, Subtasks: []models.TaskTree{}},
+		}},
+		{Task: tasks[4], BlockedBy: []int{2}, Subtasks: []models.TaskTree{}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if _, err := svc.TaskTree(userCtx(2), 1); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected another user's tree not found, got %v", err)
+	}
+}
+
+func TestTaskGraph(t *testing.T) {
+	var asked *int
+	svc := taskservice.New(&MockTaskStore{
+		TaskGraphFn: func(ctx context.Context, userID *int) (models.TaskGraph, error) {
+			asked = userID
+			return models.TaskGraph{}, nil
+		},
+	}, nil)
+
+	// Users get their own graph whatever they ask for
+	if _, err := svc.TaskGraph(userCtx(3), nil); err != nil || asked == nil || *asked != 3 {
+		t.Errorf("expected the graph of user 3, got %v, err: %v", asked, err)
+	}
+	other := 4
+	asked = nil
+	got, err := svc.TaskGraph(userCtx(3), &other)
+	if err != nil || asked != nil || len(got.Tasks) != 0 {
+		t.Errorf("expected an empty graph without a lookup, got %+v, err: %v", got, err)
+	}
+
+	// Admins get everyone's, or one user's
+	if _, err := svc.TaskGraph(adminCtx, nil); err != nil || asked != nil {
+		t.Errorf("expected every user's graph, got %v, err: %v", asked, err)
+	}
+	if _, err := svc.TaskGraph(adminCtx, &other); err != nil || asked == nil || *asked != 4 {
+		t.Errorf("expected the graph of user 4, got %v, err: %v", asked, err)
+	}
+	bad := 0
+	if _, err := svc.TaskGraph(adminCtx, &bad); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected invalid user ID, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package taskservice_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"3layerarch/models"
+	"3layerarch/service/task"
+)
+
+// labelStore returns a store holding the labels "work" and "home", with IDs
+// 1 and 2, and a task with ID 1 owned by user 1, which records the labels
+// put on the task.
+func labelStore() *MockTaskStore {
+	onTask := map[int]bool{}
+	return &MockTaskStore{
+		GetTaskFn: func(ctx context.Context, id int) (models.Task, error) {
+			if id != 1 {
+				return models.Task{}, sql.ErrNoRows
+			}
+			return models.Task{ID: 1, Task: "write report", UserID: 1}, nil
+		},
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			if id != 1 {
+				return models.Task{}, sql.ErrNoRows
+			}
+			return models.Task{ID: 1, Task: "write report", UserID: 1}, nil
+		},
+		GetLabelFn: func(ctx context.Context, id int) (models.Label, error) {
+			switch id {
+			case 1:
+				return models.Label{ID: 1, Name: "work"}, nil
+			case 2:
+				return models.Label{ID: 2, Name: "home"}, nil
+			}
+			return models.Label{}, sql.ErrNoRows
+		},
+		GetLabelByNameFn: func(ctx context.Context, name string) (models.Label, error) {
+			if name != "work" {
+				return models.Label{}, sql.ErrNoRows
+			}
+			return models.Label{ID: 1, Name: "work"}, nil
+		},
+		CreateLabelFn: func(ctx context.Context, l models.Label) (models.Label, error) {
+			l.ID = 3
+			return l, nil
+		},
+		UpdateLabelFn: func(ctx context.Context, l models.Label) error { return nil },
+		DeleteLabelFn: func(ctx context.Context, id int) error { return nil },
+		TaskLabelsFn: func(ctx context.Context, taskID int) ([]models.Label, error) {
+			labels := []models.Label{}
+			if onTask[1] {
+				labels = append(labels, models.Label{ID: 1, Name: "work"})
+			}
+			return labels, nil
+		},
+		AddTaskLabelFn: func(ctx context.Context, taskID, labelID int) error {
+			onTask[labelID] = true
+			return nil
+		},
+		RemoveTaskLabelFn: func(ctx context.Context, taskID, labelID int) error {
+			delete(onTask, labelID)
+			return nil
+		},
+	}
+}
+
+func TestCreateLabel(t *testing.T) {
+	svc := taskservice.New(labelStore(), nil)
+
+	got, err := svc.CreateLabel(userCtx(1), models.Label{Name: "  errands "})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if want := (models.Label{ID: 3, Name: "errands"}); got != want {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	tests := []struct {
+		desc    string
+		name    string
+		wantErr error
+	}{
+		{"Empty name", " ", models.ErrValidation},
+		{"Long name", strings.Repeat("x", 51), models.ErrValidation},
+		{"Taken name", "work", models.ErrConflict},
+	}
+	for _, tc := range tests {
+		if _, err := svc.CreateLabel(userCtx(1), models.Label{Name: tc.name}); !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: expected %v, got %v", tc.desc, tc.wantErr, err)
+		}
+	}
+}
+
+func TestUpdateLabel(t *testing.T) {
+	svc := taskservice.New(labelStore(), nil)
+
+	// Keeping its own name is no conflict
+	got, err := svc.UpdateLabel(adminCtx, 1, models.Label{Name: "work"})
+	if err != nil || got != (models.Label{ID: 1, Name: "work"}) {
+		t.Errorf("expected the label unchanged, got %v, %v", got, err)
+	}
+
+	tests := []struct {
+		desc    string
+		ctx     context.Context
+		id      int
+		name    string
+		wantErr error
+	}{
+		{"Not an admin", userCtx(1), 1, "chores", models.ErrForbidden},
+		{"Invalid ID", adminCtx, 0, "chores", models.ErrValidation},
+		{"Not found", adminCtx, 9, "chores", models.ErrNotFound},
+		{"Taken name", adminCtx, 2, "work", models.ErrConflict},
+		{"Empty name", adminCtx, 1, "", models.ErrValidation},
+	}
+	for _, tc := range tests {
+		if _, err := svc.UpdateLabel(tc.ctx, tc.id, models.Label{Name: tc.name}); !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: expected %v, got %v", tc.desc, tc.wantErr, err)
+		}
+	}
+}
+
+func TestDeleteLabel(t *testing.T) {
+	svc := taskservice.New(labelStore(), nil)
+
+	if err := svc.DeleteLabel(adminCtx, 1); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+	if err := svc.DeleteLabel(userCtx(1), 1); !errors.Is(err, models.ErrForbidden) {
+		t.Errorf("expected forbidden, got %v", err)
+	}
+	if err := svc.DeleteLabel(adminCtx, 9); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestTaskLabels(t *testing.T) {
+	tx := &MockTx{}
+	svc := taskservice.New(labelStore(), nil)
+	svc.Tx = tx
+	work := []models.Label{{ID: 1, Name: "work"}}
+
+	got, err := svc.AddTaskLabel(userCtx(1), 1, 1)
+	if err != nil || !reflect.DeepEqual(got, work) {
+		t.Errorf("expected %v after adding, got %v, %v", work, got, err)
+	}
+	got, err = svc.ViewTaskLabels(userCtx(1), 1)
+	if err != nil || !reflect.DeepEqual(got, work) {
+		t.Errorf("expected %v, got %v, %v", work, got, err)
+	}
+	got, err = svc.RemoveTaskLabel(userCtx(1), 1, 1)
+	if err != nil || len(got) != 0 {
+		t.Errorf("expected no labels after removing, got %v, %v", got, err)
+	}
+	if tx.Runs != 2 {
+		t.Errorf("expected each change in a unit of work, got %d runs", tx.Runs)
+	}
+
+	tests := []struct {
+		desc    string
+		ctx     context.Context
+		task    int
+		label   int
+		wantErr string
+	}{
+		{"Other user's task", userCtx(2), 1, 1, "task not found"},
+		{"Unknown task", adminCtx, 9, 1, "task not found"},
+		{"Unknown label", adminCtx, 1, 9, "label not found"},
+		{"Invalid label", adminCtx, 1, 0, "invalid label ID"},
+	}
+	for _, tc := range tests {
+		if _, err := svc.AddTaskLabel(tc.ctx, tc.task, tc.label); err == nil || err.Error() != tc.wantErr {
+			t.Errorf("%s: expected %q error, got %v", tc.desc, tc.wantErr, err)
+		}
+	}
+	if _, err := svc.ViewTaskLabels(userCtx(2), 1); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected another user's task not found, got %v", err)
+	}
+}
@@ -1,90 +1,554 @@
 package taskservice
 
 import (
+	"context"
 	//"3layerarch/handler/userhandler"
 	"3layerarch/models"
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 )
 
 type TaskStore interface {
-	CreateTask(t models.Task) error
-	GetTask(id int) (models.Task, error)
-	ViewTasks() ([]models.Task, error)
-	UpdateTask(id int) error
-	DeleteTask(id int) error
+	CreateTask(ctx context.Context, t models.Task) (models.Task, error)
+	GetTask(ctx context.Context, id int) (models.Task, error)
+	GetTaskForUpdate(ctx context.Context, id int) (models.Task, error)
+	ViewTasks(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error)
+	UpdateTask(ctx context.Context, t models.Task) (models.Task, error)
+	DeleteTask(ctx context.Context, id int) error
+	GetDeletedTaskForUpdate(ctx context.Context, id int) (models.Task, error)
+	RestoreTask(ctx context.Context, id int) (models.Task, error)
+	PurgeTasks(ctx context.Context, before time.Time) (int, error)
+
+	CreateLabel(ctx context.Context, l models.Label) (models.Label, error)
+	GetLabel(ctx context.Context, id int) (models.Label, error)
+	GetLabelByName(ctx context.Context, name string) (models.Label, error)
+	ViewLabels(ctx context.Context) ([]models.Label, error)
+	UpdateLabel(ctx context.Context, l models.Label) error
+	DeleteLabel(ctx context.Context, id int) error
+	TaskLabels(ctx context.Context, taskID int) ([]models.Label, error)
+	AddTaskLabel(ctx context.Context, taskID, labelID int) error
+	RemoveTaskLabel(ctx context.Context, taskID, labelID int) error
+
+	GetParentID(ctx context.Context, id int) (int, error)
+	Subtasks(ctx context.Context, parentID int) ([]models.Task, error)
+	Blockers(ctx context.Context, taskID int) ([]models.Task, error)
+	BlockerIDs(ctx context.Context, taskID int) ([]int, error)
+	AddDependency(ctx context.Context, taskID, blockerID int) error
+	RemoveDependency(ctx context.Context, taskID, blockerID int) error
+	TaskGraph(ctx context.Context, userID *int) (models.TaskGraph, error)
 }
 
 type UserService interface {
-	GetUser(id int) (models.User, error)
+	GetUser(ctx context.Context, id int) (models.User, error)
+	LockUser(ctx context.Context, id int) (models.User, error)
+}
+
+// Transactor runs fn as one unit of work: store calls made with the ctx it
+// passes to fn share a transaction. fn may be run again after a deadlock.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Auditor records changes in the audit log. Called in a unit of work, the
+// entry is written in its transaction.
+type Auditor interface {
+	AppendAudit(ctx context.Context, e models.AuditEntry) error
+}
+
+// Metrics counts what happens to tasks.
+type Metrics interface {
+	TaskCreated()
+	TaskCompleted()
+	TaskDeleted()
 }
 
 type Service struct {
 	TaskStore   TaskStore
 	UserService UserService
+	// Tx makes the check and the write of a change atomic. Without one each
+	// step runs on its own.
+	Tx Transactor
+	// Metrics, if set, counts the changes made.
+	Metrics Metrics
+	// Audit, if set, records every change along with who made it.
+	Audit Auditor
 }
 
 func New(ts TaskStore, us UserService) *Service {
 	return &Service{TaskStore: ts, UserService: us}
 }
 
-func (s *Service) CreateTask(t models.Task) error {
-	fmt.Println("CreateTask received:", t)
-
+// CreateTask validates t and returns the stored task with its new ID. Only
+// admins create tasks for other users. A completed subtask may complete its
+// parent.
+func (s *Service) CreateTask(ctx context.Context, t models.Task) (models.Task, error) {
+	p, err := caller(ctx)
+	if err != nil {
+		return models.Task{}, err
+	}
 	if t.Task == "" {
-		return errors.New("task cannot be empty")
+		return models.Task{}, models.Validation("task cannot be empty")
+	}
+	if err := checkPriority(t.Priority); err != nil {
+		return models.Task{}, err
+	}
+	t.DueAt = normalizeTime(t.DueAt)
+	if !p.CanAccess(t.UserID) {
+		return models.Task{}, models.Forbidden("cannot create tasks for another user")
 	}
-	// Validate user existence before creating task
-	_, err := s.UserService.GetUser(t.UserID)
+	// The user must exist, and keep existing, until the task is stored
+	var created models.Task
+	var completed int
+	err = s.inTx(ctx, func(ctx context.Context) error {
+		if err := s.checkUser(ctx, t.UserID); err != nil {
+			return err
+		}
+		if err := s.checkParent(ctx, t); err != nil {
+			return err
+		}
+		var err error
+		created, err = s.TaskStore.CreateTask(ctx, t)
+		if err != nil {
+			return err
+		}
+		if err := s.audit(ctx, models.AuditCreate, created.ID, nil, created); err != nil {
+			return err
+		}
+		if created.Completed {
+			completed, err = s.completeParents(ctx, created)
+		}
+		return err
+	})
 	if err != nil {
-		return errors.New("user ID not found")
+		return models.Task{}, err
 	}
-	return s.TaskStore.CreateTask(t)
+	if s.Metrics != nil {
+		s.Metrics.TaskCreated()
+	}
+	s.countCompleted(completed)
+	return created, nil
 }
 
-func (s *Service) GetTask(id int) (models.Task, error) {
+func (s *Service) GetTask(ctx context.Context, id int) (models.Task, error) {
+	return s.getTask(ctx, id, false)
+}
+
+// getTask looks up the task with id and, if lock is set, locks it until the
+// unit of work in ctx ends. Tasks of other users are not found unless the
+// caller is an admin, so that their IDs give nothing away.
+func (s *Service) getTask(ctx context.Context, id int, lock bool) (models.Task, error) {
+	p, err := caller(ctx)
+	if err != nil {
+		return models.Task{}, err
+	}
 	if id <= 0 {
-		return models.Task{}, errors.New("invalid task ID")
+		return models.Task{}, models.Validation("invalid task ID")
 	}
-	task, err := s.TaskStore.GetTask(id)
+	get := s.TaskStore.GetTask
+	if lock {
+		get = s.TaskStore.GetTaskForUpdate
+	}
+	task, err := get(ctx, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.Task{}, errors.New("task not found")
+			return models.Task{}, models.NotFound("task not found")
 		}
 		return models.Task{}, err
 	}
+	if !p.CanAccess(task.UserID) {
+		return models.Task{}, models.NotFound("task not found")
+	}
 	return task, nil
 }
 
-func (s *Service) ViewTasks() ([]models.Task, error) {
-	return s.TaskStore.ViewTasks()
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ViewTasks returns the page of tasks selected by f, which lists the trash
+// if f.Deleted is set. A zero limit means the default page size. Users
+// other than admins only ever see their own tasks.
+func (s *Service) ViewTasks(ctx context.Context, f models.TaskFilter) (models.TaskPage, error) {
+	p, err := caller(ctx)
+	if err != nil {
+		return models.TaskPage{}, err
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultPageSize
+	}
+	if f.Limit < 0 || f.Limit > maxPageSize {
+		return models.TaskPage{}, models.Validation(fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+	}
+	if f.Offset < 0 {
+		return models.TaskPage{}, models.Validation("offset cannot be negative")
+	}
+	switch strings.TrimPrefix(f.Sort, "-") {
+	case "", "id", "task", "completed", "user_id", "due_at":
+	default:
+		return models.TaskPage{}, models.Validation(fmt.Sprintf("cannot sort by %q", f.Sort))
+	}
+	if f.UserID != nil && *f.UserID <= 0 {
+		return models.TaskPage{}, models.Validation("invalid user ID")
+	}
+	if f.LabelID != nil && *f.LabelID <= 0 {
+		return models.TaskPage{}, models.Validation("invalid label ID")
+	}
+	if err := checkPriority(f.Priority); err != nil {
+		return models.TaskPage{}, err
+	}
+	if err := checkRange("created", f.CreatedAfter, f.CreatedBefore); err != nil {
+		return models.TaskPage{}, err
+	}
+	if err := checkRange("completed", f.CompletedAfter, f.CompletedBefore); err != nil {
+		return models.TaskPage{}, err
+	}
+	if p.Role != models.RoleAdmin {
+		if f.UserID != nil && *f.UserID != p.UserID {
+			return models.TaskPage{Tasks: []models.Task{}, Limit: f.Limit, Offset: f.Offset}, nil
+		}
+		f.UserID = &p.UserID
+	}
+
+	tasks, total, err := s.TaskStore.ViewTasks(ctx, f)
+	if err != nil {
+		return models.TaskPage{}, err
+	}
+	return models.TaskPage{Tasks: tasks, Total: total, Limit: f.Limit, Offset: f.Offset}, nil
+}
+
+// checkRange refuses time bounds on field that no time can be between.
+func checkRange(field string, after, before time.Time) error {
+	if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+		return models.Validation(fmt.Sprintf("%s_after must be before %s_before", field, field))
+	}
+	return nil
 }
 
-func (s *Service) UpdateTask(id int) error {
-	if id <= 0 {
-		return errors.New("invalid task ID")
+// ViewUserTasks returns a page of the tasks of the user with userID, selected
+// by the other filters of f. An unknown user is not found rather than an
+// empty page.
+func (s *Service) ViewUserTasks(ctx context.Context, userID int, f models.TaskFilter) (models.TaskPage, error) {
+	if _, err := s.UserService.GetUser(ctx, userID); err != nil {
+		return models.TaskPage{}, err
 	}
-	_, err := s.TaskStore.GetTask(id)
+	f.UserID = &userID
+	return s.ViewTasks(ctx, f)
+}
+
+// UpdateTask replaces every field of the task with id and returns the result.
+// A version other than 0 must be the task's current one. A task with open
+// blockers cannot be completed this way.
+func (s *Service) UpdateTask(ctx context.Context, id int, t models.Task, version int) (models.Task, error) {
+	var updated models.Task
+	var completed int
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		existing, err := s.getTask(ctx, id, true)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(existing, version); err != nil {
+			return err
+		}
+		t.ID, t.Version = id, existing.Version
+		t.CreatedAt, t.CompletedAt = existing.CreatedAt, existing.CompletedAt
+		if err := s.validateUpdate(ctx, existing, &t, false); err != nil {
+			return err
+		}
+		updated, completed, err = s.update(ctx, existing, t)
+		return err
+	})
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return errors.New("task not found")
+		return models.Task{}, err
+	}
+	s.countCompleted(completed)
+	return updated, nil
+}
+
+// PatchTask applies a merge-patch to the task with id and returns the result.
+// A version other than 0 must be the task's current one. A task with open
+// blockers cannot be completed this way.
+func (s *Service) PatchTask(ctx context.Context, id int, p models.TaskPatch, version int) (models.Task, error) {
+	return s.patchTask(ctx, id, p, version, false)
+}
+
+// CompleteTask marks the task with id completed and returns it. A task with
+// open blockers is only completed if force is set. A version other than 0
+// must be the task's current one.
+func (s *Service) CompleteTask(ctx context.Context, id int, force bool, version int) (models.Task, error) {
+	done := true
+	return s.patchTask(ctx, id, models.TaskPatch{Completed: &done}, version, force)
+}
+
+// patchTask applies p to the task with id, completing it even with open
+// blockers if force is set.
+func (s *Service) patchTask(ctx context.Context, id int, p models.TaskPatch, version int, force bool) (models.Task, error) {
+	var updated models.Task
+	var completed int
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		existing, err := s.getTask(ctx, id, true)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(existing, version); err != nil {
+			return err
+		}
+		t := existing
+		if p.Task != nil {
+			t.Task = *p.Task
+		}
+		if p.Completed != nil {
+			t.Completed = *p.Completed
+		}
+		if p.UserID != nil {
+			t.UserID = *p.UserID
+		}
+		if p.ParentID != nil {
+			t.ParentID = *p.ParentID
+		}
+		if p.Priority != nil {
+			t.Priority = *p.Priority
+		}
+		if p.DueAt != nil {
+			t.DueAt = *p.DueAt
 		}
+		if err := s.validateUpdate(ctx, existing, &t, force); err != nil {
+			return err
+		}
+		updated, completed, err = s.update(ctx, existing, t)
 		return err
+	})
+	if err != nil {
+		return models.Task{}, err
 	}
-	return s.TaskStore.UpdateTask(id)
+	s.countCompleted(completed)
+	return updated, nil
 }
 
-func (s *Service) DeleteTask(id int) error {
-	if id <= 0 {
-		return errors.New("invalid task ID")
+// checkVersion refuses a change made against another version of t than the
+// current one. Version 0 accepts any.
+func checkVersion(t models.Task, version int) error {
+	if version != 0 && version != t.Version {
+		return models.PreconditionFailed(fmt.Sprintf("task is at version %d, not %d", t.Version, version))
+	}
+	return nil
+}
+
+// update stores t, which replaces old, and records the change. When it
+// completes the task, its parents whose subtasks are all completed then are
+// completed too; completed counts every task that was.
+func (s *Service) update(ctx context.Context, old, t models.Task) (updated models.Task, completed int, err error) {
+	updated, err = s.storeUpdate(ctx, t)
+	if err != nil {
+		return models.Task{}, 0, err
+	}
+	if err := s.auditUpdate(ctx, old, updated); err != nil {
+		return models.Task{}, 0, err
+	}
+	if !updated.Completed || old.Completed {
+		return updated, 0, nil
 	}
-	_, err := s.TaskStore.GetTask(id)
+	n, err := s.completeParents(ctx, updated)
 	if err != nil {
+		return models.Task{}, 0, err
+	}
+	return updated, n + 1, nil
+}
+
+// storeUpdate stores t, which replaces the task at t.Version, and returns it
+// at its new version. Without a Tx another change can get in between the
+// read and the write, which the store then refuses.
+func (s *Service) storeUpdate(ctx context.Context, t models.Task) (models.Task, error) {
+	t, err := s.TaskStore.UpdateTask(ctx, t)
+	switch {
+	case err == sql.ErrNoRows:
+		return models.Task{}, models.NotFound("task not found")
+	case errors.Is(err, models.ErrPreconditionFailed):
+		return models.Task{}, models.PreconditionFailed("task was changed by another request")
+	case err != nil:
+		return models.Task{}, err
+	}
+	return t, nil
+}
+
+// auditUpdate records the update of old to t, which is a completion if it
+// marked the task completed.
+func (s *Service) auditUpdate(ctx context.Context, old, t models.Task) error {
+	action := models.AuditUpdate
+	if t.Completed && !old.Completed {
+		action = models.AuditComplete
+	}
+	return s.audit(ctx, action, t.ID, old, t)
+}
+
+// countCompleted counts the tasks that a change marked completed.
+func (s *Service) countCompleted(n int) {
+	if s.Metrics == nil {
+		return
+	}
+	for range n {
+		s.Metrics.TaskCompleted()
+	}
+}
+
+// validateUpdate checks the new state of a task and normalizes its due
+// date. The owner is only looked up when the update moves the task to
+// another user, which only admins may do, and the parent when it changes.
+// Unless force is set, a task with open blockers cannot be completed.
+func (s *Service) validateUpdate(ctx context.Context, old models.Task, t *models.Task, force bool) error {
+	if t.Task == "" {
+		return models.Validation("task cannot be empty")
+	}
+	if t.UserID <= 0 {
+		return models.Validation("invalid user ID")
+	}
+	if err := checkPriority(t.Priority); err != nil {
+		return err
+	}
+	t.DueAt = normalizeTime(t.DueAt)
+	if t.UserID != old.UserID {
+		if p, err := caller(ctx); err != nil || !p.CanAccess(t.UserID) {
+			return models.Forbidden("cannot assign tasks to another user")
+		}
+		if err := s.checkUser(ctx, t.UserID); err != nil {
+			return err
+		}
+	}
+	if t.ParentID != old.ParentID {
+		if err := s.checkParent(ctx, *t); err != nil {
+			return err
+		}
+	}
+	if t.Completed && !old.Completed && !force {
+		return s.checkBlockers(ctx, t.ID)
+	}
+	return nil
+}
+
+// checkPriority refuses a priority that is neither empty nor one of
+// models.Priorities.
+func checkPriority(p models.Priority) error {
+	if p != "" && !slices.Contains(models.Priorities, p) {
+		return models.Validation(fmt.Sprintf("unknown priority %q", p))
+	}
+	return nil
+}
+
+// normalizeTime returns t in UTC at the microsecond precision the stores
+// keep, so a stored time reads back as it was written.
+func normalizeTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// checkUser makes sure a task can be assigned to userID and keeps the user
+// from being deleted until the unit of work ends. A user that cannot be
+// found is a validation failure of the task; other lookup errors are not.
+func (s *Service) checkUser(ctx context.Context, userID int) error {
+	_, err := s.UserService.LockUser(ctx, userID)
+	if errors.Is(err, models.ErrNotFound) || errors.Is(err, models.ErrValidation) {
+		return models.Validation("user ID not found")
+	}
+	return err
+}
+
+// DeleteTask moves the task with id to the trash, from where RestoreTask
+// brings it back until it is purged. A version other than 0 must be the
+// task's current one.
+func (s *Service) DeleteTask(ctx context.Context, id int, version int) error {
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		existing, err := s.getTask(ctx, id, true)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(existing, version); err != nil {
+			return err
+		}
+		if err := s.TaskStore.DeleteTask(ctx, id); err != nil {
+			return err
+		}
+		return s.audit(ctx, models.AuditDelete, id, existing, nil)
+	})
+	if err == nil && s.Metrics != nil {
+		s.Metrics.TaskDeleted()
+	}
+	return err
+}
+
+// RestoreTask takes the task with id out of the trash and returns it. A
+// task whose user is in the trash too stays there until the user is
+// restored.
+func (s *Service) RestoreTask(ctx context.Context, id int) (models.Task, error) {
+	p, err := caller(ctx)
+	if err != nil {
+		return models.Task{}, err
+	}
+	if id <= 0 {
+		return models.Task{}, models.Validation("invalid task ID")
+	}
+	var t models.Task
+	err = s.inTx(ctx, func(ctx context.Context) error {
+		var err error
+		t, err = s.TaskStore.GetDeletedTaskForUpdate(ctx, id)
+		if err == sql.ErrNoRows || (err == nil && !p.CanAccess(t.UserID)) {
+			return models.NotFound("task not found in the trash")
+		}
+		if err != nil {
+			return err
+		}
+		// The user must not be deleted, nor be, until the task is back
+		if _, err := s.UserService.LockUser(ctx, t.UserID); err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				return models.Conflict("the task's user is in the trash and must be restored first")
+			}
+			return err
+		}
+		deleted := t
+		t, err = s.TaskStore.RestoreTask(ctx, id)
 		if err == sql.ErrNoRows {
-			return errors.New("task not found")
+			return models.NotFound("task not found in the trash")
 		}
-		return err
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, models.AuditRestore, id, deleted, t)
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+	return t, nil
+}
+
+// caller returns who the request in ctx was made by. Every transport
+// authenticates its requests, so a ctx without a principal is refused rather
+// than let through.
+func caller(ctx context.Context) (models.Principal, error) {
+	p, ok := models.PrincipalFrom(ctx)
+	if !ok {
+		return models.Principal{}, models.Unauthorized("authentication required")
+	}
+	return p, nil
+}
+
+// audit records a change to the task with id, from before to after, in the
+// unit of work of ctx. It does nothing without an Audit.
+func (s *Service) audit(ctx context.Context, action models.AuditAction, id int, before, after any) error {
+	if s.Audit == nil {
+		return nil
+	}
+	return s.Audit.AppendAudit(ctx, models.NewAuditEntry(ctx, action, models.AuditTask, id, before, after))
+}
+
+// inTx runs fn as one unit of work, or simply calls it without a Tx.
+func (s *Service) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.Tx == nil {
+		return fn(ctx)
 	}
-	return s.TaskStore.DeleteTask(id)
+	return s.Tx.InTx(ctx, fn)
 }
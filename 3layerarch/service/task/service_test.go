@@ -1,9 +1,12 @@
 package taskservice_test
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"reflect"
 	"testing"
+	"time"
 
 	"3layerarch/models"
 	"3layerarch/service/task"
@@ -11,52 +14,225 @@ import (
 
 // MockTaskStore implements TaskStore interface with function fields
 type MockTaskStore struct {
-	CreateTaskFn func(t models.Task) error
-	GetTaskFn    func(id int) (models.Task, error)
-	ViewTasksFn  func() ([]models.Task, error)
-	UpdateTaskFn func(id int) error
-	DeleteTaskFn func(id int) error
+	CreateTaskFn       func(ctx context.Context, t models.Task) (models.Task, error)
+	GetTaskFn          func(ctx context.Context, id int) (models.Task, error)
+	GetTaskForUpdateFn func(ctx context.Context, id int) (models.Task, error)
+	ViewTasksFn        func(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error)
+	UpdateTaskFn       func(ctx context.Context, t models.Task) (models.Task, error)
+	DeleteTaskFn       func(ctx context.Context, id int) error
+
+	GetDeletedTaskForUpdateFn func(ctx context.Context, id int) (models.Task, error)
+	RestoreTaskFn             func(ctx context.Context, id int) (models.Task, error)
+	PurgeTasksFn              func(ctx context.Context, before time.Time) (int, error)
+
+	CreateLabelFn     func(ctx context.Context, l models.Label) (models.Label, error)
+	GetLabelFn        func(ctx context.Context, id int) (models.Label, error)
+	GetLabelByNameFn  func(ctx context.Context, name string) (models.Label, error)
+	ViewLabelsFn      func(ctx context.Context) ([]models.Label, error)
+	UpdateLabelFn     func(ctx context.Context, l models.Label) error
+	DeleteLabelFn     func(ctx context.Context, id int) error
+	TaskLabelsFn      func(ctx context.Context, taskID int) ([]models.Label, error)
+	AddTaskLabelFn    func(ctx context.Context, taskID, labelID int) error
+	RemoveTaskLabelFn func(ctx context.Context, taskID, labelID int) error
+
+	// The dependency methods find no parents, subtasks or blockers while
+	// their Fn is unset, as most tests have none.
+	GetParentIDFn      func(ctx context.Context, id int) (int, error)
+	SubtasksFn         func(ctx context.Context, parentID int) ([]models.Task, error)
+	BlockersFn         func(ctx context.Context, taskID int) ([]models.Task, error)
+	BlockerIDsFn       func(ctx context.Context, taskID int) ([]int, error)
+	AddDependencyFn    func(ctx context.Context, taskID, blockerID int) error
+	RemoveDependencyFn func(ctx context.Context, taskID, blockerID int) error
+	TaskGraphFn        func(ctx context.Context, userID *int) (models.TaskGraph, error)
+}
+
+func (m *MockTaskStore) CreateTask(ctx context.Context, t models.Task) (models.Task, error) {
+	return m.CreateTaskFn(ctx, t)
+}
+
+func (m *MockTaskStore) GetTask(ctx context.Context, id int) (models.Task, error) {
+	return m.GetTaskFn(ctx, id)
+}
+
+func (m *MockTaskStore) GetTaskForUpdate(ctx context.Context, id int) (models.Task, error) {
+	return m.GetTaskForUpdateFn(ctx, id)
+}
+
+func (m *MockTaskStore) ViewTasks(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error) {
+	return m.ViewTasksFn(ctx, f)
+}
+
+func (m *MockTaskStore) UpdateTask(ctx context.Context, t models.Task) (models.Task, error) {
+	return m.UpdateTaskFn(ctx, t)
+}
+
+func (m *MockTaskStore) DeleteTask(ctx context.Context, id int) error {
+	return m.DeleteTaskFn(ctx, id)
+}
+
+func (m *MockTaskStore) GetDeletedTaskForUpdate(ctx context.Context, id int) (models.Task, error) {
+	return m.GetDeletedTaskForUpdateFn(ctx, id)
+}
+
+func (m *MockTaskStore) RestoreTask(ctx context.Context, id int) (models.Task, error) {
+	return m.RestoreTaskFn(ctx, id)
+}
+
+func (m *MockTaskStore) PurgeTasks(ctx context.Context, before time.Time) (int, error) {
+	return m.PurgeTasksFn(ctx, before)
+}
+
+func (m *MockTaskStore) CreateLabel(ctx context.Context, l models.Label) (models.Label, error) {
+	return m.CreateLabelFn(ctx, l)
+}
+
+func (m *MockTaskStore) GetLabel(ctx context.Context, id int) (models.Label, error) {
+	return m.GetLabelFn(ctx, id)
+}
+
+func (m *MockTaskStore) GetLabelByName(ctx context.Context, name string) (models.Label, error) {
+	return m.GetLabelByNameFn(ctx, name)
+}
+
+func (m *MockTaskStore) ViewLabels(ctx context.Context) ([]models.Label, error) {
+	return m.ViewLabelsFn(ctx)
+}
+
+func (m *MockTaskStore) UpdateLabel(ctx context.Context, l models.Label) error {
+	return m.UpdateLabelFn(ctx, l)
+}
+
+func (m *MockTaskStore) DeleteLabel(ctx context.Context, id int) error {
+	return m.DeleteLabelFn(ctx, id)
+}
+
+func (m *MockTaskStore) TaskLabels(ctx context.Context, taskID int) ([]models.Label, error) {
+	return m.TaskLabelsFn(ctx, taskID)
+}
+
+func (m *MockTaskStore) AddTaskLabel(ctx context.Context, taskID, labelID int) error {
+	return m.AddTaskLabelFn(ctx, taskID, labelID)
+}
+
+func (m *MockTaskStore) RemoveTaskLabel(ctx context.Context, taskID, labelID int) error {
+	return m.RemoveTaskLabelFn(ctx, taskID, labelID)
+}
+
+func (m *MockTaskStore) GetParentID(ctx context.Context, id int) (int, error) {
+	if m.GetParentIDFn == nil {
+		return 0, nil
+	}
+	return m.GetParentIDFn(ctx, id)
 }
 
-func (m *MockTaskStore) CreateTask(t models.Task) error {
-	return m.CreateTaskFn(t)
+func (m *MockTaskStore) Subtasks(ctx context.Context, parentID int) ([]models.Task, error) {
+	if m.SubtasksFn == nil {
+		return []models.Task{}, nil
+	}
+	return m.SubtasksFn(ctx, parentID)
+}
+
+func (m *MockTaskStore) Blockers(ctx context.Context, taskID int) ([]models.Task, error) {
+	if m.BlockersFn == nil {
+		return []models.Task{}, nil
+	}
+	return m.BlockersFn(ctx, taskID)
 }
 
-func (m *MockTaskStore) GetTask(id int) (models.Task, error) {
-	return m.GetTaskFn(id)
+func (m *MockTaskStore) BlockerIDs(ctx context.Context, taskID int) ([]int, error) {
+	if m.BlockerIDsFn == nil {
+		return []int{}, nil
+	}
+	return m.BlockerIDsFn(ctx, taskID)
 }
 
-func (m *MockTaskStore) ViewTasks() ([]models.Task, error) {
-	return m.ViewTasksFn()
+func (m *MockTaskStore) AddDependency(ctx context.Context, taskID, blockerID int) error {
+	return m.AddDependencyFn(ctx, taskID, blockerID)
 }
 
-func (m *MockTaskStore) UpdateTask(id int) error {
-	return m.UpdateTaskFn(id)
+func (m *MockTaskStore) RemoveDependency(ctx context.Context, taskID, blockerID int) error {
+	return m.RemoveDependencyFn(ctx, taskID, blockerID)
 }
 
-func (m *MockTaskStore) DeleteTask(id int) error {
-	return m.DeleteTaskFn(id)
+func (m *MockTaskStore) TaskGraph(ctx context.Context, userID *int) (models.TaskGraph, error) {
+	return m.TaskGraphFn(ctx, userID)
 }
 
 // MockUserService implements UserService interface
 type MockUserService struct {
-	GetUserFn func(id int) (models.User, error)
+	GetUserFn  func(ctx context.Context, id int) (models.User, error)
+	LockUserFn func(ctx context.Context, id int) (models.User, error)
 }
 
-func (m *MockUserService) GetUser(id int) (models.User, error) {
-	return m.GetUserFn(id)
+func (m *MockUserService) GetUser(ctx context.Context, id int) (models.User, error) {
+	return m.GetUserFn(ctx, id)
+}
+
+func (m *MockUserService) LockUser(ctx context.Context, id int) (models.User, error) {
+	return m.LockUserFn(ctx, id)
+}
+
+// adminCtx is the context of a request made by an admin, who may see and
+// change every task.
+var adminCtx = models.WithPrincipal(context.Background(), models.Principal{Name: "ops", Role: models.RoleAdmin})
+
+// userCtx returns the context of a request made by the user with id.
+func userCtx(id int) context.Context {
+	return models.WithPrincipal(context.Background(), models.Principal{UserID: id, Role: models.RoleUser})
+}
+
+type txKey struct{}
+
+// MockTx runs each unit of work directly with a marked context, so a test
+// can tell which calls were made inside one. Err fails the commit.
+type MockTx struct {
+	Runs int
+	Err  error
+}
+
+func (m *MockTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.Runs++
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		return err
+	}
+	return m.Err
+}
+
+func inTx(ctx context.Context) bool {
+	return ctx.Value(txKey{}) != nil
+}
+
+// errRetry fails a unit of work that RetryTx runs again.
+var errRetry = errors.New("deadlock found")
+
+// RetryTx runs each unit of work again while it fails with errRetry, up to
+// three times, as the store's Transactor does.
+type RetryTx struct {
+	Runs int
+}
+
+func (m *RetryTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for range 3 {
+		m.Runs++
+		if err = fn(context.WithValue(ctx, txKey{}, true)); !errors.Is(err, errRetry) {
+			return err
+		}
+	}
+	return err
 }
 
 // ---- TESTS ----
 
 func TestCreateTask_Success(t *testing.T) {
 	mockStore := &MockTaskStore{
-		CreateTaskFn: func(t models.Task) error {
-			return nil
+		CreateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			t.ID = 5
+			return t, nil
 		},
 	}
 	mockUser := &MockUserService{
-		GetUserFn: func(id int) (models.User, error) {
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
 			return models.User{ID: id, Name: "Alice"}, nil
 		},
 	}
@@ -67,10 +243,13 @@ func TestCreateTask_Success(t *testing.T) {
 		UserID: 1,
 	}
 
-	err := svc.CreateTask(task)
+	created, err := svc.CreateTask(adminCtx, task)
 	if err != nil {
 		t.Errorf("expected nil error, got %v", err)
 	}
+	if created.ID != 5 || created.Task != "Test task" {
+		t.Errorf("expected the stored task, got %+v", created)
+	}
 }
 
 func TestCreateTask_EmptyTask(t *testing.T) {
@@ -81,20 +260,29 @@ func TestCreateTask_EmptyTask(t *testing.T) {
 		UserID: 1,
 	}
 
-	err := svc.CreateTask(task)
+	_, err := svc.CreateTask(adminCtx, task)
 	if err == nil || err.Error() != "task cannot be empty" {
 		t.Errorf("expected 'task cannot be empty' error, got %v", err)
 	}
 }
 
+func TestCreateTask_UnknownPriority(t *testing.T) {
+	svc := taskservice.New(nil, nil)
+
+	_, err := svc.CreateTask(adminCtx, models.Task{Task: "Valid task", UserID: 1, Priority: "critical"})
+	if err == nil || err.Error() != `unknown priority "critical"` {
+		t.Errorf("expected 'unknown priority' error, got %v", err)
+	}
+}
+
 func TestCreateTask_UserNotFound(t *testing.T) {
 	mockUser := &MockUserService{
-		GetUserFn: func(id int) (models.User, error) {
-			return models.User{}, errors.New("user not found")
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{}, models.NotFound("user not found")
 		},
 	}
 	mockStore := &MockTaskStore{
-		CreateTaskFn: func(t models.Task) error { return nil },
+		CreateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) { return t, nil },
 	}
 	svc := taskservice.New(mockStore, mockUser)
 
@@ -103,21 +291,39 @@ func TestCreateTask_UserNotFound(t *testing.T) {
 		UserID: 99,
 	}
 
-	err := svc.CreateTask(task)
+	_, err := svc.CreateTask(adminCtx, task)
 	if err == nil || err.Error() != "user ID not found" {
 		t.Errorf("expected 'user ID not found' error, got %v", err)
 	}
+	if !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
+
+func TestCreateTask_UserLookupFails(t *testing.T) {
+	dbErr := errors.New("connection refused")
+	mockUser := &MockUserService{
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{}, dbErr
+		},
+	}
+	svc := taskservice.New(&MockTaskStore{}, mockUser)
+
+	_, err := svc.CreateTask(adminCtx, models.Task{Task: "Valid task", UserID: 1})
+	if err != dbErr {
+		t.Errorf("expected the lookup error to pass through, got %v", err)
+	}
 }
 
 func TestGetTask_Success(t *testing.T) {
 	mockStore := &MockTaskStore{
-		GetTaskFn: func(id int) (models.Task, error) {
+		GetTaskFn: func(ctx context.Context, id int) (models.Task, error) {
 			return models.Task{ID: id, Task: "task1", UserID: 1}, nil
 		},
 	}
 	svc := taskservice.New(mockStore, nil)
 
-	task, err := svc.GetTask(1)
+	task, err := svc.GetTask(adminCtx, 1)
 	if err != nil {
 		t.Errorf("expected nil error, got %v", err)
 	}
@@ -129,81 +335,170 @@ func TestGetTask_Success(t *testing.T) {
 func TestGetTask_InvalidID(t *testing.T) {
 	svc := taskservice.New(nil, nil)
 
-	_, err := svc.GetTask(0)
+	_, err := svc.GetTask(adminCtx, 0)
 	if err == nil || err.Error() != "invalid task ID" {
 		t.Errorf("expected 'invalid task ID' error, got %v", err)
 	}
+	if !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected a validation error, got %v", err)
+	}
 }
 
 func TestGetTask_NotFound(t *testing.T) {
 	mockStore := &MockTaskStore{
-		GetTaskFn: func(id int) (models.Task, error) {
+		GetTaskFn: func(ctx context.Context, id int) (models.Task, error) {
 			return models.Task{}, sql.ErrNoRows
 		},
 	}
 	svc := taskservice.New(mockStore, nil)
 
-	_, err := svc.GetTask(1)
+	_, err := svc.GetTask(adminCtx, 1)
 	if err == nil || err.Error() != "task not found" {
 		t.Errorf("expected 'task not found' error, got %v", err)
 	}
+	if !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
 }
 
 func TestViewTasks_Success(t *testing.T) {
+	var got models.TaskFilter
 	mockStore := &MockTaskStore{
-		ViewTasksFn: func() ([]models.Task, error) {
+		ViewTasksFn: func(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error) {
+			got = f
 			return []models.Task{
 				{ID: 1, Task: "task1"},
 				{ID: 2, Task: "task2"},
-			}, nil
+			}, 5, nil
 		},
 	}
 	svc := taskservice.New(mockStore, nil)
 
-	tasks, err := svc.ViewTasks()
+	page, err := svc.ViewTasks(adminCtx, models.TaskFilter{Search: "task", Sort: "-task"})
 	if err != nil {
 		t.Errorf("expected nil error, got %v", err)
 	}
-	if len(tasks) != 2 {
-		t.Errorf("expected 2 tasks, got %d", len(tasks))
+	if len(page.Tasks) != 2 || page.Total != 5 {
+		t.Errorf("expected 2 of 5 tasks, got %d of %d", len(page.Tasks), page.Total)
+	}
+	if got.Limit != 20 || page.Limit != 20 {
+		t.Errorf("expected default limit 20, got %d passed and %d returned", got.Limit, page.Limit)
+	}
+}
+
+func TestViewTasks_InvalidFilter(t *testing.T) {
+	svc := taskservice.New(nil, nil)
+	badUser, badLabel := 0, -1
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc    string
+		filter  models.TaskFilter
+		wantErr string
+	}{
+		{"Limit too large", models.TaskFilter{Limit: 101}, "limit must be between 1 and 100"},
+		{"Negative limit", models.TaskFilter{Limit: -1}, "limit must be between 1 and 100"},
+		{"Negative offset", models.TaskFilter{Offset: -1}, "offset cannot be negative"},
+		{"Unknown sort", models.TaskFilter{Sort: "password"}, `cannot sort by "password"`},
+		{"Invalid user", models.TaskFilter{UserID: &badUser}, "invalid user ID"},
+		{"Invalid label", models.TaskFilter{LabelID: &badLabel}, "invalid label ID"},
+		{"Unknown priority", models.TaskFilter{Priority: "someday"}, `unknown priority "someday"`},
+		{"Empty created range", models.TaskFilter{CreatedAfter: day, CreatedBefore: day}, "created_after must be before created_before"},
+		{"Empty completed range", models.TaskFilter{CompletedAfter: day.Add(time.Hour), CompletedBefore: day}, "completed_after must be before completed_before"},
+	}
+
+	for _, tc := range tests {
+		_, err := svc.ViewTasks(adminCtx, tc.filter)
+		if err == nil || err.Error() != tc.wantErr {
+			t.Errorf("%s: expected %q error, got %v", tc.desc, tc.wantErr, err)
+		}
 	}
 }
 
 func TestViewTasks_Error(t *testing.T) {
 	mockStore := &MockTaskStore{
-		ViewTasksFn: func() ([]models.Task, error) {
-			return nil, errors.New("db error")
+		ViewTasksFn: func(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error) {
+			return nil, 0, errors.New("db error")
 		},
 	}
 	svc := taskservice.New(mockStore, nil)
 
-	_, err := svc.ViewTasks()
+	_, err := svc.ViewTasks(adminCtx, models.TaskFilter{})
 	if err == nil || err.Error() != "db error" {
 		t.Errorf("expected 'db error', got %v", err)
 	}
 }
 
+func TestViewUserTasks(t *testing.T) {
+	var got models.TaskFilter
+	mockStore := &MockTaskStore{
+		ViewTasksFn: func(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error) {
+			got = f
+			return []models.Task{{ID: 1, Task: "task1", UserID: 3}}, 1, nil
+		},
+	}
+	mockUser := &MockUserService{
+		GetUserFn: func(ctx context.Context, id int) (models.User, error) {
+			if id != 3 {
+				return models.User{}, models.NotFound("user not found")
+			}
+			return models.User{ID: 3, Name: "Carol"}, nil
+		},
+	}
+	svc := taskservice.New(mockStore, mockUser)
+
+	page, err := svc.ViewUserTasks(adminCtx, 3, models.TaskFilter{Search: "task"})
+	if err != nil || len(page.Tasks) != 1 {
+		t.Errorf("unexpected page: %+v, err: %v", page, err)
+	}
+	if got.UserID == nil || *got.UserID != 3 || got.Search != "task" {
+		t.Errorf("expected the filter scoped to user 3, got %+v", got)
+	}
+
+	if _, err := svc.ViewUserTasks(adminCtx, 9, models.TaskFilter{}); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected not found for an unknown user, got %v", err)
+	}
+}
+
 func TestUpdateTask_Success(t *testing.T) {
+	var stored models.Task
+	created := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
 	mockStore := &MockTaskStore{
-		GetTaskFn: func(id int) (models.Task, error) {
-			return models.Task{ID: id}, nil
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "old", UserID: 1, Version: 3, CreatedAt: created}, nil
 		},
-		UpdateTaskFn: func(id int) error {
-			return nil
+		UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			stored = t
+			t.Version++
+			return t, nil
 		},
 	}
-	svc := taskservice.New(mockStore, nil)
+	mockUser := &MockUserService{
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{ID: id, Name: "Bob"}, nil
+		},
+	}
+	svc := taskservice.New(mockStore, mockUser)
 
-	err := svc.UpdateTask(1)
+	// Only the store sets the times
+	got, err := svc.UpdateTask(adminCtx, 1, models.Task{ID: 7, Task: "new", Completed: true, UserID: 2, Version: 9, CreatedAt: time.Now(), CompletedAt: time.Now()}, 3)
 	if err != nil {
 		t.Errorf("expected nil error, got %v", err)
 	}
+	// The store replaces version 3, which the result is the next of
+	want := models.Task{ID: 1, Task: "new", Completed: true, UserID: 2, Version: 3, CreatedAt: created}
+	if stored != want {
+		t.Errorf("expected %v stored, got %v", want, stored)
+	}
+	if want.Version = 4; got != want {
+		t.Errorf("expected %v returned, got %v", want, got)
+	}
 }
 
 func TestUpdateTask_InvalidID(t *testing.T) {
 	svc := taskservice.New(nil, nil)
 
-	err := svc.UpdateTask(0)
+	_, err := svc.UpdateTask(adminCtx, 0, models.Task{Task: "new", UserID: 1}, 0)
 	if err == nil || err.Error() != "invalid task ID" {
 		t.Errorf("expected 'invalid task ID' error, got %v", err)
 	}
@@ -211,29 +506,297 @@ func TestUpdateTask_InvalidID(t *testing.T) {
 
 func TestUpdateTask_NotFound(t *testing.T) {
 	mockStore := &MockTaskStore{
-		GetTaskFn: func(id int) (models.Task, error) {
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
 			return models.Task{}, sql.ErrNoRows // 🔧 proper sentinel error
 		},
 	}
 	svc := taskservice.New(mockStore, nil)
 
-	err := svc.UpdateTask(1)
+	_, err := svc.UpdateTask(adminCtx, 1, models.Task{Task: "new", UserID: 1}, 0)
 	if err == nil || err.Error() != "task not found" {
 		t.Errorf("expected 'task not found' error, got %v", err)
 	}
 }
+
+func TestUpdateTask_Validation(t *testing.T) {
+	mockStore := &MockTaskStore{
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "old", UserID: 1}, nil
+		},
+		UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			return models.Task{}, errors.New("store should not be called")
+		},
+	}
+	mockUser := &MockUserService{
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{}, models.NotFound("user not found")
+		},
+	}
+	svc := taskservice.New(mockStore, mockUser)
+
+	tests := []struct {
+		desc    string
+		task    models.Task
+		wantErr string
+	}{
+		{"Empty task", models.Task{Task: "", UserID: 1}, "task cannot be empty"},
+		{"Missing user", models.Task{Task: "new"}, "invalid user ID"},
+		{"Unknown user", models.Task{Task: "new", UserID: 99}, "user ID not found"},
+		{"Unknown priority", models.Task{Task: "new", UserID: 1, Priority: "HIGH"}, `unknown priority "HIGH"`},
+	}
+
+	for _, tc := range tests {
+		_, err := svc.UpdateTask(adminCtx, 1, tc.task, 0)
+		if err == nil || err.Error() != tc.wantErr {
+			t.Errorf("%s: expected %q error, got %v", tc.desc, tc.wantErr, err)
+		}
+	}
+}
+
+func TestPatchTask_Success(t *testing.T) {
+	var stored models.Task
+	mockStore := &MockTaskStore{
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "old", UserID: 1}, nil
+		},
+		UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			stored = t
+			t.Version++
+			return t, nil
+		},
+	}
+	// The owner is unchanged, so the user service must not be consulted.
+	svc := taskservice.New(mockStore, nil)
+
+	done := true
+	got, err := svc.PatchTask(adminCtx, 1, models.TaskPatch{Completed: &done}, 0)
+	if err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+	want := models.Task{ID: 1, Task: "old", Completed: true, UserID: 1}
+	if stored != want {
+		t.Errorf("expected %v stored, got %v", want, stored)
+	}
+	if want.Version = 1; got != want {
+		t.Errorf("expected %v returned, got %v", want, got)
+	}
+}
+
+func TestPatchTask_ReassignAndReopen(t *testing.T) {
+	mockStore := &MockTaskStore{
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "old", Completed: true, UserID: 1}, nil
+		},
+		UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			t.Version++
+			return t, nil
+		},
+	}
+	mockUser := &MockUserService{
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{ID: id, Name: "Carol"}, nil
+		},
+	}
+	svc := taskservice.New(mockStore, mockUser)
+
+	name, open, user := "renamed", false, 3
+	got, err := svc.PatchTask(adminCtx, 1, models.TaskPatch{Task: &name, Completed: &open, UserID: &user}, 0)
+	if err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+	want := models.Task{ID: 1, Task: "renamed", Completed: false, UserID: 3, Version: 1}
+	if got != want {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestPatchTask_PriorityAndDueAt(t *testing.T) {
+	due := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	mockStore := &MockTaskStore{
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "old", UserID: 1, Priority: models.PriorityLow, DueAt: due}, nil
+		},
+		UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			return t, nil
+		},
+	}
+	svc := taskservice.New(mockStore, nil)
+
+	// A due date in another zone is stored in UTC, to the microsecond
+	urgent := models.PriorityUrgent
+	later := time.Date(2025, 3, 2, 12, 0, 0, 1500, time.FixedZone("CET", 3600))
+	got, err := svc.PatchTask(adminCtx, 1, models.TaskPatch{Priority: &urgent, DueAt: &later}, 0)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if want := time.Date(2025, 3, 2, 11, 0, 0, 1000, time.UTC); got.Priority != urgent || got.DueAt != want {
+		t.Errorf("expected urgent due %v, got %q due %v", want, got.Priority, got.DueAt)
+	}
+
+	// Zero values remove them
+	var none models.Priority
+	var never time.Time
+	got, err = svc.PatchTask(adminCtx, 1, models.TaskPatch{Priority: &none, DueAt: &never}, 0)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if got.Priority != "" || !got.DueAt.IsZero() {
+		t.Errorf("expected no priority or due date, got %q due %v", got.Priority, got.DueAt)
+	}
+
+	someday := models.Priority("someday")
+	if _, err := svc.PatchTask(adminCtx, 1, models.TaskPatch{Priority: &someday}, 0); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
+
+func TestPatchTask_Errors(t *testing.T) {
+	mockStore := &MockTaskStore{
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			if id == 2 {
+				return models.Task{}, sql.ErrNoRows
+			}
+			return models.Task{ID: id, Task: "old", UserID: 1}, nil
+		},
+		UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			return models.Task{}, errors.New("update failed")
+		},
+	}
+	mockUser := &MockUserService{
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{}, models.NotFound("user not found")
+		},
+	}
+	svc := taskservice.New(mockStore, mockUser)
+
+	empty, unknownUser, done := "", 99, true
+	tests := []struct {
+		desc    string
+		id      int
+		patch   models.TaskPatch
+		wantErr string
+	}{
+		{"Invalid ID", 0, models.TaskPatch{}, "invalid task ID"},
+		{"Not found", 2, models.TaskPatch{}, "task not found"},
+		{"Empty task", 1, models.TaskPatch{Task: &empty}, "task cannot be empty"},
+		{"Unknown user", 1, models.TaskPatch{UserID: &unknownUser}, "user ID not found"},
+		{"Store error", 1, models.TaskPatch{Completed: &done}, "update failed"},
+	}
+
+	for _, tc := range tests {
+		_, err := svc.PatchTask(adminCtx, tc.id, tc.patch, 0)
+		if err == nil || err.Error() != tc.wantErr {
+			t.Errorf("%s: expected %q error, got %v", tc.desc, tc.wantErr, err)
+		}
+	}
+}
+
+func TestVersionMismatch(t *testing.T) {
+	mockStore := &MockTaskStore{
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "old", UserID: 1, Version: 3}, nil
+		},
+		UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			return models.Task{}, errors.New("store should not be called")
+		},
+		DeleteTaskFn: func(ctx context.Context, id int) error {
+			return errors.New("store should not be called")
+		},
+	}
+	svc := taskservice.New(mockStore, nil)
+
+	done := true
+	changes := map[string]func() error{
+		"UpdateTask": func() error {
+			_, err := svc.UpdateTask(adminCtx, 1, models.Task{Task: "new", UserID: 1}, 2)
+			return err
+		},
+		"PatchTask": func() error {
+			_, err := svc.PatchTask(adminCtx, 1, models.TaskPatch{Completed: &done}, 2)
+			return err
+		},
+		"DeleteTask": func() error { return svc.DeleteTask(adminCtx, 1, 2) },
+	}
+	for name, change := range changes {
+		if err := change(); !errors.Is(err, models.ErrPreconditionFailed) {
+			t.Errorf("%s: expected a precondition failure, got %v", name, err)
+		}
+	}
+}
+
+func TestUpdateTask_ChangedConcurrently(t *testing.T) {
+	// Without a Tx the task can change between the read and the write
+	mockStore := &MockTaskStore{
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "old", UserID: 1, Version: 3}, nil
+		},
+		UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			return models.Task{}, models.ErrPreconditionFailed
+		},
+	}
+	svc := taskservice.New(mockStore, nil)
+
+	_, err := svc.UpdateTask(adminCtx, 1, models.Task{Task: "new", UserID: 1}, 0)
+	if !errors.Is(err, models.ErrPreconditionFailed) || err.Error() != "task was changed by another request" {
+		t.Errorf("expected the conflict to be reported, got %v", err)
+	}
+}
+
+func TestUpdateTask_Retried(t *testing.T) {
+	done := true
+	updates := map[string]func(svc *taskservice.Service) (models.Task, error){
+		"update": func(svc *taskservice.Service) (models.Task, error) {
+			return svc.UpdateTask(adminCtx, 1, models.Task{Task: "new", Completed: true, UserID: 1}, 0)
+		},
+		"patch": func(svc *taskservice.Service) (models.Task, error) {
+			return svc.PatchTask(adminCtx, 1, models.TaskPatch{Completed: &done}, 0)
+		},
+		"complete": func(svc *taskservice.Service) (models.Task, error) {
+			return svc.CompleteTask(adminCtx, 1, false, 0)
+		},
+	}
+	for name, update := range updates {
+		// The first attempt deadlocks, the second goes through
+		var attempts int
+		mockStore := &MockTaskStore{
+			GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+				return models.Task{ID: id, Task: "new", UserID: 1, Version: 3}, nil
+			},
+			UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+				if attempts++; attempts == 1 {
+					return models.Task{}, errRetry
+				}
+				t.Version++
+				return t, nil
+			},
+		}
+		svc := taskservice.New(mockStore, nil)
+		tx := &RetryTx{}
+		svc.Tx = tx
+
+		got, err := update(svc)
+		want := models.Task{ID: 1, Task: "new", Completed: true, UserID: 1, Version: 4}
+		if err != nil || got != want {
+			t.Errorf("%s: expected %v, got %v, err: %v", name, want, got, err)
+		}
+		if tx.Runs != 2 {
+			t.Errorf("%s: expected 2 attempts, got %d", name, tx.Runs)
+		}
+	}
+}
+
 func TestDeleteTask_Success(t *testing.T) {
 	mockStore := &MockTaskStore{
-		GetTaskFn: func(id int) (models.Task, error) {
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
 			return models.Task{ID: id}, nil
 		},
-		DeleteTaskFn: func(id int) error {
+		DeleteTaskFn: func(ctx context.Context, id int) error {
 			return nil
 		},
 	}
 	svc := taskservice.New(mockStore, nil)
 
-	err := svc.DeleteTask(1)
+	err := svc.DeleteTask(adminCtx, 1, 0)
 	if err != nil {
 		t.Errorf("expected nil error, got %v", err)
 	}
@@ -242,7 +805,7 @@ func TestDeleteTask_Success(t *testing.T) {
 func TestDeleteTask_InvalidID(t *testing.T) {
 	svc := taskservice.New(nil, nil)
 
-	err := svc.DeleteTask(0)
+	err := svc.DeleteTask(adminCtx, 0, 0)
 	if err == nil || err.Error() != "invalid task ID" {
 		t.Errorf("expected 'invalid task ID' error, got %v", err)
 	}
@@ -250,14 +813,439 @@ func TestDeleteTask_InvalidID(t *testing.T) {
 
 func TestDeleteTask_NotFound(t *testing.T) {
 	mockStore := &MockTaskStore{
-		GetTaskFn: func(id int) (models.Task, error) {
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
 			return models.Task{}, sql.ErrNoRows // Correct sentinel error
 		},
 	}
 	svc := taskservice.New(mockStore, nil)
 
-	err := svc.DeleteTask(1)
+	err := svc.DeleteTask(adminCtx, 1, 0)
 	if err == nil || err.Error() != "task not found" {
 		t.Errorf("expected 'task not found' error, got %v", err)
 	}
 }
+
+func TestRestoreTask_Success(t *testing.T) {
+	var restored bool
+	mockStore := &MockTaskStore{
+		GetDeletedTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "Binned", UserID: 2, Version: 2, DeletedAt: time.Now()}, nil
+		},
+		RestoreTaskFn: func(ctx context.Context, id int) (models.Task, error) {
+			restored = inTx(ctx)
+			return models.Task{ID: id, Task: "Binned", UserID: 2, Version: 3}, nil
+		},
+	}
+	mockUser := &MockUserService{
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{ID: id}, nil
+		},
+	}
+	svc := taskservice.New(mockStore, mockUser)
+	svc.Tx = &MockTx{}
+
+	task, err := svc.RestoreTask(userCtx(2), 1)
+	if err != nil || !restored {
+		t.Fatalf("expected the task to be restored in the unit of work, err: %v", err)
+	}
+	if task.Version != 3 || !task.DeletedAt.IsZero() {
+		t.Errorf("expected the restored task at version 3, got %+v", task)
+	}
+}
+
+func TestRestoreTask_Errors(t *testing.T) {
+	mockStore := &MockTaskStore{
+		GetDeletedTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			if id == 9 {
+				return models.Task{}, sql.ErrNoRows
+			}
+			return models.Task{ID: id, UserID: 1, DeletedAt: time.Now()}, nil
+		},
+		RestoreTaskFn: func(ctx context.Context, id int) (models.Task, error) {
+			t.Error("expected no restore")
+			return models.Task{}, nil
+		},
+	}
+	mockUser := &MockUserService{
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{}, models.NotFound("user not found")
+		},
+	}
+	svc := taskservice.New(mockStore, mockUser)
+
+	if _, err := svc.RestoreTask(adminCtx, 0); err == nil || err.Error() != "invalid task ID" {
+		t.Errorf("expected 'invalid task ID' error, got %v", err)
+	}
+	if _, err := svc.RestoreTask(adminCtx, 9); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected a task not in the trash to be not found, got %v", err)
+	}
+	if _, err := svc.RestoreTask(userCtx(2), 1); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected someone else's task to be not found, got %v", err)
+	}
+	if _, err := svc.RestoreTask(adminCtx, 1); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("expected a conflict while the user is in the trash, got %v", err)
+	}
+}
+
+func TestCreateTask_InTx(t *testing.T) {
+	mockStore := &MockTaskStore{
+		CreateTaskFn: func(ctx context.Context, task models.Task) (models.Task, error) {
+			if !inTx(ctx) {
+				t.Error("expected the task to be stored in the unit of work")
+			}
+			task.ID = 5
+			return task, nil
+		},
+	}
+	mockUser := &MockUserService{
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			if !inTx(ctx) {
+				t.Error("expected the user to be locked in the unit of work")
+			}
+			return models.User{ID: id}, nil
+		},
+	}
+	svc := taskservice.New(mockStore, mockUser)
+	tx := &MockTx{}
+	svc.Tx = tx
+
+	if _, err := svc.CreateTask(adminCtx, models.Task{Task: "Test task", UserID: 1}); err != nil || tx.Runs != 1 {
+		t.Errorf("expected one unit of work, got %d, err: %v", tx.Runs, err)
+	}
+
+	tx.Err = errors.New("commit failed")
+	if _, err := svc.CreateTask(adminCtx, models.Task{Task: "Test task", UserID: 1}); err == nil || err.Error() != "commit failed" {
+		t.Errorf("expected the commit error, got %v", err)
+	}
+}
+
+func TestDeleteTask_InTx(t *testing.T) {
+	var deleted bool
+	mockStore := &MockTaskStore{
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			if !inTx(ctx) {
+				t.Error("expected the task to be locked in the unit of work")
+			}
+			return models.Task{ID: id}, nil
+		},
+		DeleteTaskFn: func(ctx context.Context, id int) error {
+			deleted = inTx(ctx)
+			return nil
+		},
+	}
+	svc := taskservice.New(mockStore, nil)
+	svc.Tx = &MockTx{}
+
+	if err := svc.DeleteTask(adminCtx, 1, 0); err != nil || !deleted {
+		t.Errorf("expected the task to be deleted in the unit of work, err: %v", err)
+	}
+}
+
+func TestViewTasks_OwnTasksOnly(t *testing.T) {
+	var got models.TaskFilter
+	mockStore := &MockTaskStore{
+		ViewTasksFn: func(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error) {
+			got = f
+			return []models.Task{{ID: 1, Task: "task1", UserID: 3}}, 1, nil
+		},
+	}
+	svc := taskservice.New(mockStore, nil)
+
+	page, err := svc.ViewTasks(userCtx(3), models.TaskFilter{})
+	if err != nil || len(page.Tasks) != 1 {
+		t.Errorf("unexpected page: %+v, err: %v", page, err)
+	}
+	if got.UserID == nil || *got.UserID != 3 {
+		t.Errorf("expected the listing scoped to the caller, got %+v", got)
+	}
+
+	// Asking for someone else's tasks finds none
+	other := 4
+	mockStore.ViewTasksFn = func(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error) {
+		t.Error("expected the store not to be asked")
+		return nil, 0, nil
+	}
+	page, err = svc.ViewTasks(userCtx(3), models.TaskFilter{UserID: &other})
+	if err != nil || len(page.Tasks) != 0 || page.Total != 0 || page.Tasks == nil {
+		t.Errorf("expected an empty page, got %+v, err: %v", page, err)
+	}
+}
+
+func TestOtherUsersTask_NotFound(t *testing.T) {
+	mockStore := &MockTaskStore{
+		GetTaskFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "theirs", UserID: 1}, nil
+		},
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "theirs", UserID: 1}, nil
+		},
+		UpdateTaskFn: func(ctx context.Context, t2 models.Task) (models.Task, error) {
+			t.Error("expected no update")
+			return t2, nil
+		},
+		DeleteTaskFn: func(ctx context.Context, id int) error {
+			t.Error("expected no delete")
+			return nil
+		},
+	}
+	svc := taskservice.New(mockStore, nil)
+	ctx := userCtx(2)
+	done := true
+
+	if _, err := svc.GetTask(ctx, 7); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("GetTask: expected not found, got %v", err)
+	}
+	if _, err := svc.UpdateTask(ctx, 7, models.Task{Task: "mine now", UserID: 2}, 0); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("UpdateTask: expected not found, got %v", err)
+	}
+	if _, err := svc.PatchTask(ctx, 7, models.TaskPatch{Completed: &done}, 0); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("PatchTask: expected not found, got %v", err)
+	}
+	if err := svc.DeleteTask(ctx, 7, 0); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("DeleteTask: expected not found, got %v", err)
+	}
+
+	// The owner gets their task
+	if task, err := svc.GetTask(userCtx(1), 7); err != nil || task.ID != 7 {
+		t.Errorf("expected the owner to get the task, got %+v, err: %v", task, err)
+	}
+}
+
+func TestAssignToOtherUser_Forbidden(t *testing.T) {
+	mockStore := &MockTaskStore{
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "mine", UserID: 1}, nil
+		},
+	}
+	mockUser := &MockUserService{
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{ID: id}, nil
+		},
+	}
+	svc := taskservice.New(mockStore, mockUser)
+	ctx := userCtx(1)
+	other := 2
+
+	if _, err := svc.CreateTask(ctx, models.Task{Task: "for you", UserID: 2}); !errors.Is(err, models.ErrForbidden) {
+		t.Errorf("CreateTask: expected forbidden, got %v", err)
+	}
+	if _, err := svc.PatchTask(ctx, 7, models.TaskPatch{UserID: &other}, 0); !errors.Is(err, models.ErrForbidden) {
+		t.Errorf("PatchTask: expected forbidden, got %v", err)
+	}
+}
+
+func TestNoPrincipal_Unauthorized(t *testing.T) {
+	svc := taskservice.New(&MockTaskStore{}, nil)
+	ctx := context.Background()
+
+	if _, err := svc.GetTask(ctx, 1); !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("GetTask: expected unauthorized, got %v", err)
+	}
+	if _, err := svc.ViewTasks(ctx, models.TaskFilter{}); !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("ViewTasks: expected unauthorized, got %v", err)
+	}
+	if _, err := svc.CreateTask(ctx, models.Task{Task: "x", UserID: 1}); !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("CreateTask: expected unauthorized, got %v", err)
+	}
+}
+
+// MockMetrics counts the changes a service reports.
+type MockMetrics struct {
+	Created, Completed, Deleted int
+}
+
+func (m *MockMetrics) TaskCreated()   { m.Created++ }
+func (m *MockMetrics) TaskCompleted() { m.Completed++ }
+func (m *MockMetrics) TaskDeleted()   { m.Deleted++ }
+
+func TestMetrics(t *testing.T) {
+	stored := models.Task{ID: 1, Task: "Write docs", UserID: 1}
+	mockStore := &MockTaskStore{
+		CreateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			t.ID = 1
+			return t, nil
+		},
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return stored, nil
+		},
+		UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			t.Version++
+			return t, nil
+		},
+		DeleteTaskFn: func(ctx context.Context, id int) error { return nil },
+	}
+	mockUser := &MockUserService{
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{ID: id}, nil
+		},
+	}
+	m := &MockMetrics{}
+	svc := taskservice.New(mockStore, mockUser)
+	svc.Metrics = m
+
+	if _, err := svc.CreateTask(adminCtx, models.Task{Task: "Write docs", UserID: 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.CreateTask(adminCtx, models.Task{UserID: 1}); err == nil {
+		t.Fatal("expected a validation error")
+	}
+	done := true
+	if _, err := svc.PatchTask(adminCtx, 1, models.TaskPatch{Completed: &done}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Renaming an open task, or completing a completed one, completes nothing
+	if _, err := svc.UpdateTask(adminCtx, 1, models.Task{Task: "Write more docs", UserID: 1}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored.Completed = true
+	if _, err := svc.UpdateTask(adminCtx, 1, models.Task{Task: "Write docs", Completed: true, UserID: 1}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.DeleteTask(adminCtx, 1, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if *m != (MockMetrics{Created: 1, Completed: 1, Deleted: 1}) {
+		t.Errorf("expected one of each change, got %+v", *m)
+	}
+}
+
+// MockAuditor keeps the entries a service records, and whether each was
+// recorded in a unit of work. Err fails every append.
+type MockAuditor struct {
+	Entries []models.AuditEntry
+	InTx    []bool
+	Err     error
+}
+
+func (m *MockAuditor) AppendAudit(ctx context.Context, e models.AuditEntry) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Entries = append(m.Entries, e)
+	m.InTx = append(m.InTx, inTx(ctx))
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	created, updated := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 2, 0, 0, 0, 0, time.UTC)
+	stored := models.Task{ID: 1, Task: "Write docs", UserID: 1, Version: 1, CreatedAt: created, UpdatedAt: created}
+	mockStore := &MockTaskStore{
+		CreateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			t.ID, t.Version, t.CreatedAt, t.UpdatedAt = 1, 1, created, created
+			return t, nil
+		},
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return stored, nil
+		},
+		UpdateTaskFn: func(ctx context.Context, t models.Task) (models.Task, error) {
+			t.Version++
+			t.UpdatedAt = updated
+			return t, nil
+		},
+		DeleteTaskFn: func(ctx context.Context, id int) error { return nil },
+		GetDeletedTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			deleted := stored
+			deleted.DeletedAt, deleted.UpdatedAt = updated, updated
+			return deleted, nil
+		},
+		RestoreTaskFn: func(ctx context.Context, id int) (models.Task, error) {
+			restored := stored
+			restored.Version++
+			restored.UpdatedAt = updated
+			return restored, nil
+		},
+	}
+	mockUser := &MockUserService{
+		LockUserFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{ID: id}, nil
+		},
+	}
+	a := &MockAuditor{}
+	svc := taskservice.New(mockStore, mockUser)
+	svc.Tx = &MockTx{}
+	svc.Audit = a
+	alice := models.Principal{UserID: 1, Name: "alice", Role: models.RoleUser}
+	ctx := models.WithRequestID(models.WithPrincipal(context.Background(), alice), "req-1")
+
+	if _, err := svc.CreateTask(ctx, models.Task{Task: "Write docs", UserID: 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	done := true
+	if _, err := svc.PatchTask(ctx, 1, models.TaskPatch{Completed: &done}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.UpdateTask(ctx, 1, models.Task{Task: "Write more docs", UserID: 1}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.DeleteTask(ctx, 1, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.RestoreTask(ctx, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A failed change records nothing
+	if _, err := svc.CreateTask(ctx, models.Task{UserID: 1}); err == nil {
+		t.Fatal("expected a validation error")
+	}
+
+	want := []struct {
+		action  models.AuditAction
+		changes map[string]models.AuditChange
+	}{
+		{models.AuditCreate, map[string]models.AuditChange{
+			"id": {After: 1.0}, "task": {After: "Write docs"}, "completed": {After: false}, "user_id": {After: 1.0}, "version": {After: 1.0},
+			"created_at": {After: "2025-02-01T00:00:00Z"}, "updated_at": {After: "2025-02-01T00:00:00Z"},
+		}},
+		{models.AuditComplete, map[string]models.AuditChange{
+			"completed": {Before: false, After: true}, "version": {Before: 1.0, After: 2.0},
+			"updated_at": {Before: "2025-02-01T00:00:00Z", After: "2025-02-02T00:00:00Z"},
+		}},
+		{models.AuditUpdate, map[string]models.AuditChange{
+			"task": {Before: "Write docs", After: "Write more docs"}, "version": {Before: 1.0, After: 2.0},
+			"updated_at": {Before: "2025-02-01T00:00:00Z", After: "2025-02-02T00:00:00Z"},
+		}},
+		{models.AuditDelete, map[string]models.AuditChange{
+			"id": {Before: 1.0}, "task": {Before: "Write docs"}, "completed": {Before: false}, "user_id": {Before: 1.0}, "version": {Before: 1.0},
+			"created_at": {Before: "2025-02-01T00:00:00Z"}, "updated_at": {Before: "2025-02-01T00:00:00Z"},
+		}},
+		{models.AuditRestore, map[string]models.AuditChange{
+			"deleted_at": {Before: "2025-02-02T00:00:00Z"}, "version": {Before: 1.0, After: 2.0},
+		}},
+	}
+	if len(a.Entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), a.Entries)
+	}
+	for i, w := range want {
+		e := a.Entries[i]
+		if e.Action != w.action || e.Entity != models.AuditTask || e.EntityID != 1 || e.ActorID != 1 || e.Actor != "alice" || e.RequestID != "req-1" || !a.InTx[i] {
+			t.Errorf("entry %d: expected %s of task 1 by alice in the unit of work of req-1, got %+v", i, w.action, e)
+		}
+		if !reflect.DeepEqual(e.Changes, w.changes) {
+			t.Errorf("entry %d: expected changes %v, got %v", i, w.changes, e.Changes)
+		}
+	}
+}
+
+func TestAudit_Fails(t *testing.T) {
+	errAudit := errors.New("audit log unavailable")
+	mockStore := &MockTaskStore{
+		GetTaskForUpdateFn: func(ctx context.Context, id int) (models.Task, error) {
+			return models.Task{ID: id, Task: "Write docs", UserID: 1, Version: 1}, nil
+		},
+		DeleteTaskFn: func(ctx context.Context, id int) error { return nil },
+	}
+	m := &MockMetrics{}
+	svc := taskservice.New(mockStore, nil)
+	svc.Tx = &MockTx{}
+	svc.Audit = &MockAuditor{Err: errAudit}
+	svc.Metrics = m
+
+	// The change is undone with the unit of work, so it is not counted
+	if err := svc.DeleteTask(adminCtx, 1, 0); !errors.Is(err, errAudit) {
+		t.Errorf("expected the audit error, got %v", err)
+	}
+	if m.Deleted != 0 {
+		t.Errorf("expected no delete to be counted, got %+v", *m)
+	}
+}
@@ -1,42 +1,195 @@
 package userservice_test
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"3layerarch/models"
 	"3layerarch/service/user"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // MockUserStore implements UserStore interface with function fields
 type MockUserStore struct {
-	CreateUserFn func(u models.User) error
-	GetUserFn    func(id int) (models.User, error)
+	CreateUserFn       func(ctx context.Context, u models.User) (models.User, error)
+	GetUserFn          func(ctx context.Context, id int) (models.User, error)
+	GetUserForUpdateFn func(ctx context.Context, id int) (models.User, error)
+	GetUserForShareFn  func(ctx context.Context, id int) (models.User, error)
+	GetUserStatsFn     func(ctx context.Context, id int) (models.User, models.UserStats, error)
+	GetUserTasksFn     func(ctx context.Context, id int) (models.User, []models.Task, error)
+	GetUserByNameFn    func(ctx context.Context, name string) (models.User, error)
+	GetPasswordHashFn  func(ctx context.Context, name string) (models.Principal, string, error)
+	SetPasswordHashFn  func(ctx context.Context, id int, hash string) error
+	ViewUsersFn        func(ctx context.Context, f models.UserFilter) ([]models.User, int, error)
+	UpdateUserFn       func(ctx context.Context, u models.User) error
+	CountTasksFn       func(ctx context.Context, id int) (int, error)
+	LockUserTasksFn    func(ctx context.Context, id int) ([]models.Task, error)
+	DeleteUserFn       func(ctx context.Context, id, reassignTo int) error
+
+	GetDeletedUserForUpdateFn func(ctx context.Context, id int) (models.User, error)
+	RestoreUserFn             func(ctx context.Context, id int) error
+	PurgeUsersFn              func(ctx context.Context, before time.Time) (int, error)
+}
+
+func (m *MockUserStore) CreateUser(ctx context.Context, u models.User) (models.User, error) {
+	return m.CreateUserFn(ctx, u)
+}
+
+func (m *MockUserStore) GetUser(ctx context.Context, id int) (models.User, error) {
+	return m.GetUserFn(ctx, id)
+}
+
+func (m *MockUserStore) GetUserForUpdate(ctx context.Context, id int) (models.User, error) {
+	return m.GetUserForUpdateFn(ctx, id)
+}
+
+func (m *MockUserStore) GetUserForShare(ctx context.Context, id int) (models.User, error) {
+	return m.GetUserForShareFn(ctx, id)
+}
+
+func (m *MockUserStore) GetUserStats(ctx context.Context, id int) (models.User, models.UserStats, error) {
+	return m.GetUserStatsFn(ctx, id)
+}
+
+func (m *MockUserStore) GetUserWithTasks(ctx context.Context, id int) (models.User, []models.Task, error) {
+	return m.GetUserTasksFn(ctx, id)
+}
+
+func (m *MockUserStore) GetUserByName(ctx context.Context, name string) (models.User, error) {
+	if m.GetUserByNameFn == nil {
+		return models.User{}, sql.ErrNoRows
+	}
+	return m.GetUserByNameFn(ctx, name)
+}
+
+func (m *MockUserStore) GetPasswordHash(ctx context.Context, name string) (models.Principal, string, error) {
+	return m.GetPasswordHashFn(ctx, name)
+}
+
+func (m *MockUserStore) SetPasswordHash(ctx context.Context, id int, hash string) error {
+	return m.SetPasswordHashFn(ctx, id, hash)
+}
+
+func (m *MockUserStore) ViewUsers(ctx context.Context, f models.UserFilter) ([]models.User, int, error) {
+	return m.ViewUsersFn(ctx, f)
+}
+
+func (m *MockUserStore) UpdateUser(ctx context.Context, u models.User) error {
+	return m.UpdateUserFn(ctx, u)
+}
+
+func (m *MockUserStore) CountTasks(ctx context.Context, id int) (int, error) {
+	return m.CountTasksFn(ctx, id)
+}
+
+func (m *MockUserStore) LockUserTasks(ctx context.Context, id int) ([]models.Task, error) {
+	return m.LockUserTasksFn(ctx, id)
+}
+
+func (m *MockUserStore) DeleteUser(ctx context.Context, id, reassignTo int) error {
+	return m.DeleteUserFn(ctx, id, reassignTo)
+}
+
+func (m *MockUserStore) GetDeletedUserForUpdate(ctx context.Context, id int) (models.User, error) {
+	return m.GetDeletedUserForUpdateFn(ctx, id)
+}
+
+func (m *MockUserStore) RestoreUser(ctx context.Context, id int) error {
+	return m.RestoreUserFn(ctx, id)
 }
 
-func (m *MockUserStore) CreateUser(u models.User) error {
-	return m.CreateUserFn(u)
+func (m *MockUserStore) PurgeUsers(ctx context.Context, before time.Time) (int, error) {
+	return m.PurgeUsersFn(ctx, before)
 }
 
-func (m *MockUserStore) GetUser(id int) (models.User, error) {
-	return m.GetUserFn(id)
+type txKey struct{}
+
+// MockTx runs each unit of work directly with a marked context, so a test
+// can tell which calls were made inside one.
+type MockTx struct{ Runs int }
+
+func (m *MockTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.Runs++
+	return fn(context.WithValue(ctx, txKey{}, true))
+}
+
+func inTx(ctx context.Context) bool {
+	return ctx.Value(txKey{}) != nil
+}
+
+// errRetry fails a unit of work that RetryTx runs again.
+var errRetry = errors.New("deadlock found")
+
+// RetryTx runs each unit of work again while it fails with errRetry, up to
+// three times, as the store's Transactor does.
+type RetryTx struct{ Runs int }
+
+func (m *RetryTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for range 3 {
+		m.Runs++
+		if err = fn(context.WithValue(ctx, txKey{}, true)); !errors.Is(err, errRetry) {
+			return err
+		}
+	}
+	return err
 }
 
+// adminCtx is the context of a request made by an admin, who may see and
+// change every user.
+var adminCtx = models.WithPrincipal(context.Background(), models.Principal{Name: "ops", Role: models.RoleAdmin})
+
+// userCtx is the context of a request made by the user with id.
+func userCtx(id int) context.Context {
+	return models.WithPrincipal(context.Background(), models.Principal{UserID: id, Name: "user", Role: models.RoleUser})
+}
+
+// usersByID returns a lookup function that knows the given users.
+func usersByID(users ...models.User) func(ctx context.Context, id int) (models.User, error) {
+	return func(ctx context.Context, id int) (models.User, error) {
+		for _, u := range users {
+			if u.ID == id {
+				return u, nil
+			}
+		}
+		return models.User{}, sql.ErrNoRows
+	}
+}
+
+// MockMetrics counts the users a service reports created.
+type MockMetrics struct{ Created int }
+
+func (m *MockMetrics) UserCreated() { m.Created++ }
+
 func TestCreateUser_Success(t *testing.T) {
 	mockStore := &MockUserStore{
-		CreateUserFn: func(u models.User) error {
-			return nil
+		CreateUserFn: func(ctx context.Context, u models.User) (models.User, error) {
+			u.ID = 4
+			return u, nil
 		},
 	}
 	svc := userservice.New(mockStore)
+	m := &MockMetrics{}
+	svc.Metrics = m
 
-	user := models.User{Name: "Alice"}
+	user := models.User{Name: "  Alice "}
 
-	err := svc.CreateUser(user)
+	created, err := svc.CreateUser(context.Background(), user)
 	if err != nil {
 		t.Errorf("expected nil error, got %v", err)
 	}
+	if created.ID != 4 || created.Name != "Alice" {
+		t.Errorf("expected the stored user, got %+v", created)
+	}
+	if m.Created != 1 {
+		t.Errorf("expected 1 user to be counted, got %d", m.Created)
+	}
 }
 
 func TestCreateUser_EmptyName(t *testing.T) {
@@ -44,7 +197,7 @@ func TestCreateUser_EmptyName(t *testing.T) {
 
 	user := models.User{Name: ""}
 
-	err := svc.CreateUser(user)
+	_, err := svc.CreateUser(context.Background(), user)
 	if err == nil || err.Error() != "user name cannot be empty" {
 		t.Errorf("expected 'user name cannot be empty' error, got %v", err)
 	}
@@ -52,13 +205,13 @@ func TestCreateUser_EmptyName(t *testing.T) {
 
 func TestGetUser_Success(t *testing.T) {
 	mockStore := &MockUserStore{
-		GetUserFn: func(id int) (models.User, error) {
+		GetUserFn: func(ctx context.Context, id int) (models.User, error) {
 			return models.User{ID: id, Name: "Alice"}, nil
 		},
 	}
 	svc := userservice.New(mockStore)
 
-	user, err := svc.GetUser(1)
+	user, err := svc.GetUser(context.Background(), 1)
 	if err != nil {
 		t.Errorf("expected nil error, got %v", err)
 	}
@@ -70,7 +223,7 @@ func TestGetUser_Success(t *testing.T) {
 func TestGetUser_InvalidID(t *testing.T) {
 	svc := userservice.New(nil)
 
-	_, err := svc.GetUser(0)
+	_, err := svc.GetUser(context.Background(), 0)
 	if err == nil || err.Error() != "invalid user ID" {
 		t.Errorf("expected 'invalid user ID' error, got %v", err)
 	}
@@ -78,28 +231,722 @@ func TestGetUser_InvalidID(t *testing.T) {
 
 func TestGetUser_NotFound(t *testing.T) {
 	mockStore := &MockUserStore{
-		GetUserFn: func(id int) (models.User, error) {
+		GetUserFn: func(ctx context.Context, id int) (models.User, error) {
 			return models.User{}, sql.ErrNoRows
 		},
 	}
 	svc := userservice.New(mockStore)
 
-	_, err := svc.GetUser(1)
+	_, err := svc.GetUser(context.Background(), 1)
 	if err == nil || err.Error() != "user not found" {
 		t.Errorf("expected 'user not found' error, got %v", err)
 	}
+	if !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
 }
 
 func TestGetUser_OtherError(t *testing.T) {
 	mockStore := &MockUserStore{
-		GetUserFn: func(id int) (models.User, error) {
+		GetUserFn: func(ctx context.Context, id int) (models.User, error) {
 			return models.User{}, errors.New("some db error")
 		},
 	}
 	svc := userservice.New(mockStore)
 
-	_, err := svc.GetUser(1)
+	_, err := svc.GetUser(context.Background(), 1)
 	if err == nil || err.Error() != "some db error" {
 		t.Errorf("expected 'some db error', got %v", err)
 	}
 }
+
+func TestGetUserDetail(t *testing.T) {
+	alice := models.User{ID: 1, Name: "Alice"}
+	tasks := []models.Task{{ID: 1, UserID: 1}, {ID: 2, Completed: true, UserID: 1}, {ID: 3, UserID: 1}}
+	mockStore := &MockUserStore{
+		GetUserFn: usersByID(alice),
+		GetUserStatsFn: func(ctx context.Context, id int) (models.User, models.UserStats, error) {
+			return alice, models.UserStats{Open: 4, Completed: 5}, nil
+		},
+		GetUserTasksFn: func(ctx context.Context, id int) (models.User, []models.Task, error) {
+			if id != 1 {
+				return models.User{}, nil, sql.ErrNoRows
+			}
+			return alice, tasks, nil
+		},
+	}
+	svc := userservice.New(mockStore)
+
+	d, err := svc.GetUserDetail(userCtx(1), 1, models.UserInclude{})
+	if err != nil || d.User != alice || d.Tasks != nil || d.Stats != nil {
+		t.Errorf("expected the bare user, got %+v, err: %v", d, err)
+	}
+
+	d, err = svc.GetUserDetail(userCtx(1), 1, models.UserInclude{Stats: true})
+	if err != nil || d.Stats == nil || *d.Stats != (models.UserStats{Open: 4, Completed: 5}) || d.Tasks != nil {
+		t.Errorf("expected stats from the store, got %+v, err: %v", d, err)
+	}
+
+	// Stats are counted from the tasks already loaded
+	d, err = svc.GetUserDetail(userCtx(1), 1, models.UserInclude{Tasks: true, Stats: true})
+	if err != nil || len(d.Tasks) != 3 || d.Stats == nil || *d.Stats != (models.UserStats{Open: 2, Completed: 1}) {
+		t.Errorf("expected tasks and counted stats, got %+v, err: %v", d, err)
+	}
+
+	if _, err := svc.GetUserDetail(adminCtx, 9, models.UserInclude{Tasks: true}); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if _, err := svc.GetUserDetail(adminCtx, 0, models.UserInclude{Tasks: true}); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+
+	// Other users see the bare user, but not their tasks or stats
+	if _, err := svc.GetUserDetail(userCtx(2), 1, models.UserInclude{}); err != nil {
+		t.Errorf("expected the bare user, got %v", err)
+	}
+	for _, inc := range []models.UserInclude{{Tasks: true}, {Stats: true}} {
+		if _, err := svc.GetUserDetail(userCtx(2), 1, inc); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("%+v: expected not found, got %v", inc, err)
+		}
+	}
+	if _, err := svc.GetUserDetail(adminCtx, 1, models.UserInclude{Tasks: true}); err != nil {
+		t.Errorf("expected an admin to see the tasks, got %v", err)
+	}
+}
+
+func TestCreateUser_InvalidName(t *testing.T) {
+	mockStore := &MockUserStore{
+		GetUserByNameFn: func(ctx context.Context, name string) (models.User, error) {
+			if name == "Bob" {
+				return models.User{ID: 2, Name: "Bob", DeletedAt: time.Now()}, nil
+			}
+			return models.User{ID: 1, Name: "Alice"}, nil
+		},
+	}
+	svc := userservice.New(mockStore)
+
+	tests := []struct {
+		name    string
+		wantErr string
+		kind    error
+	}{
+		{"   ", "user name cannot be empty", models.ErrValidation},
+		{strings.Repeat("a", 101), "user name cannot be longer than 100 characters", models.ErrValidation},
+		{"Alice", "user name already taken", models.ErrConflict},
+		{"Bob", "user name taken by a user in the trash", models.ErrConflict},
+	}
+
+	for _, tc := range tests {
+		_, err := svc.CreateUser(context.Background(), models.User{Name: tc.name})
+		if err == nil || err.Error() != tc.wantErr || !errors.Is(err, tc.kind) {
+			t.Errorf("%q: expected %q, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestCreateUser_Password(t *testing.T) {
+	var hash string
+	tx := &MockTx{}
+	mockStore := &MockUserStore{
+		CreateUserFn: func(ctx context.Context, u models.User) (models.User, error) {
+			if u.Password != "" {
+				t.Error("the plain password reached the store")
+			}
+			u.ID = 4
+			return u, nil
+		},
+		SetPasswordHashFn: func(ctx context.Context, id int, h string) error {
+			if id != 4 || !inTx(ctx) {
+				t.Errorf("SetPasswordHash(%d) outside the unit of work", id)
+			}
+			hash = h
+			return nil
+		},
+	}
+	svc := userservice.New(mockStore)
+	svc.Tx = tx
+
+	created, err := svc.CreateUser(context.Background(), models.User{Name: "Alice", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if created.Password != "" {
+		t.Error("expected the password not to be returned")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte("correct horse")) != nil {
+		t.Errorf("expected a bcrypt hash of the password, got %q", hash)
+	}
+	if tx.Runs != 1 {
+		t.Errorf("expected one unit of work, got %d", tx.Runs)
+	}
+
+	for _, pw := range []string{"short", strings.Repeat("a", 73)} {
+		if _, err := svc.CreateUser(context.Background(), models.User{Name: "Bob", Password: pw}); !errors.Is(err, models.ErrValidation) {
+			t.Errorf("%d byte password: expected a validation error, got %v", len(pw), err)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	mockStore := &MockUserStore{
+		GetPasswordHashFn: func(ctx context.Context, name string) (models.Principal, string, error) {
+			switch name {
+			case "Alice":
+				return models.Principal{UserID: 1, Name: "Alice", Role: models.RoleAdmin}, string(hash), nil
+			case "Bob":
+				return models.Principal{UserID: 2, Name: "Bob", Role: models.RoleUser}, "", nil
+			case "Broken":
+				return models.Principal{}, "", errors.New("db down")
+			}
+			return models.Principal{}, "", sql.ErrNoRows
+		},
+	}
+	svc := userservice.New(mockStore)
+
+	p, err := svc.Authenticate(context.Background(), " Alice ", "correct horse")
+	if err != nil || p.UserID != 1 || p.Role != models.RoleAdmin {
+		t.Errorf("unexpected result: %+v, err: %v", p, err)
+	}
+
+	tests := []struct{ name, password string }{
+		{"Alice", "wrong horse"},
+		{"Bob", ""},
+		{"Bob", "not a real password"},
+		{"Nobody", "correct horse"},
+	}
+	for _, tc := range tests {
+		_, err := svc.Authenticate(context.Background(), tc.name, tc.password)
+		if !errors.Is(err, models.ErrUnauthorized) || err.Error() != "invalid name or password" {
+			t.Errorf("%s/%q: expected unauthorized, got %v", tc.name, tc.password, err)
+		}
+	}
+
+	if _, err := svc.Authenticate(context.Background(), "Broken", "x"); errors.Is(err, models.ErrUnauthorized) || err == nil {
+		t.Errorf("expected the store error, got %v", err)
+	}
+}
+
+func TestViewUsers(t *testing.T) {
+	mockStore := &MockUserStore{
+		ViewUsersFn: func(ctx context.Context, f models.UserFilter) ([]models.User, int, error) {
+			if f.Limit != 20 || f.Offset != 0 {
+				t.Errorf("expected the default page, got %+v", f)
+			}
+			return []models.User{{ID: 1, Name: "Alice"}}, 1, nil
+		},
+	}
+	svc := userservice.New(mockStore)
+
+	page, err := svc.ViewUsers(adminCtx, models.UserFilter{})
+	if err != nil || len(page.Users) != 1 || page.Total != 1 || page.Limit != 20 {
+		t.Errorf("unexpected page: %+v, err: %v", page, err)
+	}
+
+	for _, f := range []models.UserFilter{{Limit: 101}, {Limit: -1}, {Offset: -1}} {
+		if _, err := svc.ViewUsers(adminCtx, f); !errors.Is(err, models.ErrValidation) {
+			t.Errorf("%+v: expected a validation error, got %v", f, err)
+		}
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	var saved models.User
+	mockStore := &MockUserStore{
+		GetUserForUpdateFn: usersByID(models.User{ID: 1, Name: "Alice"}, models.User{ID: 2, Name: "Bob"}),
+		GetUserByNameFn: func(ctx context.Context, name string) (models.User, error) {
+			if name == "Bob" {
+				return models.User{ID: 2, Name: "Bob"}, nil
+			}
+			if name == "Alice" {
+				return models.User{ID: 1, Name: "Alice"}, nil
+			}
+			return models.User{}, sql.ErrNoRows
+		},
+		UpdateUserFn: func(ctx context.Context, u models.User) error {
+			saved = u
+			return nil
+		},
+	}
+	svc := userservice.New(mockStore)
+
+	updated, err := svc.UpdateUser(adminCtx, 1, models.User{ID: 9, Name: "Alicia"})
+	if err != nil || updated != (models.User{ID: 1, Name: "Alicia"}) || saved != updated {
+		t.Errorf("unexpected result: %+v, saved %+v, err: %v", updated, saved, err)
+	}
+
+	// Keeping your own name is not a conflict
+	if _, err := svc.UpdateUser(adminCtx, 1, models.User{Name: "Alice"}); err != nil {
+		t.Errorf("expected no error when keeping the name, got %v", err)
+	}
+
+	if _, err := svc.UpdateUser(adminCtx, 1, models.User{Name: "Bob"}); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+	if _, err := svc.UpdateUser(adminCtx, 7, models.User{Name: "Zed"}); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestUpdateUser_Retried(t *testing.T) {
+	name := "Alicia"
+	updates := map[string]func(svc *userservice.Service) (models.User, error){
+		"update": func(svc *userservice.Service) (models.User, error) {
+			return svc.UpdateUser(adminCtx, 1, models.User{Name: "Alicia"})
+		},
+		"patch": func(svc *userservice.Service) (models.User, error) {
+			return svc.PatchUser(adminCtx, 1, models.UserPatch{Name: &name})
+		},
+	}
+	for desc, update := range updates {
+		// The first attempt deadlocks, the second goes through
+		var attempts int
+		mockStore := &MockUserStore{
+			GetUserForUpdateFn: usersByID(models.User{ID: 1, Name: "Alice"}),
+			UpdateUserFn: func(ctx context.Context, u models.User) error {
+				if attempts++; attempts == 1 {
+					return errRetry
+				}
+				return nil
+			},
+		}
+		svc := userservice.New(mockStore)
+		tx := &RetryTx{}
+		svc.Tx = tx
+
+		want := models.User{ID: 1, Name: "Alicia"}
+		if got, err := update(svc); err != nil || got != want {
+			t.Errorf("%s: expected %+v, got %+v, err: %v", desc, want, got, err)
+		}
+		if tx.Runs != 2 {
+			t.Errorf("%s: expected 2 attempts, got %d", desc, tx.Runs)
+		}
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	mockStore := &MockUserStore{
+		GetUserForUpdateFn: usersByID(models.User{ID: 1, Name: "Alice"}),
+		UpdateUserFn:       func(ctx context.Context, u models.User) error { return nil },
+	}
+	svc := userservice.New(mockStore)
+
+	name := "Alicia"
+	updated, err := svc.PatchUser(adminCtx, 1, models.UserPatch{Name: &name})
+	if err != nil || updated.Name != "Alicia" {
+		t.Errorf("unexpected result: %+v, err: %v", updated, err)
+	}
+
+	// An empty patch changes nothing
+	unchanged, err := svc.PatchUser(adminCtx, 1, models.UserPatch{})
+	if err != nil || unchanged.Name != "Alice" {
+		t.Errorf("unexpected result: %+v, err: %v", unchanged, err)
+	}
+}
+
+func TestPatchUser_Password(t *testing.T) {
+	var saved models.User
+	var hash string
+	mockStore := &MockUserStore{
+		GetUserForUpdateFn: usersByID(models.User{ID: 1, Name: "Alice"}),
+		UpdateUserFn: func(ctx context.Context, u models.User) error {
+			saved = u
+			return nil
+		},
+		SetPasswordHashFn: func(ctx context.Context, id int, h string) error {
+			hash = h
+			return nil
+		},
+	}
+	svc := userservice.New(mockStore)
+
+	pw := "correct horse"
+	updated, err := svc.PatchUser(adminCtx, 1, models.UserPatch{Password: &pw})
+	if err != nil || updated != (models.User{ID: 1, Name: "Alice"}) || saved.Name != "Alice" {
+		t.Errorf("unexpected result: %+v, saved %+v, err: %v", updated, saved, err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw)) != nil {
+		t.Errorf("expected the new password to be stored, got %q", hash)
+	}
+
+	empty := ""
+	if _, err := svc.PatchUser(adminCtx, 1, models.UserPatch{Password: &empty}); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
+
+func TestPatchUser_OtherUsersPassword(t *testing.T) {
+	var hashes int
+	mockStore := &MockUserStore{
+		GetUserForUpdateFn: usersByID(models.User{ID: 1, Name: "Alice"}, models.User{ID: 2, Name: "Bob"}),
+		UpdateUserFn:       func(ctx context.Context, u models.User) error { return nil },
+		SetPasswordHashFn: func(ctx context.Context, id int, hash string) error {
+			hashes++
+			return nil
+		},
+	}
+	svc := userservice.New(mockStore)
+
+	// Bob cannot take over Alice's account by setting its password
+	pw := "correct horse"
+	if _, err := svc.PatchUser(userCtx(2), 1, models.UserPatch{Password: &pw}); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Patch: expected not found, got %v", err)
+	}
+	if _, err := svc.UpdateUser(userCtx(2), 1, models.User{Name: "Alice", Password: pw}); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Update: expected not found, got %v", err)
+	}
+	if hashes != 0 {
+		t.Fatalf("expected no password stored, got %d", hashes)
+	}
+
+	// but can change their own
+	if _, err := svc.PatchUser(userCtx(2), 2, models.UserPatch{Password: &pw}); err != nil || hashes != 1 {
+		t.Errorf("expected bob's own password stored, got %d, err: %v", hashes, err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	type call struct{ id, reassignTo int }
+
+	tests := []struct {
+		desc     string
+		id       int
+		delete   models.UserDelete
+		tasks    int
+		wantCall *call
+		wantKind error
+	}{
+		{desc: "reject without tasks", id: 1, wantCall: &call{1, 0}},
+		{desc: "reject with tasks", id: 1, tasks: 2, wantKind: models.ErrConflict},
+		{desc: "cascade", id: 1, tasks: 2, delete: models.UserDelete{Policy: models.DeleteCascade}, wantCall: &call{1, 0}},
+		{desc: "reassign", id: 1, delete: models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 2}, wantCall: &call{1, 2}},
+		{desc: "reassign without target", id: 1, delete: models.UserDelete{Policy: models.DeleteReassign}, wantKind: models.ErrValidation},
+		{desc: "reassign to self", id: 1, delete: models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 1}, wantKind: models.ErrValidation},
+		{desc: "reassign to unknown user", id: 1, delete: models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 9}, wantKind: models.ErrValidation},
+		{desc: "unknown policy", id: 1, delete: models.UserDelete{Policy: "archive"}, wantKind: models.ErrValidation},
+		{desc: "unknown user", id: 9, wantKind: models.ErrNotFound},
+	}
+
+	for _, tc := range tests {
+		var got *call
+		mockStore := &MockUserStore{
+			GetUserForUpdateFn: usersByID(models.User{ID: 1, Name: "Alice"}, models.User{ID: 2, Name: "Bob"}),
+			GetUserForShareFn:  usersByID(models.User{ID: 1, Name: "Alice"}, models.User{ID: 2, Name: "Bob"}),
+			CountTasksFn:       func(ctx context.Context, id int) (int, error) { return tc.tasks, nil },
+			DeleteUserFn: func(ctx context.Context, id, reassignTo int) error {
+				got = &call{id, reassignTo}
+				return nil
+			},
+		}
+		svc := userservice.New(mockStore)
+
+		err := svc.DeleteUser(adminCtx, tc.id, tc.delete)
+		if tc.wantKind != nil {
+			if !errors.Is(err, tc.wantKind) {
+				t.Errorf("%s: expected %v, got %v", tc.desc, tc.wantKind, err)
+			}
+			if got != nil {
+				t.Errorf("%s: store delete should not be called", tc.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.desc, err)
+		}
+		if got == nil || *got != *tc.wantCall {
+			t.Errorf("%s: expected store delete %+v, got %+v", tc.desc, tc.wantCall, got)
+		}
+	}
+}
+
+func TestDeleteUser_DefaultPolicy(t *testing.T) {
+	deleted := false
+	mockStore := &MockUserStore{
+		GetUserForUpdateFn: usersByID(models.User{ID: 1, Name: "Alice"}),
+		DeleteUserFn: func(ctx context.Context, id, reassignTo int) error {
+			deleted = true
+			return nil
+		},
+	}
+	svc := userservice.New(mockStore)
+	svc.DeletePolicy = models.DeleteCascade
+
+	if err := svc.DeleteUser(adminCtx, 1, models.UserDelete{}); err != nil || !deleted {
+		t.Errorf("expected the configured cascade policy to delete, got %v", err)
+	}
+}
+
+func TestDeleteUser_InTx(t *testing.T) {
+	users := usersByID(models.User{ID: 1, Name: "Alice"}, models.User{ID: 2, Name: "Bob"})
+	locked := map[int]bool{}
+	lock := func(ctx context.Context, id int) (models.User, error) {
+		locked[id] = inTx(ctx)
+		return users(ctx, id)
+	}
+	var deleted bool
+	mockStore := &MockUserStore{
+		GetUserForUpdateFn: lock,
+		GetUserForShareFn:  lock,
+		DeleteUserFn: func(ctx context.Context, id, reassignTo int) error {
+			deleted = inTx(ctx)
+			return nil
+		},
+	}
+	svc := userservice.New(mockStore)
+	tx := &MockTx{}
+	svc.Tx = tx
+
+	err := svc.DeleteUser(adminCtx, 1, models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 2})
+	if err != nil || tx.Runs != 1 {
+		t.Fatalf("expected one unit of work, got %d, err: %v", tx.Runs, err)
+	}
+	if !locked[1] || !locked[2] || !deleted {
+		t.Errorf("expected both users locked and the delete in the unit of work, got locks %v, deleted %v", locked, deleted)
+	}
+}
+
+func TestRestoreUser(t *testing.T) {
+	var restored bool
+	mockStore := &MockUserStore{
+		GetDeletedUserForUpdateFn: func(ctx context.Context, id int) (models.User, error) {
+			if id != 1 {
+				return models.User{}, sql.ErrNoRows
+			}
+			return models.User{ID: 1, Name: "Alice", DeletedAt: time.Now()}, nil
+		},
+		RestoreUserFn: func(ctx context.Context, id int) error {
+			restored = inTx(ctx)
+			return nil
+		},
+	}
+	svc := userservice.New(mockStore)
+	svc.Tx = &MockTx{}
+
+	u, err := svc.RestoreUser(adminCtx, 1)
+	if err != nil || !restored || u.Name != "Alice" || !u.DeletedAt.IsZero() {
+		t.Errorf("expected Alice restored in the unit of work, got %+v, err: %v", u, err)
+	}
+	if _, err := svc.RestoreUser(adminCtx, 2); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected a user not in the trash to be not found, got %v", err)
+	}
+	if _, err := svc.RestoreUser(adminCtx, 0); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected an invalid ID to be rejected, got %v", err)
+	}
+}
+
+func TestUserAccess(t *testing.T) {
+	var writes int
+	mockStore := &MockUserStore{
+		GetUserForUpdateFn: usersByID(models.User{ID: 1, Name: "Alice"}, models.User{ID: 2, Name: "Bob"}),
+		GetUserForShareFn:  usersByID(models.User{ID: 1, Name: "Alice"}, models.User{ID: 2, Name: "Bob"}),
+		ViewUsersFn: func(ctx context.Context, f models.UserFilter) ([]models.User, int, error) {
+			return []models.User{}, 0, nil
+		},
+		UpdateUserFn: func(ctx context.Context, u models.User) error {
+			writes++
+			return nil
+		},
+		CountTasksFn: func(ctx context.Context, id int) (int, error) { return 0, nil },
+		DeleteUserFn: func(ctx context.Context, id, reassignTo int) error {
+			writes++
+			return nil
+		},
+		GetDeletedUserForUpdateFn: usersByID(models.User{ID: 1, Name: "Alice", DeletedAt: time.Now()}),
+		RestoreUserFn: func(ctx context.Context, id int) error {
+			writes++
+			return nil
+		},
+	}
+	svc := userservice.New(mockStore)
+	bob := userCtx(2)
+
+	tests := []struct {
+		desc     string
+		call     func() error
+		wantKind error
+	}{
+		{"rename another user", func() error {
+			_, err := svc.UpdateUser(bob, 1, models.User{Name: "Mallory"})
+			return err
+		}, models.ErrNotFound},
+		{"delete another user", func() error { return svc.DeleteUser(bob, 1, models.UserDelete{Policy: models.DeleteCascade}) }, models.ErrNotFound},
+		{"restore another user", func() error {
+			_, err := svc.RestoreUser(bob, 1)
+			return err
+		}, models.ErrNotFound},
+		{"give own tasks to another user", func() error {
+			return svc.DeleteUser(bob, 2, models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 1})
+		}, models.ErrForbidden},
+		{"list users", func() error {
+			_, err := svc.ViewUsers(bob, models.UserFilter{})
+			return err
+		}, models.ErrForbidden},
+		{"list the trash", func() error {
+			_, err := svc.ViewUsers(bob, models.UserFilter{Deleted: true})
+			return err
+		}, models.ErrForbidden},
+		{"without a principal", func() error {
+			_, err := svc.UpdateUser(context.Background(), 1, models.User{Name: "Alicia"})
+			return err
+		}, models.ErrUnauthorized},
+	}
+	for _, tc := range tests {
+		if err := tc.call(); !errors.Is(err, tc.wantKind) {
+			t.Errorf("%s: expected %v, got %v", tc.desc, tc.wantKind, err)
+		}
+	}
+	if writes != 0 {
+		t.Errorf("expected no refused change to reach the store, got %d writes", writes)
+	}
+
+	// Users may change and delete themselves
+	if _, err := svc.UpdateUser(bob, 2, models.User{Name: "Robert"}); err != nil {
+		t.Errorf("expected bob to rename themselves, got %v", err)
+	}
+	if err := svc.DeleteUser(bob, 2, models.UserDelete{}); err != nil {
+		t.Errorf("expected bob to delete themselves, got %v", err)
+	}
+}
+
+// MockAuditor keeps the entries a service records, and whether each was
+// recorded in a unit of work.
+type MockAuditor struct {
+	Entries []models.AuditEntry
+	InTx    []bool
+}
+
+func (m *MockAuditor) AppendAudit(ctx context.Context, e models.AuditEntry) error {
+	m.Entries = append(m.Entries, e)
+	m.InTx = append(m.InTx, inTx(ctx))
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	mockStore := &MockUserStore{
+		CreateUserFn: func(ctx context.Context, u models.User) (models.User, error) {
+			u.ID = 1
+			return u, nil
+		},
+		GetUserForUpdateFn: usersByID(models.User{ID: 1, Name: "Alice"}),
+		UpdateUserFn:       func(ctx context.Context, u models.User) error { return nil },
+		SetPasswordHashFn:  func(ctx context.Context, id int, hash string) error { return nil },
+		CountTasksFn:       func(ctx context.Context, id int) (int, error) { return 0, nil },
+		DeleteUserFn:       func(ctx context.Context, id, reassignTo int) error { return nil },
+		GetDeletedUserForUpdateFn: func(ctx context.Context, id int) (models.User, error) {
+			return models.User{ID: id, Name: "Alicia", DeletedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)}, nil
+		},
+		RestoreUserFn: func(ctx context.Context, id int) error { return nil },
+	}
+	a := &MockAuditor{}
+	svc := userservice.New(mockStore)
+	svc.Tx = &MockTx{}
+	svc.Audit = a
+	admin := models.Principal{Name: "ops", Role: models.RoleAdmin}
+	ctx := models.WithRequestID(models.WithPrincipal(context.Background(), admin), "req-1")
+
+	if _, err := svc.CreateUser(ctx, models.User{Name: "Alice", Password: "correct horse"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	name, pw := "Alicia", "battery staple"
+	if _, err := svc.PatchUser(ctx, 1, models.UserPatch{Name: &name}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.PatchUser(ctx, 1, models.UserPatch{Password: &pw}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.DeleteUser(ctx, 1, models.UserDelete{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.RestoreUser(ctx, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []struct {
+		action  models.AuditAction
+		changes map[string]models.AuditChange
+	}{
+		{models.AuditCreate, map[string]models.AuditChange{
+			"id": {After: 1.0}, "name": {After: "Alice"}, "password": {After: models.Redacted},
+		}},
+		{models.AuditUpdate, map[string]models.AuditChange{
+			"name": {Before: "Alice", After: "Alicia"},
+		}},
+		// A new password is all that changed, and only that it did is kept
+		{models.AuditUpdate, map[string]models.AuditChange{
+			"password": {After: models.Redacted},
+		}},
+		{models.AuditDelete, map[string]models.AuditChange{
+			"id": {Before: 1.0}, "name": {Before: "Alice"},
+		}},
+		{models.AuditRestore, map[string]models.AuditChange{
+			"deleted_at": {Before: "2025-03-01T00:00:00Z"},
+		}},
+	}
+	if len(a.Entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), a.Entries)
+	}
+	for i, w := range want {
+		e := a.Entries[i]
+		if e.Action != w.action || e.Entity != models.AuditUser || e.EntityID != 1 || e.ActorID != 0 || e.Actor != "ops" || e.RequestID != "req-1" || !a.InTx[i] {
+			t.Errorf("entry %d: expected %s of user 1 by ops in the unit of work of req-1, got %+v", i, w.action, e)
+		}
+		if !reflect.DeepEqual(e.Changes, w.changes) {
+			t.Errorf("entry %d: expected changes %v, got %v", i, w.changes, e.Changes)
+		}
+	}
+}
+
+func TestAudit_Reassign(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	open := models.Task{ID: 3, Task: "Write docs", UserID: 1, Version: 2, UpdatedAt: day}
+	trashed := models.Task{ID: 5, Task: "Old", UserID: 1, Version: 4, UpdatedAt: day, DeletedAt: day}
+	tasks := map[int][]models.Task{1: {open, trashed}, 2: {{ID: 1, UserID: 2}}}
+	moved := false
+	mockStore := &MockUserStore{
+		GetUserForUpdateFn: usersByID(models.User{ID: 1, Name: "Alice"}),
+		GetUserForShareFn:  usersByID(models.User{ID: 2, Name: "Bob"}),
+		LockUserTasksFn: func(ctx context.Context, id int) ([]models.Task, error) {
+			return tasks[id], nil
+		},
+		DeleteUserFn: func(ctx context.Context, id, reassignTo int) error {
+			for _, t := range tasks[id] {
+				t.UserID, t.Version, t.UpdatedAt = reassignTo, t.Version+1, day.Add(time.Hour)
+				tasks[reassignTo] = append(tasks[reassignTo], t)
+			}
+			delete(tasks, id)
+			moved = true
+			return nil
+		},
+	}
+	a := &MockAuditor{}
+	svc := userservice.New(mockStore)
+	svc.Tx = &MockTx{}
+	svc.Audit = a
+
+	if err := svc.DeleteUser(adminCtx, 1, models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 2}); err != nil || !moved {
+		t.Fatalf("expected the tasks moved, got moved %v, err: %v", moved, err)
+	}
+
+	// One update of each task moved, those in the trash included, then the
+	// delete of the user
+	if len(a.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", a.Entries)
+	}
+	for i, w := range []struct{ id, version int }{{3, 2}, {5, 4}} {
+		e := a.Entries[i]
+		want := map[string]models.AuditChange{
+			"user_id":    {Before: 1.0, After: 2.0},
+			"version":    {Before: float64(w.version), After: float64(w.version + 1)},
+			"updated_at": {Before: "2025-03-01T00:00:00Z", After: "2025-03-01T01:00:00Z"},
+		}
+		if e.Action != models.AuditUpdate || e.Entity != models.AuditTask || e.EntityID != w.id || !a.InTx[i] {
+			t.Errorf("entry %d: expected an update of task %d in the unit of work, got %+v", i, w.id, e)
+		}
+		if !reflect.DeepEqual(e.Changes, want) {
+			t.Errorf("entry %d: expected changes %v, got %v", i, want, e.Changes)
+		}
+	}
+	if e := a.Entries[2]; e.Action != models.AuditDelete || e.Entity != models.AuditUser || e.EntityID != 1 {
+		t.Errorf("expected the delete of user 1 last, got %+v", e)
+	}
+}
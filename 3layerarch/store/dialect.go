@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Dialect is how one database differs in the SQL the stores run. The stores
+// write their queries for MySQL, and the dialect rewrites them.
+type Dialect struct {
+	// Name is the database, as it appears in the scheme of DB_URL.
+	Name string
+	// Numbered placeholders are written $1, $2, ... instead of ?.
+	Numbered bool
+	// Returning inserts report the new ID with RETURNING id, for drivers
+	// that have no LastInsertId.
+	Returning bool
+	// ForUpdate and ForShare end a locking read. They are empty for
+	// databases without row locks, whose units of work never overlap.
+	ForUpdate string
+	ForShare  string
+}
+
+var (
+	MySQL = Dialect{Name: "mysql", ForUpdate: " FOR UPDATE", ForShare: " FOR SHARE"}
+	// Postgres folds the upper case table names of the queries to lower
+	// case, as it does those of its migrations.
+	Postgres = Dialect{Name: "postgres", Numbered: true, Returning: true, ForUpdate: " FOR UPDATE", ForShare: " FOR SHARE"}
+	// SQLite units of work take the write lock when they begin, see
+	// sqlitestore.Open.
+	SQLite = Dialect{Name: "sqlite"}
+)
+
+// Rebind rewrites the ? placeholders of query as d writes them. The queries
+// of the stores have no ? anywhere else.
+func (d Dialect) Rebind(query string) string {
+	if !d.Numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Bind returns conn with its queries rebound for d.
+func (d Dialect) Bind(conn DBTX) DBTX {
+	if !d.Numbered {
+		return conn
+	}
+	return rebound{conn: conn, d: d}
+}
+
+type rebound struct {
+	conn DBTX
+	d    Dialect
+}
+
+func (r rebound) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.conn.ExecContext(ctx, r.d.Rebind(query), args...)
+}
+
+func (r rebound) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.conn.QueryContext(ctx, r.d.Rebind(query), args...)
+}
+
+func (r rebound) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return r.conn.QueryRowContext(ctx, r.d.Rebind(query), args...)
+}
+
+// Insert runs the INSERT query on conn and returns the ID of the row it
+// added.
+func (d Dialect) Insert(ctx context.Context, conn DBTX, query string, args ...any) (int64, error) {
+	if d.Returning {
+		var id int64
+		err := conn.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	res, err := conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
@@ -0,0 +1,124 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"3layerarch/models"
+)
+
+// GetParentID returns the ID of the parent of the task with id, 0 for a
+// top-level task, or sql.ErrNoRows. Tasks in the trash are found too, as
+// they keep their place in the tree.
+func (s *Store) GetParentID(ctx context.Context, id int) (int, error) {
+	var t models.Task
+	ok := false
+	s.read(func(d *data) { t, ok = d.tasks[id] })
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return t.ParentID, nil
+}
+
+// Subtasks returns the subtasks of the task with parentID ordered by id.
+// Subtasks in the trash are left out.
+func (s *Store) Subtasks(ctx context.Context, parentID int) ([]models.Task, error) {
+	tasks := []models.Task{}
+	s.read(func(d *data) {
+		for _, t := range d.tasks {
+			if t.ParentID == parentID && t.DeletedAt.IsZero() {
+				tasks = append(tasks, t)
+			}
+		}
+	})
+	slices.SortFunc(tasks, taskOrder("id"))
+	return tasks, nil
+}
+
+// Blockers returns the tasks blocking the task with taskID ordered by id.
+// Blockers in the trash block nothing and are left out.
+func (s *Store) Blockers(ctx context.Context, taskID int) ([]models.Task, error) {
+	tasks := []models.Task{}
+	s.read(func(d *data) {
+		for dep := range d.deps {
+			if t := d.tasks[dep.blocker]; dep.task == taskID && t.DeletedAt.IsZero() {
+				tasks = append(tasks, t)
+			}
+		}
+	})
+	slices.SortFunc(tasks, taskOrder("id"))
+	return tasks, nil
+}
+
+// BlockerIDs returns the IDs of every task blocking the task with taskID,
+// in the trash or not, in ascending order.
+func (s *Store) BlockerIDs(ctx context.Context, taskID int) ([]int, error) {
+	ids := []int{}
+	s.read(func(d *data) {
+		for dep := range d.deps {
+			if dep.task == taskID {
+				ids = append(ids, dep.blocker)
+			}
+		}
+	})
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// AddDependency makes the task with blockerID block the one with taskID.
+// Adding a dependency that exists already changes nothing; a task that does
+// not exist fails, as the foreign keys do in MySQL.
+func (s *Store) AddDependency(ctx context.Context, taskID, blockerID int) error {
+	return s.write(ctx, func(d *data) error {
+		for _, id := range []int{taskID, blockerID} {
+			if _, ok := d.tasks[id]; !ok {
+				return fmt.Errorf("memstore: task %d does not exist", id)
+			}
+		}
+		d.deps[dependency{taskID, blockerID}] = struct{}{}
+		return nil
+	})
+}
+
+// RemoveDependency stops the task with blockerID blocking the one with
+// taskID, if it does.
+func (s *Store) RemoveDependency(ctx context.Context, taskID, blockerID int) error {
+	return s.write(ctx, func(d *data) error {
+		delete(d.deps, dependency{taskID, blockerID})
+		return nil
+	})
+}
+
+// TaskGraph returns the dependencies between tasks outside the trash, and
+// those tasks, ordered by ID. With a userID only dependencies between that
+// user's tasks are included.
+func (s *Store) TaskGraph(ctx context.Context, userID *int) (models.TaskGraph, error) {
+	g := models.TaskGraph{Tasks: []models.Task{}, Dependencies: []models.Dependency{}}
+	s.read(func(d *data) {
+		seen := map[int]bool{}
+		for dep := range d.deps {
+			t, b := d.tasks[dep.task], d.tasks[dep.blocker]
+			if !t.DeletedAt.IsZero() || !b.DeletedAt.IsZero() {
+				continue
+			}
+			if userID != nil && (t.UserID != *userID || b.UserID != *userID) {
+				continue
+			}
+			g.Dependencies = append(g.Dependencies, models.Dependency{TaskID: dep.task, BlockerID: dep.blocker})
+			for _, t := range []models.Task{t, b} {
+				if !seen[t.ID] {
+					seen[t.ID] = true
+					g.Tasks = append(g.Tasks, t)
+				}
+			}
+		}
+	})
+	slices.SortFunc(g.Tasks, taskOrder("id"))
+	slices.SortFunc(g.Dependencies, func(a, b models.Dependency) int {
+		return cmp.Or(cmp.Compare(a.TaskID, b.TaskID), cmp.Compare(a.BlockerID, b.BlockerID))
+	})
+	return g, nil
+}
@@ -0,0 +1,22 @@
+// Package sqlitestore opens SQLite databases for the task and user stores,
+// for local development and tests that should not need a database server.
+package sqlitestore
+
+import (
+	"database/sql"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// Open opens the SQLite database at path, creating the file if need be.
+// Foreign keys are enforced, and every transaction takes the write lock
+// when it begins, so units of work run one at a time and wait up to five
+// seconds for each other. Its queries are written in store.SQLite.
+func Open(path string) (*sql.DB, error) {
+	params := url.Values{
+		"_pragma": {"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"},
+		"_txlock": {"immediate"},
+	}
+	return sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+}
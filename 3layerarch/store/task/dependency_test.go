@@ -0,0 +1,128 @@
+package taskstore_test
+
+import (
+	"3layerarch/models"
+	"3layerarch/store"
+	"3layerarch/store/task"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetParentID(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+
+	mock.ExpectQuery("SELECT parent_id FROM TASKS WHERE id = ? FOR SHARE").
+		WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(1))
+	mock.ExpectQuery("SELECT parent_id FROM TASKS WHERE id = ? FOR SHARE").
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(nil))
+
+	if id, err := repo.GetParentID(context.Background(), 2); err != nil || id != 1 {
+		t.Errorf("expected parent 1, got %d, err: %v", id, err)
+	}
+	if id, err := repo.GetParentID(context.Background(), 1); err != nil || id != 0 {
+		t.Errorf("expected no parent, got %d, err: %v", id, err)
+	}
+}
+
+func TestBlockerIDs(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+
+	// The task is locked before its blockers are read
+	mock.ExpectQuery("SELECT id FROM TASKS WHERE id = ? FOR SHARE").
+		WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("SELECT blocker_id FROM TASK_DEPENDENCIES WHERE task_id = ? ORDER BY blocker_id ASC").
+		WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"blocker_id"}).AddRow(1).AddRow(2))
+
+	ids, err := repo.BlockerIDs(context.Background(), 3)
+	if err != nil || !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("expected blockers [1 2], got %v, err: %v", ids, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestAddDependency(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM TASK_DEPENDENCIES WHERE task_id = ? AND blocker_id = ?").
+		WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO TASK_DEPENDENCIES (task_id, blocker_id) VALUES (?, ?)").
+		WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	// A dependency that exists already is not inserted again
+	mock.ExpectQuery("SELECT COUNT(*) FROM TASK_DEPENDENCIES WHERE task_id = ? AND blocker_id = ?").
+		WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	for range 2 {
+		if err := repo.AddDependency(context.Background(), 2, 1); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestTaskGraph(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT d.task_id, d.blocker_id FROM TASK_DEPENDENCIES d JOIN TASKS t ON t.id = d.task_id JOIN TASKS b ON b.id = d.blocker_id
+		WHERE t.deleted_at IS NULL AND b.deleted_at IS NULL AND t.user_id = ? AND b.user_id = ? ORDER BY d.task_id ASC, d.blocker_id ASC`).
+		WithArgs(2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"task_id", "blocker_id"}).AddRow(3, 1).AddRow(3, 2))
+	mock.ExpectQuery("SELECT "+selectColumns+" FROM TASKS WHERE id IN (?, ?, ?) ORDER BY id ASC").
+		WithArgs(3, 1, 2).
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(1, "Plan", true, 2, nil, "", nil, 1, createdAt, createdAt, createdAt, nil).
+			AddRow(2, "Buy", false, 2, nil, "", nil, 1, createdAt, createdAt, nil, nil).
+			AddRow(3, "Build", false, 2, nil, "", nil, 1, createdAt, createdAt, nil, nil))
+
+	user := 2
+	g, err := repo.TaskGraph(context.Background(), &user)
+	want := []models.Dependency{{TaskID: 3, BlockerID: 1}, {TaskID: 3, BlockerID: 2}}
+	if err != nil || len(g.Tasks) != 3 || !reflect.DeepEqual(g.Dependencies, want) {
+		t.Errorf("expected 3 tasks and dependencies %v, got %+v, err: %v", want, g, err)
+	}
+
+	// Without dependencies there are no tasks to look up
+	mock.ExpectQuery(`SELECT d.task_id, d.blocker_id FROM TASK_DEPENDENCIES d JOIN TASKS t ON t.id = d.task_id JOIN TASKS b ON b.id = d.blocker_id
+		WHERE t.deleted_at IS NULL AND b.deleted_at IS NULL ORDER BY d.task_id ASC, d.blocker_id ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"task_id", "blocker_id"}))
+
+	g, err = repo.TaskGraph(context.Background(), nil)
+	if err != nil || g.Tasks == nil || len(g.Tasks) != 0 || len(g.Dependencies) != 0 {
+		t.Errorf("expected an empty graph, got %+v, err: %v", g, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package taskstore
+
+import (
+	"3layerarch/models"
+	"context"
+	"log"
+)
+
+// CreateLabel inserts l and returns it with the ID the database assigned.
+func (s *Store) CreateLabel(ctx context.Context, l models.Label) (models.Label, error) {
+	id, err := s.dialect.Insert(ctx, s.conn(ctx), "INSERT INTO LABELS (name) VALUES (?)", l.Name)
+	if err != nil {
+		return models.Label{}, err
+	}
+	l.ID = int(id)
+	return l, nil
+}
+
+// GetLabel returns the label with id, or sql.ErrNoRows.
+func (s *Store) GetLabel(ctx context.Context, id int) (models.Label, error) {
+	var l models.Label
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT id, name FROM LABELS WHERE id = ?", id).Scan(&l.ID, &l.Name)
+	return l, err
+}
+
+// GetLabelByName returns the label called name, or sql.ErrNoRows.
+func (s *Store) GetLabelByName(ctx context.Context, name string) (models.Label, error) {
+	var l models.Label
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT id, name FROM LABELS WHERE name = ?", name).Scan(&l.ID, &l.Name)
+	return l, err
+}
+
+// ViewLabels returns every label ordered by name.
+func (s *Store) ViewLabels(ctx context.Context) ([]models.Label, error) {
+	return s.queryLabels(ctx, "SELECT id, name FROM LABELS ORDER BY name ASC, id ASC")
+}
+
+// queryLabels runs a query selecting id and name of labels.
+func (s *Store) queryLabels(ctx context.Context, query string, args ...any) ([]models.Label, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}()
+
+	labels := []models.Label{}
+	for rows.Next() {
+		var l models.Label
+		if err := rows.Scan(&l.ID, &l.Name); err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// UpdateLabel renames the label with l.ID, if there is one.
+func (s *Store) UpdateLabel(ctx context.Context, l models.Label) error {
+	_, err := s.conn(ctx).ExecContext(ctx, "UPDATE LABELS SET name = ? WHERE id = ?", l.Name, l.ID)
+	return err
+}
+
+// DeleteLabel deletes the label with id, which the foreign key takes off
+// every task.
+func (s *Store) DeleteLabel(ctx context.Context, id int) error {
+	_, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM LABELS WHERE id = ?", id)
+	return err
+}
+
+// TaskLabels returns the labels of the task with taskID ordered by name.
+func (s *Store) TaskLabels(ctx context.Context, taskID int) ([]models.Label, error) {
+	return s.queryLabels(ctx, `SELECT l.id, l.name FROM LABELS l JOIN TASK_LABELS tl ON tl.label_id = l.id
+		WHERE tl.task_id = ? ORDER BY l.name ASC, l.id ASC`, taskID)
+}
+
+// AddTaskLabel puts the label with labelID on the task with taskID. Adding
+// a label the task already has changes nothing. The caller must keep the
+// task from changing until the unit of work in ctx ends.
+func (s *Store) AddTaskLabel(ctx context.Context, taskID, labelID int) error {
+	var n int
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM TASK_LABELS WHERE task_id = ? AND label_id = ?", taskID, labelID).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = s.conn(ctx).ExecContext(ctx, "INSERT INTO TASK_LABELS (task_id, label_id) VALUES (?, ?)", taskID, labelID)
+	return err
+}
+
+// RemoveTaskLabel takes the label with labelID off the task with taskID, if
+// it has it.
+func (s *Store) RemoveTaskLabel(ctx context.Context, taskID, labelID int) error {
+	_, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM TASK_LABELS WHERE task_id = ? AND label_id = ?", taskID, labelID)
+	return err
+}
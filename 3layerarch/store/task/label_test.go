@@ -0,0 +1,78 @@
+package taskstore_test
+
+import (
+	"3layerarch/models"
+	"3layerarch/store"
+	"3layerarch/store/task"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreateLabel(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+
+	mock.ExpectExec("INSERT INTO LABELS (name) VALUES (?)").
+		WithArgs("work").WillReturnResult(sqlmock.NewResult(3, 1))
+
+	l, err := repo.CreateLabel(context.Background(), models.Label{Name: "work"})
+	if err != nil || l != (models.Label{ID: 3, Name: "work"}) {
+		t.Errorf("expected the label with its ID, got %v, err: %v", l, err)
+	}
+}
+
+func TestTaskLabels(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+
+	mock.ExpectQuery(`SELECT l.id, l.name FROM LABELS l JOIN TASK_LABELS tl ON tl.label_id = l.id
+		WHERE tl.task_id = ? ORDER BY l.name ASC, l.id ASC`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "home").AddRow(1, "work"))
+
+	labels, err := repo.TaskLabels(context.Background(), 1)
+	want := []models.Label{{ID: 2, Name: "home"}, {ID: 1, Name: "work"}}
+	if err != nil || !reflect.DeepEqual(labels, want) {
+		t.Errorf("expected %v, got %v, err: %v", want, labels, err)
+	}
+}
+
+func TestAddTaskLabel(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM TASK_LABELS WHERE task_id = ? AND label_id = ?").
+		WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO TASK_LABELS (task_id, label_id) VALUES (?, ?)").
+		WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	// A label the task has already is not inserted again
+	mock.ExpectQuery("SELECT COUNT(*) FROM TASK_LABELS WHERE task_id = ? AND label_id = ?").
+		WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	for range 2 {
+		if err := repo.AddTaskLabel(context.Background(), 1, 2); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
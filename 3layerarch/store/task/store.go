@@ -2,32 +2,105 @@ package taskstore
 
 import (
 	"3layerarch/models"
+	"3layerarch/store"
+	"context"
 	"database/sql"
 	"log"
+	"strings"
+	"time"
 )
 
 type Store struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect store.Dialect
 }
 
-func New(db *sql.DB) *Store {
-	return &Store{db: db}
+// New returns the task store of db, whose SQL is written in dialect.
+func New(db *sql.DB, dialect store.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
 }
 
-func (s *Store) CreateTask(t models.Task) error {
-	_, err := s.db.Exec("INSERT INTO TASKS (task, completed, user_id) VALUES (?, ?, ?)", t.Task, t.Completed, t.UserID)
-	return err
+// conn returns what queries for ctx run on: its unit of work, if any.
+func (s *Store) conn(ctx context.Context) store.DBTX {
+	return s.dialect.Bind(store.Conn(ctx, s.db))
+}
+
+// CreateTask inserts t and returns it with the ID the database assigned, at
+// version 1 and created now.
+func (s *Store) CreateTask(ctx context.Context, t models.Task) (models.Task, error) {
+	now := store.Now()
+	t.CreatedAt, t.UpdatedAt, t.CompletedAt = now, now, completedAt(t, now)
+	id, err := s.dialect.Insert(ctx, s.conn(ctx), "INSERT INTO TASKS (task, completed, user_id, parent_id, priority, due_at, version, created_at, updated_at, completed_at) VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?, ?)",
+		t.Task, t.Completed, t.UserID, store.NullID(t.ParentID), t.Priority, store.NullTime(t.DueAt), t.CreatedAt, t.UpdatedAt, store.NullTime(t.CompletedAt))
+	if err != nil {
+		return models.Task{}, err
+	}
+	t.ID = int(id)
+	t.Version = 1
+	return t, nil
+}
+
+// completedAt is when t was completed, given that it is stored at now: the
+// time it has if it was completed already, now if it is only being
+// completed, and zero while it is open.
+func completedAt(t models.Task, now time.Time) time.Time {
+	switch {
+	case !t.Completed:
+		return time.Time{}
+	case t.CompletedAt.IsZero():
+		return now
+	}
+	return t.CompletedAt
 }
 
-func (s *Store) GetTask(id int) (models.Task, error) {
+// taskColumns are the columns scanTask reads, in order.
+const taskColumns = "id, task, completed, user_id, parent_id, priority, due_at, version, created_at, updated_at, completed_at, deleted_at"
+
+// scanTask reads a task selected as taskColumns.
+func scanTask(row interface{ Scan(dest ...any) error }) (models.Task, error) {
 	var t models.Task
-	err := s.db.QueryRow("SELECT id, task, completed, user_id FROM TASKS WHERE id = ?", id).
-		Scan(&t.ID, &t.Task, &t.Completed, &t.UserID)
+	var parentID sql.NullInt64
+	var dueAt, createdAt, updatedAt, completedAt, deletedAt sql.NullTime
+	err := row.Scan(&t.ID, &t.Task, &t.Completed, &t.UserID, &parentID, &t.Priority, &dueAt, &t.Version, &createdAt, &updatedAt, &completedAt, &deletedAt)
+	t.ParentID, t.DueAt = int(parentID.Int64), store.Time(dueAt)
+	t.CreatedAt, t.UpdatedAt = store.Time(createdAt), store.Time(updatedAt)
+	t.CompletedAt, t.DeletedAt = store.Time(completedAt), store.Time(deletedAt)
 	return t, err
 }
 
-func (s *Store) ViewTasks() ([]models.Task, error) {
-	rows, err := s.db.Query("SELECT id, task, completed, user_id FROM TASKS")
+// GetTask returns the task with id, or sql.ErrNoRows if there is none or
+// it is in the trash.
+func (s *Store) GetTask(ctx context.Context, id int) (models.Task, error) {
+	return scanTask(s.conn(ctx).QueryRowContext(ctx, "SELECT "+taskColumns+" FROM TASKS WHERE id = ? AND deleted_at IS NULL", id))
+}
+
+// GetTaskForUpdate returns the task with id like GetTask and locks it
+// against any change until the unit of work in ctx ends.
+func (s *Store) GetTaskForUpdate(ctx context.Context, id int) (models.Task, error) {
+	return scanTask(s.conn(ctx).QueryRowContext(ctx, "SELECT "+taskColumns+" FROM TASKS WHERE id = ? AND deleted_at IS NULL"+s.dialect.ForUpdate, id))
+}
+
+// ViewTasks returns the page of tasks selected by f and the number of tasks
+// that match f's filters across all pages. Tasks are overdue as of now.
+func (s *Store) ViewTasks(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error) {
+	where, args := taskWhere(f, store.Now())
+
+	var total int
+	if err := s.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM TASKS"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + taskColumns + " FROM TASKS" + where + " ORDER BY " + taskOrder(f.Sort) + " LIMIT ? OFFSET ?"
+	tasks, err := s.queryTasks(ctx, query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tasks, total, nil
+}
+
+// queryTasks runs a query selecting taskColumns.
+func (s *Store) queryTasks(ctx context.Context, query string, args ...any) ([]models.Task, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -37,23 +110,178 @@ func (s *Store) ViewTasks() ([]models.Task, error) {
 		}
 	}()
 
-	var tasks []models.Task
+	tasks := []models.Task{}
 	for rows.Next() {
-		var t models.Task
-		if err := rows.Scan(&t.ID, &t.Task, &t.Completed, &t.UserID); err != nil {
+		t, err := scanTask(rows)
+		if err != nil {
 			return nil, err
 		}
 		tasks = append(tasks, t)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 	return tasks, nil
 }
 
-func (s *Store) UpdateTask(id int) error {
-	_, err := s.db.Exec("UPDATE TASKS SET completed = true WHERE id = ?", id)
-	return err
+// taskWhere builds the WHERE clause and its arguments for f's filters, with
+// tasks due before now and still open counting as overdue.
+func taskWhere(f models.TaskFilter, now time.Time) (string, []any) {
+	conds := []string{"deleted_at IS NULL"}
+	if f.Deleted {
+		conds[0] = "deleted_at IS NOT NULL"
+	}
+	var args []any
+	if f.Completed != nil {
+		conds = append(conds, "completed = ?")
+		args = append(args, *f.Completed)
+	}
+	if f.UserID != nil {
+		conds = append(conds, "user_id = ?")
+		args = append(args, *f.UserID)
+	}
+	if f.Priority != "" {
+		conds = append(conds, "priority = ?")
+		args = append(args, f.Priority)
+	}
+	if f.LabelID != nil {
+		conds = append(conds, "id IN (SELECT task_id FROM TASK_LABELS WHERE label_id = ?)")
+		args = append(args, *f.LabelID)
+	}
+	if f.Overdue != nil {
+		if *f.Overdue {
+			conds = append(conds, "due_at < ? AND completed = ?")
+			args = append(args, now, false)
+		} else {
+			conds = append(conds, "(due_at IS NULL OR due_at >= ? OR completed = ?)")
+			args = append(args, now, true)
+		}
+	}
+	if f.Search != "" {
+		// LIKE ignores case in MySQL and SQLite, but not in PostgreSQL
+		conds = append(conds, "LOWER(task) LIKE ? ESCAPE '!'")
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(f.Search))+"%")
+	}
+	for _, c := range []struct {
+		cond string
+		at   time.Time
+	}{
+		{"created_at > ?", f.CreatedAfter},
+		{"created_at < ?", f.CreatedBefore},
+		{"completed_at > ?", f.CompletedAfter},
+		{"completed_at < ?", f.CompletedBefore},
+	} {
+		if !c.at.IsZero() {
+			conds = append(conds, c.cond)
+			args = append(args, c.at.UTC())
+		}
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// likeEscaper escapes the LIKE wildcards so a search matches literally. The
+// escape character is named in the query, as SQLite has no default one.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// taskOrder turns a sort key into an ORDER BY clause. Only known columns are
+// ever written into the query; anything else sorts by id. Ties are broken by
+// id so pages stay stable, and tasks without a due date come last.
+func taskOrder(sort string) string {
+	dir := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		dir = "DESC"
+		sort = sort[1:]
+	}
+	switch sort {
+	case "task", "completed", "user_id":
+		return sort + " " + dir + ", id ASC"
+	case "due_at":
+		return "due_at IS NULL, due_at " + dir + ", id ASC"
+	default:
+		return "id " + dir
+	}
 }
 
-func (s *Store) DeleteTask(id int) error {
-	_, err := s.db.Exec("DELETE FROM TASKS WHERE id = ?", id)
+// UpdateTask replaces the task with t's ID if it is still at t.Version, and
+// returns it at the next version. t's creation and completion times must be
+// those of t.Version. A task at another version is left alone and
+// models.ErrPreconditionFailed returned; a missing one, or one in the
+// trash, is sql.ErrNoRows.
+func (s *Store) UpdateTask(ctx context.Context, t models.Task) (models.Task, error) {
+	now := store.Now()
+	t.CompletedAt = completedAt(t, now)
+	res, err := s.conn(ctx).ExecContext(ctx, "UPDATE TASKS SET task = ?, completed = ?, user_id = ?, parent_id = ?, priority = ?, due_at = ?, completed_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL",
+		t.Task, t.Completed, t.UserID, store.NullID(t.ParentID), t.Priority, store.NullTime(t.DueAt), store.NullTime(t.CompletedAt), now, t.ID, t.Version)
+	if err != nil {
+		return models.Task{}, err
+	}
+	// The version always changes, so MySQL counts the row as affected
+	n, err := res.RowsAffected()
+	if err != nil {
+		return models.Task{}, err
+	}
+	if n > 0 {
+		t.UpdatedAt = now
+		t.Version++
+		return t, nil
+	}
+	var version int
+	if err := s.conn(ctx).QueryRowContext(ctx, "SELECT version FROM TASKS WHERE id = ? AND deleted_at IS NULL", t.ID).Scan(&version); err != nil {
+		return models.Task{}, err
+	}
+	return models.Task{}, models.ErrPreconditionFailed
+}
+
+// DeleteTask moves the task with id to the trash, at its next version.
+func (s *Store) DeleteTask(ctx context.Context, id int) error {
+	now := store.Now()
+	_, err := s.conn(ctx).ExecContext(ctx, "UPDATE TASKS SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL", now, now, id)
 	return err
 }
+
+// GetDeletedTaskForUpdate returns the task with id from the trash, or
+// sql.ErrNoRows, and locks it against any change until the unit of work in
+// ctx ends.
+func (s *Store) GetDeletedTaskForUpdate(ctx context.Context, id int) (models.Task, error) {
+	return scanTask(s.conn(ctx).QueryRowContext(ctx, "SELECT "+taskColumns+" FROM TASKS WHERE id = ? AND deleted_at IS NOT NULL"+s.dialect.ForUpdate, id))
+}
+
+// RestoreTask takes the task with id out of the trash and returns it at its
+// next version, or returns sql.ErrNoRows if it is not in the trash.
+func (s *Store) RestoreTask(ctx context.Context, id int) (models.Task, error) {
+	res, err := s.conn(ctx).ExecContext(ctx, "UPDATE TASKS SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL", store.Now(), id)
+	if err != nil {
+		return models.Task{}, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return models.Task{}, err
+	}
+	if n == 0 {
+		return models.Task{}, sql.ErrNoRows
+	}
+	return s.GetTask(ctx, id)
+}
+
+// PurgeTasks deletes for good the tasks moved to the trash before before,
+// and returns how many there were. Their subtasks become top-level tasks at
+// their next version, and their dependencies go with them.
+func (s *Store) PurgeTasks(ctx context.Context, before time.Time) (int, error) {
+	var n int64
+	err := store.InTx(ctx, s.db, func(ctx context.Context) error {
+		before := before.UTC()
+		// MySQL only reads the table being updated through a derived table
+		_, err := s.conn(ctx).ExecContext(ctx, "UPDATE TASKS SET parent_id = NULL, updated_at = ?, version = version + 1 WHERE parent_id IN (SELECT id FROM (SELECT id FROM TASKS WHERE deleted_at < ?) purged)",
+			store.Now(), before)
+		if err != nil {
+			return err
+		}
+		res, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM TASKS WHERE deleted_at < ?", before)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return int(n), err
+}
@@ -2,12 +2,35 @@ package taskstore_test
 
 import (
 	"3layerarch/models"
+	"3layerarch/store"
 	"3layerarch/store/task"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
 
+// taskColumns are the columns the store selects tasks by.
+var taskColumns = []string{"id", "task", "completed", "user_id", "parent_id", "priority", "due_at", "version", "created_at", "updated_at", "completed_at", "deleted_at"}
+
+var selectColumns = strings.Join(taskColumns, ", ")
+
+// taskRow is a row of a task created, and last updated, on 2 January 2026,
+// with no priority or due date.
+func taskRow(id int, task string, completed bool, userID, version int, deletedAt any) *sqlmock.Rows {
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var completedAt any
+	if completed {
+		completedAt = createdAt
+	}
+	return sqlmock.NewRows(taskColumns).AddRow(id, task, completed, userID, nil, "", nil, version, createdAt, createdAt, completedAt, deletedAt)
+}
+
 func TestCreateTask(t *testing.T) {
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	if err != nil {
@@ -15,17 +38,46 @@ func TestCreateTask(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := taskstore.New(db)
+	repo := taskstore.New(db, store.MySQL)
 	task := models.Task{Task: "Clean room", Completed: false, UserID: 1}
 
-	mock.ExpectExec("INSERT INTO TASKS (task, completed, user_id) VALUES (?, ?, ?)").
-		WithArgs(task.Task, task.Completed, task.UserID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO TASKS (task, completed, user_id, parent_id, priority, due_at, version, created_at, updated_at, completed_at) VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?, ?)").
+		WithArgs(task.Task, task.Completed, task.UserID, nil, models.Priority(""), nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(12, 1))
 
-	err = repo.CreateTask(task)
+	created, err := repo.CreateTask(context.Background(), task)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
+	if created.ID != 12 || created.Task != "Clean room" || created.Version != 1 {
+		t.Errorf("expected the inserted task with its ID, got %+v", created)
+	}
+	if created.CreatedAt.IsZero() || !created.UpdatedAt.Equal(created.CreatedAt) || !created.CompletedAt.IsZero() {
+		t.Errorf("expected the task to be created now and not completed, got %+v", created)
+	}
+}
+
+func TestCreateTask_Postgres(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.Postgres)
+	due := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("INSERT INTO TASKS (task, completed, user_id, parent_id, priority, due_at, version, created_at, updated_at, completed_at) VALUES ($1, $2, $3, $4, $5, $6, 1, $7, $8, $9) RETURNING id").
+		WithArgs("Clean room", false, 1, int64(4), models.PriorityHigh, due, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+
+	created, err := repo.CreateTask(context.Background(), models.Task{Task: "Clean room", UserID: 1, ParentID: 4, Priority: models.PriorityHigh, DueAt: due})
+	if err != nil || created.ID != 12 {
+		t.Errorf("expected the task with the returned ID, got %+v, err: %v", created, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
 }
 
 func TestGetTask(t *testing.T) {
@@ -35,20 +87,52 @@ func TestGetTask(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := taskstore.New(db)
+	repo := taskstore.New(db, store.MySQL)
 
-	rows := sqlmock.NewRows([]string{"id", "task", "completed", "user_id"}).
-		AddRow(1, "Read", false, 2)
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	dueAt := createdAt.AddDate(0, 1, 0)
+	rows := sqlmock.NewRows(taskColumns).
+		AddRow(1, "Read", true, 2, 7, "high", dueAt, 1, createdAt, createdAt, createdAt, nil)
 
-	mock.ExpectQuery("SELECT id, task, completed, user_id FROM TASKS WHERE id = ?").
+	mock.ExpectQuery("SELECT " + selectColumns + " FROM TASKS WHERE id = ? AND deleted_at IS NULL").
 		WithArgs(1).WillReturnRows(rows)
 
-	task, err := repo.GetTask(1)
-	if err != nil || task.ID != 1 {
+	task, err := repo.GetTask(context.Background(), 1)
+	if err != nil || task.ID != 1 || task.ParentID != 7 || task.Priority != models.PriorityHigh || !task.DueAt.Equal(dueAt) || !task.CreatedAt.Equal(createdAt) || !task.CompletedAt.Equal(createdAt) || !task.DeletedAt.IsZero() {
 		t.Errorf("unexpected result: %v, err: %v", task, err)
 	}
 }
 
+func TestGetTaskForUpdate(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT " + selectColumns + " FROM TASKS WHERE id = ? AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(1).WillReturnRows(taskRow(1, "Read", false, 2, 1, nil))
+	mock.ExpectExec("UPDATE TASKS SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = store.InTx(context.Background(), db, func(ctx context.Context) error {
+		if _, err := repo.GetTaskForUpdate(ctx, 1); err != nil {
+			return err
+		}
+		return repo.DeleteTask(ctx, 1)
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestViewTasks(t *testing.T) {
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	if err != nil {
@@ -56,20 +140,181 @@ func TestViewTasks(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := taskstore.New(db)
+	repo := taskstore.New(db, store.MySQL)
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM TASKS WHERE deleted_at IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	rows := taskRow(1, "Work", false, 1, 1, nil)
+
+	mock.ExpectQuery("SELECT "+selectColumns+" FROM TASKS WHERE deleted_at IS NULL ORDER BY id ASC LIMIT ? OFFSET ?").
+		WithArgs(20, 0).WillReturnRows(rows)
+
+	tasks, total, err := repo.ViewTasks(context.Background(), models.TaskFilter{Limit: 20})
+	if err != nil || len(tasks) != 1 || total != 1 {
+		t.Errorf("unexpected result: %v, total: %d, err: %v", tasks, total, err)
+	}
+}
+
+func TestViewTasksFiltered(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+	completed, userID := true, 2
+	filter := models.TaskFilter{
+		Completed: &completed, UserID: &userID, Search: "50%_off",
+		Sort: "-task", Limit: 10, Offset: 30,
+	}
+	where := " WHERE deleted_at IS NULL AND completed = ? AND user_id = ? AND LOWER(task) LIKE ? ESCAPE '!'"
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM TASKS"+where).
+		WithArgs(true, 2, "%50!%!_off%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(31))
+
+	mock.ExpectQuery("SELECT "+selectColumns+" FROM TASKS"+where+" ORDER BY task DESC, id ASC LIMIT ? OFFSET ?").
+		WithArgs(true, 2, "%50!%!_off%", 10, 30).
+		WillReturnRows(taskRow(40, "50%_off sale", true, 2, 1, nil))
+
+	tasks, total, err := repo.ViewTasks(context.Background(), filter)
+	if err != nil || len(tasks) != 1 || total != 31 {
+		t.Errorf("unexpected result: %v, total: %d, err: %v", tasks, total, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestViewTasks_Postgres(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.Postgres)
+	userID := 2
+	where := " WHERE deleted_at IS NULL AND user_id = $1 AND LOWER(task) LIKE $2 ESCAPE '!'"
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM TASKS"+where).
+		WithArgs(2, "%milk%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT "+selectColumns+" FROM TASKS"+where+" ORDER BY id ASC LIMIT $3 OFFSET $4").
+		WithArgs(2, "%milk%", 10, 0).
+		WillReturnRows(taskRow(3, "Buy Milk", false, 2, 1, nil))
+
+	tasks, total, err := repo.ViewTasks(context.Background(), models.TaskFilter{UserID: &userID, Search: "Milk", Limit: 10})
+	if err != nil || len(tasks) != 1 || total != 1 {
+		t.Errorf("unexpected result: %v, total: %d, err: %v", tasks, total, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestViewTasks_TimeFilters(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "task", "completed", "user_id"}).
-		AddRow(1, "Work", false, 1)
+	repo := taskstore.New(db, store.MySQL)
+	after := time.Date(2026, 1, 2, 5, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	before := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	filter := models.TaskFilter{CreatedAfter: after, CompletedBefore: before, Limit: 10}
+	where := " WHERE deleted_at IS NULL AND created_at > ? AND completed_at < ?"
 
-	mock.ExpectQuery("SELECT id, task, completed, user_id FROM TASKS").
-		WillReturnRows(rows)
+	// Bounds are compared in UTC, as the times are stored
+	mock.ExpectQuery("SELECT COUNT(*) FROM TASKS"+where).
+		WithArgs(after.UTC(), before).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT "+selectColumns+" FROM TASKS"+where+" ORDER BY id ASC LIMIT ? OFFSET ?").
+		WithArgs(after.UTC(), before, 10, 0).
+		WillReturnRows(taskRow(1, "Work", true, 1, 1, nil))
 
-	tasks, err := repo.ViewTasks()
-	if err != nil || len(tasks) != 1 {
-		t.Errorf("unexpected result: %v, err: %v", tasks, err)
+	tasks, total, err := repo.ViewTasks(context.Background(), filter)
+	if err != nil || len(tasks) != 1 || total != 1 {
+		t.Errorf("unexpected result: %v, total: %d, err: %v", tasks, total, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
 	}
 }
 
+func TestViewTasks_PlanningFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter models.TaskFilter
+		where  string
+		args   []any
+		order  string
+	}{
+		{
+			"priority and label",
+			models.TaskFilter{Priority: models.PriorityUrgent, LabelID: ptr(4), Sort: "due_at"},
+			" WHERE deleted_at IS NULL AND priority = ? AND id IN (SELECT task_id FROM TASK_LABELS WHERE label_id = ?)",
+			[]any{models.PriorityUrgent, 4},
+			"due_at IS NULL, due_at ASC, id ASC",
+		},
+		{
+			"overdue",
+			models.TaskFilter{Overdue: ptr(true), Sort: "-due_at"},
+			" WHERE deleted_at IS NULL AND due_at < ? AND completed = ?",
+			[]any{sqlmock.AnyArg(), false},
+			"due_at IS NULL, due_at DESC, id ASC",
+		},
+		{
+			"not overdue",
+			models.TaskFilter{Overdue: ptr(false)},
+			" WHERE deleted_at IS NULL AND (due_at IS NULL OR due_at >= ? OR completed = ?)",
+			[]any{sqlmock.AnyArg(), true},
+			"id ASC",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer db.Close()
+
+			repo := taskstore.New(db, store.MySQL)
+			tc.filter.Limit = 10
+
+			mock.ExpectQuery("SELECT COUNT(*) FROM TASKS" + tc.where).
+				WithArgs(toDriver(tc.args)...).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectQuery("SELECT " + selectColumns + " FROM TASKS" + tc.where + " ORDER BY " + tc.order + " LIMIT ? OFFSET ?").
+				WithArgs(toDriver(append(tc.args, 10, 0))...).
+				WillReturnRows(taskRow(1, "Work", false, 1, 1, nil))
+
+			tasks, total, err := repo.ViewTasks(context.Background(), tc.filter)
+			if err != nil || len(tasks) != 1 || total != 1 {
+				t.Errorf("unexpected result: %v, total: %d, err: %v", tasks, total, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// toDriver turns arguments into what sqlmock compares them to.
+func toDriver(args []any) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a
+	}
+	return values
+}
+
 func TestUpdateTask(t *testing.T) {
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	if err != nil {
@@ -77,15 +322,57 @@ func TestUpdateTask(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := taskstore.New(db)
+	repo := taskstore.New(db, store.MySQL)
+
+	task := models.Task{ID: 1, Task: "Clean room", Completed: true, UserID: 2, ParentID: 5, Version: 3}
 
-	mock.ExpectExec("UPDATE TASKS SET completed = true WHERE id = ?").
-		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE TASKS SET task = ?, completed = ?, user_id = ?, parent_id = ?, priority = ?, due_at = ?, completed_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+		WithArgs(task.Task, task.Completed, task.UserID, int64(5), models.Priority(""), nil, sqlmock.AnyArg(), sqlmock.AnyArg(), task.ID, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err = repo.UpdateTask(1)
+	updated, err := repo.UpdateTask(context.Background(), task)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+	if updated.Version != 4 || updated.UpdatedAt.IsZero() || !updated.CompletedAt.Equal(updated.UpdatedAt) {
+		t.Errorf("expected the task at version 4, completed as it was updated, got %+v", updated)
+	}
+}
+
+func TestUpdateTask_Stale(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    *sqlmock.Rows
+		wantErr error
+	}{
+		{"changed", sqlmock.NewRows([]string{"version"}).AddRow(4), models.ErrPreconditionFailed},
+		{"deleted", sqlmock.NewRows([]string{"version"}), sql.ErrNoRows},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer db.Close()
+
+			repo := taskstore.New(db, store.MySQL)
+
+			mock.ExpectExec("UPDATE TASKS SET task = ?, completed = ?, user_id = ?, parent_id = ?, priority = ?, due_at = ?, completed_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL").
+				WithArgs("Clean room", false, 2, nil, models.Priority(""), nil, nil, sqlmock.AnyArg(), 1, 3).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("SELECT version FROM TASKS WHERE id = ? AND deleted_at IS NULL").
+				WithArgs(1).WillReturnRows(tc.rows)
+
+			_, err = repo.UpdateTask(context.Background(), models.Task{ID: 1, Task: "Clean room", UserID: 2, Version: 3})
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("expected %v, got %v", tc.wantErr, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
 }
 
 func TestDeleteTask(t *testing.T) {
@@ -95,13 +382,132 @@ func TestDeleteTask(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := taskstore.New(db)
+	repo := taskstore.New(db, store.MySQL)
 
-	mock.ExpectExec("DELETE FROM TASKS WHERE id = ?").
-		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE TASKS SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err = repo.DeleteTask(1)
+	err = repo.DeleteTask(context.Background(), 1)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
+
+func TestViewTasks_Trash(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+	deletedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM TASKS WHERE deleted_at IS NOT NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT "+selectColumns+" FROM TASKS WHERE deleted_at IS NOT NULL ORDER BY id ASC LIMIT ? OFFSET ?").
+		WithArgs(20, 0).
+		WillReturnRows(taskRow(1, "Work", false, 1, 2, deletedAt))
+
+	tasks, _, err := repo.ViewTasks(context.Background(), models.TaskFilter{Deleted: true, Limit: 20})
+	if err != nil || len(tasks) != 1 || !tasks[0].DeletedAt.Equal(deletedAt) {
+		t.Errorf("expected the deleted task with its deletion time, got %v, err: %v", tasks, err)
+	}
+}
+
+func TestRestoreTask(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT " + selectColumns + " FROM TASKS WHERE id = ? AND deleted_at IS NOT NULL FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(taskRow(1, "Read", false, 2, 2, time.Now()))
+	mock.ExpectExec("UPDATE TASKS SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL").
+		WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT " + selectColumns + " FROM TASKS WHERE id = ? AND deleted_at IS NULL").
+		WithArgs(1).WillReturnRows(taskRow(1, "Read", false, 2, 3, nil))
+	mock.ExpectCommit()
+
+	err = store.InTx(context.Background(), db, func(ctx context.Context) error {
+		task, err := repo.GetDeletedTaskForUpdate(ctx, 1)
+		if err != nil {
+			return err
+		}
+		if task.DeletedAt.IsZero() {
+			t.Errorf("expected the deletion time, got %+v", task)
+		}
+		restored, err := repo.RestoreTask(ctx, 1)
+		if err == nil && (restored.Version != 3 || !restored.DeletedAt.IsZero()) {
+			t.Errorf("expected the task back at version 3, got %+v", restored)
+		}
+		return err
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// A task not in the trash is not restored
+	mock.ExpectExec("UPDATE TASKS SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL").
+		WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	if _, err := repo.RestoreTask(context.Background(), 2); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurgeTasks(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+	before := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE TASKS SET parent_id = NULL, updated_at = ?, version = version + 1 WHERE parent_id IN (SELECT id FROM (SELECT id FROM TASKS WHERE deleted_at < ?) purged)").
+		WithArgs(sqlmock.AnyArg(), before).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM TASKS WHERE deleted_at < ?").
+		WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	n, err := repo.PurgeTasks(context.Background(), before)
+	if err != nil || n != 3 {
+		t.Errorf("expected 3 tasks purged, got %d, err: %v", n, err)
+	}
+}
+
+func TestGetTask_Cancelled(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := taskstore.New(db, store.MySQL)
+
+	mock.ExpectQuery("SELECT " + selectColumns + " FROM TASKS WHERE id = ? AND deleted_at IS NULL").
+		WithArgs(1).WillDelayFor(time.Minute).
+		WillReturnRows(taskRow(1, "Read", false, 2, 1, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = repo.GetTask(ctx, 1)
+	if err == nil || ctx.Err() == nil {
+		t.Errorf("expected the query to stop at the deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the query to stop early, it took %v", elapsed)
+	}
+}
@@ -2,24 +2,323 @@ package userstore
 
 import (
 	"3layerarch/models"
+	"3layerarch/store"
+	"context"
 	"database/sql"
+	"log"
+	"time"
 )
 
 type Store struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect store.Dialect
 }
 
-func New(db *sql.DB) *Store {
-	return &Store{db: db}
+// New returns the user store of db, whose SQL is written in dialect.
+func New(db *sql.DB, dialect store.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
 }
 
-func (s *Store) CreateUser(u models.User) error {
-	_, err := s.db.Exec("INSERT INTO USERS (name) VALUES (?)", u.Name)
+// conn returns what queries for ctx run on: its unit of work, if any.
+func (s *Store) conn(ctx context.Context) store.DBTX {
+	return s.dialect.Bind(store.Conn(ctx, s.db))
+}
+
+// errNameTaken reports a name the unique index on USERS refused. The user
+// service checks names first, but two requests can both pass that check.
+var errNameTaken = models.Conflict("user name already taken")
+
+// CreateUser inserts u and returns it with the ID the database assigned.
+func (s *Store) CreateUser(ctx context.Context, u models.User) (models.User, error) {
+	id, err := s.dialect.Insert(ctx, s.conn(ctx), "INSERT INTO USERS (name) VALUES (?)", u.Name)
+	if store.Duplicate(err) {
+		return models.User{}, errNameTaken
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	u.ID = int(id)
+	return u, nil
+}
+
+// GetUser returns the user with id, or sql.ErrNoRows if there is none or
+// they are in the trash.
+func (s *Store) GetUser(ctx context.Context, id int) (models.User, error) {
+	var u models.User
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT id, name FROM USERS WHERE id = ? AND deleted_at IS NULL", id).Scan(&u.ID, &u.Name)
+	return u, err
+}
+
+// GetUserForUpdate returns the user with id like GetUser and locks them
+// against any change until the unit of work in ctx ends.
+func (s *Store) GetUserForUpdate(ctx context.Context, id int) (models.User, error) {
+	var u models.User
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT id, name FROM USERS WHERE id = ? AND deleted_at IS NULL"+s.dialect.ForUpdate, id).Scan(&u.ID, &u.Name)
+	return u, err
+}
+
+// GetUserForShare returns the user with id like GetUser and keeps them from
+// being changed or deleted until the unit of work in ctx ends. Other units
+// of work may still read them.
+func (s *Store) GetUserForShare(ctx context.Context, id int) (models.User, error) {
+	var u models.User
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT id, name FROM USERS WHERE id = ? AND deleted_at IS NULL"+s.dialect.ForShare, id).Scan(&u.ID, &u.Name)
+	return u, err
+}
+
+// GetUserStats returns the user with id and the counts of their open and
+// completed tasks, or sql.ErrNoRows. Tasks in the trash are not counted.
+func (s *Store) GetUserStats(ctx context.Context, id int) (models.User, models.UserStats, error) {
+	var u models.User
+	var st models.UserStats
+	err := s.conn(ctx).QueryRowContext(ctx, `SELECT u.id, u.name,
+		SUM(CASE WHEN t.completed = FALSE THEN 1 ELSE 0 END),
+		SUM(CASE WHEN t.completed = TRUE THEN 1 ELSE 0 END)
+		FROM USERS u LEFT JOIN TASKS t ON t.user_id = u.id AND t.deleted_at IS NULL
+		WHERE u.id = ? AND u.deleted_at IS NULL GROUP BY u.id, u.name`, id).
+		Scan(&u.ID, &u.Name, &st.Open, &st.Completed)
+	return u, st, err
+}
+
+// GetUserWithTasks returns the user with id and all of their tasks ordered
+// by id, or sql.ErrNoRows. Tasks in the trash are left out.
+func (s *Store) GetUserWithTasks(ctx context.Context, id int) (models.User, []models.Task, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx, `SELECT u.id, u.name, t.id, t.task, t.completed, t.parent_id, t.priority, t.due_at, t.version, t.created_at, t.updated_at, t.completed_at
+		FROM USERS u LEFT JOIN TASKS t ON t.user_id = u.id AND t.deleted_at IS NULL
+		WHERE u.id = ? AND u.deleted_at IS NULL ORDER BY t.id ASC`, id)
+	if err != nil {
+		return models.User{}, nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}()
+
+	var u models.User
+	tasks := []models.Task{}
+	found := false
+	for rows.Next() {
+		var taskID, parentID sql.NullInt64
+		var task, priority sql.NullString
+		var completed sql.NullBool
+		var version sql.NullInt64
+		var dueAt, createdAt, updatedAt, completedAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Name, &taskID, &task, &completed, &parentID, &priority, &dueAt, &version, &createdAt, &updatedAt, &completedAt); err != nil {
+			return models.User{}, nil, err
+		}
+		found = true
+		// A user without tasks still comes back as one row of NULLs.
+		if taskID.Valid {
+			tasks = append(tasks, models.Task{
+				ID: int(taskID.Int64), Task: task.String, Completed: completed.Bool, UserID: u.ID,
+				ParentID: int(parentID.Int64), Priority: models.Priority(priority.String), DueAt: store.Time(dueAt), Version: int(version.Int64),
+				CreatedAt: store.Time(createdAt), UpdatedAt: store.Time(updatedAt), CompletedAt: store.Time(completedAt),
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return models.User{}, nil, err
+	}
+	if !found {
+		return models.User{}, nil, sql.ErrNoRows
+	}
+	return u, tasks, nil
+}
+
+// GetUserByName returns the user called name, or sql.ErrNoRows. A user in
+// the trash is returned too, as they keep their name until purged.
+func (s *Store) GetUserByName(ctx context.Context, name string) (models.User, error) {
+	var u models.User
+	var deletedAt sql.NullTime
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT id, name, deleted_at FROM USERS WHERE name = ?", name).Scan(&u.ID, &u.Name, &deletedAt)
+	u.DeletedAt = store.Time(deletedAt)
+	return u, err
+}
+
+// GetPasswordHash returns the user called name, as the principal they sign
+// in as, and their password hash, which is empty if they have no password,
+// or sql.ErrNoRows. Users in the trash cannot sign in.
+func (s *Store) GetPasswordHash(ctx context.Context, name string) (models.Principal, string, error) {
+	var p models.Principal
+	var hash sql.NullString
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT id, name, role, password_hash FROM USERS WHERE name = ? AND deleted_at IS NULL", name).
+		Scan(&p.UserID, &p.Name, &p.Role, &hash)
+	return p, hash.String, err
+}
+
+// SetPasswordHash stores the password hash of the user with id.
+func (s *Store) SetPasswordHash(ctx context.Context, id int, hash string) error {
+	_, err := s.conn(ctx).ExecContext(ctx, "UPDATE USERS SET password_hash = ? WHERE id = ?", hash, id)
+	return err
+}
+
+// ViewUsers returns the page of users selected by f, ordered by id, and the
+// total number of users, either those in the trash or the others.
+func (s *Store) ViewUsers(ctx context.Context, f models.UserFilter) ([]models.User, int, error) {
+	where := " WHERE deleted_at IS NULL"
+	if f.Deleted {
+		where = " WHERE deleted_at IS NOT NULL"
+	}
+
+	var total int
+	if err := s.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM USERS"+where).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx, "SELECT id, name, deleted_at FROM USERS"+where+" ORDER BY id ASC LIMIT ? OFFSET ?", f.Limit, f.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}()
+
+	users := []models.User{}
+	for rows.Next() {
+		var u models.User
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Name, &deletedAt); err != nil {
+			return nil, 0, err
+		}
+		u.DeletedAt = store.Time(deletedAt)
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, u models.User) error {
+	_, err := s.conn(ctx).ExecContext(ctx, "UPDATE USERS SET name = ? WHERE id = ?", u.Name, u.ID)
+	if store.Duplicate(err) {
+		return errNameTaken
+	}
 	return err
 }
 
-func (s *Store) GetUser(id int) (models.User, error) {
+// CountTasks returns the number of tasks owned by the user with id, leaving
+// out those in the trash.
+func (s *Store) CountTasks(ctx context.Context, id int) (int, error) {
+	var n int
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM TASKS WHERE user_id = ? AND deleted_at IS NULL", id).Scan(&n)
+	return n, err
+}
+
+// LockUserTasks returns every task of the user with id ordered by id, those
+// in the trash included, and locks them against any change until the unit
+// of work in ctx ends.
+func (s *Store) LockUserTasks(ctx context.Context, id int) ([]models.Task, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx, `SELECT id, task, completed, user_id, parent_id, priority, due_at, version, created_at, updated_at, completed_at, deleted_at
+		FROM TASKS WHERE user_id = ? ORDER BY id ASC`+s.dialect.ForUpdate, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}()
+
+	tasks := []models.Task{}
+	for rows.Next() {
+		var t models.Task
+		var parentID sql.NullInt64
+		var dueAt, createdAt, updatedAt, completedAt, deletedAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Task, &t.Completed, &t.UserID, &parentID, &t.Priority, &dueAt, &t.Version, &createdAt, &updatedAt, &completedAt, &deletedAt); err != nil {
+			return nil, err
+		}
+		t.ParentID, t.DueAt = int(parentID.Int64), store.Time(dueAt)
+		t.CreatedAt, t.UpdatedAt = store.Time(createdAt), store.Time(updatedAt)
+		t.CompletedAt, t.DeletedAt = store.Time(completedAt), store.Time(deletedAt)
+		tasks = append(tasks, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// DeleteUser moves the user with id to the trash together with their
+// tasks, or, when reassignTo is set, after moving all their tasks, those in
+// the trash included, to that user. Both steps run in one transaction, the
+// caller's unit of work if there is one.
+func (s *Store) DeleteUser(ctx context.Context, id, reassignTo int) error {
+	now := store.Now()
+	return store.InTx(ctx, s.db, func(ctx context.Context) error {
+		var err error
+		if reassignTo > 0 {
+			_, err = s.conn(ctx).ExecContext(ctx, "UPDATE TASKS SET user_id = ?, updated_at = ?, version = version + 1 WHERE user_id = ?", reassignTo, now, id)
+		} else {
+			_, err = s.conn(ctx).ExecContext(ctx, "UPDATE TASKS SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE user_id = ? AND deleted_at IS NULL", now, now, id)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = s.conn(ctx).ExecContext(ctx, "UPDATE USERS SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", now, id)
+		return err
+	})
+}
+
+// GetDeletedUserForUpdate returns the user with id from the trash, or
+// sql.ErrNoRows, and locks them against any change until the unit of work
+// in ctx ends.
+func (s *Store) GetDeletedUserForUpdate(ctx context.Context, id int) (models.User, error) {
 	var u models.User
-	err := s.db.QueryRow("SELECT id, name FROM USERS WHERE id = ?", id).Scan(&u.ID, &u.Name)
+	var deletedAt sql.NullTime
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT id, name, deleted_at FROM USERS WHERE id = ? AND deleted_at IS NOT NULL"+s.dialect.ForUpdate, id).
+		Scan(&u.ID, &u.Name, &deletedAt)
+	u.DeletedAt = store.Time(deletedAt)
 	return u, err
 }
+
+// RestoreUser takes the user with id out of the trash together with the
+// tasks that were deleted with them, or returns sql.ErrNoRows if they are
+// not in the trash. Tasks deleted before the user stay in the trash.
+func (s *Store) RestoreUser(ctx context.Context, id int) error {
+	now := store.Now()
+	return store.InTx(ctx, s.db, func(ctx context.Context) error {
+		_, err := s.conn(ctx).ExecContext(ctx, `UPDATE TASKS SET deleted_at = NULL, updated_at = ?, version = version + 1
+			WHERE user_id = ? AND deleted_at = (SELECT deleted_at FROM USERS WHERE id = ?)`, now, id, id)
+		if err != nil {
+			return err
+		}
+		res, err := s.conn(ctx).ExecContext(ctx, "UPDATE USERS SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			return err
+		}
+		return sql.ErrNoRows
+	})
+}
+
+// PurgeUsers deletes for good the users moved to the trash before before,
+// with all of their tasks, and returns how many users there were.
+func (s *Store) PurgeUsers(ctx context.Context, before time.Time) (int, error) {
+	var n int64
+	err := store.InTx(ctx, s.db, func(ctx context.Context) error {
+		before := before.UTC()
+		// Subtasks of other users' tasks become top-level tasks
+		_, err := s.conn(ctx).ExecContext(ctx, "UPDATE TASKS SET parent_id = NULL, updated_at = ?, version = version + 1 WHERE parent_id IN (SELECT id FROM (SELECT id FROM TASKS WHERE user_id IN (SELECT id FROM USERS WHERE deleted_at < ?)) purged)",
+			store.Now(), before)
+		if err != nil {
+			return err
+		}
+		_, err = s.conn(ctx).ExecContext(ctx, "DELETE FROM TASKS WHERE user_id IN (SELECT id FROM USERS WHERE deleted_at < ?)", before)
+		if err != nil {
+			return err
+		}
+		res, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM USERS WHERE deleted_at < ?", before)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return int(n), err
+}
@@ -0,0 +1,71 @@
+// Package trash empties the trash: tasks and users deleted longer ago than
+// the retention are deleted for good.
+package trash
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// TaskStore deletes the tasks moved to the trash before a time.
+type TaskStore interface {
+	PurgeTasks(ctx context.Context, before time.Time) (int, error)
+}
+
+// UserStore deletes the users moved to the trash before a time, and all of
+// their tasks.
+type UserStore interface {
+	PurgeUsers(ctx context.Context, before time.Time) (int, error)
+}
+
+// Purger purges the trash of the stores. It goes to the stores directly, as
+// what it deletes can no longer be seen through the services.
+type Purger struct {
+	Tasks TaskStore
+	Users UserStore
+	// Retention is how long a deleted task or user can be restored.
+	Retention time.Duration
+	// Interval is the time between purges.
+	Interval time.Duration
+}
+
+func New(tasks TaskStore, users UserStore, retention, interval time.Duration) *Purger {
+	return &Purger{Tasks: tasks, Users: users, Retention: retention, Interval: interval}
+}
+
+// Purge deletes for good what has been in the trash for longer than the
+// retention. Tasks go first; purging a user takes whatever tasks of theirs
+// are left.
+func (p *Purger) Purge(ctx context.Context) error {
+	before := time.Now().Add(-p.Retention)
+	tasks, err := p.Tasks.PurgeTasks(ctx, before)
+	if err != nil {
+		return err
+	}
+	users, err := p.Users.PurgeUsers(ctx, before)
+	if err != nil {
+		return err
+	}
+	if tasks > 0 || users > 0 {
+		log.Printf("Purged %d tasks and %d users from the trash", tasks, users)
+	}
+	return nil
+}
+
+// Run purges at once and then every Interval until ctx is done. A purge
+// that fails is logged and tried again at the next interval.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Purge(ctx); err != nil && ctx.Err() == nil {
+			log.Println("Error purging the trash:", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package trash_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"3layerarch/trash"
+)
+
+// fakeStore records the times it was asked to purge before.
+type fakeStore struct {
+	mu     sync.Mutex
+	tasks  []time.Time
+	users  []time.Time
+	err    error
+	purged chan struct{}
+}
+
+func (f *fakeStore) PurgeTasks(ctx context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tasks = append(f.tasks, before)
+	return 1, f.err
+}
+
+func (f *fakeStore) PurgeUsers(ctx context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	f.users = append(f.users, before)
+	f.mu.Unlock()
+	if f.purged != nil {
+		f.purged <- struct{}{}
+	}
+	return 0, nil
+}
+
+func TestPurge(t *testing.T) {
+	f := &fakeStore{}
+	p := trash.New(f, f, time.Hour, time.Minute)
+
+	start := time.Now()
+	if err := p.Purge(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.tasks) != 1 || len(f.users) != 1 || !f.tasks[0].Equal(f.users[0]) {
+		t.Fatalf("expected tasks and users purged before the same time, got %v and %v", f.tasks, f.users)
+	}
+	if before := f.tasks[0]; before.Before(start.Add(-time.Hour)) || before.After(time.Now().Add(-time.Hour)) {
+		t.Errorf("expected to purge what was deleted an hour ago, got %v", before)
+	}
+}
+
+func TestPurge_TasksFail(t *testing.T) {
+	f := &fakeStore{err: errors.New("db down")}
+	p := trash.New(f, f, time.Hour, time.Minute)
+
+	if err := p.Purge(context.Background()); err == nil || len(f.users) != 0 {
+		t.Errorf("expected the error and no users purged, got %v and %d purges", err, len(f.users))
+	}
+}
+
+func TestRun(t *testing.T) {
+	f := &fakeStore{purged: make(chan struct{})}
+	p := trash.New(f, f, time.Hour, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	// It purges at once and then again at each interval
+	for range 2 {
+		select {
+		case <-f.purged:
+		case <-time.After(time.Second):
+			t.Fatal("expected the trash to be purged")
+		}
+	}
+	cancel()
+	go func() {
+		for range f.purged {
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Run to return once ctx is done")
+	}
+}
@@ -1,14 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
 	"strconv"
-	"time"
+	"strings"
+	"syscall"
+
+	"Assignment/server"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -91,8 +100,98 @@ func (db *input) getByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (db *input) viewTask(w http.ResponseWriter, _ *http.Request) {
-	rows, err := db.data.Query("SELECT id, task, completed FROM TASKS")
+// taskQuery is the SQL for one page of a GET /task listing.
+type taskQuery struct {
+	where  string
+	args   []any
+	order  string
+	limit  int
+	offset int
+}
+
+// likeEscaper escapes the LIKE wildcards so a search matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// parseTaskQuery turns the completed, q, sort, limit and offset query
+// parameters into SQL. Only known columns are ever written into the query.
+func parseTaskQuery(q url.Values) (taskQuery, error) {
+	tq := taskQuery{order: "id ASC", limit: 20}
+	var conds []string
+
+	if v := q.Get("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return taskQuery{}, errors.New("invalid completed value")
+		}
+		conds = append(conds, "completed = ?")
+		tq.args = append(tq.args, completed)
+	}
+
+	if v := q.Get("q"); v != "" {
+		conds = append(conds, "task LIKE ?")
+		tq.args = append(tq.args, "%"+likeEscaper.Replace(v)+"%")
+	}
+
+	if len(conds) > 0 {
+		tq.where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	if v := q.Get("sort"); v != "" {
+		dir := "ASC"
+		if strings.HasPrefix(v, "-") {
+			dir = "DESC"
+		}
+		switch field := strings.TrimPrefix(v, "-"); field {
+		case "id":
+			tq.order = "id " + dir
+		case "task", "completed":
+			tq.order = field + " " + dir + ", id ASC"
+		default:
+			return taskQuery{}, fmt.Errorf("cannot sort by %q", v)
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 100 {
+			return taskQuery{}, errors.New("limit must be between 1 and 100")
+		}
+		tq.limit = limit
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return taskQuery{}, errors.New("invalid offset")
+		}
+		tq.offset = offset
+	}
+
+	return tq, nil
+}
+
+func (db *input) viewTask(w http.ResponseWriter, r *http.Request) {
+	tq, err := parseTaskQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page := struct {
+		Tasks  []task `json:"tasks"`
+		Total  int    `json:"total"`
+		Limit  int    `json:"limit"`
+		Offset int    `json:"offset"`
+		Next   string `json:"next,omitempty"`
+	}{Tasks: []task{}, Limit: tq.limit, Offset: tq.offset}
+
+	if err := db.data.QueryRow("SELECT COUNT(*) FROM TASKS"+tq.where, tq.args...).Scan(&page.Total); err != nil {
+		http.Error(w, "Failed to count tasks", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := db.data.Query("SELECT id, task, completed FROM TASKS"+tq.where+" ORDER BY "+tq.order+" LIMIT ? OFFSET ?",
+		append(tq.args, tq.limit, tq.offset)...)
 	if err != nil {
 		http.Error(w, "Failed to query tasks", http.StatusInternalServerError)
 		return
@@ -103,51 +202,164 @@ func (db *input) viewTask(w http.ResponseWriter, _ *http.Request) {
 		}
 	}()
 
-	w.Header().Set("Content-Type", "text/plain")
-
 	for rows.Next() {
-		var id int
-		var task string
-		var completed bool
-
-		if err := rows.Scan(&id, &task, &completed); err != nil {
+		var t task
+		if err := rows.Scan(&t.ID, &t.Task, &t.Completed); err != nil {
 			http.Error(w, "Failed to read task row", http.StatusInternalServerError)
 			log.Printf("%v", err)
 			return
 		}
+		page.Tasks = append(page.Tasks, t)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Failed to read tasks", http.StatusInternalServerError)
+		return
+	}
+
+	if tq.offset+len(page.Tasks) < page.Total {
+		next := r.URL.Query()
+		next.Set("limit", strconv.Itoa(tq.limit))
+		next.Set("offset", strconv.Itoa(tq.offset+tq.limit))
+		page.Next = r.URL.Path + "?" + next.Encode()
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+type task struct {
+	ID        int    `json:"id"`
+	Task      string `json:"task"`
+	Completed bool   `json:"completed"`
+}
+
+// taskPatch is a JSON merge-patch for a task. Nil fields are left unchanged.
+type taskPatch struct {
+	Task      *string `json:"task"`
+	Completed *bool   `json:"completed"`
+}
+
+// decodePatch parses a JSON merge-patch (RFC 7396). Both task fields are
+// required, so a null, which would remove the field, is rejected, as are
+// fields a task does not have.
+func decodePatch(body []byte) (taskPatch, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return taskPatch{}, errors.New("invalid JSON input")
+	}
+
+	for field, v := range raw {
+		if string(v) == "null" {
+			return taskPatch{}, fmt.Errorf("%s cannot be null", field)
+		}
+	}
+
+	var p taskPatch
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return taskPatch{}, fmt.Errorf("invalid patch: %w", err)
+	}
+
+	return p, nil
+}
+
+// saveTask looks up the task with id, applies change to it and writes the
+// result back, answering the request with the updated task.
+func (db *input) saveTask(w http.ResponseWriter, id int, change func(t *task)) {
+	t := task{ID: id}
 
-		if _, err := fmt.Fprintf(w, "ID: %d, Task: %s, Completed: %t\n", id, task, completed); err != nil {
-			log.Printf("Error writing task row: %v", err)
+	err := db.data.QueryRow("SELECT task, completed FROM TASKS WHERE id = ?", id).Scan(&t.Task, &t.Completed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Task not found", http.StatusNotFound)
 			return
 		}
+		http.Error(w, "DB error", http.StatusInternalServerError)
+		return
+	}
+
+	change(&t)
+	if t.Task == "" {
+		http.Error(w, "Task cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.data.Exec("UPDATE TASKS SET task = ?, completed = ? WHERE id = ?", t.Task, t.Completed, id); err != nil {
+		http.Error(w, "Failed to update task", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing response: %v", err)
 	}
 }
 
-func (db *input) completeTask(w http.ResponseWriter, r *http.Request) {
+func (db *input) updateTask(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		http.Error(w, "Invalid ID", http.StatusBadRequest)
 		return
 	}
 
-	res, err := db.data.Exec("UPDATE TASKS SET completed = true WHERE id = ?", id)
+	var inputData struct {
+		Task      string `json:"task"`
+		Completed bool   `json:"completed"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&inputData); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	db.saveTask(w, id, func(t *task) {
+		t.Task = inputData.Task
+		t.Completed = inputData.Completed
+	})
+}
+
+func (db *input) patchTask(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Failed to update task", http.StatusInternalServerError)
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
 		return
 	}
 
-	affected, err := res.RowsAffected()
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Error checking update result", http.StatusInternalServerError)
+		http.Error(w, "Error reading body", http.StatusBadRequest)
 		return
 	}
 
-	if affected == 0 {
-		http.Error(w, "Task not found", http.StatusNotFound)
+	p, err := decodePatch(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	db.saveTask(w, id, func(t *task) {
+		if p.Task != nil {
+			t.Task = *p.Task
+		}
+		if p.Completed != nil {
+			t.Completed = *p.Completed
+		}
+	})
 }
 
 func (db *input) deleteTask(w http.ResponseWriter, r *http.Request) {
@@ -178,13 +390,20 @@ func (db *input) deleteTask(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	db := &input{}
-	var err error
+	cfg, args, err := server.LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatal("Config error -> ", err)
+	}
+	log.Printf("Config: %+v", cfg) // the password is redacted
 
-	db.data, err = sql.Open("mysql", "root:root123@tcp(localhost:3306)/test_db")
+	db := &input{}
+	db.data, err = sql.Open("mysql", cfg.DB.DSN())
 	if err != nil {
 		log.Fatal("Error connecting to DB -> ", err)
 	}
+	db.data.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+	db.data.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+	db.data.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime)
 	defer func() {
 		if err := db.data.Close(); err != nil {
 			log.Printf("Error closing DB connection: %v", err)
@@ -195,26 +414,50 @@ func main() {
 		log.Fatal("Error pinging DB -> ", err)
 	}
 
-	_, err = db.data.Exec("CREATE TABLE IF NOT EXISTS TASKS (id int auto_increment primary key, task text, completed bool);")
-	if err != nil {
-		log.Fatal("Error creating table -> ", err)
+	// "migrate up|down|status" changes the schema instead of serving
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := server.Migrate(db.data, args[1:], os.Stdout); err != nil {
+			log.Fatal("Migration failed -> ", err)
+		}
+		return
+	}
+	if cfg.MigrateOnStart {
+		if err := server.Migrate(db.data, []string{"up"}, os.Stdout); err != nil {
+			log.Fatal("Migration failed -> ", err)
+		}
+	}
+	if err := server.CheckSchema(db.data); err != nil {
+		log.Fatal("Schema check failed -> ", err)
 	}
 
 	http.HandleFunc("/", hellohandler)
+	http.HandleFunc("GET /healthz", server.Healthz)
+	http.HandleFunc("GET /readyz", server.Readyz(db.data))
 	http.HandleFunc("POST /task", db.addTask)
 	http.HandleFunc("GET /task/{id}", db.getByID)
 	http.HandleFunc("GET /task", db.viewTask)
-	http.HandleFunc("PUT /task/{id}", db.completeTask)
+	http.HandleFunc("PUT /task/{id}", db.updateTask)
+	http.HandleFunc("PATCH /task/{id}", db.patchTask)
 	http.HandleFunc("DELETE /task/{id}", db.deleteTask)
 
+	// Every request gets an ID and a log line, and a panic becomes a 500
+	handler := server.Chain(http.DefaultServeMux, server.RequestID, server.AccessLog, server.RecoverPanic,
+		server.CORS(cfg.Server.CORSOrigins), server.MaxBodySize(cfg.Server.MaxBodyBytes))
+
 	srv := &http.Server{
-		Addr:         ":8080",
-		Handler:      nil,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		Addr:         ":" + strconv.Itoa(cfg.Server.Port),
+		Handler:      handler,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
-	fmt.Println("Server running at http://localhost:8080")
-	log.Fatal(srv.ListenAndServe())
+	// SIGINT or SIGTERM drains the server; the DB is closed once it is done
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("Server running at http://localhost" + srv.Addr)
+	if err := server.Serve(ctx, srv, cfg.Server.ShutdownTimeout); err != nil {
+		log.Fatal("Server error -> ", err)
+	}
 }
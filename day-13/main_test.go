@@ -22,7 +22,7 @@ func TestHelloWorld(t *testing.T) {
 }
 
 func openTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("mysql", "root:root123@tcp(localhost:3306)/test_db")
+	db, err := sql.Open("mysql", "root:root123@tcp(localhost:3306)/taskserver_db")
 	if err != nil {
 		t.Fatal("Failed to open DB:", err)
 	}
@@ -108,7 +108,7 @@ func TestSQLWithError(t *testing.T) {
 
 	//Opening DataBase
 	var err error
-	db.data, err = sql.Open("mysql", "root:root123@tcp(localhost:3306)/test_db")
+	db.data, err = sql.Open("mysql", "root:root123@tcp(localhost:3306)/taskserver_db")
 	if err != nil {
 		log.Fatal(err)
 	}
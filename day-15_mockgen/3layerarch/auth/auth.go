@@ -0,0 +1,58 @@
+// Package auth authenticates API requests with static API keys or JWT
+// bearer tokens and issues tokens to users.
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"3layerarch/handler"
+	"3layerarch/models"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries
+// none of the credentials it checks, so that the next one can try.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Authenticator checks one kind of credentials. Credentials that are present
+// but wrong are an error other than ErrNoCredentials.
+type Authenticator interface {
+	Authenticate(r *http.Request) (models.Principal, error)
+}
+
+// Middleware rejects requests that no Authenticator accepts and passes the
+// principal of the others on in the request context.
+type Middleware struct {
+	Authenticators []Authenticator
+	// Public are path prefixes, such as /auth/token, served without
+	// credentials.
+	Public []string
+}
+
+func (m *Middleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range m.Public {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		for _, a := range m.Authenticators {
+			p, err := a.Authenticate(r)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				handler.WriteError(w, models.Unauthorized(err.Error()))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(models.WithPrincipal(r.Context(), p)))
+			return
+		}
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		handler.WriteError(w, models.Unauthorized("authentication required"))
+	})
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testBackend checks what every Backend does: values come back until they
+// are deleted.
+func testBackend(t *testing.T, b Backend) {
+	ctx := context.Background()
+	if _, ok, err := b.Get(ctx, "task:1"); ok || err != nil {
+		t.Fatalf("expected a miss, got ok %v, err: %v", ok, err)
+	}
+	if err := b.Set(ctx, "task:1", []byte("one"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if v, ok, err := b.Get(ctx, "task:1"); !ok || string(v) != "one" || err != nil {
+		t.Errorf("expected one, got %q, ok %v, err: %v", v, ok, err)
+	}
+	if err := b.Delete(ctx, "task:1", "task:2"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := b.Get(ctx, "task:1"); ok {
+		t.Error("expected the deleted value to be gone")
+	}
+
+	for _, key := range []string{"task:1", "task:2", "user:1"} {
+		b.Set(ctx, key, []byte(key), time.Minute)
+	}
+	if err := b.DeletePrefix(ctx, "task:"); err != nil {
+		t.Fatalf("DeletePrefix: %v", err)
+	}
+	for key, want := range map[string]bool{"task:1": false, "task:2": false, "user:1": true} {
+		if _, ok, _ := b.Get(ctx, key); ok != want {
+			t.Errorf("%s: expected kept %v, got %v", key, want, ok)
+		}
+	}
+}
+
+func TestLRU(t *testing.T) {
+	testBackend(t, NewLRU(10))
+}
+
+func TestLRU_Evicts(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	c.Set(ctx, "a", []byte("a"), time.Minute)
+	c.Set(ctx, "b", []byte("b"), time.Minute)
+	c.Get(ctx, "a") // b is now the least recently used
+	c.Set(ctx, "c", []byte("c"), time.Minute)
+
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := c.Get(ctx, key); !ok {
+			t.Errorf("expected %s to be kept", key)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 values, got %d", c.Len())
+	}
+}
+
+func TestLRU_Expires(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewLRU(10)
+	c.now = func() time.Time { return now }
+	c.Set(ctx, "a", []byte("a"), time.Minute)
+
+	now = now.Add(59 * time.Second)
+	if _, ok, _ := c.Get(ctx, "a"); !ok {
+		t.Error("expected a value within its TTL")
+	}
+	now = now.Add(time.Second)
+	if _, ok, _ := c.Get(ctx, "a"); ok || c.Len() != 0 {
+		t.Errorf("expected the expired value to be dropped, %d left", c.Len())
+	}
+}
+
+func TestRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	r := NewRedis(client, "3layerarch:")
+
+	testBackend(t, r)
+
+	ctx := context.Background()
+	r.Set(ctx, "user:1", []byte("ann"), time.Minute)
+	if !mr.Exists("3layerarch:user:1") {
+		t.Error("expected the key to be prefixed")
+	}
+	mr.FastForward(time.Minute)
+	if _, ok, _ := r.Get(ctx, "user:1"); ok {
+		t.Error("expected the value to expire")
+	}
+	if err := r.Ping(ctx); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+
+	mr.Close()
+	if _, _, err := r.Get(ctx, "user:1"); err == nil {
+		t.Error("expected an error without a server")
+	}
+}
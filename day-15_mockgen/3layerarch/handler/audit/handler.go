@@ -0,0 +1,88 @@
+package audithandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"3layerarch/handler"
+	"3layerarch/models"
+)
+
+type Handler struct {
+	Service AuditService
+}
+
+func New(service AuditService) *Handler {
+	return &Handler{Service: service}
+}
+
+// ViewAudit godoc
+// @Summary List the audit log
+// @Description Returns a page of the recorded changes to tasks and users, oldest first. Only admins may read it.
+// @Tags audit
+// @Produce json
+// @Param entity query string false "Only changes to tasks or users" Enums(task, user)
+// @Param id query int false "Only changes to the entity with this ID; needs entity"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of entries to skip"
+// @Success 200 {object} models.AuditPage
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 403 {object} handler.ErrorResponse "Forbidden"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /audit [get]
+func (h *Handler) ViewAudit(w http.ResponseWriter, r *http.Request) {
+	f, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	page, err := h.Service.ViewAudit(r.Context(), f)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	if page.Offset+len(page.Entries) < page.Total {
+		f.Limit, f.Offset = page.Limit, page.Offset+page.Limit
+		page.Next = r.URL.Path + "?" + auditFilterQuery(f).Encode()
+	}
+	b, _ := json.Marshal(page)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// parseAuditFilter reads the filter and paging parameters of the audit log.
+func parseAuditFilter(q url.Values) (models.AuditFilter, error) {
+	f := models.AuditFilter{Entity: q.Get("entity")}
+	for name, dst := range map[string]*int{"id": &f.EntityID, "limit": &f.Limit, "offset": &f.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return models.AuditFilter{}, fmt.Errorf("invalid %s value", name)
+			}
+			*dst = n
+		}
+	}
+	return f, nil
+}
+
+// auditFilterQuery is the query string that selects f.
+func auditFilterQuery(f models.AuditFilter) url.Values {
+	q := url.Values{}
+	if f.Entity != "" {
+		q.Set("entity", f.Entity)
+	}
+	if f.EntityID != 0 {
+		q.Set("id", strconv.Itoa(f.EntityID))
+	}
+	q.Set("limit", strconv.Itoa(f.Limit))
+	q.Set("offset", strconv.Itoa(f.Offset))
+	return q
+}
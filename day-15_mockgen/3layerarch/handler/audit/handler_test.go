@@ -0,0 +1,79 @@
+package audithandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"3layerarch/models"
+	"go.uber.org/mock/gomock"
+)
+
+func TestViewAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := NewMockAuditService(ctrl)
+	handler := New(mockService)
+
+	testCases := []struct {
+		desc       string
+		url        string
+		filter     *models.AuditFilter
+		page       models.AuditPage
+		mockErr    error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			desc:   "one task",
+			url:    "/audit?entity=task&id=7&limit=1&offset=1",
+			filter: &models.AuditFilter{Entity: "task", EntityID: 7, Limit: 1, Offset: 1},
+			page: models.AuditPage{
+				Entries: []models.AuditEntry{{ID: 4, Actor: "alice", Action: models.AuditComplete, Entity: "task", EntityID: 7}},
+				Total:   3, Limit: 1, Offset: 1,
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"next":"/audit?entity=task\u0026id=7\u0026limit=1\u0026offset=2"`,
+		},
+		{
+			desc:       "invalid id",
+			url:        "/audit?entity=task&id=seven",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			desc:       "unknown entity",
+			url:        "/audit?entity=project",
+			filter:     &models.AuditFilter{Entity: "project"},
+			mockErr:    models.Validation(`unknown entity "project"`),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			desc:       "not an admin",
+			url:        "/audit",
+			filter:     &models.AuditFilter{},
+			mockErr:    models.Forbidden("only admins can read the audit log"),
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if tc.filter != nil {
+				mockService.EXPECT().ViewAudit(gomock.Any(), *tc.filter).Return(tc.page, tc.mockErr)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			w := httptest.NewRecorder()
+			handler.ViewAudit(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+			if tc.wantBody != "" && !strings.Contains(w.Body.String(), tc.wantBody) {
+				t.Errorf("expected body to contain %q, got %q", tc.wantBody, w.Body.String())
+			}
+		})
+	}
+}
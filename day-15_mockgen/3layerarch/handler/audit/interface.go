@@ -0,0 +1,11 @@
+package audithandler
+
+import (
+	"context"
+
+	"3layerarch/models"
+)
+
+type AuditService interface {
+	ViewAudit(ctx context.Context, f models.AuditFilter) (models.AuditPage, error)
+}
@@ -0,0 +1,66 @@
+package authhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"3layerarch/handler"
+	"3layerarch/models"
+)
+
+type Handler struct {
+	Users  UserService
+	Tokens TokenIssuer
+}
+
+func New(users UserService, tokens TokenIssuer) *Handler {
+	return &Handler{Users: users, Tokens: tokens}
+}
+
+// Token godoc
+// @Summary Issue a token
+// @Description Exchanges a user's name and password for a JWT bearer token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body models.Credentials true "User name and password"
+// @Success 200 {object} models.Token
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 413 {object} handler.ErrorResponse "Request Entity Too Large"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Router /auth/token [post]
+func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
+		return
+	}
+	var c models.Credentials
+	if err := json.Unmarshal(body, &c); err != nil {
+		handler.WriteBadRequest(w, "Invalid JSON input")
+		return
+	}
+	if c.Name == "" || c.Password == "" {
+		handler.WriteBadRequest(w, "name and password are required")
+		return
+	}
+	p, err := h.Users.Authenticate(r.Context(), c.Name, c.Password)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	t, err := h.Tokens.Issue(p)
+	if err != nil {
+		handler.WriteError(w, models.Internal(err))
+		return
+	}
+	// Tokens must not be kept by caches along the way
+	w.Header().Set("Cache-Control", "no-store")
+	b, _ := json.Marshal(t)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
@@ -0,0 +1,120 @@
+package authhandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"3layerarch/models"
+	"go.uber.org/mock/gomock"
+)
+
+func TestToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUsers := NewMockUserService(ctrl)
+	mockTokens := NewMockTokenIssuer(ctrl)
+	handler := New(mockUsers, mockTokens)
+
+	alice := models.Principal{UserID: 7, Name: "alice", Role: models.RoleUser}
+	token := models.Token{AccessToken: "tok", TokenType: "Bearer", ExpiresIn: 3600}
+
+	testCases := []struct {
+		desc       string
+		body       string
+		setupMock  func()
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			desc: "valid credentials",
+			body: `{"name":"alice","password":"correct horse"}`,
+			setupMock: func() {
+				mockUsers.EXPECT().Authenticate(gomock.Any(), "alice", "correct horse").Return(alice, nil)
+				mockTokens.EXPECT().Issue(alice).Return(token, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"access_token":"tok"`,
+		},
+		{
+			desc:       "empty body",
+			body:       ``,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Empty or unreadable",
+		},
+		{
+			desc:       "invalid JSON",
+			body:       `{`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Invalid JSON",
+		},
+		{
+			desc:       "missing password",
+			body:       `{"name":"alice"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "name and password are required",
+		},
+		{
+			desc: "wrong password",
+			body: `{"name":"alice","password":"nope"}`,
+			setupMock: func() {
+				mockUsers.EXPECT().Authenticate(gomock.Any(), "alice", "nope").
+					Return(models.Principal{}, models.Unauthorized("invalid name or password"))
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "invalid name or password",
+		},
+		{
+			desc: "signing fails",
+			body: `{"name":"alice","password":"correct horse"}`,
+			setupMock: func() {
+				mockUsers.EXPECT().Authenticate(gomock.Any(), "alice", "correct horse").Return(alice, nil)
+				mockTokens.EXPECT().Issue(alice).Return(models.Token{}, errors.New("sign failed"))
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "internal server error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if tc.setupMock != nil {
+				tc.setupMock()
+			}
+			req := httptest.NewRequest(http.MethodPost, "/auth/token", bytes.NewBufferString(tc.body))
+			w := httptest.NewRecorder()
+
+			handler.Token(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tc.wantBody) {
+				t.Errorf("expected body to contain %q, got %q", tc.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestToken_NotCached(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockUsers := NewMockUserService(ctrl)
+	mockTokens := NewMockTokenIssuer(ctrl)
+	handler := New(mockUsers, mockTokens)
+
+	mockUsers.EXPECT().Authenticate(gomock.Any(), "alice", "correct horse").Return(models.Principal{UserID: 7}, nil)
+	mockTokens.EXPECT().Issue(gomock.Any()).Return(models.Token{AccessToken: "tok"}, nil)
+
+	body, _ := json.Marshal(models.Credentials{Name: "alice", Password: "correct horse"})
+	w := httptest.NewRecorder()
+	handler.Token(w, httptest.NewRequest(http.MethodPost, "/auth/token", bytes.NewReader(body)))
+
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("expected Cache-Control no-store, got %q", got)
+	}
+}
@@ -0,0 +1,15 @@
+package authhandler
+
+import (
+	"context"
+
+	"3layerarch/models"
+)
+
+type UserService interface {
+	Authenticate(ctx context.Context, name, password string) (models.Principal, error)
+}
+
+type TokenIssuer interface {
+	Issue(p models.Principal) (models.Token, error)
+}
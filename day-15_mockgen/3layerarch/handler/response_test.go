@@ -0,0 +1,90 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"3layerarch/handler"
+	"3layerarch/models"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		desc    string
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{"not found", models.NotFound("task not found"), http.StatusNotFound, "not_found", "task not found"},
+		{"validation", models.Validation("task cannot be empty"), http.StatusUnprocessableEntity, "validation_failed", "task cannot be empty"},
+		{"conflict", models.Conflict("user name already taken"), http.StatusConflict, "conflict", "user name already taken"},
+		{"precondition failed", models.PreconditionFailed("task is at version 4, not 3"), http.StatusPreconditionFailed, "precondition_failed", "task is at version 4, not 3"},
+		{"unauthorized", models.Unauthorized("invalid name or password"), http.StatusUnauthorized, "unauthorized", "invalid name or password"},
+		{"forbidden", models.Forbidden("cannot assign tasks to another user"), http.StatusForbidden, "forbidden", "cannot assign tasks to another user"},
+		{"internal", models.Internal(errors.New("Error 1045: Access denied for user 'root'")), http.StatusInternalServerError, "internal_error", "internal server error"},
+		{"too large", &http.MaxBytesError{Limit: 1024}, http.StatusRequestEntityTooLarge, "too_large", "request body cannot be more than 1024 bytes"},
+		{"timeout", models.Internal(context.DeadlineExceeded), http.StatusGatewayTimeout, "timeout", "request timed out"},
+		{"cancelled", fmt.Errorf("query: %w", context.Canceled), http.StatusServiceUnavailable, "cancelled", "request cancelled"},
+		{"unknown", errors.New("dial tcp 127.0.0.1:3306: connection refused"), http.StatusInternalServerError, "internal_error", "internal server error"},
+	}
+
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		handler.WriteError(w, tc.err)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.desc, tc.status, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: expected JSON content type, got %q", tc.desc, ct)
+		}
+
+		var resp handler.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tc.desc, err)
+		}
+		if resp.Error.Code != tc.code || resp.Error.Message != tc.message {
+			t.Errorf("%s: unexpected error body: %+v", tc.desc, resp.Error)
+		}
+	}
+}
+
+func TestWriteBadRequest(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler.WriteBadRequest(w, "invalid JSON")
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+	want := `{"error":{"code":"bad_request","message":"invalid JSON"}}`
+	if strings.TrimSpace(w.Body.String()) != want {
+		t.Errorf("expected body %s, got %s", want, w.Body.String())
+	}
+}
+
+func TestWriteBodyError(t *testing.T) {
+	tests := []struct {
+		desc   string
+		err    error
+		status int
+	}{
+		{"empty", nil, http.StatusBadRequest},
+		{"unreadable", errors.New("unexpected EOF"), http.StatusBadRequest},
+		{"too large", fmt.Errorf("read: %w", &http.MaxBytesError{Limit: 10}), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		handler.WriteBodyError(w, tc.err)
+		if w.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.desc, tc.status, w.Code)
+		}
+	}
+}
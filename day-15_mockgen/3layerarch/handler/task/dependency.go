@@ -0,0 +1,248 @@
+package taskhandler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"3layerarch/handler"
+	"3layerarch/models"
+)
+
+// CompleteTask godoc
+// @Summary Complete a task
+// @Description Completes a task, and any parent whose subtasks are then all completed. A task with open blockers is only completed when forced
+// @Tags tasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param force query bool false "Complete the task even while it is blocked"
+// @Param If-Match header string false "ETag the task must still have"
+// @Success 200 {object} models.Task
+// @Header 200 {string} ETag "Version of the completed task"
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 409 {object} handler.ErrorResponse "Conflict"
+// @Failure 412 {object} handler.ErrorResponse "Precondition Failed"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /task/{id}/complete [post]
+func (h *Handler) CompleteTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	force := false
+	if v := r.URL.Query().Get("force"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			handler.WriteBadRequest(w, "invalid force value")
+			return
+		}
+		force = b
+	}
+	version, err := ifMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	t, err := h.Service.CompleteTask(r.Context(), id, force, version)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(t))
+	writeJSON(w, t)
+}
+
+// TaskTree godoc
+// @Summary Get a task tree
+// @Description Returns a task with the IDs of its blockers and, nested, its subtasks
+// @Tags tasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {object} models.TaskTree
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /task/{id}/tree [get]
+func (h *Handler) TaskTree(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	tree, err := h.Service.TaskTree(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, tree)
+}
+
+// ViewBlockers godoc
+// @Summary List a task's blockers
+// @Description Lists the tasks that must be completed before a task, by ID
+// @Tags tasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.Task
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /task/{id}/dependencies [get]
+func (h *Handler) ViewBlockers(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	blockers, err := h.Service.ViewBlockers(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, blockers)
+}
+
+// AddDependency godoc
+// @Summary Block a task
+// @Description Makes one task block another, unless that would create a cycle, and returns the task's blockers
+// @Tags tasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param blocker_id path int true "ID of the blocking task"
+// @Success 200 {array} models.Task
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 409 {object} handler.ErrorResponse "Conflict"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /task/{id}/dependencies/{blocker_id} [put]
+func (h *Handler) AddDependency(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	blockerID, ok := pathID(w, r, "blocker_id")
+	if !ok {
+		return
+	}
+	blockers, err := h.Service.AddDependency(r.Context(), id, blockerID)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, blockers)
+}
+
+// RemoveDependency godoc
+// @Summary Unblock a task
+// @Description Stops one task blocking another and returns the task's blockers
+// @Tags tasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param blocker_id path int true "ID of the blocking task"
+// @Success 200 {array} models.Task
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /task/{id}/dependencies/{blocker_id} [delete]
+func (h *Handler) RemoveDependency(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	blockerID, ok := pathID(w, r, "blocker_id")
+	if !ok {
+		return
+	}
+	blockers, err := h.Service.RemoveDependency(r.Context(), id, blockerID)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, blockers)
+}
+
+// TaskGraph godoc
+// @Summary Export the dependency graph
+// @Description Exports the tasks that block or are blocked, with their dependencies, as JSON or as a Graphviz digraph. Users only get their own tasks
+// @Tags tasks
+// @Produce json
+// @Produce text/vnd.graphviz
+// @Param user_id query int false "Only tasks owned by this user"
+// @Param format query string false "json (the default) or dot"
+// @Success 200 {object} models.TaskGraph
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /task/graph [get]
+func (h *Handler) TaskGraph(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "dot" {
+		handler.WriteBadRequest(w, "format must be json or dot")
+		return
+	}
+	var userID *int
+	if v := q.Get("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			handler.WriteBadRequest(w, "invalid user_id value")
+			return
+		}
+		userID = &id
+	}
+	g, err := h.Service.TaskGraph(r.Context(), userID)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	if format != "dot" {
+		writeJSON(w, g)
+		return
+	}
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+	if _, err := w.Write([]byte(dot(g))); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// dot renders g in the Graphviz DOT language, with an edge from each
+// blocker to the task it blocks and completed tasks greyed out.
+func dot(g models.TaskGraph) string {
+	var b strings.Builder
+	b.WriteString("digraph tasks {\n")
+	for _, t := range g.Tasks {
+		fmt.Fprintf(&b, "\ttask%d [label=%s", t.ID, strconv.Quote(fmt.Sprintf("#%d %s", t.ID, t.Task)))
+		if t.Completed {
+			b.WriteString(", color=gray, fontcolor=gray")
+		}
+		b.WriteString("];\n")
+	}
+	for _, d := range g.Dependencies {
+		fmt.Fprintf(&b, "\ttask%d -> task%d;\n", d.BlockerID, d.TaskID)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
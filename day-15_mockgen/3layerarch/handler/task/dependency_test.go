@@ -0,0 +1,156 @@
+package taskhandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"3layerarch/models"
+	"go.uber.org/mock/gomock"
+)
+
+func TestDependencyHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := NewMockTaskService(ctrl)
+	handler := New(mockService)
+
+	done := models.Task{ID: 2, Task: "Ship", Completed: true, UserID: 1, Version: 5}
+	test3 := models.Task{ID: 3, Task: "Test", UserID: 1, Version: 1}
+	user := 1
+
+	tests := []struct {
+		desc      string
+		target    string
+		path      map[string]string
+		ifMatch   string
+		setupMock func()
+		serve     http.HandlerFunc
+		wantCode  int
+		wantBody  string
+		wantETag  string
+	}{
+		{
+			desc: "Complete", target: "/task/2/complete", path: map[string]string{"id": "2"}, ifMatch: `"4"`,
+			setupMock: func() { mockService.EXPECT().CompleteTask(gomock.Any(), 2, false, 4).Return(done, nil) },
+			serve:     handler.CompleteTask, wantCode: http.StatusOK, wantETag: `"5"`,
+		},
+		{
+			desc: "Complete Blocked", target: "/task/2/complete", path: map[string]string{"id": "2"},
+			setupMock: func() {
+				mockService.EXPECT().CompleteTask(gomock.Any(), 2, false, 0).
+					Return(models.Task{}, models.Conflict("task is blocked by open tasks; complete them first or force it"))
+			},
+			serve: handler.CompleteTask, wantCode: http.StatusConflict,
+		},
+		{
+			desc: "Complete Forced", target: "/task/2/complete?force=true", path: map[string]string{"id": "2"},
+			setupMock: func() { mockService.EXPECT().CompleteTask(gomock.Any(), 2, true, 0).Return(done, nil) },
+			serve:     handler.CompleteTask, wantCode: http.StatusOK,
+		},
+		{
+			desc: "Complete Invalid Force", target: "/task/2/complete?force=maybe", path: map[string]string{"id": "2"},
+			serve: handler.CompleteTask, wantCode: http.StatusBadRequest,
+		},
+		{
+			desc: "Tree", target: "/task/1/tree", path: map[string]string{"id": "1"},
+			setupMock: func() {
+				mockService.EXPECT().TaskTree(gomock.Any(), 1).Return(models.TaskTree{
+					Task: models.Task{ID: 1, Task: "Release", UserID: 1}, BlockedBy: []int{}, Subtasks: []models.TaskTree{
+						{Task: models.Task{ID: 2, Task: "Ship", UserID: 1, ParentID: 1}, BlockedBy: []int{3}, Subtasks: []models.TaskTree{}},
+					},
+				}, nil)
+			},
+			serve: handler.TaskTree, wantCode: http.StatusOK,
+		},
+		{
+			desc: "Tree Not Found", target: "/task/9/tree", path: map[string]string{"id": "9"},
+			setupMock: func() {
+				mockService.EXPECT().TaskTree(gomock.Any(), 9).Return(models.TaskTree{}, models.NotFound("task not found"))
+			},
+			serve: handler.TaskTree, wantCode: http.StatusNotFound,
+		},
+		{
+			desc: "Blockers", target: "/task/2/dependencies", path: map[string]string{"id": "2"},
+			setupMock: func() { mockService.EXPECT().ViewBlockers(gomock.Any(), 2).Return([]models.Task{test3}, nil) },
+			serve:     handler.ViewBlockers, wantCode: http.StatusOK,
+		},
+		{
+			desc: "Add", target: "/task/2/dependencies/3", path: map[string]string{"id": "2", "blocker_id": "3"},
+			setupMock: func() { mockService.EXPECT().AddDependency(gomock.Any(), 2, 3).Return([]models.Task{test3}, nil) },
+			serve:     handler.AddDependency, wantCode: http.StatusOK,
+		},
+		{
+			desc: "Add Cycle", target: "/task/3/dependencies/2", path: map[string]string{"id": "3", "blocker_id": "2"},
+			setupMock: func() {
+				mockService.EXPECT().AddDependency(gomock.Any(), 3, 2).Return(nil, models.Conflict("dependency would create a cycle"))
+			},
+			serve: handler.AddDependency, wantCode: http.StatusConflict,
+		},
+		{
+			desc: "Add Invalid Blocker ID", target: "/task/2/dependencies/x", path: map[string]string{"id": "2", "blocker_id": "x"},
+			serve: handler.AddDependency, wantCode: http.StatusBadRequest,
+		},
+		{
+			desc: "Remove", target: "/task/2/dependencies/3", path: map[string]string{"id": "2", "blocker_id": "3"},
+			setupMock: func() { mockService.EXPECT().RemoveDependency(gomock.Any(), 2, 3).Return([]models.Task{}, nil) },
+			serve:     handler.RemoveDependency, wantCode: http.StatusOK, wantBody: `[]`,
+		},
+		{
+			desc: "Graph", target: "/task/graph?user_id=1",
+			setupMock: func() {
+				mockService.EXPECT().TaskGraph(gomock.Any(), &user).
+					Return(models.TaskGraph{Tasks: []models.Task{}, Dependencies: []models.Dependency{}}, nil)
+			},
+			serve: handler.TaskGraph, wantCode: http.StatusOK, wantBody: `{"tasks":[],"dependencies":[]}`,
+		},
+		{
+			desc: "Graph As Dot", target: "/task/graph?format=dot",
+			setupMock: func() {
+				mockService.EXPECT().TaskGraph(gomock.Any(), nil).Return(models.TaskGraph{
+					Tasks:        []models.Task{done, {ID: 3, Task: `Say "done"`, UserID: 1}},
+					Dependencies: []models.Dependency{{TaskID: 3, BlockerID: 2}},
+				}, nil)
+			},
+			serve: handler.TaskGraph, wantCode: http.StatusOK,
+			wantBody: "digraph tasks {\n" +
+				"\ttask2 [label=\"#2 Ship\", color=gray, fontcolor=gray];\n" +
+				"\ttask3 [label=\"#3 Say \\\"done\\\"\"];\n" +
+				"\ttask2 -> task3;\n" +
+				"}",
+		},
+		{
+			desc: "Graph Invalid User ID", target: "/task/graph?user_id=x",
+			serve: handler.TaskGraph, wantCode: http.StatusBadRequest,
+		},
+		{
+			desc: "Graph Unknown Format", target: "/task/graph?format=svg",
+			serve: handler.TaskGraph, wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		if test.setupMock != nil {
+			test.setupMock()
+		}
+		req := httptest.NewRequest(http.MethodGet, test.target, nil)
+		for name, value := range test.path {
+			req.SetPathValue(name, value)
+		}
+		if test.ifMatch != "" {
+			req.Header.Set("If-Match", test.ifMatch)
+		}
+		w := httptest.NewRecorder()
+
+		test.serve(w, req)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: expected %d, got %d", test.desc, test.wantCode, w.Code)
+		}
+		if test.wantBody != "" && strings.TrimSpace(w.Body.String()) != test.wantBody {
+			t.Errorf("%s: expected body %q, got %q", test.desc, test.wantBody, w.Body.String())
+		}
+		if test.wantETag != "" && w.Header().Get("ETag") != test.wantETag {
+			t.Errorf("%s: expected ETag %s, got %s", test.desc, test.wantETag, w.Header().Get("ETag"))
+		}
+	}
+}
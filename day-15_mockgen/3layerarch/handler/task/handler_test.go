@@ -2,13 +2,16 @@ package taskhandler
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"3layerarch/handler"
 	"3layerarch/models"
 	"go.uber.org/mock/gomock"
 )
@@ -29,7 +32,7 @@ func TestGetTask(t *testing.T) {
 		req.SetPathValue("id", "1")
 		w := httptest.NewRecorder()
 
-		mockService.EXPECT().GetTask(1).Return(models.Task{ID: 1, Task: "test"}, nil)
+		mockService.EXPECT().GetTask(gomock.Any(), 1).Return(models.Task{ID: 1, Task: "test"}, nil)
 
 		handler.GetTask(w, req)
 		res := w.Result()
@@ -70,15 +73,45 @@ func TestGetTask(t *testing.T) {
 		req.SetPathValue("id", "2")
 		w := httptest.NewRecorder()
 
-		mockService.EXPECT().GetTask(2).Return(models.Task{}, errors.New("task not found"))
+		mockService.EXPECT().GetTask(gomock.Any(), 2).Return(models.Task{}, models.NotFound("task not found"))
 
 		handler.GetTask(w, req)
-		if w.Code != http.StatusBadRequest {
-			t.Errorf("expected 400, got %d", w.Code)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", w.Code)
 		}
 	}
 }
 
+func TestGetTask_Timeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := NewMockTaskService(ctrl)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks/{id}", New(mockService).GetTask)
+	srv := handler.Timeout(10*time.Millisecond, mux)
+
+	// A slow query that gives up when the request does
+	slow := func(ctx context.Context, _ int) (models.Task, error) {
+		<-ctx.Done()
+		return models.Task{}, ctx.Err()
+	}
+	mockService.EXPECT().GetTask(gomock.Any(), 1).DoAndReturn(slow).Times(2)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/1", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", w.Code)
+	}
+
+	// A client that hangs up cancels the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/1", nil).WithContext(ctx))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+}
+
 func TestCreateTask(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	mockService := NewMockTaskService(ctrl)
@@ -91,12 +124,23 @@ func TestCreateTask(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		task := models.Task{Task: "new task", Completed: false, UserID: 1}
-		mockService.EXPECT().CreateTask(task).Return(nil)
+		created := task
+		created.ID, created.Version = 3, 1
+		mockService.EXPECT().CreateTask(gomock.Any(), task).Return(created, nil)
 
 		handler.CreateTask(w, req)
 		if w.Code != http.StatusCreated {
 			t.Errorf("expected 201, got %d", w.Code)
 		}
+		if loc := w.Header().Get("Location"); loc != "/task/3" {
+			t.Errorf("expected Location /task/3, got %q", loc)
+		}
+		if tag := w.Header().Get("ETag"); tag != `"1"` {
+			t.Errorf("expected ETag \"1\", got %q", tag)
+		}
+		if !strings.Contains(w.Body.String(), `"id":3`) {
+			t.Errorf("expected the created task in the body, got %s", w.Body.String())
+		}
 	}
 
 	// empty body
@@ -128,11 +172,11 @@ func TestCreateTask(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		task := models.Task{Task: "fail", Completed: false, UserID: 1}
-		mockService.EXPECT().CreateTask(task).Return(errors.New("fail"))
+		mockService.EXPECT().CreateTask(gomock.Any(), task).Return(models.Task{}, errors.New("fail"))
 
 		handler.CreateTask(w, req)
-		if w.Code != http.StatusBadRequest {
-			t.Errorf("expected 400, got %d", w.Code)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected 500, got %d", w.Code)
 		}
 	}
 }
@@ -144,7 +188,8 @@ func TestViewTasks(t *testing.T) {
 
 	// success
 	{
-		mockService.EXPECT().ViewTasks().Return([]models.Task{{ID: 1, Task: "t"}}, nil)
+		mockService.EXPECT().ViewTasks(gomock.Any(), models.TaskFilter{}).
+			Return(models.TaskPage{Tasks: []models.Task{{ID: 1, Task: "t"}}, Total: 1, Limit: 20}, nil)
 		req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
 		w := httptest.NewRecorder()
 
@@ -156,18 +201,161 @@ func TestViewTasks(t *testing.T) {
 		if !strings.Contains(w.Body.String(), `"id":1`) {
 			t.Errorf("expected body to contain task id 1")
 		}
+		if strings.Contains(w.Body.String(), `"next"`) {
+			t.Errorf("expected no next link on the only page, got %s", w.Body.String())
+		}
+	}
+
+	// filters are parsed and carried over to the next link
+	{
+		done, user, label, overdue := true, 3, 4, false
+		filter := models.TaskFilter{Completed: &done, UserID: &user, LabelID: &label, Priority: models.PriorityHigh, Overdue: &overdue, Search: "milk", Sort: "-id", Limit: 1}
+		mockService.EXPECT().ViewTasks(gomock.Any(), filter).
+			Return(models.TaskPage{Tasks: []models.Task{{ID: 9, Task: "buy milk"}}, Total: 2, Limit: 1}, nil)
+		req := httptest.NewRequest(http.MethodGet, "/tasks?completed=true&user_id=3&label_id=4&priority=high&overdue=false&q=milk&sort=-id&limit=1", nil)
+		w := httptest.NewRecorder()
+
+		handler.ViewTasks(w, req)
+
+		want := `"next":"/tasks?completed=true\u0026label_id=4\u0026limit=1\u0026offset=1\u0026overdue=false\u0026priority=high\u0026q=milk\u0026sort=-id\u0026user_id=3"`
+		if !strings.Contains(w.Body.String(), want) || !strings.Contains(w.Body.String(), `"total":2`) {
+			t.Errorf("expected total and next link, got %s", w.Body.String())
+		}
+	}
+
+	// time bounds are parsed as RFC 3339 and carried over in their zone
+	{
+		after := time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("", 2*60*60))
+		before := time.Date(2026, 2, 1, 0, 0, 0, 5e8, time.UTC)
+		filter := models.TaskFilter{CreatedAfter: after, CompletedBefore: before, Limit: 1}
+		mockService.EXPECT().ViewTasks(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, f models.TaskFilter) (models.TaskPage, error) {
+				if !f.CreatedAfter.Equal(filter.CreatedAfter) || !f.CompletedBefore.Equal(filter.CompletedBefore) {
+					t.Errorf("expected filter %+v, got %+v", filter, f)
+				}
+				return models.TaskPage{Tasks: []models.Task{{ID: 9}}, Total: 2, Limit: 1}, nil
+			})
+		req := httptest.NewRequest(http.MethodGet, "/tasks?created_after=2026-01-02T03:04:05%2B02:00&completed_before=2026-02-01T00:00:00.5Z&limit=1", nil)
+		w := httptest.NewRecorder()
+
+		handler.ViewTasks(w, req)
+
+		want := `"next":"/tasks?completed_before=2026-02-01T00%3A00%3A00.5Z\u0026created_after=2026-01-02T03%3A04%3A05%2B02%3A00\u0026limit=1\u0026offset=1"`
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected next link %s, got %s", want, w.Body.String())
+		}
+	}
+
+	// invalid query
+	for _, query := range []string{"completed=maybe", "label_id=work", "overdue=soon", "created_after=yesterday", "completed_before=2026-01-02"} {
+		req := httptest.NewRequest(http.MethodGet, "/tasks?"+query, nil)
+		w := httptest.NewRecorder()
+
+		handler.ViewTasks(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
 	}
 
 	// failure
 	{
-		mockService.EXPECT().ViewTasks().Return(nil, errors.New("db error"))
-		req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+		mockService.EXPECT().ViewTasks(gomock.Any(), gomock.Any()).Return(models.TaskPage{}, models.Validation("limit must be between 1 and 100"))
+		req := httptest.NewRequest(http.MethodGet, "/tasks?limit=500", nil)
 		w := httptest.NewRecorder()
 
 		handler.ViewTasks(w, req)
 
-		if w.Code != http.StatusInternalServerError {
-			t.Errorf("expected 500, got %d", w.Code)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected 422, got %d", w.Code)
+		}
+	}
+}
+
+func TestViewTrash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := NewMockTaskService(ctrl)
+	handler := New(mockService)
+
+	// the trash is the task listing of the deleted tasks
+	{
+		deletedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		mockService.EXPECT().ViewTasks(gomock.Any(), models.TaskFilter{Deleted: true, Limit: 1}).
+			Return(models.TaskPage{Tasks: []models.Task{{ID: 9, Task: "t", DeletedAt: deletedAt}}, Total: 2, Limit: 1}, nil)
+		req := httptest.NewRequest(http.MethodGet, "/trash?limit=1", nil)
+		w := httptest.NewRecorder()
+
+		handler.ViewTrash(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", w.Code)
+		}
+		for _, want := range []string{`"deleted_at":"2026-01-02T03:04:05Z"`, `"next":"/trash?limit=1\u0026offset=1"`} {
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("expected body to contain %s, got %s", want, w.Body.String())
+			}
+		}
+	}
+
+	// invalid query
+	{
+		req := httptest.NewRequest(http.MethodGet, "/trash?completed=maybe", nil)
+		w := httptest.NewRecorder()
+
+		handler.ViewTrash(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", w.Code)
+		}
+	}
+}
+
+func TestViewUserTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := NewMockTaskService(ctrl)
+	handler := New(mockService)
+
+	// success, the user comes from the path and is not repeated in the next link
+	{
+		done := false
+		mockService.EXPECT().ViewUserTasks(gomock.Any(), 3, models.TaskFilter{Completed: &done, Limit: 1}).
+			Return(models.TaskPage{Tasks: []models.Task{{ID: 9, Task: "t", UserID: 3}}, Total: 2, Limit: 1}, nil)
+		req := httptest.NewRequest(http.MethodGet, "/user/3/tasks?completed=false&limit=1", nil)
+		req.SetPathValue("id", "3")
+		w := httptest.NewRecorder()
+
+		handler.ViewUserTasks(w, req)
+
+		want := `"next":"/user/3/tasks?completed=false\u0026limit=1\u0026offset=1"`
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected 200 with next link, got %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	// unknown user
+	{
+		mockService.EXPECT().ViewUserTasks(gomock.Any(), 9, models.TaskFilter{}).Return(models.TaskPage{}, models.NotFound("user not found"))
+		req := httptest.NewRequest(http.MethodGet, "/user/9/tasks", nil)
+		req.SetPathValue("id", "9")
+		w := httptest.NewRecorder()
+
+		handler.ViewUserTasks(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", w.Code)
+		}
+	}
+
+	// bad requests never reach the service
+	for _, target := range []string{"/user/abc/tasks", "/user/3/tasks?user_id=4", "/user/3/tasks?limit=ten"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetPathValue("id", strings.Split(target, "/")[2])
+		w := httptest.NewRecorder()
+
+		handler.ViewUserTasks(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, w.Code)
 		}
 	}
 }
@@ -177,23 +365,36 @@ func TestUpdateTask(t *testing.T) {
 	mockService := NewMockTaskService(ctrl)
 	handler := New(mockService)
 
+	body := `{"task":"renamed","completed":true,"user_id":2}`
+
 	// valid
 	{
-		req := httptest.NewRequest(http.MethodPut, "/tasks/1", nil)
+		req := httptest.NewRequest(http.MethodPut, "/tasks/1", bytes.NewBufferString(body))
 		req.SetPathValue("id", "1")
 		w := httptest.NewRecorder()
 
-		mockService.EXPECT().UpdateTask(1).Return(nil)
+		task := models.Task{Task: "renamed", Completed: true, UserID: 2}
+		day := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		updated := models.Task{ID: 1, Task: "renamed", Completed: true, UserID: 2, Version: 4, CreatedAt: day, UpdatedAt: day.AddDate(0, 0, 1), CompletedAt: day.AddDate(0, 0, 1)}
+		mockService.EXPECT().UpdateTask(gomock.Any(), 1, task, 0).Return(updated, nil)
 
 		handler.UpdateTask(w, req)
 		if w.Code != http.StatusOK {
 			t.Errorf("expected 200, got %d", w.Code)
 		}
+		want := `{"id":1,"task":"renamed","completed":true,"user_id":2,"version":4,` +
+			`"created_at":"2026-01-02T03:04:05Z","updated_at":"2026-01-03T03:04:05Z","completed_at":"2026-01-03T03:04:05Z"}`
+		if w.Body.String() != want {
+			t.Errorf("expected body %s, got %s", want, w.Body.String())
+		}
+		if tag := w.Header().Get("ETag"); tag != `"4"` {
+			t.Errorf("expected ETag \"4\", got %q", tag)
+		}
 	}
 
 	// missing id
 	{
-		req := httptest.NewRequest(http.MethodPut, "/tasks", nil)
+		req := httptest.NewRequest(http.MethodPut, "/tasks", bytes.NewBufferString(body))
 		w := httptest.NewRecorder()
 
 		handler.UpdateTask(w, req)
@@ -204,7 +405,7 @@ func TestUpdateTask(t *testing.T) {
 
 	// invalid id
 	{
-		req := httptest.NewRequest(http.MethodPut, "/tasks/abc", nil)
+		req := httptest.NewRequest(http.MethodPut, "/tasks/abc", bytes.NewBufferString(body))
 		req.SetPathValue("id", "abc")
 		w := httptest.NewRecorder()
 
@@ -214,19 +415,136 @@ func TestUpdateTask(t *testing.T) {
 		}
 	}
 
+	// empty body
+	{
+		req := httptest.NewRequest(http.MethodPut, "/tasks/1", nil)
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+
+		handler.UpdateTask(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", w.Code)
+		}
+	}
+
 	// service error
 	{
-		req := httptest.NewRequest(http.MethodPut, "/tasks/2", nil)
+		req := httptest.NewRequest(http.MethodPut, "/tasks/2", bytes.NewBufferString(body))
 		req.SetPathValue("id", "2")
 		w := httptest.NewRecorder()
 
-		mockService.EXPECT().UpdateTask(2).Return(errors.New("not found"))
+		mockService.EXPECT().UpdateTask(gomock.Any(), 2, gomock.Any(), 0).Return(models.Task{}, models.NotFound("task not found"))
 
 		handler.UpdateTask(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", w.Code)
+		}
+	}
+}
+
+func TestPatchTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := NewMockTaskService(ctrl)
+	handler := New(mockService)
+
+	// valid
+	{
+		req := httptest.NewRequest(http.MethodPatch, "/tasks/1", bytes.NewBufferString(`{"completed":true}`))
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+
+		done := true
+		mockService.EXPECT().PatchTask(gomock.Any(), 1, models.TaskPatch{Completed: &done}, 0).
+			Return(models.Task{ID: 1, Task: "test", Completed: true, UserID: 1}, nil)
+
+		handler.PatchTask(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `"completed":true`) {
+			t.Errorf("expected patched task in body, got %s", w.Body.String())
+		}
+	}
+
+	// a priority and due date are set, and removed with null
+	{
+		urgent, due := models.PriorityUrgent, time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+		mockService.EXPECT().PatchTask(gomock.Any(), 1, models.TaskPatch{Priority: &urgent, DueAt: &due}, 0).
+			Return(models.Task{ID: 1, Task: "test", UserID: 1, Priority: urgent, DueAt: due}, nil)
+		req := httptest.NewRequest(http.MethodPatch, "/tasks/1", bytes.NewBufferString(`{"priority":"urgent","due_at":"2026-02-01T09:00:00Z"}`))
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+
+		handler.PatchTask(w, req)
+		if !strings.Contains(w.Body.String(), `"priority":"urgent","due_at":"2026-02-01T09:00:00Z"`) {
+			t.Errorf("expected the priority and due date set, got %s", w.Body.String())
+		}
+
+		none, never := models.Priority(""), time.Time{}
+		mockService.EXPECT().PatchTask(gomock.Any(), 1, models.TaskPatch{Priority: &none, DueAt: &never}, 0).
+			Return(models.Task{ID: 1, Task: "test", UserID: 1}, nil)
+		req = httptest.NewRequest(http.MethodPatch, "/tasks/1", bytes.NewBufferString(`{"priority":null,"due_at":null}`))
+		req.SetPathValue("id", "1")
+		w = httptest.NewRecorder()
+
+		handler.PatchTask(w, req)
+		if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "due_at") {
+			t.Errorf("expected the priority and due date removed, got %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	// a null parent makes the task a top-level one
+	{
+		top := 0
+		mockService.EXPECT().PatchTask(gomock.Any(), 1, models.TaskPatch{ParentID: &top}, 0).
+			Return(models.Task{ID: 1, Task: "test", UserID: 1}, nil)
+		req := httptest.NewRequest(http.MethodPatch, "/tasks/1", bytes.NewBufferString(`{"parent_id":null}`))
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+
+		handler.PatchTask(w, req)
+		if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "parent_id") {
+			t.Errorf("expected the parent removed, got %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	// invalid patches never reach the service
+	for _, body := range []string{"", "{", `{"task":null}`, `{"title":"x"}`, `{"due_at":"tomorrow"}`} {
+		req := httptest.NewRequest(http.MethodPatch, "/tasks/1", bytes.NewBufferString(body))
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+
+		handler.PatchTask(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %q, got %d", body, w.Code)
+		}
+	}
+
+	// invalid id
+	{
+		req := httptest.NewRequest(http.MethodPatch, "/tasks/abc", bytes.NewBufferString(`{"completed":true}`))
+		req.SetPathValue("id", "abc")
+		w := httptest.NewRecorder()
+
+		handler.PatchTask(w, req)
 		if w.Code != http.StatusBadRequest {
 			t.Errorf("expected 400, got %d", w.Code)
 		}
 	}
+
+	// service error
+	{
+		req := httptest.NewRequest(http.MethodPatch, "/tasks/2", bytes.NewBufferString(`{"task":""}`))
+		req.SetPathValue("id", "2")
+		w := httptest.NewRecorder()
+
+		mockService.EXPECT().PatchTask(gomock.Any(), 2, gomock.Any(), 0).Return(models.Task{}, models.Validation("task cannot be empty"))
+
+		handler.PatchTask(w, req)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected 422, got %d", w.Code)
+		}
+	}
 }
 
 func TestDeleteTask(t *testing.T) {
@@ -240,7 +558,7 @@ func TestDeleteTask(t *testing.T) {
 		req.SetPathValue("id", "1")
 		w := httptest.NewRecorder()
 
-		mockService.EXPECT().DeleteTask(1).Return(nil)
+		mockService.EXPECT().DeleteTask(gomock.Any(), 1, 0).Return(nil)
 
 		handler.DeleteTask(w, req)
 		if w.Code != http.StatusOK {
@@ -277,7 +595,149 @@ func TestDeleteTask(t *testing.T) {
 		req.SetPathValue("id", "2")
 		w := httptest.NewRecorder()
 
-		mockService.EXPECT().DeleteTask(2).Return(errors.New("not found"))
+		mockService.EXPECT().DeleteTask(gomock.Any(), 2, 0).Return(models.NotFound("task not found"))
+
+		handler.DeleteTask(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", w.Code)
+		}
+	}
+}
+
+func TestRestoreTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := NewMockTaskService(ctrl)
+	handler := New(mockService)
+
+	// valid
+	{
+		req := httptest.NewRequest(http.MethodPost, "/task/1/restore", nil)
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+
+		mockService.EXPECT().RestoreTask(gomock.Any(), 1).Return(models.Task{ID: 1, Task: "t", UserID: 2, Version: 3}, nil)
+
+		handler.RestoreTask(w, req)
+		if w.Code != http.StatusOK || w.Header().Get("ETag") != `"3"` {
+			t.Errorf("expected 200 with ETag \"3\", got %d and %q", w.Code, w.Header().Get("ETag"))
+		}
+	}
+
+	// invalid id
+	{
+		req := httptest.NewRequest(http.MethodPost, "/task/abc/restore", nil)
+		req.SetPathValue("id", "abc")
+		w := httptest.NewRecorder()
+
+		handler.RestoreTask(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", w.Code)
+		}
+	}
+
+	// the user is in the trash
+	{
+		req := httptest.NewRequest(http.MethodPost, "/task/2/restore", nil)
+		req.SetPathValue("id", "2")
+		w := httptest.NewRecorder()
+
+		mockService.EXPECT().RestoreTask(gomock.Any(), 2).Return(models.Task{}, models.Conflict("the task's user is in the trash and must be restored first"))
+
+		handler.RestoreTask(w, req)
+		if w.Code != http.StatusConflict {
+			t.Errorf("expected 409, got %d", w.Code)
+		}
+	}
+}
+
+func TestGetTask_IfNoneMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := NewMockTaskService(ctrl)
+	handler := New(mockService)
+
+	tests := []struct {
+		ifNoneMatch string
+		wantCode    int
+	}{
+		{"", http.StatusOK},
+		{`"2"`, http.StatusOK},
+		{`"3"`, http.StatusNotModified},
+		{`W/"3"`, http.StatusNotModified},
+		{`"1", "3"`, http.StatusNotModified},
+		{"*", http.StatusNotModified},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/tasks/1", nil)
+		req.SetPathValue("id", "1")
+		if tc.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", tc.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+
+		mockService.EXPECT().GetTask(gomock.Any(), 1).Return(models.Task{ID: 1, Task: "test", UserID: 1, Version: 3}, nil)
+
+		handler.GetTask(w, req)
+		if w.Code != tc.wantCode {
+			t.Errorf("If-None-Match %s: expected %d, got %d", tc.ifNoneMatch, tc.wantCode, w.Code)
+		}
+		if tag := w.Header().Get("ETag"); tag != `"3"` {
+			t.Errorf("If-None-Match %s: expected ETag \"3\", got %q", tc.ifNoneMatch, tag)
+		}
+		if tc.wantCode == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: expected no body, got %s", tc.ifNoneMatch, w.Body.String())
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"*", 0, false},
+		{`"3"`, 3, false},
+		{` "3" `, 3, false},
+		{`W/"3"`, noVersion, false},
+		{`"abc"`, noVersion, false},
+		{"3", noVersion, false},
+		{`"2", "3"`, 0, true},
+	}
+	for _, tc := range tests {
+		got, err := ifMatch(tc.header)
+		if got != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf("ifMatch(%q): expected %d, error %v, got %d, %v", tc.header, tc.want, tc.wantErr, got, err)
+		}
+	}
+}
+
+func TestDeleteTask_IfMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := NewMockTaskService(ctrl)
+	handler := New(mockService)
+
+	// The version is passed on for the service to compare
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/tasks/1", nil)
+		req.SetPathValue("id", "1")
+		req.Header.Set("If-Match", `"2"`)
+		w := httptest.NewRecorder()
+
+		mockService.EXPECT().DeleteTask(gomock.Any(), 1, 2).Return(models.PreconditionFailed("task is at version 3, not 2"))
+
+		handler.DeleteTask(w, req)
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("expected 412, got %d", w.Code)
+		}
+	}
+
+	// A list of tags is refused before the service is asked
+	{
+		req := httptest.NewRequest(http.MethodDelete, "/tasks/1", nil)
+		req.SetPathValue("id", "1")
+		req.Header.Set("If-Match", `"2", "3"`)
+		w := httptest.NewRecorder()
 
 		handler.DeleteTask(w, req)
 		if w.Code != http.StatusBadRequest {
@@ -1,11 +1,33 @@
 package taskhandler
 
-import "3layerarch/models"
+import (
+	"context"
+
+	"3layerarch/models"
+)
 
 type TaskService interface {
-	CreateTask(t models.Task) error
-	GetTask(id int) (models.Task, error)
-	ViewTasks() ([]models.Task, error)
-	UpdateTask(id int) error
-	DeleteTask(id int) error
+	CreateTask(ctx context.Context, t models.Task) (models.Task, error)
+	GetTask(ctx context.Context, id int) (models.Task, error)
+	ViewTasks(ctx context.Context, f models.TaskFilter) (models.TaskPage, error)
+	ViewUserTasks(ctx context.Context, userID int, f models.TaskFilter) (models.TaskPage, error)
+	UpdateTask(ctx context.Context, id int, t models.Task, version int) (models.Task, error)
+	PatchTask(ctx context.Context, id int, p models.TaskPatch, version int) (models.Task, error)
+	DeleteTask(ctx context.Context, id int, version int) error
+	RestoreTask(ctx context.Context, id int) (models.Task, error)
+	CompleteTask(ctx context.Context, id int, force bool, version int) (models.Task, error)
+	TaskTree(ctx context.Context, id int) (models.TaskTree, error)
+	ViewBlockers(ctx context.Context, taskID int) ([]models.Task, error)
+	AddDependency(ctx context.Context, taskID, blockerID int) ([]models.Task, error)
+	RemoveDependency(ctx context.Context, taskID, blockerID int) ([]models.Task, error)
+	TaskGraph(ctx context.Context, userID *int) (models.TaskGraph, error)
+
+	CreateLabel(ctx context.Context, l models.Label) (models.Label, error)
+	GetLabel(ctx context.Context, id int) (models.Label, error)
+	ViewLabels(ctx context.Context) ([]models.Label, error)
+	UpdateLabel(ctx context.Context, id int, l models.Label) (models.Label, error)
+	DeleteLabel(ctx context.Context, id int) error
+	ViewTaskLabels(ctx context.Context, taskID int) ([]models.Label, error)
+	AddTaskLabel(ctx context.Context, taskID, labelID int) ([]models.Label, error)
+	RemoveTaskLabel(ctx context.Context, taskID, labelID int) ([]models.Label, error)
 }
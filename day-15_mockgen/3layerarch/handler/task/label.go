@@ -0,0 +1,285 @@
+package taskhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"3layerarch/handler"
+	"3layerarch/models"
+)
+
+// CreateLabel godoc
+// @Summary Create a label
+// @Description Creates a label that tasks can then be given; names are unique
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param label body models.Label true "Label to create"
+// @Success 201 {object} models.Label
+// @Header 201 {string} Location "URL of the new label"
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 409 {object} handler.ErrorResponse "Conflict"
+// @Failure 413 {object} handler.ErrorResponse "Request Entity Too Large"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /label [post]
+func (h *Handler) CreateLabel(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
+		return
+	}
+	var l models.Label
+	if err := json.Unmarshal(body, &l); err != nil {
+		handler.WriteBadRequest(w, "Invalid JSON input")
+		return
+	}
+	created, err := h.Service.CreateLabel(r.Context(), l)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/label/"+strconv.Itoa(created.ID))
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, created)
+}
+
+// GetLabel godoc
+// @Summary Get a label
+// @Tags labels
+// @Produce json
+// @Param id path int true "Label ID"
+// @Success 200 {object} models.Label
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /label/{id} [get]
+func (h *Handler) GetLabel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	l, err := h.Service.GetLabel(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, l)
+}
+
+// ViewLabels godoc
+// @Summary List labels
+// @Description Returns every label ordered by name
+// @Tags labels
+// @Produce json
+// @Success 200 {array} models.Label
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /label [get]
+func (h *Handler) ViewLabels(w http.ResponseWriter, r *http.Request) {
+	labels, err := h.Service.ViewLabels(r.Context())
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, labels)
+}
+
+// UpdateLabel godoc
+// @Summary Rename a label
+// @Description Renames a label; only admins can
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param id path int true "Label ID"
+// @Param label body models.Label true "New label name"
+// @Success 200 {object} models.Label
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 403 {object} handler.ErrorResponse "Forbidden"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 409 {object} handler.ErrorResponse "Conflict"
+// @Failure 413 {object} handler.ErrorResponse "Request Entity Too Large"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /label/{id} [put]
+func (h *Handler) UpdateLabel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
+		return
+	}
+	var l models.Label
+	if err := json.Unmarshal(body, &l); err != nil {
+		handler.WriteBadRequest(w, "Invalid JSON input")
+		return
+	}
+	updated, err := h.Service.UpdateLabel(r.Context(), id, l)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, updated)
+}
+
+// DeleteLabel godoc
+// @Summary Delete a label
+// @Description Deletes a label and takes it off every task; only admins can
+// @Tags labels
+// @Param id path int true "Label ID"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 403 {object} handler.ErrorResponse "Forbidden"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /label/{id} [delete]
+func (h *Handler) DeleteLabel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := h.Service.DeleteLabel(r.Context(), id); err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ViewTaskLabels godoc
+// @Summary List a task's labels
+// @Description Returns the labels of a task ordered by name
+// @Tags tasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /task/{id}/labels [get]
+func (h *Handler) ViewTaskLabels(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	labels, err := h.Service.ViewTaskLabels(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, labels)
+}
+
+// AddTaskLabel godoc
+// @Summary Label a task
+// @Description Puts a label on a task, if it does not have it yet, and returns the task's labels
+// @Tags tasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param label_id path int true "Label ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /task/{id}/labels/{label_id} [put]
+func (h *Handler) AddTaskLabel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	labelID, ok := pathID(w, r, "label_id")
+	if !ok {
+		return
+	}
+	labels, err := h.Service.AddTaskLabel(r.Context(), id, labelID)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, labels)
+}
+
+// RemoveTaskLabel godoc
+// @Summary Unlabel a task
+// @Description Takes a label off a task, if it has it, and returns the task's labels
+// @Tags tasks
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param label_id path int true "Label ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /task/{id}/labels/{label_id} [delete]
+func (h *Handler) RemoveTaskLabel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	labelID, ok := pathID(w, r, "label_id")
+	if !ok {
+		return
+	}
+	labels, err := h.Service.RemoveTaskLabel(r.Context(), id, labelID)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	writeJSON(w, labels)
+}
+
+// pathID reads the ID named name from the path, or writes the error and
+// reports false.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	v := r.PathValue(name)
+	if v == "" {
+		handler.WriteBadRequest(w, "Missing ID")
+		return 0, false
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	b, _ := json.Marshal(v)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
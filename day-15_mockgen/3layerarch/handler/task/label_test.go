@@ -0,0 +1,128 @@
+package taskhandler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"3layerarch/models"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLabelHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := NewMockTaskService(ctrl)
+	handler := New(mockService)
+
+	work := models.Label{ID: 1, Name: "work"}
+
+	tests := []struct {
+		desc      string
+		method    string
+		body      string
+		path      map[string]string
+		setupMock func()
+		serve     http.HandlerFunc
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			desc: "Create", method: http.MethodPost, body: `{"name":"work"}`,
+			setupMock: func() {
+				mockService.EXPECT().CreateLabel(gomock.Any(), models.Label{Name: "work"}).Return(work, nil)
+			},
+			serve: handler.CreateLabel, wantCode: http.StatusCreated, wantBody: `{"id":1,"name":"work"}`,
+		},
+		{
+			desc: "Create Invalid JSON", method: http.MethodPost, body: `{"name":`,
+			serve: handler.CreateLabel, wantCode: http.StatusBadRequest,
+		},
+		{
+			desc: "Create Name Taken", method: http.MethodPost, body: `{"name":"work"}`,
+			setupMock: func() {
+				mockService.EXPECT().CreateLabel(gomock.Any(), gomock.Any()).Return(models.Label{}, models.Conflict("label name already taken"))
+			},
+			serve: handler.CreateLabel, wantCode: http.StatusConflict,
+		},
+		{
+			desc: "Get", method: http.MethodGet, path: map[string]string{"id": "1"},
+			setupMock: func() { mockService.EXPECT().GetLabel(gomock.Any(), 1).Return(work, nil) },
+			serve:     handler.GetLabel, wantCode: http.StatusOK, wantBody: `{"id":1,"name":"work"}`,
+		},
+		{
+			desc: "Get Invalid ID", method: http.MethodGet, path: map[string]string{"id": "abc"},
+			serve: handler.GetLabel, wantCode: http.StatusBadRequest,
+		},
+		{
+			desc: "View", method: http.MethodGet,
+			setupMock: func() { mockService.EXPECT().ViewLabels(gomock.Any()).Return([]models.Label{work}, nil) },
+			serve:     handler.ViewLabels, wantCode: http.StatusOK, wantBody: `[{"id":1,"name":"work"}]`,
+		},
+		{
+			desc: "Rename", method: http.MethodPut, body: `{"name":"office"}`, path: map[string]string{"id": "1"},
+			setupMock: func() {
+				mockService.EXPECT().UpdateLabel(gomock.Any(), 1, models.Label{Name: "office"}).Return(models.Label{ID: 1, Name: "office"}, nil)
+			},
+			serve: handler.UpdateLabel, wantCode: http.StatusOK, wantBody: `{"id":1,"name":"office"}`,
+		},
+		{
+			desc: "Rename Not An Admin", method: http.MethodPut, body: `{"name":"office"}`, path: map[string]string{"id": "1"},
+			setupMock: func() {
+				mockService.EXPECT().UpdateLabel(gomock.Any(), 1, gomock.Any()).Return(models.Label{}, models.Forbidden("only admins can rename labels"))
+			},
+			serve: handler.UpdateLabel, wantCode: http.StatusForbidden,
+		},
+		{
+			desc: "Delete", method: http.MethodDelete, path: map[string]string{"id": "1"},
+			setupMock: func() { mockService.EXPECT().DeleteLabel(gomock.Any(), 1).Return(nil) },
+			serve:     handler.DeleteLabel, wantCode: http.StatusOK,
+		},
+		{
+			desc: "View Task Labels", method: http.MethodGet, path: map[string]string{"id": "7"},
+			setupMock: func() { mockService.EXPECT().ViewTaskLabels(gomock.Any(), 7).Return([]models.Label{}, nil) },
+			serve:     handler.ViewTaskLabels, wantCode: http.StatusOK, wantBody: `[]`,
+		},
+		{
+			desc: "Add Task Label", method: http.MethodPut, path: map[string]string{"id": "7", "label_id": "1"},
+			setupMock: func() { mockService.EXPECT().AddTaskLabel(gomock.Any(), 7, 1).Return([]models.Label{work}, nil) },
+			serve:     handler.AddTaskLabel, wantCode: http.StatusOK, wantBody: `[{"id":1,"name":"work"}]`,
+		},
+		{
+			desc: "Add Unknown Label", method: http.MethodPut, path: map[string]string{"id": "7", "label_id": "9"},
+			setupMock: func() {
+				mockService.EXPECT().AddTaskLabel(gomock.Any(), 7, 9).Return(nil, models.NotFound("label not found"))
+			},
+			serve: handler.AddTaskLabel, wantCode: http.StatusNotFound,
+		},
+		{
+			desc: "Remove Task Label", method: http.MethodDelete, path: map[string]string{"id": "7", "label_id": "1"},
+			setupMock: func() { mockService.EXPECT().RemoveTaskLabel(gomock.Any(), 7, 1).Return([]models.Label{}, nil) },
+			serve:     handler.RemoveTaskLabel, wantCode: http.StatusOK, wantBody: `[]`,
+		},
+		{
+			desc: "Remove Invalid Label ID", method: http.MethodDelete, path: map[string]string{"id": "7", "label_id": "x"},
+			serve: handler.RemoveTaskLabel, wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		if test.setupMock != nil {
+			test.setupMock()
+		}
+		req := httptest.NewRequest(test.method, "/label", bytes.NewBufferString(test.body))
+		for name, value := range test.path {
+			req.SetPathValue(name, value)
+		}
+		w := httptest.NewRecorder()
+
+		test.serve(w, req)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: expected %d, got %d", test.desc, test.wantCode, w.Code)
+		}
+		if test.wantBody != "" && strings.TrimSpace(w.Body.String()) != test.wantBody {
+			t.Errorf("%s: expected body %s, got %s", test.desc, test.wantBody, w.Body.String())
+		}
+	}
+}
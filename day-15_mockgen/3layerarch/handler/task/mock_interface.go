@@ -1,7 +1,17 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: interface.go
+//
+// Generated by this command:
+//
+//	mockgen -source=interface.go -destination=mock_interface.go -package=taskhandler
+//
+
+// Package taskhandler is a generated GoMock package.
 package taskhandler
 
 import (
 	models "3layerarch/models"
+	context "context"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -31,74 +41,330 @@ func (m *MockTaskService) EXPECT() *MockTaskServiceMockRecorder {
 	return m.recorder
 }
 
+// AddDependency mocks base method.
+func (m *MockTaskService) AddDependency(ctx context.Context, taskID, blockerID int) ([]models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDependency", ctx, taskID, blockerID)
+	ret0, _ := ret[0].([]models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddDependency indicates an expected call of AddDependency.
+func (mr *MockTaskServiceMockRecorder) AddDependency(ctx, taskID, blockerID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDependency", reflect.TypeOf((*MockTaskService)(nil).AddDependency), ctx, taskID, blockerID)
+}
+
+// AddTaskLabel mocks base method.
+func (m *MockTaskService) AddTaskLabel(ctx context.Context, taskID, labelID int) ([]models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTaskLabel", ctx, taskID, labelID)
+	ret0, _ := ret[0].([]models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddTaskLabel indicates an expected call of AddTaskLabel.
+func (mr *MockTaskServiceMockRecorder) AddTaskLabel(ctx, taskID, labelID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTaskLabel", reflect.TypeOf((*MockTaskService)(nil).AddTaskLabel), ctx, taskID, labelID)
+}
+
+// CompleteTask mocks base method.
+func (m *MockTaskService) CompleteTask(ctx context.Context, id int, force bool, version int) (models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteTask", ctx, id, force, version)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteTask indicates an expected call of CompleteTask.
+func (mr *MockTaskServiceMockRecorder) CompleteTask(ctx, id, force, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteTask", reflect.TypeOf((*MockTaskService)(nil).CompleteTask), ctx, id, force, version)
+}
+
+// CreateLabel mocks base method.
+func (m *MockTaskService) CreateLabel(ctx context.Context, l models.Label) (models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateLabel", ctx, l)
+	ret0, _ := ret[0].(models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateLabel indicates an expected call of CreateLabel.
+func (mr *MockTaskServiceMockRecorder) CreateLabel(ctx, l any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLabel", reflect.TypeOf((*MockTaskService)(nil).CreateLabel), ctx, l)
+}
+
 // CreateTask mocks base method.
-func (m *MockTaskService) CreateTask(t models.Task) error {
+func (m *MockTaskService) CreateTask(ctx context.Context, t models.Task) (models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTask", ctx, t)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTask indicates an expected call of CreateTask.
+func (mr *MockTaskServiceMockRecorder) CreateTask(ctx, t any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTask", reflect.TypeOf((*MockTaskService)(nil).CreateTask), ctx, t)
+}
+
+// DeleteLabel mocks base method.
+func (m *MockTaskService) DeleteLabel(ctx context.Context, id int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTask", t)
+	ret := m.ctrl.Call(m, "DeleteLabel", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// CreateTask indicates an expected call of CreateTask.
-func (mr *MockTaskServiceMockRecorder) CreateTask(t any) *gomock.Call {
+// DeleteLabel indicates an expected call of DeleteLabel.
+func (mr *MockTaskServiceMockRecorder) DeleteLabel(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTask", reflect.TypeOf((*MockTaskService)(nil).CreateTask), t)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLabel", reflect.TypeOf((*MockTaskService)(nil).DeleteLabel), ctx, id)
 }
 
 // DeleteTask mocks base method.
-func (m *MockTaskService) DeleteTask(id int) error {
+func (m *MockTaskService) DeleteTask(ctx context.Context, id, version int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteTask", id)
+	ret := m.ctrl.Call(m, "DeleteTask", ctx, id, version)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteTask indicates an expected call of DeleteTask.
-func (mr *MockTaskServiceMockRecorder) DeleteTask(id any) *gomock.Call {
+func (mr *MockTaskServiceMockRecorder) DeleteTask(ctx, id, version any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTask", reflect.TypeOf((*MockTaskService)(nil).DeleteTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTask", reflect.TypeOf((*MockTaskService)(nil).DeleteTask), ctx, id, version)
+}
+
+// GetLabel mocks base method.
+func (m *MockTaskService) GetLabel(ctx context.Context, id int) (models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLabel", ctx, id)
+	ret0, _ := ret[0].(models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLabel indicates an expected call of GetLabel.
+func (mr *MockTaskServiceMockRecorder) GetLabel(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLabel", reflect.TypeOf((*MockTaskService)(nil).GetLabel), ctx, id)
 }
 
 // GetTask mocks base method.
-func (m *MockTaskService) GetTask(id int) (models.Task, error) {
+func (m *MockTaskService) GetTask(ctx context.Context, id int) (models.Task, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTask", id)
+	ret := m.ctrl.Call(m, "GetTask", ctx, id)
 	ret0, _ := ret[0].(models.Task)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetTask indicates an expected call of GetTask.
-func (mr *MockTaskServiceMockRecorder) GetTask(id any) *gomock.Call {
+func (mr *MockTaskServiceMockRecorder) GetTask(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTask", reflect.TypeOf((*MockTaskService)(nil).GetTask), ctx, id)
+}
+
+// PatchTask mocks base method.
+func (m *MockTaskService) PatchTask(ctx context.Context, id int, p models.TaskPatch, version int) (models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchTask", ctx, id, p, version)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchTask indicates an expected call of PatchTask.
+func (mr *MockTaskServiceMockRecorder) PatchTask(ctx, id, p, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchTask", reflect.TypeOf((*MockTaskService)(nil).PatchTask), ctx, id, p, version)
+}
+
+// RemoveDependency mocks base method.
+func (m *MockTaskService) RemoveDependency(ctx context.Context, taskID, blockerID int) ([]models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveDependency", ctx, taskID, blockerID)
+	ret0, _ := ret[0].([]models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveDependency indicates an expected call of RemoveDependency.
+func (mr *MockTaskServiceMockRecorder) RemoveDependency(ctx, taskID, blockerID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveDependency", reflect.TypeOf((*MockTaskService)(nil).RemoveDependency), ctx, taskID, blockerID)
+}
+
+// RemoveTaskLabel mocks base method.
+func (m *MockTaskService) RemoveTaskLabel(ctx context.Context, taskID, labelID int) ([]models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveTaskLabel", ctx, taskID, labelID)
+	ret0, _ := ret[0].([]models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveTaskLabel indicates an expected call of RemoveTaskLabel.
+func (mr *MockTaskServiceMockRecorder) RemoveTaskLabel(ctx, taskID, labelID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTaskLabel", reflect.TypeOf((*MockTaskService)(nil).RemoveTaskLabel), ctx, taskID, labelID)
+}
+
+// RestoreTask mocks base method.
+func (m *MockTaskService) RestoreTask(ctx context.Context, id int) (models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreTask", ctx, id)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreTask indicates an expected call of RestoreTask.
+func (mr *MockTaskServiceMockRecorder) RestoreTask(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreTask", reflect.TypeOf((*MockTaskService)(nil).RestoreTask), ctx, id)
+}
+
+// TaskGraph mocks base method.
+func (m *MockTaskService) TaskGraph(ctx context.Context, userID *int) (models.TaskGraph, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TaskGraph", ctx, userID)
+	ret0, _ := ret[0].(models.TaskGraph)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TaskGraph indicates an expected call of TaskGraph.
+func (mr *MockTaskServiceMockRecorder) TaskGraph(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TaskGraph", reflect.TypeOf((*MockTaskService)(nil).TaskGraph), ctx, userID)
+}
+
+// TaskTree mocks base method.
+func (m *MockTaskService) TaskTree(ctx context.Context, id int) (models.TaskTree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TaskTree", ctx, id)
+	ret0, _ := ret[0].(models.TaskTree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TaskTree indicates an expected call of TaskTree.
+func (mr *MockTaskServiceMockRecorder) TaskTree(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTask", reflect.TypeOf((*MockTaskService)(nil).GetTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TaskTree", reflect.TypeOf((*MockTaskService)(nil).TaskTree), ctx, id)
+}
+
+// UpdateLabel mocks base method.
+func (m *MockTaskService) UpdateLabel(ctx context.Context, id int, l models.Label) (models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLabel", ctx, id, l)
+	ret0, _ := ret[0].(models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateLabel indicates an expected call of UpdateLabel.
+func (mr *MockTaskServiceMockRecorder) UpdateLabel(ctx, id, l any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLabel", reflect.TypeOf((*MockTaskService)(nil).UpdateLabel), ctx, id, l)
 }
 
 // UpdateTask mocks base method.
-func (m *MockTaskService) UpdateTask(id int) error {
+func (m *MockTaskService) UpdateTask(ctx context.Context, id int, t models.Task, version int) (models.Task, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateTask", id)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "UpdateTask", ctx, id, t, version)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // UpdateTask indicates an expected call of UpdateTask.
-func (mr *MockTaskServiceMockRecorder) UpdateTask(id any) *gomock.Call {
+func (mr *MockTaskServiceMockRecorder) UpdateTask(ctx, id, t, version any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTask", reflect.TypeOf((*MockTaskService)(nil).UpdateTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTask", reflect.TypeOf((*MockTaskService)(nil).UpdateTask), ctx, id, t, version)
 }
 
-// ViewTasks mocks base method.
-func (m *MockTaskService) ViewTasks() ([]models.Task, error) {
+// ViewBlockers mocks base method.
+func (m *MockTaskService) ViewBlockers(ctx context.Context, taskID int) ([]models.Task, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ViewTasks")
+	ret := m.ctrl.Call(m, "ViewBlockers", ctx, taskID)
 	ret0, _ := ret[0].([]models.Task)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// ViewBlockers indicates an expected call of ViewBlockers.
+func (mr *MockTaskServiceMockRecorder) ViewBlockers(ctx, taskID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewBlockers", reflect.TypeOf((*MockTaskService)(nil).ViewBlockers), ctx, taskID)
+}
+
+// ViewLabels mocks base method.
+func (m *MockTaskService) ViewLabels(ctx context.Context) ([]models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ViewLabels", ctx)
+	ret0, _ := ret[0].([]models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ViewLabels indicates an expected call of ViewLabels.
+func (mr *MockTaskServiceMockRecorder) ViewLabels(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewLabels", reflect.TypeOf((*MockTaskService)(nil).ViewLabels), ctx)
+}
+
+// ViewTaskLabels mocks base method.
+func (m *MockTaskService) ViewTaskLabels(ctx context.Context, taskID int) ([]models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ViewTaskLabels", ctx, taskID)
+	ret0, _ := ret[0].([]models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ViewTaskLabels indicates an expected call of ViewTaskLabels.
+func (mr *MockTaskServiceMockRecorder) ViewTaskLabels(ctx, taskID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewTaskLabels", reflect.TypeOf((*MockTaskService)(nil).ViewTaskLabels), ctx, taskID)
+}
+
+// ViewTasks mocks base method.
+func (m *MockTaskService) ViewTasks(ctx context.Context, f models.TaskFilter) (models.TaskPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ViewTasks", ctx, f)
+	ret0, _ := ret[0].(models.TaskPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // ViewTasks indicates an expected call of ViewTasks.
-func (mr *MockTaskServiceMockRecorder) ViewTasks() *gomock.Call {
+func (mr *MockTaskServiceMockRecorder) ViewTasks(ctx, f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewTasks", reflect.TypeOf((*MockTaskService)(nil).ViewTasks), ctx, f)
+}
+
+// ViewUserTasks mocks base method.
+func (m *MockTaskService) ViewUserTasks(ctx context.Context, userID int, f models.TaskFilter) (models.TaskPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ViewUserTasks", ctx, userID, f)
+	ret0, _ := ret[0].(models.TaskPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ViewUserTasks indicates an expected call of ViewUserTasks.
+func (mr *MockTaskServiceMockRecorder) ViewUserTasks(ctx, userID, f any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewTasks", reflect.TypeOf((*MockTaskService)(nil).ViewTasks))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewUserTasks", reflect.TypeOf((*MockTaskService)(nil).ViewUserTasks), ctx, userID, f)
 }
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Timeout gives every request handled by next a deadline of d, so database
+// queries started for it stop once it has run too long. A zero d sets no
+// deadline; the request is still cancelled if the client goes away.
+func Timeout(d time.Duration, next http.Handler) http.Handler {
+	if d <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
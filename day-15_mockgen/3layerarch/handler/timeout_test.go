@@ -0,0 +1,27 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"3layerarch/handler"
+)
+
+func TestTimeout(t *testing.T) {
+	var hasDeadline bool
+	next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, hasDeadline = r.Context().Deadline()
+	})
+
+	handler.Timeout(0, next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if hasDeadline {
+		t.Error("expected no deadline with a zero timeout")
+	}
+
+	handler.Timeout(time.Second, next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !hasDeadline {
+		t.Error("expected a deadline")
+	}
+}
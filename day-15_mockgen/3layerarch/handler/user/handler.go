@@ -1,12 +1,17 @@
 package userhandler
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"3layerarch/handler"
 	"3layerarch/models"
 )
 
@@ -25,50 +30,381 @@ func New(service UserService) *Handler {
 // @Accept json
 // @Produce json
 // @Param user body models.User true "User to create"
-// @Success 201 {string} string "Created"
-// @Failure 400 {string} string "Bad Request"
+// @Success 201 {object} models.User
+// @Header 201 {string} Location "URL of the new user"
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 413 {object} handler.ErrorResponse "Request Entity Too Large"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /user [post]
 func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil || len(body) == 0 {
-		http.Error(w, "Empty or unreadable body", http.StatusBadRequest)
+		handler.WriteBodyError(w, err)
 		return
 	}
 	var u models.User
 	if err := json.Unmarshal(body, &u); err != nil {
-		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Invalid JSON input")
 		return
 	}
-	if err := h.Service.CreateUser(u); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	created, err := h.Service.CreateUser(r.Context(), u)
+	if err != nil {
+		handler.WriteError(w, err)
 		return
 	}
+	w.Header().Set("Location", "/user/"+strconv.Itoa(created.ID))
 	w.WriteHeader(http.StatusCreated)
+	b, _ := json.Marshal(created)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
 }
 
 // GetUser godoc
 // @Summary Get user by ID
-// @Description Returns a user given their ID
+// @Description Returns a user given their ID, optionally with their tasks and task counts. Only the user themselves or an admin may include tasks or stats
 // @Tags users
 // @Produce json
 // @Param id path int true "User ID"
-// @Success 200 {object} models.User
-// @Failure 400 {string} string "Bad Request"
+// @Param include query string false "Comma-separated related data to add: tasks, stats"
+// @Success 200 {object} models.UserDetail
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /user/{id} [get]
 func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	if idStr == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Missing ID")
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	inc, err := parseInclude(r.URL.Query().Get("include"))
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	var u any
+	if inc == (models.UserInclude{}) {
+		u, err = h.Service.GetUser(r.Context(), id)
+	} else {
+		u, err = h.Service.GetUserDetail(r.Context(), id, inc)
+	}
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	b, _ := json.Marshal(u)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// parseInclude reads the include query parameter of a user fetch.
+func parseInclude(v string) (models.UserInclude, error) {
+	var inc models.UserInclude
+	if v == "" {
+		return inc, nil
+	}
+	for _, name := range strings.Split(v, ",") {
+		switch strings.TrimSpace(name) {
+		case "tasks":
+			inc.Tasks = true
+		case "stats":
+			inc.Stats = true
+		default:
+			return models.UserInclude{}, fmt.Errorf("cannot include %q", name)
+		}
+	}
+	return inc, nil
+}
+
+// ViewUsers godoc
+// @Summary List users
+// @Description Returns a page of users ordered by ID. Only admins list users
+// @Tags users
+// @Produce json
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of users to skip"
+// @Success 200 {object} models.UserPage
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 403 {object} handler.ErrorResponse "Forbidden"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user [get]
+func (h *Handler) ViewUsers(w http.ResponseWriter, r *http.Request) {
+	h.viewUsers(w, r, false)
+}
+
+// ViewTrash godoc
+// @Summary List users in the trash
+// @Description Returns a page of the deleted users that can still be restored, ordered by ID. Only admins list the trash
+// @Tags users
+// @Produce json
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of users to skip"
+// @Success 200 {object} models.UserPage
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 403 {object} handler.ErrorResponse "Forbidden"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /trash/users [get]
+func (h *Handler) ViewTrash(w http.ResponseWriter, r *http.Request) {
+	h.viewUsers(w, r, true)
+}
+
+func (h *Handler) viewUsers(w http.ResponseWriter, r *http.Request, deleted bool) {
+	f, err := parseUserFilter(r.URL.Query())
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	f.Deleted = deleted
+	page, err := h.Service.ViewUsers(r.Context(), f)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	if page.Offset+len(page.Users) < page.Total {
+		q := url.Values{}
+		q.Set("limit", strconv.Itoa(page.Limit))
+		q.Set("offset", strconv.Itoa(page.Offset+page.Limit))
+		page.Next = r.URL.Path + "?" + q.Encode()
+	}
+	b, _ := json.Marshal(page)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// parseUserFilter reads the paging parameters of a user listing.
+func parseUserFilter(q url.Values) (models.UserFilter, error) {
+	var f models.UserFilter
+	for name, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return models.UserFilter{}, fmt.Errorf("invalid %s value", name)
+			}
+			*dst = n
+		}
+	}
+	return f, nil
+}
+
+// UpdateUser godoc
+// @Summary Replace a user
+// @Description Replaces every field of a user and returns the updated user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body models.User true "New user state"
+// @Success 200 {object} models.User
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 409 {object} handler.ErrorResponse "Conflict"
+// @Failure 413 {object} handler.ErrorResponse "Request Entity Too Large"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user/{id} [put]
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		handler.WriteBadRequest(w, "Missing ID")
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
+		return
+	}
+	var u models.User
+	if err := json.Unmarshal(body, &u); err != nil {
+		handler.WriteBadRequest(w, "Invalid JSON input")
+		return
+	}
+	updated, err := h.Service.UpdateUser(r.Context(), id, u)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	b, _ := json.Marshal(updated)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// PatchUser godoc
+// @Summary Partially update a user
+// @Description Applies a JSON merge-patch to a user and returns the updated user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param patch body models.UserPatch true "Fields to change"
+// @Success 200 {object} models.User
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 409 {object} handler.ErrorResponse "Conflict"
+// @Failure 413 {object} handler.ErrorResponse "Request Entity Too Large"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user/{id} [patch]
+func (h *Handler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		handler.WriteBadRequest(w, "Missing ID")
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		handler.WriteBodyError(w, err)
+		return
+	}
+	p, err := decodePatch(body)
+	if err != nil {
+		handler.WriteBadRequest(w, err.Error())
+		return
+	}
+	updated, err := h.Service.PatchUser(r.Context(), id, p)
+	if err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	b, _ := json.Marshal(updated)
+	if _, err := w.Write(b); err != nil {
+		fmt.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// decodePatch parses a JSON merge-patch (RFC 7396) body. A null name, which
+// would remove it, is rejected, as are fields the user does not have.
+func decodePatch(body []byte) (models.UserPatch, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return models.UserPatch{}, errors.New("invalid JSON input")
+	}
+	for field, v := range raw {
+		if string(v) == "null" {
+			return models.UserPatch{}, fmt.Errorf("%s cannot be null", field)
+		}
+	}
+	var p models.UserPatch
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return models.UserPatch{}, fmt.Errorf("invalid patch: %v", err)
+	}
+	return p, nil
+}
+
+// DeleteUser godoc
+// @Summary Delete user
+// @Description Moves a user to the trash. The tasks parameter picks what happens to their tasks.
+// @Tags users
+// @Param id path int true "User ID"
+// @Param tasks query string false "reject (default), cascade or reassign"
+// @Param reassign_to query int false "User who takes over the tasks when reassigning"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 403 {object} handler.ErrorResponse "Forbidden"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 409 {object} handler.ErrorResponse "Conflict"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user/{id} [delete]
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		handler.WriteBadRequest(w, "Missing ID")
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		handler.WriteBadRequest(w, "Invalid ID format")
+		return
+	}
+	d := models.UserDelete{Policy: models.DeletePolicy(r.URL.Query().Get("tasks"))}
+	if v := r.URL.Query().Get("reassign_to"); v != "" {
+		if d.ReassignTo, err = strconv.Atoi(v); err != nil {
+			handler.WriteBadRequest(w, "invalid reassign_to value")
+			return
+		}
+	}
+	if err := h.Service.DeleteUser(r.Context(), id, d); err != nil {
+		handler.WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RestoreUser godoc
+// @Summary Restore user
+// @Description Takes a deleted user, and the tasks deleted with them, out of the trash
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} handler.ErrorResponse "Bad Request"
+// @Failure 401 {object} handler.ErrorResponse "Unauthorized"
+// @Failure 404 {object} handler.ErrorResponse "Not Found"
+// @Failure 422 {object} handler.ErrorResponse "Unprocessable Entity"
+// @Failure 500 {object} handler.ErrorResponse "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /user/{id}/restore [post]
+func (h *Handler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	if idStr == "" {
+		handler.WriteBadRequest(w, "Missing ID")
 		return
 	}
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		handler.WriteBadRequest(w, "Invalid ID format")
 		return
 	}
-	u, err := h.Service.GetUser(id)
+	u, err := h.Service.RestoreUser(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		handler.WriteError(w, err)
 		return
 	}
 	b, _ := json.Marshal(u)
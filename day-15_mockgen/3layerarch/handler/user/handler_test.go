@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"3layerarch/models"
 	"go.uber.org/mock/gomock"
@@ -38,6 +39,7 @@ func TestCreateUser(t *testing.T) {
 			body:       `{"id":1,"name":"John"}`,
 			mockErr:    nil,
 			wantStatus: http.StatusCreated,
+			wantBody:   `"id":1`,
 		},
 		{
 			desc:       "empty body",
@@ -55,8 +57,15 @@ func TestCreateUser(t *testing.T) {
 			desc:       "service error",
 			body:       `{"id":2,"name":"Error"}`,
 			mockErr:    errors.New("insert failed"),
-			wantStatus: http.StatusBadRequest,
-			wantBody:   "insert failed",
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `"message":"internal server error"`,
+		},
+		{
+			desc:       "validation error",
+			body:       `{"id":3,"name":""}`,
+			mockErr:    models.Validation("user name cannot be empty"),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "user name cannot be empty",
 		},
 	}
 
@@ -68,7 +77,7 @@ func TestCreateUser(t *testing.T) {
 			if tc.mockErr != nil || (tc.body != "" && tc.body != `{`) {
 				var u models.User
 				_ = json.Unmarshal([]byte(tc.body), &u)
-				mockService.EXPECT().CreateUser(u).Return(tc.mockErr).AnyTimes()
+				mockService.EXPECT().CreateUser(gomock.Any(), u).Return(u, tc.mockErr).AnyTimes()
 			}
 
 			handler.CreateUser(w, req)
@@ -121,9 +130,9 @@ func TestGetUser(t *testing.T) {
 		{
 			desc:       "service error",
 			id:         "2",
-			mockErr:    errors.New("not found"),
-			wantStatus: http.StatusBadRequest,
-			wantBody:   "not found",
+			mockErr:    models.NotFound("user not found"),
+			wantStatus: http.StatusNotFound,
+			wantBody:   `"code":"not_found"`,
 		},
 	}
 
@@ -137,7 +146,7 @@ func TestGetUser(t *testing.T) {
 
 			if isNumber(tc.id) {
 				id, _ := strconv.Atoi(tc.id)
-				mockService.EXPECT().GetUser(id).Return(tc.mockUser, tc.mockErr).AnyTimes()
+				mockService.EXPECT().GetUser(gomock.Any(), id).Return(tc.mockUser, tc.mockErr).AnyTimes()
 			}
 
 			handler.GetUser(w, req)
@@ -151,3 +160,356 @@ func TestGetUser(t *testing.T) {
 		})
 	}
 }
+
+func TestGetUserInclude(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := NewMockUserService(ctrl)
+	handler := New(mockService)
+
+	testCases := []struct {
+		desc       string
+		include    string
+		mockInc    *models.UserInclude
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			desc:       "tasks and stats",
+			include:    "tasks,stats",
+			mockInc:    &models.UserInclude{Tasks: true, Stats: true},
+			wantStatus: http.StatusOK,
+			wantBody: `"tasks":[{"id":4,"task":"t","completed":false,"user_id":1,"version":1,` +
+				`"created_at":"2026-01-02T03:04:05Z","updated_at":"2026-01-02T03:04:05Z"}],"stats":{"open":1,"completed":0}`,
+		},
+		{
+			desc:       "stats only",
+			include:    "stats",
+			mockInc:    &models.UserInclude{Stats: true},
+			wantStatus: http.StatusOK,
+			wantBody:   `"stats"`,
+		},
+		{
+			desc:       "unknown include",
+			include:    "friends",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `cannot include`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/user/1?include="+tc.include, nil)
+			req.SetPathValue("id", "1")
+			w := httptest.NewRecorder()
+
+			if tc.mockInc != nil {
+				d := models.UserDetail{User: models.User{ID: 1, Name: "John"}, Stats: &models.UserStats{Open: 1}}
+				if tc.mockInc.Tasks {
+					day := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+					d.Tasks = []models.Task{{ID: 4, Task: "t", UserID: 1, Version: 1, CreatedAt: day, UpdatedAt: day}}
+				}
+				mockService.EXPECT().GetUserDetail(gomock.Any(), 1, *tc.mockInc).Return(d, nil)
+			}
+
+			handler.GetUser(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tc.wantBody) {
+				t.Errorf("expected body to contain %q, got %q", tc.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestViewUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := NewMockUserService(ctrl)
+	handler := New(mockService)
+
+	testCases := []struct {
+		desc       string
+		query      string
+		mock       bool
+		mockPage   models.UserPage
+		mockErr    error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			desc:       "page with next link",
+			query:      "limit=1&offset=2",
+			mock:       true,
+			mockPage:   models.UserPage{Users: []models.User{{ID: 3, Name: "Carol"}}, Total: 5, Limit: 1, Offset: 2},
+			wantStatus: http.StatusOK,
+			wantBody:   `"next":"/user?limit=1\u0026offset=3"`,
+		},
+		{
+			desc:       "invalid limit",
+			query:      "limit=ten",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "invalid limit value",
+		},
+		{
+			desc:       "service error",
+			query:      "limit=1000",
+			mock:       true,
+			mockErr:    models.Validation("limit must be between 1 and 100"),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "limit must be between 1 and 100",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/user?"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			if tc.mock {
+				mockService.EXPECT().ViewUsers(gomock.Any(), gomock.Any()).Return(tc.mockPage, tc.mockErr)
+			}
+
+			handler.ViewUsers(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tc.wantBody) {
+				t.Errorf("expected body to contain %q, got %q", tc.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := NewMockUserService(ctrl)
+	handler := New(mockService)
+
+	testCases := []struct {
+		desc       string
+		id         string
+		body       string
+		mock       bool
+		mockErr    error
+		wantStatus int
+	}{
+		{desc: "valid", id: "1", body: `{"name":"Alicia"}`, mock: true, wantStatus: http.StatusOK},
+		{desc: "name taken", id: "1", body: `{"name":"Bob"}`, mock: true, mockErr: models.Conflict("user name already taken"), wantStatus: http.StatusConflict},
+		{desc: "unknown user", id: "9", body: `{"name":"Zed"}`, mock: true, mockErr: models.NotFound("user not found"), wantStatus: http.StatusNotFound},
+		{desc: "invalid ID format", id: "abc", body: `{"name":"Bob"}`, wantStatus: http.StatusBadRequest},
+		{desc: "invalid JSON", id: "1", body: `{`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/user/"+tc.id, bytes.NewBufferString(tc.body))
+			req.SetPathValue("id", tc.id)
+			w := httptest.NewRecorder()
+
+			if tc.mock {
+				id, _ := strconv.Atoi(tc.id)
+				var u models.User
+				_ = json.Unmarshal([]byte(tc.body), &u)
+				mockService.EXPECT().UpdateUser(gomock.Any(), id, u).Return(models.User{ID: id, Name: u.Name}, tc.mockErr)
+			}
+
+			handler.UpdateUser(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := NewMockUserService(ctrl)
+	handler := New(mockService)
+
+	name := "Alicia"
+	testCases := []struct {
+		desc       string
+		body       string
+		mock       bool
+		wantStatus int
+	}{
+		{desc: "rename", body: `{"name":"Alicia"}`, mock: true, wantStatus: http.StatusOK},
+		{desc: "null name", body: `{"name":null}`, wantStatus: http.StatusBadRequest},
+		{desc: "unknown field", body: `{"email":"a@b.c"}`, wantStatus: http.StatusBadRequest},
+		{desc: "empty body", body: ``, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/user/1", bytes.NewBufferString(tc.body))
+			req.SetPathValue("id", "1")
+			w := httptest.NewRecorder()
+
+			if tc.mock {
+				mockService.EXPECT().PatchUser(gomock.Any(), 1, models.UserPatch{Name: &name}).Return(models.User{ID: 1, Name: name}, nil)
+			}
+
+			handler.PatchUser(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := NewMockUserService(ctrl)
+	handler := New(mockService)
+
+	testCases := []struct {
+		desc       string
+		id         string
+		query      string
+		mockDelete *models.UserDelete
+		mockErr    error
+		wantStatus int
+	}{
+		{
+			desc:       "default policy",
+			id:         "1",
+			mockDelete: &models.UserDelete{},
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "reassign",
+			id:         "1",
+			query:      "?tasks=reassign&reassign_to=4",
+			mockDelete: &models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 4},
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "user owns tasks",
+			id:         "2",
+			mockDelete: &models.UserDelete{},
+			mockErr:    models.Conflict("user still owns 3 tasks"),
+			wantStatus: http.StatusConflict,
+		},
+		{
+			desc:       "invalid reassign_to",
+			id:         "1",
+			query:      "?tasks=reassign&reassign_to=bob",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			desc:       "invalid ID format",
+			id:         "abc",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/user/"+tc.id+tc.query, nil)
+			req.SetPathValue("id", tc.id)
+			w := httptest.NewRecorder()
+
+			if tc.mockDelete != nil {
+				id, _ := strconv.Atoi(tc.id)
+				mockService.EXPECT().DeleteUser(gomock.Any(), id, *tc.mockDelete).Return(tc.mockErr)
+			}
+
+			handler.DeleteUser(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestViewTrash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := NewMockUserService(ctrl)
+	handler := New(mockService)
+
+	req := httptest.NewRequest(http.MethodGet, "/trash/users?limit=1", nil)
+	w := httptest.NewRecorder()
+
+	mockService.EXPECT().ViewUsers(gomock.Any(), models.UserFilter{Deleted: true, Limit: 1}).
+		Return(models.UserPage{Users: []models.User{{ID: 3, Name: "Carol"}}, Total: 2, Limit: 1}, nil)
+
+	handler.ViewTrash(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if want := `"next":"/trash/users?limit=1\u0026offset=1"`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected body to contain %q, got %q", want, w.Body.String())
+	}
+}
+
+func TestRestoreUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := NewMockUserService(ctrl)
+	handler := New(mockService)
+
+	testCases := []struct {
+		desc       string
+		id         string
+		mock       bool
+		mockErr    error
+		wantStatus int
+	}{
+		{
+			desc:       "restored",
+			id:         "1",
+			mock:       true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "not in the trash",
+			id:         "2",
+			mock:       true,
+			mockErr:    models.NotFound("user not found in the trash"),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			desc:       "invalid ID format",
+			id:         "abc",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/user/"+tc.id+"/restore", nil)
+			req.SetPathValue("id", tc.id)
+			w := httptest.NewRecorder()
+
+			if tc.mock {
+				id, _ := strconv.Atoi(tc.id)
+				mockService.EXPECT().RestoreUser(gomock.Any(), id).Return(models.User{ID: id, Name: "Alice"}, tc.mockErr)
+			}
+
+			handler.RestoreUser(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+		})
+	}
+}
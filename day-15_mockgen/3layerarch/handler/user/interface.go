@@ -1,8 +1,18 @@
 package userhandler
 
-import "3layerarch/models"
+import (
+	"context"
+
+	"3layerarch/models"
+)
 
 type UserService interface {
-	CreateUser(u models.User) error
-	GetUser(id int) (models.User, error)
+	CreateUser(ctx context.Context, u models.User) (models.User, error)
+	GetUser(ctx context.Context, id int) (models.User, error)
+	GetUserDetail(ctx context.Context, id int, inc models.UserInclude) (models.UserDetail, error)
+	ViewUsers(ctx context.Context, f models.UserFilter) (models.UserPage, error)
+	UpdateUser(ctx context.Context, id int, u models.User) (models.User, error)
+	PatchUser(ctx context.Context, id int, p models.UserPatch) (models.User, error)
+	DeleteUser(ctx context.Context, id int, d models.UserDelete) error
+	RestoreUser(ctx context.Context, id int) (models.User, error)
 }
@@ -0,0 +1,118 @@
+// Package health serves the liveness and readiness endpoints that load
+// balancers and orchestrators probe.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Status values of a Report and of each of its checks.
+const (
+	StatusOK           = "ok"
+	StatusDown         = "down"
+	StatusDegraded     = "degraded"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Report is the JSON body of both endpoints. Checks maps each dependency to
+// its status; it is only filled in by readiness.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// CheckFunc reports whether a dependency can be used.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker answers the probes. The server is ready while every check passes
+// and it is not shutting down.
+type Checker struct {
+	checks []check
+	// Timeout bounds each check, so that a hung dependency cannot hang
+	// the probe.
+	Timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func New(timeout time.Duration) *Checker {
+	return &Checker{Timeout: timeout}
+}
+
+// Add registers a readiness check of the dependency called name.
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Shutdown makes the server report itself not ready, so that no new traffic
+// is sent to it while it drains.
+func (c *Checker) Shutdown() {
+	c.shuttingDown.Store(true)
+}
+
+// Live reports that the process is up and serving. It does not look at
+// dependencies, as restarting the server would not fix them.
+// @Summary Liveness probe
+// @Description Reports that the server process is up
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Router /healthz [get]
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// Ready reports whether the server can serve requests, with the status of
+// each dependency. Any failed check makes it degraded and a 503.
+// @Summary Readiness probe
+// @Description Reports whether the server and each of its dependencies can serve requests
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report "Degraded or shutting down"
+// @Router /readyz [get]
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	if c.shuttingDown.Load() {
+		writeReport(w, http.StatusServiceUnavailable, Report{Status: StatusShuttingDown})
+		return
+	}
+
+	report := Report{Status: StatusOK, Checks: map[string]string{}}
+	for _, chk := range c.checks {
+		ctx, cancel := context.WithTimeout(r.Context(), c.Timeout)
+		err := chk.fn(ctx)
+		cancel()
+		if err != nil {
+			// The error may name hosts or users, so it is only logged
+			log.Printf("readiness check %s failed: %v", chk.name, err)
+			report.Checks[chk.name] = StatusDown
+			report.Status = StatusDegraded
+			continue
+		}
+		report.Checks[chk.name] = StatusOK
+	}
+
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeReport(w, status, report)
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("failed to write health report: %v", err)
+	}
+}
@@ -1,22 +1,47 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"3layerarch/auth"
+	"3layerarch/cache"
+	"3layerarch/config"
+	"3layerarch/handler"
+	"3layerarch/health"
+	"3layerarch/metrics"
+	"3layerarch/middleware"
+	"3layerarch/migrate"
+	"3layerarch/trash"
+
+	audithandler "3layerarch/handler/audit"
+	authhandler "3layerarch/handler/auth"
 	taskhandler "3layerarch/handler/task"
 	userhandler "3layerarch/handler/user"
 
+	auditservice "3layerarch/service/audit"
 	taskservice "3layerarch/service/task"
 	userservice "3layerarch/service/user"
 
+	"3layerarch/store"
+	auditstore "3layerarch/store/audit"
+	cachestore "3layerarch/store/cache"
+	memstore "3layerarch/store/memory"
+	sqlitestore "3layerarch/store/sqlite"
 	taskstore "3layerarch/store/task"
 	userstore "3layerarch/store/user"
 
 	_ "3layerarch/docs" // swagger generated docs
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/redis/go-redis/v9"
 	"github.com/swaggo/http-swagger" // swagger UI
 )
 
@@ -25,67 +50,353 @@ import (
 // @description     This is a sample server for managing tasks and users.
 // @host            localhost:8080
 // @BasePath        /
+//
+// @securityDefinitions.apikey ApiKeyAuth
+// @in                         header
+// @name                       X-API-Key
+//
+// @securityDefinitions.apikey BearerAuth
+// @in                         header
+// @name                       Authorization
+// @description                "Bearer " followed by a token from /auth/token
 func main() {
-	db, err := sql.Open("mysql", "root:root123@tcp(localhost:3306)/test_db")
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Config error:", err)
+	}
+	log.Printf("Config: %+v", cfg) // the password is redacted
+
+	db, dialect, err := openDB(cfg.DB)
 	if err != nil {
 		log.Fatal("DB connection error:", err)
 	}
-	defer func() {
-		if err := db.Close(); err != nil {
-			log.Println("Error closing DB:", err)
+	if db == nil {
+		log.Println("Keeping tasks and users in memory; they are lost when the server stops")
+		if len(args) > 0 && args[0] == "migrate" {
+			log.Fatal("The memory store has no schema to migrate")
 		}
-	}()
+	} else {
+		db.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+		db.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+		db.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime)
+		defer func() {
+			if err := db.Close(); err != nil {
+				log.Println("Error closing DB:", err)
+			}
+		}()
 
-	// Ensure TASKS table exists
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS TASKS (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		task TEXT,
-		completed BOOL DEFAULT FALSE,
-		user_id int
-	);`)
-	if err != nil {
-		log.Fatal("Failed to create TASKS table:", err)
+		// sql.Open does not connect, so check the settings work before serving
+		pingCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.HealthTimeout)
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err != nil {
+			log.Fatal("DB ping failed:", err)
+		}
+
+		// "migrate up|down|status" changes the schema instead of serving
+		m := migrate.New(db, dialect, migrate.For(dialect))
+		if len(args) > 0 && args[0] == "migrate" {
+			if err := migrate.Run(m, args[1:], os.Stdout); err != nil {
+				log.Fatal("Migration failed:", err)
+			}
+			return
+		}
+		if cfg.Features.MigrateOnStart {
+			if err := migrate.Run(m, []string{"up"}, os.Stdout); err != nil {
+				log.Fatal("Migration failed:", err)
+			}
+		}
+		if err := m.Check(); err != nil {
+			log.Fatal("Schema check failed:", err)
+		}
 	}
 
-	// Ensure USERS table exists
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS USERS (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		name VARCHAR(100)
-	);`)
+	// The stores of the driver; multi-step changes run as units of work
+	taskStore, userStore, auditStore, tx := newStores(db, dialect)
+
+	// Request, pool and domain metrics
+	stats := metrics.New(db, cfg.DB.Name)
+
+	// Lookups of tasks and users by ID are read through the cache, if any
+	backend, closeCache, err := newCache(cfg.Cache)
 	if err != nil {
-		log.Fatal("Failed to create USERS table:", err)
+		log.Fatal("Cache setup failed:", err)
+	}
+	defer closeCache()
+	if backend != nil {
+		c := cachestore.New(backend, cfg.Cache.TTL)
+		c.Metrics = stats
+		taskStore, userStore, tx = c.Tasks(taskStore), c.Users(userStore), c.Tx(tx)
 	}
 
 	// Setup dependencies
-	userStore := userstore.New(db)
 	userService := userservice.New(userStore)
+	userService.DeletePolicy = cfg.Features.DeletePolicy
+	userService.Tx = tx
+	userService.Metrics = stats
+	userService.Audit = auditStore
 	userHandler := userhandler.New(userService)
 
-	taskStore := taskstore.New(db)
 	taskService := taskservice.New(taskStore, userService)
+	taskService.Tx = tx
+	taskService.Metrics = stats
+	taskService.Audit = auditStore
 	taskHandler := taskhandler.New(taskService)
 
+	auditHandler := audithandler.New(auditservice.New(auditStore))
+
 	// Register handlers
 	http.HandleFunc("POST /task", taskHandler.CreateTask)
 	http.HandleFunc("GET /task", taskHandler.ViewTasks)
 	http.HandleFunc("GET /task/{id}", taskHandler.GetTask)
 	http.HandleFunc("PUT /task/{id}", taskHandler.UpdateTask)
+	http.HandleFunc("PATCH /task/{id}", taskHandler.PatchTask)
 	http.HandleFunc("DELETE /task/{id}", taskHandler.DeleteTask)
+	http.HandleFunc("POST /task/{id}/restore", taskHandler.RestoreTask)
+	http.HandleFunc("POST /task/{id}/complete", taskHandler.CompleteTask)
+	http.HandleFunc("GET /task/{id}/tree", taskHandler.TaskTree)
+	http.HandleFunc("GET /task/{id}/dependencies", taskHandler.ViewBlockers)
+	http.HandleFunc("PUT /task/{id}/dependencies/{blocker_id}", taskHandler.AddDependency)
+	http.HandleFunc("DELETE /task/{id}/dependencies/{blocker_id}", taskHandler.RemoveDependency)
+	http.HandleFunc("GET /task/graph", taskHandler.TaskGraph)
+	http.HandleFunc("GET /task/{id}/labels", taskHandler.ViewTaskLabels)
+	http.HandleFunc("PUT /task/{id}/labels/{label_id}", taskHandler.AddTaskLabel)
+	http.HandleFunc("DELETE /task/{id}/labels/{label_id}", taskHandler.RemoveTaskLabel)
+
+	// Label routes
+	http.HandleFunc("POST /label", taskHandler.CreateLabel)
+	http.HandleFunc("GET /label", taskHandler.ViewLabels)
+	http.HandleFunc("GET /label/{id}", taskHandler.GetLabel)
+	http.HandleFunc("PUT /label/{id}", taskHandler.UpdateLabel)
+	http.HandleFunc("DELETE /label/{id}", taskHandler.DeleteLabel)
 
 	http.HandleFunc("POST /user", userHandler.CreateUser)
+	http.HandleFunc("GET /user", userHandler.ViewUsers)
 	http.HandleFunc("GET /user/{id}", userHandler.GetUser)
+	http.HandleFunc("GET /user/{id}/tasks", taskHandler.ViewUserTasks)
+	http.HandleFunc("PUT /user/{id}", userHandler.UpdateUser)
+	http.HandleFunc("PATCH /user/{id}", userHandler.PatchUser)
+	http.HandleFunc("DELETE /user/{id}", userHandler.DeleteUser)
+	http.HandleFunc("POST /user/{id}/restore", userHandler.RestoreUser)
+
+	// Trash routes
+	http.HandleFunc("GET /trash", taskHandler.ViewTrash)
+	http.HandleFunc("GET /trash/users", userHandler.ViewTrash)
+
+	// Audit routes
+	http.HandleFunc("GET /audit", auditHandler.ViewAudit)
+
+	// Authentication; tokens are only issued when there is a key to sign them
+	authn, issuer, err := newAuth(cfg.Auth)
+	if err != nil {
+		log.Fatal("Auth setup failed:", err)
+	}
+	if issuer != nil {
+		http.HandleFunc("POST /auth/token", authhandler.New(userService, issuer).Token)
+	}
 
 	// Swagger endpoint
-	http.Handle("/swagger/", httpSwagger.WrapHandler)
+	if cfg.Features.Swagger {
+		http.Handle("/swagger/", httpSwagger.WrapHandler)
+		authn.Public = append(authn.Public, "/swagger/")
+	}
+
+	// Probes; readiness fails while the database or Redis cannot be reached
+	probes := health.New(cfg.Server.HealthTimeout)
+	if db != nil {
+		probes.Add(cfg.DB.Driver, db.PingContext)
+	}
+	if r, ok := backend.(*cache.Redis); ok {
+		probes.Add(config.CacheRedis, r.Ping)
+	}
+	http.HandleFunc("GET /healthz", probes.Live)
+	http.HandleFunc("GET /readyz", probes.Ready)
+	authn.Public = append(authn.Public, "/healthz", "/readyz")
+
+	if cfg.Features.Metrics {
+		http.Handle("GET /metrics", stats.Handler())
+		if cfg.Features.PublicMetrics {
+			authn.Public = append(authn.Public, "/metrics")
+		}
+	}
+
+	// Every request gets an ID, a log line and metrics, and a panic becomes
+	// a 500.
+	// CORS comes before authentication, as preflights carry no credentials.
+	cors := &middleware.CORS{
+		AllowedOrigins: cfg.CORS.AllowedOrigins,
+		AllowedMethods: cfg.CORS.AllowedMethods,
+		AllowedHeaders: cfg.CORS.AllowedHeaders,
+		MaxAge:         cfg.CORS.MaxAge,
+	}
+	h := middleware.Chain(handler.Timeout(cfg.Server.RequestTimeout, http.DefaultServeMux),
+		middleware.RequestID,
+		middleware.AccessLog,
+		stats.Wrap(http.DefaultServeMux),
+		middleware.Recover,
+		cors.Wrap,
+		middleware.MaxBodySize(cfg.Server.MaxBodyBytes),
+		authn.Wrap,
+	)
 
 	// Start server
 	srv := &http.Server{
-		Addr:         ":8080",
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
-	}
-	log.Println("Server running at http://localhost:8080")
-	log.Println("Swagger docs at http://localhost:8080/swagger/index.html")
-	log.Fatal(srv.ListenAndServe())
+		Addr:         cfg.Server.Addr(),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		Handler:      h,
+	}
+	if cfg.Features.Swagger {
+		log.Println("Swagger docs at http://localhost" + srv.Addr + "/swagger/index.html")
+	}
+
+	// SIGINT or SIGTERM drains the server; the DB is closed once it is done
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// What has been in the trash for longer than the retention is purged
+	if cfg.Trash.Retention > 0 {
+		go trash.New(taskStore, userStore, cfg.Trash.Retention, cfg.Trash.PurgeInterval).Run(ctx)
+	}
+
+	if err := serve(ctx, srv, probes, cfg.Server.ShutdownTimeout); err != nil {
+		log.Fatal("Server error:", err)
+	}
+}
+
+// serve runs srv until ctx is done. It then reports the server not ready,
+// stops accepting connections and waits up to timeout for the requests in
+// flight to finish. Only a server that fails to start is an error; one that
+// could not drain in time has its connections closed.
+func serve(ctx context.Context, srv *http.Server, probes *health.Checker, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		log.Println("Server listening on", srv.Addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down, waiting for requests in flight")
+	probes.Shutdown()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("Shutdown did not finish in time:", err)
+		if err := srv.Close(); err != nil {
+			log.Println("Error closing server:", err)
+		}
+	}
+	log.Println("Server stopped")
+	return nil
+}
+
+// openDB opens the database of cfg's driver and returns it with its SQL
+// dialect, or nil for the memory store, which has none.
+func openDB(cfg config.DB) (*sql.DB, store.Dialect, error) {
+	var db *sql.DB
+	var err error
+	switch cfg.Driver {
+	case config.DriverMemory:
+		return nil, store.Dialect{}, nil
+	case config.DriverSQLite:
+		db, err = sqlitestore.Open(cfg.Path)
+		return db, store.SQLite, err
+	case config.DriverPostgres:
+		db, err = sql.Open("pgx", cfg.DSN())
+		return db, store.Postgres, err
+	default:
+		// DATETIME columns are scanned into time.Time
+		mc, err := mysql.ParseDSN(cfg.DSN())
+		if err != nil {
+			return nil, store.Dialect{}, err
+		}
+		mc.ParseTime = true
+		db, err = sql.Open("mysql", mc.FormatDSN())
+		return db, store.MySQL, err
+	}
+}
+
+// newStores returns the stores on db, whose SQL is written in dialect, and
+// what runs their units of work. Without a db they keep everything in
+// memory.
+func newStores(db *sql.DB, dialect store.Dialect) (taskservice.TaskStore, userservice.UserStore, auditservice.AuditStore, taskservice.Transactor) {
+	if db == nil {
+		s := memstore.New()
+		return s, s, s, s
+	}
+	return taskstore.New(db, dialect), userstore.New(db, dialect), auditstore.New(db, dialect), store.NewTransactor(db)
+}
+
+// newCache returns the cache backend of cfg, nil for none, and a function
+// that closes it. A Redis is not connected to until it is first used.
+func newCache(cfg config.Cache) (cache.Backend, func(), error) {
+	switch cfg.Backend {
+	case config.CacheMemory:
+		return cache.NewLRU(cfg.Size), func() {}, nil
+	case config.CacheRedis:
+		opts, err := redis.ParseURL(string(cfg.RedisURL))
+		if err != nil {
+			// The URL is not repeated, it may hold a password
+			return nil, nil, errors.New("CACHE_REDIS_URL is not a valid Redis URL")
+		}
+		client := redis.NewClient(opts)
+		closeClient := func() {
+			if err := client.Close(); err != nil {
+				log.Println("Error closing Redis:", err)
+			}
+		}
+		return cache.NewRedis(client, cfg.RedisPrefix), closeClient, nil
+	default:
+		return nil, func() {}, nil
+	}
+}
+
+// newAuth builds the authentication middleware from cfg and the issuer of
+// tokens, if any. An RS256 private key signs tokens in preference to the
+// HS256 secret.
+func newAuth(cfg config.Auth) (*auth.Middleware, *auth.Issuer, error) {
+	keys := &auth.KeySet{}
+	if cfg.JWTSecret != "" {
+		keys.AddHS256("", []byte(cfg.JWTSecret))
+	}
+	for _, path := range cfg.JWTPublicKeys {
+		key, err := auth.ReadRSAPublicKey(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys.AddRS256(auth.KeyID(path), key)
+	}
+
+	var issuer *auth.Issuer
+	switch {
+	case cfg.JWTPrivateKey != "":
+		key, err := auth.ReadRSAPrivateKey(cfg.JWTPrivateKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		kid := auth.KeyID(cfg.JWTPrivateKey)
+		keys.AddRS256(kid, &key.PublicKey)
+		issuer = auth.NewRS256Issuer(kid, key, cfg.JWTIssuer, cfg.TokenTTL)
+	case cfg.JWTSecret != "":
+		issuer = auth.NewHS256Issuer("", []byte(cfg.JWTSecret), cfg.JWTIssuer, cfg.TokenTTL)
+	}
+
+	apiKeys := auth.APIKeys{}
+	for name, key := range cfg.APIKeys {
+		apiKeys[name] = string(key)
+	}
+	m := &auth.Middleware{Public: []string{"/auth/token"}}
+	if len(apiKeys) > 0 {
+		m.Authenticators = append(m.Authenticators, apiKeys)
+	}
+	if !keys.Empty() {
+		m.Authenticators = append(m.Authenticators, &auth.JWT{Keys: keys, Issuer: cfg.JWTIssuer, Leeway: 30 * time.Second})
+	}
+	return m, issuer, nil
 }
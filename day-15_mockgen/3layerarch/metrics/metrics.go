@@ -0,0 +1,126 @@
+// Package metrics exposes Prometheus metrics of the HTTP API, the database
+// pool and what happens to tasks and users.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"3layerarch/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatched is the route of requests that match no pattern, so that unknown
+// paths cannot each make a series of their own.
+const unmatched = "unmatched"
+
+// Metrics holds the collectors of one server. It counts task and user
+// changes for the services and measures the requests it wraps.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+
+	tasksCreated   prometheus.Counter
+	tasksCompleted prometheus.Counter
+	tasksDeleted   prometheus.Counter
+	usersCreated   prometheus.Counter
+
+	cacheLookups *prometheus.CounterVec
+}
+
+// New registers the metrics of a server that uses db, called dbName in the
+// pool stats.
+func New(db *sql.DB, dbName string) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests served, by method, route pattern and status code.",
+		}, []string{"method", "route", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by method and route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "HTTP requests being served.",
+		}),
+		tasksCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tasks_created_total",
+			Help: "Tasks created.",
+		}),
+		tasksCompleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tasks_completed_total",
+			Help: "Tasks marked completed.",
+		}),
+		tasksDeleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tasks_deleted_total",
+			Help: "Tasks deleted.",
+		}),
+		usersCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "users_created_total",
+			Help: "Users created.",
+		}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cache_lookups_total",
+			Help: "Lookups of the task and user caches, by cache and whether they hit.",
+		}, []string{"cache", "result"}),
+	}
+	m.registry.MustRegister(
+		m.requests, m.duration, m.inFlight,
+		m.tasksCreated, m.tasksCompleted, m.tasksDeleted, m.usersCreated,
+		m.cacheLookups,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+	}
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Wrap measures the requests served by next. Requests are labelled with the
+// pattern of mux they match, such as /task/{id}, rather than their path.
+func (m *Metrics) Wrap(mux *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := unmatched
+			if _, pattern := mux.Handler(r); pattern != "" {
+				// The method is a label of its own
+				route = pattern[strings.Index(pattern, "/"):]
+			}
+
+			m.inFlight.Inc()
+			defer m.inFlight.Dec()
+			start := time.Now()
+			rec := middleware.NewRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			m.requests.WithLabelValues(r.Method, route, strconv.Itoa(rec.Status())).Inc()
+			m.duration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+func (m *Metrics) TaskCreated()   { m.tasksCreated.Inc() }
+func (m *Metrics) TaskCompleted() { m.tasksCompleted.Inc() }
+func (m *Metrics) TaskDeleted()   { m.tasksDeleted.Inc() }
+func (m *Metrics) UserCreated()   { m.usersCreated.Inc() }
+
+func (m *Metrics) CacheHit(name string)  { m.cacheLookups.WithLabelValues(name, "hit").Inc() }
+func (m *Metrics) CacheMiss(name string) { m.cacheLookups.WithLabelValues(name, "miss").Inc() }
@@ -0,0 +1,95 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"3layerarch/metrics"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// scrape returns what the metrics handler serves.
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	b, _ := io.ReadAll(w.Body)
+	return string(b)
+}
+
+func TestWrap_LabelsRoutesByPattern(t *testing.T) {
+	m := metrics.New(nil, "")
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /task/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "404" {
+			http.NotFound(w, r)
+		}
+	})
+	h := m.Wrap(mux)(mux)
+
+	for _, path := range []string{"/task/1", "/task/2", "/task/404", "/no/such/path"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	out := scrape(t, m)
+	for _, want := range []string{
+		`http_requests_total{code="200",method="GET",route="/task/{id}"} 2`,
+		`http_requests_total{code="404",method="GET",route="/task/{id}"} 1`,
+		`http_requests_total{code="404",method="GET",route="unmatched"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/task/{id}"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "/task/1") || strings.Contains(out, "/no/such/path") {
+		t.Error("expected no raw paths in the labels")
+	}
+}
+
+func TestDomainCounters(t *testing.T) {
+	m := metrics.New(nil, "")
+	m.TaskCreated()
+	m.TaskCreated()
+	m.TaskCompleted()
+	m.TaskDeleted()
+	m.UserCreated()
+	m.CacheHit("task")
+	m.CacheMiss("task")
+	m.CacheMiss("task")
+	m.CacheHit("user")
+
+	out := scrape(t, m)
+	for _, want := range []string{
+		"tasks_created_total 2",
+		"tasks_completed_total 1",
+		"tasks_deleted_total 1",
+		"users_created_total 1",
+		`cache_lookups_total{cache="task",result="hit"} 1`,
+		`cache_lookups_total{cache="task",result="miss"} 2`,
+		`cache_lookups_total{cache="user",result="hit"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in the metrics", want)
+		}
+	}
+}
+
+func TestDBStats(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	out := scrape(t, metrics.New(db, "test_db"))
+	if !strings.Contains(out, `go_sql_max_open_connections{db_name="test_db"} 0`) {
+		t.Errorf("expected the pool stats of test_db in:\n%s", out)
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// AccessLog logs one line for each request once it has been served, with
+// its status, response size, duration and request ID.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := NewRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		id := RequestIDFrom(r.Context())
+		if id == "" {
+			id = "-"
+		}
+		log.Printf("%s %s %s %d %dB %s id=%s", r.RemoteAddr, r.Method, r.URL.RequestURI(), rec.Status(), rec.Bytes(), time.Since(start).Round(time.Microsecond), id)
+	})
+}
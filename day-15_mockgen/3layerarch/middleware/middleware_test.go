@@ -0,0 +1,269 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"3layerarch/handler"
+	"3layerarch/middleware"
+)
+
+// captureLog sends the standard logger to a buffer for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	return &buf
+}
+
+func TestChain_Order(t *testing.T) {
+	var order []string
+	mark := func(name string) middleware.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := middleware.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mark("first"), mark("second"))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := strings.Join(order, ","); got != "first,second,handler" {
+		t.Errorf("expected first,second,handler, got %s", got)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		desc       string
+		write      func(w http.ResponseWriter)
+		wantStatus int
+		wantBytes  int
+	}{
+		{"Nothing Written", func(w http.ResponseWriter) {}, http.StatusOK, 0},
+		{"Body Only", func(w http.ResponseWriter) { _, _ = w.Write([]byte("hello")) }, http.StatusOK, 5},
+		{"Status Then Body", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("gone"))
+		}, http.StatusNotFound, 4},
+	}
+
+	for _, tc := range tests {
+		rec := middleware.NewRecorder(httptest.NewRecorder())
+		tc.write(rec)
+		if rec.Status() != tc.wantStatus || rec.Bytes() != tc.wantBytes {
+			t.Errorf("%s: expected %d and %dB, got %d and %dB", tc.desc, tc.wantStatus, tc.wantBytes, rec.Status(), rec.Bytes())
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = middleware.RequestIDFrom(r.Context())
+	}))
+
+	tests := []struct {
+		name string
+		sent string
+		keep bool
+	}{
+		{name: "none sent", sent: ""},
+		{name: "valid ID kept", sent: "3f2a-request_1.b", keep: true},
+		{name: "forged log line replaced", sent: "x\nGET /admin 200"},
+		{name: "too long replaced", sent: strings.Repeat("a", 129)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/task", nil)
+			if tt.sent != "" {
+				r.Header.Set(middleware.RequestIDHeader, tt.sent)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			got := w.Header().Get(middleware.RequestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("expected the same ID in the response and context, got %q and %q", got, seen)
+			}
+			if (got == tt.sent) != tt.keep {
+				t.Errorf("sent %q, got %q", tt.sent, got)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	buf := captureLog(t)
+	h := middleware.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	}), middleware.RequestID, middleware.AccessLog)
+
+	r := httptest.NewRequest(http.MethodPost, "/task?x=1", nil)
+	r.Header.Set(middleware.RequestIDHeader, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	line := buf.String()
+	for _, want := range []string{"POST /task?x=1 201 5B", "id=req-1"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in the log line, got %q", want, line)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	buf := captureLog(t)
+	h := middleware.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var tasks []string
+		_ = tasks[3]
+	}), middleware.RequestID, middleware.Recover)
+
+	r := httptest.NewRequest(http.MethodGet, "/task/3", nil)
+	r.Header.Set(middleware.RequestIDHeader, "req-2")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+	var resp handler.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error.Code != "internal_error" || strings.Contains(resp.Error.Message, "index out of range") {
+		t.Errorf("unexpected error body: %+v", resp.Error)
+	}
+	if !strings.Contains(buf.String(), "index out of range") || !strings.Contains(buf.String(), "req-2") {
+		t.Errorf("expected the panic and request ID to be logged, got %q", buf.String())
+	}
+}
+
+func TestRecover_AfterWrite(t *testing.T) {
+	captureLog(t)
+	h := middleware.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("half way")
+	}))
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expected the response to be aborted, got %v", v)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestCORS(t *testing.T) {
+	cors := &middleware.CORS{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		MaxAge:         10 * time.Minute,
+	}
+	called := false
+	h := cors.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		preflight  bool
+		wantOrigin string
+		wantCalled bool
+	}{
+		{name: "same origin", method: http.MethodGet, wantCalled: true},
+		{name: "allowed origin", method: http.MethodGet, origin: "https://app.example.com", wantOrigin: "https://app.example.com", wantCalled: true},
+		{name: "other origin", method: http.MethodGet, origin: "https://evil.example.com", wantCalled: true},
+		{name: "preflight", method: http.MethodOptions, origin: "https://app.example.com", preflight: true, wantOrigin: "https://app.example.com"},
+		{name: "preflight from other origin", method: http.MethodOptions, origin: "https://evil.example.com", preflight: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = false
+			r := httptest.NewRequest(tt.method, "/task", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("expected allowed origin %q, got %q", tt.wantOrigin, got)
+			}
+			if called != tt.wantCalled {
+				t.Errorf("expected handler called %v, got %v", tt.wantCalled, called)
+			}
+			if tt.preflight && w.Code != http.StatusNoContent {
+				t.Errorf("expected status 204 for a preflight, got %d", w.Code)
+			}
+			if tt.preflight && tt.wantOrigin != "" {
+				if w.Header().Get("Access-Control-Allow-Methods") != "GET, POST" || w.Header().Get("Access-Control-Max-Age") != "600" {
+					t.Errorf("unexpected preflight headers: %v", w.Header())
+				}
+			}
+		})
+	}
+}
+
+func TestCORS_Disabled(t *testing.T) {
+	next := http.NotFoundHandler()
+	cors := &middleware.CORS{}
+	r := httptest.NewRequest(http.MethodGet, "/task", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	cors.Wrap(next).ServeHTTP(w, r)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected no CORS headers without allowed origins, got %v", w.Header())
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	h := middleware.MaxBodySize(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			handler.WriteBodyError(w, err)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+
+	tests := []struct {
+		name   string
+		body   string
+		length int64
+		status int
+	}{
+		{name: "within the limit", body: "12345678", length: 8, status: http.StatusOK},
+		{name: "declared too long", body: "123456789", length: 9, status: http.StatusRequestEntityTooLarge},
+		{name: "chunked too long", body: "123456789", length: -1, status: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/task", strings.NewReader(tt.body))
+			r.ContentLength = tt.length
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
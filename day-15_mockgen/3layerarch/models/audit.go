@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// AuditAction is what a change did to an entity.
+type AuditAction string
+
+const (
+	AuditCreate   AuditAction = "create"
+	AuditUpdate   AuditAction = "update"
+	AuditComplete AuditAction = "complete"
+	AuditDelete   AuditAction = "delete"
+	AuditRestore  AuditAction = "restore"
+)
+
+// The entities that are audited.
+const (
+	AuditTask = "task"
+	AuditUser = "user"
+)
+
+// Redacted stands in for a value, such as a password, that the audit log
+// records the change of but must not hold.
+const Redacted = "[redacted]"
+
+// AuditEntry records one change to a task or user. Entries are only ever
+// appended; none is changed or removed.
+type AuditEntry struct {
+	ID int `json:"id"`
+	// Time is set by the store when the entry is appended.
+	Time time.Time `json:"time"`
+	// ActorID is the user that made the change, or 0 for an API key.
+	ActorID int `json:"actor_id"`
+	// Actor is the name of the user or API key that made the change, as it
+	// was then. Users are told apart by ActorID, as they can be renamed and
+	// their names reused; API keys, which have no ID, by this name.
+	Actor    string      `json:"actor"`
+	Action   AuditAction `json:"action"`
+	Entity   string      `json:"entity"`
+	EntityID int         `json:"entity_id"`
+	// Changes holds the fields that changed, by their JSON names.
+	Changes   map[string]AuditChange `json:"changes"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+// AuditChange is the value of a field before and after a change. A field
+// that did not exist on one side is nil there.
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// NewAuditEntry records a change to the entity with id made by the request
+// of ctx. before is nil for a create or restore, after for a delete.
+func NewAuditEntry(ctx context.Context, action AuditAction, entity string, id int, before, after any) AuditEntry {
+	e := AuditEntry{
+		Action:    action,
+		Entity:    entity,
+		EntityID:  id,
+		Changes:   Diff(before, after),
+		RequestID: RequestIDFrom(ctx),
+	}
+	if p, ok := PrincipalFrom(ctx); ok {
+		e.ActorID, e.Actor = p.UserID, p.Name
+	}
+	return e
+}
+
+// Diff compares the JSON forms of before and after, either of which may be
+// nil, and returns the fields that differ.
+func Diff(before, after any) map[string]AuditChange {
+	b, a := fields(before), fields(after)
+	changes := map[string]AuditChange{}
+	for k, v := range b {
+		if w, ok := a[k]; !ok || !reflect.DeepEqual(v, w) {
+			changes[k] = AuditChange{Before: v, After: w}
+		}
+	}
+	for k, w := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = AuditChange{After: w}
+		}
+	}
+	return changes
+}
+
+// fields returns the JSON object v is encoded as, or nil.
+func fields(v any) map[string]any {
+	if v == nil {
+		return nil
+	}
+	var m map[string]any
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(data, &m)
+	}
+	if err != nil {
+		// Only structs of this package are audited, and they all encode
+		panic("models: cannot audit " + reflect.TypeOf(v).String() + ": " + err.Error())
+	}
+	return m
+}
+
+// AuditFilter pages the audit log, optionally narrowed to one entity type
+// and to one entity of that type.
+type AuditFilter struct {
+	Entity   string
+	EntityID int
+	Limit    int
+	Offset   int
+}
+
+// AuditPage is one page of the audit log, oldest first, along with the
+// total number of entries that matched the filter.
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	Total   int          `json:"total"`
+	Limit   int          `json:"limit"`
+	Offset  int          `json:"offset"`
+	Next    string       `json:"next,omitempty"`
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx that carries the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the ID of the request ctx belongs to, or "".
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
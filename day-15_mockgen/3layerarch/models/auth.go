@@ -0,0 +1,57 @@
+package models
+
+import "context"
+
+// Role decides what a principal may see and change.
+type Role string
+
+const (
+	// RoleUser may only see and change their own tasks.
+	RoleUser Role = "user"
+	// RoleAdmin may see and change every task.
+	RoleAdmin Role = "admin"
+)
+
+// Principal is who a request was authenticated as.
+type Principal struct {
+	// UserID is the user a token was issued to; 0 for an API key.
+	UserID int
+	// Name is the user's name or the name of the API key.
+	Name string
+	Role Role
+	// Method is how the request authenticated: "api_key" or "jwt".
+	Method string
+}
+
+// CanAccess reports whether p may see and change what the user with userID
+// owns.
+func (p Principal) CanAccess(userID int) bool {
+	return p.Role == RoleAdmin || (p.UserID != 0 && p.UserID == userID)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx that carries p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal of the request ctx belongs to.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Credentials are what a user exchanges for a token.
+type Credentials struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// Token is a bearer token issued to a user, as an OAuth 2 token response.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the lifetime of the token in seconds.
+	ExpiresIn int `json:"expires_in"`
+}
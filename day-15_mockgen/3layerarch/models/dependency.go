@@ -0,0 +1,25 @@
+package models
+
+// Dependency is a blocking edge between two tasks: the task with TaskID
+// cannot be completed until the one with BlockerID is. Dependencies never
+// form a cycle.
+type Dependency struct {
+	TaskID    int `json:"task_id"`
+	BlockerID int `json:"blocker_id"`
+}
+
+// TaskTree is a task along with the IDs of the tasks blocking it and, in
+// turn, the trees of its subtasks.
+type TaskTree struct {
+	Task
+	BlockedBy []int      `json:"blocked_by"`
+	Subtasks  []TaskTree `json:"subtasks"`
+}
+
+// TaskGraph is the dependency graph of a set of tasks: every task with a
+// dependency on, or blocking, another task of the set, and those
+// dependencies.
+type TaskGraph struct {
+	Tasks        []Task       `json:"tasks"`
+	Dependencies []Dependency `json:"dependencies"`
+}
@@ -0,0 +1,14 @@
+package auditservice
+
+import (
+	"context"
+
+	"3layerarch/models"
+)
+
+// AuditStore keeps the audit log. It is append-only: entries are added by
+// the task and user services as they make changes, and only read here.
+type AuditStore interface {
+	AppendAudit(ctx context.Context, e models.AuditEntry) error
+	ViewAudit(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, int, error)
+}
@@ -0,0 +1,59 @@
+package auditservice
+
+import (
+	"3layerarch/models"
+	"context"
+	"fmt"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type Service struct {
+	Store AuditStore
+}
+
+func New(store AuditStore) *Service {
+	return &Service{Store: store}
+}
+
+// ViewAudit returns the page of the audit log selected by f, oldest first.
+// A zero limit means the default page size. Only admins read the log, as it
+// holds the changes of every user.
+func (s *Service) ViewAudit(ctx context.Context, f models.AuditFilter) (models.AuditPage, error) {
+	p, ok := models.PrincipalFrom(ctx)
+	if !ok {
+		return models.AuditPage{}, models.Unauthorized("authentication required")
+	}
+	if p.Role != models.RoleAdmin {
+		return models.AuditPage{}, models.Forbidden("only admins can read the audit log")
+	}
+	switch f.Entity {
+	case "", models.AuditTask, models.AuditUser:
+	default:
+		return models.AuditPage{}, models.Validation(fmt.Sprintf("unknown entity %q", f.Entity))
+	}
+	if f.EntityID < 0 {
+		return models.AuditPage{}, models.Validation("invalid entity ID")
+	}
+	if f.EntityID != 0 && f.Entity == "" {
+		return models.AuditPage{}, models.Validation("an entity ID needs an entity")
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultPageSize
+	}
+	if f.Limit < 0 || f.Limit > maxPageSize {
+		return models.AuditPage{}, models.Validation(fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+	}
+	if f.Offset < 0 {
+		return models.AuditPage{}, models.Validation("offset cannot be negative")
+	}
+
+	entries, total, err := s.Store.ViewAudit(ctx, f)
+	if err != nil {
+		return models.AuditPage{}, err
+	}
+	return models.AuditPage{Entries: entries, Total: total, Limit: f.Limit, Offset: f.Offset}, nil
+}
@@ -0,0 +1,100 @@
+package auditservice
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"3layerarch/models"
+	"go.uber.org/mock/gomock"
+)
+
+var adminCtx = models.WithPrincipal(context.Background(), models.Principal{Name: "ops", Role: models.RoleAdmin})
+
+func TestViewAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockAuditStore(ctrl)
+	svc := New(mockStore)
+
+	userCtx := models.WithPrincipal(context.Background(), models.Principal{UserID: 1, Role: models.RoleUser})
+	entries := []models.AuditEntry{{ID: 1, Action: models.AuditCreate, Entity: models.AuditTask, EntityID: 7}}
+
+	tests := []struct {
+		desc     string
+		ctx      context.Context
+		filter   models.AuditFilter
+		query    *models.AuditFilter
+		storeErr error
+		want     models.AuditPage
+		wantErr  error
+	}{
+		{
+			desc: "One Task", ctx: adminCtx, filter: models.AuditFilter{Entity: models.AuditTask, EntityID: 7},
+			query: &models.AuditFilter{Entity: models.AuditTask, EntityID: 7, Limit: 20},
+			want:  models.AuditPage{Entries: entries, Total: 1, Limit: 20},
+		},
+		{
+			desc: "Whole Log", ctx: adminCtx, filter: models.AuditFilter{Limit: 5, Offset: 5},
+			query: &models.AuditFilter{Limit: 5, Offset: 5},
+			want:  models.AuditPage{Entries: entries, Total: 1, Limit: 5, Offset: 5},
+		},
+		{
+			desc: "No Principal", ctx: context.Background(),
+			wantErr: errors.New("authentication required"),
+		},
+		{
+			desc: "Not An Admin", ctx: userCtx,
+			wantErr: errors.New("only admins can read the audit log"),
+		},
+		{
+			desc: "Unknown Entity", ctx: adminCtx, filter: models.AuditFilter{Entity: "project"},
+			wantErr: errors.New(`unknown entity "project"`),
+		},
+		{
+			desc: "Negative ID", ctx: adminCtx, filter: models.AuditFilter{Entity: models.AuditTask, EntityID: -1},
+			wantErr: errors.New("invalid entity ID"),
+		},
+		{
+			desc: "ID Without Entity", ctx: adminCtx, filter: models.AuditFilter{EntityID: 7},
+			wantErr: errors.New("an entity ID needs an entity"),
+		},
+		{
+			desc: "Limit Too Large", ctx: adminCtx, filter: models.AuditFilter{Limit: 101},
+			wantErr: errors.New("limit must be between 1 and 100"),
+		},
+		{
+			desc: "Negative Offset", ctx: adminCtx, filter: models.AuditFilter{Offset: -1},
+			wantErr: errors.New("offset cannot be negative"),
+		},
+		{
+			desc: "DB Error", ctx: adminCtx, query: &models.AuditFilter{Limit: 20}, storeErr: errors.New("db error"),
+			wantErr: errors.New("db error"),
+		},
+	}
+
+	for _, test := range tests {
+		if test.query != nil {
+			mockStore.EXPECT().ViewAudit(gomock.Any(), *test.query).
+				Return(test.want.Entries, test.want.Total, test.storeErr)
+		}
+
+		got, err := svc.ViewAudit(test.ctx, test.filter)
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if err == nil && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected page %+v, got %+v", test.desc, test.want, got)
+		}
+	}
+}
+
+func errorsEqual(err1, err2 error) bool {
+	if err1 == nil && err2 == nil {
+		return true
+	}
+	if err1 == nil || err2 == nil {
+		return false
+	}
+	return err1.Error() == err2.Error()
+}
@@ -0,0 +1,271 @@
+package taskservice
+
+import (
+	"3layerarch/models"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// checkParent makes sure t can be a subtask of its parent, if it has one:
+// the parent is a task the caller can see, and not t or one of t's own
+// subtasks. The parent is locked until the unit of work ends, and so is
+// every task on the way up from it, so that a concurrent move of one of
+// them cannot close a loop this walk has already passed.
+func (s *Service) checkParent(ctx context.Context, t models.Task) error {
+	if t.ParentID == 0 {
+		return nil
+	}
+	if t.ParentID < 0 {
+		return models.Validation("invalid parent ID")
+	}
+	if _, err := s.getTask(ctx, t.ParentID, true); err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return models.Validation("parent task not found")
+		}
+		return err
+	}
+	// A new task has no subtasks yet
+	if t.ID == 0 {
+		return nil
+	}
+	for id := t.ParentID; id != 0; {
+		if id == t.ID {
+			return models.Conflict("a task cannot be a subtask of itself or of its own subtasks")
+		}
+		var err error
+		id, err = s.TaskStore.GetParentID(ctx, id)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBlockers refuses to complete the task with id while a task blocking
+// it is still open.
+func (s *Service) checkBlockers(ctx context.Context, id int) error {
+	blockers, err := s.TaskStore.Blockers(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !allCompleted(blockers) {
+		return models.Conflict("task is blocked by open tasks; complete them first or force it")
+	}
+	return nil
+}
+
+// completeParents completes the parent of t, which was just completed, if
+// its subtasks are all completed now, and so on up the tree. A parent with
+// open blockers is left open. It returns how many parents it completed.
+func (s *Service) completeParents(ctx context.Context, t models.Task) (int, error) {
+	n := 0
+	for t.ParentID != 0 {
+		parent, err := s.TaskStore.GetTaskForUpdate(ctx, t.ParentID)
+		if err == sql.ErrNoRows || (err == nil && parent.Completed) {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		subtasks, err := s.TaskStore.Subtasks(ctx, parent.ID)
+		if err != nil {
+			return 0, err
+		}
+		blockers, err := s.TaskStore.Blockers(ctx, parent.ID)
+		if err != nil {
+			return 0, err
+		}
+		if !allCompleted(subtasks) || !allCompleted(blockers) {
+			return n, nil
+		}
+		t = parent
+		t.Completed = true
+		if t, err = s.storeUpdate(ctx, t); err != nil {
+			return 0, err
+		}
+		if err := s.auditUpdate(ctx, parent, t); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// allCompleted reports whether every one of tasks is completed.
+func allCompleted(tasks []models.Task) bool {
+	for _, t := range tasks {
+		if !t.Completed {
+			return false
+		}
+	}
+	return true
+}
+
+// ViewBlockers returns the tasks blocking the task with taskID ordered by
+// ID. Blockers the caller cannot see are left out.
+func (s *Service) ViewBlockers(ctx context.Context, taskID int) ([]models.Task, error) {
+	if _, err := s.getTask(ctx, taskID, false); err != nil {
+		return nil, err
+	}
+	return s.visibleBlockers(ctx, taskID)
+}
+
+// AddDependency makes the task with blockerID block the one with taskID,
+// and returns the task's blockers. A dependency that would close a cycle is
+// refused; adding one twice changes nothing.
+func (s *Service) AddDependency(ctx context.Context, taskID, blockerID int) ([]models.Task, error) {
+	return s.changeDependency(ctx, taskID, blockerID, func(ctx context.Context) error {
+		if taskID == blockerID {
+			return models.Conflict("a task cannot block itself")
+		}
+		blocked, err := s.blocks(ctx, taskID, blockerID)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return models.Conflict("dependency would create a cycle")
+		}
+		return s.TaskStore.AddDependency(ctx, taskID, blockerID)
+	})
+}
+
+// RemoveDependency stops the task with blockerID blocking the one with
+// taskID, and returns the task's blockers. Removing a dependency that does
+// not exist changes nothing.
+func (s *Service) RemoveDependency(ctx context.Context, taskID, blockerID int) ([]models.Task, error) {
+	return s.changeDependency(ctx, taskID, blockerID, func(ctx context.Context) error {
+		return s.TaskStore.RemoveDependency(ctx, taskID, blockerID)
+	})
+}
+
+// changeDependency locks both tasks, lower ID first so that concurrent
+// changes wait for each other rather than deadlock, and applies change.
+func (s *Service) changeDependency(ctx context.Context, taskID, blockerID int, change func(ctx context.Context) error) ([]models.Task, error) {
+	var blockers []models.Task
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		for _, id := range []int{min(taskID, blockerID), max(taskID, blockerID)} {
+			if _, err := s.getTask(ctx, id, true); err != nil {
+				return err
+			}
+		}
+		if err := change(ctx); err != nil {
+			return err
+		}
+		var err error
+		blockers, err = s.visibleBlockers(ctx, taskID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blockers, nil
+}
+
+// blocks reports whether the task with taskID blocks the one with id,
+// directly or through other tasks, counting tasks in the trash, which can be
+// restored. Every task it passes stays locked until the unit of work ends:
+// changeDependency locks the tasks it changes, so two changes that would
+// close a cycle between them cannot both pass the check. One waits for the
+// other, or deadlocks with it and is retried.
+func (s *Service) blocks(ctx context.Context, taskID, id int) (bool, error) {
+	seen := map[int]bool{id: true}
+	for next := []int{id}; len(next) > 0; {
+		blockers, err := s.TaskStore.BlockerIDs(ctx, next[0])
+		if err != nil {
+			return false, err
+		}
+		next = next[1:]
+		for _, b := range blockers {
+			if b == taskID {
+				return true, nil
+			}
+			if !seen[b] {
+				seen[b] = true
+				next = append(next, b)
+			}
+		}
+	}
+	return false, nil
+}
+
+// visibleBlockers returns the blockers of the task with taskID that the
+// caller can see.
+func (s *Service) visibleBlockers(ctx context.Context, taskID int) ([]models.Task, error) {
+	p, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blockers, err := s.TaskStore.Blockers(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	visible := []models.Task{}
+	for _, b := range blockers {
+		if p.CanAccess(b.UserID) {
+			visible = append(visible, b)
+		}
+	}
+	return visible, nil
+}
+
+// TaskTree returns the task with id with its blockers and, in turn, its
+// subtasks. Subtasks and blockers the caller cannot see are left out.
+func (s *Service) TaskTree(ctx context.Context, id int) (models.TaskTree, error) {
+	t, err := s.getTask(ctx, id, false)
+	if err != nil {
+		return models.TaskTree{}, err
+	}
+	p, _ := caller(ctx)
+	return s.tree(ctx, p, t)
+}
+
+// tree builds the tree of t as p sees it.
+func (s *Service) tree(ctx context.Context, p models.Principal, t models.Task) (models.TaskTree, error) {
+	blockers, err := s.visibleBlockers(ctx, t.ID)
+	if err != nil {
+		return models.TaskTree{}, err
+	}
+	node := models.TaskTree{Task: t, BlockedBy: []int{}, Subtasks: []models.TaskTree{}}
+	for _, b := range blockers {
+		node.BlockedBy = append(node.BlockedBy, b.ID)
+	}
+	subtasks, err := s.TaskStore.Subtasks(ctx, t.ID)
+	if err != nil {
+		return models.TaskTree{}, err
+	}
+	for _, sub := range subtasks {
+		if !p.CanAccess(sub.UserID) {
+			continue
+		}
+		child, err := s.tree(ctx, p, sub)
+		if err != nil {
+			return models.TaskTree{}, err
+		}
+		node.Subtasks = append(node.Subtasks, child)
+	}
+	return node, nil
+}
+
+// TaskGraph returns the dependency graph of the tasks of the user with
+// userID, or of every user's tasks for a nil userID. Users other than
+// admins only ever get the graph of their own tasks.
+func (s *Service) TaskGraph(ctx context.Context, userID *int) (models.TaskGraph, error) {
+	p, err := caller(ctx)
+	if err != nil {
+		return models.TaskGraph{}, err
+	}
+	if userID != nil && *userID <= 0 {
+		return models.TaskGraph{}, models.Validation("invalid user ID")
+	}
+	if p.Role != models.RoleAdmin {
+		if userID != nil && *userID != p.UserID {
+			return models.TaskGraph{Tasks: []models.Task{}, Dependencies: []models.Dependency{}}, nil
+		}
+		userID = &p.UserID
+	}
+	return s.TaskStore.TaskGraph(ctx, userID)
+}
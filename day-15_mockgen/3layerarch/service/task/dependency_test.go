@@ -0,0 +1,353 @@
+package taskservice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"3layerarch/models"
+	"go.uber.org/mock/gomock"
+)
+
+// treeStore returns a store holding tasks, keyed by ID, and the blocking
+// dependencies between them as [task, blocker] pairs. It keeps both up to
+// date as the service changes them.
+func treeStore(ctrl *gomock.Controller, tasks map[int]models.Task, deps map[[2]int]bool) *MockTaskStore {
+	get := func(ctx context.Context, id int) (models.Task, error) {
+		t, ok := tasks[id]
+		if !ok {
+			return models.Task{}, sql.ErrNoRows
+		}
+		return t, nil
+	}
+	collect := func(match func(t models.Task) bool) []models.Task {
+		found := []models.Task{}
+		for _, t := range tasks {
+			if match(t) {
+				found = append(found, t)
+			}
+		}
+		slices.SortFunc(found, func(a, b models.Task) int { return a.ID - b.ID })
+		return found
+	}
+
+	m := NewMockTaskStore(ctrl)
+	m.EXPECT().GetTask(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(get)
+	m.EXPECT().GetTaskForUpdate(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(get)
+	m.EXPECT().UpdateTask(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, t models.Task) (models.Task, error) {
+		t.Version++
+		tasks[t.ID] = t
+		return t, nil
+	})
+	m.EXPECT().GetParentID(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, id int) (int, error) {
+		t, err := get(ctx, id)
+		return t.ParentID, err
+	})
+	m.EXPECT().Subtasks(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, parentID int) ([]models.Task, error) {
+		return collect(func(t models.Task) bool { return t.ParentID == parentID }), nil
+	})
+	m.EXPECT().Blockers(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, taskID int) ([]models.Task, error) {
+		return collect(func(t models.Task) bool { return deps[[2]int{taskID, t.ID}] }), nil
+	})
+	m.EXPECT().BlockerIDs(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, taskID int) ([]int, error) {
+		ids := []int{}
+		for d := range deps {
+			if d[0] == taskID {
+				ids = append(ids, d[1])
+			}
+		}
+		return ids, nil
+	})
+	m.EXPECT().AddDependency(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, taskID, blockerID int) error {
+		deps[[2]int{taskID, blockerID}] = true
+		return nil
+	})
+	m.EXPECT().RemoveDependency(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, taskID, blockerID int) error {
+		delete(deps, [2]int{taskID, blockerID})
+		return nil
+	})
+	return m
+}
+
+func TestAddDependency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	tasks := map[int]models.Task{
+		1: {ID: 1, Task: "design", UserID: 1},
+		2: {ID: 2, Task: "build", UserID: 1},
+		3: {ID: 3, Task: "ship", UserID: 1},
+		4: {ID: 4, Task: "theirs", UserID: 2},
+	}
+	deps := map[[2]int]bool{}
+	svc := New(treeStore(ctrl, tasks, deps), NewMockUserService(ctrl))
+	mockTx := NewMockTransactor(ctrl)
+	svc.Tx = mockTx
+	inTx := func(ctx context.Context, fn func(context.Context) error) error { return fn(ctx) }
+
+	// 3 waits for 2, which waits for 1
+	mockTx.EXPECT().InTx(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(inTx)
+	if _, err := svc.AddDependency(userCtx(1), 2, 1); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	got, err := svc.AddDependency(userCtx(1), 3, 2)
+	if err != nil || len(got) != 1 || got[0].ID != 2 {
+		t.Fatalf("expected task 2 blocking task 3, got %v, %v", got, err)
+	}
+
+	tests := []struct {
+		desc    string
+		ctx     context.Context
+		task    int
+		blocker int
+		wantErr error
+	}{
+		{desc: "Direct Cycle", ctx: userCtx(1), task: 1, blocker: 2, wantErr: models.Conflict("dependency would create a cycle")},
+		{desc: "Indirect Cycle", ctx: userCtx(1), task: 1, blocker: 3, wantErr: models.Conflict("dependency would create a cycle")},
+		{desc: "Itself", ctx: userCtx(1), task: 2, blocker: 2, wantErr: models.Conflict("a task cannot block itself")},
+		{desc: "Other User's Blocker", ctx: userCtx(1), task: 3, blocker: 4, wantErr: models.NotFound("task not found")},
+		{desc: "Unknown Task", ctx: adminCtx, task: 9, blocker: 1, wantErr: models.NotFound("task not found")},
+		{desc: "Invalid Task", ctx: adminCtx, task: 0, blocker: 1, wantErr: models.Validation("invalid task ID")},
+	}
+
+	mockTx.EXPECT().InTx(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(inTx)
+	for _, test := range tests {
+		if _, err := svc.AddDependency(test.ctx, test.task, test.blocker); !errorsEqual(err, test.wantErr) {
+			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+	}
+	if len(deps) != 2 {
+		t.Errorf("expected the refused dependencies left out, got %v", deps)
+	}
+
+	// Once removed, the edge no longer closes a cycle
+	if got, err := svc.RemoveDependency(userCtx(1), 3, 2); err != nil || len(got) != 0 {
+		t.Errorf("expected no blockers left, got %v, %v", got, err)
+	}
+	if _, err := svc.AddDependency(userCtx(1), 1, 3); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+}
+
+func TestCompleteTask_Blocked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	tasks := map[int]models.Task{
+		1: {ID: 1, Task: "design", UserID: 1},
+		2: {ID: 2, Task: "build", UserID: 1},
+		3: {ID: 3, Task: "test", UserID: 1},
+	}
+	deps := map[[2]int]bool{{2, 1}: true, {3, 1}: true}
+	svc := New(treeStore(ctrl, tasks, deps), NewMockUserService(ctrl))
+	done := true
+	blocked := models.Conflict("task is blocked by open tasks; complete them first or force it")
+
+	tests := []struct {
+		desc    string
+		call    func() (models.Task, error)
+		wantErr error
+	}{
+		{
+			desc: "Patch",
+			call: func() (models.Task, error) {
+				return svc.PatchTask(userCtx(1), 2, models.TaskPatch{Completed: &done}, 0)
+			},
+			wantErr: blocked,
+		},
+		{
+			desc: "Update",
+			call: func() (models.Task, error) {
+				return svc.UpdateTask(userCtx(1), 2, models.Task{Task: "build", UserID: 1, Completed: true}, 0)
+			},
+			wantErr: blocked,
+		},
+		{
+			desc:    "Complete",
+			call:    func() (models.Task, error) { return svc.CompleteTask(userCtx(1), 2, false, 0) },
+			wantErr: blocked,
+		},
+		{
+			desc: "Forced",
+			call: func() (models.Task, error) { return svc.CompleteTask(userCtx(1), 2, true, 0) },
+		},
+		{
+			desc: "Blocker",
+			call: func() (models.Task, error) { return svc.CompleteTask(userCtx(1), 1, false, 0) },
+		},
+		{
+			desc: "Unblocked",
+			call: func() (models.Task, error) {
+				return svc.PatchTask(userCtx(1), 3, models.TaskPatch{Completed: &done}, 0)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := test.call()
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if test.wantErr == nil && !got.Completed {
+			t.Errorf("%v: expected the task completed, got %v", test.desc, got)
+		}
+	}
+}
+
+func TestCompleteTask_Parents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	tasks := map[int]models.Task{
+		1: {ID: 1, Task: "release", UserID: 1},
+		2: {ID: 2, Task: "build", UserID: 1, ParentID: 1},
+		3: {ID: 3, Task: "compile", UserID: 1, ParentID: 2},
+		4: {ID: 4, Task: "link", UserID: 1, ParentID: 2},
+		5: {ID: 5, Task: "announce", UserID: 1},
+	}
+	deps := map[[2]int]bool{}
+	svc := New(treeStore(ctrl, tasks, deps), NewMockUserService(ctrl))
+	mockMetrics := NewMockMetrics(ctrl)
+	svc.Metrics = mockMetrics
+
+	// Another subtask of 2 is still open
+	mockMetrics.EXPECT().TaskCompleted()
+	if _, err := svc.CompleteTask(userCtx(1), 3, false, 0); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if tasks[2].Completed {
+		t.Errorf("expected the parent open while a subtask is")
+	}
+
+	// The last subtask completes 2, and so 1
+	mockMetrics.EXPECT().TaskCompleted().Times(3)
+	if _, err := svc.CompleteTask(userCtx(1), 4, false, 0); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !tasks[2].Completed || !tasks[1].Completed {
+		t.Errorf("expected both parents completed, got %+v and %+v", tasks[2], tasks[1])
+	}
+
+	// A parent with an open blocker stays open
+	tasks[6] = models.Task{ID: 6, Task: "party", UserID: 1, ParentID: 5}
+	deps[[2]int{5, 2}] = true
+	tasks[2] = models.Task{ID: 2, Task: "build", UserID: 1, ParentID: 1}
+	mockMetrics.EXPECT().TaskCompleted()
+	if _, err := svc.CompleteTask(userCtx(1), 6, false, 0); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if tasks[5].Completed {
+		t.Errorf("expected the blocked parent left open")
+	}
+}
+
+func TestPatchTask_Parent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	tasks := map[int]models.Task{
+		1: {ID: 1, Task: "release", UserID: 1},
+		2: {ID: 2, Task: "build", UserID: 1, ParentID: 1},
+		3: {ID: 3, Task: "compile", UserID: 1, ParentID: 2},
+		4: {ID: 4, Task: "theirs", UserID: 2},
+	}
+	svc := New(treeStore(ctrl, tasks, map[[2]int]bool{}), NewMockUserService(ctrl))
+	ownSubtask := models.Conflict("a task cannot be a subtask of itself or of its own subtasks")
+
+	tests := []struct {
+		desc       string
+		task       int
+		parent     int
+		wantParent int
+		wantErr    error
+	}{
+		{desc: "Itself", task: 1, parent: 1, wantErr: ownSubtask},
+		{desc: "Own Subtask", task: 1, parent: 3, wantErr: ownSubtask},
+		{desc: "Other User's Task", task: 1, parent: 4, wantErr: models.Validation("parent task not found")},
+		{desc: "Unknown Parent", task: 1, parent: 9, wantErr: models.Validation("parent task not found")},
+		{desc: "Invalid Parent", task: 1, parent: -1, wantErr: models.Validation("invalid parent ID")},
+		{desc: "Moved", task: 3, parent: 1, wantParent: 1},
+		{desc: "Top Level", task: 3, parent: 0, wantParent: 0},
+	}
+
+	for _, test := range tests {
+		got, err := svc.PatchTask(userCtx(1), test.task, models.TaskPatch{ParentID: &test.parent}, 0)
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if err == nil && got.ParentID != test.wantParent {
+			t.Errorf("%v: expected parent %d, got %v", test.desc, test.wantParent, got)
+		}
+	}
+}
+
+func TestTaskTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	tasks := map[int]models.Task{
+		1: {ID: 1, Task: "release", UserID: 1},
+		2: {ID: 2, Task: "build", UserID: 1, ParentID: 1},
+		3: {ID: 3, Task: "compile", UserID: 1, ParentID: 2},
+		4: {ID: 4, Task: "docs", UserID: 1, ParentID: 1},
+		5: {ID: 5, Task: "theirs", UserID: 2, ParentID: 1},
+	}
+	deps := map[[2]int]bool{{4, 2}: true, {4, 5}: true}
+	svc := New(treeStore(ctrl, tasks, deps), NewMockUserService(ctrl))
+
+	got, err := svc.TaskTree(userCtx(1), 1)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	want := models.TaskTree{Task: tasks[1], BlockedBy: []int{}, Subtasks: []models.TaskTree{
+		{Task: tasks[2], BlockedBy: []int{}, Subtasks: []models.TaskTree{
+			{Task: tasks[3], BlockedBy: []int{}, Subtasks: []models.TaskTree{}},
+		}},
+		{Task: tasks[4], BlockedBy: []int{2}, Subtasks: []models.TaskTree{}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if _, err := svc.TaskTree(userCtx(2), 1); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected another user's tree not found, got %v", err)
+	}
+}
+
+func TestTaskGraph(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	svc := New(mockTaskStore, NewMockUserService(ctrl))
+
+	own, other, bad := 3, 4, 0
+	empty := models.TaskGraph{Tasks: []models.Task{}, Dependencies: []models.Dependency{}}
+
+	tests := []struct {
+		desc      string
+		ctx       context.Context
+		userID    *int
+		setupMock func()
+		wantErr   error
+	}{
+		{
+			desc: "Own Graph", ctx: userCtx(3),
+			setupMock: func() { mockTaskStore.EXPECT().TaskGraph(gomock.Any(), &own).Return(empty, nil) },
+		},
+		{desc: "Other User's Graph", ctx: userCtx(3), userID: &other},
+		{
+			desc: "Every User's Graph", ctx: adminCtx,
+			setupMock: func() { mockTaskStore.EXPECT().TaskGraph(gomock.Any(), nil).Return(empty, nil) },
+		},
+		{
+			desc: "One User's Graph", ctx: adminCtx, userID: &other,
+			setupMock: func() { mockTaskStore.EXPECT().TaskGraph(gomock.Any(), &other).Return(empty, nil) },
+		},
+		{desc: "Invalid User ID", ctx: adminCtx, userID: &bad, wantErr: models.Validation("invalid user ID")},
+	}
+
+	for _, test := range tests {
+		if test.setupMock != nil {
+			test.setupMock()
+		}
+		got, err := svc.TaskGraph(test.ctx, test.userID)
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if err == nil && !reflect.DeepEqual(got, empty) {
+			t.Errorf("%v: expected an empty graph, got %+v", test.desc, got)
+		}
+	}
+}
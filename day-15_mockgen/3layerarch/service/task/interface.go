@@ -1,15 +1,62 @@
 package taskservice
 
-import "3layerarch/models"
+import (
+	"context"
+	"time"
+
+	"3layerarch/models"
+)
 
 type TaskStore interface {
-	CreateTask(t models.Task) error
-	GetTask(id int) (models.Task, error)
-	ViewTasks() ([]models.Task, error)
-	UpdateTask(id int) error
-	DeleteTask(id int) error
+	CreateTask(ctx context.Context, t models.Task) (models.Task, error)
+	GetTask(ctx context.Context, id int) (models.Task, error)
+	GetTaskForUpdate(ctx context.Context, id int) (models.Task, error)
+	ViewTasks(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error)
+	UpdateTask(ctx context.Context, t models.Task) (models.Task, error)
+	DeleteTask(ctx context.Context, id int) error
+	GetDeletedTaskForUpdate(ctx context.Context, id int) (models.Task, error)
+	RestoreTask(ctx context.Context, id int) (models.Task, error)
+	PurgeTasks(ctx context.Context, before time.Time) (int, error)
+
+	CreateLabel(ctx context.Context, l models.Label) (models.Label, error)
+	GetLabel(ctx context.Context, id int) (models.Label, error)
+	GetLabelByName(ctx context.Context, name string) (models.Label, error)
+	ViewLabels(ctx context.Context) ([]models.Label, error)
+	UpdateLabel(ctx context.Context, l models.Label) error
+	DeleteLabel(ctx context.Context, id int) error
+	TaskLabels(ctx context.Context, taskID int) ([]models.Label, error)
+	AddTaskLabel(ctx context.Context, taskID, labelID int) error
+	RemoveTaskLabel(ctx context.Context, taskID, labelID int) error
+
+	GetParentID(ctx context.Context, id int) (int, error)
+	Subtasks(ctx context.Context, parentID int) ([]models.Task, error)
+	Blockers(ctx context.Context, taskID int) ([]models.Task, error)
+	BlockerIDs(ctx context.Context, taskID int) ([]int, error)
+	AddDependency(ctx context.Context, taskID, blockerID int) error
+	RemoveDependency(ctx context.Context, taskID, blockerID int) error
+	TaskGraph(ctx context.Context, userID *int) (models.TaskGraph, error)
 }
 
 type UserService interface {
-	GetUser(id int) (models.User, error)
+	GetUser(ctx context.Context, id int) (models.User, error)
+	LockUser(ctx context.Context, id int) (models.User, error)
+}
+
+// Transactor runs fn as one unit of work: store calls made with the ctx it
+// passes to fn share a transaction. fn may be run again after a deadlock.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Auditor records changes in the audit log. Called in a unit of work, the
+// entry is written in its transaction.
+type Auditor interface {
+	AppendAudit(ctx context.Context, e models.AuditEntry) error
+}
+
+// Metrics counts what happens to tasks.
+type Metrics interface {
+	TaskCreated()
+	TaskCompleted()
+	TaskDeleted()
 }
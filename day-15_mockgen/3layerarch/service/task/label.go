@@ -0,0 +1,177 @@
+package taskservice
+
+import (
+	"3layerarch/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxLabelLength = 50
+
+// CreateLabel validates l and returns the stored label with its new ID. Any
+// signed-in user can create labels.
+func (s *Service) CreateLabel(ctx context.Context, l models.Label) (models.Label, error) {
+	if _, err := caller(ctx); err != nil {
+		return models.Label{}, err
+	}
+	var created models.Label
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		name, err := s.validateLabelName(ctx, 0, l.Name)
+		if err != nil {
+			return err
+		}
+		created, err = s.TaskStore.CreateLabel(ctx, models.Label{Name: name})
+		return err
+	})
+	if err != nil {
+		return models.Label{}, err
+	}
+	return created, nil
+}
+
+// GetLabel returns the label with id.
+func (s *Service) GetLabel(ctx context.Context, id int) (models.Label, error) {
+	if _, err := caller(ctx); err != nil {
+		return models.Label{}, err
+	}
+	return s.getLabel(ctx, id)
+}
+
+// getLabel looks up the label with id.
+func (s *Service) getLabel(ctx context.Context, id int) (models.Label, error) {
+	if id <= 0 {
+		return models.Label{}, models.Validation("invalid label ID")
+	}
+	l, err := s.TaskStore.GetLabel(ctx, id)
+	if err == sql.ErrNoRows {
+		return models.Label{}, models.NotFound("label not found")
+	}
+	return l, err
+}
+
+// ViewLabels returns every label ordered by name.
+func (s *Service) ViewLabels(ctx context.Context) ([]models.Label, error) {
+	if _, err := caller(ctx); err != nil {
+		return nil, err
+	}
+	return s.TaskStore.ViewLabels(ctx)
+}
+
+// UpdateLabel renames the label with id and returns it. Labels are shared
+// by everyone's tasks, so only admins change them.
+func (s *Service) UpdateLabel(ctx context.Context, id int, l models.Label) (models.Label, error) {
+	if err := checkAdmin(ctx, "only admins can rename labels"); err != nil {
+		return models.Label{}, err
+	}
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		if _, err := s.getLabel(ctx, id); err != nil {
+			return err
+		}
+		name, err := s.validateLabelName(ctx, id, l.Name)
+		if err != nil {
+			return err
+		}
+		l = models.Label{ID: id, Name: name}
+		return s.TaskStore.UpdateLabel(ctx, l)
+	})
+	if err != nil {
+		return models.Label{}, err
+	}
+	return l, nil
+}
+
+// DeleteLabel deletes the label with id and takes it off every task. Only
+// admins delete labels.
+func (s *Service) DeleteLabel(ctx context.Context, id int) error {
+	if err := checkAdmin(ctx, "only admins can delete labels"); err != nil {
+		return err
+	}
+	return s.inTx(ctx, func(ctx context.Context) error {
+		if _, err := s.getLabel(ctx, id); err != nil {
+			return err
+		}
+		return s.TaskStore.DeleteLabel(ctx, id)
+	})
+}
+
+// ViewTaskLabels returns the labels of the task with taskID ordered by name.
+func (s *Service) ViewTaskLabels(ctx context.Context, taskID int) ([]models.Label, error) {
+	if _, err := s.getTask(ctx, taskID, false); err != nil {
+		return nil, err
+	}
+	return s.TaskStore.TaskLabels(ctx, taskID)
+}
+
+// AddTaskLabel puts the label with labelID on the task with taskID and
+// returns the task's labels. Adding a label twice changes nothing.
+func (s *Service) AddTaskLabel(ctx context.Context, taskID, labelID int) ([]models.Label, error) {
+	return s.changeTaskLabel(ctx, taskID, labelID, s.TaskStore.AddTaskLabel)
+}
+
+// RemoveTaskLabel takes the label with labelID off the task with taskID and
+// returns the task's labels. Removing a label the task does not have changes
+// nothing.
+func (s *Service) RemoveTaskLabel(ctx context.Context, taskID, labelID int) ([]models.Label, error) {
+	return s.changeTaskLabel(ctx, taskID, labelID, s.TaskStore.RemoveTaskLabel)
+}
+
+// changeTaskLabel applies change to the labels of the task with taskID,
+// which is locked until the labels that result are read.
+func (s *Service) changeTaskLabel(ctx context.Context, taskID, labelID int, change func(ctx context.Context, taskID, labelID int) error) ([]models.Label, error) {
+	var labels []models.Label
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		if _, err := s.getTask(ctx, taskID, true); err != nil {
+			return err
+		}
+		if _, err := s.getLabel(ctx, labelID); err != nil {
+			return err
+		}
+		if err := change(ctx, taskID, labelID); err != nil {
+			return err
+		}
+		var err error
+		labels, err = s.TaskStore.TaskLabels(ctx, taskID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// validateLabelName checks a new name for the label with id (0 for a new
+// label) and returns it trimmed. Label names are unique.
+func (s *Service) validateLabelName(ctx context.Context, id int, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", models.Validation("label name cannot be empty")
+	}
+	if utf8.RuneCountInString(name) > maxLabelLength {
+		return "", models.Validation(fmt.Sprintf("label name cannot be longer than %d characters", maxLabelLength))
+	}
+	other, err := s.TaskStore.GetLabelByName(ctx, name)
+	switch {
+	case err == sql.ErrNoRows:
+		return name, nil
+	case err != nil:
+		return "", err
+	case other.ID != id:
+		return "", models.Conflict("label name already taken")
+	}
+	return name, nil
+}
+
+// checkAdmin refuses callers other than admins with msg.
+func checkAdmin(ctx context.Context, msg string) error {
+	p, err := caller(ctx)
+	if err != nil {
+		return err
+	}
+	if p.Role != models.RoleAdmin {
+		return models.Forbidden(msg)
+	}
+	return nil
+}
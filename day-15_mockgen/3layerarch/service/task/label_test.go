@@ -0,0 +1,252 @@
+package taskservice
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"3layerarch/models"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCreateLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	svc := New(mockTaskStore, NewMockUserService(ctrl))
+
+	tests := []struct {
+		desc     string
+		input    models.Label
+		lookup   bool
+		existing models.Label
+		create   bool
+		want     models.Label
+		wantErr  error
+	}{
+		{
+			desc: "Valid Label", input: models.Label{Name: "  work "},
+			lookup: true, create: true, want: models.Label{ID: 3, Name: "work"},
+		},
+		{
+			desc: "Empty Name", input: models.Label{Name: " "},
+			wantErr: errors.New("label name cannot be empty"),
+		},
+		{
+			desc: "Name Too Long", input: models.Label{Name: strings.Repeat("x", 51)},
+			wantErr: errors.New("label name cannot be longer than 50 characters"),
+		},
+		{
+			desc: "Name Taken", input: models.Label{Name: "home"},
+			lookup: true, existing: models.Label{ID: 2, Name: "home"}, wantErr: errors.New("label name already taken"),
+		},
+	}
+
+	for _, test := range tests {
+		name := strings.TrimSpace(test.input.Name)
+		if test.lookup {
+			lookupErr := error(nil)
+			if test.existing.ID == 0 {
+				lookupErr = sql.ErrNoRows
+			}
+			mockTaskStore.EXPECT().GetLabelByName(gomock.Any(), name).Return(test.existing, lookupErr)
+		}
+		if test.create {
+			mockTaskStore.EXPECT().CreateLabel(gomock.Any(), models.Label{Name: name}).Return(test.want, nil)
+		}
+
+		got, err := svc.CreateLabel(userCtx(1), test.input)
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: expected label %v, got %v", test.desc, test.want, got)
+		}
+	}
+}
+
+func TestUpdateLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	svc := New(mockTaskStore, NewMockUserService(ctrl))
+
+	work, home := models.Label{ID: 1, Name: "work"}, models.Label{ID: 2, Name: "home"}
+
+	tests := []struct {
+		desc      string
+		setupMock func()
+		call      func() error
+		wantErr   error
+	}{
+		{
+			desc: "Rename",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetLabel(gomock.Any(), 1).Return(work, nil)
+				mockTaskStore.EXPECT().GetLabelByName(gomock.Any(), "office").Return(models.Label{}, sql.ErrNoRows)
+				mockTaskStore.EXPECT().UpdateLabel(gomock.Any(), models.Label{ID: 1, Name: "office"}).Return(nil)
+			},
+			call: func() error {
+				got, err := svc.UpdateLabel(adminCtx, 1, models.Label{Name: "office"})
+				if err == nil && got != (models.Label{ID: 1, Name: "office"}) {
+					return errors.New("unexpected label " + got.Name)
+				}
+				return err
+			},
+		},
+		{
+			desc: "Same Name",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetLabel(gomock.Any(), 1).Return(work, nil)
+				mockTaskStore.EXPECT().GetLabelByName(gomock.Any(), "work").Return(work, nil)
+				mockTaskStore.EXPECT().UpdateLabel(gomock.Any(), work).Return(nil)
+			},
+			call: func() error {
+				_, err := svc.UpdateLabel(adminCtx, 1, work)
+				return err
+			},
+		},
+		{
+			desc: "Name Taken",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetLabel(gomock.Any(), 1).Return(work, nil)
+				mockTaskStore.EXPECT().GetLabelByName(gomock.Any(), "home").Return(home, nil)
+			},
+			call: func() error {
+				_, err := svc.UpdateLabel(adminCtx, 1, home)
+				return err
+			},
+			wantErr: models.Conflict("label name already taken"),
+		},
+		{
+			desc:      "Label Not Found",
+			setupMock: func() { mockTaskStore.EXPECT().GetLabel(gomock.Any(), 9).Return(models.Label{}, sql.ErrNoRows) },
+			call: func() error {
+				_, err := svc.UpdateLabel(adminCtx, 9, work)
+				return err
+			},
+			wantErr: models.NotFound("label not found"),
+		},
+		{
+			desc: "Not An Admin",
+			call: func() error {
+				_, err := svc.UpdateLabel(userCtx(1), 1, work)
+				return err
+			},
+			wantErr: models.Forbidden("only admins can rename labels"),
+		},
+		{
+			desc: "Invalid ID",
+			call: func() error {
+				_, err := svc.UpdateLabel(adminCtx, 0, work)
+				return err
+			},
+			wantErr: models.Validation("invalid label ID"),
+		},
+	}
+
+	for _, test := range tests {
+		if test.setupMock != nil {
+			test.setupMock()
+		}
+		if err := test.call(); !errorsEqual(err, test.wantErr) {
+			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+	}
+}
+
+func TestDeleteLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	svc := New(mockTaskStore, NewMockUserService(ctrl))
+
+	mockTaskStore.EXPECT().GetLabel(gomock.Any(), 1).Return(models.Label{ID: 1, Name: "work"}, nil)
+	mockTaskStore.EXPECT().DeleteLabel(gomock.Any(), 1).Return(nil)
+	if err := svc.DeleteLabel(adminCtx, 1); err != nil {
+		t.Errorf("Delete: unexpected error %v", err)
+	}
+
+	mockTaskStore.EXPECT().GetLabel(gomock.Any(), 9).Return(models.Label{}, sql.ErrNoRows)
+	if err := svc.DeleteLabel(adminCtx, 9); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Not Found: expected not found, got %v", err)
+	}
+
+	if err := svc.DeleteLabel(userCtx(1), 1); !errors.Is(err, models.ErrForbidden) {
+		t.Errorf("Not An Admin: expected forbidden, got %v", err)
+	}
+}
+
+func TestTaskLabels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	svc := New(mockTaskStore, NewMockUserService(ctrl))
+
+	mine := models.Task{ID: 7, Task: "Mine", UserID: 1}
+	work := models.Label{ID: 1, Name: "work"}
+
+	tests := []struct {
+		desc      string
+		setupMock func()
+		call      func() ([]models.Label, error)
+		want      []models.Label
+		wantErr   error
+	}{
+		{
+			desc: "View",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetTask(gomock.Any(), 7).Return(mine, nil)
+				mockTaskStore.EXPECT().TaskLabels(gomock.Any(), 7).Return([]models.Label{work}, nil)
+			},
+			call: func() ([]models.Label, error) { return svc.ViewTaskLabels(userCtx(1), 7) },
+			want: []models.Label{work},
+		},
+		{
+			desc: "Add",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 7).Return(mine, nil)
+				mockTaskStore.EXPECT().GetLabel(gomock.Any(), 1).Return(work, nil)
+				mockTaskStore.EXPECT().AddTaskLabel(gomock.Any(), 7, 1).Return(nil)
+				mockTaskStore.EXPECT().TaskLabels(gomock.Any(), 7).Return([]models.Label{work}, nil)
+			},
+			call: func() ([]models.Label, error) { return svc.AddTaskLabel(userCtx(1), 7, 1) },
+			want: []models.Label{work},
+		},
+		{
+			desc: "Remove",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 7).Return(mine, nil)
+				mockTaskStore.EXPECT().GetLabel(gomock.Any(), 1).Return(work, nil)
+				mockTaskStore.EXPECT().RemoveTaskLabel(gomock.Any(), 7, 1).Return(nil)
+				mockTaskStore.EXPECT().TaskLabels(gomock.Any(), 7).Return([]models.Label{}, nil)
+			},
+			call: func() ([]models.Label, error) { return svc.RemoveTaskLabel(userCtx(1), 7, 1) },
+			want: []models.Label{},
+		},
+		{
+			desc: "Unknown Label",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 7).Return(mine, nil)
+				mockTaskStore.EXPECT().GetLabel(gomock.Any(), 9).Return(models.Label{}, sql.ErrNoRows)
+			},
+			call:    func() ([]models.Label, error) { return svc.AddTaskLabel(userCtx(1), 7, 9) },
+			wantErr: models.NotFound("label not found"),
+		},
+		{
+			desc:      "Another User's Task",
+			setupMock: func() { mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 7).Return(mine, nil) },
+			call:      func() ([]models.Label, error) { return svc.AddTaskLabel(userCtx(2), 7, 1) },
+			wantErr:   models.NotFound("task not found"),
+		},
+	}
+
+	for _, test := range tests {
+		test.setupMock()
+		got, err := test.call()
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: expected labels %v, got %v", test.desc, test.want, got)
+		}
+	}
+}
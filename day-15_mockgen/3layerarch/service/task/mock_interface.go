@@ -11,7 +11,9 @@ package taskservice
 
 import (
 	models "3layerarch/models"
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -40,76 +42,373 @@ func (m *MockTaskStore) EXPECT() *MockTaskStoreMockRecorder {
 	return m.recorder
 }
 
-// CreateTask mocks base method.
-func (m *MockTaskStore) CreateTask(t models.Task) error {
+// AddDependency mocks base method.
+func (m *MockTaskStore) AddDependency(ctx context.Context, taskID, blockerID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDependency", ctx, taskID, blockerID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDependency indicates an expected call of AddDependency.
+func (mr *MockTaskStoreMockRecorder) AddDependency(ctx, taskID, blockerID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDependency", reflect.TypeOf((*MockTaskStore)(nil).AddDependency), ctx, taskID, blockerID)
+}
+
+// AddTaskLabel mocks base method.
+func (m *MockTaskStore) AddTaskLabel(ctx context.Context, taskID, labelID int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTask", t)
+	ret := m.ctrl.Call(m, "AddTaskLabel", ctx, taskID, labelID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// AddTaskLabel indicates an expected call of AddTaskLabel.
+func (mr *MockTaskStoreMockRecorder) AddTaskLabel(ctx, taskID, labelID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTaskLabel", reflect.TypeOf((*MockTaskStore)(nil).AddTaskLabel), ctx, taskID, labelID)
+}
+
+// BlockerIDs mocks base method.
+func (m *MockTaskStore) BlockerIDs(ctx context.Context, taskID int) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockerIDs", ctx, taskID)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockerIDs indicates an expected call of BlockerIDs.
+func (mr *MockTaskStoreMockRecorder) BlockerIDs(ctx, taskID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockerIDs", reflect.TypeOf((*MockTaskStore)(nil).BlockerIDs), ctx, taskID)
+}
+
+// Blockers mocks base method.
+func (m *MockTaskStore) Blockers(ctx context.Context, taskID int) ([]models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Blockers", ctx, taskID)
+	ret0, _ := ret[0].([]models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Blockers indicates an expected call of Blockers.
+func (mr *MockTaskStoreMockRecorder) Blockers(ctx, taskID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Blockers", reflect.TypeOf((*MockTaskStore)(nil).Blockers), ctx, taskID)
+}
+
+// CreateLabel mocks base method.
+func (m *MockTaskStore) CreateLabel(ctx context.Context, l models.Label) (models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateLabel", ctx, l)
+	ret0, _ := ret[0].(models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateLabel indicates an expected call of CreateLabel.
+func (mr *MockTaskStoreMockRecorder) CreateLabel(ctx, l any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLabel", reflect.TypeOf((*MockTaskStore)(nil).CreateLabel), ctx, l)
+}
+
+// CreateTask mocks base method.
+func (m *MockTaskStore) CreateTask(ctx context.Context, t models.Task) (models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTask", ctx, t)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // CreateTask indicates an expected call of CreateTask.
-func (mr *MockTaskStoreMockRecorder) CreateTask(t any) *gomock.Call {
+func (mr *MockTaskStoreMockRecorder) CreateTask(ctx, t any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTask", reflect.TypeOf((*MockTaskStore)(nil).CreateTask), ctx, t)
+}
+
+// DeleteLabel mocks base method.
+func (m *MockTaskStore) DeleteLabel(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLabel", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLabel indicates an expected call of DeleteLabel.
+func (mr *MockTaskStoreMockRecorder) DeleteLabel(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTask", reflect.TypeOf((*MockTaskStore)(nil).CreateTask), t)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLabel", reflect.TypeOf((*MockTaskStore)(nil).DeleteLabel), ctx, id)
 }
 
 // DeleteTask mocks base method.
-func (m *MockTaskStore) DeleteTask(id int) error {
+func (m *MockTaskStore) DeleteTask(ctx context.Context, id int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteTask", id)
+	ret := m.ctrl.Call(m, "DeleteTask", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteTask indicates an expected call of DeleteTask.
-func (mr *MockTaskStoreMockRecorder) DeleteTask(id any) *gomock.Call {
+func (mr *MockTaskStoreMockRecorder) DeleteTask(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTask", reflect.TypeOf((*MockTaskStore)(nil).DeleteTask), ctx, id)
+}
+
+// GetDeletedTaskForUpdate mocks base method.
+func (m *MockTaskStore) GetDeletedTaskForUpdate(ctx context.Context, id int) (models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeletedTaskForUpdate", ctx, id)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeletedTaskForUpdate indicates an expected call of GetDeletedTaskForUpdate.
+func (mr *MockTaskStoreMockRecorder) GetDeletedTaskForUpdate(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeletedTaskForUpdate", reflect.TypeOf((*MockTaskStore)(nil).GetDeletedTaskForUpdate), ctx, id)
+}
+
+// GetLabel mocks base method.
+func (m *MockTaskStore) GetLabel(ctx context.Context, id int) (models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLabel", ctx, id)
+	ret0, _ := ret[0].(models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLabel indicates an expected call of GetLabel.
+func (mr *MockTaskStoreMockRecorder) GetLabel(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLabel", reflect.TypeOf((*MockTaskStore)(nil).GetLabel), ctx, id)
+}
+
+// GetLabelByName mocks base method.
+func (m *MockTaskStore) GetLabelByName(ctx context.Context, name string) (models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLabelByName", ctx, name)
+	ret0, _ := ret[0].(models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLabelByName indicates an expected call of GetLabelByName.
+func (mr *MockTaskStoreMockRecorder) GetLabelByName(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLabelByName", reflect.TypeOf((*MockTaskStore)(nil).GetLabelByName), ctx, name)
+}
+
+// GetParentID mocks base method.
+func (m *MockTaskStore) GetParentID(ctx context.Context, id int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetParentID", ctx, id)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetParentID indicates an expected call of GetParentID.
+func (mr *MockTaskStoreMockRecorder) GetParentID(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTask", reflect.TypeOf((*MockTaskStore)(nil).DeleteTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetParentID", reflect.TypeOf((*MockTaskStore)(nil).GetParentID), ctx, id)
 }
 
 // GetTask mocks base method.
-func (m *MockTaskStore) GetTask(id int) (models.Task, error) {
+func (m *MockTaskStore) GetTask(ctx context.Context, id int) (models.Task, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTask", id)
+	ret := m.ctrl.Call(m, "GetTask", ctx, id)
 	ret0, _ := ret[0].(models.Task)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetTask indicates an expected call of GetTask.
-func (mr *MockTaskStoreMockRecorder) GetTask(id any) *gomock.Call {
+func (mr *MockTaskStoreMockRecorder) GetTask(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTask", reflect.TypeOf((*MockTaskStore)(nil).GetTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTask", reflect.TypeOf((*MockTaskStore)(nil).GetTask), ctx, id)
 }
 
-// UpdateTask mocks base method.
-func (m *MockTaskStore) UpdateTask(id int) error {
+// GetTaskForUpdate mocks base method.
+func (m *MockTaskStore) GetTaskForUpdate(ctx context.Context, id int) (models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTaskForUpdate", ctx, id)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTaskForUpdate indicates an expected call of GetTaskForUpdate.
+func (mr *MockTaskStoreMockRecorder) GetTaskForUpdate(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTaskForUpdate", reflect.TypeOf((*MockTaskStore)(nil).GetTaskForUpdate), ctx, id)
+}
+
+// PurgeTasks mocks base method.
+func (m *MockTaskStore) PurgeTasks(ctx context.Context, before time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeTasks", ctx, before)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeTasks indicates an expected call of PurgeTasks.
+func (mr *MockTaskStoreMockRecorder) PurgeTasks(ctx, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeTasks", reflect.TypeOf((*MockTaskStore)(nil).PurgeTasks), ctx, before)
+}
+
+// RemoveDependency mocks base method.
+func (m *MockTaskStore) RemoveDependency(ctx context.Context, taskID, blockerID int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateTask", id)
+	ret := m.ctrl.Call(m, "RemoveDependency", ctx, taskID, blockerID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateTask indicates an expected call of UpdateTask.
-func (mr *MockTaskStoreMockRecorder) UpdateTask(id any) *gomock.Call {
+// RemoveDependency indicates an expected call of RemoveDependency.
+func (mr *MockTaskStoreMockRecorder) RemoveDependency(ctx, taskID, blockerID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTask", reflect.TypeOf((*MockTaskStore)(nil).UpdateTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveDependency", reflect.TypeOf((*MockTaskStore)(nil).RemoveDependency), ctx, taskID, blockerID)
 }
 
-// ViewTasks mocks base method.
-func (m *MockTaskStore) ViewTasks() ([]models.Task, error) {
+// RemoveTaskLabel mocks base method.
+func (m *MockTaskStore) RemoveTaskLabel(ctx context.Context, taskID, labelID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveTaskLabel", ctx, taskID, labelID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveTaskLabel indicates an expected call of RemoveTaskLabel.
+func (mr *MockTaskStoreMockRecorder) RemoveTaskLabel(ctx, taskID, labelID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTaskLabel", reflect.TypeOf((*MockTaskStore)(nil).RemoveTaskLabel), ctx, taskID, labelID)
+}
+
+// RestoreTask mocks base method.
+func (m *MockTaskStore) RestoreTask(ctx context.Context, id int) (models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreTask", ctx, id)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreTask indicates an expected call of RestoreTask.
+func (mr *MockTaskStoreMockRecorder) RestoreTask(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreTask", reflect.TypeOf((*MockTaskStore)(nil).RestoreTask), ctx, id)
+}
+
+// Subtasks mocks base method.
+func (m *MockTaskStore) Subtasks(ctx context.Context, parentID int) ([]models.Task, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ViewTasks")
+	ret := m.ctrl.Call(m, "Subtasks", ctx, parentID)
 	ret0, _ := ret[0].([]models.Task)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// Subtasks indicates an expected call of Subtasks.
+func (mr *MockTaskStoreMockRecorder) Subtasks(ctx, parentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subtasks", reflect.TypeOf((*MockTaskStore)(nil).Subtasks), ctx, parentID)
+}
+
+// TaskGraph mocks base method.
+func (m *MockTaskStore) TaskGraph(ctx context.Context, userID *int) (models.TaskGraph, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TaskGraph", ctx, userID)
+	ret0, _ := ret[0].(models.TaskGraph)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TaskGraph indicates an expected call of TaskGraph.
+func (mr *MockTaskStoreMockRecorder) TaskGraph(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TaskGraph", reflect.TypeOf((*MockTaskStore)(nil).TaskGraph), ctx, userID)
+}
+
+// TaskLabels mocks base method.
+func (m *MockTaskStore) TaskLabels(ctx context.Context, taskID int) ([]models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TaskLabels", ctx, taskID)
+	ret0, _ := ret[0].([]models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TaskLabels indicates an expected call of TaskLabels.
+func (mr *MockTaskStoreMockRecorder) TaskLabels(ctx, taskID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TaskLabels", reflect.TypeOf((*MockTaskStore)(nil).TaskLabels), ctx, taskID)
+}
+
+// UpdateLabel mocks base method.
+func (m *MockTaskStore) UpdateLabel(ctx context.Context, l models.Label) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLabel", ctx, l)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateLabel indicates an expected call of UpdateLabel.
+func (mr *MockTaskStoreMockRecorder) UpdateLabel(ctx, l any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLabel", reflect.TypeOf((*MockTaskStore)(nil).UpdateLabel), ctx, l)
+}
+
+// UpdateTask mocks base method.
+func (m *MockTaskStore) UpdateTask(ctx context.Context, t models.Task) (models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTask", ctx, t)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTask indicates an expected call of UpdateTask.
+func (mr *MockTaskStoreMockRecorder) UpdateTask(ctx, t any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTask", reflect.TypeOf((*MockTaskStore)(nil).UpdateTask), ctx, t)
+}
+
+// ViewLabels mocks base method.
+func (m *MockTaskStore) ViewLabels(ctx context.Context) ([]models.Label, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ViewLabels", ctx)
+	ret0, _ := ret[0].([]models.Label)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ViewLabels indicates an expected call of ViewLabels.
+func (mr *MockTaskStoreMockRecorder) ViewLabels(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewLabels", reflect.TypeOf((*MockTaskStore)(nil).ViewLabels), ctx)
+}
+
+// ViewTasks mocks base method.
+func (m *MockTaskStore) ViewTasks(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ViewTasks", ctx, f)
+	ret0, _ := ret[0].([]models.Task)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
 // ViewTasks indicates an expected call of ViewTasks.
-func (mr *MockTaskStoreMockRecorder) ViewTasks() *gomock.Call {
+func (mr *MockTaskStoreMockRecorder) ViewTasks(ctx, f any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewTasks", reflect.TypeOf((*MockTaskStore)(nil).ViewTasks))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewTasks", reflect.TypeOf((*MockTaskStore)(nil).ViewTasks), ctx, f)
 }
 
 // MockUserService is a mock of UserService interface.
@@ -137,16 +436,167 @@ func (m *MockUserService) EXPECT() *MockUserServiceMockRecorder {
 }
 
 // GetUser mocks base method.
-func (m *MockUserService) GetUser(id int) (models.User, error) {
+func (m *MockUserService) GetUser(ctx context.Context, id int) (models.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", id)
+	ret := m.ctrl.Call(m, "GetUser", ctx, id)
 	ret0, _ := ret[0].(models.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUser indicates an expected call of GetUser.
-func (mr *MockUserServiceMockRecorder) GetUser(id any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) GetUser(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserService)(nil).GetUser), ctx, id)
+}
+
+// LockUser mocks base method.
+func (m *MockUserService) LockUser(ctx context.Context, id int) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockUser", ctx, id)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockUser indicates an expected call of LockUser.
+func (mr *MockUserServiceMockRecorder) LockUser(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockUser", reflect.TypeOf((*MockUserService)(nil).LockUser), ctx, id)
+}
+
+// MockTransactor is a mock of Transactor interface.
+type MockTransactor struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransactorMockRecorder
+	isgomock struct{}
+}
+
+// MockTransactorMockRecorder is the mock recorder for MockTransactor.
+type MockTransactorMockRecorder struct {
+	mock *MockTransactor
+}
+
+// NewMockTransactor creates a new mock instance.
+func NewMockTransactor(ctrl *gomock.Controller) *MockTransactor {
+	mock := &MockTransactor{ctrl: ctrl}
+	mock.recorder = &MockTransactorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransactor) EXPECT() *MockTransactorMockRecorder {
+	return m.recorder
+}
+
+// InTx mocks base method.
+func (m *MockTransactor) InTx(ctx context.Context, fn func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InTx indicates an expected call of InTx.
+func (mr *MockTransactorMockRecorder) InTx(ctx, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InTx", reflect.TypeOf((*MockTransactor)(nil).InTx), ctx, fn)
+}
+
+// MockAuditor is a mock of Auditor interface.
+type MockAuditor struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditorMockRecorder
+	isgomock struct{}
+}
+
+// MockAuditorMockRecorder is the mock recorder for MockAuditor.
+type MockAuditorMockRecorder struct {
+	mock *MockAuditor
+}
+
+// NewMockAuditor creates a new mock instance.
+func NewMockAuditor(ctrl *gomock.Controller) *MockAuditor {
+	mock := &MockAuditor{ctrl: ctrl}
+	mock.recorder = &MockAuditorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditor) EXPECT() *MockAuditorMockRecorder {
+	return m.recorder
+}
+
+// AppendAudit mocks base method.
+func (m *MockAuditor) AppendAudit(ctx context.Context, e models.AuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendAudit", ctx, e)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AppendAudit indicates an expected call of AppendAudit.
+func (mr *MockAuditorMockRecorder) AppendAudit(ctx, e any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendAudit", reflect.TypeOf((*MockAuditor)(nil).AppendAudit), ctx, e)
+}
+
+// MockMetrics is a mock of Metrics interface.
+type MockMetrics struct {
+	ctrl     *gomock.Controller
+	recorder *MockMetricsMockRecorder
+	isgomock struct{}
+}
+
+// MockMetricsMockRecorder is the mock recorder for MockMetrics.
+type MockMetricsMockRecorder struct {
+	mock *MockMetrics
+}
+
+// NewMockMetrics creates a new mock instance.
+func NewMockMetrics(ctrl *gomock.Controller) *MockMetrics {
+	mock := &MockMetrics{ctrl: ctrl}
+	mock.recorder = &MockMetricsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetrics) EXPECT() *MockMetricsMockRecorder {
+	return m.recorder
+}
+
+// TaskCompleted mocks base method.
+func (m *MockMetrics) TaskCompleted() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "TaskCompleted")
+}
+
+// TaskCompleted indicates an expected call of TaskCompleted.
+func (mr *MockMetricsMockRecorder) TaskCompleted() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TaskCompleted", reflect.TypeOf((*MockMetrics)(nil).TaskCompleted))
+}
+
+// TaskCreated mocks base method.
+func (m *MockMetrics) TaskCreated() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "TaskCreated")
+}
+
+// TaskCreated indicates an expected call of TaskCreated.
+func (mr *MockMetricsMockRecorder) TaskCreated() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TaskCreated", reflect.TypeOf((*MockMetrics)(nil).TaskCreated))
+}
+
+// TaskDeleted mocks base method.
+func (m *MockMetrics) TaskDeleted() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "TaskDeleted")
+}
+
+// TaskDeleted indicates an expected call of TaskDeleted.
+func (mr *MockMetricsMockRecorder) TaskDeleted() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserService)(nil).GetUser), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TaskDeleted", reflect.TypeOf((*MockMetrics)(nil).TaskDeleted))
 }
@@ -1,10 +1,13 @@
 package taskservice
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"3layerarch/models"
 	"go.uber.org/mock/gomock"
@@ -33,26 +36,35 @@ func TestCreateTask(t *testing.T) {
 			"Empty Task", models.Task{Task: "", UserID: 1},
 			false, false, nil, nil, errors.New("task cannot be empty"),
 		},
+		{
+			"Unknown Priority", models.Task{Task: "Write tests", UserID: 1, Priority: "critical"},
+			false, false, nil, nil, errors.New(`unknown priority "critical"`),
+		},
 		{
 			"User Not Found", models.Task{Task: "Write tests", UserID: 42},
-			true, false, nil, errors.New("user not found"), errors.New("user ID not found"),
+			true, false, nil, models.NotFound("user not found"), errors.New("user ID not found"),
 		},
 	}
 
 	for _, test := range tests {
 		if test.ifMock {
 			if test.userErr != nil {
-				mockUserService.EXPECT().GetUser(test.task.UserID).Return(models.User{}, test.userErr)
+				mockUserService.EXPECT().LockUser(gomock.Any(), test.task.UserID).Return(models.User{}, test.userErr)
 			} else {
-				mockUserService.EXPECT().GetUser(test.task.UserID).Return(models.User{ID: test.task.UserID}, nil)
-				mockTaskStore.EXPECT().CreateTask(test.task).Return(test.storeErr)
+				mockUserService.EXPECT().LockUser(gomock.Any(), test.task.UserID).Return(models.User{ID: test.task.UserID}, nil)
+				stored := test.task
+				stored.ID = 10
+				mockTaskStore.EXPECT().CreateTask(gomock.Any(), test.task).Return(stored, test.storeErr)
 			}
 		}
 
-		err := svc.CreateTask(test.task)
+		created, err := svc.CreateTask(adminCtx, test.task)
 		if !errorsEqual(err, test.wantErr) {
 			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
 		}
+		if test.wantErr == nil && created.ID != 10 {
+			t.Errorf("%v: expected the stored task, got %+v", test.desc, created)
+		}
 	}
 }
 
@@ -86,10 +98,10 @@ func TestGetTask(t *testing.T) {
 
 	for _, test := range tests {
 		if test.taskID > 0 {
-			mockTaskStore.EXPECT().GetTask(test.taskID).Return(test.task, test.err)
+			mockTaskStore.EXPECT().GetTask(gomock.Any(), test.taskID).Return(test.task, test.err)
 		}
 
-		got, err := svc.GetTask(test.taskID)
+		got, err := svc.GetTask(adminCtx, test.taskID)
 		if !errorsEqual(err, test.wantErr) {
 			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
 		}
@@ -99,47 +111,335 @@ func TestGetTask(t *testing.T) {
 	}
 }
 
-func TestUpdateTask(t *testing.T) {
+func TestViewTasks(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	mockTaskStore := NewMockTaskStore(ctrl)
 	svc := New(mockTaskStore, nil)
 
+	badUser, label, badLabel := -1, 4, 0
+	tasks := []models.Task{{ID: 1, Task: "Test", UserID: 1}}
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc     string
+		filter   models.TaskFilter
+		query    bool
+		storeErr error
+		want     models.TaskPage
+		wantErr  error
+	}{
+		{
+			desc: "Default Page", filter: models.TaskFilter{}, query: true,
+			want: models.TaskPage{Tasks: tasks, Total: 1, Limit: 20},
+		},
+		{
+			desc: "Sorted Second Page", filter: models.TaskFilter{Sort: "-task", Limit: 5, Offset: 5}, query: true,
+			want: models.TaskPage{Tasks: tasks, Total: 1, Limit: 5, Offset: 5},
+		},
+		{
+			desc: "Limit Too Large", filter: models.TaskFilter{Limit: 101},
+			wantErr: errors.New("limit must be between 1 and 100"),
+		},
+		{
+			desc: "Negative Offset", filter: models.TaskFilter{Offset: -1},
+			wantErr: errors.New("offset cannot be negative"),
+		},
+		{
+			desc: "Unknown Sort", filter: models.TaskFilter{Sort: "secret"},
+			wantErr: errors.New(`cannot sort by "secret"`),
+		},
+		{
+			desc: "Invalid User", filter: models.TaskFilter{UserID: &badUser},
+			wantErr: errors.New("invalid user ID"),
+		},
+		{
+			desc: "Invalid Label", filter: models.TaskFilter{LabelID: &badLabel},
+			wantErr: errors.New("invalid label ID"),
+		},
+		{
+			desc: "Unknown Priority", filter: models.TaskFilter{Priority: "someday"},
+			wantErr: errors.New(`unknown priority "someday"`),
+		},
+		{
+			desc: "Labelled Urgent By Due Date", filter: models.TaskFilter{LabelID: &label, Priority: models.PriorityUrgent, Sort: "due_at"}, query: true,
+			want: models.TaskPage{Tasks: tasks, Total: 1, Limit: 20},
+		},
+		{
+			desc: "Created Between", filter: models.TaskFilter{CreatedAfter: day, CreatedBefore: day.Add(time.Hour)}, query: true,
+			want: models.TaskPage{Tasks: tasks, Total: 1, Limit: 20},
+		},
+		{
+			desc: "Empty Created Range", filter: models.TaskFilter{CreatedAfter: day, CreatedBefore: day},
+			wantErr: errors.New("created_after must be before created_before"),
+		},
+		{
+			desc: "Empty Completed Range", filter: models.TaskFilter{CompletedAfter: day.Add(time.Hour), CompletedBefore: day},
+			wantErr: errors.New("completed_after must be before completed_before"),
+		},
+		{
+			desc: "DB Error", filter: models.TaskFilter{}, query: true, storeErr: errors.New("db error"),
+			wantErr: errors.New("db error"),
+		},
+	}
+
+	for _, test := range tests {
+		if test.query {
+			stored := test.filter
+			if stored.Limit == 0 {
+				stored.Limit = 20
+			}
+			if test.storeErr != nil {
+				mockTaskStore.EXPECT().ViewTasks(gomock.Any(), stored).Return(nil, 0, test.storeErr)
+			} else {
+				mockTaskStore.EXPECT().ViewTasks(gomock.Any(), stored).Return(tasks, 1, nil)
+			}
+		}
+
+		got, err := svc.ViewTasks(adminCtx, test.filter)
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: expected page %v, got %v", test.desc, test.want, got)
+		}
+	}
+}
+
+func TestViewUserTasks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	mockUserService := NewMockUserService(ctrl)
+	svc := New(mockTaskStore, mockUserService)
+
+	user := 3
+	tasks := []models.Task{{ID: 1, Task: "Test", UserID: 3}}
+
+	mockUserService.EXPECT().GetUser(gomock.Any(), 3).Return(models.User{ID: 3}, nil)
+	mockTaskStore.EXPECT().ViewTasks(gomock.Any(), models.TaskFilter{UserID: &user, Search: "Te", Limit: 20}).Return(tasks, 1, nil)
+
+	got, err := svc.ViewUserTasks(adminCtx, 3, models.TaskFilter{Search: "Te"})
+	if err != nil || !reflect.DeepEqual(got, models.TaskPage{Tasks: tasks, Total: 1, Limit: 20}) {
+		t.Errorf("unexpected page %v, err: %v", got, err)
+	}
+
+	mockUserService.EXPECT().GetUser(gomock.Any(), 9).Return(models.User{}, models.NotFound("user not found"))
+
+	_, err = svc.ViewUserTasks(adminCtx, 9, models.TaskFilter{})
+	if !errorsEqual(err, errors.New("user not found")) {
+		t.Errorf("expected 'user not found', got %v", err)
+	}
+}
+
+func TestUpdateTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	mockUserService := NewMockUserService(ctrl)
+	svc := New(mockTaskStore, mockUserService)
+
+	created := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	existing := models.Task{ID: 1, Task: "old", UserID: 1, Version: 3, CreatedAt: created}
+
 	tests := []struct {
 		desc      string
 		taskID    int
+		input     models.Task
 		getErr    error
+		userErr   error
+		checkUser bool
+		update    bool
 		updateErr error
+		want      models.Task
 		wantErr   error
 	}{
 		{
-			"Valid Update", 1, nil, nil, nil,
+			desc: "Valid Update", taskID: 1, input: models.Task{Task: "new", Completed: true, UserID: 1},
+			update: true, want: models.Task{ID: 1, Task: "new", Completed: true, UserID: 1, Version: 4, CreatedAt: created},
 		},
 		{
-			"Invalid ID", 0, nil, nil, errors.New("invalid task ID"),
+			desc: "Times Set By The Store", taskID: 1, input: models.Task{Task: "new", UserID: 1, CreatedAt: time.Now(), CompletedAt: time.Now()},
+			update: true, want: models.Task{ID: 1, Task: "new", UserID: 1, Version: 4, CreatedAt: created},
 		},
 		{
-			"Task Not Found", 2, sql.ErrNoRows, nil, errors.New("task not found"),
+			desc: "Move To Another User", taskID: 1, input: models.Task{Task: "new", UserID: 2},
+			checkUser: true, update: true, want: models.Task{ID: 1, Task: "new", UserID: 2, Version: 4, CreatedAt: created},
 		},
 		{
-			"Get DB Error", 3, errors.New("db error"), nil, errors.New("db error"),
+			desc: "Invalid ID", taskID: 0, input: models.Task{Task: "new", UserID: 1},
+			wantErr: errors.New("invalid task ID"),
+		},
+		{
+			desc: "Task Not Found", taskID: 2, input: models.Task{Task: "new", UserID: 1},
+			getErr: sql.ErrNoRows, wantErr: errors.New("task not found"),
+		},
+		{
+			desc: "Get DB Error", taskID: 3, input: models.Task{Task: "new", UserID: 1},
+			getErr: errors.New("db error"), wantErr: errors.New("db error"),
 		},
 		{
-			"Update DB Error", 4, nil, errors.New("update failed"), errors.New("update failed"),
+			desc: "Empty Task", taskID: 1, input: models.Task{Task: "", UserID: 1},
+			wantErr: errors.New("task cannot be empty"),
+		},
+		{
+			desc: "Missing User", taskID: 1, input: models.Task{Task: "new"},
+			wantErr: errors.New("invalid user ID"),
+		},
+		{
+			desc: "Unknown User", taskID: 1, input: models.Task{Task: "new", UserID: 42},
+			checkUser: true, userErr: models.NotFound("user not found"), wantErr: errors.New("user ID not found"),
+		},
+		{
+			desc: "Unknown Priority", taskID: 1, input: models.Task{Task: "new", UserID: 1, Priority: "HIGH"},
+			wantErr: errors.New(`unknown priority "HIGH"`),
+		},
+		{
+			desc: "Update DB Error", taskID: 4, input: models.Task{Task: "new", UserID: 1},
+			update: true, updateErr: errors.New("update failed"), wantErr: errors.New("update failed"),
 		},
 	}
 
 	for _, test := range tests {
 		if test.taskID > 0 {
-			mockTaskStore.EXPECT().GetTask(test.taskID).Return(models.Task{}, test.getErr)
-			if test.getErr == nil {
-				mockTaskStore.EXPECT().UpdateTask(test.taskID).Return(test.updateErr)
-			}
+			found := existing
+			found.ID = test.taskID
+			mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), test.taskID).Return(found, test.getErr)
+		}
+		if test.checkUser {
+			mockUserService.EXPECT().LockUser(gomock.Any(), test.input.UserID).Return(models.User{ID: test.input.UserID}, test.userErr)
+		}
+		if test.update && test.input.Completed {
+			mockTaskStore.EXPECT().Blockers(gomock.Any(), test.taskID).Return([]models.Task{}, nil)
+		}
+		if test.update {
+			stored := test.input
+			stored.ID, stored.Version = test.taskID, existing.Version
+			stored.CreatedAt, stored.CompletedAt = existing.CreatedAt, existing.CompletedAt
+			mockTaskStore.EXPECT().UpdateTask(gomock.Any(), stored).Return(test.want, test.updateErr)
+		}
+
+		got, err := svc.UpdateTask(adminCtx, test.taskID, test.input, 0)
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: expected task %v, got %v", test.desc, test.want, got)
+		}
+	}
+}
+
+func TestPatchTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	mockUserService := NewMockUserService(ctrl)
+	svc := New(mockTaskStore, mockUserService)
+
+	existing := models.Task{ID: 1, Task: "old", Completed: true, UserID: 1, Version: 3}
+	name, empty, done, reopen, owner := "renamed", "", true, false, 2
+	urgent, someday := models.PriorityUrgent, models.Priority("someday")
+
+	tests := []struct {
+		desc      string
+		taskID    int
+		patch     models.TaskPatch
+		getErr    error
+		userErr   error
+		checkUser bool
+		update    bool
+		updateErr error
+		want      models.Task
+		wantErr   error
+	}{
+		{
+			desc: "Rename Only", taskID: 1, patch: models.TaskPatch{Task: &name},
+			update: true, want: models.Task{ID: 1, Task: "renamed", Completed: true, UserID: 1, Version: 4},
+		},
+		{
+			desc: "Reopen", taskID: 1, patch: models.TaskPatch{Completed: &reopen},
+			update: true, want: models.Task{ID: 1, Task: "old", Completed: false, UserID: 1, Version: 4},
+		},
+		{
+			desc: "Reassign", taskID: 1, patch: models.TaskPatch{UserID: &owner},
+			checkUser: true, update: true, want: models.Task{ID: 1, Task: "old", Completed: true, UserID: 2, Version: 4},
+		},
+		{
+			desc: "Set Priority", taskID: 1, patch: models.TaskPatch{Priority: &urgent},
+			update: true, want: models.Task{ID: 1, Task: "old", Completed: true, UserID: 1, Priority: urgent, Version: 4},
+		},
+		{
+			desc: "Unknown Priority", taskID: 1, patch: models.TaskPatch{Priority: &someday},
+			wantErr: errors.New(`unknown priority "someday"`),
+		},
+		{
+			desc: "Invalid ID", taskID: 0, patch: models.TaskPatch{Completed: &done},
+			wantErr: errors.New("invalid task ID"),
+		},
+		{
+			desc: "Task Not Found", taskID: 2, patch: models.TaskPatch{Completed: &done},
+			getErr: sql.ErrNoRows, wantErr: errors.New("task not found"),
+		},
+		{
+			desc: "Empty Task", taskID: 1, patch: models.TaskPatch{Task: &empty},
+			wantErr: errors.New("task cannot be empty"),
+		},
+		{
+			desc: "Unknown User", taskID: 1, patch: models.TaskPatch{UserID: &owner},
+			checkUser: true, userErr: models.NotFound("user not found"), wantErr: errors.New("user ID not found"),
+		},
+		{
+			desc: "Update DB Error", taskID: 1, patch: models.TaskPatch{Completed: &done},
+			update: true, updateErr: errors.New("update failed"), wantErr: errors.New("update failed"),
+		},
+	}
+
+	for _, test := range tests {
+		if test.taskID > 0 {
+			mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), test.taskID).Return(existing, test.getErr)
+		}
+		if test.checkUser {
+			mockUserService.EXPECT().LockUser(gomock.Any(), *test.patch.UserID).Return(models.User{ID: *test.patch.UserID}, test.userErr)
+		}
+		if test.update {
+			mockTaskStore.EXPECT().UpdateTask(gomock.Any(), gomock.Any()).Return(test.want, test.updateErr)
 		}
 
-		err := svc.UpdateTask(test.taskID)
+		got, err := svc.PatchTask(adminCtx, test.taskID, test.patch, 0)
 		if !errorsEqual(err, test.wantErr) {
 			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
 		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: expected task %v, got %v", test.desc, test.want, got)
+		}
+	}
+}
+
+func TestPatchTask_DueAt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	svc := New(mockTaskStore, NewMockUserService(ctrl))
+
+	existing := models.Task{ID: 1, Task: "old", UserID: 1, Version: 3, DueAt: time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)}
+	later := time.Date(2025, 3, 2, 12, 0, 0, 1500, time.FixedZone("CET", 3600))
+	var never time.Time
+
+	tests := []struct {
+		desc string
+		due  *time.Time
+		want time.Time
+	}{
+		{"Stored In UTC To The Microsecond", &later, time.Date(2025, 3, 2, 11, 0, 0, 1000, time.UTC)},
+		{"Removed", &never, time.Time{}},
+		{"Kept", nil, existing.DueAt},
+	}
+
+	for _, test := range tests {
+		stored := existing
+		stored.DueAt = test.want
+		mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 1).Return(existing, nil)
+		mockTaskStore.EXPECT().UpdateTask(gomock.Any(), stored).Return(stored, nil)
+
+		got, err := svc.PatchTask(adminCtx, 1, models.TaskPatch{DueAt: test.due}, 0)
+		if err != nil || !got.DueAt.Equal(test.want) {
+			t.Errorf("%v: expected due date %v, got %v, err: %v", test.desc, test.want, got.DueAt, err)
+		}
 	}
 }
 
@@ -174,19 +474,362 @@ func TestDeleteTask(t *testing.T) {
 
 	for _, test := range tests {
 		if test.taskID > 0 {
-			mockTaskStore.EXPECT().GetTask(test.taskID).Return(models.Task{}, test.getErr)
+			mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), test.taskID).Return(models.Task{}, test.getErr)
 			if test.getErr == nil {
-				mockTaskStore.EXPECT().DeleteTask(test.taskID).Return(test.deleteErr)
+				mockTaskStore.EXPECT().DeleteTask(gomock.Any(), test.taskID).Return(test.deleteErr)
 			}
 		}
 
-		err := svc.DeleteTask(test.taskID)
+		err := svc.DeleteTask(adminCtx, test.taskID, 0)
 		if !errorsEqual(err, test.wantErr) {
 			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
 		}
 	}
 }
 
+func TestRestoreTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	mockUserService := NewMockUserService(ctrl)
+	svc := New(mockTaskStore, mockUserService)
+
+	deleted := models.Task{ID: 1, Task: "Binned", UserID: 2, Version: 2, DeletedAt: time.Now()}
+
+	tests := []struct {
+		desc       string
+		ctx        context.Context
+		taskID     int
+		getErr     error
+		lockErr    error
+		restoreErr error
+		want       models.Task
+		wantErr    error
+	}{
+		{
+			"Valid Restore", adminCtx, 1, nil, nil, nil, models.Task{ID: 1, Task: "Binned", UserID: 2, Version: 3}, nil,
+		},
+		{
+			"Invalid ID", adminCtx, 0, nil, nil, nil, models.Task{}, errors.New("invalid task ID"),
+		},
+		{
+			"Not In Trash", adminCtx, 1, sql.ErrNoRows, nil, nil, models.Task{}, errors.New("task not found in the trash"),
+		},
+		{
+			"Someone Else's Task", userCtx(3), 1, nil, nil, nil, models.Task{}, errors.New("task not found in the trash"),
+		},
+		{
+			"User In Trash", adminCtx, 1, nil, models.NotFound("user not found"), nil, models.Task{}, errors.New("the task's user is in the trash and must be restored first"),
+		},
+		{
+			"Restore DB Error", adminCtx, 1, nil, nil, errors.New("restore failed"), models.Task{}, errors.New("restore failed"),
+		},
+	}
+
+	for _, test := range tests {
+		if test.taskID > 0 {
+			mockTaskStore.EXPECT().GetDeletedTaskForUpdate(gomock.Any(), test.taskID).Return(deleted, test.getErr)
+			if test.getErr == nil && test.ctx == adminCtx {
+				mockUserService.EXPECT().LockUser(gomock.Any(), deleted.UserID).Return(models.User{ID: deleted.UserID}, test.lockErr)
+				if test.lockErr == nil {
+					mockTaskStore.EXPECT().RestoreTask(gomock.Any(), test.taskID).Return(test.want, test.restoreErr)
+				}
+			}
+		}
+
+		task, err := svc.RestoreTask(test.ctx, test.taskID)
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%v: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if task != test.want {
+			t.Errorf("%v: expected %+v, got %+v", test.desc, test.want, task)
+		}
+	}
+}
+
+func TestVersionMismatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	svc := New(mockTaskStore, nil)
+
+	existing := models.Task{ID: 1, Task: "old", UserID: 1, Version: 3}
+	done := true
+
+	tests := []struct {
+		desc   string
+		change func() error
+	}{
+		{
+			desc: "UpdateTask",
+			change: func() error {
+				_, err := svc.UpdateTask(adminCtx, 1, models.Task{Task: "new", UserID: 1}, 2)
+				return err
+			},
+		},
+		{
+			desc: "PatchTask",
+			change: func() error {
+				_, err := svc.PatchTask(adminCtx, 1, models.TaskPatch{Completed: &done}, 2)
+				return err
+			},
+		},
+		{
+			desc:   "DeleteTask",
+			change: func() error { return svc.DeleteTask(adminCtx, 1, 2) },
+		},
+	}
+
+	for _, test := range tests {
+		// The task is read but never written
+		mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 1).Return(existing, nil)
+
+		if err := test.change(); !errors.Is(err, models.ErrPreconditionFailed) {
+			t.Errorf("%v: expected a precondition failure, got %v", test.desc, err)
+		}
+	}
+}
+
+func TestUpdateTask_ChangedConcurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	svc := New(mockTaskStore, nil)
+
+	// Without a Tx the task can change between the read and the write
+	mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 1).Return(models.Task{ID: 1, Task: "old", UserID: 1, Version: 3}, nil)
+	mockTaskStore.EXPECT().UpdateTask(gomock.Any(), gomock.Any()).Return(models.Task{}, models.ErrPreconditionFailed)
+
+	_, err := svc.UpdateTask(adminCtx, 1, models.Task{Task: "new", UserID: 1}, 0)
+	if !errors.Is(err, models.ErrPreconditionFailed) || err.Error() != "task was changed by another request" {
+		t.Errorf("expected the conflict to be reported, got %v", err)
+	}
+}
+
+func TestUpdateTask_Retried(t *testing.T) {
+	done := true
+	tests := []struct {
+		desc   string
+		update func(svc *Service) (models.Task, error)
+	}{
+		{"update", func(svc *Service) (models.Task, error) {
+			return svc.UpdateTask(adminCtx, 1, models.Task{Task: "new", Completed: true, UserID: 1}, 0)
+		}},
+		{"patch", func(svc *Service) (models.Task, error) {
+			return svc.PatchTask(adminCtx, 1, models.TaskPatch{Completed: &done}, 0)
+		}},
+		{"complete", func(svc *Service) (models.Task, error) {
+			return svc.CompleteTask(adminCtx, 1, false, 0)
+		}},
+	}
+
+	for _, test := range tests {
+		ctrl := gomock.NewController(t)
+		mockTaskStore := NewMockTaskStore(ctrl)
+		mockTx := NewMockTransactor(ctrl)
+		svc := New(mockTaskStore, nil)
+		svc.Tx = mockTx
+
+		// The first attempt deadlocks and the Tx runs the unit of work again
+		errRetry := errors.New("deadlock found")
+		mockTx.EXPECT().InTx(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, fn func(context.Context) error) error {
+				if err := fn(ctx); !errors.Is(err, errRetry) {
+					return err
+				}
+				return fn(ctx)
+			})
+		existing := models.Task{ID: 1, Task: "new", UserID: 1, Version: 3}
+		want := models.Task{ID: 1, Task: "new", Completed: true, UserID: 1, Version: 4}
+		mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 1).Return(existing, nil).Times(2)
+		mockTaskStore.EXPECT().Blockers(gomock.Any(), 1).Return([]models.Task{}, nil).Times(2)
+		gomock.InOrder(
+			mockTaskStore.EXPECT().UpdateTask(gomock.Any(), gomock.Any()).Return(models.Task{}, errRetry),
+			mockTaskStore.EXPECT().UpdateTask(gomock.Any(), gomock.Any()).Return(want, nil),
+		)
+
+		if got, err := test.update(svc); err != nil || got != want {
+			t.Errorf("%v: expected %v, got %v, err: %v", test.desc, want, got, err)
+		}
+	}
+}
+
+func TestCreateTask_InTx(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	mockUserService := NewMockUserService(ctrl)
+	mockTx := NewMockTransactor(ctrl)
+	svc := New(mockTaskStore, mockUserService)
+	svc.Tx = mockTx
+
+	// The user is locked and the task stored in the one unit of work
+	txCtx := context.WithValue(adminCtx, txKey{}, true)
+	task := models.Task{Task: "Write tests", UserID: 1}
+	inTx := func(_ context.Context, fn func(context.Context) error) error { return fn(txCtx) }
+	gomock.InOrder(
+		mockTx.EXPECT().InTx(gomock.Any(), gomock.Any()).DoAndReturn(inTx),
+		mockUserService.EXPECT().LockUser(txCtx, 1).Return(models.User{ID: 1}, nil),
+		mockTaskStore.EXPECT().CreateTask(txCtx, task).Return(models.Task{ID: 5, Task: "Write tests", UserID: 1}, nil),
+	)
+	if got, err := svc.CreateTask(adminCtx, task); err != nil || got.ID != 5 {
+		t.Errorf("unexpected result: %+v, err: %v", got, err)
+	}
+
+	// A failed commit fails the create
+	commitErr := errors.New("commit failed")
+	gomock.InOrder(
+		mockTx.EXPECT().InTx(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, fn func(context.Context) error) error {
+				if err := inTx(ctx, fn); err != nil {
+					return err
+				}
+				return commitErr
+			}),
+		mockUserService.EXPECT().LockUser(txCtx, 1).Return(models.User{ID: 1}, nil),
+		mockTaskStore.EXPECT().CreateTask(txCtx, task).Return(models.Task{ID: 6}, nil),
+	)
+	if _, err := svc.CreateTask(adminCtx, task); !errors.Is(err, commitErr) {
+		t.Errorf("expected the commit error, got %v", err)
+	}
+}
+
+type txKey struct{}
+
+// adminCtx is the context of a request made by an admin, who may see and
+// change every task.
+var adminCtx = models.WithPrincipal(context.Background(), models.Principal{Name: "ops", Role: models.RoleAdmin})
+
+// userCtx returns the context of a request made by the user with id.
+func userCtx(id int) context.Context {
+	return models.WithPrincipal(context.Background(), models.Principal{UserID: id, Role: models.RoleUser})
+}
+
+func TestViewTasks_OwnTasksOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	svc := New(mockTaskStore, NewMockUserService(ctrl))
+
+	// The listing is scoped to the caller
+	own := 3
+	mockTaskStore.EXPECT().ViewTasks(gomock.Any(), models.TaskFilter{UserID: &own, Limit: 20}).
+		Return([]models.Task{{ID: 1, Task: "Test", UserID: 3}}, 1, nil)
+	got, err := svc.ViewTasks(userCtx(3), models.TaskFilter{})
+	if err != nil || got.Total != 1 {
+		t.Errorf("Own Tasks: unexpected page %+v, err: %v", got, err)
+	}
+
+	// Asking for someone else's tasks finds none, without a query
+	other := 4
+	got, err = svc.ViewTasks(userCtx(3), models.TaskFilter{UserID: &other})
+	want := models.TaskPage{Tasks: []models.Task{}, Limit: 20}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Other User: expected %+v, got %+v (err %v)", want, got, err)
+	}
+}
+
+func TestOtherUsersTask(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	mockUserService := NewMockUserService(ctrl)
+	svc := New(mockTaskStore, mockUserService)
+
+	theirs := models.Task{ID: 7, Task: "Theirs", UserID: 1}
+	done := true
+	other := 2
+
+	tests := []struct {
+		desc      string
+		ctx       context.Context
+		setupMock func()
+		call      func(ctx context.Context) error
+		wantErr   error
+	}{
+		{
+			desc:      "Get",
+			ctx:       userCtx(2),
+			setupMock: func() { mockTaskStore.EXPECT().GetTask(gomock.Any(), 7).Return(theirs, nil) },
+			call: func(ctx context.Context) error {
+				_, err := svc.GetTask(ctx, 7)
+				return err
+			},
+			wantErr: models.ErrNotFound,
+		},
+		{
+			desc:      "Update",
+			ctx:       userCtx(2),
+			setupMock: func() { mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 7).Return(theirs, nil) },
+			call: func(ctx context.Context) error {
+				_, err := svc.UpdateTask(ctx, 7, models.Task{Task: "Mine now", UserID: 2}, 0)
+				return err
+			},
+			wantErr: models.ErrNotFound,
+		},
+		{
+			desc:      "Patch",
+			ctx:       userCtx(2),
+			setupMock: func() { mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 7).Return(theirs, nil) },
+			call: func(ctx context.Context) error {
+				_, err := svc.PatchTask(ctx, 7, models.TaskPatch{Completed: &done}, 0)
+				return err
+			},
+			wantErr: models.ErrNotFound,
+		},
+		{
+			desc:      "Delete",
+			ctx:       userCtx(2),
+			setupMock: func() { mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 7).Return(theirs, nil) },
+			call: func(ctx context.Context) error {
+				return svc.DeleteTask(ctx, 7, 0)
+			},
+			wantErr: models.ErrNotFound,
+		},
+		{
+			desc:      "Owner Gets Task",
+			ctx:       userCtx(1),
+			setupMock: func() { mockTaskStore.EXPECT().GetTask(gomock.Any(), 7).Return(theirs, nil) },
+			call: func(ctx context.Context) error {
+				_, err := svc.GetTask(ctx, 7)
+				return err
+			},
+		},
+		{
+			desc: "Create For Another User",
+			ctx:  userCtx(1),
+			call: func(ctx context.Context) error {
+				_, err := svc.CreateTask(ctx, models.Task{Task: "For you", UserID: 2})
+				return err
+			},
+			wantErr: models.ErrForbidden,
+		},
+		{
+			desc:      "Reassign To Another User",
+			ctx:       userCtx(1),
+			setupMock: func() { mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 7).Return(theirs, nil) },
+			call: func(ctx context.Context) error {
+				_, err := svc.PatchTask(ctx, 7, models.TaskPatch{UserID: &other}, 0)
+				return err
+			},
+			wantErr: models.ErrForbidden,
+		},
+		{
+			desc: "No Principal",
+			ctx:  context.Background(),
+			call: func(ctx context.Context) error {
+				_, err := svc.GetTask(ctx, 7)
+				return err
+			},
+			wantErr: models.ErrUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		if test.setupMock != nil {
+			test.setupMock()
+		}
+		err := test.call(test.ctx)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+	}
+}
+
 func errorsEqual(err1, err2 error) bool {
 	if err1 == nil && err2 == nil {
 		return true
@@ -196,3 +839,223 @@ func errorsEqual(err1, err2 error) bool {
 	}
 	return err1.Error() == err2.Error()
 }
+
+func TestMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	mockUserService := NewMockUserService(ctrl)
+	mockMetrics := NewMockMetrics(ctrl)
+	svc := New(mockTaskStore, mockUserService)
+	svc.Metrics = mockMetrics
+
+	open := models.Task{ID: 1, Task: "Write docs", UserID: 1}
+	done := models.Task{ID: 1, Task: "Write docs", Completed: true, UserID: 1}
+
+	tests := []struct {
+		desc      string
+		setupMock func()
+		call      func() error
+	}{
+		{
+			desc: "Create",
+			setupMock: func() {
+				mockUserService.EXPECT().LockUser(gomock.Any(), 1).Return(models.User{ID: 1}, nil)
+				mockTaskStore.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Return(open, nil)
+				mockMetrics.EXPECT().TaskCreated()
+			},
+			call: func() error {
+				_, err := svc.CreateTask(adminCtx, models.Task{Task: "Write docs", UserID: 1})
+				return err
+			},
+		},
+		{
+			desc: "Complete",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 1).Return(open, nil)
+				mockTaskStore.EXPECT().Blockers(gomock.Any(), 1).Return([]models.Task{}, nil)
+				mockTaskStore.EXPECT().UpdateTask(gomock.Any(), done).Return(done, nil)
+				mockMetrics.EXPECT().TaskCompleted()
+			},
+			call: func() error {
+				completed := true
+				_, err := svc.PatchTask(adminCtx, 1, models.TaskPatch{Completed: &completed}, 0)
+				return err
+			},
+		},
+		{
+			desc: "Update a completed task",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 1).Return(done, nil)
+				mockTaskStore.EXPECT().UpdateTask(gomock.Any(), done).Return(done, nil)
+			},
+			call: func() error {
+				_, err := svc.UpdateTask(adminCtx, 1, done, 0)
+				return err
+			},
+		},
+		{
+			desc: "Delete",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 1).Return(open, nil)
+				mockTaskStore.EXPECT().DeleteTask(gomock.Any(), 1).Return(nil)
+				mockMetrics.EXPECT().TaskDeleted()
+			},
+			call: func() error { return svc.DeleteTask(adminCtx, 1, 0) },
+		},
+		{
+			desc: "Failed delete",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetTaskForUpdate(gomock.Any(), 1).Return(models.Task{}, sql.ErrNoRows)
+			},
+			call: func() error {
+				if err := svc.DeleteTask(adminCtx, 1, 0); !errors.Is(err, models.ErrNotFound) {
+					return fmt.Errorf("expected not found, got %v", err)
+				}
+				return nil
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test.setupMock()
+		if err := test.call(); err != nil {
+			t.Errorf("%v: unexpected error: %v", test.desc, err)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockTaskStore := NewMockTaskStore(ctrl)
+	mockUserService := NewMockUserService(ctrl)
+	mockTx := NewMockTransactor(ctrl)
+	mockAuditor := NewMockAuditor(ctrl)
+	svc := New(mockTaskStore, mockUserService)
+	svc.Tx = mockTx
+	svc.Audit = mockAuditor
+
+	// Entries are recorded in the unit of work of the change
+	alice := models.Principal{UserID: 1, Name: "alice", Role: models.RoleUser}
+	ctx := models.WithRequestID(models.WithPrincipal(context.Background(), alice), "req-1")
+	txCtx := context.WithValue(ctx, txKey{}, true)
+	mockTx.EXPECT().InTx(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, fn func(context.Context) error) error { return fn(txCtx) })
+
+	created, updated := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 2, 0, 0, 0, 0, time.UTC)
+	stored := models.Task{ID: 1, Task: "Write docs", UserID: 1, Version: 1, CreatedAt: created, UpdatedAt: created}
+	next := func(change func(t *models.Task)) models.Task {
+		t := stored
+		t.Version, t.UpdatedAt = 2, updated
+		change(&t)
+		return t
+	}
+	entry := func(action models.AuditAction, changes map[string]models.AuditChange) models.AuditEntry {
+		return models.AuditEntry{ActorID: 1, Actor: "alice", Action: action, Entity: models.AuditTask, EntityID: 1, Changes: changes, RequestID: "req-1"}
+	}
+
+	tests := []struct {
+		desc      string
+		setupMock func()
+		call      func() error
+		want      models.AuditEntry
+	}{
+		{
+			desc: "Create",
+			setupMock: func() {
+				mockUserService.EXPECT().LockUser(txCtx, 1).Return(models.User{ID: 1}, nil)
+				mockTaskStore.EXPECT().CreateTask(txCtx, gomock.Any()).Return(stored, nil)
+			},
+			call: func() error {
+				_, err := svc.CreateTask(ctx, models.Task{Task: "Write docs", UserID: 1})
+				return err
+			},
+			want: entry(models.AuditCreate, map[string]models.AuditChange{
+				"id": {After: 1.0}, "task": {After: "Write docs"}, "completed": {After: false}, "user_id": {After: 1.0}, "version": {After: 1.0},
+				"created_at": {After: "2025-02-01T00:00:00Z"}, "updated_at": {After: "2025-02-01T00:00:00Z"},
+			}),
+		},
+		{
+			desc: "Complete",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetTaskForUpdate(txCtx, 1).Return(stored, nil)
+				mockTaskStore.EXPECT().Blockers(txCtx, 1).Return([]models.Task{}, nil)
+				mockTaskStore.EXPECT().UpdateTask(txCtx, gomock.Any()).Return(next(func(t *models.Task) {
+					t.Completed, t.CompletedAt = true, updated
+				}), nil)
+			},
+			call: func() error {
+				done := true
+				_, err := svc.PatchTask(ctx, 1, models.TaskPatch{Completed: &done}, 0)
+				return err
+			},
+			want: entry(models.AuditComplete, map[string]models.AuditChange{
+				"completed": {Before: false, After: true}, "version": {Before: 1.0, After: 2.0},
+				"updated_at":   {Before: "2025-02-01T00:00:00Z", After: "2025-02-02T00:00:00Z"},
+				"completed_at": {After: "2025-02-02T00:00:00Z"},
+			}),
+		},
+		{
+			desc: "Update",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetTaskForUpdate(txCtx, 1).Return(stored, nil)
+				mockTaskStore.EXPECT().UpdateTask(txCtx, gomock.Any()).Return(next(func(t *models.Task) {
+					t.Task = "Write more docs"
+				}), nil)
+			},
+			call: func() error {
+				_, err := svc.UpdateTask(ctx, 1, models.Task{Task: "Write more docs", UserID: 1}, 0)
+				return err
+			},
+			want: entry(models.AuditUpdate, map[string]models.AuditChange{
+				"task": {Before: "Write docs", After: "Write more docs"}, "version": {Before: 1.0, After: 2.0},
+				"updated_at": {Before: "2025-02-01T00:00:00Z", After: "2025-02-02T00:00:00Z"},
+			}),
+		},
+		{
+			desc: "Delete",
+			setupMock: func() {
+				mockTaskStore.EXPECT().GetTaskForUpdate(txCtx, 1).Return(stored, nil)
+				mockTaskStore.EXPECT().DeleteTask(txCtx, 1).Return(nil)
+			},
+			call: func() error { return svc.DeleteTask(ctx, 1, 0) },
+			want: entry(models.AuditDelete, map[string]models.AuditChange{
+				"id": {Before: 1.0}, "task": {Before: "Write docs"}, "completed": {Before: false}, "user_id": {Before: 1.0}, "version": {Before: 1.0},
+				"created_at": {Before: "2025-02-01T00:00:00Z"}, "updated_at": {Before: "2025-02-01T00:00:00Z"},
+			}),
+		},
+		{
+			desc: "Restore",
+			setupMock: func() {
+				deleted := stored
+				deleted.DeletedAt, deleted.UpdatedAt = updated, updated
+				mockTaskStore.EXPECT().GetDeletedTaskForUpdate(txCtx, 1).Return(deleted, nil)
+				mockUserService.EXPECT().LockUser(txCtx, 1).Return(models.User{ID: 1}, nil)
+				mockTaskStore.EXPECT().RestoreTask(txCtx, 1).Return(next(func(*models.Task) {}), nil)
+			},
+			call: func() error {
+				_, err := svc.RestoreTask(ctx, 1)
+				return err
+			},
+			want: entry(models.AuditRestore, map[string]models.AuditChange{
+				"deleted_at": {Before: "2025-02-02T00:00:00Z"}, "version": {Before: 1.0, After: 2.0},
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		test.setupMock()
+		mockAuditor.EXPECT().AppendAudit(txCtx, test.want).Return(nil)
+		if err := test.call(); err != nil {
+			t.Errorf("%v: unexpected error: %v", test.desc, err)
+		}
+	}
+
+	// The change fails with its entry, so the unit of work is undone
+	auditErr := errors.New("audit log unavailable")
+	mockTaskStore.EXPECT().GetTaskForUpdate(txCtx, 1).Return(stored, nil)
+	mockTaskStore.EXPECT().DeleteTask(txCtx, 1).Return(nil)
+	mockAuditor.EXPECT().AppendAudit(txCtx, gomock.Any()).Return(auditErr)
+	if err := svc.DeleteTask(ctx, 1, 0); !errors.Is(err, auditErr) {
+		t.Errorf("expected the audit error, got %v", err)
+	}
+}
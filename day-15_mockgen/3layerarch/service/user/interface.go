@@ -1,8 +1,45 @@
 package userservice
 
-import "3layerarch/models"
+import (
+	"context"
+	"time"
+
+	"3layerarch/models"
+)
 
 type UserStore interface {
-	CreateUser(u models.User) error
-	GetUser(id int) (models.User, error)
+	CreateUser(ctx context.Context, u models.User) (models.User, error)
+	GetUser(ctx context.Context, id int) (models.User, error)
+	GetUserForUpdate(ctx context.Context, id int) (models.User, error)
+	GetUserForShare(ctx context.Context, id int) (models.User, error)
+	GetUserStats(ctx context.Context, id int) (models.User, models.UserStats, error)
+	GetUserWithTasks(ctx context.Context, id int) (models.User, []models.Task, error)
+	GetUserByName(ctx context.Context, name string) (models.User, error)
+	GetPasswordHash(ctx context.Context, name string) (models.Principal, string, error)
+	SetPasswordHash(ctx context.Context, id int, hash string) error
+	ViewUsers(ctx context.Context, f models.UserFilter) ([]models.User, int, error)
+	UpdateUser(ctx context.Context, u models.User) error
+	CountTasks(ctx context.Context, id int) (int, error)
+	LockUserTasks(ctx context.Context, id int) ([]models.Task, error)
+	DeleteUser(ctx context.Context, id, reassignTo int) error
+	GetDeletedUserForUpdate(ctx context.Context, id int) (models.User, error)
+	RestoreUser(ctx context.Context, id int) error
+	PurgeUsers(ctx context.Context, before time.Time) (int, error)
+}
+
+// Transactor runs fn as one unit of work: store calls made with the ctx it
+// passes to fn share a transaction. fn may be run again after a deadlock.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Auditor records changes in the audit log. Called in a unit of work, the
+// entry is written in its transaction.
+type Auditor interface {
+	AppendAudit(ctx context.Context, e models.AuditEntry) error
+}
+
+// Metrics counts what happens to users.
+type Metrics interface {
+	UserCreated()
 }
@@ -2,40 +2,515 @@ package userservice
 
 import (
 	"3layerarch/models"
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 //type UserStore interface {
-//	CreateUser(u models.User) error
+//	CreateUser(u models.User) (models.User, error)
 //	GetUser(id int) (models.User, error)
 //}
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// maxNameLength matches the USERS.name column.
+	maxNameLength = 100
+
+	// bcrypt ignores anything past 72 bytes of a password.
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
 type Service struct {
 	Store UserStore
+	// Tx makes multi-step changes atomic. Without one each step runs on its
+	// own.
+	Tx Transactor
+	// DeletePolicy applies to deletes that do not choose a policy.
+	DeletePolicy models.DeletePolicy
+	// Metrics, if set, counts the users created.
+	Metrics Metrics
+	// Audit, if set, records every change along with who made it.
+	// Passwords are recorded as changed, never by value.
+	Audit Auditor
 }
 
 func New(store UserStore) *Service {
-	return &Service{Store: store}
+	return &Service{Store: store, DeletePolicy: models.DeleteReject}
 }
 
-func (s *Service) CreateUser(u models.User) error {
-	if u.Name == "" {
-		return errors.New("user name cannot be empty")
+// CreateUser validates u and returns the stored user with its new ID. A
+// password, if given, is stored as a bcrypt hash.
+func (s *Service) CreateUser(ctx context.Context, u models.User) (models.User, error) {
+	name, err := s.validateName(ctx, 0, u.Name)
+	if err != nil {
+		return models.User{}, err
+	}
+	hash, err := hashPassword(u.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	var created models.User
+	err = s.inTx(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = s.Store.CreateUser(ctx, models.User{Name: name})
+		if err != nil {
+			return err
+		}
+		if hash != "" {
+			if err := s.Store.SetPasswordHash(ctx, created.ID, hash); err != nil {
+				return err
+			}
+		}
+		return s.audit(ctx, models.AuditCreate, created.ID, nil, created, hash != "")
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	if s.Metrics != nil {
+		s.Metrics.UserCreated()
 	}
-	return s.Store.CreateUser(u)
+	return created, nil
 }
 
-func (s *Service) GetUser(id int) (models.User, error) {
+// Authenticate returns the user called name, with their role, if password
+// is theirs. Unknown users, users without a password and wrong passwords
+// fail alike.
+func (s *Service) Authenticate(ctx context.Context, name, password string) (models.Principal, error) {
+	p, hash, err := s.Store.GetPasswordHash(ctx, strings.TrimSpace(name))
+	if err != nil && err != sql.ErrNoRows {
+		return models.Principal{}, err
+	}
+	if hash == "" {
+		// Take as long as a real check so that response times do not
+		// tell which names exist
+		hash = dummyHash
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil || hash == dummyHash {
+		return models.Principal{}, models.Unauthorized("invalid name or password")
+	}
+	return p, nil
+}
+
+// dummyHash is compared against when there is no real hash to check.
+var dummyHash, _ = hashPassword("not a real password")
+
+// hashPassword validates a new password and returns its bcrypt hash, or ""
+// for no password.
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return "", models.Validation(fmt.Sprintf("password must be at least %d characters", minPasswordLength))
+	}
+	if len(password) > maxPasswordLength {
+		return "", models.Validation(fmt.Sprintf("password cannot be longer than %d bytes", maxPasswordLength))
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (s *Service) GetUser(ctx context.Context, id int) (models.User, error) {
+	return s.getUser(ctx, id, "")
+}
+
+// LockUser returns the user with id like GetUser and keeps them from being
+// changed or deleted until the unit of work in ctx ends.
+func (s *Service) LockUser(ctx context.Context, id int) (models.User, error) {
+	return s.getUser(ctx, id, lockShare)
+}
+
+// Row locks taken by getUser.
+const (
+	lockShare  = "share"
+	lockUpdate = "update"
+)
+
+// getUser looks up the user with id and takes the lock asked for, if any,
+// until the unit of work in ctx ends.
+func (s *Service) getUser(ctx context.Context, id int, lock string) (models.User, error) {
 	if id <= 0 {
-		return models.User{}, errors.New("invalid user ID")
+		return models.User{}, models.Validation("invalid user ID")
+	}
+	get := s.Store.GetUser
+	switch lock {
+	case lockShare:
+		get = s.Store.GetUserForShare
+	case lockUpdate:
+		get = s.Store.GetUserForUpdate
 	}
-	u, err := s.Store.GetUser(id)
+	u, err := get(ctx, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.User{}, errors.New("user not found")
+			return models.User{}, models.NotFound("user not found")
 		}
 		return models.User{}, err
 	}
 	return u, nil
 }
+
+// GetUserDetail returns the user with id along with the related data inc
+// asks for. Each combination is a single store query. Only the user
+// themselves, or an admin, may see their tasks and stats.
+func (s *Service) GetUserDetail(ctx context.Context, id int, inc models.UserInclude) (models.UserDetail, error) {
+	if id <= 0 {
+		return models.UserDetail{}, models.Validation("invalid user ID")
+	}
+	if inc.Tasks || inc.Stats {
+		if err := authorize(ctx, id); err != nil {
+			return models.UserDetail{}, err
+		}
+	}
+
+	var d models.UserDetail
+	var err error
+	switch {
+	case inc.Tasks:
+		d.User, d.Tasks, err = s.Store.GetUserWithTasks(ctx, id)
+		if err == nil && inc.Stats {
+			d.Stats = &models.UserStats{}
+			for _, t := range d.Tasks {
+				if t.Completed {
+					d.Stats.Completed++
+				} else {
+					d.Stats.Open++
+				}
+			}
+		}
+	case inc.Stats:
+		var st models.UserStats
+		d.User, st, err = s.Store.GetUserStats(ctx, id)
+		d.Stats = &st
+	default:
+		d.User, err = s.Store.GetUser(ctx, id)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.UserDetail{}, models.NotFound("user not found")
+		}
+		return models.UserDetail{}, err
+	}
+	return d, nil
+}
+
+// ViewUsers returns the page of users selected by f, which lists the trash
+// if f.Deleted is set. A zero limit means the default page size. Only admins
+// list users.
+func (s *Service) ViewUsers(ctx context.Context, f models.UserFilter) (models.UserPage, error) {
+	p, err := caller(ctx)
+	if err != nil {
+		return models.UserPage{}, err
+	}
+	if p.Role != models.RoleAdmin {
+		return models.UserPage{}, models.Forbidden("only admins can list users")
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultPageSize
+	}
+	if f.Limit < 0 || f.Limit > maxPageSize {
+		return models.UserPage{}, models.Validation(fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+	}
+	if f.Offset < 0 {
+		return models.UserPage{}, models.Validation("offset cannot be negative")
+	}
+
+	users, total, err := s.Store.ViewUsers(ctx, f)
+	if err != nil {
+		return models.UserPage{}, err
+	}
+	return models.UserPage{Users: users, Total: total, Limit: f.Limit, Offset: f.Offset}, nil
+}
+
+// UpdateUser replaces every field of the user with id and returns the result.
+// The password is the exception: it is kept unless u has a new one. Only the
+// user themselves, or an admin, may update them.
+func (s *Service) UpdateUser(ctx context.Context, id int, u models.User) (models.User, error) {
+	if err := authorize(ctx, id); err != nil {
+		return models.User{}, err
+	}
+	var updated models.User
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		existing, err := s.getUser(ctx, id, lockUpdate)
+		if err != nil {
+			return err
+		}
+		updated, err = s.updateUser(ctx, existing, u)
+		return err
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
+
+// updateUser replaces existing, which the unit of work of ctx has locked,
+// with u.
+func (s *Service) updateUser(ctx context.Context, existing, u models.User) (models.User, error) {
+	name, err := s.validateName(ctx, existing.ID, u.Name)
+	if err != nil {
+		return models.User{}, err
+	}
+	hash, err := hashPassword(u.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	u = models.User{ID: existing.ID, Name: name}
+	if err := s.Store.UpdateUser(ctx, u); err != nil {
+		return models.User{}, err
+	}
+	if hash != "" {
+		if err := s.Store.SetPasswordHash(ctx, u.ID, hash); err != nil {
+			return models.User{}, err
+		}
+	}
+	return u, s.audit(ctx, models.AuditUpdate, u.ID, existing, u, hash != "")
+}
+
+// PatchUser applies a merge-patch to the user with id and returns the result.
+// Only the user themselves, or an admin, may change their name or password.
+func (s *Service) PatchUser(ctx context.Context, id int, p models.UserPatch) (models.User, error) {
+	if err := authorize(ctx, id); err != nil {
+		return models.User{}, err
+	}
+	var updated models.User
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		existing, err := s.getUser(ctx, id, lockUpdate)
+		if err != nil {
+			return err
+		}
+		if p.Name == nil && p.Password == nil {
+			updated = existing
+			return nil
+		}
+		update := models.User{Name: existing.Name}
+		if p.Name != nil {
+			update.Name = *p.Name
+		}
+		if p.Password != nil {
+			if *p.Password == "" {
+				return models.Validation("password cannot be empty")
+			}
+			update.Password = *p.Password
+		}
+		updated, err = s.updateUser(ctx, existing, update)
+		return err
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
+
+// validateName checks a new name for the user with id (0 for a new user)
+// and returns it trimmed. Names must be unique, and users in the trash keep
+// theirs until they are purged.
+func (s *Service) validateName(ctx context.Context, id int, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", models.Validation("user name cannot be empty")
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return "", models.Validation(fmt.Sprintf("user name cannot be longer than %d characters", maxNameLength))
+	}
+	other, err := s.Store.GetUserByName(ctx, name)
+	switch {
+	case err == sql.ErrNoRows:
+		return name, nil
+	case err != nil:
+		return "", err
+	case other.ID != id && !other.DeletedAt.IsZero():
+		return "", models.Conflict("user name taken by a user in the trash")
+	case other.ID != id:
+		return "", models.Conflict("user name already taken")
+	}
+	return name, nil
+}
+
+// DeleteUser moves the user with id to the trash. What happens to their
+// tasks depends on d.Policy, or on s.DeletePolicy when d does not set one:
+// cascading moves them to the trash with the user. The user is locked
+// first, so no task can be given to them while they are deleted. The audit
+// log records the user's delete and, when their tasks are reassigned, the
+// move of each task as an update of it.
+func (s *Service) DeleteUser(ctx context.Context, id int, d models.UserDelete) error {
+	if err := authorize(ctx, id); err != nil {
+		return err
+	}
+	return s.inTx(ctx, func(ctx context.Context) error {
+		return s.deleteUser(ctx, id, d)
+	})
+}
+
+func (s *Service) deleteUser(ctx context.Context, id int, d models.UserDelete) error {
+	existing, err := s.getUser(ctx, id, lockUpdate)
+	if err != nil {
+		return err
+	}
+	policy := d.Policy
+	if policy == "" {
+		policy = s.DeletePolicy
+	}
+
+	switch policy {
+	case models.DeleteReject, "":
+		n, err := s.Store.CountTasks(ctx, id)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return models.Conflict(fmt.Sprintf("user still owns %d tasks", n))
+		}
+	case models.DeleteCascade:
+	case models.DeleteReassign:
+		if d.ReassignTo <= 0 {
+			return models.Validation("reassign_to is required to reassign tasks")
+		}
+		if d.ReassignTo == id {
+			return models.Validation("cannot reassign tasks to the user being deleted")
+		}
+		if p, err := caller(ctx); err != nil || !p.CanAccess(d.ReassignTo) {
+			return models.Forbidden("cannot reassign tasks to another user")
+		}
+		if _, err := s.LockUser(ctx, d.ReassignTo); err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				return models.Validation("reassign_to user not found")
+			}
+			return err
+		}
+	default:
+		return models.Validation(fmt.Sprintf("unknown delete policy %q", policy))
+	}
+
+	// Only reassigning keeps the tasks, so only it passes ReassignTo on
+	reassignTo := 0
+	var moved []models.Task
+	if policy == models.DeleteReassign {
+		reassignTo = d.ReassignTo
+		if s.Audit != nil {
+			if moved, err = s.Store.LockUserTasks(ctx, id); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.Store.DeleteUser(ctx, id, reassignTo); err != nil {
+		return err
+	}
+	if err := s.auditMoved(ctx, moved, reassignTo); err != nil {
+		return err
+	}
+	return s.audit(ctx, models.AuditDelete, id, existing, nil, false)
+}
+
+// auditMoved records the move of each of tasks to the user with id as an
+// update of the task.
+func (s *Service) auditMoved(ctx context.Context, tasks []models.Task, id int) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	owned, err := s.Store.LockUserTasks(ctx, id)
+	if err != nil {
+		return err
+	}
+	after := make(map[int]models.Task, len(owned))
+	for _, t := range owned {
+		after[t.ID] = t
+	}
+	for _, t := range tasks {
+		e := models.NewAuditEntry(ctx, models.AuditUpdate, models.AuditTask, t.ID, t, after[t.ID])
+		if err := s.Audit.AppendAudit(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreUser takes the user with id out of the trash, together with the
+// tasks that were deleted with them, and returns the user.
+func (s *Service) RestoreUser(ctx context.Context, id int) (models.User, error) {
+	if err := authorize(ctx, id); err != nil {
+		return models.User{}, err
+	}
+	var u models.User
+	err := s.inTx(ctx, func(ctx context.Context) error {
+		var err error
+		u, err = s.Store.GetDeletedUserForUpdate(ctx, id)
+		if err == nil {
+			err = s.Store.RestoreUser(ctx, id)
+		}
+		if err == sql.ErrNoRows {
+			return models.NotFound("user not found in the trash")
+		}
+		if err != nil {
+			return err
+		}
+		deleted := u
+		u.DeletedAt = time.Time{}
+		return s.audit(ctx, models.AuditRestore, id, deleted, u, false)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return u, nil
+}
+
+// audit records a change to the user with id, from before to after, in the
+// unit of work of ctx. A new password is recorded as redacted. It does
+// nothing without an Audit.
+func (s *Service) audit(ctx context.Context, action models.AuditAction, id int, before, after any, password bool) error {
+	if s.Audit == nil {
+		return nil
+	}
+	e := models.NewAuditEntry(ctx, action, models.AuditUser, id, before, after)
+	if password {
+		e.Changes["password"] = models.AuditChange{After: models.Redacted}
+	}
+	return s.Audit.AppendAudit(ctx, e)
+}
+
+// caller returns the principal of the request ctx belongs to.
+func caller(ctx context.Context) (models.Principal, error) {
+	p, ok := models.PrincipalFrom(ctx)
+	if !ok {
+		return models.Principal{}, models.Unauthorized("authentication required")
+	}
+	return p, nil
+}
+
+// authorize checks that the caller may change the user with id: admins may
+// change anyone, other users only themselves. Like tasks, other users are
+// not found rather than forbidden, so as not to tell which IDs exist.
+func authorize(ctx context.Context, id int) error {
+	p, err := caller(ctx)
+	if err != nil {
+		return err
+	}
+	if id <= 0 {
+		return models.Validation("invalid user ID")
+	}
+	if !p.CanAccess(id) {
+		return models.NotFound("user not found")
+	}
+	return nil
+}
+
+// inTx runs fn as one unit of work, or simply calls it without a Tx.
+func (s *Service) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.Tx == nil {
+		return fn(ctx)
+	}
+	return s.Tx.InTx(ctx, fn)
+}
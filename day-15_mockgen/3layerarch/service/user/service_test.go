@@ -1,19 +1,25 @@
 package userservice
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"3layerarch/models"
 	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestCreateUser(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	mockStore := NewMockUserStore(ctrl)
+	mockMetrics := NewMockMetrics(ctrl)
 	svc := New(mockStore)
+	svc.Metrics = mockMetrics
 
 	tests := []struct {
 		desc      string
@@ -25,25 +31,165 @@ func TestCreateUser(t *testing.T) {
 			desc:  "Success",
 			input: models.User{Name: "Alice"},
 			setupMock: func() {
-				mockStore.EXPECT().CreateUser(models.User{Name: "Alice"}).Return(nil)
+				mockStore.EXPECT().GetUserByName(gomock.Any(), "Alice").Return(models.User{}, sql.ErrNoRows)
+				mockStore.EXPECT().CreateUser(gomock.Any(), models.User{Name: "Alice"}).Return(models.User{ID: 1, Name: "Alice"}, nil)
+				mockMetrics.EXPECT().UserCreated()
 			},
 			wantErr: nil,
 		},
 		{
 			desc:    "Empty Name",
-			input:   models.User{Name: ""},
+			input:   models.User{Name: " "},
 			wantErr: errors.New("user name cannot be empty"),
 		},
+		{
+			desc:    "Name Too Long",
+			input:   models.User{Name: strings.Repeat("a", 101)},
+			wantErr: errors.New("user name cannot be longer than 100 characters"),
+		},
+		{
+			desc:  "Duplicate Name",
+			input: models.User{Name: "Bob"},
+			setupMock: func() {
+				mockStore.EXPECT().GetUserByName(gomock.Any(), "Bob").Return(models.User{ID: 2, Name: "Bob"}, nil)
+			},
+			wantErr: errors.New("user name already taken"),
+		},
+		{
+			desc:  "Name Of User In Trash",
+			input: models.User{Name: "Carol"},
+			setupMock: func() {
+				mockStore.EXPECT().GetUserByName(gomock.Any(), "Carol").Return(models.User{ID: 3, Name: "Carol", DeletedAt: time.Now()}, nil)
+			},
+			wantErr: errors.New("user name taken by a user in the trash"),
+		},
 	}
 
 	for _, test := range tests {
 		if test.setupMock != nil {
 			test.setupMock()
 		}
-		err := svc.CreateUser(test.input)
+		created, err := svc.CreateUser(context.Background(), test.input)
 		if !errorsEqual(err, test.wantErr) {
 			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
 		}
+		if test.wantErr == nil && created.ID != 1 {
+			t.Errorf("%s: expected the stored user, got %+v", test.desc, created)
+		}
+	}
+}
+
+// isPassword matches the bcrypt hash of a password.
+type isPassword string
+
+func (p isPassword) Matches(x any) bool {
+	hash, ok := x.(string)
+	return ok && bcrypt.CompareHashAndPassword([]byte(hash), []byte(p)) == nil
+}
+
+func (p isPassword) String() string { return "is the hash of " + string(p) }
+
+func TestCreateUser_Password(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	mockTx := NewMockTransactor(ctrl)
+	svc := New(mockStore)
+	svc.Tx = mockTx
+
+	// The user and their password hash are stored together
+	txCtx := context.WithValue(context.Background(), txKey{}, true)
+	gomock.InOrder(
+		mockStore.EXPECT().GetUserByName(gomock.Any(), "Alice").Return(models.User{}, sql.ErrNoRows),
+		mockTx.EXPECT().InTx(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, fn func(context.Context) error) error { return fn(txCtx) }),
+		mockStore.EXPECT().CreateUser(txCtx, models.User{Name: "Alice"}).Return(models.User{ID: 4, Name: "Alice"}, nil),
+		mockStore.EXPECT().SetPasswordHash(txCtx, 4, isPassword("correct horse")).Return(nil),
+	)
+
+	got, err := svc.CreateUser(context.Background(), models.User{Name: "Alice", Password: "correct horse"})
+	if err != nil || got != (models.User{ID: 4, Name: "Alice"}) {
+		t.Errorf("expected the stored user without a password, got %+v (err %v)", got, err)
+	}
+
+	for _, pw := range []string{"short", strings.Repeat("a", 73)} {
+		mockStore.EXPECT().GetUserByName(gomock.Any(), "Bob").Return(models.User{}, sql.ErrNoRows)
+		if _, err := svc.CreateUser(context.Background(), models.User{Name: "Bob", Password: pw}); !errors.Is(err, models.ErrValidation) {
+			t.Errorf("%d byte password: expected a validation error, got %v", len(pw), err)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	svc := New(mockStore)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	alice := models.Principal{UserID: 1, Name: "Alice", Role: models.RoleAdmin}
+
+	tests := []struct {
+		desc      string
+		name      string
+		password  string
+		setupMock func()
+		want      models.Principal
+		wantErr   error
+	}{
+		{
+			desc:     "Success",
+			name:     " Alice ",
+			password: "correct horse",
+			setupMock: func() {
+				mockStore.EXPECT().GetPasswordHash(gomock.Any(), "Alice").Return(alice, string(hash), nil)
+			},
+			want: alice,
+		},
+		{
+			desc:     "Wrong Password",
+			name:     "Alice",
+			password: "wrong horse",
+			setupMock: func() {
+				mockStore.EXPECT().GetPasswordHash(gomock.Any(), "Alice").Return(alice, string(hash), nil)
+			},
+			wantErr: errors.New("invalid name or password"),
+		},
+		{
+			desc:     "No Password Set",
+			name:     "Bob",
+			password: "not a real password",
+			setupMock: func() {
+				mockStore.EXPECT().GetPasswordHash(gomock.Any(), "Bob").Return(models.Principal{UserID: 2, Name: "Bob", Role: models.RoleUser}, "", nil)
+			},
+			wantErr: errors.New("invalid name or password"),
+		},
+		{
+			desc:     "Unknown User",
+			name:     "Nobody",
+			password: "correct horse",
+			setupMock: func() {
+				mockStore.EXPECT().GetPasswordHash(gomock.Any(), "Nobody").Return(models.Principal{}, "", sql.ErrNoRows)
+			},
+			wantErr: errors.New("invalid name or password"),
+		},
+		{
+			desc:     "Store Error",
+			name:     "Alice",
+			password: "correct horse",
+			setupMock: func() {
+				mockStore.EXPECT().GetPasswordHash(gomock.Any(), "Alice").Return(models.Principal{}, "", errors.New("db down"))
+			},
+			wantErr: errors.New("db down"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tt.setupMock()
+			got, err := svc.Authenticate(context.Background(), tt.name, tt.password)
+			if !errorsEqual(err, tt.wantErr) || got != tt.want {
+				t.Errorf("expected %+v (err %v), got %+v (err %v)", tt.want, tt.wantErr, got, err)
+			}
+		})
 	}
 }
 
@@ -63,7 +209,7 @@ func TestGetUser(t *testing.T) {
 			desc:    "Success",
 			inputID: 1,
 			setup: func() {
-				mockStore.EXPECT().GetUser(1).Return(models.User{ID: 1, Name: "Alice"}, nil)
+				mockStore.EXPECT().GetUser(gomock.Any(), 1).Return(models.User{ID: 1, Name: "Alice"}, nil)
 			},
 			want: models.User{ID: 1, Name: "Alice"},
 		},
@@ -76,7 +222,7 @@ func TestGetUser(t *testing.T) {
 			desc:    "User Not Found",
 			inputID: 2,
 			setup: func() {
-				mockStore.EXPECT().GetUser(2).Return(models.User{}, sql.ErrNoRows)
+				mockStore.EXPECT().GetUser(gomock.Any(), 2).Return(models.User{}, sql.ErrNoRows)
 			},
 			wantErr: errors.New("user not found"),
 		},
@@ -84,7 +230,7 @@ func TestGetUser(t *testing.T) {
 			desc:    "DB Error",
 			inputID: 3,
 			setup: func() {
-				mockStore.EXPECT().GetUser(3).Return(models.User{}, errors.New("some db error"))
+				mockStore.EXPECT().GetUser(gomock.Any(), 3).Return(models.User{}, errors.New("some db error"))
 			},
 			wantErr: errors.New("some db error"),
 		},
@@ -95,18 +241,597 @@ func TestGetUser(t *testing.T) {
 			test.setup()
 		}
 
-		got, err := svc.GetUser(test.inputID)
+		got, err := svc.GetUser(context.Background(), test.inputID)
+
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+
+		if err == nil && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected user %+v, got %+v", test.desc, test.want, got)
+		}
+	}
+}
+
+func TestGetUserDetail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	svc := New(mockStore)
+
+	alice := models.User{ID: 1, Name: "Alice"}
+	tasks := []models.Task{{ID: 1, UserID: 1}, {ID: 2, Completed: true, UserID: 1}}
+
+	tests := []struct {
+		desc    string
+		ctx     context.Context
+		id      int
+		inc     models.UserInclude
+		setup   func()
+		want    models.UserDetail
+		wantErr error
+	}{
+		{
+			desc:  "No Includes",
+			id:    1,
+			setup: func() { mockStore.EXPECT().GetUser(gomock.Any(), 1).Return(alice, nil) },
+			want:  models.UserDetail{User: alice},
+		},
+		{
+			desc: "Stats",
+			id:   1,
+			inc:  models.UserInclude{Stats: true},
+			setup: func() {
+				mockStore.EXPECT().GetUserStats(gomock.Any(), 1).Return(alice, models.UserStats{Open: 3, Completed: 4}, nil)
+			},
+			want: models.UserDetail{User: alice, Stats: &models.UserStats{Open: 3, Completed: 4}},
+		},
+		{
+			desc:  "Tasks And Stats",
+			id:    1,
+			inc:   models.UserInclude{Tasks: true, Stats: true},
+			setup: func() { mockStore.EXPECT().GetUserWithTasks(gomock.Any(), 1).Return(alice, tasks, nil) },
+			want:  models.UserDetail{User: alice, Tasks: tasks, Stats: &models.UserStats{Open: 1, Completed: 1}},
+		},
+		{
+			desc:    "Invalid ID",
+			id:      0,
+			inc:     models.UserInclude{Tasks: true},
+			wantErr: errors.New("invalid user ID"),
+		},
+		{
+			desc:    "User Not Found",
+			id:      2,
+			inc:     models.UserInclude{Tasks: true},
+			setup:   func() { mockStore.EXPECT().GetUserWithTasks(gomock.Any(), 2).Return(models.User{}, nil, sql.ErrNoRows) },
+			wantErr: errors.New("user not found"),
+		},
+		{
+			desc:  "Another User",
+			ctx:   userCtx(2),
+			id:    1,
+			setup: func() { mockStore.EXPECT().GetUser(gomock.Any(), 1).Return(alice, nil) },
+			want:  models.UserDetail{User: alice},
+		},
+		{
+			desc:    "Another User's Tasks",
+			ctx:     userCtx(2),
+			id:      1,
+			inc:     models.UserInclude{Tasks: true},
+			wantErr: errors.New("user not found"),
+		},
+		{
+			desc:    "Another User's Stats",
+			ctx:     userCtx(2),
+			id:      1,
+			inc:     models.UserInclude{Stats: true},
+			wantErr: errors.New("user not found"),
+		},
+	}
+
+	for _, test := range tests {
+		if test.setup != nil {
+			test.setup()
+		}
+
+		ctx := test.ctx
+		if ctx == nil {
+			ctx = adminCtx
+		}
+		got, err := svc.GetUserDetail(ctx, test.id, test.inc)
 
 		if !errorsEqual(err, test.wantErr) {
 			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
 		}
 
 		if err == nil && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected detail %+v, got %+v", test.desc, test.want, got)
+		}
+	}
+}
+
+func TestViewUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	svc := New(mockStore)
+
+	tests := []struct {
+		desc     string
+		filter   models.UserFilter
+		query    bool
+		storeErr error
+		want     models.UserPage
+		wantErr  error
+	}{
+		{
+			desc: "Default Page", filter: models.UserFilter{}, query: true,
+			want: models.UserPage{Users: []models.User{{ID: 1, Name: "Alice"}}, Total: 1, Limit: 20},
+		},
+		{
+			desc: "Limit Too Large", filter: models.UserFilter{Limit: 101},
+			wantErr: errors.New("limit must be between 1 and 100"),
+		},
+		{
+			desc: "Negative Offset", filter: models.UserFilter{Offset: -1},
+			wantErr: errors.New("offset cannot be negative"),
+		},
+		{
+			desc: "DB Error", filter: models.UserFilter{}, query: true, storeErr: errors.New("db error"),
+			wantErr: errors.New("db error"),
+		},
+	}
+
+	for _, test := range tests {
+		if test.query {
+			mockStore.EXPECT().ViewUsers(gomock.Any(), models.UserFilter{Limit: 20}).
+				Return(test.want.Users, test.want.Total, test.storeErr)
+		}
+
+		got, err := svc.ViewUsers(adminCtx, test.filter)
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if err == nil && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected page %+v, got %+v", test.desc, test.want, got)
+		}
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	svc := New(mockStore)
+
+	alice := models.User{ID: 1, Name: "Alice"}
+
+	tests := []struct {
+		desc    string
+		id      int
+		input   models.User
+		setup   func()
+		want    models.User
+		wantErr error
+	}{
+		{
+			desc: "Rename", id: 1, input: models.User{ID: 9, Name: "Alicia"},
+			setup: func() {
+				mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 1).Return(alice, nil)
+				mockStore.EXPECT().GetUserByName(gomock.Any(), "Alicia").Return(models.User{}, sql.ErrNoRows)
+				mockStore.EXPECT().UpdateUser(gomock.Any(), models.User{ID: 1, Name: "Alicia"}).Return(nil)
+			},
+			want: models.User{ID: 1, Name: "Alicia"},
+		},
+		{
+			desc: "Keep Own Name", id: 1, input: models.User{Name: "Alice"},
+			setup: func() {
+				mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 1).Return(alice, nil)
+				mockStore.EXPECT().GetUserByName(gomock.Any(), "Alice").Return(alice, nil)
+				mockStore.EXPECT().UpdateUser(gomock.Any(), alice).Return(nil)
+			},
+			want: alice,
+		},
+		{
+			desc: "Name Taken", id: 1, input: models.User{Name: "Bob"},
+			setup: func() {
+				mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 1).Return(alice, nil)
+				mockStore.EXPECT().GetUserByName(gomock.Any(), "Bob").Return(models.User{ID: 2, Name: "Bob"}, nil)
+			},
+			wantErr: errors.New("user name already taken"),
+		},
+		{
+			desc: "Not Found", id: 7, input: models.User{Name: "Zed"},
+			setup: func() {
+				mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 7).Return(models.User{}, sql.ErrNoRows)
+			},
+			wantErr: errors.New("user not found"),
+		},
+	}
+
+	for _, test := range tests {
+		test.setup()
+
+		got, err := svc.UpdateUser(adminCtx, test.id, test.input)
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if err == nil && got != test.want {
 			t.Errorf("%s: expected user %+v, got %+v", test.desc, test.want, got)
 		}
 	}
 }
 
+func TestUpdateUser_Retried(t *testing.T) {
+	name := "Alicia"
+	tests := []struct {
+		desc   string
+		update func(svc *Service) (models.User, error)
+	}{
+		{"update", func(svc *Service) (models.User, error) {
+			return svc.UpdateUser(adminCtx, 1, models.User{Name: "Alicia"})
+		}},
+		{"patch", func(svc *Service) (models.User, error) {
+			return svc.PatchUser(adminCtx, 1, models.UserPatch{Name: &name})
+		}},
+	}
+
+	for _, test := range tests {
+		ctrl := gomock.NewController(t)
+		mockStore := NewMockUserStore(ctrl)
+		mockTx := NewMockTransactor(ctrl)
+		svc := New(mockStore)
+		svc.Tx = mockTx
+
+		// The first attempt deadlocks and the Tx runs the unit of work again
+		errRetry := errors.New("deadlock found")
+		mockTx.EXPECT().InTx(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, fn func(context.Context) error) error {
+				if err := fn(ctx); !errors.Is(err, errRetry) {
+					return err
+				}
+				return fn(ctx)
+			})
+		want := models.User{ID: 1, Name: "Alicia"}
+		mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 1).Return(models.User{ID: 1, Name: "Alice"}, nil).Times(2)
+		mockStore.EXPECT().GetUserByName(gomock.Any(), "Alicia").Return(models.User{}, sql.ErrNoRows).Times(2)
+		gomock.InOrder(
+			mockStore.EXPECT().UpdateUser(gomock.Any(), want).Return(errRetry),
+			mockStore.EXPECT().UpdateUser(gomock.Any(), want).Return(nil),
+		)
+
+		if got, err := test.update(svc); err != nil || got != want {
+			t.Errorf("%v: expected %+v, got %+v, err: %v", test.desc, want, got, err)
+		}
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	svc := New(mockStore)
+
+	alice := models.User{ID: 1, Name: "Alice"}
+	name := "Alicia"
+
+	// Empty patch
+	mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 1).Return(alice, nil)
+	got, err := svc.PatchUser(adminCtx, 1, models.UserPatch{})
+	if err != nil || got != alice {
+		t.Errorf("Empty Patch: expected %+v, got %+v (err %v)", alice, got, err)
+	}
+
+	// Rename
+	mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 1).Return(alice, nil)
+	mockStore.EXPECT().GetUserByName(gomock.Any(), name).Return(models.User{}, sql.ErrNoRows)
+	mockStore.EXPECT().UpdateUser(gomock.Any(), models.User{ID: 1, Name: name}).Return(nil)
+	got, err = svc.PatchUser(adminCtx, 1, models.UserPatch{Name: &name})
+	if err != nil || got.Name != name {
+		t.Errorf("Rename: expected name %s, got %+v (err %v)", name, got, err)
+	}
+
+	// New password
+	pw := "correct horse"
+	mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 1).Return(alice, nil)
+	mockStore.EXPECT().GetUserByName(gomock.Any(), "Alice").Return(alice, nil)
+	mockStore.EXPECT().UpdateUser(gomock.Any(), alice).Return(nil)
+	mockStore.EXPECT().SetPasswordHash(gomock.Any(), 1, isPassword(pw)).Return(nil)
+	got, err = svc.PatchUser(adminCtx, 1, models.UserPatch{Password: &pw})
+	if err != nil || got != alice {
+		t.Errorf("New Password: expected %+v, got %+v (err %v)", alice, got, err)
+	}
+
+	// Empty password
+	empty := ""
+	mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 1).Return(alice, nil)
+	if _, err := svc.PatchUser(adminCtx, 1, models.UserPatch{Password: &empty}); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("Empty Password: expected a validation error, got %v", err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	svc := New(mockStore)
+
+	alice := models.User{ID: 1, Name: "Alice"}
+
+	tests := []struct {
+		desc    string
+		input   models.UserDelete
+		setup   func()
+		wantErr error
+	}{
+		{
+			desc: "Reject Without Tasks",
+			setup: func() {
+				mockStore.EXPECT().CountTasks(gomock.Any(), 1).Return(0, nil)
+				mockStore.EXPECT().DeleteUser(gomock.Any(), 1, 0).Return(nil)
+			},
+		},
+		{
+			desc: "Reject With Tasks",
+			setup: func() {
+				mockStore.EXPECT().CountTasks(gomock.Any(), 1).Return(3, nil)
+			},
+			wantErr: errors.New("user still owns 3 tasks"),
+		},
+		{
+			desc:  "Cascade",
+			input: models.UserDelete{Policy: models.DeleteCascade},
+			setup: func() {
+				mockStore.EXPECT().DeleteUser(gomock.Any(), 1, 0).Return(nil)
+			},
+		},
+		{
+			desc:  "Reassign",
+			input: models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 2},
+			setup: func() {
+				mockStore.EXPECT().GetUserForShare(gomock.Any(), 2).Return(models.User{ID: 2, Name: "Bob"}, nil)
+				mockStore.EXPECT().DeleteUser(gomock.Any(), 1, 2).Return(nil)
+			},
+		},
+		{
+			desc:    "Reassign Without Target",
+			input:   models.UserDelete{Policy: models.DeleteReassign},
+			wantErr: errors.New("reassign_to is required to reassign tasks"),
+		},
+		{
+			desc:    "Reassign To Self",
+			input:   models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 1},
+			wantErr: errors.New("cannot reassign tasks to the user being deleted"),
+		},
+		{
+			desc:  "Reassign To Unknown User",
+			input: models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 9},
+			setup: func() {
+				mockStore.EXPECT().GetUserForShare(gomock.Any(), 9).Return(models.User{}, sql.ErrNoRows)
+			},
+			wantErr: errors.New("reassign_to user not found"),
+		},
+		{
+			desc:    "Unknown Policy",
+			input:   models.UserDelete{Policy: "archive"},
+			wantErr: errors.New(`unknown delete policy "archive"`),
+		},
+	}
+
+	for _, test := range tests {
+		mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 1).Return(alice, nil)
+		if test.setup != nil {
+			test.setup()
+		}
+
+		err := svc.DeleteUser(adminCtx, 1, test.input)
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+	}
+
+	// Unknown user
+	mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 5).Return(models.User{}, sql.ErrNoRows)
+	if err := svc.DeleteUser(adminCtx, 5, models.UserDelete{}); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("Unknown User: expected not found, got %v", err)
+	}
+}
+
+func TestDeleteUser_InTx(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	mockTx := NewMockTransactor(ctrl)
+	svc := New(mockStore)
+	svc.Tx = mockTx
+
+	// The lock, the count and the delete all run in the one unit of work
+	txCtx := context.WithValue(context.Background(), txKey{}, true)
+	gomock.InOrder(
+		mockTx.EXPECT().InTx(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, fn func(context.Context) error) error { return fn(txCtx) }),
+		mockStore.EXPECT().GetUserForUpdate(txCtx, 1).Return(models.User{ID: 1, Name: "Alice"}, nil),
+		mockStore.EXPECT().CountTasks(txCtx, 1).Return(0, nil),
+		mockStore.EXPECT().DeleteUser(txCtx, 1, 0).Return(nil),
+	)
+
+	if err := svc.DeleteUser(adminCtx, 1, models.UserDelete{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRestoreUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	svc := New(mockStore)
+
+	tests := []struct {
+		desc      string
+		id        int
+		setupMock func()
+		want      models.User
+		wantErr   error
+	}{
+		{
+			desc: "Success",
+			id:   1,
+			setupMock: func() {
+				mockStore.EXPECT().GetDeletedUserForUpdate(gomock.Any(), 1).Return(models.User{ID: 1, Name: "Alice", DeletedAt: time.Now()}, nil)
+				mockStore.EXPECT().RestoreUser(gomock.Any(), 1).Return(nil)
+			},
+			want: models.User{ID: 1, Name: "Alice"},
+		},
+		{
+			desc:    "Invalid ID",
+			id:      0,
+			wantErr: errors.New("invalid user ID"),
+		},
+		{
+			desc: "Not In Trash",
+			id:   2,
+			setupMock: func() {
+				mockStore.EXPECT().GetDeletedUserForUpdate(gomock.Any(), 2).Return(models.User{}, sql.ErrNoRows)
+			},
+			wantErr: errors.New("user not found in the trash"),
+		},
+		{
+			desc: "DB Error",
+			id:   3,
+			setupMock: func() {
+				mockStore.EXPECT().GetDeletedUserForUpdate(gomock.Any(), 3).Return(models.User{ID: 3, DeletedAt: time.Now()}, nil)
+				mockStore.EXPECT().RestoreUser(gomock.Any(), 3).Return(errors.New("db error"))
+			},
+			wantErr: errors.New("db error"),
+		},
+	}
+
+	for _, test := range tests {
+		if test.setupMock != nil {
+			test.setupMock()
+		}
+		u, err := svc.RestoreUser(adminCtx, test.id)
+		if !errorsEqual(err, test.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+		if u != test.want {
+			t.Errorf("%s: expected %+v, got %+v", test.desc, test.want, u)
+		}
+	}
+}
+
+func TestUserAccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	svc := New(mockStore)
+	bob := userCtx(2)
+
+	tests := []struct {
+		desc      string
+		setupMock func()
+		call      func() error
+		wantErr   error
+	}{
+		{
+			desc: "Rename Another User",
+			call: func() error {
+				_, err := svc.UpdateUser(bob, 1, models.User{Name: "Mallory"})
+				return err
+			},
+			wantErr: models.NotFound("user not found"),
+		},
+		{
+			desc: "Patch Another User's Password",
+			call: func() error {
+				pw := "correct horse"
+				_, err := svc.PatchUser(bob, 1, models.UserPatch{Password: &pw})
+				return err
+			},
+			wantErr: models.NotFound("user not found"),
+		},
+		{
+			desc: "Update Another User's Password",
+			call: func() error {
+				_, err := svc.UpdateUser(bob, 1, models.User{Name: "Alice", Password: "correct horse"})
+				return err
+			},
+			wantErr: models.NotFound("user not found"),
+		},
+		{
+			desc:    "Delete Another User",
+			call:    func() error { return svc.DeleteUser(bob, 1, models.UserDelete{Policy: models.DeleteCascade}) },
+			wantErr: models.NotFound("user not found"),
+		},
+		{
+			desc: "Restore Another User",
+			call: func() error {
+				_, err := svc.RestoreUser(bob, 1)
+				return err
+			},
+			wantErr: models.NotFound("user not found"),
+		},
+		{
+			desc: "Give Own Tasks To Another User",
+			setupMock: func() {
+				mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 2).Return(models.User{ID: 2, Name: "Bob"}, nil)
+			},
+			call: func() error {
+				return svc.DeleteUser(bob, 2, models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 1})
+			},
+			wantErr: models.Forbidden("cannot reassign tasks to another user"),
+		},
+		{
+			desc: "List Users",
+			call: func() error {
+				_, err := svc.ViewUsers(bob, models.UserFilter{})
+				return err
+			},
+			wantErr: models.Forbidden("only admins can list users"),
+		},
+		{
+			desc: "List The Trash",
+			call: func() error {
+				_, err := svc.ViewUsers(bob, models.UserFilter{Deleted: true})
+				return err
+			},
+			wantErr: models.Forbidden("only admins can list users"),
+		},
+		{
+			desc: "Without A Principal",
+			call: func() error {
+				_, err := svc.UpdateUser(context.Background(), 1, models.User{Name: "Alicia"})
+				return err
+			},
+			wantErr: models.Unauthorized("authentication required"),
+		},
+		{
+			desc: "Rename Themselves",
+			setupMock: func() {
+				mockStore.EXPECT().GetUserForUpdate(gomock.Any(), 2).Return(models.User{ID: 2, Name: "Bob"}, nil)
+				mockStore.EXPECT().GetUserByName(gomock.Any(), "Robert").Return(models.User{}, sql.ErrNoRows)
+				mockStore.EXPECT().UpdateUser(gomock.Any(), models.User{ID: 2, Name: "Robert"}).Return(nil)
+			},
+			call: func() error {
+				_, err := svc.UpdateUser(bob, 2, models.User{Name: "Robert"})
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if test.setupMock != nil {
+			test.setupMock()
+		}
+		if err := test.call(); !errorsEqual(err, test.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+	}
+}
+
+type txKey struct{}
+
+// adminCtx is the context of a request made by an admin, who may see and
+// change every user.
+var adminCtx = models.WithPrincipal(context.Background(), models.Principal{Name: "ops", Role: models.RoleAdmin})
+
+// userCtx is the context of a request made by the user with id.
+func userCtx(id int) context.Context {
+	return models.WithPrincipal(context.Background(), models.Principal{UserID: id, Name: "user", Role: models.RoleUser})
+}
+
 func errorsEqual(err1, err2 error) bool {
 	if err1 == nil && err2 == nil {
 		return true
@@ -116,3 +841,168 @@ func errorsEqual(err1, err2 error) bool {
 	}
 	return err1.Error() == err2.Error()
 }
+
+func TestAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	mockTx := NewMockTransactor(ctrl)
+	mockAuditor := NewMockAuditor(ctrl)
+	svc := New(mockStore)
+	svc.Tx = mockTx
+	svc.Audit = mockAuditor
+
+	// Entries are recorded in the unit of work of the change
+	admin := models.Principal{Name: "ops", Role: models.RoleAdmin}
+	ctx := models.WithRequestID(models.WithPrincipal(context.Background(), admin), "req-1")
+	txCtx := context.WithValue(ctx, txKey{}, true)
+	mockTx.EXPECT().InTx(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, fn func(context.Context) error) error { return fn(txCtx) })
+	mockStore.EXPECT().GetUserByName(gomock.Any(), gomock.Any()).AnyTimes().Return(models.User{}, sql.ErrNoRows)
+
+	alice := models.User{ID: 1, Name: "Alice"}
+	entry := func(action models.AuditAction, changes map[string]models.AuditChange) models.AuditEntry {
+		return models.AuditEntry{Actor: "ops", Action: action, Entity: models.AuditUser, EntityID: 1, Changes: changes, RequestID: "req-1"}
+	}
+
+	tests := []struct {
+		desc      string
+		setupMock func()
+		call      func() error
+		want      models.AuditEntry
+	}{
+		{
+			desc: "Create With Password",
+			setupMock: func() {
+				mockStore.EXPECT().CreateUser(txCtx, models.User{Name: "Alice"}).Return(alice, nil)
+				mockStore.EXPECT().SetPasswordHash(txCtx, 1, gomock.Any()).Return(nil)
+			},
+			call: func() error {
+				_, err := svc.CreateUser(ctx, models.User{Name: "Alice", Password: "correct horse"})
+				return err
+			},
+			want: entry(models.AuditCreate, map[string]models.AuditChange{
+				"id": {After: 1.0}, "name": {After: "Alice"}, "password": {After: models.Redacted},
+			}),
+		},
+		{
+			desc: "Rename",
+			setupMock: func() {
+				mockStore.EXPECT().GetUserForUpdate(txCtx, 1).Return(alice, nil)
+				mockStore.EXPECT().UpdateUser(txCtx, models.User{ID: 1, Name: "Alicia"}).Return(nil)
+			},
+			call: func() error {
+				name := "Alicia"
+				_, err := svc.PatchUser(ctx, 1, models.UserPatch{Name: &name})
+				return err
+			},
+			want: entry(models.AuditUpdate, map[string]models.AuditChange{
+				"name": {Before: "Alice", After: "Alicia"},
+			}),
+		},
+		{
+			// Only that the password changed is recorded, never the password
+			desc: "New Password",
+			setupMock: func() {
+				mockStore.EXPECT().GetUserForUpdate(txCtx, 1).Return(alice, nil)
+				mockStore.EXPECT().UpdateUser(txCtx, alice).Return(nil)
+				mockStore.EXPECT().SetPasswordHash(txCtx, 1, gomock.Any()).Return(nil)
+			},
+			call: func() error {
+				pw := "battery staple"
+				_, err := svc.PatchUser(ctx, 1, models.UserPatch{Password: &pw})
+				return err
+			},
+			want: entry(models.AuditUpdate, map[string]models.AuditChange{
+				"password": {After: models.Redacted},
+			}),
+		},
+		{
+			desc: "Delete",
+			setupMock: func() {
+				mockStore.EXPECT().GetUserForUpdate(txCtx, 1).Return(alice, nil)
+				mockStore.EXPECT().CountTasks(txCtx, 1).Return(0, nil)
+				mockStore.EXPECT().DeleteUser(txCtx, 1, 0).Return(nil)
+			},
+			call: func() error { return svc.DeleteUser(ctx, 1, models.UserDelete{Policy: models.DeleteReject}) },
+			want: entry(models.AuditDelete, map[string]models.AuditChange{
+				"id": {Before: 1.0}, "name": {Before: "Alice"},
+			}),
+		},
+		{
+			desc: "Restore",
+			setupMock: func() {
+				deleted := alice
+				deleted.DeletedAt = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+				mockStore.EXPECT().GetDeletedUserForUpdate(txCtx, 1).Return(deleted, nil)
+				mockStore.EXPECT().RestoreUser(txCtx, 1).Return(nil)
+			},
+			call: func() error {
+				_, err := svc.RestoreUser(ctx, 1)
+				return err
+			},
+			want: entry(models.AuditRestore, map[string]models.AuditChange{
+				"deleted_at": {Before: "2025-03-01T00:00:00Z"},
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		test.setupMock()
+		mockAuditor.EXPECT().AppendAudit(txCtx, test.want).Return(nil)
+		if err := test.call(); err != nil {
+			t.Errorf("%v: unexpected error: %v", test.desc, err)
+		}
+	}
+}
+
+func TestAudit_Reassign(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	mockTx := NewMockTransactor(ctrl)
+	mockAuditor := NewMockAuditor(ctrl)
+	svc := New(mockStore)
+	svc.Tx = mockTx
+	svc.Audit = mockAuditor
+
+	ctx := models.WithRequestID(adminCtx, "req-1")
+	txCtx := context.WithValue(ctx, txKey{}, true)
+	mockTx.EXPECT().InTx(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, fn func(context.Context) error) error { return fn(txCtx) })
+
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	open := models.Task{ID: 3, Task: "Write docs", UserID: 1, Version: 2, UpdatedAt: day}
+	trashed := models.Task{ID: 5, Task: "Old", UserID: 1, Version: 4, UpdatedAt: day, DeletedAt: day}
+	move := func(t models.Task) models.Task {
+		t.UserID, t.Version, t.UpdatedAt = 2, t.Version+1, day.Add(time.Hour)
+		return t
+	}
+	entry := func(action models.AuditAction, entity string, id int, changes map[string]models.AuditChange) models.AuditEntry {
+		return models.AuditEntry{Actor: "ops", Action: action, Entity: entity, EntityID: id, Changes: changes, RequestID: "req-1"}
+	}
+	moved := func(id, version int) models.AuditEntry {
+		return entry(models.AuditUpdate, models.AuditTask, id, map[string]models.AuditChange{
+			"user_id":    {Before: 1.0, After: 2.0},
+			"version":    {Before: float64(version), After: float64(version + 1)},
+			"updated_at": {Before: "2025-03-01T00:00:00Z", After: "2025-03-01T01:00:00Z"},
+		})
+	}
+
+	// One update of each task moved, those in the trash included, then the
+	// delete of the user
+	gomock.InOrder(
+		mockStore.EXPECT().GetUserForUpdate(txCtx, 1).Return(models.User{ID: 1, Name: "Alice"}, nil),
+		mockStore.EXPECT().GetUserForShare(txCtx, 2).Return(models.User{ID: 2, Name: "Bob"}, nil),
+		mockStore.EXPECT().LockUserTasks(txCtx, 1).Return([]models.Task{open, trashed}, nil),
+		mockStore.EXPECT().DeleteUser(txCtx, 1, 2).Return(nil),
+		mockStore.EXPECT().LockUserTasks(txCtx, 2).Return([]models.Task{{ID: 1, UserID: 2}, move(open), move(trashed)}, nil),
+		mockAuditor.EXPECT().AppendAudit(txCtx, moved(3, 2)).Return(nil),
+		mockAuditor.EXPECT().AppendAudit(txCtx, moved(5, 4)).Return(nil),
+		mockAuditor.EXPECT().AppendAudit(txCtx, entry(models.AuditDelete, models.AuditUser, 1, map[string]models.AuditChange{
+			"id": {Before: 1.0}, "name": {Before: "Alice"},
+		})).Return(nil),
+	)
+
+	if err := svc.DeleteUser(ctx, 1, models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 2}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+// Package auditstore keeps the audit log in the AUDIT table. The log is
+// append-only: the store has no way to change or remove an entry.
+package auditstore
+
+import (
+	"3layerarch/models"
+	"3layerarch/store"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+type Store struct {
+	db      *sql.DB
+	dialect store.Dialect
+}
+
+// New returns the audit store of db, whose SQL is written in dialect.
+func New(db *sql.DB, dialect store.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+// conn returns what queries for ctx run on: its unit of work, if any.
+func (s *Store) conn(ctx context.Context) store.DBTX {
+	return s.dialect.Bind(store.Conn(ctx, s.db))
+}
+
+// AppendAudit adds e to the log at the current time. Called in a unit of
+// work, the entry is only kept if the change it records is.
+func (s *Store) AppendAudit(ctx context.Context, e models.AuditEntry) error {
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+	_, err = s.dialect.Insert(ctx, s.conn(ctx),
+		"INSERT INTO AUDIT (created_at, actor_id, actor, action, entity, entity_id, changes, request_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		store.Now(), e.ActorID, e.Actor, e.Action, e.Entity, e.EntityID, string(changes), e.RequestID)
+	return err
+}
+
+// ViewAudit returns the page of entries selected by f, oldest first, and the
+// number of entries that match f across all pages.
+func (s *Store) ViewAudit(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, int, error) {
+	where, args := auditWhere(f)
+
+	var total int
+	if err := s.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM AUDIT"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT id, created_at, actor_id, actor, action, entity, entity_id, changes, request_id FROM AUDIT" + where + " ORDER BY id ASC LIMIT ? OFFSET ?"
+	rows, err := s.conn(ctx).QueryContext(ctx, query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var changes string
+		if err := rows.Scan(&e.ID, &e.Time, &e.ActorID, &e.Actor, &e.Action, &e.Entity, &e.EntityID, &changes, &e.RequestID); err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+			return nil, 0, err
+		}
+		e.Time = e.Time.UTC()
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// auditWhere builds the WHERE clause and its arguments for f's filters.
+func auditWhere(f models.AuditFilter) (string, []any) {
+	var conds []string
+	var args []any
+	if f.Entity != "" {
+		conds = append(conds, "entity = ?")
+		args = append(args, f.Entity)
+	}
+	if f.EntityID != 0 {
+		conds = append(conds, "entity_id = ?")
+		args = append(args, f.EntityID)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,86 @@
+package auditstore_test
+
+import (
+	"3layerarch/models"
+	"3layerarch/store"
+	"3layerarch/store/audit"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAppendAudit(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := auditstore.New(db, store.MySQL)
+	e := models.AuditEntry{
+		ActorID:   1,
+		Actor:     "alice",
+		Action:    models.AuditComplete,
+		Entity:    models.AuditTask,
+		EntityID:  7,
+		Changes:   map[string]models.AuditChange{"completed": {Before: false, After: true}},
+		RequestID: "req-1",
+	}
+
+	mock.ExpectExec("INSERT INTO AUDIT (created_at, actor_id, actor, action, entity, entity_id, changes, request_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)").
+		WithArgs(sqlmock.AnyArg(), 1, "alice", "complete", "task", 7, `{"completed":{"before":false,"after":true}}`, "req-1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := repo.AppendAudit(context.Background(), e); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestViewAudit(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := auditstore.New(db, store.MySQL)
+	columns := []string{"id", "created_at", "actor_id", "actor", "action", "entity", "entity_id", "changes", "request_id"}
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM AUDIT WHERE entity = ? AND entity_id = ?").
+		WithArgs("task", 7).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT id, created_at, actor_id, actor, action, entity, entity_id, changes, request_id FROM AUDIT WHERE entity = ? AND entity_id = ? ORDER BY id ASC LIMIT ? OFFSET ?").
+		WithArgs("task", 7, 2, 1).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(2, at, 1, "alice", "update", "task", 7, `{"task":{"before":"a","after":"b"}}`, "req-2").
+			AddRow(5, at, 0, "admin", "delete", "task", 7, `{"task":{"before":"b","after":null}}`, ""))
+
+	entries, total, err := repo.ViewAudit(context.Background(), models.AuditFilter{Entity: "task", EntityID: 7, Limit: 2, Offset: 1})
+	if err != nil || len(entries) != 2 || total != 3 {
+		t.Fatalf("unexpected result: %v, total: %d, err: %v", entries, total, err)
+	}
+	if e := entries[0]; e.ID != 2 || e.ActorID != 1 || e.Action != models.AuditUpdate || e.Changes["task"].After != "b" || e.RequestID != "req-2" || !e.Time.Equal(at) {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	// The whole log
+	mock.ExpectQuery("SELECT COUNT(*) FROM AUDIT").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT id, created_at, actor_id, actor, action, entity, entity_id, changes, request_id FROM AUDIT ORDER BY id ASC LIMIT ? OFFSET ?").
+		WithArgs(20, 0).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	entries, _, err = repo.ViewAudit(context.Background(), models.AuditFilter{Limit: 20})
+	if err != nil || entries == nil || len(entries) != 0 {
+		t.Errorf("expected an empty page, got %#v, err: %v", entries, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+// Package cachestore reads tasks and users by ID through a cache, in front
+// of the stores of any driver.
+//
+// A cached value is at most the TTL old: every write through the stores
+// evicts what it changes, but a lookup that raced the write may have put
+// the old value back. Units of work must run through the Transactor of the
+// cache, which evicts their changes again once they end and keeps their
+// uncommitted reads out of the cache.
+package cachestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"3layerarch/cache"
+	"3layerarch/models"
+	taskservice "3layerarch/service/task"
+	userservice "3layerarch/service/user"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Metrics counts the lookups of each cache, "task" or "user", that were
+// found in it and those that were not.
+type Metrics interface {
+	CacheHit(name string)
+	CacheMiss(name string)
+}
+
+type Cache struct {
+	backend cache.Backend
+	ttl     time.Duration
+	// group collapses concurrent misses of a key into one load.
+	group singleflight.Group
+	// Metrics, if set, counts hits and misses.
+	Metrics Metrics
+}
+
+// New returns a cache that keeps values in backend for ttl.
+func New(backend cache.Backend, ttl time.Duration) *Cache {
+	return &Cache{backend: backend, ttl: ttl}
+}
+
+// loadTimeout bounds a shared load, which no caller can cancel.
+const loadTimeout = 10 * time.Second
+
+const (
+	taskPrefix = "task:"
+	userPrefix = "user:"
+)
+
+func taskKey(id int) string { return taskPrefix + strconv.Itoa(id) }
+func userKey(id int) string { return userPrefix + strconv.Itoa(id) }
+
+type unitKey struct{}
+
+// unit is a unit of work in progress and the keys it has changed.
+type unit struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (u *unit) add(keys ...string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.keys = append(u.keys, keys...)
+}
+
+func (u *unit) changed(key string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return slices.Contains(u.keys, key)
+}
+
+func unitFrom(ctx context.Context) *unit {
+	u, _ := ctx.Value(unitKey{}).(*unit)
+	return u
+}
+
+// Transactor runs units of work through the one it wraps.
+type Transactor struct {
+	tx taskservice.Transactor
+	c  *Cache
+}
+
+// Tx wraps tx, which must run the units of work of the stores c wraps.
+func (c *Cache) Tx(tx taskservice.Transactor) *Transactor {
+	return &Transactor{tx: tx, c: c}
+}
+
+// InTx runs fn as tx does, and once it has ended evicts again what it
+// changed, as a lookup made before it committed may have cached the old
+// values.
+func (t *Transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if unitFrom(ctx) != nil {
+		return t.tx.InTx(ctx, fn)
+	}
+	u := &unit{}
+	err := t.tx.InTx(context.WithValue(ctx, unitKey{}, u), fn)
+	t.c.delete(ctx, u.keys...)
+	return err
+}
+
+// get returns the value of key, called name in the metrics, from the cache
+// or else from load, keeping it for next time. Inside a unit of work what
+// load returns may not be committed, so it is not kept, and the keys the
+// unit has changed are always loaded.
+func get[T any](ctx context.Context, c *Cache, name, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	u := unitFrom(ctx)
+	if u != nil && u.changed(key) {
+		return load(ctx)
+	}
+
+	var v T
+	b, ok, err := c.backend.Get(ctx, key)
+	if err != nil {
+		log.Println("Error reading cache:", err)
+	}
+	if ok && json.Unmarshal(b, &v) == nil {
+		c.count(name, true)
+		return v, nil
+	}
+	c.count(name, false)
+	if u != nil {
+		return load(ctx)
+	}
+
+	// The load is shared by every caller, so it must outlive the one that
+	// started it
+	ch := c.group.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+		v, err := load(ctx)
+		if err != nil {
+			return v, err
+		}
+		if b, err := json.Marshal(v); err == nil {
+			if err := c.backend.Set(ctx, key, b, c.ttl); err != nil {
+				log.Println("Error writing cache:", err)
+			}
+		}
+		return v, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return v, res.Err
+		}
+		return res.Val.(T), nil
+	case <-ctx.Done():
+		return v, ctx.Err()
+	}
+}
+
+func (c *Cache) count(name string, hit bool) {
+	switch {
+	case c.Metrics == nil:
+	case hit:
+		c.Metrics.CacheHit(name)
+	default:
+		c.Metrics.CacheMiss(name)
+	}
+}
+
+// evict drops keys from the cache now and, inside a unit of work, once
+// more when it ends.
+func (c *Cache) evict(ctx context.Context, keys ...string) {
+	if u := unitFrom(ctx); u != nil {
+		u.add(keys...)
+	}
+	c.delete(ctx, keys...)
+}
+
+// flush drops every key starting with one of prefixes, for changes to more
+// values than can be told apart. Unlike evict it is not repeated when a unit
+// of work ends, so it belongs outside of one.
+func (c *Cache) flush(ctx context.Context, prefixes ...string) {
+	for _, prefix := range prefixes {
+		if err := c.backend.DeletePrefix(context.WithoutCancel(ctx), prefix); err != nil {
+			log.Println("Error evicting from cache:", err)
+		}
+	}
+}
+
+func (c *Cache) delete(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	// The request may be over, but the values must still go
+	if err := c.backend.Delete(context.WithoutCancel(ctx), keys...); err != nil {
+		log.Println("Error evicting from cache:", err)
+	}
+}
+
+// TaskStore reads tasks through the cache. Locking reads and listings go
+// to the store it wraps.
+type TaskStore struct {
+	taskservice.TaskStore
+	c *Cache
+}
+
+func (c *Cache) Tasks(s taskservice.TaskStore) *TaskStore {
+	return &TaskStore{TaskStore: s, c: c}
+}
+
+func (s *TaskStore) GetTask(ctx context.Context, id int) (models.Task, error) {
+	return get(ctx, s.c, "task", taskKey(id), func(ctx context.Context) (models.Task, error) {
+		return s.TaskStore.GetTask(ctx, id)
+	})
+}
+
+func (s *TaskStore) UpdateTask(ctx context.Context, t models.Task) (models.Task, error) {
+	updated, err := s.TaskStore.UpdateTask(ctx, t)
+	s.c.evict(ctx, taskKey(t.ID))
+	return updated, err
+}
+
+func (s *TaskStore) DeleteTask(ctx context.Context, id int) error {
+	err := s.TaskStore.DeleteTask(ctx, id)
+	s.c.evict(ctx, taskKey(id))
+	return err
+}
+
+// PurgeTasks purges as the store it wraps does. The subtasks of the purged
+// tasks change too, and which they are is not known here, so every task is
+// evicted once any has been purged.
+func (s *TaskStore) PurgeTasks(ctx context.Context, before time.Time) (int, error) {
+	n, err := s.TaskStore.PurgeTasks(ctx, before)
+	if n > 0 {
+		s.c.flush(ctx, taskPrefix)
+	}
+	return n, err
+}
+
+// UserStore reads users through the cache. Locking reads, lookups by name
+// and listings go to the store it wraps.
+type UserStore struct {
+	userservice.UserStore
+	c *Cache
+}
+
+func (c *Cache) Users(s userservice.UserStore) *UserStore {
+	return &UserStore{UserStore: s, c: c}
+}
+
+func (s *UserStore) GetUser(ctx context.Context, id int) (models.User, error) {
+	return get(ctx, s.c, "user", userKey(id), func(ctx context.Context) (models.User, error) {
+		return s.UserStore.GetUser(ctx, id)
+	})
+}
+
+// GetUserForShare always goes to the store it wraps, as it locks the user
+// until the unit of work ends. Deleting a user only moves them to the trash,
+// so without the lock nothing would stop a task being given to a user who
+// was deleted meanwhile.
+func (s *UserStore) GetUserForShare(ctx context.Context, id int) (models.User, error) {
+	return s.UserStore.GetUserForShare(ctx, id)
+}
+
+func (s *UserStore) UpdateUser(ctx context.Context, u models.User) error {
+	err := s.UserStore.UpdateUser(ctx, u)
+	s.c.evict(ctx, userKey(u.ID))
+	return err
+}
+
+// DeleteUser deletes the user as the store it wraps does, and evicts them
+// and their tasks, which are deleted or moved to another user.
+func (s *UserStore) DeleteUser(ctx context.Context, id, reassignTo int) error {
+	_, tasks, err := s.UserStore.GetUserWithTasks(ctx, id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	keys := []string{userKey(id)}
+	for _, t := range tasks {
+		keys = append(keys, taskKey(t.ID))
+	}
+	err = s.UserStore.DeleteUser(ctx, id, reassignTo)
+	s.c.evict(ctx, keys...)
+	return err
+}
+
+// PurgeUsers purges as the store it wraps does, which changes the subtasks
+// of their tasks that other users own, so every task and user is evicted
+// once any user has been purged.
+func (s *UserStore) PurgeUsers(ctx context.Context, before time.Time) (int, error) {
+	n, err := s.UserStore.PurgeUsers(ctx, before)
+	if n > 0 {
+		s.c.flush(ctx, taskPrefix, userPrefix)
+	}
+	return n, err
+}
@@ -0,0 +1,361 @@
+package cachestore_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"3layerarch/cache"
+	"3layerarch/models"
+	cachestore "3layerarch/store/cache"
+	memstore "3layerarch/store/memory"
+	"3layerarch/store/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		s := memstore.New()
+		c := cachestore.New(cache.NewLRU(100), time.Minute)
+		return storetest.Stores{Tasks: c.Tasks(s), Users: c.Users(s), Audit: s, Tx: c.Tx(s)}
+	})
+}
+
+// countingStore counts the lookups that reach the store. Lookups wait for
+// release, if set, and then fail if their ctx is done, as a database would.
+type countingStore struct {
+	*memstore.Store
+	tasks, users atomic.Int32
+	release      chan struct{}
+}
+
+func (s *countingStore) GetTask(ctx context.Context, id int) (models.Task, error) {
+	s.tasks.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	if err := ctx.Err(); err != nil {
+		return models.Task{}, err
+	}
+	return s.Store.GetTask(ctx, id)
+}
+
+func (s *countingStore) GetUserForShare(ctx context.Context, id int) (models.User, error) {
+	s.users.Add(1)
+	return s.Store.GetUserForShare(ctx, id)
+}
+
+type countingMetrics struct {
+	mu           sync.Mutex
+	hits, misses map[string]int
+}
+
+func (m *countingMetrics) CacheHit(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hits[name]++
+}
+
+func (m *countingMetrics) CacheMiss(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.misses[name]++
+}
+
+type fixture struct {
+	store   *countingStore
+	backend *cache.LRU
+	metrics *countingMetrics
+	tasks   *cachestore.TaskStore
+	users   *cachestore.UserStore
+	tx      *cachestore.Transactor
+	alice   models.User
+	task    models.Task
+}
+
+// setup returns cached stores holding alice and one task of hers.
+func setup(t *testing.T) *fixture {
+	ctx := context.Background()
+	f := &fixture{
+		store:   &countingStore{Store: memstore.New()},
+		backend: cache.NewLRU(100),
+		metrics: &countingMetrics{hits: map[string]int{}, misses: map[string]int{}},
+	}
+	c := cachestore.New(f.backend, time.Minute)
+	c.Metrics = f.metrics
+	f.tasks, f.users, f.tx = c.Tasks(f.store), c.Users(f.store), c.Tx(f.store)
+
+	var err error
+	if f.alice, err = f.users.CreateUser(ctx, models.User{Name: "alice"}); err != nil {
+		t.Fatalf("failed to create alice: %v", err)
+	}
+	if f.task, err = f.tasks.CreateTask(ctx, models.Task{Task: "write", UserID: f.alice.ID}); err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	return f
+}
+
+func TestGetTask_ReadsThrough(t *testing.T) {
+	ctx := context.Background()
+	f := setup(t)
+
+	for range 3 {
+		got, err := f.tasks.GetTask(ctx, f.task.ID)
+		if err != nil || got != f.task {
+			t.Fatalf("expected %+v, got %+v, err: %v", f.task, got, err)
+		}
+	}
+	if n := f.store.tasks.Load(); n != 1 {
+		t.Errorf("expected 1 lookup in the store, got %d", n)
+	}
+	if f.metrics.hits["task"] != 2 || f.metrics.misses["task"] != 1 {
+		t.Errorf("expected 2 hits and 1 miss, got %v and %v", f.metrics.hits, f.metrics.misses)
+	}
+
+	f.task.Completed = true
+	if _, err := f.tasks.UpdateTask(ctx, f.task); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if got, _ := f.tasks.GetTask(ctx, f.task.ID); !got.Completed {
+		t.Error("expected the update to evict the cached task")
+	}
+
+	if err := f.tasks.DeleteTask(ctx, f.task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if _, err := f.tasks.GetTask(ctx, f.task.ID); err == nil {
+		t.Error("expected the deleted task to be gone")
+	}
+}
+
+func TestGetTask_CollapsesMisses(t *testing.T) {
+	ctx := context.Background()
+	f := setup(t)
+	f.store.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := f.tasks.GetTask(ctx, f.task.ID); err != nil || got != f.task {
+				t.Errorf("expected %+v, got %+v, err: %v", f.task, got, err)
+			}
+		}()
+	}
+	// Let the lookups pile up behind the first
+	time.Sleep(50 * time.Millisecond)
+	close(f.store.release)
+	wg.Wait()
+
+	if n := f.store.tasks.Load(); n != 1 {
+		t.Errorf("expected 1 lookup in the store, got %d", n)
+	}
+}
+
+func TestGetTask_CancelledLeader(t *testing.T) {
+	f := setup(t)
+	f.store.release = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := f.tasks.GetTask(ctx, f.task.ID)
+		leader <- err
+	}()
+	// Let the leader start the load before the others join it
+	time.Sleep(20 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := f.tasks.GetTask(context.Background(), f.task.ID); err != nil || got != f.task {
+				t.Errorf("expected %+v, got %+v, err: %v", f.task, got, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	close(f.store.release)
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the leader to be cancelled, got %v", err)
+	}
+	wg.Wait()
+
+	if n := f.store.tasks.Load(); n != 1 {
+		t.Errorf("expected 1 lookup in the store, got %d", n)
+	}
+	if _, err := f.tasks.GetTask(context.Background(), f.task.ID); err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if n := f.store.tasks.Load(); n != 1 {
+		t.Errorf("expected the shared load to be cached, got %d lookups", n)
+	}
+}
+
+func TestGetUserForShare_Locks(t *testing.T) {
+	ctx := context.Background()
+	f := setup(t)
+	if _, err := f.users.GetUser(ctx, f.alice.ID); err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+
+	// The cached user would not be locked
+	for range 2 {
+		err := f.tx.InTx(ctx, func(ctx context.Context) error {
+			_, err := f.users.GetUserForShare(ctx, f.alice.ID)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("InTx: %v", err)
+		}
+	}
+	if n := f.store.users.Load(); n != 2 {
+		t.Errorf("expected every lookup in the store, got %d", n)
+	}
+}
+
+func TestPurgeTasks_EvictsSubtasks(t *testing.T) {
+	ctx := context.Background()
+	f := setup(t)
+	sub, err := f.tasks.CreateTask(ctx, models.Task{Task: "proofread", UserID: f.alice.ID, ParentID: f.task.ID})
+	if err != nil {
+		t.Fatalf("failed to create subtask: %v", err)
+	}
+	if _, err := f.tasks.GetTask(ctx, sub.ID); err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if err := f.tasks.DeleteTask(ctx, f.task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+
+	if n, err := f.tasks.PurgeTasks(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Fatalf("expected the parent purged, got %d, err: %v", n, err)
+	}
+	got, err := f.tasks.GetTask(ctx, sub.ID)
+	if err != nil || got.ParentID != 0 || got.Version != sub.Version+1 {
+		t.Errorf("expected a top-level subtask at version %d, got %+v, err: %v", sub.Version+1, got, err)
+	}
+}
+
+func TestPurgeUsers_EvictsSubtasks(t *testing.T) {
+	ctx := context.Background()
+	f := setup(t)
+	bob, err := f.users.CreateUser(ctx, models.User{Name: "bob"})
+	if err != nil {
+		t.Fatalf("failed to create bob: %v", err)
+	}
+	sub, err := f.tasks.CreateTask(ctx, models.Task{Task: "review", UserID: bob.ID, ParentID: f.task.ID})
+	if err != nil {
+		t.Fatalf("failed to create subtask: %v", err)
+	}
+	if _, err := f.tasks.GetTask(ctx, sub.ID); err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if err := f.users.DeleteUser(ctx, f.alice.ID, 0); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	if n, err := f.users.PurgeUsers(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Fatalf("expected alice purged, got %d, err: %v", n, err)
+	}
+	got, err := f.tasks.GetTask(ctx, sub.ID)
+	if err != nil || got.ParentID != 0 || got.Version != sub.Version+1 {
+		t.Errorf("expected a top-level subtask at version %d, got %+v, err: %v", sub.Version+1, got, err)
+	}
+}
+
+func TestInTx_KeepsChangesOutUntilCommit(t *testing.T) {
+	ctx := context.Background()
+	f := setup(t)
+	if _, err := f.tasks.GetTask(ctx, f.task.ID); err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+
+	rollback := errors.New("rollback")
+	err := f.tx.InTx(ctx, func(ctx context.Context) error {
+		changed := f.task
+		changed.Task = "changed"
+		if _, err := f.tasks.UpdateTask(ctx, changed); err != nil {
+			return err
+		}
+		if got, _ := f.tasks.GetTask(ctx, f.task.ID); got.Task != "changed" {
+			t.Errorf("expected the unit of work to read its change, got %q", got.Task)
+		}
+		return rollback
+	})
+	if err != rollback {
+		t.Fatalf("expected the rollback, got %v", err)
+	}
+
+	if f.backend.Len() != 0 {
+		t.Errorf("expected nothing cached, got %d values", f.backend.Len())
+	}
+	if got, _ := f.tasks.GetTask(ctx, f.task.ID); got.Task != "write" {
+		t.Errorf("expected the change rolled back, got %q", got.Task)
+	}
+}
+
+func TestDeleteUser_EvictsTasks(t *testing.T) {
+	ctx := context.Background()
+	f := setup(t)
+	bob, err := f.users.CreateUser(ctx, models.User{Name: "bob"})
+	if err != nil {
+		t.Fatalf("failed to create bob: %v", err)
+	}
+	if _, err := f.users.GetUser(ctx, f.alice.ID); err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if _, err := f.tasks.GetTask(ctx, f.task.ID); err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+
+	if err := f.users.DeleteUser(ctx, f.alice.ID, bob.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := f.users.GetUser(ctx, f.alice.ID); err == nil {
+		t.Error("expected alice to be gone")
+	}
+	if got, _ := f.tasks.GetTask(ctx, f.task.ID); got.UserID != bob.ID {
+		t.Errorf("expected the task to be bob's, got user %d", got.UserID)
+	}
+}
+
+// brokenBackend fails every call, as a Redis that is down does.
+type brokenBackend struct{}
+
+var errDown = errors.New("connection refused")
+
+func (brokenBackend) Get(context.Context, string) ([]byte, bool, error) { return nil, false, errDown }
+func (brokenBackend) Set(context.Context, string, []byte, time.Duration) error {
+	return errDown
+}
+func (brokenBackend) Delete(context.Context, ...string) error    { return errDown }
+func (brokenBackend) DeletePrefix(context.Context, string) error { return errDown }
+
+func TestBackendDown(t *testing.T) {
+	ctx := context.Background()
+	s := memstore.New()
+	c := cachestore.New(brokenBackend{}, time.Minute)
+	tasks, users := c.Tasks(s), c.Users(s)
+
+	alice, err := users.CreateUser(ctx, models.User{Name: "alice"})
+	if err != nil {
+		t.Fatalf("failed to create alice: %v", err)
+	}
+	if got, err := users.GetUser(ctx, alice.ID); err != nil || got != alice {
+		t.Errorf("expected %+v from the store, got %+v, err: %v", alice, got, err)
+	}
+	task, err := tasks.CreateTask(ctx, models.Task{Task: "write", UserID: alice.ID})
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	task.Completed = true
+	if _, err := tasks.UpdateTask(ctx, task); err != nil {
+		t.Errorf("expected the update despite the cache, got %v", err)
+	}
+}
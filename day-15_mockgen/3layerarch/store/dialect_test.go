@@ -0,0 +1,24 @@
+package store_test
+
+import (
+	"testing"
+
+	"3layerarch/store"
+)
+
+func TestRebind(t *testing.T) {
+	query := "SELECT id FROM TASKS WHERE user_id = ? AND task LIKE ? ESCAPE '!' LIMIT ? OFFSET ?"
+	tests := []struct {
+		dialect store.Dialect
+		want    string
+	}{
+		{store.MySQL, query},
+		{store.SQLite, query},
+		{store.Postgres, "SELECT id FROM TASKS WHERE user_id = $1 AND task LIKE $2 ESCAPE '!' LIMIT $3 OFFSET $4"},
+	}
+	for _, tc := range tests {
+		if got := tc.dialect.Rebind(query); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.dialect.Name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package store
+
+import "database/sql"
+
+// NullID is the value of a nullable ID column: NULL for zero, which is
+// never an ID.
+func NullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
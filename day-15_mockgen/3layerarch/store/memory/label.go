@@ -0,0 +1,153 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"3layerarch/models"
+)
+
+// CreateLabel stores l and returns it with a new ID. A name that is taken
+// fails, as the unique key does in MySQL.
+func (s *Store) CreateLabel(ctx context.Context, l models.Label) (models.Label, error) {
+	err := s.write(ctx, func(d *data) error {
+		if d.labelNamed(l.Name, 0) {
+			return fmt.Errorf("memstore: label %q already exists", l.Name)
+		}
+		d.lastLabel++
+		l.ID = d.lastLabel
+		d.labels[l.ID] = l
+		return nil
+	})
+	if err != nil {
+		return models.Label{}, err
+	}
+	return l, nil
+}
+
+// labelNamed reports whether a label other than the one with id is called
+// name.
+func (d *data) labelNamed(name string, id int) bool {
+	for _, l := range d.labels {
+		if l.Name == name && l.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLabel returns the label with id, or sql.ErrNoRows.
+func (s *Store) GetLabel(ctx context.Context, id int) (models.Label, error) {
+	var l models.Label
+	ok := false
+	s.read(func(d *data) { l, ok = d.labels[id] })
+	if !ok {
+		return models.Label{}, sql.ErrNoRows
+	}
+	return l, nil
+}
+
+// GetLabelByName returns the label called name, or sql.ErrNoRows.
+func (s *Store) GetLabelByName(ctx context.Context, name string) (models.Label, error) {
+	var l models.Label
+	ok := false
+	s.read(func(d *data) {
+		for _, stored := range d.labels {
+			if stored.Name == name {
+				l, ok = stored, true
+				return
+			}
+		}
+	})
+	if !ok {
+		return models.Label{}, sql.ErrNoRows
+	}
+	return l, nil
+}
+
+// ViewLabels returns every label ordered by name.
+func (s *Store) ViewLabels(ctx context.Context) ([]models.Label, error) {
+	labels := []models.Label{}
+	s.read(func(d *data) {
+		for _, l := range d.labels {
+			labels = append(labels, l)
+		}
+	})
+	slices.SortFunc(labels, labelOrder)
+	return labels, nil
+}
+
+// labelOrder compares labels by name, then by id.
+func labelOrder(a, b models.Label) int {
+	return cmp.Or(strings.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+}
+
+// UpdateLabel renames the label with l.ID, if there is one.
+func (s *Store) UpdateLabel(ctx context.Context, l models.Label) error {
+	return s.write(ctx, func(d *data) error {
+		if _, ok := d.labels[l.ID]; !ok {
+			return nil
+		}
+		if d.labelNamed(l.Name, l.ID) {
+			return fmt.Errorf("memstore: label %q already exists", l.Name)
+		}
+		d.labels[l.ID] = l
+		return nil
+	})
+}
+
+// DeleteLabel deletes the label with id and takes it off every task.
+func (s *Store) DeleteLabel(ctx context.Context, id int) error {
+	return s.write(ctx, func(d *data) error {
+		delete(d.labels, id)
+		for tl := range d.taskLabels {
+			if tl.label == id {
+				delete(d.taskLabels, tl)
+			}
+		}
+		return nil
+	})
+}
+
+// TaskLabels returns the labels of the task with taskID ordered by name.
+func (s *Store) TaskLabels(ctx context.Context, taskID int) ([]models.Label, error) {
+	labels := []models.Label{}
+	s.read(func(d *data) {
+		for tl := range d.taskLabels {
+			if tl.task == taskID {
+				labels = append(labels, d.labels[tl.label])
+			}
+		}
+	})
+	slices.SortFunc(labels, labelOrder)
+	return labels, nil
+}
+
+// AddTaskLabel puts the label with labelID on the task with taskID. Adding
+// a label the task already has changes nothing; a task or label that does
+// not exist fails, as the foreign keys do in MySQL.
+func (s *Store) AddTaskLabel(ctx context.Context, taskID, labelID int) error {
+	return s.write(ctx, func(d *data) error {
+		if _, ok := d.tasks[taskID]; !ok {
+			return fmt.Errorf("memstore: task %d does not exist", taskID)
+		}
+		if _, ok := d.labels[labelID]; !ok {
+			return fmt.Errorf("memstore: label %d does not exist", labelID)
+		}
+		d.taskLabels[taskLabel{taskID, labelID}] = struct{}{}
+		return nil
+	})
+}
+
+// RemoveTaskLabel takes the label with labelID off the task with taskID, if
+// it has it.
+func (s *Store) RemoveTaskLabel(ctx context.Context, taskID, labelID int) error {
+	return s.write(ctx, func(d *data) error {
+		delete(d.taskLabels, taskLabel{taskID, labelID})
+		return nil
+	})
+}
@@ -0,0 +1,773 @@
+// Package memstore keeps tasks, labels, users and the audit log in memory,
+// for local development and tests that should not need a database server.
+// Everything is lost when the process exits.
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"3layerarch/models"
+)
+
+// user is a stored user with what models.User does not carry.
+type user struct {
+	models.User
+	role models.Role
+	hash string
+}
+
+// taskLabel is a label on a task.
+type taskLabel struct{ task, label int }
+
+// dependency is the task blocker blocking task.
+type dependency struct{ task, blocker int }
+
+type data struct {
+	tasks      map[int]models.Task
+	users      map[int]user
+	labels     map[int]models.Label
+	taskLabels map[taskLabel]struct{}
+	deps       map[dependency]struct{}
+	lastTask   int
+	lastUser   int
+	lastLabel  int
+	// audit is only ever appended to, so a clone can share it: appends made
+	// after the clone are beyond the length the clone saw.
+	audit []models.AuditEntry
+}
+
+func (d data) clone() data {
+	d.tasks = maps.Clone(d.tasks)
+	d.users = maps.Clone(d.users)
+	d.labels = maps.Clone(d.labels)
+	d.taskLabels = maps.Clone(d.taskLabels)
+	d.deps = maps.Clone(d.deps)
+	return d
+}
+
+// Store implements the task, user and audit stores, and runs units of work
+// for the services. Units of work and writes run one at a time, so reads
+// made inside a unit of work need no locks of their own.
+type Store struct {
+	// sem is held by the unit of work or write in progress.
+	sem chan struct{}
+	// mu guards data against reads made outside a unit of work.
+	mu   sync.Mutex
+	data data
+}
+
+func New() *Store {
+	return &Store{
+		sem: make(chan struct{}, 1),
+		data: data{
+			tasks:      map[int]models.Task{},
+			users:      map[int]user{},
+			labels:     map[int]models.Label{},
+			taskLabels: map[taskLabel]struct{}{},
+			deps:       map[dependency]struct{}{},
+		},
+	}
+}
+
+type txKey struct{}
+
+func (s *Store) inTx(ctx context.Context) bool {
+	return ctx.Value(txKey{}) == s
+}
+
+// InTx runs fn as one unit of work: its changes are undone if it returns an
+// error. When ctx is already in a unit of work of s, fn joins it instead.
+func (s *Store) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.inTx(ctx) {
+		return fn(ctx)
+	}
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.sem }()
+
+	s.mu.Lock()
+	saved := s.data.clone()
+	s.mu.Unlock()
+
+	if err := fn(context.WithValue(ctx, txKey{}, s)); err != nil {
+		s.mu.Lock()
+		s.data = saved
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// read runs fn with the data locked.
+func (s *Store) read(fn func(d *data)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.data)
+}
+
+// write runs fn with the data locked, in the unit of work of ctx or else
+// after any unit of work in progress. fn must change nothing when it
+// returns an error.
+func (s *Store) write(ctx context.Context, fn func(d *data) error) error {
+	if !s.inTx(ctx) {
+		select {
+		case s.sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() { <-s.sem }()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(&s.data)
+}
+
+// errNoUser is what a task of a user that does not exist gets, where MySQL
+// would fail the foreign key.
+func errNoUser(id int) error {
+	return fmt.Errorf("memstore: user %d does not exist", id)
+}
+
+// checkParent fails for a parent of t that does not exist, where MySQL
+// would fail the foreign key.
+func (d *data) checkParent(t models.Task) error {
+	if _, ok := d.tasks[t.ParentID]; t.ParentID != 0 && !ok {
+		return fmt.Errorf("memstore: task %d does not exist", t.ParentID)
+	}
+	return nil
+}
+
+// CreateTask stores t and returns it with a new ID, at version 1.
+func (s *Store) CreateTask(ctx context.Context, t models.Task) (models.Task, error) {
+	err := s.write(ctx, func(d *data) error {
+		if _, ok := d.users[t.UserID]; !ok {
+			return errNoUser(t.UserID)
+		}
+		if err := d.checkParent(t); err != nil {
+			return err
+		}
+		d.lastTask++
+		now := time.Now().UTC()
+		t.ID = d.lastTask
+		t.Version = 1
+		t.CreatedAt, t.UpdatedAt, t.CompletedAt = now, now, time.Time{}
+		if t.Completed {
+			t.CompletedAt = now
+		}
+		d.tasks[t.ID] = t
+		return nil
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+	return t, nil
+}
+
+// GetTask returns the task with id, or sql.ErrNoRows if there is none or it
+// is in the trash.
+func (s *Store) GetTask(ctx context.Context, id int) (models.Task, error) {
+	var t models.Task
+	ok := false
+	s.read(func(d *data) { t, ok = d.tasks[id] })
+	if !ok || !t.DeletedAt.IsZero() {
+		return models.Task{}, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+// GetTaskForUpdate is GetTask: units of work already run one at a time.
+func (s *Store) GetTaskForUpdate(ctx context.Context, id int) (models.Task, error) {
+	return s.GetTask(ctx, id)
+}
+
+// ViewTasks returns the page of tasks selected by f and the number of tasks
+// that match f's filters across all pages. Tasks are overdue as of now.
+func (s *Store) ViewTasks(ctx context.Context, f models.TaskFilter) ([]models.Task, int, error) {
+	var tasks []models.Task
+	now := time.Now().UTC()
+	s.read(func(d *data) {
+		for _, t := range d.tasks {
+			if d.taskMatches(t, f, now) {
+				tasks = append(tasks, t)
+			}
+		}
+	})
+	slices.SortFunc(tasks, taskOrder(f.Sort))
+	return page(tasks, f.Limit, f.Offset), len(tasks), nil
+}
+
+// taskMatches reports whether t passes f's filters, with open tasks due
+// before now counting as overdue. Search ignores case, as MySQL's collation
+// does, and a completion bound never matches an open task, as NULL never
+// compares true.
+func (d *data) taskMatches(t models.Task, f models.TaskFilter, now time.Time) bool {
+	if t.DeletedAt.IsZero() == f.Deleted {
+		return false
+	}
+	if !after(t.CreatedAt, f.CreatedAfter) || !before(t.CreatedAt, f.CreatedBefore) {
+		return false
+	}
+	if !f.CompletedAfter.IsZero() || !f.CompletedBefore.IsZero() {
+		if t.CompletedAt.IsZero() || !after(t.CompletedAt, f.CompletedAfter) || !before(t.CompletedAt, f.CompletedBefore) {
+			return false
+		}
+	}
+	if f.Completed != nil && t.Completed != *f.Completed {
+		return false
+	}
+	if f.UserID != nil && t.UserID != *f.UserID {
+		return false
+	}
+	if f.Priority != "" && t.Priority != f.Priority {
+		return false
+	}
+	if f.LabelID != nil {
+		if _, ok := d.taskLabels[taskLabel{t.ID, *f.LabelID}]; !ok {
+			return false
+		}
+	}
+	overdue := !t.DueAt.IsZero() && t.DueAt.Before(now) && !t.Completed
+	if f.Overdue != nil && overdue != *f.Overdue {
+		return false
+	}
+	return strings.Contains(strings.ToLower(t.Task), strings.ToLower(f.Search))
+}
+
+// after reports whether t is after bound, or bound is zero.
+func after(t, bound time.Time) bool {
+	return bound.IsZero() || t.After(bound)
+}
+
+// before reports whether t is before bound, or bound is zero.
+func before(t, bound time.Time) bool {
+	return bound.IsZero() || t.Before(bound)
+}
+
+// taskOrder compares tasks by a sort key as the MySQL store orders them:
+// known columns with ties broken by id, anything else by id. Tasks without
+// a due date come last by due_at either way, as NULL sorts in MySQL once
+// "due_at IS NULL" is ordered first.
+func taskOrder(sort string) func(a, b models.Task) int {
+	desc := strings.HasPrefix(sort, "-")
+	var key func(a, b models.Task) int
+	switch strings.TrimPrefix(sort, "-") {
+	case "due_at":
+		return func(a, b models.Task) int {
+			if c := compareBool(a.DueAt.IsZero(), b.DueAt.IsZero()); c != 0 {
+				return c
+			}
+			c := a.DueAt.Compare(b.DueAt)
+			if desc {
+				c = -c
+			}
+			return cmp.Or(c, cmp.Compare(a.ID, b.ID))
+		}
+	case "task":
+		key = func(a, b models.Task) int { return strings.Compare(a.Task, b.Task) }
+	case "completed":
+		key = func(a, b models.Task) int { return compareBool(a.Completed, b.Completed) }
+	case "user_id":
+		key = func(a, b models.Task) int { return cmp.Compare(a.UserID, b.UserID) }
+	default:
+		if desc {
+			return func(a, b models.Task) int { return cmp.Compare(b.ID, a.ID) }
+		}
+		return func(a, b models.Task) int { return cmp.Compare(a.ID, b.ID) }
+	}
+	return func(a, b models.Task) int {
+		c := key(a, b)
+		if desc {
+			c = -c
+		}
+		return cmp.Or(c, cmp.Compare(a.ID, b.ID))
+	}
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// page returns the items of s that LIMIT limit OFFSET offset would, never
+// nil.
+func page[T any](s []T, limit, offset int) []T {
+	start := min(offset, len(s))
+	end := min(start+limit, len(s))
+	return append([]T{}, s[start:end]...)
+}
+
+// UpdateTask replaces the task with t.ID if it is still at t.Version, and
+// returns it at the next version. Otherwise it returns
+// models.ErrPreconditionFailed, or sql.ErrNoRows if there is no such task
+// outside the trash.
+func (s *Store) UpdateTask(ctx context.Context, t models.Task) (models.Task, error) {
+	err := s.write(ctx, func(d *data) error {
+		old, ok := d.tasks[t.ID]
+		if !ok || !old.DeletedAt.IsZero() {
+			return sql.ErrNoRows
+		}
+		if old.Version != t.Version {
+			return models.ErrPreconditionFailed
+		}
+		if _, ok := d.users[t.UserID]; !ok {
+			return errNoUser(t.UserID)
+		}
+		if err := d.checkParent(t); err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		t.CreatedAt, t.UpdatedAt, t.CompletedAt = old.CreatedAt, now, old.CompletedAt
+		switch {
+		case !t.Completed:
+			t.CompletedAt = time.Time{}
+		case !old.Completed:
+			t.CompletedAt = now
+		}
+		t.Version++
+		d.tasks[t.ID] = t
+		return nil
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+	return t, nil
+}
+
+// DeleteTask moves the task with id to the trash, at its next version.
+func (s *Store) DeleteTask(ctx context.Context, id int) error {
+	return s.write(ctx, func(d *data) error {
+		if t, ok := d.tasks[id]; ok && t.DeletedAt.IsZero() {
+			t.DeletedAt = time.Now().UTC()
+			t.UpdatedAt = t.DeletedAt
+			t.Version++
+			d.tasks[id] = t
+		}
+		return nil
+	})
+}
+
+// GetDeletedTaskForUpdate returns the task with id from the trash, or
+// sql.ErrNoRows.
+func (s *Store) GetDeletedTaskForUpdate(ctx context.Context, id int) (models.Task, error) {
+	var t models.Task
+	ok := false
+	s.read(func(d *data) { t, ok = d.tasks[id] })
+	if !ok || t.DeletedAt.IsZero() {
+		return models.Task{}, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+// RestoreTask takes the task with id out of the trash and returns it at its
+// next version, or returns sql.ErrNoRows if it is not in the trash.
+func (s *Store) RestoreTask(ctx context.Context, id int) (models.Task, error) {
+	var t models.Task
+	err := s.write(ctx, func(d *data) error {
+		var ok bool
+		t, ok = d.tasks[id]
+		if !ok || t.DeletedAt.IsZero() {
+			return sql.ErrNoRows
+		}
+		t.DeletedAt = time.Time{}
+		t.UpdatedAt = time.Now().UTC()
+		t.Version++
+		d.tasks[id] = t
+		return nil
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+	return t, nil
+}
+
+// PurgeTasks deletes for good the tasks moved to the trash before before,
+// and returns how many there were. Their subtasks become top-level tasks at
+// their next version, and their dependencies go with them.
+func (s *Store) PurgeTasks(ctx context.Context, before time.Time) (int, error) {
+	n := 0
+	err := s.write(ctx, func(d *data) error {
+		for id, t := range d.tasks {
+			if !t.DeletedAt.IsZero() && t.DeletedAt.Before(before) {
+				d.deleteTask(id)
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// deleteTask deletes the task with id for good, with its labels and
+// dependencies as the foreign keys do in MySQL, and makes its subtasks
+// top-level tasks.
+func (d *data) deleteTask(id int) {
+	delete(d.tasks, id)
+	for tl := range d.taskLabels {
+		if tl.task == id {
+			delete(d.taskLabels, tl)
+		}
+	}
+	for dep := range d.deps {
+		if dep.task == id || dep.blocker == id {
+			delete(d.deps, dep)
+		}
+	}
+	now := time.Now().UTC()
+	for tid, t := range d.tasks {
+		if t.ParentID == id {
+			t.ParentID = 0
+			t.UpdatedAt = now
+			t.Version++
+			d.tasks[tid] = t
+		}
+	}
+}
+
+// CreateUser stores u, without its password, and returns it with a new ID.
+// A name that is taken, even by a user in the trash, is a conflict, as the
+// unique index makes it in SQL.
+func (s *Store) CreateUser(ctx context.Context, u models.User) (models.User, error) {
+	err := s.write(ctx, func(d *data) error {
+		if d.userNamed(u.Name, 0) {
+			return errNameTaken
+		}
+		d.lastUser++
+		u.ID = d.lastUser
+		d.users[u.ID] = user{User: models.User{ID: u.ID, Name: u.Name}, role: models.RoleUser}
+		return nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return u, nil
+}
+
+// errNameTaken reports a user name that is taken.
+var errNameTaken = models.Conflict("user name already taken")
+
+// userNamed reports whether a user other than the one with id is called
+// name.
+func (d *data) userNamed(name string, id int) bool {
+	for _, u := range d.users {
+		if u.Name == name && u.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+// getUser returns the user with id, or sql.ErrNoRows if there is none or
+// they are in the trash.
+func (s *Store) getUser(id int) (user, error) {
+	var u user
+	ok := false
+	s.read(func(d *data) { u, ok = d.users[id] })
+	if !ok || !u.DeletedAt.IsZero() {
+		return user{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (s *Store) GetUser(ctx context.Context, id int) (models.User, error) {
+	u, err := s.getUser(id)
+	return u.User, err
+}
+
+// GetUserForUpdate is GetUser: units of work already run one at a time.
+func (s *Store) GetUserForUpdate(ctx context.Context, id int) (models.User, error) {
+	return s.GetUser(ctx, id)
+}
+
+// GetUserForShare is GetUser: units of work already run one at a time.
+func (s *Store) GetUserForShare(ctx context.Context, id int) (models.User, error) {
+	return s.GetUser(ctx, id)
+}
+
+// userTasks returns the tasks of the user with id ordered by id, leaving out
+// those in the trash.
+func (d *data) userTasks(id int) []models.Task {
+	tasks := []models.Task{}
+	for _, tid := range slices.Sorted(maps.Keys(d.tasks)) {
+		if t := d.tasks[tid]; t.UserID == id && t.DeletedAt.IsZero() {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks
+}
+
+// GetUserStats returns the user with id and the counts of their open and
+// completed tasks, or sql.ErrNoRows.
+func (s *Store) GetUserStats(ctx context.Context, id int) (models.User, models.UserStats, error) {
+	u, tasks, err := s.GetUserWithTasks(ctx, id)
+	if err != nil {
+		return models.User{}, models.UserStats{}, err
+	}
+	var st models.UserStats
+	for _, t := range tasks {
+		if t.Completed {
+			st.Completed++
+		} else {
+			st.Open++
+		}
+	}
+	return u, st, nil
+}
+
+// GetUserWithTasks returns the user with id and all of their tasks ordered
+// by id, or sql.ErrNoRows.
+func (s *Store) GetUserWithTasks(ctx context.Context, id int) (models.User, []models.Task, error) {
+	var u user
+	var tasks []models.Task
+	ok := false
+	s.read(func(d *data) {
+		if u, ok = d.users[id]; ok && u.DeletedAt.IsZero() {
+			tasks = d.userTasks(id)
+		}
+	})
+	if !ok || !u.DeletedAt.IsZero() {
+		return models.User{}, nil, sql.ErrNoRows
+	}
+	return u.User, tasks, nil
+}
+
+// userByName returns the user called name, or sql.ErrNoRows. Should there
+// be several, it is the first.
+func (s *Store) userByName(name string) (user, error) {
+	var u user
+	ok := false
+	s.read(func(d *data) {
+		for _, id := range slices.Sorted(maps.Keys(d.users)) {
+			if d.users[id].Name == name {
+				u, ok = d.users[id], true
+				return
+			}
+		}
+	})
+	if !ok {
+		return user{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+// GetUserByName returns the user called name, or sql.ErrNoRows. A user in
+// the trash is returned too, as they keep their name until purged.
+func (s *Store) GetUserByName(ctx context.Context, name string) (models.User, error) {
+	u, err := s.userByName(name)
+	return u.User, err
+}
+
+// GetPasswordHash returns the user called name, as the principal they sign
+// in as, and their password hash, which is empty if they have no password,
+// or sql.ErrNoRows. Users in the trash cannot sign in.
+func (s *Store) GetPasswordHash(ctx context.Context, name string) (models.Principal, string, error) {
+	u, err := s.userByName(name)
+	if err == nil && !u.DeletedAt.IsZero() {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return models.Principal{}, "", err
+	}
+	return models.Principal{UserID: u.ID, Name: u.Name, Role: u.role}, u.hash, nil
+}
+
+// SetPasswordHash stores the password hash of the user with id.
+func (s *Store) SetPasswordHash(ctx context.Context, id int, hash string) error {
+	return s.write(ctx, func(d *data) error {
+		if u, ok := d.users[id]; ok {
+			u.hash = hash
+			d.users[id] = u
+		}
+		return nil
+	})
+}
+
+// ViewUsers returns the page of users selected by f, ordered by id, and the
+// total number of users, either those in the trash or the others.
+func (s *Store) ViewUsers(ctx context.Context, f models.UserFilter) ([]models.User, int, error) {
+	var users []models.User
+	s.read(func(d *data) {
+		for _, id := range slices.Sorted(maps.Keys(d.users)) {
+			if u := d.users[id].User; u.DeletedAt.IsZero() != f.Deleted {
+				users = append(users, u)
+			}
+		}
+	})
+	return page(users, f.Limit, f.Offset), len(users), nil
+}
+
+// UpdateUser renames the user with u.ID, if there is one. A name that is
+// taken is a conflict, as in CreateUser.
+func (s *Store) UpdateUser(ctx context.Context, u models.User) error {
+	return s.write(ctx, func(d *data) error {
+		if d.userNamed(u.Name, u.ID) {
+			return errNameTaken
+		}
+		if stored, ok := d.users[u.ID]; ok {
+			stored.Name = u.Name
+			d.users[u.ID] = stored
+		}
+		return nil
+	})
+}
+
+// CountTasks returns the number of tasks owned by the user with id, leaving
+// out those in the trash.
+func (s *Store) CountTasks(ctx context.Context, id int) (int, error) {
+	n := 0
+	s.read(func(d *data) {
+		for _, t := range d.tasks {
+			if t.UserID == id && t.DeletedAt.IsZero() {
+				n++
+			}
+		}
+	})
+	return n, nil
+}
+
+// LockUserTasks returns every task of the user with id ordered by id, those
+// in the trash included.
+func (s *Store) LockUserTasks(ctx context.Context, id int) ([]models.Task, error) {
+	tasks := []models.Task{}
+	s.read(func(d *data) {
+		for _, tid := range slices.Sorted(maps.Keys(d.tasks)) {
+			if t := d.tasks[tid]; t.UserID == id {
+				tasks = append(tasks, t)
+			}
+		}
+	})
+	return tasks, nil
+}
+
+// DeleteUser moves the user with id to the trash together with their
+// tasks, or, when reassignTo is set, after moving all their tasks, those in
+// the trash included, to that user.
+func (s *Store) DeleteUser(ctx context.Context, id, reassignTo int) error {
+	now := time.Now().UTC()
+	return s.write(ctx, func(d *data) error {
+		if _, ok := d.users[reassignTo]; reassignTo > 0 && !ok {
+			return errNoUser(reassignTo)
+		}
+		u, ok := d.users[id]
+		if !ok || !u.DeletedAt.IsZero() {
+			return nil
+		}
+		for tid, t := range d.tasks {
+			switch {
+			case t.UserID != id:
+				continue
+			case reassignTo > 0:
+				t.UserID = reassignTo
+			case t.DeletedAt.IsZero():
+				t.DeletedAt = now
+			default:
+				continue
+			}
+			t.UpdatedAt = now
+			t.Version++
+			d.tasks[tid] = t
+		}
+		u.DeletedAt = now
+		d.users[id] = u
+		return nil
+	})
+}
+
+// GetDeletedUserForUpdate returns the user with id from the trash, or
+// sql.ErrNoRows.
+func (s *Store) GetDeletedUserForUpdate(ctx context.Context, id int) (models.User, error) {
+	var u user
+	ok := false
+	s.read(func(d *data) { u, ok = d.users[id] })
+	if !ok || u.DeletedAt.IsZero() {
+		return models.User{}, sql.ErrNoRows
+	}
+	return u.User, nil
+}
+
+// RestoreUser takes the user with id out of the trash together with the
+// tasks that were deleted with them, or returns sql.ErrNoRows if they are
+// not in the trash.
+func (s *Store) RestoreUser(ctx context.Context, id int) error {
+	now := time.Now().UTC()
+	return s.write(ctx, func(d *data) error {
+		u, ok := d.users[id]
+		if !ok || u.DeletedAt.IsZero() {
+			return sql.ErrNoRows
+		}
+		for tid, t := range d.tasks {
+			if t.UserID == id && t.DeletedAt.Equal(u.DeletedAt) {
+				t.DeletedAt = time.Time{}
+				t.UpdatedAt = now
+				t.Version++
+				d.tasks[tid] = t
+			}
+		}
+		u.DeletedAt = time.Time{}
+		d.users[id] = u
+		return nil
+	})
+}
+
+// PurgeUsers deletes for good the users moved to the trash before before,
+// with all of their tasks, and returns how many users there were.
+func (s *Store) PurgeUsers(ctx context.Context, before time.Time) (int, error) {
+	n := 0
+	err := s.write(ctx, func(d *data) error {
+		for id, u := range d.users {
+			if u.DeletedAt.IsZero() || !u.DeletedAt.Before(before) {
+				continue
+			}
+			for tid, t := range d.tasks {
+				if t.UserID == id {
+					d.deleteTask(tid)
+				}
+			}
+			delete(d.users, id)
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// AppendAudit adds e to the end of the audit log with a new ID and the
+// current time.
+func (s *Store) AppendAudit(ctx context.Context, e models.AuditEntry) error {
+	return s.write(ctx, func(d *data) error {
+		e.ID = len(d.audit) + 1
+		e.Time = time.Now().UTC()
+		d.audit = append(d.audit, e)
+		return nil
+	})
+}
+
+// ViewAudit returns the page of audit entries selected by f, oldest first,
+// and the number of entries that match f across all pages.
+func (s *Store) ViewAudit(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, int, error) {
+	var entries []models.AuditEntry
+	s.read(func(d *data) {
+		for _, e := range d.audit {
+			if (f.Entity == "" || e.Entity == f.Entity) && (f.EntityID == 0 || e.EntityID == f.EntityID) {
+				entries = append(entries, e)
+			}
+		}
+	})
+	return page(entries, f.Limit, f.Offset), len(entries), nil
+}
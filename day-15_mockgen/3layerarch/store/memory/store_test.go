@@ -0,0 +1,15 @@
+package memstore_test
+
+import (
+	"testing"
+
+	memstore "3layerarch/store/memory"
+	"3layerarch/store/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		s := memstore.New()
+		return storetest.Stores{Tasks: s, Users: s, Audit: s, Tx: s}
+	})
+}
@@ -0,0 +1,46 @@
+package sqlitestore_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"3layerarch/migrate"
+	"3layerarch/store"
+	auditstore "3layerarch/store/audit"
+	sqlitestore "3layerarch/store/sqlite"
+	"3layerarch/store/storetest"
+	taskstore "3layerarch/store/task"
+	userstore "3layerarch/store/user"
+)
+
+// open returns the stores of a new SQLite database whose queries are
+// written in dialect.
+func open(t *testing.T, dialect store.Dialect) storetest.Stores {
+	db, err := sqlitestore.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open SQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrate.New(db, store.SQLite, migrate.SQLiteMigrations).Up(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return storetest.Stores{
+		Tasks: taskstore.New(db, dialect),
+		Users: userstore.New(db, dialect),
+		Audit: auditstore.New(db, dialect),
+		Tx:    store.NewTransactor(db),
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Stores { return open(t, store.SQLite) })
+}
+
+// TestConformance_PostgresDialect stands in for a PostgreSQL server: SQLite
+// takes the $n placeholders and RETURNING of the PostgreSQL dialect, just
+// not its row locks. TEST_POSTGRES_DSN runs the suite on the real thing.
+func TestConformance_PostgresDialect(t *testing.T) {
+	dialect := store.Postgres
+	dialect.ForUpdate, dialect.ForShare = "", ""
+	storetest.Run(t, func(t *testing.T) storetest.Stores { return open(t, dialect) })
+}
@@ -0,0 +1,1112 @@
+// Package storetest is the conformance suite of the task, label, user and
+// audit stores.
+// A store that passes it can stand in for the MySQL ones behind the
+// services.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"3layerarch/models"
+	auditservice "3layerarch/service/audit"
+	taskservice "3layerarch/service/task"
+	userservice "3layerarch/service/user"
+)
+
+// Stores is one implementation under test, all of its parts sharing the
+// same data.
+type Stores struct {
+	Tasks taskservice.TaskStore
+	Users userservice.UserStore
+	Audit auditservice.AuditStore
+	Tx    taskservice.Transactor
+}
+
+// Run runs the suite against the stores that open returns. open is called
+// once per test and must return stores holding no tasks, users or audit
+// entries.
+func Run(t *testing.T, open func(t *testing.T) Stores) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s Stores)
+	}{
+		{"Users", testUsers},
+		{"UserNotFound", testUserNotFound},
+		{"PasswordHash", testPasswordHash},
+		{"ViewUsers", testViewUsers},
+		{"Tasks", testTasks},
+		{"TaskOfMissingUser", testTaskOfMissingUser},
+		{"ViewTasks", testViewTasks},
+		{"TaskTimeFilters", testTaskTimeFilters},
+		{"TaskPlanning", testTaskPlanning},
+		{"Labels", testLabels},
+		{"TaskLabels", testTaskLabels},
+		{"Subtasks", testSubtasks},
+		{"Dependencies", testDependencies},
+		{"TaskGraph", testTaskGraph},
+		{"UserTasks", testUserTasks},
+		{"DeleteUser", testDeleteUser},
+		{"TaskTrash", testTaskTrash},
+		{"UserTrash", testUserTrash},
+		{"PurgeTrash", testPurgeTrash},
+		{"Audit", testAudit},
+		{"AuditRollback", testAuditRollback},
+		{"TxCommit", testTxCommit},
+		{"TxRollback", testTxRollback},
+		{"TxSerializes", testTxSerializes},
+		{"GraphReadsWait", testGraphReadsWait},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) { tc.fn(t, open(t)) })
+	}
+}
+
+func createUser(t *testing.T, s Stores, name string) models.User {
+	t.Helper()
+	u, err := s.Users.CreateUser(context.Background(), models.User{Name: name})
+	if err != nil {
+		t.Fatalf("failed to create user %s: %v", name, err)
+	}
+	return u
+}
+
+func createTask(t *testing.T, s Stores, task models.Task) models.Task {
+	t.Helper()
+	created, err := s.Tasks.CreateTask(context.Background(), task)
+	if err != nil {
+		t.Fatalf("failed to create task %q: %v", task.Task, err)
+	}
+	return created
+}
+
+func testUsers(t *testing.T, s Stores) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	if alice.ID == 0 || alice.ID == bob.ID {
+		t.Fatalf("expected distinct IDs, got %d and %d", alice.ID, bob.ID)
+	}
+
+	gets := map[string]func(context.Context, int) (models.User, error){
+		"GetUser":          s.Users.GetUser,
+		"GetUserForUpdate": s.Users.GetUserForUpdate,
+		"GetUserForShare":  s.Users.GetUserForShare,
+	}
+	for name, get := range gets {
+		if u, err := get(ctx, alice.ID); err != nil || u != alice {
+			t.Errorf("%s: expected %+v, got %+v, err: %v", name, alice, u, err)
+		}
+	}
+	if u, err := s.Users.GetUserByName(ctx, "bob"); err != nil || u != bob {
+		t.Errorf("GetUserByName: expected %+v, got %+v, err: %v", bob, u, err)
+	}
+
+	alice.Name = "alicia"
+	if err := s.Users.UpdateUser(ctx, alice); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if u, err := s.Users.GetUser(ctx, alice.ID); err != nil || u != alice {
+		t.Errorf("expected the renamed user %+v, got %+v, err: %v", alice, u, err)
+	}
+
+	// Names are unique even when the service's own check is raced past
+	if _, err := s.Users.CreateUser(ctx, models.User{Name: "bob"}); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("expected a second bob to be a conflict, got %v", err)
+	}
+	if err := s.Users.UpdateUser(ctx, models.User{ID: alice.ID, Name: "bob"}); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("expected renaming alicia to bob to be a conflict, got %v", err)
+	}
+}
+
+func testUserNotFound(t *testing.T, s Stores) {
+	ctx := context.Background()
+	checks := map[string]func() error{
+		"GetUser": func() error { _, err := s.Users.GetUser(ctx, 404); return err },
+		"GetUserForUpdate": func() error {
+			_, err := s.Users.GetUserForUpdate(ctx, 404)
+			return err
+		},
+		"GetUserForShare": func() error {
+			_, err := s.Users.GetUserForShare(ctx, 404)
+			return err
+		},
+		"GetUserByName": func() error { _, err := s.Users.GetUserByName(ctx, "nobody"); return err },
+		"GetPasswordHash": func() error {
+			_, _, err := s.Users.GetPasswordHash(ctx, "nobody")
+			return err
+		},
+		"GetUserStats": func() error {
+			_, _, err := s.Users.GetUserStats(ctx, 404)
+			return err
+		},
+		"GetUserWithTasks": func() error {
+			_, _, err := s.Users.GetUserWithTasks(ctx, 404)
+			return err
+		},
+	}
+	for name, check := range checks {
+		if err := check(); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s: expected sql.ErrNoRows, got %v", name, err)
+		}
+	}
+}
+
+func testPasswordHash(t *testing.T, s Stores) {
+	ctx := context.Background()
+	u := createUser(t, s, "alice")
+
+	p, hash, err := s.Users.GetPasswordHash(ctx, "alice")
+	want := models.Principal{UserID: u.ID, Name: "alice", Role: models.RoleUser}
+	if err != nil || p != want || hash != "" {
+		t.Errorf("expected %+v without a hash, got %+v %q, err: %v", want, p, hash, err)
+	}
+
+	if err := s.Users.SetPasswordHash(ctx, u.ID, "$2a$10$hash"); err != nil {
+		t.Fatalf("SetPasswordHash: %v", err)
+	}
+	if _, hash, err := s.Users.GetPasswordHash(ctx, "alice"); err != nil || hash != "$2a$10$hash" {
+		t.Errorf("expected the stored hash, got %q, err: %v", hash, err)
+	}
+}
+
+func testViewUsers(t *testing.T, s Stores) {
+	var users []models.User
+	for _, name := range []string{"a", "b", "c"} {
+		users = append(users, createUser(t, s, name))
+	}
+
+	page, total, err := s.Users.ViewUsers(context.Background(), models.UserFilter{Limit: 2, Offset: 1})
+	if err != nil || total != 3 || !reflect.DeepEqual(page, users[1:]) {
+		t.Errorf("expected %+v of 3, got %+v of %d, err: %v", users[1:], page, total, err)
+	}
+
+	page, _, err = s.Users.ViewUsers(context.Background(), models.UserFilter{Limit: 2, Offset: 3})
+	if err != nil || page == nil || len(page) != 0 {
+		t.Errorf("expected an empty page past the end, got %#v, err: %v", page, err)
+	}
+}
+
+func testTasks(t *testing.T, s Stores) {
+	ctx := context.Background()
+	u := createUser(t, s, "alice")
+	other := createUser(t, s, "bob")
+	task := createTask(t, s, models.Task{Task: "write tests", UserID: u.ID})
+	if task.ID == 0 || task.Version != 1 {
+		t.Fatalf("expected the created task to have an ID at version 1, got %+v", task)
+	}
+	if task.CreatedAt.IsZero() || !task.UpdatedAt.Equal(task.CreatedAt) || !task.CompletedAt.IsZero() {
+		t.Errorf("expected the created task to have been created and updated just now, got %+v", task)
+	}
+
+	for name, get := range map[string]func(context.Context, int) (models.Task, error){
+		"GetTask":          s.Tasks.GetTask,
+		"GetTaskForUpdate": s.Tasks.GetTaskForUpdate,
+	} {
+		if got, err := get(ctx, task.ID); err != nil || got != task {
+			t.Errorf("%s: expected %+v, got %+v, err: %v", name, task, got, err)
+		}
+		if _, err := get(ctx, 404); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s: expected sql.ErrNoRows for a missing task, got %v", name, err)
+		}
+	}
+
+	created := task
+	task.Task, task.Completed, task.UserID = "write more tests", true, other.ID
+	task, err := s.Tasks.UpdateTask(ctx, task)
+	if err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if task.Version != 2 || !task.CreatedAt.Equal(created.CreatedAt) || task.UpdatedAt.Before(created.UpdatedAt) || !task.CompletedAt.Equal(task.UpdatedAt) {
+		t.Errorf("expected the task at version 2, completed when it was updated, got %+v", task)
+	}
+	if got, err := s.Tasks.GetTask(ctx, task.ID); err != nil || got != task {
+		t.Errorf("expected the updated task %+v, got %+v, err: %v", task, got, err)
+	}
+
+	// Completing it again keeps when it was first completed
+	renamed := task
+	renamed.Task = "write even more tests"
+	if renamed, err = s.Tasks.UpdateTask(ctx, renamed); err != nil || !renamed.CompletedAt.Equal(task.CompletedAt) {
+		t.Errorf("expected the completion time %v to be kept, got %+v, err: %v", task.CompletedAt, renamed, err)
+	}
+	reopened := renamed
+	reopened.Completed = false
+	if reopened, err = s.Tasks.UpdateTask(ctx, reopened); err != nil || !reopened.CompletedAt.IsZero() {
+		t.Errorf("expected a reopened task to have no completion time, got %+v, err: %v", reopened, err)
+	}
+	task = reopened
+
+	// An update made against an older version changes nothing
+	stale := task
+	stale.Version, stale.Task = 1, "lost update"
+	if _, err := s.Tasks.UpdateTask(ctx, stale); !errors.Is(err, models.ErrPreconditionFailed) {
+		t.Errorf("expected models.ErrPreconditionFailed for a stale update, got %v", err)
+	}
+	if got, err := s.Tasks.GetTask(ctx, task.ID); err != nil || got != task {
+		t.Errorf("expected the task to be unchanged %+v, got %+v, err: %v", task, got, err)
+	}
+	missing := task
+	missing.ID = 404
+	if _, err := s.Tasks.UpdateTask(ctx, missing); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows updating a missing task, got %v", err)
+	}
+
+	if err := s.Tasks.DeleteTask(ctx, task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if _, err := s.Tasks.GetTask(ctx, task.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the task to be deleted, got %v", err)
+	}
+	if _, err := s.Tasks.UpdateTask(ctx, task); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows updating a deleted task, got %v", err)
+	}
+}
+
+func testTaskOfMissingUser(t *testing.T, s Stores) {
+	if _, err := s.Tasks.CreateTask(context.Background(), models.Task{Task: "orphan", UserID: 404}); err == nil {
+		t.Error("expected a task of a missing user to be refused")
+	}
+}
+
+func testViewTasks(t *testing.T, s Stores) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	t1 := createTask(t, s, models.Task{Task: "buy milk", UserID: alice.ID})
+	t2 := createTask(t, s, models.Task{Task: "50%_off sale", Completed: true, UserID: alice.ID})
+	t3 := createTask(t, s, models.Task{Task: "500 offers", UserID: bob.ID})
+	t4 := createTask(t, s, models.Task{Task: "buy milk", Completed: true, UserID: bob.ID})
+
+	yes, aliceID := true, alice.ID
+	tests := []struct {
+		desc   string
+		filter models.TaskFilter
+		want   []models.Task
+		total  int
+	}{
+		{"all by id", models.TaskFilter{Limit: 10}, []models.Task{t1, t2, t3, t4}, 4},
+		{"newest first", models.TaskFilter{Sort: "-id", Limit: 10}, []models.Task{t4, t3, t2, t1}, 4},
+		{"a page", models.TaskFilter{Limit: 2, Offset: 1}, []models.Task{t2, t3}, 4},
+		{"past the end", models.TaskFilter{Limit: 2, Offset: 4}, []models.Task{}, 4},
+		{"completed", models.TaskFilter{Completed: &yes, Limit: 10}, []models.Task{t2, t4}, 2},
+		{"by user", models.TaskFilter{UserID: &aliceID, Limit: 1}, []models.Task{t1}, 2},
+		{"wildcards match literally", models.TaskFilter{Search: "50%_", Limit: 10}, []models.Task{t2}, 1},
+		{"search ignores case", models.TaskFilter{Search: "MILK", Limit: 10}, []models.Task{t1, t4}, 2},
+		{"by task, ties by id", models.TaskFilter{Sort: "task", Limit: 10}, []models.Task{t2, t3, t1, t4}, 4},
+		{"by task descending, ties by id", models.TaskFilter{Sort: "-task", Limit: 10}, []models.Task{t1, t4, t3, t2}, 4},
+		{"by completed", models.TaskFilter{Sort: "-completed", Limit: 10}, []models.Task{t2, t4, t1, t3}, 4},
+		{"by user descending", models.TaskFilter{Sort: "-user_id", Limit: 10}, []models.Task{t3, t4, t1, t2}, 4},
+	}
+	for _, tc := range tests {
+		tasks, total, err := s.Tasks.ViewTasks(ctx, tc.filter)
+		if err != nil || total != tc.total || !reflect.DeepEqual(tasks, tc.want) {
+			t.Errorf("%s: expected %+v of %d, got %+v of %d, err: %v", tc.desc, tc.want, tc.total, tasks, total, err)
+		}
+	}
+}
+
+func testTaskTimeFilters(t *testing.T, s Stores) {
+	ctx := context.Background()
+	u := createUser(t, s, "alice")
+	var tasks []models.Task
+	for _, completed := range []bool{true, true, false} {
+		// Far enough apart for every store to tell them apart
+		time.Sleep(2 * time.Millisecond)
+		tasks = append(tasks, createTask(t, s, models.Task{Task: "task", Completed: completed, UserID: u.ID}))
+	}
+	t1, t2, t3 := tasks[0], tasks[1], tasks[2]
+	later := t3.CreatedAt.Add(time.Hour)
+
+	tests := []struct {
+		desc   string
+		filter models.TaskFilter
+		want   []models.Task
+	}{
+		{"created after", models.TaskFilter{CreatedAfter: t1.CreatedAt}, []models.Task{t2, t3}},
+		{"created before", models.TaskFilter{CreatedBefore: t3.CreatedAt}, []models.Task{t1, t2}},
+		{"created between", models.TaskFilter{CreatedAfter: t1.CreatedAt, CreatedBefore: t3.CreatedAt}, []models.Task{t2}},
+		{"completed after", models.TaskFilter{CompletedAfter: t1.CompletedAt}, []models.Task{t2}},
+		{"completed before", models.TaskFilter{CompletedBefore: later}, []models.Task{t1, t2}},
+		{"in another zone", models.TaskFilter{CreatedAfter: t2.CreatedAt.In(time.FixedZone("UTC+2", 2*60*60))}, []models.Task{t3}},
+	}
+	for _, tc := range tests {
+		tc.filter.Limit = 10
+		got, total, err := s.Tasks.ViewTasks(ctx, tc.filter)
+		if err != nil || total != len(tc.want) || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %+v, got %+v of %d, err: %v", tc.desc, tc.want, got, total, err)
+		}
+	}
+}
+
+func testTaskPlanning(t *testing.T, s Stores) {
+	ctx := context.Background()
+	u := createUser(t, s, "alice")
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	yesterday, tomorrow := now.AddDate(0, 0, -1), now.AddDate(0, 0, 1)
+	late := createTask(t, s, models.Task{Task: "late", UserID: u.ID, Priority: models.PriorityHigh, DueAt: yesterday})
+	done := createTask(t, s, models.Task{Task: "done", Completed: true, UserID: u.ID, Priority: models.PriorityHigh, DueAt: yesterday.Add(-time.Hour)})
+	soon := createTask(t, s, models.Task{Task: "soon", UserID: u.ID, Priority: models.PriorityLow, DueAt: tomorrow})
+	someday := createTask(t, s, models.Task{Task: "someday", UserID: u.ID})
+
+	if got, err := s.Tasks.GetTask(ctx, late.ID); err != nil || got != late {
+		t.Errorf("expected %+v, got %+v, err: %v", late, got, err)
+	}
+
+	// The priority and due date can be changed and removed
+	changed := soon
+	changed.Priority, changed.DueAt = models.PriorityUrgent, tomorrow.Add(time.Hour)
+	changed, err := s.Tasks.UpdateTask(ctx, changed)
+	if err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if got, err := s.Tasks.GetTask(ctx, soon.ID); err != nil || got != changed {
+		t.Errorf("expected %+v, got %+v, err: %v", changed, got, err)
+	}
+	cleared := changed
+	cleared.Priority, cleared.DueAt = "", time.Time{}
+	if cleared, err = s.Tasks.UpdateTask(ctx, cleared); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if got, err := s.Tasks.GetTask(ctx, soon.ID); err != nil || got.Priority != "" || !got.DueAt.IsZero() {
+		t.Errorf("expected no priority or due date, got %+v, err: %v", got, err)
+	}
+	soon = cleared
+	soon.Priority, soon.DueAt = models.PriorityLow, tomorrow
+	if soon, err = s.Tasks.UpdateTask(ctx, soon); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+
+	yes, no := true, false
+	tests := []struct {
+		desc   string
+		filter models.TaskFilter
+		want   []models.Task
+	}{
+		{"by priority", models.TaskFilter{Priority: models.PriorityHigh}, []models.Task{late, done}},
+		{"overdue", models.TaskFilter{Overdue: &yes}, []models.Task{late}},
+		{"not overdue", models.TaskFilter{Overdue: &no}, []models.Task{done, soon, someday}},
+		{"by due date, undated last", models.TaskFilter{Sort: "due_at"}, []models.Task{done, late, soon, someday}},
+		{"by due date descending, undated last", models.TaskFilter{Sort: "-due_at"}, []models.Task{soon, late, done, someday}},
+	}
+	for _, tc := range tests {
+		tc.filter.Limit = 10
+		got, total, err := s.Tasks.ViewTasks(ctx, tc.filter)
+		if err != nil || total != len(tc.want) || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %+v, got %+v of %d, err: %v", tc.desc, tc.want, got, total, err)
+		}
+	}
+}
+
+func testLabels(t *testing.T, s Stores) {
+	ctx := context.Background()
+	work, err := s.Tasks.CreateLabel(ctx, models.Label{Name: "work"})
+	if err != nil || work.ID == 0 {
+		t.Fatalf("expected the created label to have an ID, got %+v, err: %v", work, err)
+	}
+	home, err := s.Tasks.CreateLabel(ctx, models.Label{Name: "home"})
+	if err != nil {
+		t.Fatalf("CreateLabel: %v", err)
+	}
+	if _, err := s.Tasks.CreateLabel(ctx, models.Label{Name: "work"}); err == nil {
+		t.Error("expected a second label called work to be refused")
+	}
+
+	if got, err := s.Tasks.GetLabel(ctx, work.ID); err != nil || got != work {
+		t.Errorf("GetLabel: expected %+v, got %+v, err: %v", work, got, err)
+	}
+	if got, err := s.Tasks.GetLabelByName(ctx, "home"); err != nil || got != home {
+		t.Errorf("GetLabelByName: expected %+v, got %+v, err: %v", home, got, err)
+	}
+	if _, err := s.Tasks.GetLabel(ctx, 404); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing label, got %v", err)
+	}
+	if _, err := s.Tasks.GetLabelByName(ctx, "nowhere"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing name, got %v", err)
+	}
+	if got, err := s.Tasks.ViewLabels(ctx); err != nil || !reflect.DeepEqual(got, []models.Label{home, work}) {
+		t.Errorf("expected the labels by name, got %+v, err: %v", got, err)
+	}
+
+	work.Name = "office"
+	if err := s.Tasks.UpdateLabel(ctx, work); err != nil {
+		t.Fatalf("UpdateLabel: %v", err)
+	}
+	if got, err := s.Tasks.GetLabel(ctx, work.ID); err != nil || got != work {
+		t.Errorf("expected the renamed label %+v, got %+v, err: %v", work, got, err)
+	}
+
+	if err := s.Tasks.DeleteLabel(ctx, home.ID); err != nil {
+		t.Fatalf("DeleteLabel: %v", err)
+	}
+	if got, err := s.Tasks.ViewLabels(ctx); err != nil || !reflect.DeepEqual(got, []models.Label{work}) {
+		t.Errorf("expected only %+v left, got %+v, err: %v", work, got, err)
+	}
+}
+
+func testTaskLabels(t *testing.T, s Stores) {
+	ctx := context.Background()
+	u := createUser(t, s, "alice")
+	t1 := createTask(t, s, models.Task{Task: "first", UserID: u.ID})
+	t2 := createTask(t, s, models.Task{Task: "second", UserID: u.ID})
+	work, err := s.Tasks.CreateLabel(ctx, models.Label{Name: "work"})
+	if err != nil {
+		t.Fatalf("CreateLabel: %v", err)
+	}
+	home, err := s.Tasks.CreateLabel(ctx, models.Label{Name: "home"})
+	if err != nil {
+		t.Fatalf("CreateLabel: %v", err)
+	}
+
+	// Adding a label twice is the same as adding it once
+	for _, tl := range [][2]int{{t1.ID, work.ID}, {t1.ID, home.ID}, {t1.ID, work.ID}, {t2.ID, work.ID}} {
+		if err := s.Tasks.AddTaskLabel(ctx, tl[0], tl[1]); err != nil {
+			t.Fatalf("AddTaskLabel(%d, %d): %v", tl[0], tl[1], err)
+		}
+	}
+	if got, err := s.Tasks.TaskLabels(ctx, t1.ID); err != nil || !reflect.DeepEqual(got, []models.Label{home, work}) {
+		t.Errorf("expected both labels by name, got %+v, err: %v", got, err)
+	}
+	labelID := work.ID
+	if got, _, err := s.Tasks.ViewTasks(ctx, models.TaskFilter{LabelID: &labelID, Limit: 10}); err != nil || !reflect.DeepEqual(got, []models.Task{t1, t2}) {
+		t.Errorf("expected both tasks labelled work, got %+v, err: %v", got, err)
+	}
+
+	if err := s.Tasks.RemoveTaskLabel(ctx, t1.ID, home.ID); err != nil {
+		t.Fatalf("RemoveTaskLabel: %v", err)
+	}
+	if err := s.Tasks.RemoveTaskLabel(ctx, t1.ID, home.ID); err != nil {
+		t.Errorf("expected removing a label the task lacks to do nothing, got %v", err)
+	}
+	if got, err := s.Tasks.TaskLabels(ctx, t1.ID); err != nil || !reflect.DeepEqual(got, []models.Label{work}) {
+		t.Errorf("expected only work left, got %+v, err: %v", got, err)
+	}
+
+	// Deleting a label takes it off its tasks
+	if err := s.Tasks.DeleteLabel(ctx, work.ID); err != nil {
+		t.Fatalf("DeleteLabel: %v", err)
+	}
+	if got, err := s.Tasks.TaskLabels(ctx, t2.ID); err != nil || len(got) != 0 {
+		t.Errorf("expected no labels left, got %+v, err: %v", got, err)
+	}
+
+	// Purging a labelled task takes its labels with it
+	if err := s.Tasks.AddTaskLabel(ctx, t2.ID, home.ID); err != nil {
+		t.Fatalf("AddTaskLabel: %v", err)
+	}
+	if err := s.Tasks.DeleteTask(ctx, t2.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if n, err := s.Tasks.PurgeTasks(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Fatalf("expected the labelled task purged, got %d, err: %v", n, err)
+	}
+	if got, err := s.Tasks.TaskLabels(ctx, t2.ID); err != nil || len(got) != 0 {
+		t.Errorf("expected the purged task's labels gone, got %+v, err: %v", got, err)
+	}
+	if _, err := s.Tasks.GetLabel(ctx, home.ID); err != nil {
+		t.Errorf("expected the label to stay, got %v", err)
+	}
+}
+
+func testSubtasks(t *testing.T, s Stores) {
+	ctx := context.Background()
+	u := createUser(t, s, "alice")
+	parent := createTask(t, s, models.Task{Task: "parent", UserID: u.ID})
+	first := createTask(t, s, models.Task{Task: "first", UserID: u.ID, ParentID: parent.ID})
+	second := createTask(t, s, models.Task{Task: "second", UserID: u.ID, ParentID: parent.ID})
+
+	if first.ParentID != parent.ID {
+		t.Errorf("expected the subtask's parent to be %d, got %+v", parent.ID, first)
+	}
+	if got, err := s.Tasks.GetTask(ctx, first.ID); err != nil || got != first {
+		t.Errorf("expected %+v, got %+v, err: %v", first, got, err)
+	}
+	if got, err := s.Tasks.Subtasks(ctx, parent.ID); err != nil || !reflect.DeepEqual(got, []models.Task{first, second}) {
+		t.Errorf("expected both subtasks, got %+v, err: %v", got, err)
+	}
+	if id, err := s.Tasks.GetParentID(ctx, second.ID); err != nil || id != parent.ID {
+		t.Errorf("expected parent %d, got %d, err: %v", parent.ID, id, err)
+	}
+	if id, err := s.Tasks.GetParentID(ctx, parent.ID); err != nil || id != 0 {
+		t.Errorf("expected no parent, got %d, err: %v", id, err)
+	}
+	if _, err := s.Tasks.GetParentID(ctx, second.ID+100); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing task, got %v", err)
+	}
+
+	// Moving a subtask to the top level
+	second.ParentID = 0
+	moved, err := s.Tasks.UpdateTask(ctx, second)
+	if err != nil || moved.ParentID != 0 {
+		t.Fatalf("expected a top-level task, got %+v, err: %v", moved, err)
+	}
+
+	// A subtask in the trash is left out, but keeps its parent
+	if err := s.Tasks.DeleteTask(ctx, first.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if got, err := s.Tasks.Subtasks(ctx, parent.ID); err != nil || len(got) != 0 {
+		t.Errorf("expected no subtasks listed, got %+v, err: %v", got, err)
+	}
+	if id, err := s.Tasks.GetParentID(ctx, first.ID); err != nil || id != parent.ID {
+		t.Errorf("expected the deleted subtask to keep parent %d, got %d, err: %v", parent.ID, id, err)
+	}
+	if first, err = s.Tasks.RestoreTask(ctx, first.ID); err != nil {
+		t.Fatalf("RestoreTask: %v", err)
+	}
+
+	// Purging a parent makes its subtasks top-level, at their next version
+	if err := s.Tasks.DeleteTask(ctx, parent.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if n, err := s.Tasks.PurgeTasks(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Fatalf("expected the parent purged, got %d, err: %v", n, err)
+	}
+	got, err := s.Tasks.GetTask(ctx, first.ID)
+	if err != nil || got.ParentID != 0 || got.Version != first.Version+1 {
+		t.Errorf("expected %+v top-level at its next version, got %+v, err: %v", first, got, err)
+	}
+}
+
+func testDependencies(t *testing.T, s Stores) {
+	ctx := context.Background()
+	u := createUser(t, s, "alice")
+	build := createTask(t, s, models.Task{Task: "build", UserID: u.ID})
+	design := createTask(t, s, models.Task{Task: "design", UserID: u.ID})
+	buy := createTask(t, s, models.Task{Task: "buy", UserID: u.ID})
+
+	// Adding a dependency twice is the same as adding it once
+	for _, d := range [][2]int{{build.ID, design.ID}, {build.ID, buy.ID}, {build.ID, design.ID}} {
+		if err := s.Tasks.AddDependency(ctx, d[0], d[1]); err != nil {
+			t.Fatalf("AddDependency(%d, %d): %v", d[0], d[1], err)
+		}
+	}
+	if got, err := s.Tasks.Blockers(ctx, build.ID); err != nil || !reflect.DeepEqual(got, []models.Task{design, buy}) {
+		t.Errorf("expected both blockers, got %+v, err: %v", got, err)
+	}
+	if got, err := s.Tasks.BlockerIDs(ctx, build.ID); err != nil || !reflect.DeepEqual(got, []int{design.ID, buy.ID}) {
+		t.Errorf("expected both blocker IDs, got %v, err: %v", got, err)
+	}
+	if got, err := s.Tasks.BlockerIDs(ctx, design.ID); err != nil || len(got) != 0 {
+		t.Errorf("expected no blockers, got %v, err: %v", got, err)
+	}
+
+	if err := s.Tasks.RemoveDependency(ctx, build.ID, buy.ID); err != nil {
+		t.Fatalf("RemoveDependency: %v", err)
+	}
+	if err := s.Tasks.RemoveDependency(ctx, build.ID, buy.ID); err != nil {
+		t.Errorf("expected removing a missing dependency to do nothing, got %v", err)
+	}
+
+	// A blocker in the trash blocks nothing, but its edge stays
+	if err := s.Tasks.DeleteTask(ctx, design.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if got, err := s.Tasks.Blockers(ctx, build.ID); err != nil || len(got) != 0 {
+		t.Errorf("expected no blockers listed, got %+v, err: %v", got, err)
+	}
+	if got, err := s.Tasks.BlockerIDs(ctx, build.ID); err != nil || !reflect.DeepEqual(got, []int{design.ID}) {
+		t.Errorf("expected the deleted blocker's ID, got %v, err: %v", got, err)
+	}
+
+	// Purging it takes the dependency with it
+	if n, err := s.Tasks.PurgeTasks(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Fatalf("expected the blocker purged, got %d, err: %v", n, err)
+	}
+	if got, err := s.Tasks.BlockerIDs(ctx, build.ID); err != nil || len(got) != 0 {
+		t.Errorf("expected the purged blocker gone, got %v, err: %v", got, err)
+	}
+}
+
+func testTaskGraph(t *testing.T, s Stores) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	a1 := createTask(t, s, models.Task{Task: "a1", UserID: alice.ID})
+	a2 := createTask(t, s, models.Task{Task: "a2", UserID: alice.ID})
+	b1 := createTask(t, s, models.Task{Task: "b1", UserID: bob.ID})
+	createTask(t, s, models.Task{Task: "loose", UserID: alice.ID})
+	for _, d := range [][2]int{{a2.ID, a1.ID}, {b1.ID, a1.ID}} {
+		if err := s.Tasks.AddDependency(ctx, d[0], d[1]); err != nil {
+			t.Fatalf("AddDependency(%d, %d): %v", d[0], d[1], err)
+		}
+	}
+
+	// Only tasks with dependencies are in the graph
+	g, err := s.Tasks.TaskGraph(ctx, nil)
+	want := models.TaskGraph{
+		Tasks:        []models.Task{a1, a2, b1},
+		Dependencies: []models.Dependency{{TaskID: a2.ID, BlockerID: a1.ID}, {TaskID: b1.ID, BlockerID: a1.ID}},
+	}
+	if err != nil || !reflect.DeepEqual(g, want) {
+		t.Errorf("expected %+v, got %+v, err: %v", want, g, err)
+	}
+
+	// A user's graph leaves out the dependencies on other users' tasks
+	g, err = s.Tasks.TaskGraph(ctx, &alice.ID)
+	want = models.TaskGraph{Tasks: []models.Task{a1, a2}, Dependencies: []models.Dependency{{TaskID: a2.ID, BlockerID: a1.ID}}}
+	if err != nil || !reflect.DeepEqual(g, want) {
+		t.Errorf("expected %+v, got %+v, err: %v", want, g, err)
+	}
+	g, err = s.Tasks.TaskGraph(ctx, &bob.ID)
+	if err != nil || g.Tasks == nil || len(g.Tasks) != 0 || g.Dependencies == nil || len(g.Dependencies) != 0 {
+		t.Errorf("expected an empty graph for bob, got %+v, err: %v", g, err)
+	}
+
+	// Tasks in the trash are left out, with their dependencies
+	if err := s.Tasks.DeleteTask(ctx, a2.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if g, err := s.Tasks.TaskGraph(ctx, &alice.ID); err != nil || len(g.Tasks) != 0 || len(g.Dependencies) != 0 {
+		t.Errorf("expected an empty graph, got %+v, err: %v", g, err)
+	}
+}
+
+func testUserTasks(t *testing.T, s Stores) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	idle := createUser(t, s, "idle")
+	t1 := createTask(t, s, models.Task{Task: "one", UserID: alice.ID})
+	t2 := createTask(t, s, models.Task{Task: "two", Completed: true, UserID: alice.ID})
+	t3 := createTask(t, s, models.Task{Task: "three", UserID: alice.ID})
+
+	u, tasks, err := s.Users.GetUserWithTasks(ctx, alice.ID)
+	if err != nil || u != alice || !reflect.DeepEqual(tasks, []models.Task{t1, t2, t3}) {
+		t.Errorf("expected %+v with tasks %+v, got %+v with %+v, err: %v", alice, []models.Task{t1, t2, t3}, u, tasks, err)
+	}
+	u, tasks, err = s.Users.GetUserWithTasks(ctx, idle.ID)
+	if err != nil || u != idle || tasks == nil || len(tasks) != 0 {
+		t.Errorf("expected %+v with no tasks, got %+v with %#v, err: %v", idle, u, tasks, err)
+	}
+
+	if _, st, err := s.Users.GetUserStats(ctx, alice.ID); err != nil || st != (models.UserStats{Open: 2, Completed: 1}) {
+		t.Errorf("expected 2 open and 1 completed, got %+v, err: %v", st, err)
+	}
+	if _, st, err := s.Users.GetUserStats(ctx, idle.ID); err != nil || st != (models.UserStats{}) {
+		t.Errorf("expected no tasks counted, got %+v, err: %v", st, err)
+	}
+
+	if n, err := s.Users.CountTasks(ctx, alice.ID); err != nil || n != 3 {
+		t.Errorf("expected 3 tasks, got %d, err: %v", n, err)
+	}
+}
+
+func testDeleteUser(t *testing.T, s Stores) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	carol := createUser(t, s, "carol")
+	ta := createTask(t, s, models.Task{Task: "alice's", UserID: alice.ID})
+	tb := createTask(t, s, models.Task{Task: "bob's", UserID: bob.ID})
+	trashed := createTask(t, s, models.Task{Task: "alice's old", UserID: alice.ID})
+	if err := s.Tasks.DeleteTask(ctx, trashed.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if got, err := s.Users.LockUserTasks(ctx, alice.ID); err != nil || len(got) != 2 || got[0].ID != ta.ID || got[1].ID != trashed.ID {
+		t.Errorf("expected alice's tasks, the trashed one included, got %+v, err: %v", got, err)
+	}
+
+	// Reassigned tasks live on with their new owner
+	if err := s.Users.DeleteUser(ctx, alice.ID, carol.ID); err != nil {
+		t.Fatalf("DeleteUser reassigning: %v", err)
+	}
+	if _, err := s.Users.GetUser(ctx, alice.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected alice to be deleted, got %v", err)
+	}
+	if got, err := s.Tasks.GetTask(ctx, ta.ID); err != nil || got.UserID != carol.ID || got.Version != ta.Version+1 {
+		t.Errorf("expected the task to move to carol at its next version, got %+v, err: %v", got, err)
+	}
+
+	// Otherwise the tasks go with the user
+	if err := s.Users.DeleteUser(ctx, bob.ID, 0); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := s.Tasks.GetTask(ctx, tb.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected bob's task to be deleted, got %v", err)
+	}
+	if n, err := s.Users.CountTasks(ctx, bob.ID); err != nil || n != 0 {
+		t.Errorf("expected bob's deleted tasks not to count, got %d, err: %v", n, err)
+	}
+}
+
+func testTaskTrash(t *testing.T, s Stores) {
+	ctx := context.Background()
+	u := createUser(t, s, "alice")
+	kept := createTask(t, s, models.Task{Task: "kept", UserID: u.ID})
+	binned := createTask(t, s, models.Task{Task: "binned", UserID: u.ID})
+
+	if err := s.Tasks.DeleteTask(ctx, binned.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	tasks, total, err := s.Tasks.ViewTasks(ctx, models.TaskFilter{Limit: 10})
+	if err != nil || total != 1 || !reflect.DeepEqual(tasks, []models.Task{kept}) {
+		t.Errorf("expected only %+v listed, got %+v of %d, err: %v", kept, tasks, total, err)
+	}
+	if _, st, err := s.Users.GetUserStats(ctx, u.ID); err != nil || st != (models.UserStats{Open: 1}) {
+		t.Errorf("expected the deleted task not to count, got %+v, err: %v", st, err)
+	}
+	if _, tasks, err := s.Users.GetUserWithTasks(ctx, u.ID); err != nil || !reflect.DeepEqual(tasks, []models.Task{kept}) {
+		t.Errorf("expected only %+v with the user, got %+v, err: %v", kept, tasks, err)
+	}
+
+	// The trash lists it at its next version, with when it was deleted
+	tasks, total, err = s.Tasks.ViewTasks(ctx, models.TaskFilter{Deleted: true, Limit: 10})
+	if err != nil || total != 1 || len(tasks) != 1 {
+		t.Fatalf("expected the deleted task in the trash, got %+v of %d, err: %v", tasks, total, err)
+	}
+	if got := tasks[0]; got.ID != binned.ID || got.Version != binned.Version+1 || got.DeletedAt.IsZero() || !got.UpdatedAt.Equal(got.DeletedAt) {
+		t.Errorf("expected %+v at its next version, updated when it was deleted, got %+v", binned, got)
+	}
+	if got, err := s.Tasks.GetDeletedTaskForUpdate(ctx, binned.ID); err != nil || !got.DeletedAt.Equal(tasks[0].DeletedAt) {
+		t.Errorf("expected the deleted task %+v, got %+v, err: %v", tasks[0], got, err)
+	}
+	if _, err := s.Tasks.GetDeletedTaskForUpdate(ctx, kept.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a task not in the trash, got %v", err)
+	}
+
+	// Restoring it bumps the version again
+	restored, err := s.Tasks.RestoreTask(ctx, binned.ID)
+	if err != nil {
+		t.Fatalf("RestoreTask: %v", err)
+	}
+	if restored.Version != binned.Version+2 || !restored.DeletedAt.IsZero() || restored.UpdatedAt.Before(tasks[0].UpdatedAt) {
+		t.Errorf("expected %+v back at version %d, got %+v", binned, binned.Version+2, restored)
+	}
+	if got, err := s.Tasks.GetTask(ctx, binned.ID); err != nil || got != restored {
+		t.Errorf("expected the restored task %+v, got %+v, err: %v", restored, got, err)
+	}
+	if _, err := s.Tasks.RestoreTask(ctx, kept.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows restoring a task not in the trash, got %v", err)
+	}
+}
+
+func testUserTrash(t *testing.T, s Stores) {
+	ctx := context.Background()
+	bob := createUser(t, s, "bob")
+	carol := createUser(t, s, "carol")
+	earlier := createTask(t, s, models.Task{Task: "deleted before bob", UserID: bob.ID})
+	tb := createTask(t, s, models.Task{Task: "bob's", UserID: bob.ID})
+	if err := s.Tasks.DeleteTask(ctx, earlier.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if err := s.Users.DeleteUser(ctx, bob.ID, 0); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	// A deleted user is only found by name, which they keep
+	if _, err := s.Users.GetUser(ctx, bob.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected bob to be deleted, got %v", err)
+	}
+	if u, err := s.Users.GetUserByName(ctx, "bob"); err != nil || u.ID != bob.ID || u.DeletedAt.IsZero() {
+		t.Errorf("expected bob in the trash by name, got %+v, err: %v", u, err)
+	}
+	if _, _, err := s.Users.GetPasswordHash(ctx, "bob"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected a deleted user to have no password, got %v", err)
+	}
+	users, total, err := s.Users.ViewUsers(ctx, models.UserFilter{Limit: 10})
+	if err != nil || total != 1 || !reflect.DeepEqual(users, []models.User{carol}) {
+		t.Errorf("expected only %+v listed, got %+v of %d, err: %v", carol, users, total, err)
+	}
+	users, total, err = s.Users.ViewUsers(ctx, models.UserFilter{Deleted: true, Limit: 10})
+	if err != nil || total != 1 || len(users) != 1 || users[0].ID != bob.ID || users[0].DeletedAt.IsZero() {
+		t.Errorf("expected bob in the trash, got %+v of %d, err: %v", users, total, err)
+	}
+	if _, err := s.Users.GetDeletedUserForUpdate(ctx, carol.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a user not in the trash, got %v", err)
+	}
+
+	// Restoring bob brings back the tasks deleted with him, not the others
+	if u, err := s.Users.GetDeletedUserForUpdate(ctx, bob.ID); err != nil || u.ID != bob.ID {
+		t.Errorf("expected bob, got %+v, err: %v", u, err)
+	}
+	if err := s.Users.RestoreUser(ctx, bob.ID); err != nil {
+		t.Fatalf("RestoreUser: %v", err)
+	}
+	if u, err := s.Users.GetUser(ctx, bob.ID); err != nil || u != bob {
+		t.Errorf("expected bob restored, got %+v, err: %v", u, err)
+	}
+	got, err := s.Tasks.GetTask(ctx, tb.ID)
+	if err != nil || got.Version != tb.Version+2 || got.UpdatedAt.Before(tb.UpdatedAt) {
+		t.Errorf("expected the restored task %+v at version %d, got %+v, err: %v", tb, tb.Version+2, got, err)
+	}
+	got.Version, got.UpdatedAt = tb.Version, tb.UpdatedAt
+	if got != tb {
+		t.Errorf("expected the restored task %+v, got %+v", tb, got)
+	}
+	if _, err := s.Tasks.GetTask(ctx, earlier.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the task deleted before bob to stay in the trash, got %v", err)
+	}
+	if err := s.Users.RestoreUser(ctx, bob.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows restoring a user not in the trash, got %v", err)
+	}
+}
+
+func testPurgeTrash(t *testing.T, s Stores) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	ta := createTask(t, s, models.Task{Task: "alice's", UserID: alice.ID})
+	tb := createTask(t, s, models.Task{Task: "bob's", UserID: bob.ID})
+	if err := s.Tasks.DeleteTask(ctx, ta.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if err := s.Users.DeleteUser(ctx, bob.ID, 0); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	// Nothing was deleted an hour ago
+	if n, err := s.Tasks.PurgeTasks(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("expected nothing purged, got %d, err: %v", n, err)
+	}
+	if n, err := s.Users.PurgeUsers(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("expected nothing purged, got %d, err: %v", n, err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if n, err := s.Tasks.PurgeTasks(ctx, later); err != nil || n != 2 {
+		t.Errorf("expected both tasks purged, got %d, err: %v", n, err)
+	}
+	if n, err := s.Users.PurgeUsers(ctx, later); err != nil || n != 1 {
+		t.Errorf("expected bob purged, got %d, err: %v", n, err)
+	}
+	for _, id := range []int{ta.ID, tb.ID} {
+		if _, err := s.Tasks.GetDeletedTaskForUpdate(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("expected task %d to be gone, got %v", id, err)
+		}
+	}
+	if _, err := s.Users.GetUserByName(ctx, "bob"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected bob to be gone, got %v", err)
+	}
+	if _, err := s.Users.GetUser(ctx, alice.ID); err != nil {
+		t.Errorf("expected alice to stay, got %v", err)
+	}
+}
+
+func testAudit(t *testing.T, s Stores) {
+	ctx := context.Background()
+	start := time.Now().Add(-time.Second)
+	entries := []models.AuditEntry{
+		{Actor: "alice", Action: models.AuditCreate, Entity: models.AuditTask, EntityID: 1,
+			Changes: map[string]models.AuditChange{"task": {After: "first"}, "user_id": {After: 1.0}}, RequestID: "req-1"},
+		{Actor: "admin", Action: models.AuditCreate, Entity: models.AuditUser, EntityID: 1,
+			Changes: map[string]models.AuditChange{"name": {After: "alice"}}},
+		{Actor: "alice", Action: models.AuditComplete, Entity: models.AuditTask, EntityID: 1,
+			Changes: map[string]models.AuditChange{"completed": {Before: false, After: true}}, RequestID: "req-2"},
+		{Actor: "alice", Action: models.AuditDelete, Entity: models.AuditTask, EntityID: 2,
+			Changes: map[string]models.AuditChange{"task": {Before: "second"}}},
+	}
+	for _, e := range entries {
+		if err := s.Audit.AppendAudit(ctx, e); err != nil {
+			t.Fatalf("AppendAudit: %v", err)
+		}
+	}
+
+	got, total, err := s.Audit.ViewAudit(ctx, models.AuditFilter{Limit: 10})
+	if err != nil || total != 4 || len(got) != 4 {
+		t.Fatalf("expected 4 entries, got %d of %d, err: %v", len(got), total, err)
+	}
+	for i, e := range got {
+		if i > 0 && e.ID <= got[i-1].ID {
+			t.Errorf("expected entries oldest first, got ID %d after %d", e.ID, got[i-1].ID)
+		}
+		if e.Time.Before(start) || e.Time.Location() != time.UTC {
+			t.Errorf("expected the time of the append in UTC, got %v", e.Time)
+		}
+		want := entries[i]
+		want.ID, want.Time = e.ID, e.Time
+		if !reflect.DeepEqual(e, want) {
+			t.Errorf("expected %+v, got %+v", want, e)
+		}
+	}
+
+	got, total, err = s.Audit.ViewAudit(ctx, models.AuditFilter{Entity: models.AuditTask, EntityID: 1, Limit: 1, Offset: 1})
+	if err != nil || total != 2 || len(got) != 1 || got[0].Action != models.AuditComplete {
+		t.Errorf("expected the second entry of task 1 of 2, got %+v of %d, err: %v", got, total, err)
+	}
+	got, total, err = s.Audit.ViewAudit(ctx, models.AuditFilter{Entity: models.AuditUser, Limit: 10})
+	if err != nil || total != 1 || len(got) != 1 || got[0].Entity != models.AuditUser {
+		t.Errorf("expected the one user entry, got %+v of %d, err: %v", got, total, err)
+	}
+	got, total, err = s.Audit.ViewAudit(ctx, models.AuditFilter{Entity: models.AuditUser, EntityID: 2, Limit: 10})
+	if err != nil || total != 0 || got == nil || len(got) != 0 {
+		t.Errorf("expected an empty page, got %#v of %d, err: %v", got, total, err)
+	}
+}
+
+// testAuditRollback checks that an entry is only kept with the change it
+// records.
+func testAuditRollback(t *testing.T, s Stores) {
+	ctx := context.Background()
+	errFail := errors.New("fail")
+	err := s.Tx.InTx(ctx, func(ctx context.Context) error {
+		u, err := s.Users.CreateUser(ctx, models.User{Name: "alice"})
+		if err != nil {
+			return err
+		}
+		e := models.AuditEntry{Actor: "admin", Action: models.AuditCreate, Entity: models.AuditUser, EntityID: u.ID,
+			Changes: map[string]models.AuditChange{"name": {After: "alice"}}}
+		if err := s.Audit.AppendAudit(ctx, e); err != nil {
+			return err
+		}
+		return errFail
+	})
+	if !errors.Is(err, errFail) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+	if got, total, err := s.Audit.ViewAudit(ctx, models.AuditFilter{Limit: 10}); err != nil || total != 0 || len(got) != 0 {
+		t.Errorf("expected the entry to be rolled back, got %+v of %d, err: %v", got, total, err)
+	}
+}
+
+func testTxCommit(t *testing.T, s Stores) {
+	ctx := context.Background()
+	var u models.User
+	err := s.Tx.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if u, err = s.Users.CreateUser(ctx, models.User{Name: "alice"}); err != nil {
+			return err
+		}
+		_, err = s.Tasks.CreateTask(ctx, models.Task{Task: "first", UserID: u.ID})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("InTx: %v", err)
+	}
+	if n, err := s.Users.CountTasks(ctx, u.ID); err != nil || n != 1 {
+		t.Errorf("expected the committed task, got %d tasks, err: %v", n, err)
+	}
+}
+
+func testTxRollback(t *testing.T, s Stores) {
+	ctx := context.Background()
+	u := createUser(t, s, "alice")
+	task := createTask(t, s, models.Task{Task: "keep", UserID: u.ID})
+	errFail := errors.New("fail")
+
+	err := s.Tx.InTx(ctx, func(ctx context.Context) error {
+		renamed := task
+		renamed.Task = "lost"
+		if _, err := s.Tasks.UpdateTask(ctx, renamed); err != nil {
+			return err
+		}
+		// A nested unit of work is undone with the one it joined
+		return s.Tx.InTx(ctx, func(ctx context.Context) error {
+			if _, err := s.Tasks.CreateTask(ctx, models.Task{Task: "lost too", UserID: u.ID}); err != nil {
+				return err
+			}
+			return errFail
+		})
+	})
+	if !errors.Is(err, errFail) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+
+	tasks, total, err := s.Tasks.ViewTasks(ctx, models.TaskFilter{Limit: 10})
+	if err != nil || total != 1 || !reflect.DeepEqual(tasks, []models.Task{task}) {
+		t.Errorf("expected only %+v, got %+v, err: %v", task, tasks, err)
+	}
+}
+
+// testTxSerializes has units of work read a task and write it back. None
+// may overwrite another's change, as the locking read orders them.
+func testTxSerializes(t *testing.T, s Stores) {
+	ctx := context.Background()
+	u := createUser(t, s, "alice")
+	task := createTask(t, s, models.Task{UserID: u.ID})
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.Tx.InTx(ctx, func(ctx context.Context) error {
+				cur, err := s.Tasks.GetTaskForUpdate(ctx, task.ID)
+				if err != nil {
+					return err
+				}
+				cur.Task += "x"
+				_, err = s.Tasks.UpdateTask(ctx, cur)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unit of work failed: %v", err)
+		}
+	}
+
+	got, err := s.Tasks.GetTask(ctx, task.ID)
+	if err != nil || got.Task != strings.Repeat("x", workers) {
+		t.Errorf("expected %d updates, got %q, err: %v", workers, got.Task, err)
+	}
+}
+
+// testGraphReadsWait moves a task and adds a blocker to it in one unit of
+// work while another reads its parent and blockers. The reads must wait for
+// the change and see it, or the cycle checks of the task service could miss
+// a cycle closed by two concurrent changes.
+func testGraphReadsWait(t *testing.T, s Stores) {
+	ctx := context.Background()
+	u := createUser(t, s, "alice")
+	parent := createTask(t, s, models.Task{Task: "parent", UserID: u.ID})
+	task := createTask(t, s, models.Task{Task: "task", UserID: u.ID})
+	blocker := createTask(t, s, models.Task{Task: "blocker", UserID: u.ID})
+
+	changed := make(chan struct{}, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Tx.InTx(ctx, func(ctx context.Context) error {
+			cur, err := s.Tasks.GetTaskForUpdate(ctx, task.ID)
+			if err != nil {
+				return err
+			}
+			cur.ParentID = parent.ID
+			if _, err := s.Tasks.UpdateTask(ctx, cur); err != nil {
+				return err
+			}
+			if err := s.Tasks.AddDependency(ctx, task.ID, blocker.ID); err != nil {
+				return err
+			}
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+			// Give the reads time to reach the lock before committing
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})
+	}()
+	select {
+	case <-changed:
+	case err := <-done:
+		t.Fatalf("the change failed: %v", err)
+	}
+
+	var parentID int
+	var blockers []int
+	err := s.Tx.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if parentID, err = s.Tasks.GetParentID(ctx, task.ID); err != nil {
+			return err
+		}
+		blockers, err = s.Tasks.BlockerIDs(ctx, task.ID)
+		return err
+	})
+	if err := <-done; err != nil {
+		t.Fatalf("the change failed: %v", err)
+	}
+	if err != nil || parentID != parent.ID || !reflect.DeepEqual(blockers, []int{blocker.ID}) {
+		t.Errorf("expected parent %d and blocker %d, got %d and %v, err: %v", parent.ID, blocker.ID, parentID, blockers, err)
+	}
+}
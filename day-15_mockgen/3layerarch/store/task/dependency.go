@@ -0,0 +1,140 @@
+package taskstore
+
+import (
+	"3layerarch/models"
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// GetParentID returns the ID of the parent of the task with id, 0 for a
+// top-level task, or sql.ErrNoRows. Tasks in the trash are found too, as
+// they keep their place in the tree. The task is kept from being changed
+// until the unit of work in ctx ends, so a walk up the tree waits for a
+// concurrent move and then sees it.
+func (s *Store) GetParentID(ctx context.Context, id int) (int, error) {
+	var parentID sql.NullInt64
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT parent_id FROM TASKS WHERE id = ?"+s.dialect.ForShare, id).Scan(&parentID)
+	return int(parentID.Int64), err
+}
+
+// Subtasks returns the subtasks of the task with parentID ordered by id.
+// Subtasks in the trash are left out.
+func (s *Store) Subtasks(ctx context.Context, parentID int) ([]models.Task, error) {
+	return s.queryTasks(ctx, "SELECT "+taskColumns+" FROM TASKS WHERE parent_id = ? AND deleted_at IS NULL ORDER BY id ASC", parentID)
+}
+
+// Blockers returns the tasks blocking the task with taskID ordered by id.
+// Blockers in the trash block nothing and are left out.
+func (s *Store) Blockers(ctx context.Context, taskID int) ([]models.Task, error) {
+	return s.queryTasks(ctx, "SELECT "+taskColumns+" FROM TASKS WHERE id IN (SELECT blocker_id FROM TASK_DEPENDENCIES WHERE task_id = ?) AND deleted_at IS NULL ORDER BY id ASC", taskID)
+}
+
+// BlockerIDs returns the IDs of every task blocking the task with taskID,
+// in the trash or not, in ascending order. Like GetParentID it keeps the
+// task from being changed until the unit of work in ctx ends, which holds
+// off dependencies being added to it too.
+func (s *Store) BlockerIDs(ctx context.Context, taskID int) ([]int, error) {
+	if s.dialect.ForShare != "" {
+		var id int
+		err := s.conn(ctx).QueryRowContext(ctx, "SELECT id FROM TASKS WHERE id = ?"+s.dialect.ForShare, taskID).Scan(&id)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	rows, err := s.conn(ctx).QueryContext(ctx, "SELECT blocker_id FROM TASK_DEPENDENCIES WHERE task_id = ? ORDER BY blocker_id ASC", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// AddDependency makes the task with blockerID block the one with taskID.
+// Adding a dependency that exists already changes nothing. The caller must
+// keep both tasks from changing until the unit of work in ctx ends.
+func (s *Store) AddDependency(ctx context.Context, taskID, blockerID int) error {
+	var n int
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM TASK_DEPENDENCIES WHERE task_id = ? AND blocker_id = ?", taskID, blockerID).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = s.conn(ctx).ExecContext(ctx, "INSERT INTO TASK_DEPENDENCIES (task_id, blocker_id) VALUES (?, ?)", taskID, blockerID)
+	return err
+}
+
+// RemoveDependency stops the task with blockerID blocking the one with
+// taskID, if it does.
+func (s *Store) RemoveDependency(ctx context.Context, taskID, blockerID int) error {
+	_, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM TASK_DEPENDENCIES WHERE task_id = ? AND blocker_id = ?", taskID, blockerID)
+	return err
+}
+
+// TaskGraph returns the dependencies between tasks outside the trash, and
+// those tasks, ordered by ID. With a userID only dependencies between that
+// user's tasks are included.
+func (s *Store) TaskGraph(ctx context.Context, userID *int) (models.TaskGraph, error) {
+	query := `SELECT d.task_id, d.blocker_id FROM TASK_DEPENDENCIES d JOIN TASKS t ON t.id = d.task_id JOIN TASKS b ON b.id = d.blocker_id
+		WHERE t.deleted_at IS NULL AND b.deleted_at IS NULL`
+	var args []any
+	if userID != nil {
+		query += " AND t.user_id = ? AND b.user_id = ?"
+		args = append(args, *userID, *userID)
+	}
+	rows, err := s.conn(ctx).QueryContext(ctx, query+" ORDER BY d.task_id ASC, d.blocker_id ASC", args...)
+	if err != nil {
+		return models.TaskGraph{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}()
+
+	g := models.TaskGraph{Tasks: []models.Task{}, Dependencies: []models.Dependency{}}
+	seen := map[int]bool{}
+	var ids []any
+	for rows.Next() {
+		var d models.Dependency
+		if err := rows.Scan(&d.TaskID, &d.BlockerID); err != nil {
+			return models.TaskGraph{}, err
+		}
+		g.Dependencies = append(g.Dependencies, d)
+		for _, id := range []int{d.TaskID, d.BlockerID} {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return models.TaskGraph{}, err
+	}
+	if len(ids) == 0 {
+		return g, nil
+	}
+
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	g.Tasks, err = s.queryTasks(ctx, "SELECT "+taskColumns+" FROM TASKS WHERE id IN ("+in+") ORDER BY id ASC", ids...)
+	if err != nil {
+		return models.TaskGraph{}, err
+	}
+	return g, nil
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Now is the time the stores record a change at: in UTC, and to the
+// microsecond that MySQL and PostgreSQL keep, so that it reads back as
+// written.
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// Time returns the value of a nullable time column in UTC, or the zero time
+// for NULL. Drivers read times back in other locations.
+func Time(t sql.NullTime) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+	return t.Time.UTC()
+}
+
+// NullTime is the value of a nullable time column: NULL for the zero time.
+func NullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,148 @@
+// Package store holds what the task and user stores share: running several
+// store calls in one database transaction, and the dialect of the database.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// DBTX is what a store runs its queries on, either the database or the
+// transaction of the current unit of work.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// Conn returns the transaction carried by ctx, or db when there is none, so
+// that store calls made inside a unit of work join its transaction.
+func Conn(ctx context.Context, db *sql.DB) DBTX {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// txOptions is the isolation of every unit of work. Reads that a write
+// depends on take row locks, so read committed is enough and avoids the gap
+// locks of MySQL's default repeatable read. SQLite ignores it.
+var txOptions = &sql.TxOptions{Isolation: sql.LevelReadCommitted}
+
+// InTx runs fn in a transaction on db that is committed if fn returns nil
+// and rolled back otherwise. The ctx passed to fn carries the transaction.
+// When ctx already carries one, fn joins it instead.
+func InTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, txOptions)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Println("Error rolling back:", err)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Transactor runs units of work for the services, retrying those that lose
+// a deadlock or time out waiting for a lock.
+type Transactor struct {
+	db *sql.DB
+	// Attempts is how many times a unit of work is tried in all.
+	Attempts int
+	// Backoff is the wait before the first retry; it doubles after each.
+	Backoff time.Duration
+}
+
+func NewTransactor(db *sql.DB) *Transactor {
+	return &Transactor{db: db, Attempts: 3, Backoff: 10 * time.Millisecond}
+}
+
+// InTx runs fn in a transaction as the package-level InTx does. fn may run
+// more than once, so it must not have effects outside the database. A unit
+// of work nested in another is retried with the outer one, not by itself.
+func (t *Transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, nested := ctx.Value(txKey{}).(*sql.Tx)
+	backoff := t.Backoff
+	for attempt := 1; ; attempt++ {
+		err := InTx(ctx, t.db, fn)
+		if nested || attempt >= t.Attempts || !Retryable(err) {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// MySQL and PostgreSQL errors that abort a transaction which may well
+// succeed if run again.
+const (
+	errLockWaitTimeout = 1205
+	errDeadlock        = 1213
+
+	pgSerializationFailure = "40001"
+	pgDeadlock             = "40P01"
+	pgLockNotAvailable     = "55P03"
+)
+
+// Retryable reports whether err is a deadlock, lock wait timeout or
+// serialization failure.
+func Retryable(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == errDeadlock || me.Number == errLockWaitTimeout
+	}
+	var pe *pgconn.PgError
+	if errors.As(err, &pe) {
+		return pe.Code == pgSerializationFailure || pe.Code == pgDeadlock || pe.Code == pgLockNotAvailable
+	}
+	return false
+}
+
+// Errors that a unique key raises on each database.
+const (
+	errDuplicateEntry = 1062
+
+	pgUniqueViolation = "23505"
+)
+
+// Duplicate reports whether err is a unique key refusing a second row with
+// the same value.
+func Duplicate(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == errDuplicateEntry
+	}
+	var pe *pgconn.PgError
+	if errors.As(err, &pe) {
+		return pe.Code == pgUniqueViolation
+	}
+	var se *sqlite.Error
+	if errors.As(err, &se) {
+		return se.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	}
+	return false
+}
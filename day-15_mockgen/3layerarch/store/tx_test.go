@@ -0,0 +1,166 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"3layerarch/store"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var errDeadlock = &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"}
+
+func TestInTx(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE TASKS").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM TASKS").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = store.InTx(context.Background(), db, func(ctx context.Context) error {
+		if _, err := store.Conn(ctx, db).ExecContext(ctx, "UPDATE TASKS SET completed = TRUE"); err != nil {
+			return err
+		}
+		// A nested unit of work joins the outer transaction
+		return store.InTx(ctx, db, func(ctx context.Context) error {
+			_, err := store.Conn(ctx, db).ExecContext(ctx, "DELETE FROM TASKS")
+			return err
+		})
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInTx_Rollback(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE TASKS").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	want := errors.New("user not found")
+	err = store.InTx(context.Background(), db, func(ctx context.Context) error {
+		if _, err := store.Conn(ctx, db).ExecContext(ctx, "UPDATE TASKS SET completed = TRUE"); err != nil {
+			return err
+		}
+		return want
+	})
+	if err != want {
+		t.Errorf("expected %v, got %v", want, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransactor_Retry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	tr := store.NewTransactor(db)
+	tr.Backoff = time.Millisecond
+
+	// A deadlock is retried and the second attempt commits
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE TASKS").WillReturnError(errDeadlock)
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE TASKS").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	runs := 0
+	update := func(ctx context.Context) error {
+		runs++
+		_, err := store.Conn(ctx, db).ExecContext(ctx, "UPDATE TASKS SET completed = TRUE")
+		return err
+	}
+	if err := tr.InTx(context.Background(), update); err != nil || runs != 2 {
+		t.Errorf("expected success on the second run, got %d runs, err: %v", runs, err)
+	}
+
+	// Attempts are limited
+	runs = 0
+	for range tr.Attempts {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE TASKS").WillReturnError(errDeadlock)
+		mock.ExpectRollback()
+	}
+	if err := tr.InTx(context.Background(), update); !errors.Is(err, errDeadlock) || runs != tr.Attempts {
+		t.Errorf("expected the deadlock after %d runs, got %d runs, err: %v", tr.Attempts, runs, err)
+	}
+
+	// Other errors are not retried
+	runs = 0
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE TASKS").WillReturnError(&mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"})
+	mock.ExpectRollback()
+	if err := tr.InTx(context.Background(), update); err == nil || runs != 1 {
+		t.Errorf("expected one run, got %d, err: %v", runs, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errDeadlock, true},
+		{&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, false},
+		{&pgconn.PgError{Code: "40P01", Message: "deadlock detected"}, true},
+		{&pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"}, false},
+		{errors.New("deadlock"), false},
+		{nil, false},
+	}
+
+	for _, tc := range tests {
+		if got := store.Retryable(tc.err); got != tc.want {
+			t.Errorf("Retryable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, true},
+		{errDeadlock, false},
+		{&pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"}, true},
+		{&pgconn.PgError{Code: "23503", Message: "violates foreign key constraint"}, false},
+		{errors.New("duplicate"), false},
+		{nil, false},
+	}
+
+	for _, tc := range tests {
+		if got := store.Duplicate(tc.err); got != tc.want {
+			t.Errorf("Duplicate(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -2,10 +2,16 @@ package userstore_test
 
 import (
 	"3layerarch/models"
+	"3layerarch/store"
 	"3layerarch/store/user"
+	"context"
+	"database/sql"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 )
 
 func TestCreateUser(t *testing.T) {
@@ -15,16 +21,40 @@ func TestCreateUser(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := userstore.New(db)
+	repo := userstore.New(db, store.MySQL)
 	user := models.User{Name: "Alice"}
 
 	mock.ExpectExec("INSERT INTO USERS (name) VALUES (?)").
-		WithArgs(user.Name).WillReturnResult(sqlmock.NewResult(1, 1))
+		WithArgs(user.Name).WillReturnResult(sqlmock.NewResult(9, 1))
 
-	err = repo.CreateUser(user)
+	created, err := repo.CreateUser(context.Background(), user)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
+	if created.ID != 9 || created.Name != "Alice" {
+		t.Errorf("expected the inserted user with its ID, got %+v", created)
+	}
+}
+
+func TestCreateUser_NameTaken(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'Alice' for key 'idx_users_name'"}
+
+	mock.ExpectExec("INSERT INTO USERS (name) VALUES (?)").WithArgs("Alice").WillReturnError(duplicate)
+	mock.ExpectExec("UPDATE USERS SET name = ? WHERE id = ?").WithArgs("Alice", 2).WillReturnError(duplicate)
+
+	if _, err := repo.CreateUser(context.Background(), models.User{Name: "Alice"}); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+	if err := repo.UpdateUser(context.Background(), models.User{ID: 2, Name: "Alice"}); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("expected a conflict, got %v", err)
+	}
 }
 
 func TestGetUser(t *testing.T) {
@@ -34,16 +64,402 @@ func TestGetUser(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := userstore.New(db)
+	repo := userstore.New(db, store.MySQL)
 
 	rows := sqlmock.NewRows([]string{"id", "name"}).
 		AddRow(1, "Bob")
 
-	mock.ExpectQuery("SELECT id, name FROM USERS WHERE id = ?").
+	mock.ExpectQuery("SELECT id, name FROM USERS WHERE id = ? AND deleted_at IS NULL").
 		WithArgs(1).WillReturnRows(rows)
 
-	user, err := repo.GetUser(1)
+	user, err := repo.GetUser(context.Background(), 1)
 	if err != nil || user.ID != 1 || user.Name != "Bob" {
 		t.Errorf("unexpected result: %v, err: %v", user, err)
 	}
 }
+
+func TestGetUser_Locking(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name FROM USERS WHERE id = ? AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Bob"))
+	mock.ExpectQuery("SELECT id, name FROM USERS WHERE id = ? AND deleted_at IS NULL FOR SHARE").
+		WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Eve"))
+	mock.ExpectCommit()
+
+	err = store.InTx(context.Background(), db, func(ctx context.Context) error {
+		if _, err := repo.GetUserForUpdate(ctx, 1); err != nil {
+			return err
+		}
+		_, err := repo.GetUserForShare(ctx, 2)
+		return err
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetUser_LockingSQLite(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.SQLite)
+
+	// SQLite has no row locks, so the reads are plain
+	mock.ExpectQuery("SELECT id, name FROM USERS WHERE id = ? AND deleted_at IS NULL").
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Bob"))
+	mock.ExpectQuery("SELECT id, name FROM USERS WHERE id = ? AND deleted_at IS NULL").
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Bob"))
+
+	if _, err := repo.GetUserForUpdate(context.Background(), 1); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := repo.GetUserForShare(context.Background(), 1); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetUserStats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+
+	mock.ExpectQuery(`SELECT u.id, u.name, .* FROM USERS u LEFT JOIN TASKS t ON t.user_id = u.id AND t.deleted_at IS NULL WHERE u.id = \? AND u.deleted_at IS NULL GROUP BY u.id, u.name`).
+		WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "open", "completed"}).AddRow(1, "Bob", 2, 3))
+
+	user, st, err := repo.GetUserStats(context.Background(), 1)
+	if err != nil || user.Name != "Bob" || st != (models.UserStats{Open: 2, Completed: 3}) {
+		t.Errorf("unexpected result: %v %v, err: %v", user, st, err)
+	}
+}
+
+func TestGetUserWithTasks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+	query := `SELECT u.id, u.name, t.id, t.task, t.completed, t.parent_id, t.priority, t.due_at, t.version, t.created_at, t.updated_at, t.completed_at
+		FROM USERS u LEFT JOIN TASKS t`
+	cols := []string{"id", "name", "task_id", "task", "completed", "parent_id", "priority", "due_at", "version", "created_at", "updated_at", "completed_at"}
+	day := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectQuery(query).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(1, "Bob", 4, "Buy milk", false, nil, "", nil, 1, day, day, nil).AddRow(1, "Bob", 6, "Walk dog", true, 4, "low", day, 2, day, day, day))
+	user, tasks, err := repo.GetUserWithTasks(context.Background(), 1)
+	want := models.Task{ID: 6, Task: "Walk dog", Completed: true, UserID: 1, ParentID: 4, Priority: models.PriorityLow, DueAt: day, Version: 2, CreatedAt: day, UpdatedAt: day, CompletedAt: day}
+	if err != nil || user.Name != "Bob" || len(tasks) != 2 || tasks[1] != want {
+		t.Errorf("unexpected result: %v %v, err: %v", user, tasks, err)
+	}
+
+	// A user without tasks is one row with NULL task columns
+	mock.ExpectQuery(query).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(2, "Carol", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil))
+	user, tasks, err = repo.GetUserWithTasks(context.Background(), 2)
+	if err != nil || user.ID != 2 || tasks == nil || len(tasks) != 0 {
+		t.Errorf("expected an empty task list, got %v %v, err: %v", user, tasks, err)
+	}
+
+	mock.ExpectQuery(query).WithArgs(9).WillReturnRows(sqlmock.NewRows(cols))
+	if _, _, err := repo.GetUserWithTasks(context.Background(), 9); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestGetUserByName(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+
+	mock.ExpectQuery("SELECT id, name, deleted_at FROM USERS WHERE name = ?").
+		WithArgs("Bob").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "deleted_at"}).AddRow(2, "Bob", nil))
+
+	user, err := repo.GetUserByName(context.Background(), "Bob")
+	if err != nil || user.ID != 2 || !user.DeletedAt.IsZero() {
+		t.Errorf("unexpected result: %v, err: %v", user, err)
+	}
+
+	// A user in the trash keeps their name
+	deletedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery("SELECT id, name, deleted_at FROM USERS WHERE name = ?").
+		WithArgs("Carol").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "deleted_at"}).AddRow(3, "Carol", deletedAt))
+
+	user, err = repo.GetUserByName(context.Background(), "Carol")
+	if err != nil || !user.DeletedAt.Equal(deletedAt) {
+		t.Errorf("expected the deleted user, got %v, err: %v", user, err)
+	}
+}
+
+func TestPasswordHash(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+
+	mock.ExpectExec("UPDATE USERS SET password_hash = ? WHERE id = ?").
+		WithArgs("$2a$hash", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, name, role, password_hash FROM USERS WHERE name = ? AND deleted_at IS NULL").
+		WithArgs("Bob").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "password_hash"}).AddRow(2, "Bob", "admin", "$2a$hash"))
+	mock.ExpectQuery("SELECT id, name, role, password_hash FROM USERS WHERE name = ? AND deleted_at IS NULL").
+		WithArgs("Carol").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "password_hash"}).AddRow(3, "Carol", "user", nil))
+
+	if err := repo.SetPasswordHash(context.Background(), 2, "$2a$hash"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	p, hash, err := repo.GetPasswordHash(context.Background(), "Bob")
+	if err != nil || p.UserID != 2 || p.Role != models.RoleAdmin || hash != "$2a$hash" {
+		t.Errorf("unexpected result: %v, %q, err: %v", p, hash, err)
+	}
+	// A user without a password has an empty hash
+	p, hash, err = repo.GetPasswordHash(context.Background(), "Carol")
+	if err != nil || p.UserID != 3 || hash != "" {
+		t.Errorf("unexpected result: %v, %q, err: %v", p, hash, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestViewUsers(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM USERS WHERE deleted_at IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT id, name, deleted_at FROM USERS WHERE deleted_at IS NULL ORDER BY id ASC LIMIT ? OFFSET ?").
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "deleted_at"}).AddRow(2, "Bob", nil).AddRow(3, "Carol", nil))
+
+	users, total, err := repo.ViewUsers(context.Background(), models.UserFilter{Limit: 2, Offset: 1})
+	if err != nil || len(users) != 2 || total != 3 {
+		t.Errorf("unexpected result: %v, total: %d, err: %v", users, total, err)
+	}
+
+	// The trash
+	mock.ExpectQuery("SELECT COUNT(*) FROM USERS WHERE deleted_at IS NOT NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT id, name, deleted_at FROM USERS WHERE deleted_at IS NOT NULL ORDER BY id ASC LIMIT ? OFFSET ?").
+		WithArgs(2, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "deleted_at"}).AddRow(4, "Dave", time.Now()))
+
+	users, _, err = repo.ViewUsers(context.Background(), models.UserFilter{Deleted: true, Limit: 2})
+	if err != nil || len(users) != 1 || users[0].DeletedAt.IsZero() {
+		t.Errorf("expected the deleted user, got %v, err: %v", users, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+
+	mock.ExpectExec("UPDATE USERS SET name = ? WHERE id = ?").
+		WithArgs("Robert", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.UpdateUser(context.Background(), models.User{ID: 2, Name: "Robert"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCountTasks(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM TASKS WHERE user_id = ? AND deleted_at IS NULL").
+		WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+
+	n, err := repo.CountTasks(context.Background(), 2)
+	if err != nil || n != 4 {
+		t.Errorf("unexpected result: %d, err: %v", n, err)
+	}
+}
+
+func TestLockUserTasks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+	cols := []string{"id", "task", "completed", "user_id", "parent_id", "priority", "due_at", "version", "created_at", "updated_at", "completed_at", "deleted_at"}
+	day := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT id, task, completed, user_id, parent_id, priority, due_at, version, created_at, updated_at, completed_at, deleted_at
+		FROM TASKS WHERE user_id = \? ORDER BY id ASC FOR UPDATE`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(4, "Buy milk", false, 1, nil, "", nil, 1, day, day, nil, nil).
+			AddRow(6, "Walk dog", true, 1, 4, "low", day, 2, day, day, day, day))
+	tasks, err := repo.LockUserTasks(context.Background(), 1)
+	want := models.Task{ID: 6, Task: "Walk dog", Completed: true, UserID: 1, ParentID: 4, Priority: models.PriorityLow, DueAt: day, Version: 2,
+		CreatedAt: day, UpdatedAt: day, CompletedAt: day, DeletedAt: day}
+	if err != nil || len(tasks) != 2 || tasks[1] != want {
+		t.Errorf("expected the trashed task too, got %v, err: %v", tasks, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+
+	// Cascade; the tasks go to the trash with the user
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE TASKS SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE user_id = ? AND deleted_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE USERS SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.DeleteUser(context.Background(), 2, 0); err != nil {
+		t.Errorf("cascade: unexpected error: %v", err)
+	}
+
+	// Reassign
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE TASKS SET user_id = ?, updated_at = ?, version = version + 1 WHERE user_id = ?").
+		WithArgs(5, sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE USERS SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.DeleteUser(context.Background(), 3, 5); err != nil {
+		t.Errorf("reassign: unexpected error: %v", err)
+	}
+
+	// A failed step rolls back the whole delete
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE TASKS SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE user_id = ? AND deleted_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE USERS SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), 4).WillReturnError(errors.New("lock wait timeout"))
+	mock.ExpectRollback()
+
+	if err := repo.DeleteUser(context.Background(), 4, 0); err == nil {
+		t.Error("expected an error when the user delete fails")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRestoreUser(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+
+	// The tasks deleted with the user come back with them
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE TASKS SET deleted_at = NULL, updated_at = ?, version = version + 1
+			WHERE user_id = ? AND deleted_at = (SELECT deleted_at FROM USERS WHERE id = ?)`).
+		WithArgs(sqlmock.AnyArg(), 2, 2).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE USERS SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL").
+		WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.RestoreUser(context.Background(), 2); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// A user not in the trash is not restored
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE TASKS SET deleted_at = NULL, updated_at = ?, version = version + 1
+			WHERE user_id = ? AND deleted_at = (SELECT deleted_at FROM USERS WHERE id = ?)`).
+		WithArgs(sqlmock.AnyArg(), 3, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE USERS SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL").
+		WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := repo.RestoreUser(context.Background(), 3); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurgeUsers(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	repo := userstore.New(db, store.MySQL)
+	before := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE TASKS SET parent_id = NULL, updated_at = ?, version = version + 1 WHERE parent_id IN (SELECT id FROM (SELECT id FROM TASKS WHERE user_id IN (SELECT id FROM USERS WHERE deleted_at < ?)) purged)").
+		WithArgs(sqlmock.AnyArg(), before).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM TASKS WHERE user_id IN (SELECT id FROM USERS WHERE deleted_at < ?)").
+		WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("DELETE FROM USERS WHERE deleted_at < ?").
+		WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	n, err := repo.PurgeUsers(context.Background(), before)
+	if err != nil || n != 2 {
+		t.Errorf("expected 2 users purged, got %d, err: %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"3layerarch/models"
+)
+
+// APIKeyHeader is the request header API keys are sent in.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates requests by a static key. It maps the name each key
+// is known by to the key. Keys belong to operators and other services rather
+// than users, so they act as admins.
+type APIKeys map[string]string
+
+func (k APIKeys) Authenticate(r *http.Request) (models.Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return models.Principal{}, ErrNoCredentials
+	}
+	// Compare against every key so the time taken does not give one away
+	var match string
+	for name, want := range k {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(want)) == 1 {
+			match = name
+		}
+	}
+	if match == "" {
+		return models.Principal{}, errors.New("invalid API key")
+	}
+	return models.Principal{Name: match, Role: models.RoleAdmin, Method: "api_key"}, nil
+}
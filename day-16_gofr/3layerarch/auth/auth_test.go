@@ -0,0 +1,109 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"3layerarch/auth"
+	"3layerarch/models"
+)
+
+func TestAPIKeys(t *testing.T) {
+	keys := auth.APIKeys{"ci": "ci-key", "ops": "ops-key"}
+
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{name: "known key", key: "ops-key", want: "ops"},
+		{name: "unknown key", key: "other", wantErr: true},
+		{name: "prefix of a key", key: "ci-", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/task", nil)
+			r.Header.Set(auth.APIKeyHeader, tt.key)
+			p, err := keys.Authenticate(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (p.Name != tt.want || p.Method != "api_key") {
+				t.Errorf("Authenticate() = %+v, want name %q", p, tt.want)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/task", nil)
+	if _, err := keys.Authenticate(r); err != auth.ErrNoCredentials {
+		t.Errorf("Authenticate() without key error = %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got models.Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = models.PrincipalFrom(r.Context())
+	})
+	mw := &auth.Middleware{
+		Authenticators: []auth.Authenticator{auth.APIKeys{"ci": "ci-key"}},
+		Public:         []string{"/auth/token"},
+	}
+	h := mw.Wrap(next)
+
+	tests := []struct {
+		name      string
+		path      string
+		key       string
+		want      int
+		challenge string
+		message   string
+	}{
+		{name: "valid key", path: "/task", key: "ci-key", want: http.StatusOK},
+		{name: "public path", path: "/auth/token", want: http.StatusOK},
+		{name: "no credentials", path: "/task", want: http.StatusUnauthorized, challenge: "Bearer", message: "authentication required"},
+		{name: "wrong key", path: "/task", key: "nope", want: http.StatusUnauthorized, challenge: `Bearer error="invalid_token"`, message: "invalid API key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = models.Principal{}
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				r.Header.Set(auth.APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, w.Code)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.challenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.challenge)
+			}
+			if tt.want == http.StatusUnauthorized {
+				var resp struct {
+					Error struct {
+						Message string `json:"message"`
+					} `json:"error"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("failed to decode error body: %v", err)
+				}
+				if resp.Error.Message != tt.message {
+					t.Errorf("unexpected error body: %+v", resp.Error)
+				}
+			}
+		})
+	}
+
+	// The key's principal reaches the handler
+	r := httptest.NewRequest(http.MethodGet, "/task", nil)
+	r.Header.Set(auth.APIKeyHeader, "ci-key")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if got.Name != "ci" || got.Role != models.RoleAdmin || got.Method != "api_key" {
+		t.Errorf("principal = %+v, want admin ci via api_key", got)
+	}
+}
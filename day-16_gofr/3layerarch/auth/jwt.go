@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"3layerarch/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are the claims of the tokens this package issues. The subject is
+// the user's ID.
+type Claims struct {
+	Name string      `json:"name"`
+	Role models.Role `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// KeySet holds the keys that tokens are verified against, by key ID. HS256
+// keys are shared secrets and RS256 keys are RSA public keys.
+type KeySet struct {
+	hs256 map[string][]byte
+	rs256 map[string]*rsa.PublicKey
+}
+
+func (k *KeySet) AddHS256(kid string, secret []byte) {
+	if k.hs256 == nil {
+		k.hs256 = map[string][]byte{}
+	}
+	k.hs256[kid] = secret
+}
+
+func (k *KeySet) AddRS256(kid string, key *rsa.PublicKey) {
+	if k.rs256 == nil {
+		k.rs256 = map[string]*rsa.PublicKey{}
+	}
+	k.rs256[kid] = key
+}
+
+// Empty reports whether the set has no keys.
+func (k *KeySet) Empty() bool {
+	return len(k.hs256) == 0 && len(k.rs256) == 0
+}
+
+// keyFunc picks the keys a token may have been signed with. Keys are only
+// ever used with their own algorithm, so an RS256 public key can never be
+// taken for an HS256 secret. A token without a key ID is tried against
+// every key of its algorithm.
+func (k *KeySet) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	var keys []jwt.VerificationKey
+	switch t.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		for id, key := range k.hs256 {
+			if kid == "" || kid == id {
+				keys = append(keys, key)
+			}
+		}
+	case jwt.SigningMethodRS256.Alg():
+		for id, key := range k.rs256 {
+			if kid == "" || kid == id {
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return jwt.VerificationKeySet{Keys: keys}, nil
+}
+
+// JWT authenticates requests by a bearer token in the Authorization header.
+type JWT struct {
+	Keys *KeySet
+	// Issuer, if set, must be the token's iss claim.
+	Issuer string
+	// Leeway allows for clock skew when checking the token's times.
+	Leeway time.Duration
+}
+
+func (j *JWT) Authenticate(r *http.Request) (models.Principal, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return models.Principal{}, ErrNoCredentials
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(j.Leeway),
+	}
+	if j.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(j.Issuer))
+	}
+
+	var claims Claims
+	if _, err := jwt.ParseWithClaims(strings.TrimSpace(token), &claims, j.Keys.keyFunc, opts...); err != nil {
+		return models.Principal{}, invalidToken(err)
+	}
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil || id <= 0 {
+		return models.Principal{}, errors.New("invalid token: bad subject")
+	}
+	role := claims.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	return models.Principal{UserID: id, Name: claims.Name, Role: role, Method: "jwt"}, nil
+}
+
+// invalidToken turns a parse error into a message safe for clients.
+func invalidToken(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return errors.New("token has expired")
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return errors.New("token is not valid yet")
+	default:
+		return errors.New("invalid token")
+	}
+}
+
+// Issuer signs tokens for users, with an HS256 secret or an RS256 private
+// key.
+type Issuer struct {
+	method jwt.SigningMethod
+	key    any
+	kid    string
+	// Name is the iss claim of issued tokens.
+	Name string
+	// TTL is how long issued tokens are valid for.
+	TTL time.Duration
+}
+
+func NewHS256Issuer(kid string, secret []byte, name string, ttl time.Duration) *Issuer {
+	return &Issuer{method: jwt.SigningMethodHS256, key: secret, kid: kid, Name: name, TTL: ttl}
+}
+
+func NewRS256Issuer(kid string, key *rsa.PrivateKey, name string, ttl time.Duration) *Issuer {
+	return &Issuer{method: jwt.SigningMethodRS256, key: key, kid: kid, Name: name, TTL: ttl}
+}
+
+// Issue returns a signed token for the user p. The token carries p's role,
+// so a changed role takes effect once the user gets a new token.
+func (i *Issuer) Issue(p models.Principal) (models.Token, error) {
+	now := time.Now()
+	t := jwt.NewWithClaims(i.method, Claims{
+		Name: p.Name,
+		Role: p.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(p.UserID),
+			Issuer:    i.Name,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(i.TTL)),
+		},
+	})
+	if i.kid != "" {
+		t.Header["kid"] = i.kid
+	}
+	s, err := t.SignedString(i.key)
+	if err != nil {
+		return models.Token{}, err
+	}
+	return models.Token{AccessToken: s, TokenType: "Bearer", ExpiresIn: int(i.TTL.Seconds())}, nil
+}
+
+// ReadRSAPrivateKey reads a PEM encoded RSA private key from path.
+func ReadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ReadRSAPublicKey reads a PEM encoded RSA public key from path.
+func ReadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseRSAPublicKeyFromPEM(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// KeyID names a key after its file, e.g. "2024-01" for keys/2024-01.pem.
+func KeyID(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".pem")
+}
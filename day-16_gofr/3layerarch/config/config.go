@@ -0,0 +1,334 @@
+// Package config loads the server settings from, in increasing priority,
+// built-in defaults, a .env file, environment variables and flags.
+package config
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"3layerarch/models"
+)
+
+// DefaultFile is the .env file read when no -config flag is given.
+const DefaultFile = "configs/.env"
+
+// Secret is a string that prints as "****" so it never ends up in a log.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "****"
+}
+
+func (s Secret) GoString() string { return strconv.Quote(s.String()) }
+
+type DB struct {
+	Host     string
+	Port     int
+	User     string
+	Password Secret
+	Name     string
+
+	// Pool sizes; zero keeps the database/sql default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// DSN returns the MySQL data source name. It holds the password, so it must
+// not be logged.
+func (d DB) DSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", d.User, string(d.Password), d.Host, d.Port, d.Name)
+}
+
+// Server holds the port only; gofr manages its own timeouts.
+type Server struct {
+	Port int
+}
+
+// Features are behaviours that can be switched per deployment.
+type Features struct {
+	// DeletePolicy is what DELETE /user does with the user's tasks when the
+	// request does not say.
+	DeletePolicy models.DeletePolicy
+	// MigrateOnStart applies pending migrations at startup instead of
+	// refusing to serve.
+	MigrateOnStart bool
+}
+
+// Auth is how requests are authenticated. At least one of API keys, an HS256
+// secret or RS256 keys must be set.
+type Auth struct {
+	// APIKeys maps the name of each static API key to the key.
+	APIKeys map[string]Secret
+	// JWTSecret verifies HS256 tokens and, without a private key, signs
+	// the tokens issued to users.
+	JWTSecret Secret
+	// JWTPublicKeys are PEM files of the RSA keys that verify RS256 tokens.
+	JWTPublicKeys []string
+	// JWTPrivateKey is a PEM file of the RSA key that signs the tokens
+	// issued to users.
+	JWTPrivateKey string
+	JWTIssuer     string
+	TokenTTL      time.Duration
+}
+
+type Config struct {
+	DB       DB
+	Server   Server
+	Features Features
+	Auth     Auth
+}
+
+// setting is one configuration key, named as its environment variable. The
+// flag is the key in lower case with dashes, e.g. -db-host.
+type setting struct {
+	key   string
+	def   string
+	usage string
+}
+
+var settings = []setting{
+	{"DB_HOST", "localhost", "database host"},
+	{"DB_PORT", "3306", "database port"},
+	{"DB_USER", "root", "database user"},
+	{"DB_PASSWORD", "", "database password"},
+	{"DB_NAME", "gofr_db", "database name"},
+	{"DB_MAX_OPEN_CONNS", "0", "maximum open connections, 0 for no limit"},
+	{"DB_MAX_IDLE_CONNS", "2", "maximum idle connections"},
+	{"DB_CONN_MAX_LIFETIME", "0s", "maximum connection age, 0 for no limit"},
+	{"HTTP_PORT", "8000", "port to serve HTTP on"},
+	{"API_KEYS", "", "static API keys as comma separated name=key pairs"},
+	{"JWT_SECRET", "", "HS256 secret of at least 32 bytes for bearer tokens"},
+	{"JWT_PUBLIC_KEYS", "", "comma separated PEM files of RSA keys that verify RS256 tokens"},
+	{"JWT_PRIVATE_KEY", "", "PEM file of the RSA key that signs issued tokens"},
+	{"JWT_ISSUER", "3layerarch", "issuer of bearer tokens"},
+	{"JWT_TTL", "1h", "lifetime of issued tokens"},
+	{"USER_DELETE_POLICY", string(models.DeleteReject), "default task policy of user deletes: reject, cascade or reassign"},
+	{"MIGRATE_ON_START", "false", "apply pending migrations at startup"},
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// Load parses the flags in args and builds the configuration. It returns the
+// arguments left after the flags, such as a migrate command.
+func Load(args []string) (Config, []string, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	file := fs.String("config", DefaultFile, "path of the .env file to read")
+	for _, s := range settings {
+		fs.String(flagName(s.key), s.def, s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	values := map[string]string{}
+	for _, s := range settings {
+		values[s.key] = s.def
+	}
+
+	// A missing file is fine unless it was asked for by name
+	explicit := false
+	fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "config" })
+	if err := readEnvFile(*file, values); err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+		return Config{}, nil, err
+	}
+
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.key); ok {
+			values[s.key] = v
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if flagName(s.key) == f.Name {
+				values[s.key] = f.Value.String()
+			}
+		}
+	})
+
+	cfg, err := parse(values)
+	return cfg, fs.Args(), err
+}
+
+// list splits a comma separated value, dropping empty items.
+func list(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func readEnvFile(path string, values map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Println("Error closing config file:", err)
+		}
+	}()
+	if err := readEnv(f, values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// readEnv reads KEY=VALUE lines into values. Blank lines, # comments, an
+// "export " prefix and quotes around the value are allowed.
+func readEnv(r io.Reader, values map[string]string) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return sc.Err()
+}
+
+// parse converts and validates values, reporting every bad setting at once.
+func parse(values map[string]string) (Config, error) {
+	var errs []error
+	integer := func(key string) int {
+		n, err := strconv.Atoi(values[key])
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("%s must be a non-negative integer, got %q", key, values[key]))
+		}
+		return n
+	}
+	duration := func(key string) time.Duration {
+		d, err := time.ParseDuration(values[key])
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("%s must be a non-negative duration such as 5s, got %q", key, values[key]))
+		}
+		return d
+	}
+	boolean := func(key string) bool {
+		b, err := strconv.ParseBool(values[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s must be true or false, got %q", key, values[key]))
+		}
+		return b
+	}
+
+	cfg := Config{
+		DB: DB{
+			Host:            values["DB_HOST"],
+			Port:            integer("DB_PORT"),
+			User:            values["DB_USER"],
+			Password:        Secret(values["DB_PASSWORD"]),
+			Name:            values["DB_NAME"],
+			MaxOpenConns:    integer("DB_MAX_OPEN_CONNS"),
+			MaxIdleConns:    integer("DB_MAX_IDLE_CONNS"),
+			ConnMaxLifetime: duration("DB_CONN_MAX_LIFETIME"),
+		},
+		Server: Server{
+			Port: integer("HTTP_PORT"),
+		},
+		Features: Features{
+			DeletePolicy:   models.DeletePolicy(values["USER_DELETE_POLICY"]),
+			MigrateOnStart: boolean("MIGRATE_ON_START"),
+		},
+	}
+
+	cfg.Auth = Auth{
+		APIKeys:       map[string]Secret{},
+		JWTSecret:     Secret(values["JWT_SECRET"]),
+		JWTPublicKeys: list(values["JWT_PUBLIC_KEYS"]),
+		JWTPrivateKey: values["JWT_PRIVATE_KEY"],
+		JWTIssuer:     values["JWT_ISSUER"],
+		TokenTTL:      duration("JWT_TTL"),
+	}
+	for _, pair := range list(values["API_KEYS"]) {
+		name, key, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || key == "" {
+			// The value is not repeated, it may well hold a key
+			errs = append(errs, errors.New("API_KEYS must be comma separated name=key pairs"))
+			continue
+		}
+		cfg.Auth.APIKeys[name] = Secret(key)
+	}
+
+	for _, key := range []string{"DB_HOST", "DB_USER", "DB_NAME"} {
+		if values[key] == "" {
+			errs = append(errs, fmt.Errorf("%s is required", key))
+		}
+	}
+	if cfg.DB.Port < 1 || cfg.DB.Port > 65535 {
+		errs = append(errs, errors.New("DB_PORT must be between 1 and 65535"))
+	}
+	if cfg.Server.Port < 1 || cfg.Server.Port > 65535 {
+		errs = append(errs, errors.New("HTTP_PORT must be between 1 and 65535"))
+	}
+	if cfg.DB.MaxOpenConns > 0 && cfg.DB.MaxIdleConns > cfg.DB.MaxOpenConns {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS cannot be more than DB_MAX_OPEN_CONNS"))
+	}
+	switch cfg.Features.DeletePolicy {
+	case models.DeleteReject, models.DeleteCascade, models.DeleteReassign:
+	default:
+		errs = append(errs, fmt.Errorf("USER_DELETE_POLICY must be reject, cascade or reassign, got %q", cfg.Features.DeletePolicy))
+	}
+
+	if len(cfg.Auth.APIKeys) == 0 && cfg.Auth.JWTSecret == "" && len(cfg.Auth.JWTPublicKeys) == 0 && cfg.Auth.JWTPrivateKey == "" {
+		errs = append(errs, errors.New("no authentication configured: set API_KEYS, JWT_SECRET, JWT_PUBLIC_KEYS or JWT_PRIVATE_KEY"))
+	}
+	if n := len(cfg.Auth.JWTSecret); n > 0 && n < 32 {
+		errs = append(errs, errors.New("JWT_SECRET must be at least 32 bytes"))
+	}
+	if cfg.Auth.TokenTTL == 0 {
+		errs = append(errs, errors.New("JWT_TTL must be more than 0"))
+	}
+
+	if len(errs) > 0 {
+		return Config{}, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+// Export sets the environment variables gofr reads its own settings from,
+// so the SQL connection in gofr.Context and the HTTP port follow the loaded
+// configuration, flags included. Call it before gofr.New.
+func (c Config) Export() error {
+	env := map[string]string{
+		"DB_HOST":                c.DB.Host,
+		"DB_PORT":                strconv.Itoa(c.DB.Port),
+		"DB_USER":                c.DB.User,
+		"DB_PASSWORD":            string(c.DB.Password),
+		"DB_NAME":                c.DB.Name,
+		"DB_MAX_OPEN_CONNECTION": strconv.Itoa(c.DB.MaxOpenConns),
+		"DB_MAX_IDLE_CONNECTION": strconv.Itoa(c.DB.MaxIdleConns),
+		"HTTP_PORT":              strconv.Itoa(c.Server.Port),
+	}
+	for key, value := range env {
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
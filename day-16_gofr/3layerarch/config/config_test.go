@@ -0,0 +1,150 @@
+package config_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"3layerarch/config"
+	"3layerarch/models"
+)
+
+// testAuth is the least authentication a configuration needs.
+const testAuth = "API_KEYS=ci=test-key\n"
+
+// writeEnv writes an .env file with content to a temporary directory.
+func writeEnv(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, rest, err := config.Load([]string{"-config", writeEnv(t, testAuth), "migrate", "up"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := config.Config{
+		DB:       config.DB{Host: "localhost", Port: 3306, User: "root", Name: "gofr_db", MaxIdleConns: 2},
+		Server:   config.Server{Port: 8000},
+		Features: config.Features{DeletePolicy: models.DeleteReject},
+		Auth: config.Auth{
+			APIKeys:   map[string]config.Secret{"ci": "test-key"},
+			JWTIssuer: "3layerarch",
+			TokenTTL:  time.Hour,
+		},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("expected %+v, got %+v", want, cfg)
+	}
+	if !reflect.DeepEqual(rest, []string{"migrate", "up"}) {
+		t.Errorf("expected the migrate command to be left over, got %v", rest)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeEnv(t, `# comment
+export DB_HOST=filehost
+API_KEYS=ci=test-key
+DB_PASSWORD="s3cret"
+DB_NAME=filedb
+HTTP_PORT=9000
+`)
+	t.Setenv("DB_NAME", "envdb")
+	t.Setenv("HTTP_PORT", "9001")
+
+	cfg, _, err := config.Load([]string{"-config", path, "-http-port", "9002"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DB.Host != "filehost" || cfg.DB.Name != "envdb" || cfg.Server.Port != 9002 {
+		t.Errorf("expected file < env < flags, got %+v", cfg)
+	}
+	if want := "root:s3cret@tcp(filehost:3306)/envdb"; cfg.DB.DSN() != want {
+		t.Errorf("expected DSN %s, got %s", want, cfg.DB.DSN())
+	}
+}
+
+func TestLoad_Redaction(t *testing.T) {
+	t.Setenv("DB_PASSWORD", "hunter2")
+	t.Setenv("API_KEYS", "ci=hunter3")
+	t.Setenv("JWT_SECRET", "hunter4-is-a-secret-of-32-bytes!")
+
+	cfg, _, err := config.Load([]string{"-config", writeEnv(t, testAuth)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		if out := fmt.Sprintf(format, cfg); strings.Contains(out, "hunter") {
+			t.Errorf("%s leaked the password: %s", format, out)
+		}
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{"-http-port", "http"}, "HTTP_PORT must be a non-negative integer"},
+		{[]string{"-http-port", "70000"}, "HTTP_PORT must be between 1 and 65535"},
+		{[]string{"-db-conn-max-lifetime", "5"}, "DB_CONN_MAX_LIFETIME must be a non-negative duration"},
+		{[]string{"-db-host", ""}, "DB_HOST is required"},
+		{[]string{"-db-max-open-conns", "1", "-db-max-idle-conns", "5"}, "DB_MAX_IDLE_CONNS cannot be more than DB_MAX_OPEN_CONNS"},
+		{[]string{"-user-delete-policy", "archive"}, "USER_DELETE_POLICY must be reject, cascade or reassign"},
+		{[]string{"-migrate-on-start", "perhaps"}, "MIGRATE_ON_START must be true or false"},
+		{[]string{"-api-keys", "ci"}, "API_KEYS must be comma separated name=key pairs"},
+		{[]string{"-api-keys", ""}, "no authentication configured"},
+		{[]string{"-jwt-secret", "short"}, "JWT_SECRET must be at least 32 bytes"},
+		{[]string{"-jwt-ttl", "0s"}, "JWT_TTL must be more than 0"},
+		{[]string{"-no-such-flag"}, "flag provided but not defined"},
+	}
+
+	for _, tc := range tests {
+		args := append([]string{"-config", writeEnv(t, testAuth)}, tc.args...)
+		_, _, err := config.Load(args)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%v: expected %q, got %v", tc.args, tc.wantErr, err)
+		}
+	}
+
+	// Every problem is reported at once
+	_, _, err := config.Load([]string{"-config", writeEnv(t, testAuth), "-db-port", "x", "-http-port", "y"})
+	if err == nil || !strings.Contains(err.Error(), "DB_PORT") || !strings.Contains(err.Error(), "HTTP_PORT") {
+		t.Errorf("expected both ports to be reported, got %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	// Start from an empty environment and restore it once the test is done
+	for _, key := range []string{"DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME", "DB_MAX_OPEN_CONNECTION", "DB_MAX_IDLE_CONNECTION", "HTTP_PORT"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	cfg, _, err := config.Load([]string{"-config", writeEnv(t, testAuth+"DB_PASSWORD=s3cret"), "-http-port", "9000"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cfg.Export(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if os.Getenv("HTTP_PORT") != "9000" || os.Getenv("DB_PASSWORD") != "s3cret" {
+		t.Errorf("expected gofr settings in the environment, got port %q", os.Getenv("HTTP_PORT"))
+	}
+}
+
+func TestLoad_File(t *testing.T) {
+	if _, _, err := config.Load([]string{"-config", writeEnv(t, "DB_HOST")}); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected a parse error on line 1, got %v", err)
+	}
+	if _, _, err := config.Load([]string{"-config", filepath.Join(t.TempDir(), "missing.env")}); err == nil {
+		t.Error("expected an error for a missing file named by -config")
+	}
+}
@@ -2,13 +2,19 @@ package datasource
 
 import (
 	"database/sql"
+
+	"3layerarch/config"
 )
 
-func New(creds string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", creds)
+// New opens the database described by cfg with its pool settings applied.
+func New(cfg config.DB) (*sql.DB, error) {
+	db, err := sql.Open("mysql", cfg.DSN())
 	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
 	err = db.Ping()
 
@@ -16,25 +22,5 @@ func New(creds string) (*sql.DB, error) {
 		return nil, err
 	}
 
-	// Ensure TASKS table exists
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS TASKS (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		task TEXT,
-		completed BOOL DEFAULT FALSE,
-		user_id int
-	);`)
-	if err != nil {
-		return nil, err
-	}
-
-	// Ensure USERS table exists
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS USERS (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		name VARCHAR(100)
-	);`)
-	if err != nil {
-		return nil, err
-	}
-
 	return db, nil
 }
@@ -0,0 +1,56 @@
+package authhandler
+
+import (
+	"3layerarch/handler"
+	"3layerarch/models"
+	"gofr.dev/pkg/gofr"
+	"gofr.dev/pkg/gofr/http/response"
+)
+
+type Handler struct {
+	Users  UserService
+	Tokens TokenIssuer
+}
+
+func New(users UserService, tokens TokenIssuer) *Handler {
+	return &Handler{Users: users, Tokens: tokens}
+}
+
+// Token godoc
+// @Summary Issue a token
+// @Description Exchanges a user's name and password for a JWT bearer token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body models.Credentials true "User name and password"
+// @Success 200 {object} models.Token
+// @Failure 400 {string} string "Bad Request"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /auth/token [post]
+func (h *Handler) Token(ctx *gofr.Context) (interface{}, error) {
+	var c models.Credentials
+	if err := ctx.Bind(&c); err != nil {
+		return nil, handler.BadRequest("invalid JSON input")
+	}
+
+	if c.Name == "" || c.Password == "" {
+		return nil, handler.BadRequest("name and password are required")
+	}
+
+	p, err := h.Users.Authenticate(ctx, c.Name, c.Password)
+	if err != nil {
+		return nil, handler.Error(ctx, err)
+	}
+
+	t, err := h.Tokens.Issue(p)
+	if err != nil {
+		return nil, handler.Error(ctx, models.Internal(err))
+	}
+
+	// Tokens must not be kept by caches along the way
+	return response.Response{
+		Data:    t,
+		Headers: map[string]string{"Cache-Control": "no-store"},
+	}, nil
+}
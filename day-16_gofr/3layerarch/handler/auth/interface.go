@@ -0,0 +1,14 @@
+package authhandler
+
+import (
+	"3layerarch/models"
+	"gofr.dev/pkg/gofr"
+)
+
+type UserService interface {
+	Authenticate(ctx *gofr.Context, name, password string) (models.Principal, error)
+}
+
+type TokenIssuer interface {
+	Issue(p models.Principal) (models.Token, error)
+}
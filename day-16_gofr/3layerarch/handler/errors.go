@@ -0,0 +1,47 @@
+// Package handler holds the helpers shared by the task and user handlers.
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"3layerarch/models"
+	"gofr.dev/pkg/gofr"
+)
+
+// statusError is an error that gofr reports with its own status code, as
+// {"error": {"message": ...}}.
+type statusError struct {
+	status int
+	msg    string
+}
+
+func (e statusError) Error() string { return e.msg }
+
+func (e statusError) StatusCode() int { return e.status }
+
+// Error maps a service error to the status code gofr should respond with.
+// Errors that are not a known domain kind are logged and reported as internal
+// errors so that database details never reach clients.
+func Error(ctx *gofr.Context, err error) error {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return statusError{status: http.StatusNotFound, msg: err.Error()}
+	case errors.Is(err, models.ErrValidation):
+		return statusError{status: http.StatusUnprocessableEntity, msg: err.Error()}
+	case errors.Is(err, models.ErrConflict):
+		return statusError{status: http.StatusConflict, msg: err.Error()}
+	case errors.Is(err, models.ErrUnauthorized):
+		return statusError{status: http.StatusUnauthorized, msg: err.Error()}
+	case errors.Is(err, models.ErrForbidden):
+		return statusError{status: http.StatusForbidden, msg: err.Error()}
+	default:
+		ctx.Logger.Errorf("internal error: %v", err)
+		return statusError{status: http.StatusInternalServerError, msg: "internal server error"}
+	}
+}
+
+// BadRequest reports a request that could not be parsed.
+func BadRequest(msg string) error {
+	return statusError{status: http.StatusBadRequest, msg: msg}
+}
@@ -1,10 +1,16 @@
 package taskhandler
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
 	"strconv"
 
+	"3layerarch/handler"
 	"3layerarch/models"
 	"gofr.dev/pkg/gofr"
+	"gofr.dev/pkg/gofr/http/response"
 )
 
 type Handler struct {
@@ -22,24 +28,30 @@ func New(service TaskService) *Handler {
 // @Accept json
 // @Produce json
 // @Param task body models.Task true "Task to create"
-// @Success 201 {string} string "Created"
+// @Success 201 {object} models.Task
+// @Header 201 {string} Location "URL of the new task"
 // @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
 // @Router /task [post]
 func (h *Handler) CreateTask(ctx *gofr.Context) (interface{}, error) {
 	var t models.Task
 
 	err := ctx.Bind(&t)
 	if err != nil {
-		return nil, err
+		return nil, handler.BadRequest("invalid JSON input")
 	}
 
-	err = h.Service.CreateTask(ctx, t)
-
+	created, err := h.Service.CreateTask(ctx, t)
 	if err != nil {
-		return nil, err
+		return nil, handler.Error(ctx, err)
 	}
 
-	return "Task created", nil
+	return response.Response{
+		Data:    created,
+		Headers: map[string]string{"Location": "/task/" + strconv.Itoa(created.ID)},
+	}, nil
 }
 
 // GetTask godoc
@@ -50,59 +62,271 @@ func (h *Handler) CreateTask(ctx *gofr.Context) (interface{}, error) {
 // @Param id path int true "Task ID"
 // @Success 200 {object} models.Task
 // @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
 // @Router /task/{id} [get]
 func (h *Handler) GetTask(ctx *gofr.Context) (interface{}, error) {
 	idStr := ctx.PathParam("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return nil, err
+		return nil, handler.BadRequest("invalid ID format")
 	}
 
 	t, err := h.Service.GetTask(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, handler.Error(ctx, err)
 	}
 
 	return t, nil
 }
 
 // ViewTasks godoc
-// @Summary List all tasks
-// @Description Returns a list of all tasks
+// @Summary List tasks
+// @Description Returns a page of tasks, optionally filtered and sorted
 // @Tags tasks
 // @Produce json
-// @Success 200 {array} models.Task
+// @Param completed query bool false "Only tasks with this completion state"
+// @Param user_id query int false "Only tasks owned by this user"
+// @Param q query string false "Text the task must contain"
+// @Param sort query string false "Sort field (id, task, completed, user_id); prefix with - for descending"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of tasks to skip"
+// @Success 200 {object} models.TaskPage
+// @Failure 400 {string} string "Bad Request"
+// @Failure 422 {string} string "Unprocessable Entity"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /task [get]
 func (h *Handler) ViewTasks(ctx *gofr.Context) (interface{}, error) {
-	tasks, err := h.Service.ViewTasks(ctx)
+	f, err := parseTaskFilter(ctx)
+	if err != nil {
+		return nil, handler.BadRequest(err.Error())
+	}
+
+	page, err := h.Service.ViewTasks(ctx, f)
+	if err != nil {
+		return nil, handler.Error(ctx, err)
+	}
+
+	if page.Offset+len(page.Tasks) < page.Total {
+		f.Limit, f.Offset = page.Limit, page.Offset+page.Limit
+		page.Next = "/task?" + taskFilterQuery(f).Encode()
+	}
+
+	return page, nil
+}
+
+// ViewUserTasks godoc
+// @Summary List a user's tasks
+// @Description Returns a page of the tasks of one user, filtered and sorted like the task list
+// @Tags tasks
+// @Produce json
+// @Param id path int true "User ID"
+// @Param completed query bool false "Only tasks with this completion state"
+// @Param q query string false "Text the task must contain"
+// @Param sort query string false "Sort field (id, task, completed, user_id); prefix with - for descending"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of tasks to skip"
+// @Success 200 {object} models.TaskPage
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /user/{id}/tasks [get]
+func (h *Handler) ViewUserTasks(ctx *gofr.Context) (interface{}, error) {
+	userID, err := strconv.Atoi(ctx.PathParam("id"))
+	if err != nil {
+		return nil, handler.BadRequest("invalid ID format")
+	}
+
+	f, err := parseTaskFilter(ctx)
+	if err != nil {
+		return nil, handler.BadRequest(err.Error())
+	}
+	if f.UserID != nil {
+		return nil, handler.BadRequest("user_id cannot be used here")
+	}
+
+	page, err := h.Service.ViewUserTasks(ctx, userID, f)
 	if err != nil {
-		return nil, err
+		return nil, handler.Error(ctx, err)
+	}
+
+	if page.Offset+len(page.Tasks) < page.Total {
+		f.Limit, f.Offset = page.Limit, page.Offset+page.Limit
+		page.Next = fmt.Sprintf("/user/%d/tasks?%s", userID, taskFilterQuery(f).Encode())
 	}
 
-	return tasks, nil
+	return page, nil
+}
+
+// parseTaskFilter reads a task filter from the query string of a listing.
+func parseTaskFilter(ctx *gofr.Context) (models.TaskFilter, error) {
+	f := models.TaskFilter{Search: ctx.Param("q"), Sort: ctx.Param("sort")}
+
+	if v := ctx.Param("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return models.TaskFilter{}, errors.New("invalid completed value")
+		}
+
+		f.Completed = &completed
+	}
+
+	if v := ctx.Param("user_id"); v != "" {
+		userID, err := strconv.Atoi(v)
+		if err != nil {
+			return models.TaskFilter{}, errors.New("invalid user_id value")
+		}
+
+		f.UserID = &userID
+	}
+
+	for name, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := ctx.Param(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return models.TaskFilter{}, fmt.Errorf("invalid %s value", name)
+			}
+
+			*dst = n
+		}
+	}
+
+	return f, nil
+}
+
+// taskFilterQuery is the inverse of parseTaskFilter.
+func taskFilterQuery(f models.TaskFilter) url.Values {
+	q := url.Values{}
+
+	if f.Completed != nil {
+		q.Set("completed", strconv.FormatBool(*f.Completed))
+	}
+
+	if f.UserID != nil {
+		q.Set("user_id", strconv.Itoa(*f.UserID))
+	}
+
+	if f.Search != "" {
+		q.Set("q", f.Search)
+	}
+
+	if f.Sort != "" {
+		q.Set("sort", f.Sort)
+	}
+
+	q.Set("limit", strconv.Itoa(f.Limit))
+	q.Set("offset", strconv.Itoa(f.Offset))
+
+	return q
 }
 
 // UpdateTask godoc
-// @Summary Update task status
-// @Description Marks a task as completed
+// @Summary Replace a task
+// @Description Replaces every field of a task and returns the updated task
 // @Tags tasks
+// @Accept json
+// @Produce json
 // @Param id path int true "Task ID"
-// @Success 200 {string} string "OK"
+// @Param task body models.Task true "New task state"
+// @Success 200 {object} models.Task
 // @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
 // @Router /task/{id} [put]
 func (h *Handler) UpdateTask(ctx *gofr.Context) (interface{}, error) {
 	idStr := ctx.PathParam("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return nil, err
+		return nil, handler.BadRequest("invalid ID format")
 	}
 
-	if err := h.Service.UpdateTask(ctx, id); err != nil {
-		return nil, err
+	var t models.Task
+	if err := ctx.Bind(&t); err != nil {
+		return nil, handler.BadRequest("invalid JSON input")
 	}
 
-	return "Task updated", nil
+	updated, err := h.Service.UpdateTask(ctx, id, t)
+	if err != nil {
+		return nil, handler.Error(ctx, err)
+	}
+
+	return updated, nil
+}
+
+// PatchTask godoc
+// @Summary Partially update a task
+// @Description Applies a JSON merge-patch to a task and returns the updated task
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param patch body models.TaskPatch true "Fields to change"
+// @Success 200 {object} models.Task
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /task/{id} [patch]
+func (h *Handler) PatchTask(ctx *gofr.Context) (interface{}, error) {
+	idStr := ctx.PathParam("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, handler.BadRequest("invalid ID format")
+	}
+
+	var raw map[string]json.RawMessage
+	if err := ctx.Bind(&raw); err != nil {
+		return nil, handler.BadRequest("invalid JSON input")
+	}
+
+	p, err := decodePatch(raw)
+	if err != nil {
+		return nil, handler.BadRequest(err.Error())
+	}
+
+	updated, err := h.Service.PatchTask(ctx, id, p)
+	if err != nil {
+		return nil, handler.Error(ctx, err)
+	}
+
+	return updated, nil
+}
+
+// decodePatch turns the members of a JSON merge-patch (RFC 7396) into a
+// TaskPatch. Every task field is required, so a null, which would remove the
+// field, is rejected, as are fields the task does not have.
+func decodePatch(raw map[string]json.RawMessage) (models.TaskPatch, error) {
+	var p models.TaskPatch
+
+	for field, v := range raw {
+		if string(v) == "null" {
+			return models.TaskPatch{}, fmt.Errorf("%s cannot be null", field)
+		}
+
+		var err error
+
+		switch field {
+		case "task":
+			err = json.Unmarshal(v, &p.Task)
+		case "completed":
+			err = json.Unmarshal(v, &p.Completed)
+		case "user_id":
+			err = json.Unmarshal(v, &p.UserID)
+		default:
+			return models.TaskPatch{}, fmt.Errorf("unknown field %q", field)
+		}
+
+		if err != nil {
+			return models.TaskPatch{}, fmt.Errorf("invalid %s: %w", field, err)
+		}
+	}
+
+	return p, nil
 }
 
 // DeleteTask godoc
@@ -112,16 +336,19 @@ func (h *Handler) UpdateTask(ctx *gofr.Context) (interface{}, error) {
 // @Param id path int true "Task ID"
 // @Success 200 {string} string "OK"
 // @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
 // @Router /task/{id} [delete]
 func (h *Handler) DeleteTask(ctx *gofr.Context) (interface{}, error) {
 	idStr := ctx.PathParam("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return nil, err
+		return nil, handler.BadRequest("invalid ID format")
 	}
 
 	if err := h.Service.DeleteTask(ctx, id); err != nil {
-		return nil, err
+		return nil, handler.Error(ctx, err)
 	}
 
 	return "Task deleted", nil
@@ -6,9 +6,11 @@ import (
 )
 
 type TaskService interface {
-	CreateTask(ctx *gofr.Context, t models.Task) error
+	CreateTask(ctx *gofr.Context, t models.Task) (models.Task, error)
 	GetTask(ctx *gofr.Context, id int) (models.Task, error)
-	ViewTasks(ctx *gofr.Context) ([]models.Task, error)
-	UpdateTask(ctx *gofr.Context, id int) error
+	ViewTasks(ctx *gofr.Context, f models.TaskFilter) (models.TaskPage, error)
+	ViewUserTasks(ctx *gofr.Context, userID int, f models.TaskFilter) (models.TaskPage, error)
+	UpdateTask(ctx *gofr.Context, id int, t models.Task) (models.Task, error)
+	PatchTask(ctx *gofr.Context, id int, p models.TaskPatch) (models.Task, error)
 	DeleteTask(ctx *gofr.Context, id int) error
 }
@@ -1,3 +1,12 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: interface.go
+//
+// Generated by this command:
+//
+//	mockgen -source=interface.go -destination=mock_interface.go -package=taskhandler
+//
+
+// Package taskhandler is a generated GoMock package.
 package taskhandler
 
 import (
@@ -5,6 +14,7 @@ import (
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
+	gofr "gofr.dev/pkg/gofr"
 )
 
 // MockTaskService is a mock of TaskService interface.
@@ -32,73 +42,105 @@ func (m *MockTaskService) EXPECT() *MockTaskServiceMockRecorder {
 }
 
 // CreateTask mocks base method.
-func (m *MockTaskService) CreateTask(t models.Task) error {
+func (m *MockTaskService) CreateTask(ctx *gofr.Context, t models.Task) (models.Task, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTask", t)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateTask", ctx, t)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // CreateTask indicates an expected call of CreateTask.
-func (mr *MockTaskServiceMockRecorder) CreateTask(t any) *gomock.Call {
+func (mr *MockTaskServiceMockRecorder) CreateTask(ctx, t any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTask", reflect.TypeOf((*MockTaskService)(nil).CreateTask), t)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTask", reflect.TypeOf((*MockTaskService)(nil).CreateTask), ctx, t)
 }
 
 // DeleteTask mocks base method.
-func (m *MockTaskService) DeleteTask(id int) error {
+func (m *MockTaskService) DeleteTask(ctx *gofr.Context, id int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteTask", id)
+	ret := m.ctrl.Call(m, "DeleteTask", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteTask indicates an expected call of DeleteTask.
-func (mr *MockTaskServiceMockRecorder) DeleteTask(id any) *gomock.Call {
+func (mr *MockTaskServiceMockRecorder) DeleteTask(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTask", reflect.TypeOf((*MockTaskService)(nil).DeleteTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTask", reflect.TypeOf((*MockTaskService)(nil).DeleteTask), ctx, id)
 }
 
 // GetTask mocks base method.
-func (m *MockTaskService) GetTask(id int) (models.Task, error) {
+func (m *MockTaskService) GetTask(ctx *gofr.Context, id int) (models.Task, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTask", id)
+	ret := m.ctrl.Call(m, "GetTask", ctx, id)
 	ret0, _ := ret[0].(models.Task)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetTask indicates an expected call of GetTask.
-func (mr *MockTaskServiceMockRecorder) GetTask(id any) *gomock.Call {
+func (mr *MockTaskServiceMockRecorder) GetTask(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTask", reflect.TypeOf((*MockTaskService)(nil).GetTask), ctx, id)
+}
+
+// PatchTask mocks base method.
+func (m *MockTaskService) PatchTask(ctx *gofr.Context, id int, p models.TaskPatch) (models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchTask", ctx, id, p)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchTask indicates an expected call of PatchTask.
+func (mr *MockTaskServiceMockRecorder) PatchTask(ctx, id, p any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTask", reflect.TypeOf((*MockTaskService)(nil).GetTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchTask", reflect.TypeOf((*MockTaskService)(nil).PatchTask), ctx, id, p)
 }
 
 // UpdateTask mocks base method.
-func (m *MockTaskService) UpdateTask(id int) error {
+func (m *MockTaskService) UpdateTask(ctx *gofr.Context, id int, t models.Task) (models.Task, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateTask", id)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "UpdateTask", ctx, id, t)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // UpdateTask indicates an expected call of UpdateTask.
-func (mr *MockTaskServiceMockRecorder) UpdateTask(id any) *gomock.Call {
+func (mr *MockTaskServiceMockRecorder) UpdateTask(ctx, id, t any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTask", reflect.TypeOf((*MockTaskService)(nil).UpdateTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTask", reflect.TypeOf((*MockTaskService)(nil).UpdateTask), ctx, id, t)
 }
 
 // ViewTasks mocks base method.
-func (m *MockTaskService) ViewTasks() ([]models.Task, error) {
+func (m *MockTaskService) ViewTasks(ctx *gofr.Context, f models.TaskFilter) (models.TaskPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ViewTasks")
-	ret0, _ := ret[0].([]models.Task)
+	ret := m.ctrl.Call(m, "ViewTasks", ctx, f)
+	ret0, _ := ret[0].(models.TaskPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ViewTasks indicates an expected call of ViewTasks.
-func (mr *MockTaskServiceMockRecorder) ViewTasks() *gomock.Call {
+func (mr *MockTaskServiceMockRecorder) ViewTasks(ctx, f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewTasks", reflect.TypeOf((*MockTaskService)(nil).ViewTasks), ctx, f)
+}
+
+// ViewUserTasks mocks base method.
+func (m *MockTaskService) ViewUserTasks(ctx *gofr.Context, userID int, f models.TaskFilter) (models.TaskPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ViewUserTasks", ctx, userID, f)
+	ret0, _ := ret[0].(models.TaskPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ViewUserTasks indicates an expected call of ViewUserTasks.
+func (mr *MockTaskServiceMockRecorder) ViewUserTasks(ctx, userID, f any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewTasks", reflect.TypeOf((*MockTaskService)(nil).ViewTasks))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewUserTasks", reflect.TypeOf((*MockTaskService)(nil).ViewUserTasks), ctx, userID, f)
 }
@@ -1,10 +1,16 @@
 package userhandler
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"3layerarch/handler"
 	"3layerarch/models"
 	"gofr.dev/pkg/gofr"
+	"gofr.dev/pkg/gofr/http/response"
 )
 
 type Handler struct {
@@ -22,42 +28,261 @@ func New(service UserService) *Handler {
 // @Accept json
 // @Produce json
 // @Param user body models.User true "User to create"
-// @Success 201 {string} string "Created"
+// @Success 201 {object} models.User
+// @Header 201 {string} Location "URL of the new user"
 // @Failure 400 {string} string "Bad Request"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
 // @Router /user [post]
 func (h *Handler) CreateUser(ctx *gofr.Context) (interface{}, error) {
 	var u models.User
 	if err := ctx.Bind(&u); err != nil {
-		return nil, err
+		return nil, handler.BadRequest("invalid JSON input")
 	}
 
-	if err := h.Service.CreateUser(ctx, u); err != nil {
-		return nil, err
+	created, err := h.Service.CreateUser(ctx, u)
+	if err != nil {
+		return nil, handler.Error(ctx, err)
 	}
 
-	return "error", nil
+	return response.Response{
+		Data:    created,
+		Headers: map[string]string{"Location": "/user/" + strconv.Itoa(created.ID)},
+	}, nil
 }
 
 // GetUser godoc
 // @Summary Get user by ID
-// @Description Returns a user given their ID
+// @Description Returns a user given their ID, optionally with their tasks and task counts. Only the user themselves or an admin may include tasks or stats
 // @Tags users
 // @Produce json
 // @Param id path int true "User ID"
-// @Success 200 {object} models.User
+// @Param include query string false "Comma-separated related data to add: tasks, stats"
+// @Success 200 {object} models.UserDetail
 // @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
 // @Router /user/{id} [get]
 func (h *Handler) GetUser(ctx *gofr.Context) (interface{}, error) {
 	idStr := ctx.PathParam("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return nil, err
+		return nil, handler.BadRequest("invalid ID format")
+	}
+
+	inc, err := parseInclude(ctx.Param("include"))
+	if err != nil {
+		return nil, handler.BadRequest(err.Error())
+	}
+
+	if inc == (models.UserInclude{}) {
+		u, err := h.Service.GetUser(ctx, id)
+		if err != nil {
+			return nil, handler.Error(ctx, err)
+		}
+
+		return u, nil
+	}
+
+	d, err := h.Service.GetUserDetail(ctx, id, inc)
+	if err != nil {
+		return nil, handler.Error(ctx, err)
+	}
+
+	return d, nil
+}
+
+// parseInclude reads the include query parameter of a user fetch.
+func parseInclude(v string) (models.UserInclude, error) {
+	var inc models.UserInclude
+	if v == "" {
+		return inc, nil
+	}
+
+	for _, name := range strings.Split(v, ",") {
+		switch strings.TrimSpace(name) {
+		case "tasks":
+			inc.Tasks = true
+		case "stats":
+			inc.Stats = true
+		default:
+			return models.UserInclude{}, fmt.Errorf("cannot include %q", name)
+		}
+	}
+
+	return inc, nil
+}
+
+// ViewUsers godoc
+// @Summary List users
+// @Description Returns a page of users ordered by ID. Only admins list users
+// @Tags users
+// @Produce json
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of users to skip"
+// @Success 200 {object} models.UserPage
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /user [get]
+func (h *Handler) ViewUsers(ctx *gofr.Context) (interface{}, error) {
+	var f models.UserFilter
+
+	for name, dst := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := ctx.Param(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, handler.BadRequest(fmt.Sprintf("invalid %s value", name))
+			}
+
+			*dst = n
+		}
+	}
+
+	page, err := h.Service.ViewUsers(ctx, f)
+	if err != nil {
+		return nil, handler.Error(ctx, err)
+	}
+
+	if page.Offset+len(page.Users) < page.Total {
+		q := url.Values{}
+		q.Set("limit", strconv.Itoa(page.Limit))
+		q.Set("offset", strconv.Itoa(page.Offset+page.Limit))
+		page.Next = "/user?" + q.Encode()
+	}
+
+	return page, nil
+}
+
+// UpdateUser godoc
+// @Summary Replace a user
+// @Description Replaces every field of a user and returns the updated user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param user body models.User true "New user state"
+// @Success 200 {object} models.User
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /user/{id} [put]
+func (h *Handler) UpdateUser(ctx *gofr.Context) (interface{}, error) {
+	id, err := strconv.Atoi(ctx.PathParam("id"))
+	if err != nil {
+		return nil, handler.BadRequest("invalid ID format")
 	}
 
-	u, err := h.Service.GetUser(ctx, id)
+	var u models.User
+	if err := ctx.Bind(&u); err != nil {
+		return nil, handler.BadRequest("invalid JSON input")
+	}
+
+	updated, err := h.Service.UpdateUser(ctx, id, u)
+	if err != nil {
+		return nil, handler.Error(ctx, err)
+	}
+
+	return updated, nil
+}
+
+// PatchUser godoc
+// @Summary Partially update a user
+// @Description Applies a JSON merge-patch to a user and returns the updated user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param patch body models.UserPatch true "Fields to change"
+// @Success 200 {object} models.User
+// @Failure 400 {string} string "Bad Request"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /user/{id} [patch]
+func (h *Handler) PatchUser(ctx *gofr.Context) (interface{}, error) {
+	id, err := strconv.Atoi(ctx.PathParam("id"))
+	if err != nil {
+		return nil, handler.BadRequest("invalid ID format")
+	}
+
+	var raw map[string]json.RawMessage
+	if err := ctx.Bind(&raw); err != nil {
+		return nil, handler.BadRequest("invalid JSON input")
+	}
+
+	p, err := decodePatch(raw)
+	if err != nil {
+		return nil, handler.BadRequest(err.Error())
+	}
+
+	updated, err := h.Service.PatchUser(ctx, id, p)
 	if err != nil {
-		return nil, err
+		return nil, handler.Error(ctx, err)
+	}
+
+	return updated, nil
+}
+
+// decodePatch turns the members of a JSON merge-patch (RFC 7396) into a
+// UserPatch. A null name, which would remove it, is rejected, as are fields
+// the user does not have.
+func decodePatch(raw map[string]json.RawMessage) (models.UserPatch, error) {
+	var p models.UserPatch
+
+	for field, v := range raw {
+		if string(v) == "null" {
+			return models.UserPatch{}, fmt.Errorf("%s cannot be null", field)
+		}
+
+		if field != "name" {
+			return models.UserPatch{}, fmt.Errorf("unknown field %q", field)
+		}
+
+		if err := json.Unmarshal(v, &p.Name); err != nil {
+			return models.UserPatch{}, fmt.Errorf("invalid %s: %w", field, err)
+		}
+	}
+
+	return p, nil
+}
+
+// DeleteUser godoc
+// @Summary Delete user
+// @Description Deletes a user. The tasks parameter picks what happens to their tasks.
+// @Tags users
+// @Param id path int true "User ID"
+// @Param tasks query string false "reject (default), cascade or reassign"
+// @Param reassign_to query int false "User who takes over the tasks when reassigning"
+// @Success 200 {string} string "OK"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Forbidden"
+// @Failure 404 {string} string "Not Found"
+// @Failure 409 {string} string "Conflict"
+// @Failure 422 {string} string "Unprocessable Entity"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /user/{id} [delete]
+func (h *Handler) DeleteUser(ctx *gofr.Context) (interface{}, error) {
+	id, err := strconv.Atoi(ctx.PathParam("id"))
+	if err != nil {
+		return nil, handler.BadRequest("invalid ID format")
+	}
+
+	d := models.UserDelete{Policy: models.DeletePolicy(ctx.Param("tasks"))}
+	if v := ctx.Param("reassign_to"); v != "" {
+		if d.ReassignTo, err = strconv.Atoi(v); err != nil {
+			return nil, handler.BadRequest("invalid reassign_to value")
+		}
+	}
+
+	if err := h.Service.DeleteUser(ctx, id, d); err != nil {
+		return nil, handler.Error(ctx, err)
 	}
 
-	return u, nil
+	return "User deleted", nil
 }
@@ -6,6 +6,11 @@ import (
 )
 
 type UserService interface {
-	CreateUser(ctx *gofr.Context, u models.User) error
+	CreateUser(ctx *gofr.Context, u models.User) (models.User, error)
 	GetUser(ctx *gofr.Context, id int) (models.User, error)
+	GetUserDetail(ctx *gofr.Context, id int, inc models.UserInclude) (models.UserDetail, error)
+	ViewUsers(ctx *gofr.Context, f models.UserFilter) (models.UserPage, error)
+	UpdateUser(ctx *gofr.Context, id int, u models.User) (models.User, error)
+	PatchUser(ctx *gofr.Context, id int, p models.UserPatch) (models.User, error)
+	DeleteUser(ctx *gofr.Context, id int, d models.UserDelete) error
 }
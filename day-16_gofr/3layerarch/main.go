@@ -15,9 +15,16 @@
 package main
 
 import (
-	"database/sql"
 	"log"
+	"os"
+	"time"
 
+	"3layerarch/auth"
+	"3layerarch/config"
+	"3layerarch/datasource"
+	"3layerarch/migrate"
+
+	authhandler "3layerarch/handler/auth"
 	taskhandler "3layerarch/handler/task"
 	userhandler "3layerarch/handler/user"
 
@@ -34,18 +41,51 @@ import (
 )
 
 func main() {
-	app := gofr.New()
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Println("Config error:", err)
+		return
+	}
+	log.Printf("Config: %+v", cfg) // the password is redacted
 
 	// Initialize DB
-	db, err := sql.Open("mysql", "root:root123@tcp(localhost:3306)/test_db")
+	db, err := datasource.New(cfg.DB)
 	if err != nil {
 		log.Println("Failed to connect to DB:", err)
 		return
 	}
 
+	// "migrate up|down|status" changes the schema instead of serving
+	m := migrate.New(db, migrate.Migrations)
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := migrate.Run(m, args[1:], os.Stdout); err != nil {
+			log.Println("Migration failed:", err)
+		}
+		return
+	}
+	if cfg.Features.MigrateOnStart {
+		if err := migrate.Run(m, []string{"up"}, os.Stdout); err != nil {
+			log.Println("Migration failed:", err)
+			return
+		}
+	}
+	if err := m.Check(); err != nil {
+		log.Println("Schema check failed:", err)
+		return
+	}
+
+	// gofr reads its own settings, including the SQL connection behind
+	// gofr.Context, from the environment
+	if err := cfg.Export(); err != nil {
+		log.Println("Config error:", err)
+		return
+	}
+	app := gofr.New()
+
 	// Setup dependencies
 	userStore := userstore.New(db)
 	userService := userservice.New(userStore)
+	userService.DeletePolicy = cfg.Features.DeletePolicy
 	userHandler := userhandler.New(userService)
 
 	taskStore := taskstore.New(db)
@@ -57,11 +97,76 @@ func main() {
 	app.GET("/task", taskHandler.ViewTasks)
 	app.GET("/task/{id}", taskHandler.GetTask)
 	app.PUT("/task/{id}", taskHandler.UpdateTask)
+	app.PATCH("/task/{id}", taskHandler.PatchTask)
 	app.DELETE("/task/{id}", taskHandler.DeleteTask)
 
 	// Register user routes
 	app.POST("/user", userHandler.CreateUser)
+	app.GET("/user", userHandler.ViewUsers)
 	app.GET("/user/{id}", userHandler.GetUser)
+	app.GET("/user/{id}/tasks", taskHandler.ViewUserTasks)
+	app.PUT("/user/{id}", userHandler.UpdateUser)
+	app.PATCH("/user/{id}", userHandler.PatchUser)
+	app.DELETE("/user/{id}", userHandler.DeleteUser)
+
+	// Authentication; tokens are only issued when there is a key to sign them.
+	// gofr's own endpoints, such as health and the API docs, stay open.
+	authn, issuer, err := newAuth(cfg.Auth)
+	if err != nil {
+		log.Println("Auth setup failed:", err)
+		return
+	}
+
+	if issuer != nil {
+		app.POST("/auth/token", authhandler.New(userService, issuer).Token)
+	}
+
+	authn.Public = append(authn.Public, "/.well-known/")
+	app.UseMiddleware(authn.Wrap)
 
 	app.Run()
 }
+
+// newAuth builds the authentication middleware from cfg and the issuer of
+// tokens, if any. An RS256 private key signs tokens in preference to the
+// HS256 secret.
+func newAuth(cfg config.Auth) (*auth.Middleware, *auth.Issuer, error) {
+	keys := &auth.KeySet{}
+	if cfg.JWTSecret != "" {
+		keys.AddHS256("", []byte(cfg.JWTSecret))
+	}
+	for _, path := range cfg.JWTPublicKeys {
+		key, err := auth.ReadRSAPublicKey(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys.AddRS256(auth.KeyID(path), key)
+	}
+
+	var issuer *auth.Issuer
+	switch {
+	case cfg.JWTPrivateKey != "":
+		key, err := auth.ReadRSAPrivateKey(cfg.JWTPrivateKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		kid := auth.KeyID(cfg.JWTPrivateKey)
+		keys.AddRS256(kid, &key.PublicKey)
+		issuer = auth.NewRS256Issuer(kid, key, cfg.JWTIssuer, cfg.TokenTTL)
+	case cfg.JWTSecret != "":
+		issuer = auth.NewHS256Issuer("", []byte(cfg.JWTSecret), cfg.JWTIssuer, cfg.TokenTTL)
+	}
+
+	apiKeys := auth.APIKeys{}
+	for name, key := range cfg.APIKeys {
+		apiKeys[name] = string(key)
+	}
+	m := &auth.Middleware{Public: []string{"/auth/token"}}
+	if len(apiKeys) > 0 {
+		m.Authenticators = append(m.Authenticators, apiKeys)
+	}
+	if !keys.Empty() {
+		m.Authenticators = append(m.Authenticators, &auth.JWT{Keys: keys, Issuer: cfg.JWTIssuer, Leeway: 30 * time.Second})
+	}
+	return m, issuer, nil
+}
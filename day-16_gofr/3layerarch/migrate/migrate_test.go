@@ -0,0 +1,149 @@
+package migrate_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"3layerarch/migrate"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var testMigrations = []migrate.Migration{
+	{Version: 1, Name: "first", Up: []string{"CREATE TABLE A"}, Down: []string{"DROP TABLE A"}},
+	{Version: 2, Name: "second", Up: []string{"CREATE TABLE B"}, Down: []string{"DROP TABLE B"}},
+}
+
+// expectApplied sets up the version table lookup to report versions.
+func expectApplied(mock sqlmock.Sqlmock, versions ...int) {
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS SCHEMA_MIGRATIONS").WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version"})
+	for _, v := range versions {
+		rows.AddRow(v)
+	}
+	mock.ExpectQuery("SELECT version FROM SCHEMA_MIGRATIONS").WillReturnRows(rows)
+}
+
+func TestUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	expectApplied(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE B").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO SCHEMA_MIGRATIONS \(version, name\) VALUES \(\?, \?\)`).
+		WithArgs(2, "second").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	done, err := migrate.New(db, testMigrations).Up()
+	if err != nil || len(done) != 1 || done[0].Version != 2 {
+		t.Errorf("expected only migration 2 to be applied, got %+v, err: %v", done, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUp_Failure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	expectApplied(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE A").WillReturnError(errors.New("table exists"))
+	mock.ExpectRollback()
+
+	done, err := migrate.New(db, testMigrations).Up()
+	if err == nil || err.Error() != "migration 1 first: table exists" || len(done) != 0 {
+		t.Errorf("expected migration 1 to fail, got %+v, err: %v", done, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	expectApplied(mock, 1, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP TABLE B").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM SCHEMA_MIGRATIONS WHERE version = \?`).
+		WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mg, err := migrate.New(db, testMigrations).Down()
+	if err != nil || mg.Version != 2 {
+		t.Errorf("expected migration 2 to be reverted, got %+v, err: %v", mg, err)
+	}
+
+	expectApplied(mock)
+	if _, err := migrate.New(db, testMigrations).Down(); err == nil {
+		t.Error("expected an error with nothing to revert")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	m := migrate.New(db, testMigrations)
+
+	expectApplied(mock, 1)
+	if err := m.Check(); !errors.Is(err, migrate.ErrOutdated) {
+		t.Errorf("expected ErrOutdated, got %v", err)
+	}
+
+	expectApplied(mock, 1, 2)
+	if err := m.Check(); err != nil {
+		t.Errorf("expected an up to date schema, got %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	m := migrate.New(db, testMigrations)
+
+	var out bytes.Buffer
+	expectApplied(mock, 1)
+	if err := migrate.Run(m, []string{"status"}, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "1 first applied\n2 second pending\n"; out.String() != want {
+		t.Errorf("expected status %q, got %q", want, out.String())
+	}
+
+	out.Reset()
+	expectApplied(mock, 1, 2)
+	if err := migrate.Run(m, []string{"up"}, &out); err != nil || out.String() != "schema is up to date\n" {
+		t.Errorf("unexpected output %q, err: %v", out.String(), err)
+	}
+
+	for _, args := range [][]string{nil, {"sideways"}, {"up", "now"}} {
+		if err := migrate.Run(m, args, &out); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package migrate
+
+// Migrations is the schema of the task and user tables, oldest first. Add
+// new changes to the end; never edit one that has been released.
+var Migrations = []Migration{
+	{
+		// The tables as the servers used to create them, so an existing
+		// database adopts the migrations without losing data.
+		Version: 1,
+		Name:    "create_tables",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS USERS (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				name VARCHAR(100)
+			)`,
+			`CREATE TABLE IF NOT EXISTS TASKS (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				task TEXT,
+				completed BOOL DEFAULT FALSE,
+				user_id INT
+			)`,
+		},
+		Down: []string{
+			"DROP TABLE TASKS",
+			"DROP TABLE USERS",
+		},
+	},
+	{
+		// Tasks without an owner fail this migration and must be assigned
+		// to a user first.
+		Version: 2,
+		Name:    "not_null",
+		Up: []string{
+			"UPDATE TASKS SET task = '' WHERE task IS NULL",
+			"UPDATE TASKS SET completed = FALSE WHERE completed IS NULL",
+			"UPDATE USERS SET name = CONCAT('user ', id) WHERE name IS NULL",
+			"ALTER TABLE USERS MODIFY name VARCHAR(100) NOT NULL",
+			"ALTER TABLE TASKS MODIFY task TEXT NOT NULL, MODIFY completed BOOL NOT NULL DEFAULT FALSE, MODIFY user_id INT NOT NULL",
+		},
+		Down: []string{
+			"ALTER TABLE TASKS MODIFY task TEXT, MODIFY completed BOOL DEFAULT FALSE, MODIFY user_id INT",
+			"ALTER TABLE USERS MODIFY name VARCHAR(100)",
+		},
+	},
+	{
+		Version: 3,
+		Name:    "indexes",
+		Up: []string{
+			"CREATE INDEX idx_tasks_user_id ON TASKS (user_id)",
+			"CREATE INDEX idx_tasks_completed ON TASKS (completed)",
+			"CREATE INDEX idx_users_name ON USERS (name)",
+		},
+		Down: []string{
+			"DROP INDEX idx_users_name ON USERS",
+			"DROP INDEX idx_tasks_completed ON TASKS",
+			"DROP INDEX idx_tasks_user_id ON TASKS",
+		},
+	},
+	{
+		// Tasks whose user no longer exists fail this migration.
+		Version: 4,
+		Name:    "tasks_user_fk",
+		Up: []string{
+			"ALTER TABLE TASKS ADD CONSTRAINT fk_tasks_user FOREIGN KEY (user_id) REFERENCES USERS (id)",
+		},
+		Down: []string{
+			"ALTER TABLE TASKS DROP FOREIGN KEY fk_tasks_user",
+		},
+	},
+	{
+		// NULL until the user sets a password; such users cannot log in.
+		Version: 5,
+		Name:    "user_password",
+		Up: []string{
+			"ALTER TABLE USERS ADD COLUMN password_hash VARCHAR(255) NULL",
+		},
+		Down: []string{
+			"ALTER TABLE USERS DROP COLUMN password_hash",
+		},
+	},
+	{
+		// Every existing user is a plain user; admins are made by hand.
+		Version: 6,
+		Name:    "user_role",
+		Up: []string{
+			"ALTER TABLE USERS ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user'",
+		},
+		Down: []string{
+			"ALTER TABLE USERS DROP COLUMN role",
+		},
+	},
+	{
+		// Users that share a name fail this migration and must be renamed
+		// first.
+		Version: 7,
+		Name:    "users_name_unique",
+		Up: []string{
+			"DROP INDEX idx_users_name ON USERS",
+			"CREATE UNIQUE INDEX idx_users_name ON USERS (name)",
+		},
+		Down: []string{
+			"DROP INDEX idx_users_name ON USERS",
+			"CREATE INDEX idx_users_name ON USERS (name)",
+		},
+	},
+}
@@ -0,0 +1,59 @@
+package models
+
+import "errors"
+
+// Sentinel errors for the kinds of failure the domain reports. Callers
+// classify an error with errors.Is; the constructors below attach a message.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrValidation   = errors.New("validation failed")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrInternal     = errors.New("internal error")
+)
+
+// Error is a domain error of one of the sentinel kinds. Msg is safe to show
+// to clients; Err is the underlying cause, if any, and is not.
+type Error struct {
+	Kind error
+	Msg  string
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Msg }
+
+func (e *Error) Is(target error) bool { return target == e.Kind }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// NotFound reports that the requested entity does not exist.
+func NotFound(msg string) error {
+	return &Error{Kind: ErrNotFound, Msg: msg}
+}
+
+// Validation reports input that breaks a domain rule.
+func Validation(msg string) error {
+	return &Error{Kind: ErrValidation, Msg: msg}
+}
+
+// Conflict reports a change that clashes with the current state.
+func Conflict(msg string) error {
+	return &Error{Kind: ErrConflict, Msg: msg}
+}
+
+// Unauthorized reports a request without valid credentials.
+func Unauthorized(msg string) error {
+	return &Error{Kind: ErrUnauthorized, Msg: msg}
+}
+
+// Forbidden reports a change the caller is not allowed to make.
+func Forbidden(msg string) error {
+	return &Error{Kind: ErrForbidden, Msg: msg}
+}
+
+// Internal wraps an unexpected failure, such as a database error, so that
+// only a generic message reaches clients.
+func Internal(err error) error {
+	return &Error{Kind: ErrInternal, Msg: "internal error", Err: err}
+}
@@ -6,3 +6,32 @@ type Task struct {
 	Completed bool   `json:"completed"`
 	UserID    int    `json:"user_id"`
 }
+
+// TaskPatch is a JSON merge-patch for a task. Nil fields were absent from
+// the patch and are left unchanged.
+type TaskPatch struct {
+	Task      *string `json:"task,omitempty"`
+	Completed *bool   `json:"completed,omitempty"`
+	UserID    *int    `json:"user_id,omitempty"`
+}
+
+// TaskFilter narrows, orders and pages a task listing. Nil fields do not
+// filter. Sort names a task field, prefixed with "-" for descending order.
+type TaskFilter struct {
+	Completed *bool
+	UserID    *int
+	Search    string
+	Sort      string
+	Limit     int
+	Offset    int
+}
+
+// TaskPage is one page of a task listing along with the total number of
+// tasks that matched the filter.
+type TaskPage struct {
+	Tasks  []Task `json:"tasks"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Next   string `json:"next,omitempty"`
+}
@@ -3,4 +3,69 @@ package models
 type User struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
+	// Password is only ever read from requests. It is stored as a hash and
+	// never written back.
+	Password string `json:"password,omitempty"`
+}
+
+// UserInclude names the related data to add to a fetched user.
+type UserInclude struct {
+	Tasks bool
+	Stats bool
+}
+
+// UserStats counts a user's tasks.
+type UserStats struct {
+	Open      int `json:"open"`
+	Completed int `json:"completed"`
+}
+
+// UserDetail is a user with the related data asked for by a UserInclude.
+// Tasks is nil, and left out of the JSON, unless tasks were included.
+type UserDetail struct {
+	User
+	Tasks []Task     `json:"tasks,omitzero"`
+	Stats *UserStats `json:"stats,omitempty"`
+}
+
+// UserPatch is a JSON merge-patch for a user. Nil fields were absent from
+// the patch and are left unchanged.
+type UserPatch struct {
+	Name     *string `json:"name,omitempty"`
+	Password *string `json:"password,omitempty"`
+}
+
+// UserFilter pages a user listing.
+type UserFilter struct {
+	Limit  int
+	Offset int
+}
+
+// UserPage is one page of a user listing along with the total number of
+// users.
+type UserPage struct {
+	Users  []User `json:"users"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Next   string `json:"next,omitempty"`
+}
+
+// DeletePolicy says what happens to a user's tasks when the user is deleted.
+type DeletePolicy string
+
+const (
+	// DeleteReject refuses to delete a user who still owns tasks.
+	DeleteReject DeletePolicy = "reject"
+	// DeleteCascade deletes the user's tasks along with the user.
+	DeleteCascade DeletePolicy = "cascade"
+	// DeleteReassign moves the user's tasks to another user.
+	DeleteReassign DeletePolicy = "reassign"
+)
+
+// UserDelete describes how to delete a user. An empty Policy means the
+// service default. ReassignTo is only used by DeleteReassign.
+type UserDelete struct {
+	Policy     DeletePolicy
+	ReassignTo int
 }
@@ -6,10 +6,10 @@ import (
 )
 
 type TaskStore interface {
-	CreateTask(ctx *gofr.Context, t models.Task) error
+	CreateTask(ctx *gofr.Context, t models.Task) (models.Task, error)
 	GetTask(ctx *gofr.Context, id int) (models.Task, error)
-	ViewTasks(ctx *gofr.Context) ([]models.Task, error)
-	UpdateTask(ctx *gofr.Context, id int) error
+	ViewTasks(ctx *gofr.Context, f models.TaskFilter) ([]models.Task, int, error)
+	UpdateTask(ctx *gofr.Context, t models.Task) error
 	DeleteTask(ctx *gofr.Context, id int) error
 }
 
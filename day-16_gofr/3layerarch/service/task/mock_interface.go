@@ -14,6 +14,7 @@ import (
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
+	gofr "gofr.dev/pkg/gofr"
 )
 
 // MockTaskStore is a mock of TaskStore interface.
@@ -41,75 +42,77 @@ func (m *MockTaskStore) EXPECT() *MockTaskStoreMockRecorder {
 }
 
 // CreateTask mocks base method.
-func (m *MockTaskStore) CreateTask(t models.Task) error {
+func (m *MockTaskStore) CreateTask(ctx *gofr.Context, t models.Task) (models.Task, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTask", t)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateTask", ctx, t)
+	ret0, _ := ret[0].(models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // CreateTask indicates an expected call of CreateTask.
-func (mr *MockTaskStoreMockRecorder) CreateTask(t any) *gomock.Call {
+func (mr *MockTaskStoreMockRecorder) CreateTask(ctx, t any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTask", reflect.TypeOf((*MockTaskStore)(nil).CreateTask), t)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTask", reflect.TypeOf((*MockTaskStore)(nil).CreateTask), ctx, t)
 }
 
 // DeleteTask mocks base method.
-func (m *MockTaskStore) DeleteTask(id int) error {
+func (m *MockTaskStore) DeleteTask(ctx *gofr.Context, id int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteTask", id)
+	ret := m.ctrl.Call(m, "DeleteTask", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteTask indicates an expected call of DeleteTask.
-func (mr *MockTaskStoreMockRecorder) DeleteTask(id any) *gomock.Call {
+func (mr *MockTaskStoreMockRecorder) DeleteTask(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTask", reflect.TypeOf((*MockTaskStore)(nil).DeleteTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTask", reflect.TypeOf((*MockTaskStore)(nil).DeleteTask), ctx, id)
 }
 
 // GetTask mocks base method.
-func (m *MockTaskStore) GetTask(id int) (models.Task, error) {
+func (m *MockTaskStore) GetTask(ctx *gofr.Context, id int) (models.Task, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTask", id)
+	ret := m.ctrl.Call(m, "GetTask", ctx, id)
 	ret0, _ := ret[0].(models.Task)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetTask indicates an expected call of GetTask.
-func (mr *MockTaskStoreMockRecorder) GetTask(id any) *gomock.Call {
+func (mr *MockTaskStoreMockRecorder) GetTask(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTask", reflect.TypeOf((*MockTaskStore)(nil).GetTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTask", reflect.TypeOf((*MockTaskStore)(nil).GetTask), ctx, id)
 }
 
 // UpdateTask mocks base method.
-func (m *MockTaskStore) UpdateTask(id int) error {
+func (m *MockTaskStore) UpdateTask(ctx *gofr.Context, t models.Task) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateTask", id)
+	ret := m.ctrl.Call(m, "UpdateTask", ctx, t)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateTask indicates an expected call of UpdateTask.
-func (mr *MockTaskStoreMockRecorder) UpdateTask(id any) *gomock.Call {
+func (mr *MockTaskStoreMockRecorder) UpdateTask(ctx, t any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTask", reflect.TypeOf((*MockTaskStore)(nil).UpdateTask), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTask", reflect.TypeOf((*MockTaskStore)(nil).UpdateTask), ctx, t)
 }
 
 // ViewTasks mocks base method.
-func (m *MockTaskStore) ViewTasks() ([]models.Task, error) {
+func (m *MockTaskStore) ViewTasks(ctx *gofr.Context, f models.TaskFilter) ([]models.Task, int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ViewTasks")
+	ret := m.ctrl.Call(m, "ViewTasks", ctx, f)
 	ret0, _ := ret[0].([]models.Task)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // ViewTasks indicates an expected call of ViewTasks.
-func (mr *MockTaskStoreMockRecorder) ViewTasks() *gomock.Call {
+func (mr *MockTaskStoreMockRecorder) ViewTasks(ctx, f any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewTasks", reflect.TypeOf((*MockTaskStore)(nil).ViewTasks))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewTasks", reflect.TypeOf((*MockTaskStore)(nil).ViewTasks), ctx, f)
 }
 
 // MockUserService is a mock of UserService interface.
@@ -137,16 +140,16 @@ func (m *MockUserService) EXPECT() *MockUserServiceMockRecorder {
 }
 
 // GetUser mocks base method.
-func (m *MockUserService) GetUser(id int) (models.User, error) {
+func (m *MockUserService) GetUser(ctx *gofr.Context, id int) (models.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", id)
+	ret := m.ctrl.Call(m, "GetUser", ctx, id)
 	ret0, _ := ret[0].(models.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUser indicates an expected call of GetUser.
-func (mr *MockUserServiceMockRecorder) GetUser(id any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) GetUser(ctx, id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserService)(nil).GetUser), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserService)(nil).GetUser), ctx, id)
 }
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"gofr.dev/pkg/gofr"
+	"strings"
 )
 
 //type TaskStore interface {
@@ -30,62 +31,190 @@ func New(ts TaskStore, us UserService) *Service {
 	return &Service{TaskStore: ts, UserService: us}
 }
 
-func (s *Service) CreateTask(ctx *gofr.Context, t models.Task) error {
-	fmt.Println("CreateTask received:", t)
-
+// CreateTask validates t and returns the stored task with its new ID. Only
+// admins create tasks for other users.
+func (s *Service) CreateTask(ctx *gofr.Context, t models.Task) (models.Task, error) {
+	p, err := caller(ctx)
+	if err != nil {
+		return models.Task{}, err
+	}
 	if t.Task == "" {
-		return errors.New("task cannot be empty")
+		return models.Task{}, models.Validation("task cannot be empty")
+	}
+	if !p.CanAccess(t.UserID) {
+		return models.Task{}, models.Forbidden("cannot create tasks for another user")
 	}
 	// Validate user existence before creating task
-	_, err := s.UserService.GetUser(ctx, t.UserID)
-	if err != nil {
-		return errors.New("user ID not found")
+	if err := s.checkUser(ctx, t.UserID); err != nil {
+		return models.Task{}, err
 	}
 	return s.TaskStore.CreateTask(ctx, t)
 }
 
 func (s *Service) GetTask(ctx *gofr.Context, id int) (models.Task, error) {
+	return s.getTask(ctx, id)
+}
+
+// getTask looks up the task with id. Tasks of other users are not found
+// unless the caller is an admin, so that their IDs give nothing away.
+func (s *Service) getTask(ctx *gofr.Context, id int) (models.Task, error) {
+	p, err := caller(ctx)
+	if err != nil {
+		return models.Task{}, err
+	}
 	if id <= 0 {
-		return models.Task{}, errors.New("invalid task ID")
+		return models.Task{}, models.Validation("invalid task ID")
 	}
 	task, err := s.TaskStore.GetTask(ctx, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.Task{}, errors.New("task not found")
+			return models.Task{}, models.NotFound("task not found")
 		}
 		return models.Task{}, err
 	}
+	if !p.CanAccess(task.UserID) {
+		return models.Task{}, models.NotFound("task not found")
+	}
 	return task, nil
 }
 
-func (s *Service) ViewTasks(ctx *gofr.Context) ([]models.Task, error) {
-	return s.TaskStore.ViewTasks(ctx)
-}
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
 
-func (s *Service) UpdateTask(ctx *gofr.Context, id int) error {
-	if id <= 0 {
-		return errors.New("invalid task ID")
-	}
-	_, err := s.TaskStore.GetTask(ctx, id)
+// ViewTasks returns the page of tasks selected by f. A zero limit means the
+// default page size. Users other than admins only ever see their own tasks.
+func (s *Service) ViewTasks(ctx *gofr.Context, f models.TaskFilter) (models.TaskPage, error) {
+	p, err := caller(ctx)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return errors.New("task not found")
+		return models.TaskPage{}, err
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultPageSize
+	}
+	if f.Limit < 0 || f.Limit > maxPageSize {
+		return models.TaskPage{}, models.Validation(fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+	}
+	if f.Offset < 0 {
+		return models.TaskPage{}, models.Validation("offset cannot be negative")
+	}
+	switch strings.TrimPrefix(f.Sort, "-") {
+	case "", "id", "task", "completed", "user_id":
+	default:
+		return models.TaskPage{}, models.Validation(fmt.Sprintf("cannot sort by %q", f.Sort))
+	}
+	if f.UserID != nil && *f.UserID <= 0 {
+		return models.TaskPage{}, models.Validation("invalid user ID")
+	}
+	if p.Role != models.RoleAdmin {
+		if f.UserID != nil && *f.UserID != p.UserID {
+			return models.TaskPage{Tasks: []models.Task{}, Limit: f.Limit, Offset: f.Offset}, nil
 		}
-		return err
+		f.UserID = &p.UserID
 	}
-	return s.TaskStore.UpdateTask(ctx, id)
+
+	tasks, total, err := s.TaskStore.ViewTasks(ctx, f)
+	if err != nil {
+		return models.TaskPage{}, err
+	}
+	return models.TaskPage{Tasks: tasks, Total: total, Limit: f.Limit, Offset: f.Offset}, nil
 }
 
-func (s *Service) DeleteTask(ctx *gofr.Context, id int) error {
-	if id <= 0 {
-		return errors.New("invalid task ID")
+// ViewUserTasks returns a page of the tasks of the user with userID, selected
+// by the other filters of f. An unknown user is not found rather than an
+// empty page.
+func (s *Service) ViewUserTasks(ctx *gofr.Context, userID int, f models.TaskFilter) (models.TaskPage, error) {
+	if _, err := s.UserService.GetUser(ctx, userID); err != nil {
+		return models.TaskPage{}, err
 	}
-	_, err := s.TaskStore.GetTask(ctx, id)
+	f.UserID = &userID
+	return s.ViewTasks(ctx, f)
+}
+
+// UpdateTask replaces every field of the task with id and returns the result.
+func (s *Service) UpdateTask(ctx *gofr.Context, id int, t models.Task) (models.Task, error) {
+	existing, err := s.getTask(ctx, id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return errors.New("task not found")
+		return models.Task{}, err
+	}
+	t.ID = id
+	if err := s.validateUpdate(ctx, existing, t); err != nil {
+		return models.Task{}, err
+	}
+	if err := s.TaskStore.UpdateTask(ctx, t); err != nil {
+		return models.Task{}, err
+	}
+	return t, nil
+}
+
+// PatchTask applies a merge-patch to the task with id and returns the result.
+func (s *Service) PatchTask(ctx *gofr.Context, id int, p models.TaskPatch) (models.Task, error) {
+	existing, err := s.getTask(ctx, id)
+	if err != nil {
+		return models.Task{}, err
+	}
+	t := existing
+	if p.Task != nil {
+		t.Task = *p.Task
+	}
+	if p.Completed != nil {
+		t.Completed = *p.Completed
+	}
+	if p.UserID != nil {
+		t.UserID = *p.UserID
+	}
+	if err := s.validateUpdate(ctx, existing, t); err != nil {
+		return models.Task{}, err
+	}
+	if err := s.TaskStore.UpdateTask(ctx, t); err != nil {
+		return models.Task{}, err
+	}
+	return t, nil
+}
+
+// validateUpdate checks the new state of a task. The owner is only looked up
+// when the update moves the task to another user, which only admins may do.
+func (s *Service) validateUpdate(ctx *gofr.Context, old, t models.Task) error {
+	if t.Task == "" {
+		return models.Validation("task cannot be empty")
+	}
+	if t.UserID <= 0 {
+		return models.Validation("invalid user ID")
+	}
+	if t.UserID != old.UserID {
+		if p, err := caller(ctx); err != nil || !p.CanAccess(t.UserID) {
+			return models.Forbidden("cannot assign tasks to another user")
 		}
+		return s.checkUser(ctx, t.UserID)
+	}
+	return nil
+}
+
+// checkUser makes sure a task can be assigned to userID. A user that cannot
+// be found is a validation failure of the task; other lookup errors are not.
+func (s *Service) checkUser(ctx *gofr.Context, userID int) error {
+	_, err := s.UserService.GetUser(ctx, userID)
+	if errors.Is(err, models.ErrNotFound) || errors.Is(err, models.ErrValidation) {
+		return models.Validation("user ID not found")
+	}
+	return err
+}
+
+func (s *Service) DeleteTask(ctx *gofr.Context, id int) error {
+	if _, err := s.getTask(ctx, id); err != nil {
 		return err
 	}
 	return s.TaskStore.DeleteTask(ctx, id)
 }
+
+// caller returns who the request in ctx was made by. Every transport
+// authenticates its requests, so a ctx without a principal is refused rather
+// than let through.
+func caller(ctx *gofr.Context) (models.Principal, error) {
+	p, ok := models.PrincipalFrom(ctx)
+	if !ok {
+		return models.Principal{}, models.Unauthorized("authentication required")
+	}
+	return p, nil
+}
@@ -6,6 +6,15 @@ import (
 )
 
 type UserStore interface {
-	CreateUser(ctx *gofr.Context, u models.User) error
+	CreateUser(ctx *gofr.Context, u models.User) (models.User, error)
 	GetUser(ctx *gofr.Context, id int) (models.User, error)
+	GetUserStats(ctx *gofr.Context, id int) (models.User, models.UserStats, error)
+	GetUserWithTasks(ctx *gofr.Context, id int) (models.User, []models.Task, error)
+	GetUserByName(ctx *gofr.Context, name string) (models.User, error)
+	GetPasswordHash(ctx *gofr.Context, name string) (models.Principal, string, error)
+	SetPasswordHash(ctx *gofr.Context, id int, hash string) error
+	ViewUsers(ctx *gofr.Context, f models.UserFilter) ([]models.User, int, error)
+	UpdateUser(ctx *gofr.Context, u models.User) error
+	CountTasks(ctx *gofr.Context, id int) (int, error)
+	DeleteUser(ctx *gofr.Context, id, reassignTo int) error
 }
@@ -14,6 +14,7 @@ import (
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
+	gofr "gofr.dev/pkg/gofr"
 )
 
 // MockUserStore is a mock of UserStore interface.
@@ -40,31 +41,168 @@ func (m *MockUserStore) EXPECT() *MockUserStoreMockRecorder {
 	return m.recorder
 }
 
+// CountTasks mocks base method.
+func (m *MockUserStore) CountTasks(ctx *gofr.Context, id int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountTasks", ctx, id)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountTasks indicates an expected call of CountTasks.
+func (mr *MockUserStoreMockRecorder) CountTasks(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountTasks", reflect.TypeOf((*MockUserStore)(nil).CountTasks), ctx, id)
+}
+
 // CreateUser mocks base method.
-func (m *MockUserStore) CreateUser(u models.User) error {
+func (m *MockUserStore) CreateUser(ctx *gofr.Context, u models.User) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, u)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockUserStoreMockRecorder) CreateUser(ctx, u any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserStore)(nil).CreateUser), ctx, u)
+}
+
+// DeleteUser mocks base method.
+func (m *MockUserStore) DeleteUser(ctx *gofr.Context, id, reassignTo int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", u)
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id, reassignTo)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// CreateUser indicates an expected call of CreateUser.
-func (mr *MockUserStoreMockRecorder) CreateUser(u any) *gomock.Call {
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUserStoreMockRecorder) DeleteUser(ctx, id, reassignTo any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserStore)(nil).CreateUser), u)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserStore)(nil).DeleteUser), ctx, id, reassignTo)
+}
+
+// GetPasswordHash mocks base method.
+func (m *MockUserStore) GetPasswordHash(ctx *gofr.Context, name string) (models.Principal, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordHash", ctx, name)
+	ret0, _ := ret[0].(models.Principal)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetPasswordHash indicates an expected call of GetPasswordHash.
+func (mr *MockUserStoreMockRecorder) GetPasswordHash(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordHash", reflect.TypeOf((*MockUserStore)(nil).GetPasswordHash), ctx, name)
 }
 
 // GetUser mocks base method.
-func (m *MockUserStore) GetUser(id int) (models.User, error) {
+func (m *MockUserStore) GetUser(ctx *gofr.Context, id int) (models.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", id)
+	ret := m.ctrl.Call(m, "GetUser", ctx, id)
 	ret0, _ := ret[0].(models.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUser indicates an expected call of GetUser.
-func (mr *MockUserStoreMockRecorder) GetUser(id any) *gomock.Call {
+func (mr *MockUserStoreMockRecorder) GetUser(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserStore)(nil).GetUser), ctx, id)
+}
+
+// GetUserByName mocks base method.
+func (m *MockUserStore) GetUserByName(ctx *gofr.Context, name string) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByName", ctx, name)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByName indicates an expected call of GetUserByName.
+func (mr *MockUserStoreMockRecorder) GetUserByName(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByName", reflect.TypeOf((*MockUserStore)(nil).GetUserByName), ctx, name)
+}
+
+// GetUserStats mocks base method.
+func (m *MockUserStore) GetUserStats(ctx *gofr.Context, id int) (models.User, models.UserStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserStats", ctx, id)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(models.UserStats)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetUserStats indicates an expected call of GetUserStats.
+func (mr *MockUserStoreMockRecorder) GetUserStats(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserStats", reflect.TypeOf((*MockUserStore)(nil).GetUserStats), ctx, id)
+}
+
+// GetUserWithTasks mocks base method.
+func (m *MockUserStore) GetUserWithTasks(ctx *gofr.Context, id int) (models.User, []models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserWithTasks", ctx, id)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].([]models.Task)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetUserWithTasks indicates an expected call of GetUserWithTasks.
+func (mr *MockUserStoreMockRecorder) GetUserWithTasks(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserWithTasks", reflect.TypeOf((*MockUserStore)(nil).GetUserWithTasks), ctx, id)
+}
+
+// SetPasswordHash mocks base method.
+func (m *MockUserStore) SetPasswordHash(ctx *gofr.Context, id int, hash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPasswordHash", ctx, id, hash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPasswordHash indicates an expected call of SetPasswordHash.
+func (mr *MockUserStoreMockRecorder) SetPasswordHash(ctx, id, hash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPasswordHash", reflect.TypeOf((*MockUserStore)(nil).SetPasswordHash), ctx, id, hash)
+}
+
+// UpdateUser mocks base method.
+func (m *MockUserStore) UpdateUser(ctx *gofr.Context, u models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, u)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockUserStoreMockRecorder) UpdateUser(ctx, u any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUserStore)(nil).UpdateUser), ctx, u)
+}
+
+// ViewUsers mocks base method.
+func (m *MockUserStore) ViewUsers(ctx *gofr.Context, f models.UserFilter) ([]models.User, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ViewUsers", ctx, f)
+	ret0, _ := ret[0].([]models.User)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ViewUsers indicates an expected call of ViewUsers.
+func (mr *MockUserStoreMockRecorder) ViewUsers(ctx, f any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserStore)(nil).GetUser), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ViewUsers", reflect.TypeOf((*MockUserStore)(nil).ViewUsers), ctx, f)
 }
@@ -4,7 +4,12 @@ import (
 	"3layerarch/models"
 	"database/sql"
 	"errors"
+	"fmt"
 	"gofr.dev/pkg/gofr"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 //type UserStore interface {
@@ -12,31 +17,325 @@ import (
 //	GetUser(id int) (models.User, error)
 //}
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// maxNameLength matches the USERS.name column.
+	maxNameLength = 100
+
+	// bcrypt ignores anything past 72 bytes of a password.
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
 type Service struct {
 	Store UserStore
+	// DeletePolicy applies to deletes that do not choose a policy.
+	DeletePolicy models.DeletePolicy
 }
 
 func New(store UserStore) *Service {
-	return &Service{Store: store}
+	return &Service{Store: store, DeletePolicy: models.DeleteReject}
 }
 
-func (s *Service) CreateUser(ctx *gofr.Context, u models.User) error {
-	if u.Name == "" {
-		return errors.New("user name cannot be empty")
+// CreateUser validates u and returns the stored user with its new ID. A
+// password, if given, is stored as a bcrypt hash.
+func (s *Service) CreateUser(ctx *gofr.Context, u models.User) (models.User, error) {
+	name, err := s.validateName(ctx, 0, u.Name)
+	if err != nil {
+		return models.User{}, err
 	}
-	return s.Store.CreateUser(ctx, u)
+	hash, err := hashPassword(u.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	created, err := s.Store.CreateUser(ctx, models.User{Name: name})
+	if err != nil || hash == "" {
+		return created, err
+	}
+	if err := s.Store.SetPasswordHash(ctx, created.ID, hash); err != nil {
+		return models.User{}, err
+	}
+	return created, nil
+}
+
+// Authenticate returns the user called name, with their role, if password
+// is theirs. Unknown users, users without a password and wrong passwords
+// fail alike.
+func (s *Service) Authenticate(ctx *gofr.Context, name, password string) (models.Principal, error) {
+	p, hash, err := s.Store.GetPasswordHash(ctx, strings.TrimSpace(name))
+	if err != nil && err != sql.ErrNoRows {
+		return models.Principal{}, err
+	}
+	if hash == "" {
+		// Take as long as a real check so that response times do not
+		// tell which names exist
+		hash = dummyHash
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil || hash == dummyHash {
+		return models.Principal{}, models.Unauthorized("invalid name or password")
+	}
+	return p, nil
+}
+
+// dummyHash is compared against when there is no real hash to check.
+var dummyHash, _ = hashPassword("not a real password")
+
+// hashPassword validates a new password and returns its bcrypt hash, or ""
+// for no password.
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return "", models.Validation(fmt.Sprintf("password must be at least %d characters", minPasswordLength))
+	}
+	if len(password) > maxPasswordLength {
+		return "", models.Validation(fmt.Sprintf("password cannot be longer than %d bytes", maxPasswordLength))
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
 }
 
 func (s *Service) GetUser(ctx *gofr.Context, id int) (models.User, error) {
 	if id <= 0 {
-		return models.User{}, errors.New("invalid user ID")
+		return models.User{}, models.Validation("invalid user ID")
 	}
 	u, err := s.Store.GetUser(ctx, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.User{}, errors.New("user not found")
+			return models.User{}, models.NotFound("user not found")
 		}
 		return models.User{}, err
 	}
 	return u, nil
 }
+
+// GetUserDetail returns the user with id along with the related data inc
+// asks for. Each combination is a single store query. Only the user
+// themselves, or an admin, may see their tasks and stats.
+func (s *Service) GetUserDetail(ctx *gofr.Context, id int, inc models.UserInclude) (models.UserDetail, error) {
+	if id <= 0 {
+		return models.UserDetail{}, models.Validation("invalid user ID")
+	}
+	if inc.Tasks || inc.Stats {
+		if err := authorize(ctx, id); err != nil {
+			return models.UserDetail{}, err
+		}
+	}
+
+	var d models.UserDetail
+	var err error
+	switch {
+	case inc.Tasks:
+		d.User, d.Tasks, err = s.Store.GetUserWithTasks(ctx, id)
+		if err == nil && inc.Stats {
+			d.Stats = &models.UserStats{}
+			for _, t := range d.Tasks {
+				if t.Completed {
+					d.Stats.Completed++
+				} else {
+					d.Stats.Open++
+				}
+			}
+		}
+	case inc.Stats:
+		var st models.UserStats
+		d.User, st, err = s.Store.GetUserStats(ctx, id)
+		d.Stats = &st
+	default:
+		d.User, err = s.Store.GetUser(ctx, id)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.UserDetail{}, models.NotFound("user not found")
+		}
+		return models.UserDetail{}, err
+	}
+	return d, nil
+}
+
+// ViewUsers returns the page of users selected by f. A zero limit means the
+// default page size. Only admins list users.
+func (s *Service) ViewUsers(ctx *gofr.Context, f models.UserFilter) (models.UserPage, error) {
+	p, err := caller(ctx)
+	if err != nil {
+		return models.UserPage{}, err
+	}
+	if p.Role != models.RoleAdmin {
+		return models.UserPage{}, models.Forbidden("only admins can list users")
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultPageSize
+	}
+	if f.Limit < 0 || f.Limit > maxPageSize {
+		return models.UserPage{}, models.Validation(fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+	}
+	if f.Offset < 0 {
+		return models.UserPage{}, models.Validation("offset cannot be negative")
+	}
+
+	users, total, err := s.Store.ViewUsers(ctx, f)
+	if err != nil {
+		return models.UserPage{}, err
+	}
+	return models.UserPage{Users: users, Total: total, Limit: f.Limit, Offset: f.Offset}, nil
+}
+
+// UpdateUser replaces every field of the user with id and returns the result.
+// The password is the exception: it is kept unless u has a new one. Only the
+// user themselves, or an admin, may update them.
+func (s *Service) UpdateUser(ctx *gofr.Context, id int, u models.User) (models.User, error) {
+	if err := authorize(ctx, id); err != nil {
+		return models.User{}, err
+	}
+	if _, err := s.GetUser(ctx, id); err != nil {
+		return models.User{}, err
+	}
+	name, err := s.validateName(ctx, id, u.Name)
+	if err != nil {
+		return models.User{}, err
+	}
+	hash, err := hashPassword(u.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	u = models.User{ID: id, Name: name}
+	if err := s.Store.UpdateUser(ctx, u); err != nil {
+		return models.User{}, err
+	}
+	if hash != "" {
+		if err := s.Store.SetPasswordHash(ctx, id, hash); err != nil {
+			return models.User{}, err
+		}
+	}
+	return u, nil
+}
+
+// PatchUser applies a merge-patch to the user with id and returns the result.
+// Only the user themselves, or an admin, may change their name or password.
+func (s *Service) PatchUser(ctx *gofr.Context, id int, p models.UserPatch) (models.User, error) {
+	if err := authorize(ctx, id); err != nil {
+		return models.User{}, err
+	}
+	existing, err := s.GetUser(ctx, id)
+	if err != nil {
+		return models.User{}, err
+	}
+	if p.Name == nil && p.Password == nil {
+		return existing, nil
+	}
+	update := models.User{Name: existing.Name}
+	if p.Name != nil {
+		update.Name = *p.Name
+	}
+	if p.Password != nil {
+		if *p.Password == "" {
+			return models.User{}, models.Validation("password cannot be empty")
+		}
+		update.Password = *p.Password
+	}
+	return s.UpdateUser(ctx, id, update)
+}
+
+// validateName checks a new name for the user with id (0 for a new user)
+// and returns it trimmed. Names must be unique.
+func (s *Service) validateName(ctx *gofr.Context, id int, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", models.Validation("user name cannot be empty")
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return "", models.Validation(fmt.Sprintf("user name cannot be longer than %d characters", maxNameLength))
+	}
+	other, err := s.Store.GetUserByName(ctx, name)
+	switch {
+	case err == sql.ErrNoRows:
+		return name, nil
+	case err != nil:
+		return "", err
+	case other.ID != id:
+		return "", models.Conflict("user name already taken")
+	}
+	return name, nil
+}
+
+// DeleteUser deletes the user with id. What happens to their tasks depends
+// on d.Policy, or on s.DeletePolicy when d does not set one. Only the user
+// themselves, or an admin, may delete them, and only an admin may give
+// their tasks to another user.
+func (s *Service) DeleteUser(ctx *gofr.Context, id int, d models.UserDelete) error {
+	if err := authorize(ctx, id); err != nil {
+		return err
+	}
+	if _, err := s.GetUser(ctx, id); err != nil {
+		return err
+	}
+	policy := d.Policy
+	if policy == "" {
+		policy = s.DeletePolicy
+	}
+
+	switch policy {
+	case models.DeleteReject, "":
+		n, err := s.Store.CountTasks(ctx, id)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return models.Conflict(fmt.Sprintf("user still owns %d tasks", n))
+		}
+		return s.Store.DeleteUser(ctx, id, 0)
+	case models.DeleteCascade:
+		return s.Store.DeleteUser(ctx, id, 0)
+	case models.DeleteReassign:
+		if d.ReassignTo <= 0 {
+			return models.Validation("reassign_to is required to reassign tasks")
+		}
+		if d.ReassignTo == id {
+			return models.Validation("cannot reassign tasks to the user being deleted")
+		}
+		if p, err := caller(ctx); err != nil || !p.CanAccess(d.ReassignTo) {
+			return models.Forbidden("cannot reassign tasks to another user")
+		}
+		if _, err := s.GetUser(ctx, d.ReassignTo); err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				return models.Validation("reassign_to user not found")
+			}
+			return err
+		}
+		return s.Store.DeleteUser(ctx, id, d.ReassignTo)
+	default:
+		return models.Validation(fmt.Sprintf("unknown delete policy %q", policy))
+	}
+}
+
+// caller returns the principal of the request ctx belongs to.
+func caller(ctx *gofr.Context) (models.Principal, error) {
+	p, ok := models.PrincipalFrom(ctx)
+	if !ok {
+		return models.Principal{}, models.Unauthorized("authentication required")
+	}
+	return p, nil
+}
+
+// authorize checks that the caller may change the user with id: admins may
+// change anyone, other users only themselves. Like tasks, other users are
+// not found rather than forbidden, so as not to tell which IDs exist.
+func authorize(ctx *gofr.Context, id int) error {
+	p, err := caller(ctx)
+	if err != nil {
+		return err
+	}
+	if id <= 0 {
+		return models.Validation("invalid user ID")
+	}
+	if !p.CanAccess(id) {
+		return models.NotFound("user not found")
+	}
+	return nil
+}
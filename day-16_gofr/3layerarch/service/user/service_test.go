@@ -1,6 +1,7 @@
 package userservice
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"reflect"
@@ -8,6 +9,7 @@ import (
 
 	"3layerarch/models"
 	"go.uber.org/mock/gomock"
+	"gofr.dev/pkg/gofr"
 )
 
 func TestCreateUser(t *testing.T) {
@@ -25,7 +27,8 @@ func TestCreateUser(t *testing.T) {
 			desc:  "Success",
 			input: models.User{Name: "Alice"},
 			setupMock: func() {
-				mockStore.EXPECT().CreateUser(models.User{Name: "Alice"}).Return(nil)
+				mockStore.EXPECT().GetUserByName(gomock.Any(), "Alice").Return(models.User{}, sql.ErrNoRows)
+				mockStore.EXPECT().CreateUser(gomock.Any(), models.User{Name: "Alice"}).Return(models.User{ID: 1, Name: "Alice"}, nil)
 			},
 			wantErr: nil,
 		},
@@ -40,7 +43,7 @@ func TestCreateUser(t *testing.T) {
 		if test.setupMock != nil {
 			test.setupMock()
 		}
-		err := svc.CreateUser(test.input)
+		_, err := svc.CreateUser(adminCtx, test.input)
 		if !errorsEqual(err, test.wantErr) {
 			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
 		}
@@ -63,7 +66,7 @@ func TestGetUser(t *testing.T) {
 			desc:    "Success",
 			inputID: 1,
 			setup: func() {
-				mockStore.EXPECT().GetUser(1).Return(models.User{ID: 1, Name: "Alice"}, nil)
+				mockStore.EXPECT().GetUser(gomock.Any(), 1).Return(models.User{ID: 1, Name: "Alice"}, nil)
 			},
 			want: models.User{ID: 1, Name: "Alice"},
 		},
@@ -76,7 +79,7 @@ func TestGetUser(t *testing.T) {
 			desc:    "User Not Found",
 			inputID: 2,
 			setup: func() {
-				mockStore.EXPECT().GetUser(2).Return(models.User{}, sql.ErrNoRows)
+				mockStore.EXPECT().GetUser(gomock.Any(), 2).Return(models.User{}, sql.ErrNoRows)
 			},
 			wantErr: errors.New("user not found"),
 		},
@@ -84,7 +87,7 @@ func TestGetUser(t *testing.T) {
 			desc:    "DB Error",
 			inputID: 3,
 			setup: func() {
-				mockStore.EXPECT().GetUser(3).Return(models.User{}, errors.New("some db error"))
+				mockStore.EXPECT().GetUser(gomock.Any(), 3).Return(models.User{}, errors.New("some db error"))
 			},
 			wantErr: errors.New("some db error"),
 		},
@@ -95,7 +98,7 @@ func TestGetUser(t *testing.T) {
 			test.setup()
 		}
 
-		got, err := svc.GetUser(test.inputID)
+		got, err := svc.GetUser(adminCtx, test.inputID)
 
 		if !errorsEqual(err, test.wantErr) {
 			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
@@ -107,6 +110,143 @@ func TestGetUser(t *testing.T) {
 	}
 }
 
+func TestUserAccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := NewMockUserStore(ctrl)
+	svc := New(mockStore)
+	bob := userCtx(2)
+
+	tests := []struct {
+		desc      string
+		setupMock func()
+		call      func() error
+		wantErr   error
+	}{
+		{
+			desc: "Rename Another User",
+			call: func() error {
+				_, err := svc.UpdateUser(bob, 1, models.User{Name: "Mallory"})
+				return err
+			},
+			wantErr: models.NotFound("user not found"),
+		},
+		{
+			desc: "Patch Another User's Password",
+			call: func() error {
+				pw := "correct horse"
+				_, err := svc.PatchUser(bob, 1, models.UserPatch{Password: &pw})
+				return err
+			},
+			wantErr: models.NotFound("user not found"),
+		},
+		{
+			desc: "Update Another User's Password",
+			call: func() error {
+				_, err := svc.UpdateUser(bob, 1, models.User{Name: "Alice", Password: "correct horse"})
+				return err
+			},
+			wantErr: models.NotFound("user not found"),
+		},
+		{
+			desc:    "Delete Another User",
+			call:    func() error { return svc.DeleteUser(bob, 1, models.UserDelete{Policy: models.DeleteCascade}) },
+			wantErr: models.NotFound("user not found"),
+		},
+		{
+			desc: "Give Own Tasks To Another User",
+			setupMock: func() {
+				mockStore.EXPECT().GetUser(gomock.Any(), 2).Return(models.User{ID: 2, Name: "Bob"}, nil)
+			},
+			call: func() error {
+				return svc.DeleteUser(bob, 2, models.UserDelete{Policy: models.DeleteReassign, ReassignTo: 1})
+			},
+			wantErr: models.Forbidden("cannot reassign tasks to another user"),
+		},
+		{
+			desc: "List Users",
+			call: func() error {
+				_, err := svc.ViewUsers(bob, models.UserFilter{})
+				return err
+			},
+			wantErr: models.Forbidden("only admins can list users"),
+		},
+		{
+			desc: "Another User's Tasks",
+			call: func() error {
+				_, err := svc.GetUserDetail(bob, 1, models.UserInclude{Tasks: true})
+				return err
+			},
+			wantErr: models.NotFound("user not found"),
+		},
+		{
+			desc: "Another User's Stats",
+			call: func() error {
+				_, err := svc.GetUserDetail(bob, 1, models.UserInclude{Stats: true})
+				return err
+			},
+			wantErr: models.NotFound("user not found"),
+		},
+		{
+			desc: "Another User Without Include",
+			setupMock: func() {
+				mockStore.EXPECT().GetUser(gomock.Any(), 1).Return(models.User{ID: 1, Name: "Alice"}, nil)
+			},
+			call: func() error {
+				_, err := svc.GetUserDetail(bob, 1, models.UserInclude{})
+				return err
+			},
+		},
+		{
+			desc: "Without A Principal",
+			call: func() error {
+				_, err := svc.UpdateUser(&gofr.Context{Context: context.Background()}, 1, models.User{Name: "Alicia"})
+				return err
+			},
+			wantErr: models.Unauthorized("authentication required"),
+		},
+		{
+			desc: "Rename Themselves",
+			setupMock: func() {
+				mockStore.EXPECT().GetUser(gomock.Any(), 2).Return(models.User{ID: 2, Name: "Bob"}, nil)
+				mockStore.EXPECT().GetUserByName(gomock.Any(), "Robert").Return(models.User{}, sql.ErrNoRows)
+				mockStore.EXPECT().UpdateUser(gomock.Any(), models.User{ID: 2, Name: "Robert"}).Return(nil)
+			},
+			call: func() error {
+				_, err := svc.UpdateUser(bob, 2, models.User{Name: "Robert"})
+				return err
+			},
+		},
+		{
+			desc: "Admin Lists Users",
+			setupMock: func() {
+				mockStore.EXPECT().ViewUsers(gomock.Any(), models.UserFilter{Limit: defaultPageSize}).Return([]models.User{}, 0, nil)
+			},
+			call: func() error {
+				_, err := svc.ViewUsers(adminCtx, models.UserFilter{})
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if test.setupMock != nil {
+			test.setupMock()
+		}
+		if err := test.call(); !errorsEqual(err, test.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", test.desc, test.wantErr, err)
+		}
+	}
+}
+
+// adminCtx is the context of a request made by an admin, who may see and
+// change every user.
+var adminCtx = &gofr.Context{Context: models.WithPrincipal(context.Background(), models.Principal{Name: "ops", Role: models.RoleAdmin})}
+
+// userCtx is the context of a request made by the user with id.
+func userCtx(id int) *gofr.Context {
+	return &gofr.Context{Context: models.WithPrincipal(context.Background(), models.Principal{UserID: id, Name: "user", Role: models.RoleUser})}
+}
+
 func errorsEqual(err1, err2 error) bool {
 	if err1 == nil && err2 == nil {
 		return true
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"gofr.dev/pkg/gofr"
 	"log"
+	"strings"
 )
 
 type Store struct {
@@ -15,9 +16,18 @@ func New(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) CreateTask(ctx *gofr.Context, t models.Task) error {
-	_, err := ctx.SQL.ExecContext(ctx, "INSERT INTO TASKS (task, completed, user_id) VALUES (?, ?, ?)", t.Task, t.Completed, t.UserID)
-	return err
+// CreateTask inserts t and returns it with the ID the database assigned.
+func (s *Store) CreateTask(ctx *gofr.Context, t models.Task) (models.Task, error) {
+	res, err := ctx.SQL.ExecContext(ctx, "INSERT INTO TASKS (task, completed, user_id) VALUES (?, ?, ?)", t.Task, t.Completed, t.UserID)
+	if err != nil {
+		return models.Task{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return models.Task{}, err
+	}
+	t.ID = int(id)
+	return t, nil
 }
 
 func (s *Store) GetTask(ctx *gofr.Context, id int) (models.Task, error) {
@@ -27,10 +37,20 @@ func (s *Store) GetTask(ctx *gofr.Context, id int) (models.Task, error) {
 	return t, err
 }
 
-func (s *Store) ViewTasks(ctx *gofr.Context) ([]models.Task, error) {
-	rows, err := ctx.SQL.QueryContext(ctx, "SELECT id, task, completed, user_id FROM TASKS")
+// ViewTasks returns the page of tasks selected by f and the number of tasks
+// that match f's filters across all pages.
+func (s *Store) ViewTasks(ctx *gofr.Context, f models.TaskFilter) ([]models.Task, int, error) {
+	where, args := taskWhere(f)
+
+	var total int
+	if err := ctx.SQL.QueryRowContext(ctx, "SELECT COUNT(*) FROM TASKS"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT id, task, completed, user_id FROM TASKS" + where + " ORDER BY " + taskOrder(f.Sort) + " LIMIT ? OFFSET ?"
+	rows, err := ctx.SQL.QueryContext(ctx, query, append(args, f.Limit, f.Offset)...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -38,19 +58,65 @@ func (s *Store) ViewTasks(ctx *gofr.Context) ([]models.Task, error) {
 		}
 	}()
 
-	var tasks []models.Task
+	tasks := []models.Task{}
 	for rows.Next() {
 		var t models.Task
 		if err := rows.Scan(&t.ID, &t.Task, &t.Completed, &t.UserID); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		tasks = append(tasks, t)
 	}
-	return tasks, nil
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return tasks, total, nil
+}
+
+// taskWhere builds the WHERE clause and its arguments for f's filters.
+func taskWhere(f models.TaskFilter) (string, []any) {
+	var conds []string
+	var args []any
+	if f.Completed != nil {
+		conds = append(conds, "completed = ?")
+		args = append(args, *f.Completed)
+	}
+	if f.UserID != nil {
+		conds = append(conds, "user_id = ?")
+		args = append(args, *f.UserID)
+	}
+	if f.Search != "" {
+		conds = append(conds, "task LIKE ?")
+		args = append(args, "%"+likeEscaper.Replace(f.Search)+"%")
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// likeEscaper escapes the LIKE wildcards so a search matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// taskOrder turns a sort key into an ORDER BY clause. Only known columns are
+// ever written into the query; anything else sorts by id. Ties are broken by
+// id so pages stay stable.
+func taskOrder(sort string) string {
+	dir := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		dir = "DESC"
+		sort = sort[1:]
+	}
+	switch sort {
+	case "task", "completed", "user_id":
+		return sort + " " + dir + ", id ASC"
+	default:
+		return "id " + dir
+	}
 }
 
-func (s *Store) UpdateTask(ctx *gofr.Context, id int) error {
-	_, err := ctx.SQL.ExecContext(ctx, "UPDATE TASKS SET completed = true WHERE id = ?", id)
+func (s *Store) UpdateTask(ctx *gofr.Context, t models.Task) error {
+	_, err := ctx.SQL.ExecContext(ctx, "UPDATE TASKS SET task = ?, completed = ?, user_id = ? WHERE id = ?",
+		t.Task, t.Completed, t.UserID, t.ID)
 	return err
 }
 
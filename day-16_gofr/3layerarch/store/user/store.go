@@ -3,7 +3,11 @@ package userstore
 import (
 	"3layerarch/models"
 	"database/sql"
+	"errors"
 	"gofr.dev/pkg/gofr"
+	"log"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 type Store struct {
@@ -14,9 +18,35 @@ func New(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) CreateUser(ctx *gofr.Context, u models.User) error {
-	_, err := ctx.SQL.ExecContext(ctx, "INSERT INTO USERS (name) VALUES (?)", u.Name)
-	return err
+// errDuplicateEntry is the MySQL error of a unique key refusing a row.
+const errDuplicateEntry = 1062
+
+// errNameTaken reports a name the unique index on USERS refused. The user
+// service checks names first, but two requests can both pass that check.
+var errNameTaken = models.Conflict("user name already taken")
+
+// duplicate reports whether err is a unique key refusing a second row with
+// the same value.
+func duplicate(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == errDuplicateEntry
+}
+
+// CreateUser inserts u and returns it with the ID the database assigned.
+func (s *Store) CreateUser(ctx *gofr.Context, u models.User) (models.User, error) {
+	res, err := ctx.SQL.ExecContext(ctx, "INSERT INTO USERS (name) VALUES (?)", u.Name)
+	if duplicate(err) {
+		return models.User{}, errNameTaken
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return models.User{}, err
+	}
+	u.ID = int(id)
+	return u, nil
 }
 
 func (s *Store) GetUser(ctx *gofr.Context, id int) (models.User, error) {
@@ -24,3 +54,156 @@ func (s *Store) GetUser(ctx *gofr.Context, id int) (models.User, error) {
 	err := ctx.SQL.QueryRowContext(ctx, "SELECT id, name FROM USERS WHERE id = ?", id).Scan(&u.ID, &u.Name)
 	return u, err
 }
+
+// GetUserStats returns the user with id and the counts of their open and
+// completed tasks, or sql.ErrNoRows.
+func (s *Store) GetUserStats(ctx *gofr.Context, id int) (models.User, models.UserStats, error) {
+	var u models.User
+	var st models.UserStats
+	err := ctx.SQL.QueryRowContext(ctx, `SELECT u.id, u.name,
+		SUM(CASE WHEN t.completed = FALSE THEN 1 ELSE 0 END),
+		SUM(CASE WHEN t.completed = TRUE THEN 1 ELSE 0 END)
+		FROM USERS u LEFT JOIN TASKS t ON t.user_id = u.id
+		WHERE u.id = ? GROUP BY u.id, u.name`, id).
+		Scan(&u.ID, &u.Name, &st.Open, &st.Completed)
+	return u, st, err
+}
+
+// GetUserWithTasks returns the user with id and all of their tasks ordered
+// by id, or sql.ErrNoRows.
+func (s *Store) GetUserWithTasks(ctx *gofr.Context, id int) (models.User, []models.Task, error) {
+	rows, err := ctx.SQL.QueryContext(ctx, `SELECT u.id, u.name, t.id, t.task, t.completed
+		FROM USERS u LEFT JOIN TASKS t ON t.user_id = u.id
+		WHERE u.id = ? ORDER BY t.id ASC`, id)
+	if err != nil {
+		return models.User{}, nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}()
+
+	var u models.User
+	tasks := []models.Task{}
+	found := false
+	for rows.Next() {
+		var taskID sql.NullInt64
+		var task sql.NullString
+		var completed sql.NullBool
+		if err := rows.Scan(&u.ID, &u.Name, &taskID, &task, &completed); err != nil {
+			return models.User{}, nil, err
+		}
+		found = true
+		// A user without tasks still comes back as one row of NULLs.
+		if taskID.Valid {
+			tasks = append(tasks, models.Task{ID: int(taskID.Int64), Task: task.String, Completed: completed.Bool, UserID: u.ID})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return models.User{}, nil, err
+	}
+	if !found {
+		return models.User{}, nil, sql.ErrNoRows
+	}
+	return u, tasks, nil
+}
+
+// GetUserByName returns the user called name, or sql.ErrNoRows.
+func (s *Store) GetUserByName(ctx *gofr.Context, name string) (models.User, error) {
+	var u models.User
+	err := ctx.SQL.QueryRowContext(ctx, "SELECT id, name FROM USERS WHERE name = ?", name).Scan(&u.ID, &u.Name)
+	return u, err
+}
+
+// GetPasswordHash returns the user called name, as the principal they sign
+// in as, and their password hash, which is empty if they have no password,
+// or sql.ErrNoRows.
+func (s *Store) GetPasswordHash(ctx *gofr.Context, name string) (models.Principal, string, error) {
+	var p models.Principal
+	var hash sql.NullString
+	err := ctx.SQL.QueryRowContext(ctx, "SELECT id, name, role, password_hash FROM USERS WHERE name = ?", name).
+		Scan(&p.UserID, &p.Name, &p.Role, &hash)
+	return p, hash.String, err
+}
+
+// SetPasswordHash stores the password hash of the user with id.
+func (s *Store) SetPasswordHash(ctx *gofr.Context, id int, hash string) error {
+	_, err := ctx.SQL.ExecContext(ctx, "UPDATE USERS SET password_hash = ? WHERE id = ?", hash, id)
+	return err
+}
+
+// ViewUsers returns the page of users selected by f, ordered by id, and the
+// total number of users.
+func (s *Store) ViewUsers(ctx *gofr.Context, f models.UserFilter) ([]models.User, int, error) {
+	var total int
+	if err := ctx.SQL.QueryRowContext(ctx, "SELECT COUNT(*) FROM USERS").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := ctx.SQL.QueryContext(ctx, "SELECT id, name FROM USERS ORDER BY id ASC LIMIT ? OFFSET ?", f.Limit, f.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}()
+
+	users := []models.User{}
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Name); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *Store) UpdateUser(ctx *gofr.Context, u models.User) error {
+	_, err := ctx.SQL.ExecContext(ctx, "UPDATE USERS SET name = ? WHERE id = ?", u.Name, u.ID)
+	if duplicate(err) {
+		return errNameTaken
+	}
+	return err
+}
+
+// CountTasks returns the number of tasks owned by the user with id.
+func (s *Store) CountTasks(ctx *gofr.Context, id int) (int, error) {
+	var n int
+	err := ctx.SQL.QueryRowContext(ctx, "SELECT COUNT(*) FROM TASKS WHERE user_id = ?", id).Scan(&n)
+	return n, err
+}
+
+// DeleteUser deletes the user with id together with their tasks, or, when
+// reassignTo is set, after moving their tasks to that user. Both steps run
+// in one transaction.
+func (s *Store) DeleteUser(ctx *gofr.Context, id, reassignTo int) error {
+	tx, err := ctx.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Println("Error rolling back:", err)
+		}
+	}()
+
+	if reassignTo > 0 {
+		_, err = tx.ExecContext(ctx, "UPDATE TASKS SET user_id = ? WHERE user_id = ?", reassignTo, id)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM TASKS WHERE user_id = ?", id)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM USERS WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
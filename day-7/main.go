@@ -1,75 +1,139 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strconv"
 )
 
 type Task struct {
-	Task      string
-	Completed bool
+	Task      string `json:"task"`
+	Completed bool   `json:"completed"`
 }
 
 var tasks []*Task
 
+// writeJSON encodes v as the response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Println("Failed to write response:", err)
+	}
+}
+
+// writeError sends {"error": msg} with the given status code.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// taskID reads the {id} path value and checks it against the task list.
+// On failure the error response is already written and ok is false.
+func taskID(w http.ResponseWriter, r *http.Request) (id int, ok bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid task ID")
+		return 0, false
+	}
+
+	if id < 0 || id >= len(tasks) {
+		writeError(w, http.StatusNotFound, "Task not found")
+		return 0, false
+	}
+
+	return id, true
+}
+
 func addTask(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	body, _ := io.ReadAll(r.Body)
 
-	tasks = append(tasks, &Task{string(body), false})
-	w.WriteHeader(http.StatusCreated)
+	var input Task
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON input")
+		return
+	}
+
+	if input.Task == "" {
+		writeError(w, http.StatusBadRequest, "Task cannot be empty")
+		return
+	}
+
+	t := &Task{Task: input.Task, Completed: false}
+	tasks = append(tasks, t)
+	writeJSON(w, http.StatusCreated, t)
 }
 
 func getByID(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	id, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil {
-		fmt.Fprintf(w, "%v\n", err)
+
+	id, ok := taskID(w, r)
+	if !ok {
 		return
 	}
-	w.Write([]byte(tasks[id].Task))
-	w.WriteHeader(http.StatusOK)
+
+	writeJSON(w, http.StatusOK, tasks[id])
 }
 
-func viewTask(w http.ResponseWriter, r *http.Request) {
-	for i, t := range tasks {
-		//fmt.Fprintf(w, "ID: %d, Task: %s, Completed: %v\n", i, t.Task, t.Completed)
-		_, _ = w.Write([]byte(fmt.Sprintf("ID: %d, Task: %s, Completed: %v\n", i, t.Task, t.Completed)))
+func viewTask(w http.ResponseWriter, _ *http.Request) {
+	if tasks == nil {
+		writeJSON(w, http.StatusOK, []*Task{})
+		return
 	}
+
+	writeJSON(w, http.StatusOK, tasks)
 }
 
 func completeTask(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	id, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil {
-		fmt.Fprintf(w, "%v\n", err)
+
+	id, ok := taskID(w, r)
+	if !ok {
 		return
 	}
+
 	tasks[id].Completed = true
-	w.WriteHeader(http.StatusOK)
+	writeJSON(w, http.StatusOK, tasks[id])
 }
 
 func deleteTask(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	id, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil {
-		fmt.Fprintf(w, "%v\n", err)
+
+	id, ok := taskID(w, r)
+	if !ok {
 		return
 	}
+
 	tasks = append(tasks[:id], tasks[id+1:]...)
 	w.WriteHeader(http.StatusOK)
 }
 
-func main() {
-	http.HandleFunc("POST /task", addTask)
-	http.HandleFunc("GET /task/{id}", getByID)
-	http.HandleFunc("GET /task", viewTask)
-	http.HandleFunc("PUT /task/{id}", completeTask)
-	http.HandleFunc("DELETE /task/{id}", deleteTask)
+// methodNotAllowed answers any method that has no route on a known path.
+func methodNotAllowed(allow string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Allow", allow)
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
 
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	mux.HandleFunc("POST /task", addTask)
+	mux.HandleFunc("GET /task/{id}", getByID)
+	mux.HandleFunc("GET /task", viewTask)
+	mux.HandleFunc("PUT /task/{id}", completeTask)
+	mux.HandleFunc("DELETE /task/{id}", deleteTask)
+
+	mux.HandleFunc("/task", methodNotAllowed("GET, HEAD, POST"))
+	mux.HandleFunc("/task/{id}", methodNotAllowed("GET, HEAD, PUT, DELETE"))
+
+	return mux
+}
+
+func main() {
+	if err := http.ListenAndServe(":8080", newMux()); err != nil {
 		fmt.Println("Not able to start server")
 	}
 }
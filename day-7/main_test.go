@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlers(t *testing.T) {
+	tasks = nil
+
+	// Test addTask
+	req := httptest.NewRequest("POST", "/task", strings.NewReader(`{"task":"wake up early"}`))
+	w := httptest.NewRecorder()
+	addTask(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("addTask: Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	if len(tasks) != 1 || tasks[0].Task != "wake up early" || tasks[0].Completed {
+		t.Errorf("addTask: Task was not added correctly: %+v", tasks)
+	}
+
+	// Test getByID
+	req = httptest.NewRequest("GET", "/task/0", nil)
+	req.SetPathValue("id", "0")
+	w = httptest.NewRecorder()
+	getByID(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("getByID: Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	expectedGet := `{"task":"wake up early","completed":false}`
+	if strings.TrimSpace(w.Body.String()) != expectedGet {
+		t.Errorf("getByID: Expected body %s, got %s", expectedGet, w.Body.String())
+	}
+
+	// Test viewTask
+	req = httptest.NewRequest("GET", "/task", nil)
+	w = httptest.NewRecorder()
+	viewTask(w, req)
+
+	expectedAll := `[{"task":"wake up early","completed":false}]`
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != expectedAll {
+		t.Errorf("viewTask: Expected %d %s, got %d %s", http.StatusOK, expectedAll, w.Code, w.Body.String())
+	}
+
+	// Test completeTask
+	req = httptest.NewRequest("PUT", "/task/0", nil)
+	req.SetPathValue("id", "0")
+	w = httptest.NewRecorder()
+	completeTask(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("completeTask: Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if !tasks[0].Completed {
+		t.Errorf("completeTask: Task was not marked completed: %+v", tasks[0])
+	}
+
+	// Test deleteTask
+	req = httptest.NewRequest("DELETE", "/task/0", nil)
+	req.SetPathValue("id", "0")
+	w = httptest.NewRecorder()
+	deleteTask(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("deleteTask: Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if len(tasks) != 0 {
+		t.Errorf("deleteTask: Task was not deleted properly, tasks left: %d", len(tasks))
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	tasks = []*Task{{Task: "only task"}}
+	mux := newMux()
+
+	tests := []struct {
+		desc   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"addTask invalid JSON", "POST", "/task", `{"task":`, http.StatusBadRequest},
+		{"addTask raw body", "POST", "/task", "wake up early", http.StatusBadRequest},
+		{"addTask empty task", "POST", "/task", `{"task":""}`, http.StatusBadRequest},
+		{"getByID non-numeric", "GET", "/task/abc", "", http.StatusBadRequest},
+		{"getByID out of range", "GET", "/task/5", "", http.StatusNotFound},
+		{"getByID negative", "GET", "/task/-1", "", http.StatusNotFound},
+		{"completeTask non-numeric", "PUT", "/task/abc", "", http.StatusBadRequest},
+		{"completeTask out of range", "PUT", "/task/1", "", http.StatusNotFound},
+		{"deleteTask non-numeric", "DELETE", "/task/abc", "", http.StatusBadRequest},
+		{"deleteTask out of range", "DELETE", "/task/1", "", http.StatusNotFound},
+		{"PATCH on /task/{id}", "PATCH", "/task/0", "", http.StatusMethodNotAllowed},
+		{"DELETE on /task", "DELETE", "/task", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: Expected status %d, got %d", tc.desc, tc.status, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Expected JSON content type, got %q", tc.desc, ct)
+		}
+		if !strings.Contains(w.Body.String(), `"error":`) {
+			t.Errorf("%s: Expected JSON error body, got %s", tc.desc, w.Body.String())
+		}
+	}
+
+	if len(tasks) != 1 || tasks[0].Completed {
+		t.Errorf("Failed requests should not modify tasks: %+v", tasks)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// shuttingDown is set once the server starts draining, so that readiness
+// probes send no new traffic its way.
+var shuttingDown atomic.Bool
+
+type healthReport struct {
+	Status string `json:"status"`
+}
+
+// healthz reports that the server is up.
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	writeHealth(w, http.StatusOK, healthReport{Status: "ok"})
+}
+
+// readyz reports whether the server can take requests. Tasks are kept in
+// memory, so it only stops being ready when it shuts down.
+func readyz(w http.ResponseWriter, _ *http.Request) {
+	if shuttingDown.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, healthReport{Status: "shutting_down"})
+		return
+	}
+	writeHealth(w, http.StatusOK, healthReport{Status: "ok"})
+}
+
+func writeHealth(w http.ResponseWriter, status int, report healthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		fmt.Println("Failed to write response:", err)
+	}
+}
+
+// serve runs srv until ctx is done, then stops taking connections and waits
+// up to timeout for the requests in flight. Only a server that fails to
+// start is an error.
+func serve(ctx context.Context, srv *http.Server, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down, waiting for requests in flight")
+	shuttingDown.Store(true)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown did not finish in time: %v", err)
+		srv.Close()
+	}
+	log.Println("Server stopped")
+	return nil
+}
@@ -1,14 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
+// maxBodyBytes is the largest request body accepted.
+const maxBodyBytes = 1 << 20
+
+// shutdownTimeout is how long requests in flight get to finish on shutdown.
+const shutdownTimeout = 15 * time.Second
+
 // Task represents a to-do item.
 type Task struct {
 	Task      string `json:"task"`
@@ -97,8 +110,56 @@ func (tm *TaskManager) viewAll(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
-// CompleteTask handles PATCH /task/{id}.
-func (tm *TaskManager) completeTask(w http.ResponseWriter, r *http.Request) {
+// taskPatch is a JSON merge-patch for a task. Nil fields are left unchanged.
+type taskPatch struct {
+	Task      *string `json:"task"`
+	Completed *bool   `json:"completed"`
+}
+
+// decodePatch parses a JSON merge-patch (RFC 7396). Both task fields are
+// required, so a null, which would remove the field, is rejected, as are
+// fields a task does not have.
+func decodePatch(body []byte) (taskPatch, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return taskPatch{}, errors.New("invalid JSON input")
+	}
+
+	for field, v := range raw {
+		if string(v) == "null" {
+			return taskPatch{}, fmt.Errorf("%s cannot be null", field)
+		}
+	}
+
+	var p taskPatch
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&p); err != nil {
+		return taskPatch{}, fmt.Errorf("invalid patch: %w", err)
+	}
+
+	return p, nil
+}
+
+// writeTask sends the task as the JSON response body.
+func writeTask(w http.ResponseWriter, t *Task) {
+	w.Header().Set("Content-Type", "application/json")
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		http.Error(w, "Failed to encode task", http.StatusInternalServerError)
+		return
+	}
+
+	if _, writeErr := w.Write(data); writeErr != nil {
+		fmt.Println("Failed to write response:", writeErr)
+	}
+}
+
+// UpdateTask handles PUT /task/{id} by replacing the whole task.
+func (tm *TaskManager) updateTask(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	id, err := strconv.Atoi(r.PathValue("id"))
@@ -107,20 +168,59 @@ func (tm *TaskManager) completeTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tm.tasks[id].Completed = true
-	message := fmt.Sprintf("Task '%s' marked as completed", tm.tasks[id].Task)
+	var input Task
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
+	if input.Task == "" {
+		http.Error(w, "Task cannot be empty", http.StatusBadRequest)
+		return
+	}
 
-	resp, err := json.Marshal(message)
+	*tm.tasks[id] = input
+	writeTask(w, tm.tasks[id])
+}
+
+// PatchTask handles PATCH /task/{id} by applying a JSON merge-patch.
+func (tm *TaskManager) patchTask(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 0 || id >= len(tm.tasks) {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		http.Error(w, "Error reading body", http.StatusBadRequest)
 		return
 	}
 
-	if _, writeErr := w.Write(resp); writeErr != nil {
-		fmt.Println("Failed to write response:", writeErr)
+	p, err := decodePatch(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated := *tm.tasks[id]
+	if p.Task != nil {
+		updated.Task = *p.Task
+	}
+
+	if p.Completed != nil {
+		updated.Completed = *p.Completed
 	}
+
+	if updated.Task == "" {
+		http.Error(w, "Task cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	*tm.tasks[id] = updated
+	writeTask(w, tm.tasks[id])
 }
 
 // DeleteTask handles DELETE /task/{id}.
@@ -152,22 +252,42 @@ func (tm *TaskManager) deleteTask(w http.ResponseWriter, r *http.Request) {
 func main() {
 	tm := &TaskManager{}
 
+	http.HandleFunc("GET /healthz", healthz)
+	http.HandleFunc("GET /readyz", readyz)
 	http.HandleFunc("POST /task", tm.addTask)
 	http.HandleFunc("GET /task/{id}", tm.getByID)
 	http.HandleFunc("GET /task", tm.viewAll)
-	http.HandleFunc("PATCH /task/{id}", tm.completeTask)
+	http.HandleFunc("PUT /task/{id}", tm.updateTask)
+	http.HandleFunc("PATCH /task/{id}", tm.patchTask)
 	http.HandleFunc("DELETE /task/{id}", tm.deleteTask)
 
+	// Browser origins allowed to call the API, e.g. CORS_ALLOWED_ORIGINS=http://localhost:3000
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+
+	// Every request gets an ID and a log line, and a panic becomes a 500
+	handler := chain(http.DefaultServeMux,
+		requestID, accessLog, recoverPanic, cors(origins), maxBodySize(maxBodyBytes))
+
 	server := &http.Server{
 		Addr:         ":8080",
+		Handler:      handler,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  15 * time.Second,
 	}
 
+	// SIGINT or SIGTERM lets requests in flight finish before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	fmt.Println("Server listening on http://localhost:8080")
 
-	if err := server.ListenAndServe(); err != nil {
+	if err := serve(ctx, server, shutdownTimeout); err != nil {
 		fmt.Println("Failed to start server:", err)
 	}
 }
@@ -57,18 +57,60 @@ func TestHandlers(t *testing.T) {
 		t.Errorf("viewAll: Expected body %s, got %s", expectedAll, w.Body.String())
 	}
 
-	// Test completeTask
-	req = httptest.NewRequest("PATCH", "/task/0", nil)
+	// Test patchTask
+	req = httptest.NewRequest("PATCH", "/task/0", strings.NewReader(`{"completed":true}`))
 	req.SetPathValue("id", "0")
 	w = httptest.NewRecorder()
-	tm.completeTask(w, req)
+	tm.patchTask(w, req)
 	resp = w.Result()
 
 	if resp.StatusCode != http.StatusOK {
-		t.Errorf("completeTask: Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		t.Errorf("patchTask: Expected status %d, got %d", http.StatusOK, resp.StatusCode)
 	}
-	if !tm.tasks[0].Completed {
-		t.Errorf("completeTask: Task was not marked completed: %+v", tm.tasks[0])
+	if !tm.tasks[0].Completed || tm.tasks[0].Task != "wake up early" {
+		t.Errorf("patchTask: Task was not patched correctly: %+v", tm.tasks[0])
+	}
+	expectedPatch := `{"task":"wake up early","completed":true}`
+	if strings.TrimSpace(w.Body.String()) != expectedPatch {
+		t.Errorf("patchTask: Expected body %s, got %s", expectedPatch, w.Body.String())
+	}
+
+	// Test updateTask
+	req = httptest.NewRequest("PUT", "/task/0", strings.NewReader(`{"task":"sleep early"}`))
+	req.SetPathValue("id", "0")
+	w = httptest.NewRecorder()
+	tm.updateTask(w, req)
+	resp = w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("updateTask: Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	expectedPut := `{"task":"sleep early","completed":false}`
+	if strings.TrimSpace(w.Body.String()) != expectedPut {
+		t.Errorf("updateTask: Expected body %s, got %s", expectedPut, w.Body.String())
+	}
+
+	// Test invalid updates leave the task unchanged
+	for _, body := range []string{`{"task":null}`, `{"title":"x"}`, `{"task":""}`, `{`} {
+		req = httptest.NewRequest("PATCH", "/task/0", strings.NewReader(body))
+		req.SetPathValue("id", "0")
+		w = httptest.NewRecorder()
+		tm.patchTask(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("patchTask %s: Expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+	req = httptest.NewRequest("PUT", "/task/0", strings.NewReader(`{"completed":true}`))
+	req.SetPathValue("id", "0")
+	w = httptest.NewRecorder()
+	tm.updateTask(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("updateTask: Expected status %d for missing task, got %d", http.StatusBadRequest, w.Code)
+	}
+	if *tm.tasks[0] != (Task{Task: "sleep early"}) {
+		t.Errorf("Invalid updates modified the task: %+v", tm.tasks[0])
 	}
 
 	// Test deleteTask
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareRecover(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(prev)
+
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var tasks []*Task
+		_ = tasks[5]
+	}), requestID, accessLog, recoverPanic)
+
+	req := httptest.NewRequest("GET", "/task/5", nil)
+	req.Header.Set(requestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != `{"error":"internal server error"}` {
+		t.Errorf("Unexpected body: %s", body)
+	}
+	if w.Header().Get(requestIDHeader) != "req-1" {
+		t.Errorf("Expected the request ID to be echoed, got %q", w.Header().Get(requestIDHeader))
+	}
+	if !strings.Contains(buf.String(), "index out of range") || !strings.Contains(buf.String(), "GET /task/5 500") {
+		t.Errorf("Expected the panic and the request to be logged, got %s", buf.String())
+	}
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	h := requestID(http.NotFoundHandler())
+	for _, sent := range []string{"", "bad id\n", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(requestIDHeader, sent)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if got := w.Header().Get(requestIDHeader); got == "" || got == sent {
+			t.Errorf("Expected a new ID for %q, got %q", sent, got)
+		}
+	}
+}
+
+func TestMiddlewareCORS(t *testing.T) {
+	h := cors([]string{"https://app.example.com"})(http.NotFoundHandler())
+
+	req := httptest.NewRequest("OPTIONS", "/task", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("Unexpected preflight response: %d %v", w.Code, w.Header())
+	}
+
+	req = httptest.NewRequest("GET", "/task", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for another origin, got %v", w.Header())
+	}
+}
+
+func TestMiddlewareMaxBodySize(t *testing.T) {
+	h := maxBodySize(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, "Error reading body", http.StatusBadRequest)
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/task", strings.NewReader("too long")))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/task", strings.NewReader("too long"))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown length body to fail reading, got %d", w.Code)
+	}
+}
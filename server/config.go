@@ -0,0 +1,257 @@
+// Package server is what the task servers of day-12 and day-13 share: their
+// configuration, the migrations of their schema, their HTTP middleware and
+// their health probes.
+package server
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultConfigFile is the .env file read when no -config flag is given.
+const defaultConfigFile = "configs/.env"
+
+// Secret is a string that prints as "****" so it never ends up in a log.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "****"
+}
+
+func (s Secret) GoString() string { return strconv.Quote(s.String()) }
+
+type DBConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password Secret
+	Name     string
+
+	// Pool sizes; zero keeps the database/sql default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// DSN returns the MySQL data source name. It holds the password, so it must
+// not be logged.
+func (d DBConfig) DSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", d.User, string(d.Password), d.Host, d.Port, d.Name)
+}
+
+type ServerConfig struct {
+	Port         int
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// MaxBodyBytes is the largest request body accepted; zero sets no limit.
+	MaxBodyBytes int64
+	// CORSOrigins may call the API from a browser; "*" allows any.
+	CORSOrigins []string
+	// ShutdownTimeout is how long requests in flight get to finish once
+	// the server is told to stop.
+	ShutdownTimeout time.Duration
+}
+
+// Config is the server settings from, in increasing priority, the defaults,
+// a .env file, environment variables and flags.
+type Config struct {
+	DB     DBConfig
+	Server ServerConfig
+	// MigrateOnStart applies pending migrations at startup instead of
+	// refusing to serve.
+	MigrateOnStart bool
+}
+
+// setting is one configuration key, named as its environment variable. The
+// flag is the key in lower case with dashes, e.g. -db-host.
+type setting struct {
+	key   string
+	def   string
+	usage string
+}
+
+var settings = []setting{
+	{"DB_HOST", "localhost", "database host"},
+	{"DB_PORT", "3306", "database port"},
+	{"DB_USER", "root", "database user"},
+	{"DB_PASSWORD", "", "database password"},
+	{"DB_NAME", "taskserver_db", "database name"},
+	{"DB_MAX_OPEN_CONNS", "0", "maximum open connections, 0 for no limit"},
+	{"DB_MAX_IDLE_CONNS", "2", "maximum idle connections"},
+	{"DB_CONN_MAX_LIFETIME", "0s", "maximum connection age, 0 for no limit"},
+	{"HTTP_PORT", "8080", "port to serve HTTP on"},
+	{"SERVER_READ_TIMEOUT", "5s", "maximum time to read a request"},
+	{"SERVER_WRITE_TIMEOUT", "10s", "maximum time to write a response"},
+	{"SERVER_IDLE_TIMEOUT", "120s", "maximum keep-alive idle time"},
+	{"SERVER_MAX_BODY_BYTES", "1048576", "largest request body accepted, 0 for no limit"},
+	{"SERVER_SHUTDOWN_TIMEOUT", "15s", "time given to requests in flight to finish on shutdown"},
+	{"CORS_ALLOWED_ORIGINS", "", "comma separated origins allowed to call the API from a browser"},
+	{"MIGRATE_ON_START", "false", "apply pending migrations at startup"},
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// LoadConfig parses the flags in args and builds the configuration. It
+// returns the arguments left after the flags, such as a migrate command.
+func LoadConfig(args []string) (Config, []string, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	file := fs.String("config", defaultConfigFile, "path of the .env file to read")
+	for _, s := range settings {
+		fs.String(flagName(s.key), s.def, s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	values := map[string]string{}
+	for _, s := range settings {
+		values[s.key] = s.def
+	}
+
+	// A missing file is fine unless it was asked for by name
+	explicit := false
+	fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "config" })
+	if err := readEnvFile(*file, values); err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+		return Config{}, nil, err
+	}
+
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.key); ok {
+			values[s.key] = v
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if flagName(s.key) == f.Name {
+				values[s.key] = f.Value.String()
+			}
+		}
+	})
+
+	cfg, err := parseConfig(values)
+	return cfg, fs.Args(), err
+}
+
+func readEnvFile(path string, values map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("Error closing config file: %v", err)
+		}
+	}()
+
+	if err := readEnv(f, values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// readEnv reads KEY=VALUE lines into values. Blank lines, # comments, an
+// "export " prefix and quotes around the value are allowed.
+func readEnv(r io.Reader, values map[string]string) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return sc.Err()
+}
+
+// parseConfig converts and validates values, reporting every bad setting at
+// once.
+func parseConfig(values map[string]string) (Config, error) {
+	var errs []error
+	integer := func(key string) int {
+		n, err := strconv.Atoi(values[key])
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("%s must be a non-negative integer, got %q", key, values[key]))
+		}
+		return n
+	}
+	duration := func(key string) time.Duration {
+		d, err := time.ParseDuration(values[key])
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("%s must be a non-negative duration such as 5s, got %q", key, values[key]))
+		}
+		return d
+	}
+
+	cfg := Config{
+		DB: DBConfig{
+			Host:            values["DB_HOST"],
+			Port:            integer("DB_PORT"),
+			User:            values["DB_USER"],
+			Password:        Secret(values["DB_PASSWORD"]),
+			Name:            values["DB_NAME"],
+			MaxOpenConns:    integer("DB_MAX_OPEN_CONNS"),
+			MaxIdleConns:    integer("DB_MAX_IDLE_CONNS"),
+			ConnMaxLifetime: duration("DB_CONN_MAX_LIFETIME"),
+		},
+		Server: ServerConfig{
+			Port:            integer("HTTP_PORT"),
+			ReadTimeout:     duration("SERVER_READ_TIMEOUT"),
+			WriteTimeout:    duration("SERVER_WRITE_TIMEOUT"),
+			IdleTimeout:     duration("SERVER_IDLE_TIMEOUT"),
+			MaxBodyBytes:    int64(integer("SERVER_MAX_BODY_BYTES")),
+			ShutdownTimeout: duration("SERVER_SHUTDOWN_TIMEOUT"),
+		},
+	}
+	for _, origin := range strings.Split(values["CORS_ALLOWED_ORIGINS"], ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.Server.CORSOrigins = append(cfg.Server.CORSOrigins, origin)
+		}
+	}
+	migrateOnStart, err := strconv.ParseBool(values["MIGRATE_ON_START"])
+	if err != nil {
+		errs = append(errs, fmt.Errorf("MIGRATE_ON_START must be true or false, got %q", values["MIGRATE_ON_START"]))
+	}
+	cfg.MigrateOnStart = migrateOnStart
+
+	for _, key := range []string{"DB_HOST", "DB_USER", "DB_NAME"} {
+		if values[key] == "" {
+			errs = append(errs, fmt.Errorf("%s is required", key))
+		}
+	}
+	if cfg.DB.Port < 1 || cfg.DB.Port > 65535 {
+		errs = append(errs, errors.New("DB_PORT must be between 1 and 65535"))
+	}
+	if cfg.Server.Port < 1 || cfg.Server.Port > 65535 {
+		errs = append(errs, errors.New("HTTP_PORT must be between 1 and 65535"))
+	}
+	if cfg.DB.MaxOpenConns > 0 && cfg.DB.MaxIdleConns > cfg.DB.MaxOpenConns {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS cannot be more than DB_MAX_OPEN_CONNS"))
+	}
+
+	if len(errs) > 0 {
+		return Config{}, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return cfg, nil
+}
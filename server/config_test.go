@@ -0,0 +1,46 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("DB_PASSWORD=s3cret\nHTTP_PORT=9000\n"), 0o600); err != nil {
+		t.Fatal("Failed to write config file:", err)
+	}
+	t.Setenv("HTTP_PORT", "9001")
+
+	cfg, args, err := LoadConfig([]string{"-config", path, "-db-name", "flagdb", "migrate", "status"})
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	if cfg.DB.DSN() != "root:s3cret@tcp(localhost:3306)/flagdb" || cfg.Server.Port != 9001 {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if len(args) != 2 || args[0] != "migrate" {
+		t.Errorf("Expected the migrate command to be left over, got %v", args)
+	}
+	if out := fmt.Sprintf("%+v", cfg); strings.Contains(out, "s3cret") {
+		t.Errorf("Password leaked: %s", out)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"-http-port", "0"},
+		{"-server-read-timeout", "soon"},
+		{"-db-host", ""},
+		{"-migrate-on-start", "maybe"},
+		{"-server-max-body-bytes", "big"},
+		{"-config", filepath.Join(t.TempDir(), "missing.env")},
+	} {
+		if _, _, err := LoadConfig(args); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// healthCheckTimeout bounds the MySQL ping of a readiness probe.
+const healthCheckTimeout = 2 * time.Second
+
+// shuttingDown is set once the server starts draining, so that readiness
+// probes send no new traffic its way.
+var shuttingDown atomic.Bool
+
+type healthReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Healthz reports that the server is up. It does not look at MySQL, as a
+// restart would not bring it back.
+func Healthz(w http.ResponseWriter, _ *http.Request) {
+	writeHealth(w, http.StatusOK, healthReport{Status: "ok"})
+}
+
+// Readyz reports whether the server can take requests: it is not shutting
+// down and db, a MySQL database, answers a ping.
+func Readyz(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if shuttingDown.Load() {
+			writeHealth(w, http.StatusServiceUnavailable, healthReport{Status: "shutting_down"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+		if err := db.PingContext(ctx); err != nil {
+			log.Printf("Readiness check mysql failed: %v", err)
+			writeHealth(w, http.StatusServiceUnavailable, healthReport{Status: "degraded", Checks: map[string]string{"mysql": "down"}})
+			return
+		}
+		writeHealth(w, http.StatusOK, healthReport{Status: "ok", Checks: map[string]string{"mysql": "ok"}})
+	}
+}
+
+func writeHealth(w http.ResponseWriter, status int, report healthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// Serve runs srv until ctx is done, then stops taking connections and waits
+// up to timeout for the requests in flight. Only a server that fails to
+// start is an error.
+func Serve(ctx context.Context, srv *http.Server, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down, waiting for requests in flight")
+	shuttingDown.Store(true)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown did not finish in time: %v", err)
+		srv.Close()
+	}
+	log.Println("Server stopped")
+	return nil
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// migration is one versioned schema change. down undoes exactly what up does.
+type migration struct {
+	version int
+	name    string
+	up      []string
+	down    []string
+}
+
+// migrations is the schema of the TASKS table, oldest first. Add new changes
+// to the end; never edit one that has been released.
+//
+// SCHEMA_MIGRATIONS holds the versions of whichever app owns the database,
+// and 3layerarch has a TASKS table of its own, so these servers use
+// taskserver_db rather than its test_db.
+var migrations = []migration{
+	{
+		// The table as the server used to create it, so an existing
+		// database adopts the migrations without losing data.
+		version: 1,
+		name:    "create_tasks",
+		up:      []string{"CREATE TABLE IF NOT EXISTS TASKS (id int auto_increment primary key, task text, completed bool)"},
+		down:    []string{"DROP TABLE TASKS"},
+	},
+	{
+		version: 2,
+		name:    "not_null",
+		up: []string{
+			"UPDATE TASKS SET task = '' WHERE task IS NULL",
+			"UPDATE TASKS SET completed = FALSE WHERE completed IS NULL",
+			"ALTER TABLE TASKS MODIFY task TEXT NOT NULL, MODIFY completed BOOL NOT NULL DEFAULT FALSE",
+		},
+		down: []string{"ALTER TABLE TASKS MODIFY task TEXT, MODIFY completed BOOL"},
+	},
+	{
+		version: 3,
+		name:    "indexes",
+		up:      []string{"CREATE INDEX idx_tasks_completed ON TASKS (completed)"},
+		down:    []string{"DROP INDEX idx_tasks_completed ON TASKS"},
+	},
+}
+
+// appliedMigrations returns the versions recorded in SCHEMA_MIGRATIONS,
+// creating the table on first use.
+func appliedMigrations(db *sql.DB) (map[int]bool, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS SCHEMA_MIGRATIONS (
+		version INT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version FROM SCHEMA_MIGRATIONS")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Printf("Error closing rows: %v", err)
+		}
+	}()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+// runMigration executes stmts and then record in one transaction. MySQL
+// commits schema changes implicitly, so a migration that fails part way may
+// need fixing by hand before it is run again.
+func runMigration(db *sql.DB, stmts []string, record string, args ...any) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			log.Printf("Error rolling back migration: %v", err)
+		}
+	}()
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CheckSchema fails if any migration is pending, so the server never runs
+// against an older schema than it was built for.
+func CheckSchema(db *sql.DB) error {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if !applied[m.version] {
+			return fmt.Errorf("database schema is out of date, migration %d %s is pending: run migrate up", m.version, m.name)
+		}
+	}
+	return nil
+}
+
+// Migrate carries out a migrate command and reports on w: up applies
+// every pending migration, down reverts the latest one and status lists them.
+func Migrate(db *sql.DB, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate up|down|status")
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		for _, m := range migrations {
+			if applied[m.version] {
+				continue
+			}
+			if err := runMigration(db, m.up, "INSERT INTO SCHEMA_MIGRATIONS (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+				return fmt.Errorf("migration %d %s: %w", m.version, m.name, err)
+			}
+			fmt.Fprintf(w, "applied %d %s\n", m.version, m.name)
+		}
+		return nil
+	case "down":
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if !applied[m.version] {
+				continue
+			}
+			if err := runMigration(db, m.down, "DELETE FROM SCHEMA_MIGRATIONS WHERE version = ?", m.version); err != nil {
+				return fmt.Errorf("reverting migration %d %s: %w", m.version, m.name, err)
+			}
+			fmt.Fprintf(w, "reverted %d %s\n", m.version, m.name)
+			return nil
+		}
+		return errors.New("no migrations have been applied")
+	case "status":
+		for _, m := range migrations {
+			state := "pending"
+			if applied[m.version] {
+				state = "applied"
+			}
+			fmt.Fprintf(w, "%d %s %s\n", m.version, m.name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, want up, down or status", args[0])
+	}
+}